@@ -1,19 +1,38 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	_ "embed"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"cloud.google.com/go/compute/metadata"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
 	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/analyzer"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/approval"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/audit"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
 	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
 	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/daemon"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/datadog"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/decisionhook"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/redact"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/report"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/rules"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/sandbox"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/secrets"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/version"
 )
 
 var (
@@ -22,11 +41,174 @@ var (
 	dryRun    bool
 	profile   string
 	output    string
+	// Custom scaling conditions
+	scaleUpRule   string
+	scaleDownRule string
+	// Config file flags
+	configPath string
+	configFile *config.File
+	// One-off setting overrides
+	setOverrides  []string
+	setThresholds config.Thresholds
+	// Idempotency flags
+	idempotencyWindow time.Duration
+	// Batch flags
+	instancesFile string
 	// Daemon mode flags
 	daemonMode     bool
 	daemonInterval time.Duration
 	httpPort       int
 	enableMetrics  bool
+	apiToken       string
+	slackWebhook   string
+	slackBotToken  string
+	datadogAPIKey  string
+	datadogSite    string
+	datadogTags    []string
+	pagerDutyKey   string
+	overloadCycles int
+	stableCycles   int
+	pubsubTopic    string
+	issueTracker   string
+	issueToken     string
+	issueAfterDays int
+	webhookURL     string
+	webhookHeaders []string
+	webhookTmpl    string
+	eventFormat    string
+	secretRefresh  time.Duration
+	preScaleMax    time.Duration
+	opJournal      string
+	stateStore     string
+	requireApprove bool
+	approvalStore  string
+	cycleDeadline  time.Duration
+	preflightOnly  bool
+	sampleSize     string
+	sampleStrategy string
+	sampleMaxAge   time.Duration
+	// Shadow evaluation flags
+	shadowProfile     string
+	shadowScaleUpAt   float64
+	shadowScaleDownAt float64
+	// Metrics source flags
+	metricsSource string
+	// Read-only audit flags
+	readOnly bool
+	// Decision hook flags
+	decisionHookURL      string
+	decisionHookSecret   string
+	decisionHookTimeout  time.Duration
+	decisionHookFailOpen bool
+	// Monitoring quota flags
+	monitoringQuota int
+	concurrency     int
+	latencyBudget   time.Duration
+	instanceTimeout time.Duration
+	hedgeAfter      time.Duration
+	// Rate-of-change trigger flags
+	trendWindow          time.Duration
+	cpuTrendThreshold    float64
+	memoryTrendThreshold float64
+	// Predictive scaling flags
+	predictive      bool
+	forecastHorizon time.Duration
+	// Cold start flags
+	coldStart       bool
+	coldStartWindow time.Duration
+	// Fleet signal flags
+	fleetSignals bool
+	// Pricing flags
+	catalogPricing bool
+	// Report ranking flags
+	sortBy  string
+	topN    int
+	sortKey analyzer.SortKey
+	// Output verbosity flags
+	summaryOnly bool
+	sparklines  bool
+	groupBy     string
+	groupKey    analyzer.GroupBy
+	quiet       bool
+	// Post-scale verification flags
+	probeEnabled bool
+	probeTimeout time.Duration
+	probeIPType  string
+	// Replica handling flags
+	replicaPolicy string
+	// Target restriction flags
+	deniedMachineTypes  []string
+	allowedMachineTypes []string
+	connectionCapacity  string
+	connectionMemory    float64
+	// Fleet optimization flags
+	costIncreaseCap float64
+	maxOperations   int
+	bundleDowntime  bool
+	maintenance     string
+	instanceWindows bool
+	downtimeBudget  time.Duration
+	blackouts       []string
+	freezes         []string
+	schedules       []string
+	scheduleLead    time.Duration
+	freezeEmergency float64
+	memoryPressure  []string
+	businessHours   string
+	owners          []string
+	// Scale-up auto-revert flags
+	revertDeadline    time.Duration
+	revertQuietPeriod time.Duration
+	revertApply       bool
+	// Scale-down rollback flags
+	rollbackWindow    time.Duration
+	rollbackThreshold float64
+	// Storage autoscaling flags
+	storageScaling   bool
+	storageThreshold float64
+	storageTarget    float64
+	maxDiskSize      int64
+	shrinkThreshold  float64
+	shrinkHorizon    time.Duration
+	ioBoundThreshold float64
+	stoppedReview    time.Duration
+	// Read replica autoscaling flags
+	replicaScaling   bool
+	replicaThreshold float64
+	replicaScaleDown float64
+	maxReplicaLag    time.Duration
+	minReadReplicas  int
+	maxReadReplicas  int
+	// Calendar flags
+	calendarDays int
+	// Export flags
+	exportFile string
+	// Sandbox flags
+	sandboxFleetSize int
+	sandboxSeed      int64
+	sandboxInterval  time.Duration
+	sandboxHistory   time.Duration
+	sandboxOpDelay   time.Duration
+	sandboxDryRun    bool
+	sandboxForce     bool
+	// Report currency flags
+	currencyCode string
+	currencyRate float64
+	locale       string
+	// Committed use discount flags
+	commitment string
+	// Output redaction flags
+	redactFields []string
+	redactMode   string
+	redactSalt   string
+	// Timestamp display flags
+	timezone string
+	// Approvals command flags
+	approvalsURL     string
+	approvalsToken   string
+	approvalsStatus  string
+	approvalsBy      string
+	approvalsComment string
 )
 
 var rootCmd = &cobra.Command{
@@ -36,175 +218,1934 @@ var rootCmd = &cobra.Command{
 scales instances based on CPU and memory utilization patterns.
 
 It supports both Enterprise and Enterprise Plus editions with awareness
-of scaling constraints and downtime implications.`,
+of scaling constraints and downtime implications.
+
+Pass - as the only argument to read the instances to analyze from stdin,
+one per line or as JSON, e.g. get-prod-dbs | cloudsql-autoscaler -`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 1 || (len(args) == 1 && args[0] != "-") {
+			return fmt.Errorf("unexpected arguments %q (pass - to read instances from stdin)", args)
+		}
+		return nil
+	},
 	RunE: runAutoscaler,
 }
 
-func init() {
-	rootCmd.Flags().StringVar(&projectID, "project", "", "GCP project ID (uses ADC default if not specified)")
-	rootCmd.Flags().StringSliceVar(&instances, "instance", []string{}, "Instance name(s) to analyze (analyzes all if not specified)")
-	rootCmd.Flags().BoolVar(&dryRun, "dry-run", true, "Show what would be done without making changes")
-	rootCmd.Flags().StringVar(&profile, "profile", "default", "Scaling profile (default, conservative, aggressive)")
-	rootCmd.Flags().StringVar(&output, "output", "table", "Output format (table, json)")
+var calendarCmd = &cobra.Command{
+	Use:   "calendar",
+	Short: "Show upcoming scaling operations, cooldowns and blackout windows",
+	Long: `calendar analyzes the fleet and prints a chronological view of what the
+autoscaler will do: scheduled operations, operations deferred to later
+windows, cooldown expirations and blackout windows.`,
+	Args: cobra.NoArgs,
+	RunE: runCalendar,
+}
+
+var exportRecommenderCmd = &cobra.Command{
+	Use:   "export-recommender",
+	Short: "Export scaling recommendations in the Cloud Recommender JSON format",
+	Long: `export-recommender analyzes the fleet and writes each recommended scaling
+operation as a Cloud Recommender recommendation with an associated insight,
+for FinOps pipelines that already ingest Recommender exports.`,
+	Args: cobra.NoArgs,
+	RunE: runExportRecommender,
+}
+
+var exportMetricsCmd = &cobra.Command{
+	Use:   "export-metrics",
+	Short: "Export instances and their metrics for offline analysis",
+	Long: `export-metrics writes the project's instances and the metrics the analysis
+would use for each to a JSON dump. Pass it back with
+--metrics-source file://PATH to review decisions without access to the
+project, reproduce production decisions, or try threshold changes.`,
+	Args: cobra.NoArgs,
+	RunE: runExportMetrics,
+}
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Capture the fleet's state to a file for later diffing",
+	Long: `snapshot analyzes the fleet and writes its state to a JSON file: each
+instance's machine type, policy, utilization and recommendation, the number of
+instances of each machine type, and the project summary. Compare two snapshots
+with snapshot diff to see what changed between them, e.g. from one weekly
+capacity review to the next.`,
+	Args: cobra.NoArgs,
+	RunE: runSnapshot,
+}
+
+var snapshotDiffCmd = &cobra.Command{
+	Use:   "diff BEFORE AFTER",
+	Short: "Show what changed between two snapshots",
+	Long: `snapshot diff compares two files written by snapshot and lists the
+instances added and removed, the changes to each instance present in both,
+and how the number of instances of each machine type and the fleet totals
+moved.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runSnapshotDiff,
+}
+
+var sandboxCmd = &cobra.Command{
+	Use:   "sandbox",
+	Short: "Run the daemon against a simulated project, without GCP",
+	Long: `sandbox runs the autoscaling daemon against an in-memory project with a
+synthetic fleet and workload, and serves a dashboard of its decisions. Resizes
+complete after a short delay and change the utilization the workload
+produces, so the whole loop can be watched without a Google Cloud project or
+credentials. Changes are applied to the simulated fleet unless --dry-run is
+set.`,
+	Args: cobra.NoArgs,
+	RunE: runSandbox,
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the build's version, commit and supported API versions",
+	Long: `version prints the semantic version, git commit and build date of this
+binary, and the versions of the interfaces other tools depend on: the daemon
+HTTP API and the --output json schema. A running daemon reports the same at
+/api/v1/version.`,
+	Args: cobra.NoArgs,
+	RunE: runVersion,
+}
+
+var approvalsCmd = &cobra.Command{
+	Use:   "approvals",
+	Short: "List, approve and reject scaling operations awaiting approval",
+	Long: `approvals reviews the scaling operations a daemon started with
+--require-approval holds until an operator approves them. It talks to the
+daemon's API, authenticating with its --api-token.`,
+}
+
+var approvalsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List approval requests, newest first",
+	Args:  cobra.NoArgs,
+	RunE:  runApprovalsList,
+}
+
+var approvalsApproveCmd = &cobra.Command{
+	Use:   "approve ID",
+	Short: "Approve a scaling operation; it is applied once eligible",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runApprovalsDecide,
+}
+
+var approvalsRejectCmd = &cobra.Command{
+	Use:   "reject ID",
+	Short: "Reject a scaling operation; it is not applied while it stays planned",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runApprovalsDecide,
+}
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of --output json",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, err := os.Stdout.Write(outputSchema)
+		return err
+	},
+}
+
+func init() {
+	// Set here rather than in rootCmd, which loadConfigFile refers to
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := applySetOverrides(cmd); err != nil {
+			return err
+		}
+		if err := loadConfigFile(cmd, args); err != nil {
+			return err
+		}
+		return setupTimestamps()
+	}
+
+	rootCmd.PersistentFlags().StringVar(&projectID, "project", "", "GCP project ID (uses ADC default if not specified)")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "YAML file of settings keyed by flag name, daemon settings, scaling thresholds and per-instance overrides; flags given on the command line take precedence")
+	rootCmd.PersistentFlags().StringArrayVar(&setOverrides, "set", []string{}, "Override one setting for this run as KEY=VALUE, e.g. scale_up_threshold=0.85: a scaling threshold, a flag or daemon.KEY, taking precedence over --config and the profile (repeatable)")
+	rootCmd.Flags().StringSliceVar(&instances, "instance", []string{}, "Instance name(s) to analyze (analyzes all if not specified)")
+	rootCmd.Flags().StringVar(&instancesFile, "instances-file", "", "File listing instances to analyze, one per line or as JSON (- = stdin)")
+	rootCmd.Flags().BoolVar(&dryRun, "dry-run", true, "Show what would be done without making changes")
+	rootCmd.PersistentFlags().DurationVar(&idempotencyWindow, "idempotency-window", time.Hour, "Apply the same change to an instance at most once per window of this length, however often it is retried (0 = until another change)")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "default", "Scaling profile (default, conservative, aggressive)")
+	rootCmd.PersistentFlags().StringVar(&scaleUpRule, "scale-up-rule", "", "CEL condition over the metrics summary that triggers a scale-up instead of the scale-up threshold, e.g. 'cpu.p95 > 85 && connections.max > 400' (empty = threshold)")
+	rootCmd.PersistentFlags().StringVar(&scaleDownRule, "scale-down-rule", "", "CEL condition over the metrics summary that triggers a scale-down instead of the scale-down threshold, e.g. 'cpu.p95 < 30 && memory.p95_pct < 40' (empty = threshold)")
+	rootCmd.PersistentFlags().StringVar(&output, "output", "table", "Output format (table, json; html for a project report with utilization charts)")
+	rootCmd.Flags().StringVar(&sortBy, "sort", "name", "Sort results by (name, savings, pressure, priority)")
+	rootCmd.Flags().IntVar(&topN, "top", 0, "Only report and apply the first N results after sorting; skipped instances are listed besides them (0 = all)")
+	rootCmd.Flags().BoolVar(&summaryOnly, "summary", false, "Print only the aggregate project summary")
+	rootCmd.Flags().BoolVar(&sparklines, "sparklines", false, "Add CPU and memory sparklines over the metrics period to table output")
+	rootCmd.Flags().StringVar(&groupBy, "group-by", "", "Roll the project summary up by team, region, env or label:<key> (implies --summary)")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Suppress progress output")
+
+	// Daemon mode flags
+	rootCmd.Flags().BoolVar(&daemonMode, "daemon", false, "Run in continuous daemon mode")
+	rootCmd.Flags().DurationVar(&daemonInterval, "interval", 30*time.Minute, "Interval between autoscaling checks in daemon mode")
+	rootCmd.Flags().IntVar(&httpPort, "http-port", 8080, "HTTP port for health checks and metrics")
+	rootCmd.Flags().BoolVar(&enableMetrics, "metrics", true, "Enable Prometheus metrics endpoint")
+	secretVar(rootCmd.Flags(), &apiToken, "api-token", os.Getenv("CLOUDSQL_AUTOSCALER_API_TOKEN"), "Bearer token for mutating API endpoints, or env:NAME, file://PATH or sm://projects/P/secrets/S to load it from (default $CLOUDSQL_AUTOSCALER_API_TOKEN; empty disables them)")
+	secretVar(rootCmd.Flags(), &slackWebhook, "slack-webhook", os.Getenv("CLOUDSQL_AUTOSCALER_SLACK_WEBHOOK"), "Slack incoming webhook URL to post recommendations, applied changes and failures to, or env:NAME, file://PATH or sm://projects/P/secrets/S to load it from (default $CLOUDSQL_AUTOSCALER_SLACK_WEBHOOK; empty disables)")
+	secretVar(rootCmd.Flags(), &slackBotToken, "slack-bot-token", os.Getenv("CLOUDSQL_AUTOSCALER_SLACK_BOT_TOKEN"), "Slack bot token (chat:write) to post each change to its owner's Slack channel with instead of --slack-webhook, or env:NAME, file://PATH or sm://projects/P/secrets/S to load it from (default $CLOUDSQL_AUTOSCALER_SLACK_BOT_TOKEN; empty posts everything to --slack-webhook)")
+	secretVar(rootCmd.Flags(), &datadogAPIKey, "datadog-api-key", os.Getenv("DD_API_KEY"), "Datadog API key to send metrics and scaling events to Datadog with, or env:NAME, file://PATH or sm://projects/P/secrets/S to load it from (default $DD_API_KEY; empty disables)")
+	rootCmd.Flags().StringVar(&datadogSite, "datadog-site", os.Getenv("DD_SITE"), "Datadog site, e.g. datadoghq.eu or us5.datadoghq.com (default $DD_SITE, else datadoghq.com)")
+	rootCmd.Flags().StringSliceVar(&datadogTags, "datadog-tag", nil, "Tag added to every Datadog metric and event, e.g. env:prod (repeatable)")
+	secretVar(rootCmd.Flags(), &pagerDutyKey, "pagerduty-routing-key", os.Getenv("CLOUDSQL_AUTOSCALER_PAGERDUTY_ROUTING_KEY"), "PagerDuty Events API v2 integration key to page on failed resizes and overloaded instances with, or env:NAME, file://PATH or sm://projects/P/secrets/S to load it from (default $CLOUDSQL_AUTOSCALER_PAGERDUTY_ROUTING_KEY; empty disables)")
+	rootCmd.Flags().StringVar(&pubsubTopic, "pubsub-topic", "", "Pub/Sub topic ID or projects/P/topics/T to publish analysis_completed, scaling_planned, scaling_applied and scaling_failed events to (empty disables)")
+	rootCmd.Flags().StringVar(&issueTracker, "issue-tracker", "", "File a ticket for scale-down recommendations left standing, in github:OWNER/REPO or jira:https://HOST/PROJECT, and close it once resolved (empty disables)")
+	secretVar(rootCmd.Flags(), &issueToken, "issue-tracker-token", os.Getenv("CLOUDSQL_AUTOSCALER_ISSUE_TRACKER_TOKEN"), "GitHub token, Jira Cloud EMAIL:API_TOKEN or Jira Data Center personal access token for --issue-tracker, or env:NAME, file://PATH or sm://projects/P/secrets/S to load it from (default $CLOUDSQL_AUTOSCALER_ISSUE_TRACKER_TOKEN)")
+	rootCmd.Flags().IntVar(&issueAfterDays, "issue-after-days", 14, "Days a scale-down recommendation must stand unapplied before --issue-tracker files it")
+	secretVar(rootCmd.Flags(), &webhookURL, "webhook-url", os.Getenv("CLOUDSQL_AUTOSCALER_WEBHOOK_URL"), "URL to POST recommendations, applied changes, failures and overloads to, or env:NAME, file://PATH or sm://projects/P/secrets/S to load it from (default $CLOUDSQL_AUTOSCALER_WEBHOOK_URL; empty disables)")
+	rootCmd.Flags().StringArrayVar(&webhookHeaders, "webhook-header", nil, "Header to send to --webhook-url as 'NAME: VALUE'; the value may be a secret reference (repeatable)")
+	secretFlags["webhook-header"] = true
+	rootCmd.Flags().StringVar(&webhookTmpl, "webhook-template", "", "File with a Go template rendering --webhook-url request bodies (default: the message as JSON)")
+	rootCmd.Flags().StringVar(&eventFormat, "event-format", daemon.EventFormatNative, "Format of Pub/Sub messages and webhook bodies: native, or cloudevents to wrap them in CloudEvents 1.0 envelopes")
+	rootCmd.Flags().IntVar(&overloadCycles, "overload-cycles", 3, "Notify instances above the scale-up thresholds for this many consecutive cycles, e.g. at their largest tier or frozen (0 disables)")
+	rootCmd.Flags().IntVar(&stableCycles, "stable-cycles", 1, "Apply a recommendation only once this many consecutive cycles made it, except emergency scale-ups and rollbacks (1 = at once)")
+	rootCmd.Flags().DurationVar(&secretRefresh, "secret-refresh", 5*time.Minute, "How often to re-read secrets loaded from files or Secret Manager (0 = load once)")
+	rootCmd.Flags().DurationVar(&preScaleMax, "prescale-max-duration", 24*time.Hour, "Longest pre-scale an external system may request")
+	rootCmd.Flags().StringVar(&opJournal, "operation-journal", "", "File persisting in-flight scaling operations so a restarted daemon resumes them (empty disables)")
+	rootCmd.Flags().StringVar(&stateStore, "state-store", "", "File, gs://BUCKET/OBJECT or firestore://COLLECTION/DOCUMENT persisting last-scaled times, pre-scales and API freezes across restarts (empty disables)")
+	rootCmd.Flags().BoolVar(&requireApprove, "require-approval", false, "Apply scaling operations only once approved with the approvals command or API; requires --approval-store and --api-token")
+	rootCmd.Flags().StringVar(&approvalStore, "approval-store", "", "File the approval requests of --require-approval are kept in")
+	rootCmd.Flags().DurationVar(&cycleDeadline, "cycle-deadline", 15*time.Minute, "Abort a daemon cycle still running after this long and start the next one cleanly (0 disables)")
+	rootCmd.Flags().BoolVar(&preflightOnly, "preflight-only", false, "Run the daemon's startup preflight, print it and exit, non-zero if a check failed, without starting the daemon")
+	rootCmd.Flags().StringVar(&sampleSize, "sample", "", "Analyze only this share of instances per cycle, e.g. 20% (empty analyzes all)")
+	rootCmd.Flags().StringVar(&sampleStrategy, "sample-strategy", "rotate", "How sampled instances are chosen: rotate (stalest first) or priority (weighted by last priority)")
+	rootCmd.Flags().DurationVar(&sampleMaxAge, "sample-max-age", 6*time.Hour, "Longest an instance may go unanalyzed when sampling")
+	rootCmd.Flags().StringVar(&shadowProfile, "shadow-profile", "", "Evaluate this scaling profile in shadow each daemon cycle and report differing decisions")
+	rootCmd.Flags().Float64Var(&shadowScaleUpAt, "shadow-scale-up-threshold", 0, "Scale-up threshold (0-1) of the shadow config (0 = same as active or --shadow-profile)")
+	rootCmd.Flags().Float64Var(&shadowScaleDownAt, "shadow-scale-down-threshold", 0, "Scale-down threshold (0-1) of the shadow config (0 = same as active or --shadow-profile)")
+
+	rootCmd.Flags().BoolVar(&probeEnabled, "probe", false, "Probe instance connectivity after scaling before declaring success: the database must answer its protocol handshake on the instance IP (instances reachable only through the Cloud SQL connectors or Auth Proxy cannot be probed)")
+	rootCmd.Flags().DurationVar(&probeTimeout, "probe-timeout", 5*time.Minute, "How long a resized instance has to accept connections")
+	rootCmd.Flags().StringVar(&probeIPType, "probe-ip-type", "PRIMARY", "Instance IP address type to probe (PRIMARY, PRIVATE)")
+
+	rootCmd.PersistentFlags().StringVar(&replicaPolicy, "replica-policy", "parity", "Failover/DR replica policy: parity (resize with primary) or exclude")
+	rootCmd.PersistentFlags().StringVar(&connectionCapacity, "connection-capacity", "block", "Scale-downs whose default max_connections is below peak connections: block (hold them) or warn")
+	rootCmd.PersistentFlags().Float64Var(&connectionMemory, "connection-memory-mb", 0, "Estimated memory per connection a scale-down's target must hold next to its buffer pool (0 = engine estimate: 10 PostgreSQL, 4 MySQL, 0.5 SQL Server)")
+	rootCmd.PersistentFlags().StringSliceVar(&deniedMachineTypes, "deny-machine-type", []string{}, "Machine type never recommended as a target: a name, a glob such as db-e2-* or shared-core (repeatable)")
+	rootCmd.PersistentFlags().StringSliceVar(&allowedMachineTypes, "allow-machine-type", []string{}, "Only machine types recommended as a target, named as --deny-machine-type, e.g. db-custom-* (repeatable; default all)")
+
+	rootCmd.PersistentFlags().Float64Var(&costIncreaseCap, "cost-increase-cap", 0, "Max net monthly cost increase applied per run/cycle in dollars (0 = unlimited)")
+	rootCmd.PersistentFlags().IntVar(&maxOperations, "max-operations", 0, "Max scaling operations applied per run/cycle (0 = unlimited)")
+	rootCmd.PersistentFlags().BoolVar(&bundleDowntime, "bundle-downtime", false, "Run all downtime-causing operations together in one shared window")
+	rootCmd.PersistentFlags().StringVar(&maintenance, "maintenance-night", "", "Hold downtime-causing operations for one weekly window, as DAY HH:MM-HH:MM [TZ], e.g. 'sat 22:00-04:00 Europe/London', and run them in sequence during it (empty = any time)")
+	rootCmd.PersistentFlags().DurationVar(&downtimeBudget, "downtime-budget", 0, "Monthly downtime each instance may spend on resizes; resizes whose estimated downtime exceeds what is left wait for next month. Instances override it with an autoscaler-downtime-budget label (0 = no budget)")
+	rootCmd.PersistentFlags().BoolVar(&instanceWindows, "maintenance-windows", false, "Hold downtime-causing operations for each instance's own Cloud SQL maintenance window; instances without one use --maintenance-night")
+
+	rootCmd.PersistentFlags().DurationVar(&trendWindow, "trend-window", 3*time.Hour, "Window over which a sustained utilization climb triggers a preemptive scale-up")
+	rootCmd.PersistentFlags().Float64Var(&cpuTrendThreshold, "cpu-trend-threshold", 0, "CPU climb in percentage points/hour that triggers a preemptive scale-up (0 = off)")
+	rootCmd.PersistentFlags().Float64Var(&memoryTrendThreshold, "memory-trend-threshold", 0, "Memory climb in percentage points/hour that triggers a preemptive scale-up (0 = off)")
+	rootCmd.PersistentFlags().BoolVar(&predictive, "predictive", false, "Forecast CPU and memory from trend and daily seasonality, and scale up before the forecast crosses the scale-up threshold")
+	rootCmd.PersistentFlags().DurationVar(&forecastHorizon, "forecast-horizon", 24*time.Hour, "How far ahead --predictive forecasts (1h to 7d)")
+	rootCmd.PersistentFlags().BoolVar(&coldStart, "cold-start", false, "Judge instances with less history than --metrics-period on the history they have, once it spans --cold-start-window")
+	rootCmd.PersistentFlags().DurationVar(&coldStartWindow, "cold-start-window", 24*time.Hour, "History --cold-start needs before deciding (1h up to --metrics-period)")
+	rootCmd.PersistentFlags().BoolVar(&fleetSignals, "fleet-signals", false, "Merge the Recommender API recommendations and insights Database Center shows for each instance into its analysis")
+	rootCmd.PersistentFlags().BoolVar(&catalogPricing, "catalog-pricing", true, "Price cost estimates at the Cloud Billing Catalog API's rates for each instance's region and edition; the built-in list prices are used where it cannot be read")
+	rootCmd.PersistentFlags().StringArrayVar(&owners, "owner", []string{}, "Instance ownership INSTANCE:KEY=VALUE,... with keys team, contact, channel and notes; overrides the team, owner and slack-channel labels (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&businessHours, "business-hours", "", "Judge scale-down on metrics from these hours only, as DAYS RANGES [TZ], e.g. 'mon-fri 09:00-18:00 Europe/London' (empty = all hours)")
+	rootCmd.PersistentFlags().StringArrayVar(&memoryPressure, "memory-pressure", []string{}, "Memory pressure mode ENGINE=MODE: total, noncache (exclude page cache) or corroborated (require swapping or connection saturation) (repeatable)")
+
+	rootCmd.PersistentFlags().DurationVar(&revertDeadline, "revert-scale-ups", 0, "Consider reverting a reactive scale-up for this long after it is applied (0 = off)")
+	rootCmd.PersistentFlags().DurationVar(&revertQuietPeriod, "revert-quiet-period", 24*time.Hour, "How long utilization on the original machine type must stay below target before a scale-up is reverted")
+	rootCmd.PersistentFlags().BoolVar(&revertApply, "revert-apply", false, "Apply scale-up reverts; otherwise they are recommended only")
+	rootCmd.PersistentFlags().DurationVar(&rollbackWindow, "rollback-window", 0, "Roll a scale-down back to the previous machine type when it regresses within this long of being applied (0 = off)")
+	rootCmd.PersistentFlags().Float64Var(&rollbackThreshold, "rollback-threshold", 0.9, "P95 CPU or memory utilization (0-1) after a scale-down that --rollback-window treats as a regression")
+
+	rootCmd.PersistentFlags().BoolVar(&storageScaling, "storage-scaling", false, "Apply recommended data disk size increases; disks can never shrink again")
+	rootCmd.PersistentFlags().Float64Var(&storageThreshold, "storage-threshold", 0.85, "Fraction of the data disk used (0-1) at which an increase is recommended (0 = off)")
+	rootCmd.PersistentFlags().Float64Var(&storageTarget, "storage-target", 0.7, "Fraction of the data disk used (0-1) after a recommended increase")
+	rootCmd.PersistentFlags().Float64Var(&shrinkThreshold, "disk-shrink-threshold", 0.4, "Fraction of the data disk used (0-1) below which the savings of migrating to a smaller disk are reported (0 = off)")
+	rootCmd.PersistentFlags().DurationVar(&shrinkHorizon, "disk-shrink-horizon", 4320*time.Hour, "Disk usage growth a right-sized disk leaves room for, at --storage-target")
+	rootCmd.PersistentFlags().Int64Var(&maxDiskSize, "max-disk-size", 0, "Largest data disk size in GB an increase may recommend (0 = platform limit)")
+	rootCmd.PersistentFlags().DurationVar(&stoppedReview, "stopped-review-age", 0, "How long an instance may stay stopped before its deletion is suggested for review (0 = never)")
+	rootCmd.PersistentFlags().Float64Var(&ioBoundThreshold, "io-bound-threshold", 0.8, "Fraction of the data disk's IOPS or throughput limit (0-1) at P95 at which an instance is IO-bound and CPU-based resizes are held (0 = off)")
+
+	rootCmd.PersistentFlags().BoolVar(&replicaScaling, "replica-scaling", false, "Apply recommended read replica additions and removals")
+	rootCmd.PersistentFlags().Float64Var(&replicaThreshold, "replica-threshold", 0.75, "Mean read replica P95 CPU (0-1) at which a read replica is added (0 = off)")
+	rootCmd.PersistentFlags().Float64Var(&replicaScaleDown, "replica-scale-down-threshold", 0.3, "Mean read replica P95 CPU (0-1) below which an autoscaler-created read replica is removed")
+	rootCmd.PersistentFlags().DurationVar(&maxReplicaLag, "max-replica-lag", time.Minute, "Replication lag at which a read replica is added, a lagging replica is not scaled down and sustained lag scales it up (0 = ignore lag)")
+	rootCmd.PersistentFlags().IntVar(&minReadReplicas, "min-read-replicas", 1, "Fewest read replicas kept per primary")
+	rootCmd.PersistentFlags().IntVar(&maxReadReplicas, "max-read-replicas", 5, "Most read replicas per primary (0 = no limit)")
+
+	rootCmd.PersistentFlags().StringVar(&metricsSource, "metrics-source", "", "Read instances and metrics from file://PATH (written by export-metrics) instead of the Google APIs")
+	rootCmd.PersistentFlags().BoolVar(&readOnly, "read-only", false, "Never call a mutating Cloud SQL Admin API method, e.g. to report with a viewer-only service account (implies --dry-run)")
+	secretVar(rootCmd.PersistentFlags(), &decisionHookURL, "decision-hook-url", os.Getenv("CLOUDSQL_AUTOSCALER_DECISION_HOOK_URL"), "URL each proposed resize is POSTed to for an allow, deny or modify verdict, or env:NAME, file://PATH or sm://projects/P/secrets/S to load it from (default $CLOUDSQL_AUTOSCALER_DECISION_HOOK_URL; empty disables)")
+	secretVar(rootCmd.PersistentFlags(), &decisionHookSecret, "decision-hook-secret", os.Getenv("CLOUDSQL_AUTOSCALER_DECISION_HOOK_SECRET"), "Key signing decision hook requests and verifying its responses, or a secret reference (default $CLOUDSQL_AUTOSCALER_DECISION_HOOK_SECRET; empty leaves them unsigned)")
+	rootCmd.PersistentFlags().DurationVar(&decisionHookTimeout, "decision-hook-timeout", decisionhook.DefaultTimeout, "Longest the decision hook may take to answer")
+	rootCmd.PersistentFlags().BoolVar(&decisionHookFailOpen, "decision-hook-fail-open", false, "Let resizes go ahead unreviewed when the decision hook fails, instead of holding them")
+	rootCmd.PersistentFlags().DurationVar(&latencyBudget, "latency-budget", 30*time.Second, "Per-instance analysis time above which an instance is reported as slow (0 = off)")
+	rootCmd.PersistentFlags().DurationVar(&instanceTimeout, "instance-timeout", 2*time.Minute, "Skip an instance whose analysis takes longer than this so it cannot hold up the rest (0 = no limit)")
+	rootCmd.PersistentFlags().DurationVar(&hedgeAfter, "hedge-after", 0, "Send a duplicate Cloud Monitoring request when one takes longer than this and use the first answer (0 = off)")
+	rootCmd.PersistentFlags().IntVar(&monitoringQuota, "monitoring-quota", 600, "Max Cloud Monitoring ListTimeSeries calls per minute (0 = unlimited)")
+	rootCmd.PersistentFlags().IntVar(&concurrency, "concurrency", 4, "Instances analyzed at once when analyzing the project (1 = one at a time)")
+
+	rootCmd.PersistentFlags().StringVar(&currencyCode, "currency", "USD", "ISO 4217 currency that cost estimates are reported in")
+	rootCmd.PersistentFlags().Float64Var(&currencyRate, "currency-rate", 0, "Units of --currency per US dollar (required unless USD)")
+	rootCmd.PersistentFlags().StringVar(&locale, "locale", "en-US", "Locale for number and currency formatting, e.g. de-DE")
+	rootCmd.PersistentFlags().StringVar(&commitment, "cud", "", "Committed use discount as TERM:PERCENT, e.g. 3yr:70 when a three-year commitment covers 70% of vCPU and memory spend; scale-downs save only the uncommitted part (empty = none)")
+	rootCmd.PersistentFlags().StringSliceVar(&redactFields, "redact", []string{}, "Mask these in JSON output and notifications: instances, projects, costs (comma-separated)")
+	rootCmd.PersistentFlags().StringVar(&redactMode, "redact-mode", string(config.RedactHash), "How --redact masks names: hash (stable pseudonyms) or truncate (first characters kept)")
+	rootCmd.PersistentFlags().StringVar(&redactSalt, "redact-salt", "", "Secret mixed into hashed pseudonyms so they cannot be reversed by hashing known names")
+	secretFlags["redact-salt"] = true
+	rootCmd.PersistentFlags().StringVar(&timezone, "timezone", "UTC", "IANA time zone that timestamps in table output, logs, reasons and notifications are shown in, e.g. America/New_York; JSON timestamps are always UTC")
+
+	rootCmd.PersistentFlags().StringArrayVar(&blackouts, "blackout", []string{}, "Blackout window START/END[=REASON] in RFC3339 during which no scaling runs (repeatable)")
+	rootCmd.PersistentFlags().StringArrayVar(&freezes, "freeze", []string{}, "Scaling freeze SCOPE/UNTIL=REASON where SCOPE is global, project:ID or label:KEY:VALUE (repeatable)")
+	rootCmd.PersistentFlags().StringArrayVar(&schedules, "schedule", []string{}, "Scheduled scaling SELECTOR=TARGET@CRON[ TZ]/DURATION[=REASON] where SELECTOR is an instance or label:KEY:VALUE and TARGET a machine type or profile:NAME (repeatable)")
+	rootCmd.PersistentFlags().DurationVar(&scheduleLead, "schedule-lead", 15*time.Minute, "How long before a schedule window its instances are scaled up; the daemon uses at least --interval")
+	rootCmd.PersistentFlags().Float64Var(&freezeEmergency, "freeze-emergency-threshold", 95, "P95 CPU or memory percentage at which scale-ups run despite a freeze (0 = never)")
+
+	calendarCmd.Flags().IntVar(&calendarDays, "days", 7, "Number of days ahead to show")
+	rootCmd.AddCommand(calendarCmd)
+	exportRecommenderCmd.Flags().StringVar(&exportFile, "file", "", "Write the export to this file instead of stdout")
+	rootCmd.AddCommand(exportRecommenderCmd)
+	exportMetricsCmd.Flags().StringVar(&exportFile, "file", "", "Write the export to this file instead of stdout")
+	rootCmd.AddCommand(exportMetricsCmd)
+	snapshotCmd.Flags().StringVar(&exportFile, "file", "", "Write the snapshot to this file instead of stdout")
+	snapshotCmd.AddCommand(snapshotDiffCmd)
+	rootCmd.AddCommand(snapshotCmd)
+	rootCmd.AddCommand(schemaCmd)
+	approvalsCmd.PersistentFlags().StringVar(&approvalsURL, "daemon-url", "http://localhost:8080", "Base URL of the daemon's API")
+	secretVar(approvalsCmd.PersistentFlags(), &approvalsToken, "api-token", os.Getenv("CLOUDSQL_AUTOSCALER_API_TOKEN"), "The daemon's API token, or env:NAME, file://PATH or sm://projects/P/secrets/S to load it from (default $CLOUDSQL_AUTOSCALER_API_TOKEN)")
+	approvalsListCmd.Flags().StringVar(&approvalsStatus, "status", "", "List only requests in this status (pending, approved, rejected, applied, failed, expired)")
+	for _, cmd := range []*cobra.Command{approvalsApproveCmd, approvalsRejectCmd} {
+		cmd.Flags().StringVar(&approvalsBy, "by", os.Getenv("USER"), "Who is deciding, recorded with the decision (default $USER)")
+		cmd.Flags().StringVar(&approvalsComment, "comment", "", "Comment recorded with the decision")
+	}
+	approvalsCmd.AddCommand(approvalsListCmd, approvalsApproveCmd, approvalsRejectCmd)
+	rootCmd.AddCommand(approvalsCmd)
+	rootCmd.AddCommand(versionCmd)
+	rootCmd.Version = version.Get().Version
+	sandboxCmd.Flags().IntVar(&sandboxFleetSize, "fleet-size", sandbox.DefaultFleetSize, "Number of simulated instances")
+	sandboxCmd.Flags().Int64Var(&sandboxSeed, "seed", 1, "Seed for the simulated fleet's workload")
+	sandboxCmd.Flags().DurationVar(&sandboxInterval, "interval", time.Minute, "Interval between autoscaling checks")
+	sandboxCmd.Flags().DurationVar(&sandboxHistory, "history", 6*time.Hour, "Metrics history analyzed each cycle; shorter histories react to resizes sooner")
+	sandboxCmd.Flags().DurationVar(&sandboxOpDelay, "operation-delay", sandbox.DefaultOperationDelay, "How long a simulated resize takes")
+	sandboxCmd.Flags().BoolVar(&sandboxDryRun, "dry-run", false, "Recommend without resizing the simulated fleet")
+	sandboxCmd.Flags().BoolVar(&sandboxForce, "force", true, "Resize simulated instances even when the resize would cause downtime")
+	sandboxCmd.Flags().IntVar(&httpPort, "http-port", 8080, "HTTP port for the dashboard, API and metrics")
+	rootCmd.AddCommand(sandboxCmd)
+}
+
+func main() {
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if hint := cloudsql.Hint(err); hint != "" {
+			fmt.Fprintf(os.Stderr, "Hint: %s\n", hint)
+		}
+		os.Exit(1)
+	}
+}
+
+type OutputResult struct {
+	Instance        string                `json:"instance"`
+	InstanceID      string                `json:"instance_id,omitempty"` // PROJECT:REGION:NAME; unset for instances not read
+	CurrentType     string                `json:"current_type"`
+	CurrentCPU      int                   `json:"current_cpu"`
+	CurrentMemoryGB float64               `json:"current_memory_gb"`
+	RecommendedType string                `json:"recommended_type,omitempty"`
+	DecisionID      string                `json:"decision_id,omitempty"`
+	DecisionKey     string                `json:"decision_key,omitempty"`
+	Action          string                `json:"action"`
+	Reason          string                `json:"reason"`
+	ReasonCode      string                `json:"reason_code,omitempty"`
+	ReasonCodes     []cloudsql.ReasonCode `json:"reason_codes,omitempty"` // Primary first, then the deferral's code when deferred
+	DowntimeWarning string                `json:"downtime_warning,omitempty"`
+	DeferReason     string                `json:"defer_reason,omitempty"`
+	DeferKind       string                `json:"defer_kind,omitempty"`
+	EligibleAt      *time.Time            `json:"eligible_at,omitempty"`
+	SkipReason      string                `json:"skip_reason,omitempty"`
+	StoppedAt       *time.Time            `json:"stopped_at,omitempty"`      // When a skipped, stopped instance was stopped
+	DeletionReview  bool                  `json:"deletion_review,omitempty"` // Stopped longer than --stopped-review-age
+	Applied         bool                  `json:"applied"`
+	Error           string                `json:"error,omitempty"`
+	Hint            string                `json:"hint,omitempty"`
+	Timestamp       time.Time             `json:"timestamp"`
+
+	Warnings []rules.Warning `json:"warnings,omitempty"`
+	Owner    *config.Owner   `json:"owner,omitempty"`
+
+	// Instance storage, pricing and flag details
+	DiskSizeGB               int64             `json:"disk_size_gb,omitempty"`
+	DiskType                 string            `json:"disk_type,omitempty"`
+	StorageAutoResize        bool              `json:"storage_auto_resize,omitempty"`
+	StorageAutoResizeLimitGB int64             `json:"storage_auto_resize_limit_gb,omitempty"`
+	PricingPlan              string            `json:"pricing_plan,omitempty"`
+	DatabaseFlags            map[string]string `json:"database_flags,omitempty"`
+
+	// Disk size recommendation, when the disk is over the storage threshold, and its outcome
+	Storage            *cloudsql.StorageDecision `json:"storage,omitempty"`
+	StorageApplied     bool                      `json:"storage_applied,omitempty"`
+	StorageError       string                    `json:"storage_error,omitempty"`
+	StorageDeferReason string                    `json:"storage_defer_reason,omitempty"`
+
+	// Over-provisioned disk, with the savings of migrating to a smaller one
+	DiskShrink *cloudsql.DiskAdvisory `json:"disk_shrink,omitempty"`
+
+	// Read replica count recommendation for a primary with read replicas, and its outcome
+	Replicas *ReplicaOutput `json:"replicas,omitempty"`
+
+	// Projected peak utilization, with --predictive
+	Forecast *analyzer.Forecast `json:"forecast,omitempty"`
+
+	// History shortfall, with --cold-start on instances with short history
+	ColdStart *analyzer.ColdStart `json:"cold_start,omitempty"`
+
+	// Monthly downtime budget and its use, on instances with one
+	DowntimeBudget *analyzer.DowntimeBudget `json:"downtime_budget,omitempty"`
+
+	// Recommender recommendations and insights, with --fleet-signals
+	FleetSignals []cloudsql.FleetSignal `json:"fleet_signals,omitempty"`
+}
+
+// ReplicaOutput is a read replica count recommendation and its outcome
+type ReplicaOutput struct {
+	*cloudsql.ReplicaDecision
+	Applied     bool   `json:"applied,omitempty"`
+	Error       string `json:"error,omitempty"`
+	DeferReason string `json:"defer_reason,omitempty"`
+}
+
+// note summarizes the recommendation for the table, or returns "" when
+// read replica load is within target
+func (r *ReplicaOutput) note() string {
+	var note string
+	switch {
+	case r.Change > 0:
+		note = fmt.Sprintf("Read replicas %d→%d", r.CurrentCount, r.RecommendedCount)
+	case r.Change < 0:
+		note = fmt.Sprintf("Read replicas %d→%d (%s)", r.CurrentCount, r.RecommendedCount, r.RemoveReplica)
+	case r.ReasonCode() == cloudsql.ReasonReplicaAtMaxCount:
+		return "Read replicas at max count"
+	default:
+		return ""
+	}
+	switch {
+	case r.Applied:
+		note += " applied"
+	case r.Error != "":
+		note += " failed"
+	case r.DeferReason != "":
+		note += " deferred"
+	}
+	return note
+}
+
+// applyReplicaChanges applies the plan's read replica changes when replica
+// scaling is enabled, this is not a dry run and no blackout or freeze defers
+// them. It returns every read replica recommendation with its outcome, keyed
+// by primary, and reports whether applying any of them failed.
+func applyReplicaChanges(ctx context.Context, a *analyzer.ProjectAnalyzer, cfg *config.Config, results *analyzer.ProjectAnalysisResult, plan *analyzer.ScalingPlan) (map[string]*ReplicaOutput, bool) {
+	outputs := make(map[string]*ReplicaOutput, len(results.Replicas))
+	for _, decision := range results.Replicas {
+		outputs[decision.Primary] = &ReplicaOutput{ReplicaDecision: decision}
+	}
+	if dryRun || !a.ReplicaScalingEnabled() {
+		return outputs, false
+	}
+
+	var failed bool
+	for _, change := range plan.ReplicaChanges {
+		o := outputs[change.Decision.Primary]
+		if reason, deferred := a.ReplicaDeferral(change, cfg.Freezes, time.Now()); deferred {
+			o.DeferReason = reason
+			continue
+		}
+		if err := a.ApplyReplicaChange(ctx, change.Decision); err != nil {
+			o.Error = err.Error()
+			logf("  Failed: %v\n", err)
+			logHint(err)
+			failed = true
+			continue
+		}
+		o.Applied = true
+	}
+	return outputs, failed
+}
+
+// describeDeferral records why the plan deferred the result's operation and
+// when it becomes eligible
+func (o *OutputResult) describeDeferral(d analyzer.DeferredOperation, row *TableRow) {
+	o.DeferReason = d.DeferReason
+	o.DeferKind = string(d.DeferKind)
+	if d.DeferCode != "" {
+		o.ReasonCodes = append(o.ReasonCodes, d.DeferCode)
+	}
+	row.Status = "DEFERRED"
+	row.Warning = d.DeferReason
+	switch d.DeferKind {
+	case analyzer.DeferCooldown, analyzer.DeferInterval, analyzer.DeferBlackout, analyzer.DeferFreeze:
+		row.Status = "BLOCKED"
+	case analyzer.DeferInvalidTarget:
+		row.Status = "INVALID"
+	case analyzer.DeferRevertReview:
+		row.Status = "REVIEW"
+	}
+	if !d.NotBefore.IsZero() {
+		eligible := d.NotBefore
+		o.EligibleAt = &eligible
+		row.Warning += fmt.Sprintf(" (eligible %s)", config.FormatTime(eligible))
+	}
+}
+
+// describeDecision records the decision's reason and reason codes
+func (o *OutputResult) describeDecision(decision *cloudsql.ScalingDecision) {
+	o.Reason = decision.Reason
+	o.ReasonCode = string(decision.ReasonCode())
+	o.ReasonCodes = append([]cloudsql.ReasonCode(nil), decision.ReasonCodes...)
+}
+
+// describeStorage records the result's disk size recommendation, if the disk
+// is over the storage threshold, and applies it when storage scaling is
+// enabled, this is not a dry run and no blackout or freeze defers it. It
+// reports whether applying it failed.
+func (o *OutputResult) describeStorage(ctx context.Context, a *analyzer.ProjectAnalyzer, cfg *config.Config, result *analyzer.AnalysisResult, row *TableRow) bool {
+	storage := result.Storage
+	if storage == nil || storage.ReasonCode() != cloudsql.ReasonStorageHigh {
+		return false
+	}
+	o.Storage = storage
+
+	if !storage.ShouldResize {
+		row.addWarning("Disk at max size")
+		return false
+	}
+	note := fmt.Sprintf("Disk %d→%d GB", storage.CurrentSizeGB, storage.RecommendedSizeGB)
+	if dryRun || !a.StorageScalingEnabled() {
+		row.addWarning(note)
+		return false
+	}
+	if reason, deferred := a.StorageDeferral(result, cfg.Freezes, time.Now()); deferred {
+		o.StorageDeferReason = reason
+		row.addWarning(note + " deferred")
+		return false
+	}
+
+	logf("Growing disk of %s from %d GB to %d GB...\n", result.Instance.Name, storage.CurrentSizeGB, storage.RecommendedSizeGB)
+	if err := a.ApplyStorage(ctx, result.Instance.Name, storage); err != nil {
+		o.StorageError = err.Error()
+		row.addWarning(note + " failed")
+		logf("  Failed: %v\n", err)
+		logHint(err)
+		return true
+	}
+	o.StorageApplied = true
+	row.addWarning(note + " applied")
+	logf("  Success\n")
+	return false
+}
+
+// describeOwner records who owns the result's instance, if known
+func (o *OutputResult) describeOwner(owner config.Owner, row *TableRow) {
+	if owner.IsZero() {
+		return
+	}
+	o.Owner = &owner
+	row.Owner = owner.Team
+	if row.Owner == "" {
+		row.Owner = owner.String()
+	}
+}
+
+// describeInstance fills the result's current configuration from instance
+func (o *OutputResult) describeInstance(instance *config.InstanceInfo) {
+	o.InstanceID = instance.ID()
+	o.CurrentType = instance.MachineType
+	o.CurrentCPU = instance.CurrentCPU
+	o.CurrentMemoryGB = instance.CurrentMemoryGB
+	o.DiskSizeGB = instance.DiskSizeGB
+	o.DiskType = instance.DiskType
+	o.StorageAutoResize = instance.StorageAutoResize
+	o.StorageAutoResizeLimitGB = instance.StorageAutoResizeLimitGB
+	o.PricingPlan = instance.PricingPlan
+	o.DatabaseFlags = instance.DatabaseFlags
+}
+
+// outputSchemaVersion is the version of the JSON output schema in
+// output.schema.json. Bump the minor version when adding optional fields or
+// enum values and the major version for any removal, rename or type change.
+const outputSchemaVersion = "1.25"
+
+//go:embed output.schema.json
+var outputSchema []byte
+
+type OutputSummary struct {
+	SchemaVersion     string         `json:"schema_version"`
+	ProjectID         string         `json:"project_id"`
+	TotalInstances    int            `json:"total_instances"`
+	AnalyzedInstances int            `json:"analyzed_instances"`
+	ScalingResults    []OutputResult `json:"scaling_results"`
+	Profile           string         `json:"profile"`
+	DryRun            bool           `json:"dry_run"`
+	Timestamp         time.Time      `json:"timestamp"`
+	Redacted          []string       `json:"redacted,omitempty"` // What --redact masked
+}
+
+type TableRow struct {
+	Instance         string
+	Owner            string
+	CurrentType      string
+	CurrentResources string
+	Action           string
+	RecommendedType  string
+	Status           string
+	Warning          string
+	CPUTrend         string // Sparklines, shown with --sparklines
+	MemoryTrend      string
+}
+
+// tableHeaders returns the column headers of table output
+func tableHeaders() []string {
+	headers := []string{"Instance", "Owner", "Current Type", "Resources", "Action", "Recommended", "Status", "Warning"}
+	if sparklines {
+		headers = append(headers, "CPU", "Memory")
+	}
+	return headers
+}
+
+// cells returns the row's values in tableHeaders order
+func (r TableRow) cells() []string {
+	cells := []string{r.Instance, r.Owner, r.CurrentType, r.CurrentResources, r.Action, r.RecommendedType, r.Status, r.Warning}
+	if sparklines {
+		cells = append(cells, r.CPUTrend, r.MemoryTrend)
+	}
+	return cells
+}
+
+// addWarning appends warning to the row's warnings
+func (r *TableRow) addWarning(warning string) {
+	if r.Warning != "" {
+		warning = r.Warning + "; " + warning
+	}
+	r.Warning = warning
+}
+
+// describeTrends renders the CPU and memory utilization over the metrics
+// period as sparklines
+func (r *TableRow) describeTrends(metrics *config.MetricsData) {
+	if metrics == nil {
+		return
+	}
+	r.CPUTrend = report.Sparkline(metrics.CPUUtilization, report.DefaultSparklineWidth)
+	r.MemoryTrend = report.Sparkline(metrics.MemoryPercent, report.DefaultSparklineWidth)
+}
+
+func printTable(headers []string, rows []TableRow) {
+	if len(rows) == 0 {
+		return
+	}
+
+	widths := make([]int, len(headers))
+	for i, header := range headers {
+		widths[i] = len(header)
+	}
+
+	for _, row := range rows {
+		for i, cell := range row.cells() {
+			if n := utf8.RuneCountInString(cell); i < len(widths) && n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	printRow(headers, widths)
+	printSeparator(widths)
+	for _, row := range rows {
+		printRow(row.cells(), widths)
+	}
+}
+
+func printRow(data []string, widths []int) {
+	row := "| "
+	for i, cell := range data {
+		if i < len(widths) {
+			row += fmt.Sprintf("%-*s | ", widths[i], cell)
+		}
+	}
+	fmt.Println(row)
+}
+
+func printSeparator(widths []int) {
+	row := "|-"
+	for _, width := range widths {
+		row += strings.Repeat("-", width) + "-|-"
+	}
+	fmt.Println(row)
+}
+
+func logf(format string, args ...interface{}) {
+	if quiet {
+		return
+	}
+	fmt.Fprintf(os.Stderr, format, args...)
+}
+
+// logHint prints the remediation hint for a classified API error, if any
+func logHint(err error) {
+	if hint := cloudsql.Hint(err); hint != "" {
+		logf("  Hint: %s\n", hint)
+	}
+}
+
+// buildConfig resolves the project and builds the autoscaler config from the
+// flags shared by all commands
+func buildConfig(ctx context.Context) (*config.Config, error) {
+	var err error
+	if readOnly && !dryRun {
+		return nil, fmt.Errorf("--read-only cannot be combined with --dry-run=false")
+	}
+	if metricsSource != "" {
+		path, ok := cloudsql.MetricsFilePath(metricsSource)
+		if !ok {
+			return nil, fmt.Errorf("invalid --metrics-source %q (must be file://PATH)", metricsSource)
+		}
+		if !dryRun {
+			return nil, fmt.Errorf("--metrics-source is read only; it cannot be combined with --dry-run=false")
+		}
+		// A dump records the project it came from
+		if projectID == "" {
+			file, err := cloudsql.LoadMetricsFile(path)
+			if err != nil {
+				return nil, err
+			}
+			projectID = file.ProjectID()
+		}
+	}
+	if projectID == "" {
+		projectID, err = getDefaultProjectID(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("project not specified and could not determine default: %w", err)
+		}
+		logf("Using project: %s\n", projectID)
+	}
+
+	cfg := buildConfigFromProfile(profile)
+	if configFile != nil {
+		configFile.Thresholds.ApplyTo(cfg)
+		if cfg.ScaleDownThreshold >= cfg.ScaleUpThreshold {
+			return nil, fmt.Errorf("%s: scale-down threshold %.2f must be below the scale-up threshold %.2f",
+				configFile.Path, cfg.ScaleDownThreshold, cfg.ScaleUpThreshold)
+		}
+	}
+	if setThresholds != (config.Thresholds{}) {
+		setThresholds.ApplyTo(cfg)
+		if cfg.ScaleDownThreshold >= cfg.ScaleUpThreshold {
+			return nil, fmt.Errorf("invalid --set: scale-down threshold %.2f must be below the scale-up threshold %.2f",
+				cfg.ScaleDownThreshold, cfg.ScaleUpThreshold)
+		}
+	}
+	cfg.ProjectID = projectID
+	cfg.DryRun = dryRun
+	cfg.ReadOnly = readOnly
+	for _, r := range []struct {
+		flag string
+		rule *string
+		dst  *string
+	}{{"scale-up-rule", &scaleUpRule, &cfg.ScaleUpRule}, {"scale-down-rule", &scaleDownRule, &cfg.ScaleDownRule}} {
+		if *r.rule == "" {
+			continue
+		}
+		if _, err := rules.CompileRule(*r.rule); err != nil {
+			return nil, fmt.Errorf("invalid --%s: %w", r.flag, err)
+		}
+		*r.dst = *r.rule
+	}
+	if decisionHookTimeout <= 0 {
+		return nil, fmt.Errorf("invalid --decision-hook-timeout: must be positive")
+	}
+	cfg.DecisionHookURL = decisionHookURL
+	cfg.DecisionHookSecret = decisionHookSecret
+	cfg.DecisionHookTimeout = decisionHookTimeout
+	cfg.DecisionHookFailOpen = decisionHookFailOpen
+	if idempotencyWindow < 0 {
+		return nil, fmt.Errorf("invalid --idempotency-window: must not be negative")
+	}
+	cfg.IdempotencyWindow = idempotencyWindow
+	cfg.MetricsSource = metricsSource
+	cfg.MonitoringQuotaPerMinute = monitoringQuota
+	if concurrency < 1 {
+		return nil, fmt.Errorf("invalid --concurrency: must be at least 1")
+	}
+	cfg.AnalysisConcurrency = concurrency
+	cfg.AnalysisLatencyBudget = latencyBudget
+	if instanceTimeout < 0 {
+		return nil, fmt.Errorf("invalid --instance-timeout: must not be negative")
+	}
+	cfg.InstanceAnalysisTimeout = instanceTimeout
+	if hedgeAfter < 0 {
+		return nil, fmt.Errorf("invalid --hedge-after: must not be negative")
+	}
+	cfg.MetricsHedgeDelay = hedgeAfter
+	cfg.TrendWindow = trendWindow
+	cfg.CPUTrendThreshold = cpuTrendThreshold
+	cfg.MemoryTrendThreshold = memoryTrendThreshold
+	if forecastHorizon < time.Hour || forecastHorizon > 7*24*time.Hour {
+		return nil, fmt.Errorf("invalid --forecast-horizon: must be between 1h and 7d")
+	}
+	cfg.Predictive = predictive
+	cfg.ForecastHorizon = forecastHorizon
+	if coldStartWindow < time.Hour || coldStartWindow > cfg.MetricsPeriod {
+		return nil, fmt.Errorf("invalid --cold-start-window: must be between 1h and the metrics period (%v)", cfg.MetricsPeriod)
+	}
+	cfg.ColdStart = coldStart
+	cfg.ColdStartWindow = coldStartWindow
+	cfg.FleetSignals = fleetSignals
+	cfg.CatalogPricing = catalogPricing
+	for _, o := range owners {
+		instance, owner, err := config.ParseOwner(o)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --owner: %w", err)
+		}
+		if cfg.Owners == nil {
+			cfg.Owners = make(map[string]config.Owner)
+		}
+		cfg.Owners[instance] = owner
+	}
+	if businessHours != "" {
+		cfg.BusinessHours, err = config.ParseBusinessHours(businessHours)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --business-hours: %w", err)
+		}
+	}
+	for _, m := range memoryPressure {
+		engine, mode, err := config.ParseMemoryPressure(m)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --memory-pressure: %w", err)
+		}
+		if cfg.MemoryPressureModes == nil {
+			cfg.MemoryPressureModes = make(map[config.DatabaseEngine]config.MemoryPressureMode)
+		}
+		cfg.MemoryPressureModes[engine] = mode
+	}
+	if probeTimeout <= 0 {
+		return nil, fmt.Errorf("invalid --probe-timeout: must be positive")
+	}
+	cfg.ProbeEnabled = probeEnabled
+	cfg.ProbeTimeout = probeTimeout
+	cfg.ProbeIPType = probeIPType
+
+	if revertDeadline < 0 {
+		return nil, fmt.Errorf("invalid --revert-scale-ups: must not be negative")
+	}
+	if revertDeadline > 0 && (revertQuietPeriod <= 0 || revertQuietPeriod >= revertDeadline) {
+		return nil, fmt.Errorf("invalid --revert-quiet-period: must be above 0 and shorter than --revert-scale-ups")
+	}
+	cfg.RevertDeadline = revertDeadline
+	cfg.RevertQuietPeriod = revertQuietPeriod
+	cfg.RevertApply = revertApply
+
+	if rollbackWindow < 0 {
+		return nil, fmt.Errorf("invalid --rollback-window: must not be negative")
+	}
+	if rollbackThreshold <= 0 || rollbackThreshold > 1 {
+		return nil, fmt.Errorf("invalid --rollback-threshold: must be above 0 and at most 1")
+	}
+	cfg.RollbackWindow = rollbackWindow
+	cfg.RollbackThreshold = rollbackThreshold
+
+	if storageThreshold < 0 || storageThreshold > 1 {
+		return nil, fmt.Errorf("invalid --storage-threshold: must be between 0 and 1")
+	}
+	if storageThreshold > 0 && (storageTarget <= 0 || storageTarget >= storageThreshold) {
+		return nil, fmt.Errorf("invalid --storage-target: must be above 0 and below --storage-threshold")
+	}
+	if maxDiskSize < 0 {
+		return nil, fmt.Errorf("invalid --max-disk-size: must not be negative")
+	}
+	cfg.StorageScaling = storageScaling
+	cfg.StorageScaleUpThreshold = storageThreshold
+	cfg.StorageTargetUtilization = storageTarget
+	cfg.MaxDiskSizeGB = maxDiskSize
+	if shrinkThreshold < 0 || shrinkThreshold >= 1 {
+		return nil, fmt.Errorf("invalid --disk-shrink-threshold: must be at least 0 and below 1")
+	}
+	if shrinkHorizon < 0 {
+		return nil, fmt.Errorf("invalid --disk-shrink-horizon: must not be negative")
+	}
+	cfg.DiskShrinkThreshold = shrinkThreshold
+	cfg.DiskShrinkHorizon = shrinkHorizon
+	if ioBoundThreshold < 0 || ioBoundThreshold > 1 {
+		return nil, fmt.Errorf("invalid --io-bound-threshold: must be between 0 and 1")
+	}
+	cfg.IOBoundThreshold = ioBoundThreshold
+	if stoppedReview < 0 {
+		return nil, fmt.Errorf("invalid --stopped-review-age: must not be negative")
+	}
+	cfg.StoppedReviewAge = stoppedReview
+
+	if replicaThreshold < 0 || replicaThreshold > 1 {
+		return nil, fmt.Errorf("invalid --replica-threshold: must be between 0 and 1")
+	}
+	if replicaThreshold > 0 && (replicaScaleDown <= 0 || replicaScaleDown >= replicaThreshold) {
+		return nil, fmt.Errorf("invalid --replica-scale-down-threshold: must be above 0 and below --replica-threshold")
+	}
+	if maxReplicaLag < 0 {
+		return nil, fmt.Errorf("invalid --max-replica-lag: must not be negative")
+	}
+	if minReadReplicas < 1 {
+		return nil, fmt.Errorf("invalid --min-read-replicas: must be at least 1")
+	}
+	if maxReadReplicas < 0 || (maxReadReplicas > 0 && maxReadReplicas < minReadReplicas) {
+		return nil, fmt.Errorf("invalid --max-read-replicas: must be 0 or at least --min-read-replicas")
+	}
+	cfg.ReplicaScaling = replicaScaling
+	cfg.ReplicaScaleUpThreshold = replicaThreshold
+	cfg.ReplicaScaleDownThreshold = replicaScaleDown
+	cfg.MaxReplicaLag = maxReplicaLag
+	cfg.MinReadReplicas = minReadReplicas
+	cfg.MaxReadReplicas = maxReadReplicas
+
+	cfg.CycleCostIncreaseCap = costIncreaseCap
+	cfg.MaxOperationsPerCycle = maxOperations
+	cfg.BundleDowntimeOperations = bundleDowntime
+	if maintenance != "" {
+		cfg.MaintenanceNight, err = config.ParseMaintenanceNight(maintenance)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --maintenance-night: %w", err)
+		}
+	}
+	cfg.MaintenanceWindows = instanceWindows
+	if downtimeBudget < 0 {
+		return nil, fmt.Errorf("invalid --downtime-budget: must not be negative")
+	}
+	cfg.DowntimeBudget = downtimeBudget
+
+	cfg.SampleFraction, err = config.ParseSampleFraction(sampleSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --sample: %w", err)
+	}
+	switch strategy := config.SampleStrategy(sampleStrategy); strategy {
+	case config.SampleRotate, config.SamplePriority:
+		cfg.SampleStrategy = strategy
+	case "":
+	default:
+		return nil, fmt.Errorf("invalid sample strategy: %s (must be 'rotate' or 'priority')", sampleStrategy)
+	}
+	if sampleMaxAge > 0 {
+		cfg.SampleMaxAge = sampleMaxAge
+	}
+
+	switch policy := config.ReplicaPolicy(replicaPolicy); policy {
+	case config.ReplicaPolicyParity, config.ReplicaPolicyExclude:
+		cfg.ReplicaPolicy = policy
+	default:
+		return nil, fmt.Errorf("invalid replica policy: %s (must be 'parity' or 'exclude')", replicaPolicy)
+	}
+
+	switch policy := config.ConnectionCapacityPolicy(connectionCapacity); policy {
+	case config.ConnectionCapacityBlock, config.ConnectionCapacityWarn:
+		cfg.ConnectionCapacityPolicy = policy
+	default:
+		return nil, fmt.Errorf("invalid connection capacity policy: %s (must be 'block' or 'warn')", connectionCapacity)
+	}
+	if connectionMemory < 0 {
+		return nil, fmt.Errorf("invalid --connection-memory-mb: %v (must not be negative)", connectionMemory)
+	}
+	cfg.PerConnectionMemoryMB = connectionMemory
+
+	cfg.DeniedMachineTypes, err = config.ParseMachineTypeDenylist(deniedMachineTypes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --deny-machine-type: %w", err)
+	}
+	cfg.AllowedMachineTypes, err = config.ParseMachineTypeAllowlist(allowedMachineTypes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --allow-machine-type: %w", err)
+	}
+
+	cfg.Currency, err = config.ParseCurrency(currencyCode, currencyRate, locale)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --currency: %w", err)
+	}
+	cfg.Commitment, err = config.ParseCommitment(commitment)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --cud: %w", err)
+	}
+
+	cfg.Redaction, err = config.ParseRedaction(redactFields, redactMode, redactSalt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --redact: %w", err)
+	}
+
+	cfg.Shadow, err = buildShadowConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range blackouts {
+		window, err := config.ParseTimeWindow(b)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --blackout: %w", err)
+		}
+		cfg.BlackoutWindows = append(cfg.BlackoutWindows, window)
+	}
+	for _, f := range freezes {
+		freeze, err := config.ParseFreeze(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --freeze: %w", err)
+		}
+		cfg.Freezes = append(cfg.Freezes, freeze)
+	}
+	cfg.FreezeEmergencyThreshold = freezeEmergency
+
+	if scheduleLead < 0 {
+		return nil, fmt.Errorf("invalid --schedule-lead: must not be negative")
+	}
+	cfg.ScheduleLead = scheduleLead
+	for _, s := range schedules {
+		schedule, err := config.ParseSchedule(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --schedule: %w", err)
+		}
+		if why, restricted := cfg.RestrictsTarget(schedule.MachineType); restricted && schedule.MachineType != "" {
+			return nil, fmt.Errorf("invalid --schedule: target %s", why)
+		}
+		if schedule.Profile != "" {
+			if schedule.ProfileConfig, err = buildProfileConfig(cfg, schedule.Profile); err != nil {
+				return nil, fmt.Errorf("invalid --schedule: %w", err)
+			}
+		}
+		cfg.Schedules = append(cfg.Schedules, schedule)
+	}
+
+	// Instances may name a profile in their labels
+	cfg.ProfileConfigs = make(map[string]*config.Config)
+	for _, p := range []string{"default", "conservative", "aggressive"} {
+		if cfg.ProfileConfigs[p], err = buildProfileConfig(cfg, p); err != nil {
+			return nil, err
+		}
+	}
+
+	if configFile != nil {
+		for _, o := range configFile.Instances {
+			if o.Config, err = buildInstanceOverride(cfg, o); err != nil {
+				what := "instance " + o.Instance
+				if o.Instance == "" {
+					what = "instances matching " + o.Match
+				}
+				return nil, fmt.Errorf("%s:%d: %s: %w", configFile.Path, o.Line, what, err)
+			}
+			cfg.InstanceOverrides = append(cfg.InstanceOverrides, o)
+		}
+	}
+
+	return cfg, nil
+}
+
+// buildProfileConfig derives the config that judges instances with another
+// profile, such as a profile schedule's: the active config with the
+// profile's thresholds
+func buildProfileConfig(active *config.Config, profile string) (*config.Config, error) {
+	switch profile {
+	case "default", "conservative", "aggressive":
+	default:
+		return nil, fmt.Errorf("invalid profile: %s (must be default, conservative or aggressive)", profile)
+	}
+	derived := *active
+	derived.Shadow, derived.Schedules, derived.InstanceOverrides, derived.ProfileConfigs = nil, nil, nil, nil
+	p := buildConfigFromProfile(profile)
+	derived.ScaleUpThreshold = p.ScaleUpThreshold
+	derived.ScaleDownThreshold = p.ScaleDownThreshold
+	derived.MinStableDuration = p.MinStableDuration
+	return &derived, nil
+}
+
+// buildInstanceOverride derives the config an instance override judges its
+// instance with: the active config, or its profile's if it names one, with
+// the thresholds it sets
+func buildInstanceOverride(active *config.Config, o config.InstanceOverride) (*config.Config, error) {
+	derived := *active
+	derived.Shadow, derived.Schedules, derived.InstanceOverrides, derived.ProfileConfigs = nil, nil, nil, nil
+	if o.Profile != "" {
+		p, err := buildProfileConfig(active, o.Profile)
+		if err != nil {
+			return nil, err
+		}
+		derived = *p
+	}
+	o.Thresholds.ApplyTo(&derived)
+	if derived.ScaleDownThreshold >= derived.ScaleUpThreshold {
+		return nil, fmt.Errorf("scale-down threshold %.2f must be below the scale-up threshold %.2f",
+			derived.ScaleDownThreshold, derived.ScaleUpThreshold)
+	}
+	return &derived, nil
+}
+
+// daemonSettings maps the settings of a config file's daemon section to the
+// flags they set
+var daemonSettings = map[string]string{
+	"enabled":                     "daemon",
+	"interval":                    "interval",
+	"http-port":                   "http-port",
+	"metrics":                     "metrics",
+	"api-token":                   "api-token",
+	"slack-webhook":               "slack-webhook",
+	"slack-bot-token":             "slack-bot-token",
+	"datadog-api-key":             "datadog-api-key",
+	"datadog-site":                "datadog-site",
+	"datadog-tags":                "datadog-tag",
+	"pagerduty-routing-key":       "pagerduty-routing-key",
+	"overload-cycles":             "overload-cycles",
+	"stable-cycles":               "stable-cycles",
+	"pubsub-topic":                "pubsub-topic",
+	"issue-tracker":               "issue-tracker",
+	"issue-tracker-token":         "issue-tracker-token",
+	"issue-after-days":            "issue-after-days",
+	"webhook-url":                 "webhook-url",
+	"webhook-headers":             "webhook-header",
+	"webhook-template":            "webhook-template",
+	"event-format":                "event-format",
+	"secret-refresh":              "secret-refresh",
+	"prescale-max-duration":       "prescale-max-duration",
+	"operation-journal":           "operation-journal",
+	"state-store":                 "state-store",
+	"require-approval":            "require-approval",
+	"approval-store":              "approval-store",
+	"cycle-deadline":              "cycle-deadline",
+	"sample":                      "sample",
+	"sample-strategy":             "sample-strategy",
+	"sample-max-age":              "sample-max-age",
+	"shadow-profile":              "shadow-profile",
+	"shadow-scale-up-threshold":   "shadow-scale-up-threshold",
+	"shadow-scale-down-threshold": "shadow-scale-down-threshold",
+}
+
+// applySetOverrides applies --set. Keys are named as in a config file, with
+// underscores allowed for hyphens: a scaling threshold, a flag, or a daemon
+// setting as daemon.KEY. Flags set this way count as given on the command
+// line, so the config file does not override them; thresholds are applied
+// over the file's by buildConfig.
+func applySetOverrides(cmd *cobra.Command) error {
+	set := make(map[string]bool)
+	for _, override := range setOverrides {
+		key, value, ok := strings.Cut(override, "=")
+		key = strings.ReplaceAll(strings.TrimSpace(key), "_", "-")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid --set %q (must be KEY=VALUE)", override)
+		}
+
+		known, err := setThresholds.Set(key, value)
+		if err != nil {
+			return fmt.Errorf("invalid --set %s: %w", key, err)
+		}
+		if known {
+			continue
+		}
+
+		name := key
+		if section, setting, ok := strings.Cut(key, "."); ok {
+			if name, ok = daemonSettings[setting]; !ok || section != "daemon" {
+				return fmt.Errorf("invalid --set %s: unknown setting", key)
+			}
+		}
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil || name == "help" || name == "set" {
+			return fmt.Errorf("invalid --set %s: unknown setting for %s", key, cmd.CommandPath())
+		}
+		if flag.Changed && !set[name] {
+			return fmt.Errorf("invalid --set %s: --%s is also given", key, name)
+		}
+		if err := cmd.Flags().Set(name, value); err != nil {
+			return fmt.Errorf("invalid --set %s: %w", key, err)
+		}
+		set[name] = true
+	}
+	return nil
+}
+
+// loadConfigFile loads --config and sets the flags not given on the command
+// line from its settings. Flags only a subcommand defines, such as sandbox's
+// --interval, are left to the command line.
+func loadConfigFile(cmd *cobra.Command, args []string) error {
+	if configPath == "" {
+		return nil
+	}
+	file, err := config.LoadFile(configPath)
+	if err != nil {
+		return err
+	}
+
+	daemonFlags := make(map[string]bool, len(daemonSettings))
+	for _, name := range daemonSettings {
+		daemonFlags[name] = true
+	}
+	for _, s := range file.Settings {
+		name := s.Key
+		if s.Section == "daemon" {
+			var ok bool
+			if name, ok = daemonSettings[s.Key]; !ok {
+				return file.Errorf(s, "unknown daemon setting")
+			}
+		} else if daemonFlags[name] {
+			return file.Errorf(s, "daemon setting; set it in the daemon section")
+		}
+		flag := rootCmd.PersistentFlags().Lookup(name)
+		if flag == nil {
+			flag = rootCmd.Flags().Lookup(name)
+		}
+		if flag == nil || name == "help" {
+			return file.Errorf(s, "unknown setting")
+		}
+		if flag.Changed || cmd.Flags().Lookup(name) != flag {
+			continue
+		}
+		if list := flag.Value.Type() == "stringSlice" || flag.Value.Type() == "stringArray"; !list && len(s.Values) != 1 {
+			return file.Errorf(s, "takes a single value, not a list")
+		}
+		for _, v := range s.Values {
+			if err := flag.Value.Set(v); err != nil {
+				return file.Errorf(s, "%v", err)
+			}
+		}
+	}
+	configFile = file
+	return nil
+}
+
+// setupTimestamps makes every timestamp the process emits RFC3339: UTC in
+// JSON, and in --timezone in table output, logs, reasons and notifications.
+// It runs before any command, so nothing has formatted a timestamp yet.
+func setupTimestamps() error {
+	loc, err := config.ParseTimezone(timezone)
+	if err != nil {
+		return fmt.Errorf("invalid --timezone: %w", err)
+	}
+
+	// Times marshal in their own location; making local time UTC keeps the
+	// host's zone out of JSON
+	time.Local = time.UTC
+	config.SetDisplayLocation(loc)
+
+	log.SetFlags(0)
+	log.SetOutput(timestampWriter{os.Stderr})
+	return nil
+}
+
+// timestampWriter prefixes each log entry with the time it was written, as
+// RFC3339 in the display time zone. The log package writes each entry in a
+// single call.
+type timestampWriter struct {
+	w io.Writer
+}
+
+func (t timestampWriter) Write(p []byte) (int, error) {
+	line := append([]byte(config.FormatTime(time.Now())+" "), p...)
+	if _, err := t.w.Write(line); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func runAutoscaler(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	cfg, err := buildConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	sortKey, err = analyzer.ParseSortKey(sortBy)
+	if err != nil {
+		return err
+	}
+	if groupBy != "" {
+		groupKey, err = analyzer.ParseGroupBy(groupBy)
+		if err != nil {
+			return fmt.Errorf("invalid --group-by: %w", err)
+		}
+		summaryOnly = true
+	}
+
+	// Handle daemon mode; a preflight-only run checks the daemon's setup
+	if daemonMode || preflightOnly {
+		if instancesFile != "" || len(args) > 0 {
+			return fmt.Errorf("--instances-file and - select instances for one-shot analysis and cannot be combined with --daemon")
+		}
+		return runDaemon(ctx, cfg)
+	}
+
+	targets, err := resolveInstances(args)
+	if err != nil {
+		return err
+	}
+
+	// Handle one-shot mode
+	projectAnalyzer, err := analyzer.NewProjectAnalyzer(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create analyzer: %w", err)
+	}
+	defer projectAnalyzer.Close()
+	if quiet {
+		projectAnalyzer.SetProgressOutput(io.Discard)
+	}
+	if _, offline := cloudsql.MetricsFilePath(cfg.MetricsSource); !cfg.DryRun && !cfg.ReadOnly && !offline {
+		projectAnalyzer.SetActor(resolveActor(ctx, audit.ModeCLI))
+	}
+
+	if output != "table" && output != "json" && output != "html" {
+		return fmt.Errorf("invalid output format: %s (must be 'table', 'json' or 'html')", output)
+	}
+	if output == "html" && (len(targets) > 0 || summaryOnly) {
+		return fmt.Errorf("--output html reports on every instance of the project and cannot be combined with --summary, --group-by, --instance or an instance list")
+	}
+
+	if len(targets) > 0 {
+		if summaryOnly {
+			return fmt.Errorf("--summary and --group-by report on the whole project and cannot be combined with --instance or an instance list")
+		}
+		return analyzeSpecificInstances(ctx, projectAnalyzer, targets, cfg)
+	}
+	return analyzeAllInstances(ctx, projectAnalyzer, cfg)
+}
+
+// resolveInstances combines --instance with the instances read from
+// --instances-file or, when args is "-", stdin. Duplicates are dropped and
+// the order given is kept.
+func resolveInstances(args []string) ([]string, error) {
+	targets := append([]string{}, instances...)
+
+	source := instancesFile
+	if len(args) == 1 {
+		if source != "" && source != "-" {
+			return nil, fmt.Errorf("cannot read instances from both --instances-file and stdin")
+		}
+		source = "-"
+	}
+	if source != "" {
+		var r io.Reader = os.Stdin
+		if source != "-" {
+			f, err := os.Open(source)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read --instances-file: %w", err)
+			}
+			defer f.Close()
+			r = f
+		}
+		listed, err := readInstanceList(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(listed) == 0 {
+			return nil, fmt.Errorf("no instances listed in %s", describeSource(source))
+		}
+		targets = append(targets, listed...)
+	}
+
+	seen := make(map[string]bool, len(targets))
+	unique := targets[:0]
+	for _, name := range targets {
+		if !seen[name] {
+			seen[name] = true
+			unique = append(unique, name)
+		}
+	}
+	return unique, nil
+}
+
+// describeSource names where an instance list was read from
+func describeSource(source string) string {
+	if source == "-" {
+		return "stdin"
+	}
+	return source
+}
+
+// readInstanceList reads instance names one per line, ignoring blank lines
+// and # comments, or as a JSON array of names or of objects with a name or
+// instance field, such as gcloud sql instances list --format=json
+func readInstanceList(r io.Reader) ([]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read instance list: %w", err)
+	}
+
+	text := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(text, "[") {
+		var names []string
+		for _, line := range strings.Split(text, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			names = append(names, line)
+		}
+		return names, nil
+	}
+
+	var entries []json.RawMessage
+	if err := json.Unmarshal([]byte(text), &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse instance list: %w", err)
+	}
+	names := make([]string, 0, len(entries))
+	for i, entry := range entries {
+		var name string
+		if err := json.Unmarshal(entry, &name); err != nil {
+			var obj struct {
+				Name     string `json:"name"`
+				Instance string `json:"instance"`
+			}
+			if err := json.Unmarshal(entry, &obj); err != nil {
+				return nil, fmt.Errorf("instance list entry %d must be a name or an object with a name", i)
+			}
+			name = obj.Name
+			if name == "" {
+				name = obj.Instance
+			}
+		}
+		if name == "" {
+			return nil, fmt.Errorf("instance list entry %d has no name", i)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func runCalendar(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	cfg, err := buildConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if calendarDays <= 0 {
+		return fmt.Errorf("--days must be positive")
+	}
+	if output != "table" && output != "json" {
+		return fmt.Errorf("invalid output format: %s (must be 'table' or 'json')", output)
+	}
+
+	projectAnalyzer, err := analyzer.NewProjectAnalyzer(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create analyzer: %w", err)
+	}
+	defer projectAnalyzer.Close()
+	if quiet {
+		projectAnalyzer.SetProgressOutput(io.Discard)
+	}
+
+	results, err := projectAnalyzer.AnalyzeAllInstances(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to analyze instances: %w", err)
+	}
+
+	now := projectAnalyzer.Now()
+	plan := projectAnalyzer.PlanScaling(results)
+	entries := analyzer.BuildCalendar(results, plan, cfg, now, time.Duration(calendarDays)*24*time.Hour)
+
+	if output == "json" {
+		jsonOutput, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON output: %w", err)
+		}
+		fmt.Println(string(jsonOutput))
+		return nil
+	}
+	analyzer.PrintCalendar(os.Stdout, entries)
+	return nil
+}
+
+func runExportRecommender(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	cfg, err := buildConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	projectAnalyzer, err := analyzer.NewProjectAnalyzer(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create analyzer: %w", err)
+	}
+	defer projectAnalyzer.Close()
+	// Keep stdout for the export itself
+	if quiet {
+		projectAnalyzer.SetProgressOutput(io.Discard)
+	} else {
+		projectAnalyzer.SetProgressOutput(os.Stderr)
+	}
+
+	results, err := projectAnalyzer.AnalyzeAllInstances(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to analyze instances: %w", err)
+	}
+
+	export := analyzer.ExportRecommender(results, projectAnalyzer.Now())
+	jsonOutput, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal export: %w", err)
+	}
+
+	if exportFile == "" {
+		fmt.Println(string(jsonOutput))
+		return nil
+	}
+	if err := os.WriteFile(exportFile, append(jsonOutput, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	logf("Wrote %d recommendation(s) to %s\n", len(export.Recommendations), exportFile)
+	return nil
+}
+
+func runExportMetrics(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	cfg, err := buildConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	projectAnalyzer, err := analyzer.NewProjectAnalyzer(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create analyzer: %w", err)
+	}
+	defer projectAnalyzer.Close()
+	// Keep stdout for the export itself
+	if quiet {
+		projectAnalyzer.SetProgressOutput(io.Discard)
+	} else {
+		projectAnalyzer.SetProgressOutput(os.Stderr)
+	}
+
+	dump, err := projectAnalyzer.ExportMetrics(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to export metrics: %w", err)
+	}
+
+	jsonOutput, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal export: %w", err)
+	}
+
+	if exportFile == "" {
+		fmt.Println(string(jsonOutput))
+		return nil
+	}
+	if err := os.WriteFile(exportFile, append(jsonOutput, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	logf("Wrote metrics for %d instance(s) to %s\n", len(dump.Instances), exportFile)
+	return nil
+}
+
+func runSnapshot(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	cfg, err := buildConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	projectAnalyzer, err := analyzer.NewProjectAnalyzer(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create analyzer: %w", err)
+	}
+	defer projectAnalyzer.Close()
+	// Keep stdout for the snapshot itself
+	if quiet {
+		projectAnalyzer.SetProgressOutput(io.Discard)
+	} else {
+		projectAnalyzer.SetProgressOutput(os.Stderr)
+	}
+
+	results, err := projectAnalyzer.AnalyzeAllInstances(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to analyze instances: %w", err)
+	}
+
+	snapshot := projectAnalyzer.Snapshot(results, projectAnalyzer.Now())
+	jsonOutput, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+
+	if exportFile == "" {
+		fmt.Println(string(jsonOutput))
+		return nil
+	}
+	if err := os.WriteFile(exportFile, append(jsonOutput, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	logf("Wrote a snapshot of %d instance(s) to %s\n", len(snapshot.Instances), exportFile)
+	return nil
+}
+
+func runSnapshotDiff(cmd *cobra.Command, args []string) error {
+	if output != "table" && output != "json" {
+		return fmt.Errorf("invalid output format: %s (must be 'table' or 'json')", output)
+	}
+	before, err := analyzer.LoadSnapshot(args[0])
+	if err != nil {
+		return err
+	}
+	after, err := analyzer.LoadSnapshot(args[1])
+	if err != nil {
+		return err
+	}
+	if before.ProjectID != after.ProjectID {
+		logf("Warning: comparing snapshots of different projects, %s and %s\n", before.ProjectID, after.ProjectID)
+	}
+
+	diff := analyzer.DiffSnapshots(before, after)
+	if output == "json" {
+		jsonOutput, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON output: %w", err)
+		}
+		fmt.Println(string(jsonOutput))
+		return nil
+	}
+	diff.Print(os.Stdout)
+	return nil
+}
+
+func runDaemon(ctx context.Context, cfg *config.Config) error {
+	// Initialize metrics if enabled; Datadog is sent the same metrics
+	if datadogAPIKey != "" {
+		for _, tag := range datadogTags {
+			if err := datadog.ValidateTag(tag); err != nil {
+				return fmt.Errorf("invalid --datadog-tag: %w", err)
+			}
+		}
+	}
+	if enableMetrics || datadogAPIKey != "" {
+		daemon.InitMetrics()
+	}
+	if overloadCycles < 0 {
+		return fmt.Errorf("invalid --overload-cycles: must not be negative")
+	}
+	if stableCycles < 1 {
+		return fmt.Errorf("invalid --stable-cycles: must be at least 1")
+	}
+	if issueAfterDays < 0 {
+		return fmt.Errorf("invalid --issue-after-days: must not be negative")
+	}
 
-	// Daemon mode flags
-	rootCmd.Flags().BoolVar(&daemonMode, "daemon", false, "Run in continuous daemon mode")
-	rootCmd.Flags().DurationVar(&daemonInterval, "interval", 30*time.Minute, "Interval between autoscaling checks in daemon mode")
-	rootCmd.Flags().IntVar(&httpPort, "http-port", 8080, "HTTP port for health checks and metrics")
-	rootCmd.Flags().BoolVar(&enableMetrics, "metrics", true, "Enable Prometheus metrics endpoint")
-}
+	// Spread analysis over half the interval when the fleet exceeds the quota
+	cfg.AnalysisSpreadWindow = daemonInterval / 2
 
-func main() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+	// A cycle must run within the lead for schedule windows to be scaled up in time
+	cfg.ScheduleLead = max(cfg.ScheduleLead, daemonInterval)
+
+	// and at least one during the maintenance night for held operations to run
+	if cfg.MaintenanceNight != nil && cfg.MaintenanceNight.Length < daemonInterval {
+		return fmt.Errorf("invalid --maintenance-night: %s is shorter than --interval %s", cfg.MaintenanceNight, daemonInterval)
+	}
+	if cfg.MaintenanceWindows && daemonInterval > time.Hour {
+		return fmt.Errorf("invalid --maintenance-windows: Cloud SQL maintenance windows are an hour long, shorter than --interval %s", daemonInterval)
 	}
-}
 
-type OutputResult struct {
-	Instance        string    `json:"instance"`
-	CurrentType     string    `json:"current_type"`
-	CurrentCPU      int       `json:"current_cpu"`
-	CurrentMemoryGB float64   `json:"current_memory_gb"`
-	RecommendedType string    `json:"recommended_type,omitempty"`
-	Action          string    `json:"action"`
-	Reason          string    `json:"reason"`
-	DowntimeWarning string    `json:"downtime_warning,omitempty"`
-	Applied         bool      `json:"applied"`
-	Error           string    `json:"error,omitempty"`
-	Timestamp       time.Time `json:"timestamp"`
-}
+	// Create daemon configuration
+	daemonCfg := &daemon.DaemonConfig{
+		Interval:      daemonInterval,
+		HTTPPort:      httpPort,
+		EnableMetrics: enableMetrics,
 
-type OutputSummary struct {
-	ProjectID         string         `json:"project_id"`
-	TotalInstances    int            `json:"total_instances"`
-	AnalyzedInstances int            `json:"analyzed_instances"`
-	ScalingResults    []OutputResult `json:"scaling_results"`
-	Profile           string         `json:"profile"`
-	DryRun            bool           `json:"dry_run"`
-	Timestamp         time.Time      `json:"timestamp"`
-}
+		APIToken:            apiToken,
+		MaxPreScaleDuration: preScaleMax,
+		SecretRefresh:       secretRefresh,
+		SlackWebhook:        slackWebhook,
+		SlackBotToken:       slackBotToken,
+		DatadogAPIKey:       datadogAPIKey,
+		DatadogSite:         datadogSite,
+		DatadogTags:         datadogTags,
+		PagerDutyRoutingKey: pagerDutyKey,
+		OverloadCycles:      overloadCycles,
+		StableCycles:        stableCycles,
+		PubSubTopic:         pubsubTopic,
+		IssueTracker:        issueTracker,
+		IssueTrackerToken:   issueToken,
+		IssueAfter:          time.Duration(issueAfterDays) * 24 * time.Hour,
+		WebhookURL:          webhookURL,
+		WebhookHeaders:      webhookHeaders,
+		WebhookTemplate:     webhookTmpl,
+		EventFormat:         eventFormat,
 
-type TableRow struct {
-	Instance         string
-	CurrentType      string
-	CurrentResources string
-	Action           string
-	RecommendedType  string
-	Status           string
-	Warning          string
-}
+		OperationJournal: opJournal,
+		StateStore:       stateStore,
+		RequireApproval:  requireApprove,
+		ApprovalStore:    approvalStore,
 
-func printTable(headers []string, rows []TableRow) {
-	if len(rows) == 0 {
-		return
+		CycleDeadline: cycleDeadline,
+
+		Profile:    profile,
+		ConfigFile: configPath,
+		Build:      buildInfo(),
+	}
+	if _, offline := cloudsql.MetricsFilePath(cfg.MetricsSource); !cfg.DryRun && !cfg.ReadOnly && !offline {
+		daemonCfg.Actor = resolveActor(ctx, audit.ModeDaemon)
 	}
 
-	widths := make([]int, len(headers))
-	for i, header := range headers {
-		widths[i] = len(header)
+	// Create and start daemon
+	d, err := daemon.NewDaemon(cfg, daemonCfg)
+	if err != nil {
+		return fmt.Errorf("failed to create daemon: %w", err)
+	}
+	if preflightOnly {
+		return runPreflight(ctx, cfg, d)
 	}
 
-	for _, row := range rows {
-		data := []string{row.Instance, row.CurrentType, row.CurrentResources, row.Action, row.RecommendedType, row.Status, row.Warning}
-		for i, cell := range data {
-			if i < len(widths) && len(cell) > widths[i] {
-				widths[i] = len(cell)
-			}
+	return d.Start()
+}
+
+// runPreflight prints the daemon's preflight report, failing if any check failed
+func runPreflight(ctx context.Context, cfg *config.Config, d *daemon.Daemon) error {
+	report, err := d.Preflight(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to run preflight: %w", err)
+	}
+
+	if output == "json" {
+		names := make([]string, 0, len(report.Instances)+len(report.Skipped))
+		for _, p := range report.Instances {
+			names = append(names, p.Instance)
+		}
+		for _, s := range report.Skipped {
+			names = append(names, s.Name)
+		}
+		jsonOutput, err := redact.New(cfg.Redaction, cfg.Currency).JSON(report, cfg.ProjectID, names)
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON output: %w", err)
 		}
+		fmt.Println(string(jsonOutput))
+	} else {
+		report.Print(os.Stdout)
 	}
 
-	printRow(headers, widths)
-	printSeparator(widths)
-	for _, row := range rows {
-		data := []string{row.Instance, row.CurrentType, row.CurrentResources, row.Action, row.RecommendedType, row.Status, row.Warning}
-		printRow(data, widths)
+	if failed := report.Failed(); len(failed) > 0 {
+		return fmt.Errorf("preflight failed: %d check(s) failed", len(failed))
 	}
+	return nil
 }
 
-func printRow(data []string, widths []int) {
-	row := "| "
-	for i, cell := range data {
-		if i < len(widths) {
-			row += fmt.Sprintf("%-*s | ", widths[i], cell)
-		}
-	}
-	fmt.Println(row)
+// buildInfo returns this binary's build metadata, including the output schema
+// version
+func buildInfo() version.Info {
+	info := version.Get()
+	info.APIs["output_schema"] = outputSchemaVersion
+	return info
 }
 
-func printSeparator(widths []int) {
-	row := "|-"
-	for _, width := range widths {
-		row += strings.Repeat("-", width) + "-|-"
+func runVersion(cmd *cobra.Command, args []string) error {
+	info := buildInfo()
+	if output == "json" {
+		jsonOutput, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal version: %w", err)
+		}
+		fmt.Println(string(jsonOutput))
+		return nil
 	}
-	fmt.Println(row)
-}
 
-func logf(format string, args ...interface{}) {
-	fmt.Fprintf(os.Stderr, format, args...)
+	commit, built := info.Commit, info.BuildDate
+	if commit == "" {
+		commit = "unknown"
+	} else if info.Dirty {
+		commit += " (modified)"
+	}
+	if built == "" {
+		built = "unknown"
+	}
+	fmt.Printf("cloudsql-autoscaler %s\n", info.Version)
+	fmt.Printf("  Commit:        %s\n", commit)
+	fmt.Printf("  Built:         %s\n", built)
+	fmt.Printf("  Go:            %s %s\n", info.GoVersion, info.Platform)
+	fmt.Printf("  HTTP API:      %s\n", info.APIs["http"])
+	fmt.Printf("  Output schema: %s\n", info.APIs["output_schema"])
+	return nil
 }
 
-func runAutoscaler(cmd *cobra.Command, args []string) error {
-	ctx := context.Background()
+// approvalsRequest calls the daemon's approvals API at path, sending body as
+// JSON if it is not nil, and decodes the response into out
+func approvalsRequest(ctx context.Context, method, path string, body, out interface{}) error {
+	token, err := secrets.Resolve(ctx, approvalsToken)
+	if err != nil {
+		return err
+	}
+	if token.Value() == "" {
+		return fmt.Errorf("an API token is required (--api-token or $CLOUDSQL_AUTOSCALER_API_TOKEN)")
+	}
 
-	if projectID == "" {
-		var err error
-		projectID, err = getDefaultProjectID(ctx)
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
 		if err != nil {
-			return fmt.Errorf("project not specified and could not determine default: %w", err)
+			return fmt.Errorf("failed to encode request: %w", err)
 		}
-		logf("Using project: %s\n", projectID)
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(approvalsURL, "/")+"/api/v1/approvals"+path, reader)
+	if err != nil {
+		return fmt.Errorf("invalid --daemon-url: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token.Value())
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
 
-	cfg := buildConfigFromProfile(profile)
-	cfg.ProjectID = projectID
-	cfg.DryRun = dryRun
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call daemon: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("daemon returned %s: %s", resp.Status, apiErr.Error)
+		}
+		return fmt.Errorf("daemon returned %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to parse daemon response: %w", err)
+	}
+	return nil
+}
 
-	// Handle daemon mode
-	if daemonMode {
-		return runDaemon(ctx, cfg)
+func runApprovalsList(cmd *cobra.Command, args []string) error {
+	path := ""
+	if approvalsStatus != "" {
+		path = "?status=" + url.QueryEscape(approvalsStatus)
+	}
+	var list struct {
+		Approvals []approval.Request `json:"approvals"`
+	}
+	if err := approvalsRequest(cmd.Context(), http.MethodGet, path, nil, &list); err != nil {
+		return err
+	}
+	if output == "json" {
+		jsonOutput, err := json.MarshalIndent(list.Approvals, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal approvals: %w", err)
+		}
+		fmt.Println(string(jsonOutput))
+		return nil
 	}
 
-	// Handle one-shot mode
-	projectAnalyzer, err := analyzer.NewProjectAnalyzer(ctx, cfg)
+	currency, err := config.ParseCurrency(currencyCode, currencyRate, locale)
 	if err != nil {
-		return fmt.Errorf("failed to create analyzer: %w", err)
+		return err
+	}
+	if len(list.Approvals) == 0 {
+		fmt.Println("No approval requests")
+		return nil
+	}
+	headers := []string{"ID", "Status", "Instance", "Change", "Savings/mo", "Created", "Decided By"}
+	rows := make([][]string, 0, len(list.Approvals))
+	for _, req := range list.Approvals {
+		change := req.CurrentType + " → " + req.TargetType
+		if req.DowntimeExpected {
+			change += " (downtime)"
+		}
+		rows = append(rows, []string{req.ID, string(req.Status), req.Instance, change,
+			currency.Format(req.EstimatedSavings), config.FormatTime(req.CreatedAt), req.DecidedBy})
 	}
-	defer projectAnalyzer.Close()
 
-	if output != "table" && output != "json" {
-		return fmt.Errorf("invalid output format: %s (must be 'table' or 'json')", output)
+	widths := make([]int, len(headers))
+	for i, header := range headers {
+		widths[i] = len(header)
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+	printRow(headers, widths)
+	printSeparator(widths)
+	for _, row := range rows {
+		printRow(row, widths)
 	}
+	return nil
+}
 
-	if len(instances) > 0 {
-		return analyzeSpecificInstances(ctx, projectAnalyzer, instances)
+func runApprovalsDecide(cmd *cobra.Command, args []string) error {
+	if approvalsBy == "" {
+		return fmt.Errorf("--by is required")
 	}
-	return analyzeAllInstances(ctx, projectAnalyzer)
+	var req approval.Request
+	body := map[string]string{"by": approvalsBy, "comment": approvalsComment}
+	if err := approvalsRequest(cmd.Context(), http.MethodPost, "/"+url.PathEscape(args[0])+"/"+cmd.Name(), body, &req); err != nil {
+		return err
+	}
+	fmt.Printf("Request %s to scale %s from %s to %s %s\n", req.ID, req.Instance, req.CurrentType, req.TargetType, req.Status)
+	return nil
 }
 
-func runDaemon(ctx context.Context, cfg *config.Config) error {
-	// Initialize metrics if enabled
-	if enableMetrics {
-		daemon.InitMetrics()
+func runSandbox(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	if metricsSource != "" {
+		return fmt.Errorf("--metrics-source cannot be combined with sandbox, which generates its own metrics")
+	}
+	if sandboxFleetSize <= 0 {
+		return fmt.Errorf("invalid --fleet-size %d: must be positive", sandboxFleetSize)
+	}
+	if httpPort <= 0 {
+		return fmt.Errorf("invalid --http-port %d: the sandbox serves its dashboard over HTTP", httpPort)
 	}
+	if projectID == "" {
+		projectID = "sandbox"
+	}
+	dryRun = sandboxDryRun || readOnly
 
-	// Create daemon configuration
+	cfg, err := buildConfig(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.Force = sandboxForce
+	cfg.MetricsPeriod = sandboxHistory
+	cfg.AnalysisSpreadWindow = sandboxInterval / 2
+	cfg.ScheduleLead = max(cfg.ScheduleLead, sandboxInterval)
+
+	fake := sandbox.NewProject(cfg.ProjectID, sandboxFleetSize, sandboxSeed)
+	fake.SetOperationDelay(sandboxOpDelay)
+
+	daemon.InitMetrics()
 	daemonCfg := &daemon.DaemonConfig{
-		Interval:      daemonInterval,
+		Interval:      sandboxInterval,
 		HTTPPort:      httpPort,
-		EnableMetrics: enableMetrics,
+		EnableMetrics: true,
+
+		APIToken:            apiToken,
+		MaxPreScaleDuration: preScaleMax,
+		SecretRefresh:       secretRefresh,
+		SlackWebhook:        slackWebhook,
+		SlackBotToken:       slackBotToken,
+		DatadogAPIKey:       datadogAPIKey,
+		DatadogSite:         datadogSite,
+		DatadogTags:         datadogTags,
+		PagerDutyRoutingKey: pagerDutyKey,
+		OverloadCycles:      overloadCycles,
+		StableCycles:        stableCycles,
+		PubSubTopic:         pubsubTopic,
+		IssueTracker:        issueTracker,
+		IssueTrackerToken:   issueToken,
+		IssueAfter:          time.Duration(issueAfterDays) * 24 * time.Hour,
+		WebhookURL:          webhookURL,
+		WebhookHeaders:      webhookHeaders,
+		WebhookTemplate:     webhookTmpl,
+		EventFormat:         eventFormat,
+
+		CycleDeadline: cycleDeadline,
+
+		Profile:    profile,
+		ConfigFile: configPath,
+		Build:      buildInfo(),
+
+		SQLAdmin: fake,
+		Metrics:  fake,
 	}
 
-	// Create and start daemon
 	d, err := daemon.NewDaemon(cfg, daemonCfg)
 	if err != nil {
 		return fmt.Errorf("failed to create daemon: %w", err)
 	}
 
+	logf("Sandbox project %s with %d simulated instance(s); dashboard at http://localhost:%d/dashboard\n", cfg.ProjectID, sandboxFleetSize, httpPort)
 	return d.Start()
 }
 
-func analyzeSpecificInstances(ctx context.Context, analyzer *analyzer.ProjectAnalyzer, instances []string) error {
+func analyzeSpecificInstances(ctx context.Context, analyzer *analyzer.ProjectAnalyzer, instances []string, cfg *config.Config) error {
 	var results []OutputResult
 	var tableRows []TableRow
 
@@ -219,24 +2160,29 @@ func analyzeSpecificInstances(ctx context.Context, analyzer *analyzer.ProjectAna
 
 		result, err := analyzer.AnalyzeInstance(ctx, instanceName)
 		if err != nil {
+			skipReason := cloudsql.ClassifySkip(err)
 			outputResult.Error = err.Error()
+			outputResult.Hint = cloudsql.Hint(err)
 			outputResult.Action = "error"
+			outputResult.SkipReason = string(skipReason)
 			outputResult.Reason = "Failed to analyze instance"
 			tableRow.Action = "ERROR"
 			tableRow.Status = "Failed"
-			tableRow.Warning = "Analysis failed"
+			tableRow.Warning = "Analysis failed: " + string(skipReason)
 			logf("  Error: %v\n", err)
+			logHint(err)
 			hasErrors = true
 			results = append(results, outputResult)
 			tableRows = append(tableRows, tableRow)
 			continue
 		}
 
-		outputResult.CurrentType = result.Instance.MachineType
-		outputResult.CurrentCPU = result.Instance.CurrentCPU
-		outputResult.CurrentMemoryGB = result.Instance.CurrentMemoryGB
+		outputResult.describeInstance(result.Instance)
+		outputResult.describeOwner(result.Owner, &tableRow)
+		outputResult.Warnings = result.Warnings
 		tableRow.CurrentType = result.Instance.MachineType
 		tableRow.CurrentResources = fmt.Sprintf("%d CPU, %.1f GB", result.Instance.CurrentCPU, result.Instance.CurrentMemoryGB)
+		tableRow.describeTrends(result.Metrics)
 
 		if result.Decision.ShouldScale {
 			// Determine scale direction
@@ -252,7 +2198,9 @@ func analyzeSpecificInstances(ctx context.Context, analyzer *analyzer.ProjectAna
 
 			outputResult.Action = strings.ToLower(action)
 			outputResult.RecommendedType = result.Decision.RecommendedType
-			outputResult.Reason = result.Decision.Reason
+			outputResult.DecisionID = result.Decision.ID
+			outputResult.DecisionKey = result.Decision.Key
+			outputResult.describeDecision(result.Decision)
 			tableRow.Action = action
 			tableRow.RecommendedType = result.Decision.RecommendedType
 
@@ -261,13 +2209,18 @@ func analyzeSpecificInstances(ctx context.Context, analyzer *analyzer.ProjectAna
 				tableRow.Warning = "Downtime expected"
 			}
 
-			if !dryRun {
+			if deferred, ok := analyzer.PlanInstance(result).IsDeferred(instanceName); ok {
+				outputResult.describeDeferral(deferred, &tableRow)
+				logf("  Deferred: %s\n", deferred.DeferReason)
+			} else if !dryRun {
 				logf("  Applying scaling from %s to %s...\n", result.Instance.MachineType, result.Decision.RecommendedType)
 				if err := analyzer.ApplyScaling(ctx, instanceName, result.Decision); err != nil {
 					outputResult.Error = err.Error()
+					outputResult.Hint = cloudsql.Hint(err)
 					tableRow.Status = "FAILED"
 					tableRow.Warning = "Scaling failed"
 					logf("  Failed: %v\n", err)
+					logHint(err)
 					hasErrors = true
 				} else {
 					outputResult.Applied = true
@@ -277,12 +2230,26 @@ func analyzeSpecificInstances(ctx context.Context, analyzer *analyzer.ProjectAna
 			} else {
 				tableRow.Status = "DRY-RUN"
 			}
+		} else if result.Instance.UnsupportedTier {
+			outputResult.Action = "advisory"
+			outputResult.describeDecision(result.Decision)
+			tableRow.Action = "ADVISORY"
+			tableRow.Status = "OK"
+			tableRow.Warning = "Tier not in catalog"
 		} else {
 			outputResult.Action = "no_action"
-			outputResult.Reason = result.Decision.Reason
+			outputResult.describeDecision(result.Decision)
 			tableRow.Action = "NONE"
 			tableRow.Status = "OK"
 		}
+		if outputResult.describeStorage(ctx, analyzer, cfg, result, &tableRow) {
+			hasErrors = true
+		}
+		outputResult.DiskShrink = result.DiskShrink
+		outputResult.Forecast = result.Forecast
+		outputResult.ColdStart = result.ColdStart
+		outputResult.DowntimeBudget = result.Downtime
+		outputResult.FleetSignals = result.Fleet
 
 		results = append(results, outputResult)
 		tableRows = append(tableRows, tableRow)
@@ -290,17 +2257,20 @@ func analyzeSpecificInstances(ctx context.Context, analyzer *analyzer.ProjectAna
 
 	if output == "json" {
 		summary := OutputSummary{
-			ProjectID: projectID, TotalInstances: len(instances), AnalyzedInstances: len(instances) - countErrors(results),
+			SchemaVersion: outputSchemaVersion,
+			ProjectID:     projectID, TotalInstances: len(instances), AnalyzedInstances: len(instances) - countErrors(results),
 			ScalingResults: results, Profile: profile, DryRun: dryRun, Timestamp: time.Now(),
 		}
-		jsonOutput, err := json.MarshalIndent(summary, "", "  ")
+		if cfg.Redaction.Enabled() {
+			summary.Redacted = cfg.Redaction.Fields()
+		}
+		jsonOutput, err := redact.New(cfg.Redaction, cfg.Currency).JSON(summary, projectID, instances)
 		if err != nil {
 			return fmt.Errorf("failed to marshal JSON output: %w", err)
 		}
 		fmt.Println(string(jsonOutput))
 	} else {
-		headers := []string{"Instance", "Current Type", "Resources", "Action", "Recommended", "Status", "Warning"}
-		printTable(headers, tableRows)
+		printTable(tableHeaders(), tableRows)
 	}
 
 	if hasErrors {
@@ -309,7 +2279,7 @@ func analyzeSpecificInstances(ctx context.Context, analyzer *analyzer.ProjectAna
 	return nil
 }
 
-func analyzeAllInstances(ctx context.Context, analyzer *analyzer.ProjectAnalyzer) error {
+func analyzeAllInstances(ctx context.Context, analyzer *analyzer.ProjectAnalyzer, cfg *config.Config) error {
 	results, err := analyzer.AnalyzeAllInstances(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to analyze instances: %w", err)
@@ -321,17 +2291,28 @@ func analyzeAllInstances(ctx context.Context, analyzer *analyzer.ProjectAnalyzer
 
 	logf("Total instances: %d, Analyzed: %d, Need scaling: %d\n", results.TotalInstances, results.AnalyzedInstances, len(scalable))
 
-	var hasErrors bool
-	for _, result := range results.Results {
-		outputResult := OutputResult{
-			Instance: result.Instance.Name, CurrentType: result.Instance.MachineType,
-			CurrentCPU: result.Instance.CurrentCPU, CurrentMemoryGB: result.Instance.CurrentMemoryGB,
-			Applied: false, Timestamp: time.Now(),
-		}
+	plan := analyzer.PlanScaling(results)
+
+	// Only the top results are applied and reported; skipped instances are
+	// listed after them, outside the count
+	ranked := results.Ranked(sortKey)
+	if topN > 0 && topN < len(ranked) {
+		logf("Showing top %d of %d results by %s\n", topN, len(ranked), sortKey)
+		ranked = ranked[:topN]
+	}
+
+	// Read replicas change before any primary is resized
+	replicas, hasErrors := applyReplicaChanges(ctx, analyzer, cfg, results, plan)
+	for _, result := range ranked {
+		outputResult := OutputResult{Instance: result.Instance.Name, Applied: false, Timestamp: time.Now()}
+		outputResult.describeInstance(result.Instance)
+		outputResult.Warnings = result.Warnings
 		tableRow := TableRow{
 			Instance: result.Instance.Name, CurrentType: result.Instance.MachineType,
 			CurrentResources: fmt.Sprintf("%d CPU, %.1f GB", result.Instance.CurrentCPU, result.Instance.CurrentMemoryGB),
 		}
+		outputResult.describeOwner(result.Owner, &tableRow)
+		tableRow.describeTrends(result.Metrics)
 
 		if result.Decision.ShouldScale {
 			// Determine scale direction
@@ -347,7 +2328,9 @@ func analyzeAllInstances(ctx context.Context, analyzer *analyzer.ProjectAnalyzer
 
 			outputResult.Action = strings.ToLower(action)
 			outputResult.RecommendedType = result.Decision.RecommendedType
-			outputResult.Reason = result.Decision.Reason
+			outputResult.DecisionID = result.Decision.ID
+			outputResult.DecisionKey = result.Decision.Key
+			outputResult.describeDecision(result.Decision)
 			tableRow.Action = action
 			tableRow.RecommendedType = result.Decision.RecommendedType
 
@@ -356,13 +2339,17 @@ func analyzeAllInstances(ctx context.Context, analyzer *analyzer.ProjectAnalyzer
 				tableRow.Warning = "Downtime expected"
 			}
 
-			if !dryRun {
+			if deferred, ok := plan.IsDeferred(result.Instance.Name); ok {
+				outputResult.describeDeferral(deferred, &tableRow)
+			} else if !dryRun {
 				logf("Applying scaling for %s from %s to %s...\n", result.Instance.Name, result.Instance.MachineType, result.Decision.RecommendedType)
 				if err := analyzer.ApplyScaling(ctx, result.Instance.Name, result.Decision); err != nil {
 					outputResult.Error = err.Error()
+					outputResult.Hint = cloudsql.Hint(err)
 					tableRow.Status = "FAILED"
 					tableRow.Warning = "Scaling failed"
 					logf("  Failed: %v\n", err)
+					logHint(err)
 					hasErrors = true
 				} else {
 					outputResult.Applied = true
@@ -372,30 +2359,74 @@ func analyzeAllInstances(ctx context.Context, analyzer *analyzer.ProjectAnalyzer
 			} else {
 				tableRow.Status = "DRY-RUN"
 			}
+		} else if result.Instance.UnsupportedTier {
+			outputResult.Action = "advisory"
+			outputResult.describeDecision(result.Decision)
+			tableRow.Action = "ADVISORY"
+			tableRow.Status = "OK"
+			tableRow.Warning = "Tier not in catalog"
 		} else {
 			outputResult.Action = "no_action"
-			outputResult.Reason = result.Decision.Reason
+			outputResult.describeDecision(result.Decision)
 			tableRow.Action = "NONE"
 			tableRow.Status = "OK"
 		}
+		if outputResult.describeStorage(ctx, analyzer, cfg, result, &tableRow) {
+			hasErrors = true
+		}
+		outputResult.DiskShrink = result.DiskShrink
+		outputResult.Forecast = result.Forecast
+		outputResult.ColdStart = result.ColdStart
+		outputResult.DowntimeBudget = result.Downtime
+		outputResult.FleetSignals = result.Fleet
+		if r, ok := replicas[result.Instance.Name]; ok {
+			outputResult.Replicas = r
+			if note := r.note(); note != "" {
+				tableRow.addWarning(note)
+			}
+		}
 
 		outputResults = append(outputResults, outputResult)
 		tableRows = append(tableRows, tableRow)
 	}
 
-	if output == "json" {
+	for _, skip := range results.Skipped {
+		outputResults = append(outputResults, OutputResult{
+			Instance: skip.Name, Action: "skipped", SkipReason: string(skip.Reason),
+			Reason: skip.Detail, Hint: skip.Hint, Timestamp: time.Now(),
+			StoppedAt: skip.StoppedAt, DeletionReview: skip.DeletionReview,
+		})
+		tableRow := TableRow{Instance: skip.Name, Action: "SKIPPED", Status: string(skip.Reason)}
+		if skip.DeletionReview {
+			tableRow.addWarning("Review for deletion")
+		}
+		tableRows = append(tableRows, tableRow)
+	}
+
+	if summaryOnly {
+		if err := printProjectSummary(cfg, results); err != nil {
+			return err
+		}
+	} else if output == "html" {
+		if err := writeHTMLReport(cfg, results, plan, outputResults); err != nil {
+			return err
+		}
+	} else if output == "json" {
 		summary := OutputSummary{
-			ProjectID: projectID, TotalInstances: results.TotalInstances, AnalyzedInstances: results.AnalyzedInstances,
+			SchemaVersion: outputSchemaVersion,
+			ProjectID:     projectID, TotalInstances: results.TotalInstances, AnalyzedInstances: results.AnalyzedInstances,
 			ScalingResults: outputResults, Profile: profile, DryRun: dryRun, Timestamp: time.Now(),
 		}
-		jsonOutput, err := json.MarshalIndent(summary, "", "  ")
+		if cfg.Redaction.Enabled() {
+			summary.Redacted = cfg.Redaction.Fields()
+		}
+		jsonOutput, err := redact.New(cfg.Redaction, cfg.Currency).JSON(summary, projectID, results.InstanceNames())
 		if err != nil {
 			return fmt.Errorf("failed to marshal JSON output: %w", err)
 		}
 		fmt.Println(string(jsonOutput))
 	} else {
-		headers := []string{"Instance", "Current Type", "Resources", "Action", "Recommended", "Status", "Warning"}
-		printTable(headers, tableRows)
+		printTable(tableHeaders(), tableRows)
 	}
 
 	if hasErrors {
@@ -404,6 +2435,43 @@ func analyzeAllInstances(ctx context.Context, analyzer *analyzer.ProjectAnalyzer
 	return nil
 }
 
+// writeHTMLReport writes the HTML report of the ranked results, marking the
+// operations that were applied
+func writeHTMLReport(cfg *config.Config, results *analyzer.ProjectAnalysisResult, plan *analyzer.ScalingPlan, outputResults []OutputResult) error {
+	rep := report.New(results, analyzer.TopN(results.Ranked(sortKey), topN), plan, cfg, profile, dryRun)
+	for _, o := range outputResults {
+		if o.Applied {
+			rep.MarkApplied(o.Instance)
+		}
+	}
+	return rep.WriteHTML(os.Stdout)
+}
+
+// summaryTopActions is the number of actions listed in --summary output when --top is not set
+const summaryTopActions = 5
+
+func printProjectSummary(cfg *config.Config, results *analyzer.ProjectAnalysisResult) error {
+	limit := summaryTopActions
+	if topN > 0 {
+		limit = topN
+	}
+	summary := results.Summarize(limit, latencyBudget)
+	if groupKey != "" {
+		summary.GroupResults(results, groupKey)
+	}
+
+	if output == "json" {
+		jsonOutput, err := redact.New(cfg.Redaction, cfg.Currency).JSON(summary, results.ProjectID, results.InstanceNames())
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON output: %w", err)
+		}
+		fmt.Println(string(jsonOutput))
+		return nil
+	}
+	summary.Print(os.Stdout)
+	return nil
+}
+
 func countErrors(results []OutputResult) int {
 	count := 0
 	for _, result := range results {
@@ -414,6 +2482,67 @@ func countErrors(results []OutputResult) int {
 	return count
 }
 
+// secretFlags are the flags whose values are credentials, redacted from the
+// command line audit records carry. secretVar adds every flag resolved with
+// secrets.Resolve as it is defined; the few other secrets are added next to
+// their definitions.
+var secretFlags = make(map[string]bool)
+
+// secretVar defines a string flag holding a credential, or a reference
+// secrets.Resolve loads it from, and records it in secretFlags
+func secretVar(flags *pflag.FlagSet, p *string, name, value, usage string) {
+	flags.StringVar(p, name, value, usage)
+	secretFlags[name] = true
+}
+
+// redactArgs returns args with the values of secretFlags, given as --flag
+// VALUE, --flag=VALUE or --set KEY=VALUE, replaced by REDACTED
+func redactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	copy(redacted, args)
+	secret := func(key string) bool {
+		key = strings.ReplaceAll(strings.TrimSpace(key), "_", "-")
+		if section, setting, ok := strings.Cut(key, "."); ok && section == "daemon" {
+			key = daemonSettings[setting]
+		}
+		return secretFlags[key]
+	}
+	for i := 0; i < len(redacted); i++ {
+		if !strings.HasPrefix(redacted[i], "--") {
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(redacted[i], "--"), "=")
+		switch {
+		case name == "set" && hasValue:
+			if key, _, ok := strings.Cut(value, "="); ok && secret(key) {
+				redacted[i] = "--set=" + key + "=REDACTED"
+			}
+		case name == "set" && i+1 < len(redacted):
+			if key, _, ok := strings.Cut(redacted[i+1], "="); ok && secret(key) {
+				redacted[i+1] = key + "=REDACTED"
+			}
+			i++
+		case secretFlags[name] && hasValue:
+			redacted[i] = "--" + name + "=REDACTED"
+		case secretFlags[name] && i+1 < len(redacted):
+			redacted[i+1] = "REDACTED"
+			i++
+		}
+	}
+	return redacted
+}
+
+// resolveActor returns who this process applies changes as in mode. A
+// principal that cannot be determined is left out with a warning rather than
+// stopping the run.
+func resolveActor(ctx context.Context, mode string) *audit.Actor {
+	principal, err := cloudsql.Principal(ctx)
+	if err != nil {
+		logf("Warning: %v; applied changes are recorded without a principal\n", err)
+	}
+	return audit.NewActor(principal, mode, redactArgs(os.Args[1:]))
+}
+
 func getDefaultProjectID(ctx context.Context) (string, error) {
 	if metadata.OnGCE() {
 		project, err := metadata.ProjectID()
@@ -427,6 +2556,39 @@ func getDefaultProjectID(ctx context.Context) (string, error) {
 	return "", fmt.Errorf("unable to determine project ID from Application Default Credentials")
 }
 
+// buildShadowConfig derives the candidate config evaluated in shadow from the
+// active config and the --shadow-* flags, or returns nil when none are set
+func buildShadowConfig(active *config.Config) (*config.Config, error) {
+	if shadowProfile == "" && shadowScaleUpAt == 0 && shadowScaleDownAt == 0 {
+		return nil, nil
+	}
+
+	candidate := *active
+	candidate.Shadow = nil
+	if shadowProfile != "" {
+		switch shadowProfile {
+		case "default", "conservative", "aggressive":
+		default:
+			return nil, fmt.Errorf("invalid shadow profile: %s (must be default, conservative or aggressive)", shadowProfile)
+		}
+		p := buildConfigFromProfile(shadowProfile)
+		candidate.ScaleUpThreshold = p.ScaleUpThreshold
+		candidate.ScaleDownThreshold = p.ScaleDownThreshold
+		candidate.MinStableDuration = p.MinStableDuration
+	}
+	if shadowScaleUpAt > 0 {
+		candidate.ScaleUpThreshold = shadowScaleUpAt
+	}
+	if shadowScaleDownAt > 0 {
+		candidate.ScaleDownThreshold = shadowScaleDownAt
+	}
+	if candidate.ScaleDownThreshold >= candidate.ScaleUpThreshold {
+		return nil, fmt.Errorf("shadow scale-down threshold %.2f must be below its scale-up threshold %.2f",
+			candidate.ScaleDownThreshold, candidate.ScaleUpThreshold)
+	}
+	return &candidate, nil
+}
+
 func buildConfigFromProfile(profile string) *config.Config {
 	cfg := config.DefaultConfig()
 	switch profile {
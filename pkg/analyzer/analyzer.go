@@ -2,113 +2,417 @@ package analyzer
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"os"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/audit"
 	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
 	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
 	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/rules"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/state"
 )
 
 // Analyzer performs instance analysis and generates recommendations
 type Analyzer struct {
-	sqlClient     *cloudsql.Client
-	metricsClient *cloudsql.MetricsClient
+	sqlClient     SQLAdmin
+	metricsClient MetricsSource
+	ownsMetrics   bool
+	release       func() // Returns pooled clients to their pool
 	rulesEngine   *rules.Engine
+	overrides     map[string]*rules.Engine         // Engines of instances with their own thresholds, by name
+	profiles      map[string]*rules.Engine         // Engines of instances whose labels name a profile, by profile
+	scheduled     map[*config.Config]*rules.Engine // Engines of scheduled profiles, by Schedule.ProfileConfig
+	shadow        *rules.Engine                    // Engine of Config.Shadow; nil without one
 	config        *config.Config
+	progress      io.Writer
+	progressMu    sync.Mutex // Keeps concurrent analyses' messages whole
+	auditLog      *audit.Logger
+	actor         *audit.Actor // Who applies changes; nil records no one
+	prober        cloudsql.Prober
+	journal       OperationJournal
+	state         *state.Store
+	fleet         FleetSource      // Recommender signals merged into analyses; nil unless Config.FleetSignals
+	pricing       PriceSource      // Cloud Billing Catalog rates for cost estimates; nil unless Config.CatalogPricing
+	hook          DecisionHook     // Reviews proposed resizes; nil when no decision hook is configured
+	permissions   PermissionSource // Tests IAM permissions in Preflight; nil unless the clients are the analyzer's own
+	chains        *chainGuard      // Serializes operations within a replication chain
+	replayAt      time.Time        // When the metrics file analyzed was exported; zero for live analysis
+	slowLog       slowLogLimiter   // Rate-limits the over-budget analysis warning
+
+	// Downtime spent on resizes, when there is no state store to keep it in
+	downtimeMu sync.Mutex
+	downtime   downtimeLedger
+
+	// Calls using the clients, which Close waits for
+	closeMu sync.RWMutex
+	closed  bool
+	calls   sync.WaitGroup
 }
 
-// NewAnalyzer creates a new analyzer
-func NewAnalyzer(ctx context.Context, cfg *config.Config) (*Analyzer, error) {
-	sqlClient, err := cloudsql.NewClient(ctx, cfg.ProjectID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Cloud SQL client: %w", err)
+// Now returns the time decisions are made as of. For a metrics file that is
+// when it was exported, so schedules, blackouts, freezes and cooldowns are
+// judged as they were for the recorded metrics; otherwise it is the current
+// time.
+func (a *Analyzer) Now() time.Time {
+	if !a.replayAt.IsZero() {
+		return a.replayAt
 	}
+	return time.Now()
+}
 
-	metricsClient, err := cloudsql.NewMetricsClient(ctx, cfg.ProjectID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create metrics client: %w", err)
+// ErrClosed is returned by calls made after Close
+var ErrClosed = errors.New("analyzer is closed")
+
+// NewAnalyzer creates an analyzer with default Google API clients that
+// writes progress to stdout and audit records to stderr, as the CLI does.
+// Embedders should prefer New.
+func NewAnalyzer(ctx context.Context, cfg *config.Config) (*Analyzer, error) {
+	return New(ctx, Options{
+		Config:      cfg,
+		Progress:    os.Stdout,
+		AuditLogger: audit.DefaultLogger(),
+	})
+}
+
+// SetAuditLogger sets the logger that records applied scaling operations.
+// Like the other setters except SetProgressOutput, it must be called before
+// the analyzer is shared between goroutines.
+func (a *Analyzer) SetAuditLogger(l *audit.Logger) {
+	a.auditLog = l
+}
+
+// SetActor sets who applies changes, recorded in audit records and in the
+// labels of the instances changed
+func (a *Analyzer) SetActor(actor *audit.Actor) {
+	a.actor = actor
+}
+
+// attribute records the analyzer's actor in rec and in the labels rec's
+// change writes, if it has any
+func (a *Analyzer) attribute(rec *audit.Record) {
+	if a.actor == nil {
+		return
+	}
+	rec.Actor = a.actor
+	if rec.Labels != nil {
+		maps.Copy(rec.Labels, cloudsql.ActorLabels(a.actor.Principal, a.actor.Mode, a.actor.Host))
 	}
+}
 
-	rulesEngine := rules.NewEngine(cfg)
+// SetProgressOutput sets where progress messages are written; use io.Discard
+// to silence them. It may be called while analyses run.
+func (a *Analyzer) SetProgressOutput(w io.Writer) {
+	a.progressMu.Lock()
+	defer a.progressMu.Unlock()
+	a.progress = w
+}
 
-	return &Analyzer{
-		sqlClient:     sqlClient,
-		metricsClient: metricsClient,
-		rulesEngine:   rulesEngine,
-		config:        cfg,
-	}, nil
+// logf writes a progress message
+func (a *Analyzer) logf(format string, args ...interface{}) {
+	a.progressMu.Lock()
+	defer a.progressMu.Unlock()
+	fmt.Fprintf(a.progress, format, args...)
 }
 
-// Close closes all clients
+// checkout registers a call that uses the analyzer's clients, failing with
+// ErrClosed once Close has been called; done must be called when it returns
+func (a *Analyzer) checkout() (done func(), err error) {
+	a.closeMu.RLock()
+	defer a.closeMu.RUnlock()
+	if a.closed {
+		return nil, ErrClosed
+	}
+	a.calls.Add(1)
+	return a.calls.Done, nil
+}
+
+// Close closes the clients the analyzer created, or returns pooled ones to
+// their pool, once the calls in flight have returned; injected clients are
+// left open. Calls made after Close fail with ErrClosed.
 func (a *Analyzer) Close() error {
+	a.closeMu.Lock()
+	if a.closed {
+		a.closeMu.Unlock()
+		return nil
+	}
+	a.closed = true
+	a.closeMu.Unlock()
+	a.calls.Wait()
+
+	if a.release != nil {
+		a.release()
+		return nil
+	}
+	if !a.ownsMetrics {
+		return nil
+	}
 	return a.metricsClient.Close()
 }
 
+// QuotaStats returns the current Monitoring quota budget usage
+func (a *Analyzer) QuotaStats() cloudsql.QuotaStats {
+	return a.metricsClient.QuotaBudget().Stats()
+}
+
+// hedger is implemented by metrics sources that hedge slow requests
+type hedger interface {
+	HedgeStats() cloudsql.HedgeStats
+}
+
+// HedgeStats returns how many Monitoring requests were hedged; zero when the
+// metrics source does not hedge
+func (a *Analyzer) HedgeStats() cloudsql.HedgeStats {
+	if h, ok := a.metricsClient.(hedger); ok {
+		return h.HedgeStats()
+	}
+	return cloudsql.HedgeStats{}
+}
+
 // GetInstance retrieves instance information
 func (a *Analyzer) GetInstance(ctx context.Context, instanceName string) (*config.InstanceInfo, error) {
+	done, err := a.checkout()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
 	return a.sqlClient.GetInstance(ctx, instanceName)
 }
 
-// AnalyzeInstance performs a complete analysis of a Cloud SQL instance
+// AnalyzeInstance performs a complete analysis of a Cloud SQL instance.
+// It is equivalent to Analyze.
 func (a *Analyzer) AnalyzeInstance(ctx context.Context, instanceName string) (*AnalysisResult, error) {
+	return a.Analyze(ctx, instanceName)
+}
+
+// Analyze performs a complete analysis of a Cloud SQL instance: it reads the
+// instance and its metrics and returns the scaling decision, constraint
+// warnings and suggested window. It makes no changes to the instance. An
+// analysis still running after the configured per-instance timeout fails
+// with cloudsql.ErrAnalysisTimeout.
+func (a *Analyzer) Analyze(ctx context.Context, instanceName string) (*AnalysisResult, error) {
+	done, err := a.checkout()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	return a.analyzeWithTimeout(ctx, instanceName)
+}
+
+// analyzeWithTimeout runs analyze within the per-instance timeout
+func (a *Analyzer) analyzeWithTimeout(ctx context.Context, instanceName string) (*AnalysisResult, error) {
+	timeout := a.config.InstanceAnalysisTimeout
+	if timeout <= 0 {
+		return a.analyze(ctx, instanceName)
+	}
+
+	instanceCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	result, err := a.analyze(instanceCtx, instanceName)
+	if err != nil && ctx.Err() == nil && errors.Is(instanceCtx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("analysis of %s did not finish within %v: %w", instanceName, timeout, cloudsql.ErrAnalysisTimeout)
+	}
+	return result, err
+}
+
+// analyze performs the analysis Analyze describes
+func (a *Analyzer) analyze(ctx context.Context, instanceName string) (*AnalysisResult, error) {
+	start := time.Now()
+	timing := &AnalysisTiming{}
+
+	// Resolve tiers from the API; the built-in catalog covers any failure
+	if err := a.sqlClient.RefreshTiers(ctx); err != nil {
+		a.logf("Warning: %v; using the built-in machine type catalog\n", err)
+	}
+	if a.pricing != nil {
+		if err := a.pricing.Refresh(ctx); err != nil {
+			a.logf("Warning: %v; using built-in list prices\n", err)
+		}
+	}
+
 	// Get instance information
-	fmt.Printf("Fetching instance information for %s...\n", instanceName)
+	a.logf("Fetching instance information for %s...\n", instanceName)
 	instance, err := a.sqlClient.GetInstance(ctx, instanceName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get instance info: %w", err)
 	}
 
 	// Get last scaling time
-	instance.LastScaledTime, _ = a.sqlClient.GetLastScalingTime(ctx, instanceName)
+	instance.LastScaledTime = a.lastScalingTime(ctx, instance)
+	timing.InstanceAPI = time.Since(start)
 
 	// Fetch metrics
-	fmt.Printf("Collecting metrics for the last %v...\n", a.config.MetricsPeriod)
-	metrics, err := a.metricsClient.GetInstanceMetrics(ctx, instanceName, a.config)
+	a.logf("Collecting metrics for %s over the last %v...\n", instanceName, a.config.MetricsPeriod)
+	metricsStart := time.Now()
+	metrics, err := a.metricsClient.GetInstanceMetrics(ctx, instance, a.config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get metrics: %w", err)
 	}
+	timing.MetricsAPI = time.Since(metricsStart)
+	timing.MetricPoints = len(metrics.Timestamps)
 
 	// Calculate metrics summary
 	summary := cloudsql.CalculateMetricsSummary(metrics)
+	cloudsql.ApplyTrends(summary, metrics, a.config.TrendWindow)
+	cloudsql.ApplySustainedLag(summary, metrics, a.config.MinStableDuration)
+	if a.config.BusinessHours != nil {
+		summary.BusinessHours = cloudsql.CalculateMetricsSummary(cloudsql.FilterMetrics(metrics, a.config.BusinessHours.Contains))
+	}
 
 	// Analyze scaling requirements
-	fmt.Println("Analyzing scaling requirements...")
-	decision, err := a.rulesEngine.AnalyzeInstance(instance, summary)
+	a.logf("Analyzing scaling requirements of %s...\n", instanceName)
+	decision, err := a.engineFor(instance).AnalyzeInstance(instance, summary)
 	if err != nil {
 		return nil, fmt.Errorf("failed to analyze instance: %w", err)
 	}
+	if profile, ok := a.labelProfile(instance); ok && !instance.IsFailoverReplica {
+		decision.Reason = fmt.Sprintf("%s [profile %s by label %s]", decision.Reason, profile, cloudsql.LabelProfile)
+		decision.ReasonCodes = append(decision.ReasonCodes, cloudsql.ReasonLabelProfile)
+	}
+	now := a.Now()
+	decision, coldStart := a.applyColdStart(instance, metrics, decision)
+	if revert := a.revertScaleUp(instance, metrics, decision, now); revert != nil {
+		decision = revert
+	}
+	decision = a.applyRollback(instance, metrics, decision, now)
+	decision, forecast := a.applyForecast(instance, metrics, summary, decision)
+	if decision, err = a.applySchedule(instance, summary, decision, now); err != nil {
+		return nil, err
+	}
+	decision, hookWarning := a.applyDecisionHook(ctx, instance, decision)
+	decision, capacityWarning := a.rulesEngine.CheckConnectionCapacity(instance, summary, decision)
+	decision = a.rulesEngine.CheckTargetSizing(instance, summary, decision)
+	decision, ioWarning := a.rulesEngine.CheckIOBound(instance, summary, decision)
+	fleet, fleetWarnings := a.applyFleet(ctx, instance, decision)
+	if decision.ShouldScale {
+		a.decisionKey(instanceName, decision, now)
+	}
+
+	downtime := a.downtimeBudget(ctx, instance, decision, now)
+
+	storage := a.rulesEngine.AnalyzeStorage(instance, summary)
+	diskShrink := a.rulesEngine.AdviseDiskShrink(instance, summary)
 
 	// Check constraints
-	warnings := rules.CheckScalingConstraints(instance, summary, a.config)
+	warnings := rules.CheckScalingConstraints(instance, summary, a.config, now)
+	warnings = append(warnings, rules.PolicyLabelWarnings(instance, a.config)...)
+	if capacityWarning != nil {
+		warnings = append(warnings, *capacityWarning)
+	}
+	if ioWarning != nil {
+		warnings = append(warnings, *ioWarning)
+	}
+	warnings = append(warnings, fleetWarnings...)
+	if hookWarning != nil {
+		warnings = append(warnings, *hookWarning)
+	}
+	if coldStart != nil {
+		warnings = a.coldStartWarnings(warnings, coldStart, summary)
+	}
 
-	// Get optimal scaling window if scaling is recommended
+	// Validate the target and get the optimal scaling window if scaling is recommended
 	var scalingWindow *rules.ScalingWindow
+	var targetErr error
 	if decision.ShouldScale {
+		if targetErr = a.ValidateTarget(ctx, instance, decision.RecommendedType); targetErr != nil {
+			warnings = append(warnings, rules.Warning{
+				Code:     rules.WarningInvalidTarget,
+				Severity: rules.SeverityCritical,
+				Message:  fmt.Sprintf("Recommended machine type failed validation: %v", targetErr),
+				Data:     map[string]interface{}{"target_type": decision.RecommendedType, "region": instance.Region},
+			})
+		}
 		constraints := config.GetScalingConstraints(instance.Edition)
-		scalingWindow = rules.GetOptimalScalingWindow(metrics, constraints)
+		scalingWindow = rules.GetOptimalScalingWindow(metrics, constraints, now)
+	}
+
+	timing.Total = time.Since(start)
+	if budget := a.config.AnalysisLatencyBudget; budget > 0 && timing.Total > budget {
+		if ok, suppressed := a.slowLog.allow(time.Now()); ok {
+			a.logf("Analysis of %s took %v, over the %v latency budget (%d metric points, %d similar warnings suppressed)\n",
+				instanceName, timing.Total.Round(time.Millisecond), budget, timing.MetricPoints, suppressed)
+		}
 	}
 
 	return &AnalysisResult{
 		Instance:      instance,
+		Owner:         a.config.OwnerOf(instance),
 		Metrics:       metrics,
 		Summary:       summary,
 		Decision:      decision,
+		Storage:       storage,
+		DiskShrink:    diskShrink,
+		Forecast:      forecast,
+		Fleet:         fleet,
+		ColdStart:     coldStart,
+		Downtime:      downtime,
 		Warnings:      warnings,
 		ScalingWindow: scalingWindow,
-		AnalyzedAt:    time.Now(),
+		AnalyzedAt:    now,
+		Timing:        timing,
+		TargetError:   targetErr,
+		currency:      a.config.Currency,
 	}, nil
 }
 
+// ValidateTarget checks that machineType may be a target, which catches
+// targets a decision hook or a rollback chose, and that instance can be
+// resized to it, when the SQL Admin client supports validation
+func (a *Analyzer) ValidateTarget(ctx context.Context, instance *config.InstanceInfo, machineType string) error {
+	if why, restricted := a.config.RestrictsTarget(machineType); restricted {
+		return errors.New(why)
+	}
+	validator, ok := a.sqlClient.(MachineTypeValidator)
+	if !ok {
+		return config.CheckAvailability(instance.Edition, instance.DatabaseVersion, machineType)
+	}
+	return validator.ValidateMachineType(ctx, instance, machineType)
+}
+
 // AnalysisResult contains the complete analysis results
 type AnalysisResult struct {
 	Instance      *config.InstanceInfo
+	Owner         config.Owner // Who acts on the recommendation
 	Metrics       *config.MetricsData
 	Summary       *config.MetricsSummary
 	Decision      *cloudsql.ScalingDecision
-	Warnings      []string
+	Storage       *cloudsql.StorageDecision // Disk size recommendation; nil when storage autoscaling is off
+	DiskShrink    *cloudsql.DiskAdvisory    // Over-provisioned disk; nil unless the disk is mostly empty
+	Forecast      *Forecast                 // Projected utilization; nil unless predictive scaling is on and history suffices
+	Fleet         []cloudsql.FleetSignal    // Recommender recommendations and insights; nil unless fleet signals are on
+	ColdStart     *ColdStart                // History shortfall; nil unless cold start is on and history is short
+	Stability     *Stability                // Consistency of the recommendation across daemon cycles; nil outside the daemon
+	Downtime      *DowntimeBudget           // Monthly downtime budget and its use; nil unless the instance has one
+	Warnings      []rules.Warning
 	ScalingWindow *rules.ScalingWindow
 	AnalyzedAt    time.Time
+	Timing        *AnalysisTiming
+	TargetError   error // Why the recommended machine type cannot be applied; nil if it validated or could not be checked
+
+	currency config.Currency // Formats cost estimates in reports
+}
+
+// describeStorage summarizes an instance's data disk, e.g.
+// "100 GB PD_SSD, auto-resize up to 500 GB"
+func describeStorage(instance *config.InstanceInfo) string {
+	s := fmt.Sprintf("%d GB", instance.DiskSizeGB)
+	if instance.DiskType != "" {
+		s += " " + instance.DiskType
+	}
+	switch {
+	case instance.StorageAutoResize && instance.StorageAutoResizeLimitGB > 0:
+		s += fmt.Sprintf(", auto-resize up to %d GB", instance.StorageAutoResizeLimitGB)
+	case instance.StorageAutoResize:
+		s += ", auto-resize"
+	}
+	return s
 }
 
 // PrintAnalysisReport prints a formatted analysis report
@@ -116,7 +420,13 @@ func (r *AnalysisResult) PrintAnalysisReport() {
 	fmt.Printf("\n=== Cloud SQL Instance Analysis Report ===\n")
 	fmt.Printf("Instance: %s\n", r.Instance.Name)
 	fmt.Printf("Project: %s\n", r.Instance.Project)
-	fmt.Printf("Analyzed at: %s\n\n", r.AnalyzedAt.Format(time.RFC3339))
+	if !r.Owner.IsZero() {
+		fmt.Printf("Owner: %s\n", r.Owner)
+		if r.Owner.Notes != "" {
+			fmt.Printf("Notes: %s\n", r.Owner.Notes)
+		}
+	}
+	fmt.Printf("Analyzed at: %s\n\n", config.FormatTime(r.AnalyzedAt))
 
 	fmt.Printf("Current Configuration:\n")
 	fmt.Printf("  Machine Type: %s\n", r.Instance.MachineType)
@@ -127,9 +437,26 @@ func (r *AnalysisResult) PrintAnalysisReport() {
 	if r.Instance.Zone != "" {
 		fmt.Printf("  Zone: %s\n", r.Instance.Zone)
 	}
+	if r.Instance.DiskSizeGB > 0 {
+		fmt.Printf("  Storage: %s\n", describeStorage(r.Instance))
+	}
+	if r.Instance.PricingPlan != "" {
+		fmt.Printf("  Pricing Plan: %s\n", r.Instance.PricingPlan)
+	}
+	if len(r.Instance.DatabaseFlags) > 0 {
+		fmt.Printf("  Database Flags:\n")
+		names := make([]string, 0, len(r.Instance.DatabaseFlags))
+		for name := range r.Instance.DatabaseFlags {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("    %s = %s\n", name, r.Instance.DatabaseFlags[name])
+		}
+	}
 	if !r.Instance.LastScaledTime.IsZero() {
 		fmt.Printf("  Last Scaled: %s (%s ago)\n",
-			r.Instance.LastScaledTime.Format(time.RFC3339),
+			config.FormatTime(r.Instance.LastScaledTime),
 			time.Since(r.Instance.LastScaledTime).Round(time.Minute))
 	}
 
@@ -145,6 +472,35 @@ func (r *AnalysisResult) PrintAnalysisReport() {
 	fmt.Printf("    P95: %.1f%% (%.1f GB)\n", r.Summary.MemoryP95Pct, r.Summary.MemoryP95GB)
 	fmt.Printf("    P99: %.1f%% (%.1f GB)\n", r.Summary.MemoryP99Pct, r.Summary.MemoryP99GB)
 	fmt.Printf("    Max: %.1f GB\n", r.Summary.MemoryMaxGB)
+	if r.Summary.CPUTrendPerHour > 0 || r.Summary.MemoryTrendPerHour > 0 {
+		fmt.Printf("  Sustained Rise (last %v):\n", r.Summary.TrendWindow)
+		fmt.Printf("    CPU: %+.1f%%/hour\n", r.Summary.CPUTrendPerHour)
+		fmt.Printf("    Memory: %+.1f%%/hour\n", r.Summary.MemoryTrendPerHour)
+	}
+	if r.Summary.DiskIOPSP95 > 0 || r.Summary.DiskThroughputP95MBps > 0 {
+		limits := cloudsql.DiskLimitsFor(r.Instance.DiskType, r.Instance.DiskSizeGB, r.Instance.CurrentCPU)
+		fmt.Printf("  Disk IO:\n")
+		fmt.Printf("    IOPS P95: %.0f (limit %.0f)\n", r.Summary.DiskIOPSP95, limits.IOPS)
+		fmt.Printf("    Throughput P95: %.1f MB/s (limit %.0f MB/s)\n", r.Summary.DiskThroughputP95MBps, limits.ThroughputMBps)
+	}
+	if cloudsql.IsReadReplica(r.Instance) && r.Summary.ReplicaLagMaxSeconds > 0 {
+		fmt.Printf("  Replication Lag:\n")
+		fmt.Printf("    P95: %.0fs\n", r.Summary.ReplicaLagP95Seconds)
+		fmt.Printf("    Max: %.0fs\n", r.Summary.ReplicaLagMaxSeconds)
+		fmt.Printf("    Sustained: %.0fs\n", r.Summary.ReplicaLagSustainedSeconds)
+	}
+	if bh := r.Summary.BusinessHours; bh != nil {
+		fmt.Printf("  Business Hours (%d data points):\n", bh.DataPoints)
+		fmt.Printf("    CPU P95: %.1f%%\n", bh.CPUP95)
+		fmt.Printf("    Memory P95: %.1f%%\n", bh.MemoryP95Pct)
+	}
+	if r.Instance.DataCacheEnabled {
+		fmt.Printf("  Data Cache:\n")
+		fmt.Printf("    Average Used: %.1f GB\n", r.Summary.DataCacheUsedGB)
+		if r.Summary.DataCacheHitRatio > 0 {
+			fmt.Printf("    Hit Ratio: %.1f%%\n", r.Summary.DataCacheHitRatio)
+		}
+	}
 
 	fmt.Printf("\nScaling Recommendation:\n")
 	if r.Decision.ShouldScale {
@@ -152,11 +508,12 @@ func (r *AnalysisResult) PrintAnalysisReport() {
 		fmt.Printf("  Current Type: %s\n", r.Decision.CurrentType)
 		fmt.Printf("  Recommended Type: %s\n", r.Decision.RecommendedType)
 		fmt.Printf("  Reason: %s\n", r.Decision.Reason)
+		printReasonCodes(r.Decision.ReasonCodes)
 
 		if r.Decision.EstimatedSavings > 0 {
-			fmt.Printf("  Estimated Monthly Savings: $%.2f\n", r.Decision.EstimatedSavings)
+			fmt.Printf("  Estimated Monthly Savings: %s\n", r.currency.Format(r.Decision.EstimatedSavings))
 		} else if r.Decision.EstimatedSavings < 0 {
-			fmt.Printf("  Estimated Monthly Cost Increase: $%.2f\n", -r.Decision.EstimatedSavings)
+			fmt.Printf("  Estimated Monthly Cost Increase: %s\n", r.currency.Format(-r.Decision.EstimatedSavings))
 		}
 
 		if r.Decision.DowntimeExpected {
@@ -171,24 +528,63 @@ func (r *AnalysisResult) PrintAnalysisReport() {
 
 		if r.ScalingWindow != nil {
 			fmt.Printf("\nRecommended Scaling Window:\n")
-			fmt.Printf("  Start: %s\n", r.ScalingWindow.Start.Format(time.RFC3339))
-			fmt.Printf("  End: %s\n", r.ScalingWindow.End.Format(time.RFC3339))
+			fmt.Printf("  Start: %s\n", config.FormatTime(r.ScalingWindow.Start))
+			fmt.Printf("  End: %s\n", config.FormatTime(r.ScalingWindow.End))
 		}
 	} else {
 		fmt.Printf("  Action: NO SCALING NEEDED\n")
 		fmt.Printf("  Reason: %s\n", r.Decision.Reason)
+		printReasonCodes(r.Decision.ReasonCodes)
+	}
+
+	if s := r.Storage; s != nil {
+		fmt.Printf("\nStorage Recommendation:\n")
+		if s.UsedGB > 0 {
+			fmt.Printf("  Disk Used: %.1f of %d GB (%.1f%%)\n", s.UsedGB, s.CurrentSizeGB, s.UsedPct)
+		}
+		if s.ShouldResize {
+			fmt.Printf("  Action: GROW DISK\n")
+			fmt.Printf("  Recommended Size: %d GB\n", s.RecommendedSizeGB)
+		} else {
+			fmt.Printf("  Action: NO CHANGE\n")
+		}
+		fmt.Printf("  Reason: %s\n", s.Reason)
+		printReasonCodes(s.ReasonCodes)
+		if s.EstimatedCost > 0 {
+			fmt.Printf("  Estimated Monthly Cost Increase: %s\n", r.currency.Format(s.EstimatedCost))
+		}
+	}
+
+	if s := r.DiskShrink; s != nil {
+		fmt.Printf("\nStorage Advisory:\n")
+		fmt.Printf("  Disk Used: %.1f of %d GB (%.1f%%), growing %.1f GB/month\n", s.UsedGB, s.CurrentSizeGB, s.UsedPct, s.GrowthGBPerMonth)
+		fmt.Printf("  Right-sized Disk: %d GB (%d GB reclaimable)\n", s.RecommendedSizeGB, s.ReclaimableGB)
+		fmt.Printf("  Reason: %s\n", s.Reason)
+		fmt.Printf("  Estimated Monthly Savings After Migration: %s\n", r.currency.Format(s.EstimatedSavings))
 	}
 
 	if len(r.Warnings) > 0 {
 		fmt.Printf("\nWarnings:\n")
 		for _, warning := range r.Warnings {
-			fmt.Printf("  ⚠️  %s\n", warning)
+			fmt.Printf("  ⚠️  [%s] %s\n", warning.Severity, warning.Message)
 		}
 	}
 
 	fmt.Printf("\n")
 }
 
+// printReasonCodes prints a decision's reason codes, if it has any
+func printReasonCodes(reasonCodes []cloudsql.ReasonCode) {
+	if len(reasonCodes) == 0 {
+		return
+	}
+	codes := make([]string, len(reasonCodes))
+	for i, code := range reasonCodes {
+		codes[i] = string(code)
+	}
+	fmt.Printf("  Reason Codes: %s\n", strings.Join(codes, ", "))
+}
+
 // PrintMetricsSummary prints a brief metrics summary
 func (r *AnalysisResult) PrintMetricsSummary() {
 	fmt.Printf("Instance: %s | CPU P95: %.1f%% | Memory P95: %.1f%% | ",
@@ -0,0 +1,191 @@
+package analyzer
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// CalendarEntryKind classifies an entry in the scaling calendar
+type CalendarEntryKind string
+
+const (
+	CalendarScheduled CalendarEntryKind = "scheduled" // Operation that runs this cycle or in its scaling window
+	CalendarDeferred  CalendarEntryKind = "deferred"  // Operation the fleet optimizer postponed
+	CalendarCooldown  CalendarEntryKind = "cooldown"  // Instance leaves its post-scaling cooldown
+	CalendarBlackout  CalendarEntryKind = "blackout"  // Change freeze during which nothing scales
+	CalendarFreeze    CalendarEntryKind = "freeze"    // Scaling freeze covering some or all instances
+	CalendarSchedule  CalendarEntryKind = "schedule"  // Scheduled scaling window
+)
+
+// calendarScheduleWindows is the most windows of one schedule in a calendar,
+// so frequent schedules do not crowd out everything else
+const calendarScheduleWindows = 24
+
+// CalendarEntry is a single dated event in the scaling calendar
+type CalendarEntry struct {
+	Time        time.Time         `json:"time"`
+	End         time.Time         `json:"end,omitempty"`
+	Kind        CalendarEntryKind `json:"kind"`
+	Instance    string            `json:"instance,omitempty"`
+	Description string            `json:"description"`
+}
+
+// BuildCalendar lays out what the autoscaler will do between now and
+// now+horizon: scheduled and deferred operations from the plan, cooldown
+// expirations from the analysis results, configured blackout windows and
+// scaling freezes, and schedule windows.
+// Entries are returned in chronological order.
+func BuildCalendar(results *ProjectAnalysisResult, plan *ScalingPlan, cfg *config.Config, now time.Time, horizon time.Duration) []CalendarEntry {
+	until := now.Add(horizon)
+	var entries []CalendarEntry
+
+	for _, op := range plan.Operations {
+		entry := CalendarEntry{
+			Time:        now,
+			Kind:        CalendarScheduled,
+			Instance:    op.Instance,
+			Description: describeOperation(op),
+		}
+		if op.Window != nil && op.Window.Start.After(now) {
+			entry.Time, entry.End = op.Window.Start, op.Window.End
+		}
+		entries = append(entries, entry)
+	}
+
+	for _, d := range plan.Deferred {
+		entry := CalendarEntry{
+			Time:        d.NotBefore,
+			Kind:        CalendarDeferred,
+			Instance:    d.Instance,
+			Description: fmt.Sprintf("%s (%s)", describeOperation(d.ScalingOperation), d.DeferReason),
+		}
+		if entry.Time.Before(now) {
+			// Deferred to the next cycle rather than a specific time
+			entry.Time = now
+		}
+		if d.Window != nil && !entry.Time.Before(d.Window.Start) {
+			entry.End = d.Window.End
+		}
+		entries = append(entries, entry)
+	}
+
+	for _, r := range results.Results {
+		if r.Instance == nil || r.Instance.LastScaledTime.IsZero() {
+			continue
+		}
+		expires := r.Instance.LastScaledTime.Add(cfg.CoolDownFor(r.Instance.Name))
+		if expires.After(now) {
+			entries = append(entries, CalendarEntry{
+				Time:     expires,
+				Kind:     CalendarCooldown,
+				Instance: r.Instance.Name,
+				Description: fmt.Sprintf("Cooldown ends (last scaled %s)",
+					config.FormatTime(r.Instance.LastScaledTime)),
+			})
+		}
+	}
+
+	for _, w := range cfg.BlackoutWindows {
+		if w.End.Before(now) {
+			continue
+		}
+		description := "Blackout: no scaling"
+		if w.Reason != "" {
+			description += " (" + w.Reason + ")"
+		}
+		entries = append(entries, CalendarEntry{Time: w.Start, End: w.End, Kind: CalendarBlackout, Description: description})
+	}
+
+	for _, f := range cfg.Freezes {
+		if !f.Active(now) {
+			continue
+		}
+		entries = append(entries, CalendarEntry{Time: now, End: f.Until, Kind: CalendarFreeze,
+			Description: fmt.Sprintf("Freeze (%s): no scaling except emergencies (%s)", f.Target(), f.Reason)})
+	}
+
+	for _, s := range cfg.Schedules {
+		instance := s.Instance
+		if instance == "" {
+			instance = fmt.Sprintf("label %s=%s", s.LabelKey, s.LabelValue)
+		}
+		description := fmt.Sprintf("Scheduled: at least %s", s.Target())
+		if s.Profile != "" {
+			description = "Scheduled: " + s.Target()
+		}
+		if s.Reason != "" {
+			description += " (" + s.Reason + ")"
+		}
+		// Start from the window in progress, if any
+		start := s.Next(now.Add(-s.Duration))
+		for n := 0; !start.IsZero() && !start.After(until) && n < calendarScheduleWindows; n++ {
+			entries = append(entries, CalendarEntry{Time: start, End: start.Add(s.Duration), Kind: CalendarSchedule,
+				Instance: instance, Description: description})
+			start = s.Next(start)
+		}
+	}
+
+	// Keep only what happens within the horizon
+	filtered := entries[:0]
+	for _, e := range entries {
+		if !e.Time.After(until) {
+			filtered = append(filtered, e)
+		}
+	}
+
+	sort.SliceStable(filtered, func(i, j int) bool {
+		if !filtered[i].Time.Equal(filtered[j].Time) {
+			return filtered[i].Time.Before(filtered[j].Time)
+		}
+		return filtered[i].Instance < filtered[j].Instance
+	})
+
+	return filtered
+}
+
+// describeOperation returns a one-line description of a scaling operation
+func describeOperation(op ScalingOperation) string {
+	description := fmt.Sprintf("%s → %s", op.CurrentType, op.TargetType)
+	if op.DowntimeExpected {
+		description += " [downtime]"
+	}
+	return description
+}
+
+// PrintCalendar writes calendar entries grouped by day, in the display time zone
+func PrintCalendar(w io.Writer, entries []CalendarEntry) {
+	if len(entries) == 0 {
+		fmt.Fprintln(w, "Nothing scheduled.")
+		return
+	}
+
+	var day string
+	for _, e := range entries {
+		start, end := config.InDisplayLocation(e.Time), config.InDisplayLocation(e.End)
+		if d := start.Format("Mon 2006-01-02") + " (" + config.DisplayLocation().String() + ")"; d != day {
+			if day != "" {
+				fmt.Fprintln(w)
+			}
+			day = d
+			fmt.Fprintln(w, day)
+		}
+
+		when := start.Format("15:04")
+		if !e.End.IsZero() {
+			if end.YearDay() == start.YearDay() && end.Year() == start.Year() {
+				when += "-" + end.Format("15:04")
+			} else {
+				when += "-" + end.Format("Jan 2 15:04")
+			}
+		}
+		line := fmt.Sprintf("  %-18s %-9s", when, e.Kind)
+		if e.Instance != "" {
+			line += " " + e.Instance + ":"
+		}
+		fmt.Fprintln(w, line+" "+e.Description)
+	}
+}
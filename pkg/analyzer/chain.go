@@ -0,0 +1,78 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// maxChainDepth bounds the walk from a replica up to its chain's primary, in
+// case replication metadata is inconsistent
+const maxChainDepth = 8
+
+// chainGuard serializes operations within a replication chain: a primary,
+// its replicas and theirs. Resizing an HA instance resizes the primary and
+// its standby in one operation, so serializing on the instance also covers
+// both HA halves.
+type chainGuard struct {
+	mu    sync.Mutex
+	locks map[string]chan struct{} // By chain root; holding the slot holds the chain
+}
+
+// newChainGuard creates a guard with no chains held
+func newChainGuard() *chainGuard {
+	return &chainGuard{locks: make(map[string]chan struct{})}
+}
+
+// slot returns the lock for the chain rooted at root
+func (g *chainGuard) slot(root string) chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	lock, ok := g.locks[root]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		g.locks[root] = lock
+	}
+	return lock
+}
+
+// chainRoot follows instanceName's primaries up to the primary at the root of
+// its replication chain
+func (a *Analyzer) chainRoot(ctx context.Context, instanceName string) (string, error) {
+	name := instanceName
+	for depth := 0; depth < maxChainDepth; depth++ {
+		instance, err := a.sqlClient.GetInstance(ctx, name)
+		if err != nil {
+			return "", fmt.Errorf("failed to read replication topology of %s: %w", name, err)
+		}
+		if instance.PrimaryInstance == "" {
+			return name, nil
+		}
+		name = instance.PrimaryInstance
+	}
+	return "", fmt.Errorf("replication chain of %s is deeper than %d instances", instanceName, maxChainDepth)
+}
+
+// lockChain waits until no other operation runs on instanceName's replication
+// chain and holds it until release is called
+func (a *Analyzer) lockChain(ctx context.Context, instanceName string) (release func(), err error) {
+	root, err := a.chainRoot(ctx, instanceName)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := a.chains.slot(root)
+	select {
+	case lock <- struct{}{}:
+		return func() { <-lock }, nil
+	default:
+	}
+
+	a.logf("Waiting for another operation on the replication chain of %s to finish before changing %s...\n", root, instanceName)
+	select {
+	case lock <- struct{}{}:
+		return func() { <-lock }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for other operations on the replication chain of %s: %w", root, ctx.Err())
+	}
+}
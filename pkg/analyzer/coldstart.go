@@ -0,0 +1,125 @@
+package analyzer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/rules"
+)
+
+// coldStartScaleDownConfidence is the confidence below which cold start holds
+// scale-downs: under half the metrics period, a quiet stretch is too likely
+// to be the lull of a longer cycle
+const coldStartScaleDownConfidence = 0.5
+
+// ColdStart describes an instance judged in cold start: on history shorter
+// than the metrics period. The window decisions rest on grows with the
+// history until it spans the whole period.
+type ColdStart struct {
+	History      time.Duration `json:"-"`
+	HistoryHours float64       `json:"history_hours"`
+	PeriodHours  float64       `json:"period_hours"`
+	Confidence   float64       `json:"confidence"` // History as a fraction of the metrics period
+	Ready        bool          `json:"ready"`      // History spans the cold start window, so decisions are made
+}
+
+// coldStart returns how far metrics fall short of the metrics period, or nil
+// when cold start is off or the history is complete. History runs from the
+// first data point to one interval past the last.
+func (a *Analyzer) coldStart(metrics *config.MetricsData) *ColdStart {
+	if !a.config.ColdStart {
+		return nil
+	}
+	var history time.Duration
+	if n := len(metrics.Timestamps); n > 0 {
+		history = metrics.Timestamps[n-1].Sub(metrics.Timestamps[0]) + a.config.MetricsInterval
+	}
+	if history >= a.config.MetricsPeriod {
+		return nil
+	}
+	return &ColdStart{
+		History:      history,
+		HistoryHours: history.Hours(),
+		PeriodHours:  a.config.MetricsPeriod.Hours(),
+		Confidence:   history.Hours() / a.config.MetricsPeriod.Hours(),
+		Ready:        history >= a.config.ColdStartWindow,
+	}
+}
+
+// applyColdStart judges the metric-driven decision by how much history backs
+// it when cold start is on:
+//   - until the history spans the cold start window nothing is decided
+//   - scale-downs are held while the history covers less than half the
+//     metrics period
+//   - other decisions stand, marked COLD_START with their confidence
+func (a *Analyzer) applyColdStart(instance *config.InstanceInfo, metrics *config.MetricsData, decision *cloudsql.ScalingDecision) (*cloudsql.ScalingDecision, *ColdStart) {
+	if instance.IsFailoverReplica {
+		return decision, nil
+	}
+	cs := a.coldStart(metrics)
+	if cs == nil {
+		return decision, nil
+	}
+
+	if !cs.Ready {
+		return &cloudsql.ScalingDecision{
+			CurrentType:     instance.MachineType,
+			RecommendedType: instance.MachineType,
+			Reason: fmt.Sprintf("Cold start: %s of metrics history, decisions start once it spans %s",
+				cs.History.Round(time.Minute), a.config.ColdStartWindow),
+			ReasonCodes: []cloudsql.ReasonCode{cloudsql.ReasonInsufficientData, cloudsql.ReasonColdStart},
+			Metrics:     decision.Metrics,
+		}, cs
+	}
+	// A resize in an unknown direction is held like a scale-down
+	if up, err := config.IsUpscale(decision.CurrentType, decision.RecommendedType); decision.ShouldScale && (err != nil || !up) &&
+		cs.Confidence < coldStartScaleDownConfidence {
+		return &cloudsql.ScalingDecision{
+			CurrentType:     instance.MachineType,
+			RecommendedType: instance.MachineType,
+			Reason: fmt.Sprintf("Scale-down to %s held: cold start with %s of the %s metrics period (%.0f%% confidence)",
+				decision.RecommendedType, cs.History.Round(time.Minute), a.config.MetricsPeriod, cs.Confidence*100),
+			ReasonCodes: append([]cloudsql.ReasonCode{cloudsql.ReasonColdStartHold}, decision.ReasonCodes...),
+			Metrics:     decision.Metrics,
+		}, cs
+	}
+	decision.Reason = fmt.Sprintf("%s [cold start: %.0fh of history, %.0f%% confidence]", decision.Reason, cs.HistoryHours, cs.Confidence*100)
+	decision.ReasonCodes = append(decision.ReasonCodes, cloudsql.ReasonColdStart)
+	return decision, cs
+}
+
+// coldStartWarnings replaces the limited_data warning, which measures data
+// against the whole metrics period, with a cold_start warning measuring it
+// against the history there is
+func (a *Analyzer) coldStartWarnings(warnings []rules.Warning, cs *ColdStart, summary *config.MetricsSummary) []rules.Warning {
+	kept := warnings[:0]
+	for _, w := range warnings {
+		if w.Code != rules.WarningLimitedData {
+			kept = append(kept, w)
+		}
+	}
+
+	completeness := 0.0
+	if expected := int(cs.History / a.config.MetricsInterval); expected > 0 {
+		completeness = float64(summary.DataPoints) / float64(expected) * 100
+	}
+	message := fmt.Sprintf("Cold start: only %.0fh of the %.0fh metrics period has history (%.0f%% confidence). Recommendations may change as it accumulates.",
+		cs.HistoryHours, cs.PeriodHours, cs.Confidence*100)
+	if completeness < 80 {
+		message += fmt.Sprintf(" That history is %.0f%% complete.", completeness)
+	}
+	return append(kept, rules.Warning{
+		Code:     rules.WarningColdStart,
+		Severity: rules.SeverityWarning,
+		Message:  message,
+		Data: map[string]interface{}{
+			"history_hours":    cs.HistoryHours,
+			"period_hours":     cs.PeriodHours,
+			"confidence":       cs.Confidence,
+			"completeness_pct": completeness,
+			"data_points":      summary.DataPoints,
+		},
+	})
+}
@@ -0,0 +1,128 @@
+package analyzer_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/analyzer"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/sandbox"
+)
+
+// TestConcurrentUse analyzes, plans, resizes and redirects progress from
+// many goroutines at once, as the daemon's cycle and API do, and then closes
+// the analyzer while calls are still arriving. Run it with -race to fail on
+// unsynchronized state.
+func TestConcurrentUse(t *testing.T) {
+	ctx := context.Background()
+	fake := sandbox.NewProject("concurrency", sandbox.DefaultFleetSize, 1)
+	fake.SetOperationDelay(0)
+	cfg := config.DefaultConfig()
+	cfg.ProjectID = fake.ProjectID()
+	cfg.MetricsPeriod = 6 * time.Hour
+	cfg.DryRun = false
+	cfg.Force = true
+
+	p, err := analyzer.NewProject(ctx, analyzer.Options{Config: cfg, SQLAdmin: fake, Metrics: fake, Progress: io.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	instances, _, err := fake.ListInstances(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	call := func(f func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := f(); err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	for round := 0; round < 3; round++ {
+		for _, inst := range instances {
+			call(func() error {
+				_, err := p.Analyze(ctx, inst.Name)
+				return err
+			})
+		}
+		call(func() error {
+			results, err := p.AnalyzeAllInstances(ctx)
+			if err != nil {
+				return err
+			}
+			p.PlanScaling(results)
+			return nil
+		})
+		call(func() error {
+			p.SetProgressOutput(io.Discard)
+			return nil
+		})
+	}
+	for _, inst := range instances {
+		// Replicas are resized with their primary
+		if inst.PrimaryInstance != "" {
+			continue
+		}
+		call(func() error { return stepAndBack(ctx, p.Analyzer, inst.Name) })
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// Calls racing Close either finish or are refused
+	var closing sync.WaitGroup
+	for _, inst := range instances {
+		closing.Add(1)
+		go func() {
+			defer closing.Done()
+			if _, err := p.Analyze(ctx, inst.Name); err != nil && !errors.Is(err, analyzer.ErrClosed) {
+				t.Errorf("analyze %s while closing: %v", inst.Name, err)
+			}
+		}()
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	closing.Wait()
+	if _, err := p.Analyze(ctx, instances[0].Name); !errors.Is(err, analyzer.ErrClosed) {
+		t.Fatalf("analyze after close returned %v, expected %v", err, analyzer.ErrClosed)
+	}
+}
+
+// stepAndBack resizes a sandbox instance one machine type up, or down when it
+// is the largest, and back again
+func stepAndBack(ctx context.Context, a *analyzer.Analyzer, name string) error {
+	instance, err := a.GetInstance(ctx, name)
+	if err != nil {
+		return err
+	}
+	original := instance.MachineType
+	target, err := config.GetNextLargerMachineType(original)
+	if err != nil {
+		if target, err = config.GetNextSmallerMachineType(original); err != nil {
+			return nil
+		}
+	}
+	for _, d := range []*cloudsql.ScalingDecision{
+		{ShouldScale: true, CurrentType: original, RecommendedType: target, Reason: "Concurrent resize"},
+		{ShouldScale: true, CurrentType: target, RecommendedType: original, Reason: "Concurrent revert"},
+	} {
+		if err := a.ApplyScaling(ctx, name, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/decisionhook"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/rules"
+)
+
+// DecisionHook has the final say over each proposed resize.
+// *decisionhook.Client implements it.
+type DecisionHook interface {
+	Review(ctx context.Context, req decisionhook.Request) (*decisionhook.Response, error)
+}
+
+// SetDecisionHook sets the hook that reviews proposed resizes; nil removes it
+func (a *Analyzer) SetDecisionHook(h DecisionHook) {
+	a.hook = h
+}
+
+// applyDecisionHook sends a proposed resize to the decision hook and applies
+// its verdict:
+//   - allow leaves the decision as it is
+//   - deny holds the instance at its machine type with DECISION_HOOK_DENIED,
+//     followed by the codes of the held resize
+//   - modify resizes to the hook's target instead, appending
+//     DECISION_HOOK_MODIFIED, with its downtime and savings estimated for
+//     it; a target that is not a known machine type, that reverses the
+//     direction of the resize, that is above the instance's max-tier label
+//     or that ValidateTarget rejects is a failure
+//
+// It runs before the connection capacity and target sizing checks, so they
+// judge the target the hook chose.
+//
+// When the hook fails the resize is held with DECISION_HOOK_UNAVAILABLE, or
+// left as it is with Config.DecisionHookFailOpen, and a decision_hook warning
+// reports why.
+func (a *Analyzer) applyDecisionHook(ctx context.Context, instance *config.InstanceInfo, decision *cloudsql.ScalingDecision) (*cloudsql.ScalingDecision, *rules.Warning) {
+	if a.hook == nil || !decision.ShouldScale {
+		return decision, nil
+	}
+
+	scaleUp, directionErr := config.IsUpscale(decision.CurrentType, decision.RecommendedType)
+	req := decisionhook.Request{
+		Project:          a.config.ProjectID,
+		Instance:         instance.Name,
+		Region:           instance.Region,
+		Edition:          instance.Edition,
+		DatabaseVersion:  instance.DatabaseVersion,
+		Labels:           instance.Labels,
+		CurrentType:      decision.CurrentType,
+		TargetType:       decision.RecommendedType,
+		ScaleUp:          scaleUp,
+		Reason:           decision.Reason,
+		ReasonCodes:      decision.ReasonCodes,
+		EstimatedSavings: decision.EstimatedSavings,
+		DowntimeExpected: decision.DowntimeExpected,
+		DryRun:           a.config.DryRun,
+	}
+	if owner := a.config.OwnerOf(instance); !owner.IsZero() {
+		req.Owner = &owner
+	}
+	if decision.Metrics != nil {
+		req.CPUP95 = decision.Metrics.CPUP95
+		req.MemoryP95Pct = decision.Metrics.MemoryP95Pct
+	}
+
+	// The hook is told the direction, so a resize whose direction is unknown
+	// fails like a hook that cannot be reached
+	var verdict *decisionhook.Response
+	err := directionErr
+	if err == nil {
+		verdict, err = a.hook.Review(ctx, req)
+	}
+	if err == nil && verdict.Action == decisionhook.ActionModify {
+		err = a.checkHookTarget(ctx, instance, decision, verdict.TargetType, scaleUp)
+	}
+	if err != nil {
+		warning := &rules.Warning{
+			Code:     rules.WarningDecisionHook,
+			Severity: rules.SeverityWarning,
+			Data:     map[string]interface{}{"error": err.Error(), "target_type": decision.RecommendedType},
+		}
+		if a.config.DecisionHookFailOpen {
+			warning.Message = fmt.Sprintf("Decision hook failed: %v; the resize to %s goes ahead unreviewed", err, decision.RecommendedType)
+			return decision, warning
+		}
+		warning.Severity = rules.SeverityCritical
+		warning.Message = fmt.Sprintf("Decision hook failed: %v; the resize to %s is held", err, decision.RecommendedType)
+		return heldByHook(instance, decision, cloudsql.ReasonDecisionHookUnavailable,
+			fmt.Sprintf("Resize to %s held: the decision hook could not review it", decision.RecommendedType)), warning
+	}
+
+	switch verdict.Action {
+	case decisionhook.ActionDeny:
+		reason := fmt.Sprintf("Resize to %s denied by the decision hook", decision.RecommendedType)
+		if verdict.Reason != "" {
+			reason += ": " + verdict.Reason
+		}
+		return heldByHook(instance, decision, cloudsql.ReasonDecisionHookDenied, reason), nil
+	case decisionhook.ActionModify:
+		if verdict.TargetType == decision.RecommendedType {
+			return decision, nil
+		}
+		note := fmt.Sprintf("target changed from %s to %s by the decision hook", decision.RecommendedType, verdict.TargetType)
+		if verdict.Reason != "" {
+			note += ": " + verdict.Reason
+		}
+		decision.Reason = fmt.Sprintf("%s [%s]", decision.Reason, note)
+		decision.ReasonCodes = append(decision.ReasonCodes, cloudsql.ReasonDecisionHookModified)
+		a.engineFor(instance).Retarget(instance, decision, verdict.TargetType, scaleUp)
+	}
+	return decision, nil
+}
+
+// checkHookTarget reports whether a modified target can replace decision's:
+// it must be a known machine type other than the current one, resizing in
+// the same direction, within the instance's max-tier label and valid for the
+// instance as ValidateTarget judges any other target
+func (a *Analyzer) checkHookTarget(ctx context.Context, instance *config.InstanceInfo, decision *cloudsql.ScalingDecision, target string, scaleUp bool) error {
+	up, err := config.IsUpscale(decision.CurrentType, target)
+	if err != nil {
+		return fmt.Errorf("decision hook modified the target to %s: %w", target, err)
+	}
+	if target == decision.CurrentType {
+		return fmt.Errorf("decision hook modified the target to the current machine type %s; deny the resize instead", target)
+	}
+	if up != scaleUp {
+		return fmt.Errorf("decision hook modified the target to %s, reversing the direction of the resize", target)
+	}
+	if scaleUp && rules.AboveMaxTier(instance, target) {
+		return fmt.Errorf("decision hook modified the target to %s, larger than %s, the most the %s label allows", target, instance.MaxTier, cloudsql.LabelMaxTier)
+	}
+	if err := a.ValidateTarget(ctx, instance, target); err != nil {
+		return fmt.Errorf("decision hook modified the target to %s: %w", target, err)
+	}
+	return nil
+}
+
+// heldByHook returns decision held at instance's machine type, leading with
+// code and followed by the codes of the held resize
+func heldByHook(instance *config.InstanceInfo, decision *cloudsql.ScalingDecision, code cloudsql.ReasonCode, reason string) *cloudsql.ScalingDecision {
+	return &cloudsql.ScalingDecision{
+		CurrentType:     instance.MachineType,
+		RecommendedType: instance.MachineType,
+		Reason:          reason,
+		ReasonCodes:     append([]cloudsql.ReasonCode{code}, decision.ReasonCodes...),
+		Metrics:         decision.Metrics,
+	}
+}
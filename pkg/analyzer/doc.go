@@ -0,0 +1,83 @@
+// Package analyzer is the recommendation engine behind cloudsql-autoscaler.
+// It reads a Cloud SQL instance and its Cloud Monitoring metrics, applies the
+// scaling rules and returns a decision; applying it is a separate, explicit
+// step.
+//
+// New, NewProject, Options, Analyze, AnalyzeAllInstances, ApplyScaling and
+// PlanScaling, along with the result types they return, form the stable API
+// for embedding the engine in other services. Fields are only ever added to
+// Options and the result types, never removed or repurposed.
+//
+// Analyze a single instance with the default Google API clients, using
+// Application Default Credentials:
+//
+//	cfg := config.DefaultConfig()
+//	cfg.ProjectID = "my-project"
+//
+//	a, err := analyzer.New(ctx, analyzer.Options{Config: cfg})
+//	if err != nil {
+//		return err
+//	}
+//	defer a.Close()
+//
+//	result, err := a.Analyze(ctx, "orders-db")
+//	if err != nil {
+//		return err
+//	}
+//	if result.Decision.ShouldScale {
+//		log.Printf("%s: %s → %s (%s)", result.Instance.Name,
+//			result.Decision.CurrentType, result.Decision.RecommendedType, result.Decision.Reason)
+//	}
+//
+// Inject clients to share credentials and connection pools with the
+// embedding service, or to substitute fakes in tests:
+//
+//	sqlClient, _ := cloudsql.NewClient(ctx, cfg.ProjectID, option.WithCredentialsFile(path))
+//	metricsClient, _ := cloudsql.NewMetricsClient(ctx, cfg.ProjectID, option.WithCredentialsFile(path))
+//
+//	p, err := analyzer.NewProject(ctx, analyzer.Options{
+//		Config:   cfg,
+//		SQLAdmin: sqlClient,
+//		Metrics:  metricsClient,
+//		Progress: os.Stderr,
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	fleet, err := p.AnalyzeAllInstances(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	plan := p.PlanScaling(fleet)
+//
+// Services analyzing many projects can share a ClientPool, which keeps one
+// client pair per project and closes pairs left idle:
+//
+//	pool := cloudsql.NewClientPool(cloudsql.DefaultPoolIdleTimeout, cfg.MonitoringQuotaPerMinute)
+//	defer pool.Close()
+//
+//	for _, project := range projects {
+//		projectCfg := *cfg
+//		projectCfg.ProjectID = project
+//		a, err := analyzer.New(ctx, analyzer.Options{Config: &projectCfg, Pool: pool})
+//		...
+//		a.Close() // Returns the clients to the pool
+//	}
+//
+// Analyzers built with New write nothing to stdout or stderr unless Progress
+// or AuditLogger are set.
+//
+// An Analyzer or ProjectAnalyzer is safe for concurrent use once built, so a
+// service's scheduled cycle and its API handlers can share one. Analyze,
+// AnalyzeAllInstances, PlanScaling, ApplyScaling and the other methods may
+// be called from several goroutines; the clients, caches and stores they
+// share synchronize internally. The Set methods configure the analyzer and
+// must be called before it is shared, except SetProgressOutput, which may be
+// called at any time. Close waits for the calls in flight to return, so
+// pooled clients are never returned while in use, and later calls fail with
+// ErrClosed.
+//
+// Operations on the same replication chain (a primary and its replicas, at
+// any depth) are run one at a time; callers of ApplyScaling wait for the
+// chain to be free.
+package analyzer
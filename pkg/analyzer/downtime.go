@@ -0,0 +1,191 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/rules"
+)
+
+// downtimeSection is the state store section recording the downtime each
+// instance spent on resizes this month and the observed downtime estimates
+// are calibrated with
+const downtimeSection = "downtime"
+
+// calibrationSamples is the number of recent resizes downtime estimates are
+// calibrated against
+const calibrationSamples = 20
+
+// downtimeLedger is the content of the downtime section
+type downtimeLedger struct {
+	Used    map[string]downtimeUse `json:"used,omitempty"` // By fully qualified instance ID
+	Samples []downtimeSample       `json:"samples,omitempty"`
+}
+
+// downtimeUse is the downtime an instance spent on resizes in a month
+type downtimeUse struct {
+	Month   string  `json:"month"` // 2006-01, UTC
+	Seconds float64 `json:"seconds"`
+}
+
+// downtimeSample is the estimated and observed downtime of an applied resize
+type downtimeSample struct {
+	EstimatedSeconds float64 `json:"estimated_seconds"`
+	ObservedSeconds  float64 `json:"observed_seconds"`
+}
+
+// DowntimeBudget is an instance's monthly downtime budget and how much of it
+// this month's resizes spent
+type DowntimeBudget struct {
+	BudgetSeconds    float64   `json:"budget_seconds"`
+	UsedSeconds      float64   `json:"used_seconds"`
+	RemainingSeconds float64   `json:"remaining_seconds"`
+	EstimatedSeconds float64   `json:"estimated_seconds,omitempty"` // Calibrated downtime of the recommended resize
+	FromLabel        bool      `json:"from_label,omitempty"`        // Set by the autoscaler-downtime-budget label rather than the default
+	RenewsAt         time.Time `json:"renews_at"`                   // Start of next month, UTC
+}
+
+// Exceeded reports whether the recommended resize would spend more downtime
+// than the budget has left
+func (b *DowntimeBudget) Exceeded() bool {
+	return b.EstimatedSeconds > b.RemainingSeconds
+}
+
+// downtimeMonth returns the month t falls in, as downtime use is recorded
+func downtimeMonth(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// nextMonth returns the start of the month after t's, UTC
+func nextMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// downtimeBudgetOf returns instance's monthly downtime budget: its label's
+// when that is valid, else Config.DowntimeBudget. It reports false when the
+// instance has no budget.
+func (a *Analyzer) downtimeBudgetOf(instance *config.InstanceInfo) (time.Duration, bool, bool) {
+	if instance.DowntimeBudget != "" {
+		if budget, err := rules.ParseDowntimeBudget(instance.DowntimeBudget); err == nil {
+			return budget, true, true
+		}
+	}
+	return a.config.DowntimeBudget, false, a.config.DowntimeBudget > 0
+}
+
+// readDowntime returns the downtime ledger, from the state store when the
+// analyzer has one and else as kept in memory
+func (a *Analyzer) readDowntime(ctx context.Context) downtimeLedger {
+	if a.state != nil {
+		var ledger downtimeLedger
+		_, err := a.state.Get(ctx, downtimeSection, &ledger)
+		if err == nil {
+			return ledger
+		}
+		a.logf("Warning: %v; downtime budgets use this process's record only\n", err)
+	}
+	a.downtimeMu.Lock()
+	defer a.downtimeMu.Unlock()
+	ledger := downtimeLedger{Used: make(map[string]downtimeUse, len(a.downtime.Used)), Samples: a.downtime.Samples}
+	for id, use := range a.downtime.Used {
+		ledger.Used[id] = use
+	}
+	return ledger
+}
+
+// calibratedDowntime estimates the downtime of resizing instance from
+// currentType to targetType: rules.EstimateDowntime, scaled by how the
+// downtime of recent resizes compared with their estimates
+func calibratedDowntime(ledger downtimeLedger, instance *config.InstanceInfo, currentType, targetType string) time.Duration {
+	estimate := rules.EstimateDowntime(instance, currentType, targetType)
+	var estimated, observed float64
+	for _, s := range ledger.Samples {
+		estimated += s.EstimatedSeconds
+		observed += s.ObservedSeconds
+	}
+	if estimated <= 0 || observed <= 0 {
+		return estimate
+	}
+	return time.Duration(float64(estimate) * observed / estimated)
+}
+
+// downtimeBudget returns instance's downtime budget at now, with the
+// calibrated downtime of decision's resize when it causes downtime, or nil
+// when the instance has no budget
+func (a *Analyzer) downtimeBudget(ctx context.Context, instance *config.InstanceInfo, decision *cloudsql.ScalingDecision, now time.Time) *DowntimeBudget {
+	budget, fromLabel, ok := a.downtimeBudgetOf(instance)
+	if !ok {
+		return nil
+	}
+	ledger := a.readDowntime(ctx)
+	b := &DowntimeBudget{BudgetSeconds: budget.Seconds(), FromLabel: fromLabel, RenewsAt: nextMonth(now)}
+	if use, ok := ledger.Used[instance.ID()]; ok && use.Month == downtimeMonth(now) {
+		b.UsedSeconds = math.Round(use.Seconds)
+	}
+	b.RemainingSeconds = max(b.BudgetSeconds-b.UsedSeconds, 0)
+	if decision.ShouldScale && decision.DowntimeExpected {
+		b.EstimatedSeconds = math.Round(calibratedDowntime(ledger, instance, decision.CurrentType, decision.RecommendedType).Seconds())
+	}
+	return b
+}
+
+// recordDowntime charges instance's downtime budget with the downtime of its
+// resize from decision's current to its recommended machine type, observed
+// or else estimated, and calibrates later estimates with observed downtime
+func (a *Analyzer) recordDowntime(ctx context.Context, instance *config.InstanceInfo, decision *cloudsql.ScalingDecision, observed time.Duration, at time.Time) {
+	if !decision.DowntimeExpected {
+		return
+	}
+	estimate := rules.EstimateDowntime(instance, decision.CurrentType, decision.RecommendedType)
+	update := func(ledger *downtimeLedger) {
+		spent := observed
+		if spent <= 0 {
+			spent = calibratedDowntime(*ledger, instance, decision.CurrentType, decision.RecommendedType)
+		} else if estimate > 0 {
+			ledger.Samples = append(ledger.Samples, downtimeSample{EstimatedSeconds: estimate.Seconds(), ObservedSeconds: observed.Seconds()})
+			ledger.Samples = ledger.Samples[max(len(ledger.Samples)-calibrationSamples, 0):]
+		}
+		if ledger.Used == nil {
+			ledger.Used = make(map[string]downtimeUse)
+		}
+		month := downtimeMonth(at)
+		for id, use := range ledger.Used {
+			if use.Month != month {
+				delete(ledger.Used, id)
+			}
+		}
+		use := ledger.Used[instance.ID()]
+		use.Month = month
+		use.Seconds += spent.Seconds()
+		ledger.Used[instance.ID()] = use
+	}
+
+	if a.state != nil {
+		var ledger downtimeLedger
+		err := a.state.Update(ctx, downtimeSection, &ledger, func() { update(&ledger) })
+		if err == nil {
+			return
+		}
+		a.logf("Warning: %v; the downtime of resizing %s will not survive a restart\n", err, instance.Name)
+	}
+	a.downtimeMu.Lock()
+	defer a.downtimeMu.Unlock()
+	update(&a.downtime)
+}
+
+// downtimeBudgetReason describes why a resize waits for its instance's
+// downtime budget to renew
+func downtimeBudgetReason(b *DowntimeBudget) string {
+	return fmt.Sprintf("Estimated downtime of %v exceeds the %v left of the instance's %v monthly downtime budget",
+		seconds(b.EstimatedSeconds), seconds(b.RemainingSeconds), seconds(b.BudgetSeconds))
+}
+
+// seconds converts a number of seconds to a duration rounded to the second
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second)).Round(time.Second)
+}
@@ -0,0 +1,68 @@
+package analyzer_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/analyzer"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/sandbox"
+)
+
+// The sandbox project stands in for the Cloud SQL Admin and Monitoring
+// clients; without them, New uses the Google API clients and Application
+// Default Credentials.
+func ExampleNew() {
+	ctx := context.Background()
+	fake := sandbox.NewProject("my-project", sandbox.DefaultFleetSize, 1)
+
+	cfg := config.DefaultConfig()
+	cfg.ProjectID = fake.ProjectID()
+	cfg.MetricsPeriod = 6 * time.Hour
+
+	a, err := analyzer.New(ctx, analyzer.Options{Config: cfg, SQLAdmin: fake, Metrics: fake})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer a.Close()
+
+	result, err := a.Analyze(ctx, "orders-db")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s runs %s (%s)\n", result.Instance.Name, result.Decision.CurrentType, result.Instance.Edition)
+	if result.Decision.ShouldScale {
+		log.Printf("recommended %s: %s", result.Decision.RecommendedType, result.Decision.Reason)
+	}
+	// Output: orders-db runs db-custom-4-16384 (ENTERPRISE)
+}
+
+func ExampleNewProject() {
+	ctx := context.Background()
+	fake := sandbox.NewProject("my-project", sandbox.DefaultFleetSize, 1)
+
+	cfg := config.DefaultConfig()
+	cfg.ProjectID = fake.ProjectID()
+	cfg.MetricsPeriod = 6 * time.Hour
+
+	p, err := analyzer.NewProject(ctx, analyzer.Options{Config: cfg, SQLAdmin: fake, Metrics: fake})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer p.Close()
+
+	fleet, err := p.AnalyzeAllInstances(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d of %d instances analyzed\n", fleet.AnalyzedInstances, fleet.TotalInstances)
+
+	// Decisions follow the sandbox's daily workload, so they vary by time of day
+	plan := p.PlanScaling(fleet)
+	for _, op := range plan.Operations {
+		log.Printf("%s: %s → %s (%s)", op.Instance, op.CurrentType, op.TargetType, op.Reason)
+	}
+	// Output: 8 of 8 instances analyzed
+}
@@ -0,0 +1,54 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+)
+
+// ExportMetrics collects the instances in the project and the metrics the
+// analysis would use for each, for offline analysis with a file:// metrics
+// source. Instances that would be skipped are exported without metrics so
+// the skip is reproduced; instances whose metrics cannot be read are left out.
+func (p *ProjectAnalyzer) ExportMetrics(ctx context.Context) (*cloudsql.MetricsDump, error) {
+	done, err := p.checkout()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	p.logf("Listing all Cloud SQL instances in the project...\n")
+	listed, _, err := p.sqlClient.ListInstances(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instance details: %w", err)
+	}
+
+	dump := &cloudsql.MetricsDump{
+		ProjectID:     p.config.ProjectID,
+		ExportedAt:    time.Now().UTC(),
+		MetricsPeriod: p.config.MetricsPeriod.String(),
+		Instances:     make([]cloudsql.DumpedInstance, 0, len(listed)),
+	}
+	for _, instance := range listed {
+		if _, skip := p.skipInstance(instance, dump.ExportedAt); skip {
+			dump.Instances = append(dump.Instances, cloudsql.DumpedInstance{Instance: instance})
+			continue
+		}
+
+		p.logf("Collecting metrics for %s...\n", instance.Name)
+		instance.LastScaledTime = p.lastScalingTime(ctx, instance)
+		metrics, err := p.metricsClient.GetInstanceMetrics(ctx, instance, p.config)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			p.logf("  Error collecting metrics for %s: %v\n", instance.Name, err)
+			continue
+		}
+		dump.Instances = append(dump.Instances, cloudsql.DumpedInstance{Instance: instance, Metrics: metrics})
+	}
+
+	return dump, nil
+}
@@ -0,0 +1,493 @@
+package analyzer
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// Filter selects analysis results with an expression such as
+//
+//	action = scale_down AND savings >= 100 AND (region = europe-west1 OR label.env = prod)
+//
+// Comparisons are FIELD OP VALUE with the operators = != < <= > >=, combined
+// with AND, OR (binding looser than AND), NOT and parentheses. Keywords are
+// case-insensitive. Text fields compare case-insensitively and treat * and ?
+// in the value as wildcards; list fields such as reason_codes match when any
+// element does. Values with spaces or operators are written in double quotes.
+type Filter struct {
+	root filterNode
+}
+
+// filterKind is the type of a filter field's values
+type filterKind int
+
+const (
+	filterText filterKind = iota
+	filterNumber
+	filterBool
+	filterList
+)
+
+// filterField reads one field of a result; exactly the function matching
+// kind is set
+type filterField struct {
+	kind   filterKind
+	text   func(r *AnalysisResult) string
+	number func(r *AnalysisResult) float64
+	flag   func(r *AnalysisResult) bool
+	list   func(r *AnalysisResult) []string
+}
+
+// filterFields are the fields filters can compare, besides label.KEY
+var filterFields = map[string]filterField{
+	"instance":          {kind: filterText, text: func(r *AnalysisResult) string { return r.Instance.Name }},
+	"instance_id":       {kind: filterText, text: func(r *AnalysisResult) string { return r.Instance.ID() }},
+	"project":           {kind: filterText, text: func(r *AnalysisResult) string { return r.Instance.Project }},
+	"region":            {kind: filterText, text: func(r *AnalysisResult) string { return r.Instance.Region }},
+	"zone":              {kind: filterText, text: func(r *AnalysisResult) string { return r.Instance.Zone }},
+	"engine":            {kind: filterText, text: func(r *AnalysisResult) string { return string(config.ParseEngine(r.Instance.DatabaseVersion)) }},
+	"database_version":  {kind: filterText, text: func(r *AnalysisResult) string { return r.Instance.DatabaseVersion }},
+	"edition":           {kind: filterText, text: func(r *AnalysisResult) string { return string(r.Instance.Edition) }},
+	"machine_type":      {kind: filterText, text: func(r *AnalysisResult) string { return r.Instance.MachineType }},
+	"recommended_type":  {kind: filterText, text: func(r *AnalysisResult) string { return decisionOf(r).RecommendedType }},
+	"action":            {kind: filterText, text: func(r *AnalysisResult) string { return resultAction(r) }},
+	"reason_code":       {kind: filterText, text: func(r *AnalysisResult) string { return string(decisionOf(r).ReasonCode()) }},
+	"team":              {kind: filterText, text: func(r *AnalysisResult) string { return r.Owner.Team }},
+	"contact":           {kind: filterText, text: func(r *AnalysisResult) string { return r.Owner.Contact }},
+	"cpu":               {kind: filterNumber, number: func(r *AnalysisResult) float64 { return float64(r.Instance.CurrentCPU) }},
+	"memory_gb":         {kind: filterNumber, number: func(r *AnalysisResult) float64 { return r.Instance.CurrentMemoryGB }},
+	"cpu_p95":           {kind: filterNumber, number: func(r *AnalysisResult) float64 { return summaryOf(r).CPUP95 }},
+	"memory_p95":        {kind: filterNumber, number: func(r *AnalysisResult) float64 { return summaryOf(r).MemoryP95Pct }},
+	"pressure":          {kind: filterNumber, number: func(r *AnalysisResult) float64 { return r.UtilizationPressure() }},
+	"savings":           {kind: filterNumber, number: savingsOf},
+	"priority":          {kind: filterNumber, number: func(r *AnalysisResult) float64 { return float64(r.Priority()) }},
+	"downtime":          {kind: filterBool, flag: func(r *AnalysisResult) bool { return decisionOf(r).DowntimeExpected }},
+	"high_availability": {kind: filterBool, flag: func(r *AnalysisResult) bool { return r.Instance.HighAvailability }},
+	"reason_codes": {kind: filterList, list: func(r *AnalysisResult) []string {
+		codes := make([]string, len(decisionOf(r).ReasonCodes))
+		for i, code := range decisionOf(r).ReasonCodes {
+			codes[i] = string(code)
+		}
+		return codes
+	}},
+	"warnings": {kind: filterList, list: func(r *AnalysisResult) []string {
+		codes := make([]string, len(r.Warnings))
+		for i, w := range r.Warnings {
+			codes[i] = string(w.Code)
+		}
+		return codes
+	}},
+}
+
+// FilterFields returns the names of the fields filters can compare
+func FilterFields() []string {
+	names := make([]string, 0, len(filterFields)+1)
+	for name := range filterFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append(names, "label.KEY")
+}
+
+// ParseFilter parses a filter expression; an empty expression matches every
+// result
+func ParseFilter(expr string) (*Filter, error) {
+	tokens, err := tokenizeFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return &Filter{}, nil
+	}
+	p := &filterParser{tokens: tokens}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("invalid filter: unexpected %s", p.tokens[p.pos])
+	}
+	return &Filter{root: root}, nil
+}
+
+// And returns a filter matching what both f and other match
+func (f *Filter) And(other *Filter) *Filter {
+	switch {
+	case f.root == nil:
+		return other
+	case other.root == nil:
+		return f
+	}
+	return &Filter{root: &filterBinary{op: "AND", left: f.root, right: other.root}}
+}
+
+// Match reports whether r satisfies the filter
+func (f *Filter) Match(r *AnalysisResult) bool {
+	return f.root == nil || f.root.match(r)
+}
+
+// String returns the filter in normalized form, with every AND and OR
+// parenthesized
+func (f *Filter) String() string {
+	if f.root == nil {
+		return ""
+	}
+	return f.root.String()
+}
+
+// FilterResults returns the results f matches, in order
+func FilterResults(results []*AnalysisResult, f *Filter) []*AnalysisResult {
+	matched := make([]*AnalysisResult, 0, len(results))
+	for _, r := range results {
+		if f.Match(r) {
+			matched = append(matched, r)
+		}
+	}
+	return matched
+}
+
+// filterNode is a parsed filter expression
+type filterNode interface {
+	match(r *AnalysisResult) bool
+	String() string
+}
+
+type filterBinary struct {
+	op          string // AND or OR
+	left, right filterNode
+}
+
+func (n *filterBinary) match(r *AnalysisResult) bool {
+	if n.op == "AND" {
+		return n.left.match(r) && n.right.match(r)
+	}
+	return n.left.match(r) || n.right.match(r)
+}
+
+func (n *filterBinary) String() string {
+	return "(" + n.left.String() + " " + n.op + " " + n.right.String() + ")"
+}
+
+type filterNot struct {
+	operand filterNode
+}
+
+func (n *filterNot) match(r *AnalysisResult) bool { return !n.operand.match(r) }
+func (n *filterNot) String() string               { return "NOT " + n.operand.String() }
+
+// filterComparison compares one field with a value
+type filterComparison struct {
+	name   string
+	field  filterField
+	label  string // Label key, for label.KEY
+	op     string
+	value  string
+	number float64 // value, for number fields
+	flag   bool    // value, for bool fields
+}
+
+func (c *filterComparison) match(r *AnalysisResult) bool {
+	switch c.field.kind {
+	case filterNumber:
+		v := c.field.number(r)
+		switch c.op {
+		case "=":
+			return v == c.number
+		case "!=":
+			return v != c.number
+		case "<":
+			return v < c.number
+		case "<=":
+			return v <= c.number
+		case ">":
+			return v > c.number
+		default:
+			return v >= c.number
+		}
+	case filterBool:
+		return (c.field.flag(r) == c.flag) == (c.op == "=")
+	case filterList:
+		found := false
+		for _, v := range c.field.list(r) {
+			if matchText(c.value, v) {
+				found = true
+				break
+			}
+		}
+		return found == (c.op == "=")
+	}
+	v := ""
+	if c.label != "" {
+		v = r.Instance.Labels[c.label]
+	} else {
+		v = c.field.text(r)
+	}
+	return matchText(c.value, v) == (c.op == "=")
+}
+
+func (c *filterComparison) String() string {
+	value := c.value
+	if value == "" || strings.ContainsFunc(value, func(r rune) bool { return !isFilterWordRune(r) }) {
+		value = strconv.Quote(value)
+	}
+	return c.name + " " + c.op + " " + value
+}
+
+// matchText compares text case-insensitively, with * and ? in pattern as
+// wildcards
+func matchText(pattern, text string) bool {
+	pattern, text = strings.ToLower(pattern), strings.ToLower(text)
+	if !strings.ContainsAny(pattern, "*?") {
+		return pattern == text
+	}
+	ok, _ := path.Match(pattern, text)
+	return ok
+}
+
+// filterToken is a word, quoted string, operator or parenthesis
+type filterToken struct {
+	text   string
+	quoted bool
+}
+
+func (t filterToken) String() string {
+	return strconv.Quote(t.text)
+}
+
+// keyword reports whether t is the unquoted keyword kw, in any case
+func (t filterToken) keyword(kw string) bool {
+	return !t.quoted && strings.EqualFold(t.text, kw)
+}
+
+// isFilterWordRune reports whether r may appear in an unquoted word
+func isFilterWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-.*?:/+", r)
+}
+
+// tokenizeFilter splits expr into tokens
+func tokenizeFilter(expr string) ([]filterToken, error) {
+	var tokens []filterToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, filterToken{text: string(c)})
+			i++
+		case c == '=' || c == '!' || c == '<' || c == '>':
+			op := string(c)
+			if i+1 < len(expr) && expr[i+1] == '=' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("invalid filter: unexpected \"!\" (use != or NOT)")
+			}
+			tokens = append(tokens, filterToken{text: op})
+			i += len(op)
+		case c == '"':
+			end := i + 1
+			for end < len(expr) && expr[end] != '"' {
+				if expr[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(expr) {
+				return nil, fmt.Errorf("invalid filter: unterminated string at offset %d", i)
+			}
+			text, err := strconv.Unquote(expr[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid filter: bad string at offset %d", i)
+			}
+			tokens = append(tokens, filterToken{text: text, quoted: true})
+			i = end + 1
+		default:
+			end := i
+			for end < len(expr) {
+				r := rune(expr[end])
+				if r >= 0x80 || !isFilterWordRune(r) {
+					break
+				}
+				end++
+			}
+			if end == i {
+				return nil, fmt.Errorf("invalid filter: unexpected %q at offset %d", c, i)
+			}
+			tokens = append(tokens, filterToken{text: expr[i:end]})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+// filterParser parses tokens by recursive descent
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+// peek returns the next token, or false at the end
+func (p *filterParser) peek() (filterToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return filterToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+// or parses and-expressions separated by OR
+func (p *filterParser) or() (filterNode, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, ok := p.peek()
+		if !ok || !t.keyword("OR") {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &filterBinary{op: "OR", left: left, right: right}
+	}
+}
+
+// and parses unary expressions separated by AND
+func (p *filterParser) and() (filterNode, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, ok := p.peek()
+		if !ok || !t.keyword("AND") {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = &filterBinary{op: "AND", left: left, right: right}
+	}
+}
+
+// unary parses NOT, a parenthesized expression or a comparison
+func (p *filterParser) unary() (filterNode, error) {
+	t, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("invalid filter: unexpected end of expression")
+	}
+	switch {
+	case t.keyword("NOT"):
+		p.pos++
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &filterNot{operand: operand}, nil
+	case !t.quoted && t.text == "(":
+		p.pos++
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t, ok := p.peek(); !ok || t.quoted || t.text != ")" {
+			return nil, fmt.Errorf("invalid filter: missing )")
+		}
+		p.pos++
+		return inner, nil
+	}
+	return p.comparison()
+}
+
+// comparison parses FIELD OP VALUE and checks the operator and value suit
+// the field
+func (p *filterParser) comparison() (filterNode, error) {
+	if p.pos+3 > len(p.tokens) {
+		return nil, fmt.Errorf("invalid filter: expected FIELD OP VALUE")
+	}
+	name, op, value := p.tokens[p.pos], p.tokens[p.pos+1], p.tokens[p.pos+2]
+	p.pos += 3
+
+	c := &filterComparison{name: strings.ToLower(name.text), op: op.text, value: value.text}
+	switch op.text {
+	case "=", "!=", "<", "<=", ">", ">=":
+	default:
+		return nil, fmt.Errorf("invalid filter: expected an operator after %s, got %s", name, op)
+	}
+	if op.quoted {
+		return nil, fmt.Errorf("invalid filter: expected an operator after %s, got %s", name, op)
+	}
+	if !value.quoted && strings.ContainsAny(value.text, "()=!<>") {
+		return nil, fmt.Errorf("invalid filter: expected a value after %s %s, got %s", name.text, op.text, value)
+	}
+
+	if key, ok := strings.CutPrefix(c.name, "label."); ok && key != "" && !name.quoted {
+		c.label = key
+		c.name = "label." + key
+		c.field = filterField{kind: filterText}
+	} else if field, ok := filterFields[c.name]; ok && !name.quoted {
+		c.field = field
+	} else {
+		return nil, fmt.Errorf("invalid filter: unknown field %s (must be one of %s)", name, strings.Join(FilterFields(), ", "))
+	}
+
+	ordering := op.text != "=" && op.text != "!="
+	switch c.field.kind {
+	case filterNumber:
+		n, err := strconv.ParseFloat(value.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter: %s compares numbers, not %s", c.name, value)
+		}
+		c.number = n
+	case filterBool:
+		b, err := strconv.ParseBool(value.text)
+		if err != nil || ordering {
+			return nil, fmt.Errorf("invalid filter: %s takes = or != with true or false", c.name)
+		}
+		c.flag = b
+	default:
+		if ordering {
+			return nil, fmt.Errorf("invalid filter: %s takes = or !=, not %s", c.name, op.text)
+		}
+		if _, err := path.Match(strings.ToLower(value.text), ""); err != nil {
+			return nil, fmt.Errorf("invalid filter: bad pattern %s", value)
+		}
+	}
+	return c, nil
+}
+
+// decisionOf returns r's decision, or an empty one when analysis made none
+func decisionOf(r *AnalysisResult) *cloudsql.ScalingDecision {
+	if r.Decision == nil {
+		return &cloudsql.ScalingDecision{}
+	}
+	return r.Decision
+}
+
+// summaryOf returns r's metrics summary, or an empty one when there is none
+func summaryOf(r *AnalysisResult) *config.MetricsSummary {
+	if r.Summary == nil {
+		return &config.MetricsSummary{}
+	}
+	return r.Summary
+}
+
+// resultAction returns what r recommends, as one of the Action values
+func resultAction(r *AnalysisResult) string {
+	if r.Decision == nil {
+		return ActionNone
+	}
+	return DecisionAction(r.Decision)
+}
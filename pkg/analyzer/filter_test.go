@@ -0,0 +1,162 @@
+package analyzer_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/analyzer"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// TestParseFilter checks that expressions parse into the normalized form
+// String gives, with AND binding tighter than OR and keywords in any case
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"", ""},
+		{"region = europe-west1", "region = europe-west1"},
+		{"REGION = europe-west1", "region = europe-west1"},
+		{"action = scale_down and savings >= 100", "(action = scale_down AND savings >= 100)"},
+		{"region = us-east1 OR region = us-west1 AND cpu > 4", "(region = us-east1 OR (region = us-west1 AND cpu > 4))"},
+		{"(region = us-east1 OR region = us-west1) AND cpu > 4", "((region = us-east1 OR region = us-west1) AND cpu > 4)"},
+		{"NOT downtime = true", "NOT downtime = true"},
+		{"label.env = prod", "label.env = prod"},
+		{`instance = "orders db"`, `instance = "orders db"`},
+		{"reason_codes = CPU_*", "reason_codes = CPU_*"},
+		{`team = ""`, `team = ""`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			f, err := analyzer.ParseFilter(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseFilter(%q): %v", tt.expr, err)
+			}
+			if got := f.String(); got != tt.want {
+				t.Errorf("ParseFilter(%q) = %q, want %q", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseFilterErrors checks that malformed expressions are rejected with
+// an error naming the problem
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string // Substring of the error
+	}{
+		{"a = 1", `unknown field "a"`},
+		{"region", "expected FIELD OP VALUE"},
+		{"region ~ us-east1", "unexpected '~'"},
+		{`region "=" us-east1`, "expected an operator"},
+		{"region = (", "expected a value"},
+		{"(region = us-east1", "missing )"},
+		{"region = us-east1 region = us-west1", "unexpected"},
+		{"cpu > many", "compares numbers"},
+		{"downtime = maybe", "takes = or != with true or false"},
+		{"downtime < true", "takes = or != with true or false"},
+		{"region > us", "takes = or !=, not >"},
+		{`instance = "["`, "bad pattern"},
+		{`"region" = us-east1`, "unknown field"},
+		{`region = "us-east1`, "invalid filter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := analyzer.ParseFilter(tt.expr)
+			if err == nil {
+				t.Fatalf("ParseFilter(%q) succeeded, want an error containing %q", tt.expr, tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseFilter(%q) = %v, want an error containing %q", tt.expr, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestFilterMatch checks what parsed filters select
+func TestFilterMatch(t *testing.T) {
+	result := &analyzer.AnalysisResult{
+		Instance: &config.InstanceInfo{
+			Name:        "Orders-DB",
+			Project:     "shop",
+			Region:      "europe-west1",
+			MachineType: "db-custom-4-16384",
+			CurrentCPU:  4,
+			Labels:      map[string]string{"env": "prod"},
+		},
+		Decision: &cloudsql.ScalingDecision{
+			ShouldScale:      true,
+			CurrentType:      "db-custom-4-16384",
+			RecommendedType:  "db-custom-2-8192",
+			EstimatedSavings: 150,
+			ReasonCodes:      []cloudsql.ReasonCode{cloudsql.ReasonCPUP95Low},
+		},
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"", true},
+		{"instance = orders-db", true},
+		{"instance = orders-*", true},
+		{"instance = ?rders-db", true},
+		{"instance != orders-db", false},
+		{"region = europe-west1 AND label.env = prod", true},
+		{"region = us-east1 OR label.env = prod", true},
+		{"region = us-east1 OR label.env = dev", false},
+		{"label.team = orders", false},
+		{"label.team != orders", true},
+		{"action = scale_down", true},
+		{"action = scale_up", false},
+		{"savings >= 150", true},
+		{"savings > 150", false},
+		{"cpu = 4 AND cpu <= 4 AND cpu < 5", true},
+		{"downtime = false", true},
+		{"downtime != false", false},
+		{"NOT downtime = true", true},
+		{"NOT (savings > 100 AND cpu = 4)", false},
+		{"reason_codes = CPU_*", true},
+		{"reason_codes != CPU_*", false},
+		{"warnings = *", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			f, err := analyzer.ParseFilter(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseFilter(%q): %v", tt.expr, err)
+			}
+			if got := f.Match(result); got != tt.want {
+				t.Errorf("%q matched = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFilterAnd checks that And combines filters and treats an empty filter
+// as matching everything
+func TestFilterAnd(t *testing.T) {
+	empty, _ := analyzer.ParseFilter("")
+	region, _ := analyzer.ParseFilter("region = europe-west1")
+	cpu, _ := analyzer.ParseFilter("cpu > 2")
+
+	tests := []struct {
+		name string
+		f    *analyzer.Filter
+		want string
+	}{
+		{"empty and filter", empty.And(region), "region = europe-west1"},
+		{"filter and empty", region.And(empty), "region = europe-west1"},
+		{"filter and filter", region.And(cpu), "(region = europe-west1 AND cpu > 2)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.f.String(); got != tt.want {
+				t.Errorf("And = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/rules"
+)
+
+// FleetSource supplies the recommendations and insights Google's fleet
+// tooling holds for an instance. *cloudsql.FleetClient implements it.
+type FleetSource interface {
+	FleetSignals(ctx context.Context, instance *config.InstanceInfo) ([]cloudsql.FleetSignal, error)
+}
+
+// applyFleet merges instance's fleet signals with the metric-driven decision,
+// so one report carries both:
+//   - a recommendation resizing the instance the way the decision does
+//     corroborates it with FLEET_AGREES
+//   - any other recommendation or insight becomes a fleet_signal warning
+//
+// Fleet signals never change the recommended machine type: Recommender judges
+// on its own thresholds and schedule, which the autoscaler's configuration
+// does not reach. Failures to fetch them leave the decision as it is.
+func (a *Analyzer) applyFleet(ctx context.Context, instance *config.InstanceInfo, decision *cloudsql.ScalingDecision) ([]cloudsql.FleetSignal, []rules.Warning) {
+	if a.fleet == nil {
+		return nil, nil
+	}
+	signals, err := a.fleet.FleetSignals(ctx, instance)
+	if err != nil {
+		a.logf("Warning: %v; analyzing without fleet signals\n", err)
+		return nil, nil
+	}
+
+	var warnings []rules.Warning
+	agreed := false
+	decisionUp, decisionErr := config.IsUpscale(instance.MachineType, decision.RecommendedType)
+	for _, s := range signals {
+		resize := s.TargetTier != "" && s.TargetTier != instance.MachineType
+		signalUp, signalErr := config.IsUpscale(instance.MachineType, s.TargetTier)
+		if resize && decision.ShouldScale && !agreed && decisionErr == nil && signalErr == nil && signalUp == decisionUp {
+			decision.Reason = fmt.Sprintf("%s [Recommender also recommends %s]", decision.Reason, s.TargetTier)
+			decision.ReasonCodes = append(decision.ReasonCodes, cloudsql.ReasonFleetAgrees)
+			agreed = true
+			continue
+		}
+
+		w := rules.Warning{
+			Code:     rules.WarningFleetSignal,
+			Severity: fleetSeverity(s),
+			Message:  fmt.Sprintf("Recommender %s: %s", s.Kind, s.Description),
+			Data: map[string]interface{}{
+				"kind":     s.Kind,
+				"type":     s.Type,
+				"subtype":  s.Subtype,
+				"priority": s.Priority,
+				"name":     s.Name,
+			},
+		}
+		if resize {
+			w.Severity = rules.SeverityWarning
+			w.Message = fmt.Sprintf("Recommender recommends resizing to %s, which the metrics do not support: %s", s.TargetTier, s.Description)
+			w.Data["target_tier"] = s.TargetTier
+		}
+		warnings = append(warnings, w)
+	}
+	return signals, warnings
+}
+
+// fleetSeverity ranks a fleet signal: the two highest recommendation
+// priorities and insight severities need attention, the rest are context
+func fleetSeverity(s cloudsql.FleetSignal) rules.Severity {
+	switch s.Priority {
+	case "P1", "P2", "CRITICAL", "HIGH":
+		return rules.SeverityWarning
+	}
+	return rules.SeverityInfo
+}
@@ -0,0 +1,259 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/rules"
+)
+
+// season is the period of the seasonal component forecasts model; database
+// load mostly follows the working day
+const season = 24 * time.Hour
+
+// minForecastSeasons is how many full seasons of history a forecast needs,
+// so the daily pattern is seen more than once
+const minForecastSeasons = 2
+
+// Forecast is an instance's projected peak CPU and memory utilization over
+// the forecast horizon on its current machine type
+type Forecast struct {
+	Horizon       time.Duration `json:"-"`
+	HorizonHours  float64       `json:"horizon_hours"`
+	CPUPeakPct    float64       `json:"cpu_peak_pct"`
+	CPUPeakAt     time.Time     `json:"cpu_peak_at"`
+	MemoryPeakPct float64       `json:"memory_peak_pct"`
+	MemoryPeakAt  time.Time     `json:"memory_peak_at"`
+}
+
+// ForecastUtilization projects CPU and memory utilization horizon past the
+// end of data. Each series is decomposed into a linear trend over hourly
+// means, a daily seasonal profile of what the trend leaves, and the P95 of
+// the remaining noise, so projections track the P95 readings thresholds are
+// judged on. memory is the memory series to project; without one the memory
+// peak is zero. It returns false when data covers fewer than two days.
+func ForecastUtilization(data *config.MetricsData, memory []float64, horizon time.Duration) (*Forecast, bool) {
+	if horizon <= 0 || len(data.Timestamps) == 0 {
+		return nil, false
+	}
+	cpu, cpuAt, ok := forecastPeak(data.Timestamps, data.CPUUtilization, horizon)
+	if !ok {
+		return nil, false
+	}
+	mem, memAt, _ := forecastPeak(data.Timestamps, memory, horizon)
+	return &Forecast{
+		Horizon:       horizon,
+		HorizonHours:  horizon.Hours(),
+		CPUPeakPct:    cpu,
+		CPUPeakAt:     cpuAt,
+		MemoryPeakPct: mem,
+		MemoryPeakAt:  memAt,
+	}, true
+}
+
+// forecastPeak returns the highest projected value of a percentage series
+// within horizon of its last reading, and when it occurs. Zero values are
+// gaps in the aligned series and are ignored.
+func forecastPeak(timestamps []time.Time, values []float64, horizon time.Duration) (float64, time.Time, bool) {
+	if len(values) != len(timestamps) {
+		return 0, time.Time{}, false
+	}
+	start := timestamps[0].Truncate(time.Hour)
+	end := timestamps[len(timestamps)-1]
+	if end.Sub(start) < minForecastSeasons*season {
+		return 0, time.Time{}, false
+	}
+
+	// Trend: least squares over hourly means, so dense and sparse stretches
+	// of the series weigh the same
+	hours := int(end.Sub(start).Hours()) + 1
+	sums := make([]float64, hours)
+	counts := make([]int, hours)
+	for i, ts := range timestamps {
+		if values[i] == 0 {
+			continue
+		}
+		h := int(ts.Sub(start).Hours())
+		sums[h] += values[i]
+		counts[h]++
+	}
+	var xs, ys []float64
+	for h := range sums {
+		if counts[h] > 0 {
+			xs = append(xs, float64(h)+0.5)
+			ys = append(ys, sums[h]/float64(counts[h]))
+		}
+	}
+	intercept, slope, ok := fitLine(xs, ys)
+	if !ok {
+		return 0, time.Time{}, false
+	}
+	trend := func(t time.Time) float64 {
+		return intercept + slope*t.Sub(start).Hours()
+	}
+
+	// Season: the mean each hour of the day sits above or below the trend
+	slots := int(season / time.Hour)
+	profile := make([]float64, slots)
+	profileCounts := make([]int, slots)
+	for i, x := range xs {
+		slot := int(x) % slots
+		profile[slot] += ys[i] - (intercept + slope*x)
+		profileCounts[slot]++
+	}
+	for slot := range profile {
+		if profileCounts[slot] > 0 {
+			profile[slot] /= float64(profileCounts[slot])
+		}
+	}
+	seasonal := func(t time.Time) float64 {
+		return profile[int(t.Sub(start).Hours())%slots]
+	}
+
+	// Noise: how far individual readings rise above the model
+	var residuals []float64
+	for i, ts := range timestamps {
+		if values[i] != 0 {
+			residuals = append(residuals, values[i]-trend(ts)-seasonal(ts))
+		}
+	}
+	sort.Float64s(residuals)
+	noise := math.Max(0, residuals[int(float64(len(residuals)-1)*0.95)])
+
+	var peak float64
+	var peakAt time.Time
+	for t := end.Truncate(time.Hour).Add(time.Hour); !t.After(end.Add(horizon)); t = t.Add(time.Hour) {
+		v := math.Min(100, math.Max(0, trend(t)+seasonal(t)+noise))
+		if peakAt.IsZero() || v > peak {
+			peak, peakAt = v, t
+		}
+	}
+	if peakAt.IsZero() {
+		return 0, time.Time{}, false
+	}
+	return peak, peakAt, true
+}
+
+// fitLine fits y = a + bx by least squares and returns a and b
+func fitLine(xs, ys []float64) (a, b float64, ok bool) {
+	n := float64(len(xs))
+	if len(xs) < 2 {
+		return 0, 0, false
+	}
+	var sumX, sumY, sumXY, sumXX float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+		sumXY += xs[i] * ys[i]
+		sumXX += xs[i] * xs[i]
+	}
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0, 0, false
+	}
+	b = (n*sumXY - sumX*sumY) / denom
+	return (sumY - b*sumX) / n, b, true
+}
+
+// forecastMemory returns the memory series forecasts project: usage outside
+// the page cache when the engine's memory pressure mode judges that and the
+// instance reports it, total utilization otherwise
+func (a *Analyzer) forecastMemory(instance *config.InstanceInfo, metrics *config.MetricsData) []float64 {
+	if a.config.MemoryPressureFor(instance.DatabaseVersion) == config.MemoryPressureNonCache &&
+		len(metrics.MemoryNonCachePercent) == len(metrics.Timestamps) {
+		for _, v := range metrics.MemoryNonCachePercent {
+			if v > 0 {
+				return metrics.MemoryNonCachePercent
+			}
+		}
+	}
+	return metrics.MemoryPercent
+}
+
+// applyForecast merges a forecast of instance's utilization over the
+// forecast horizon with the metric-driven decision when predictive scaling
+// is on:
+//   - an instance within target whose forecast peak crosses the scale-up
+//     threshold is scaled up now, ahead of the crossing
+//   - a scale-down is held back when the forecast peak, projected onto the
+//     smaller machine type, would cross the threshold there
+//
+// Scale-ups stand as they are. Memory counts only when it would count
+// toward a scale-up today under the engine's memory pressure mode.
+func (a *Analyzer) applyForecast(instance *config.InstanceInfo, metrics *config.MetricsData, summary *config.MetricsSummary, decision *cloudsql.ScalingDecision) (*cloudsql.ScalingDecision, *Forecast) {
+	if !a.config.Predictive || instance.IsFailoverReplica || instance.UnsupportedTier {
+		return decision, nil
+	}
+	forecast, ok := ForecastUtilization(metrics, a.forecastMemory(instance, metrics), a.config.ForecastHorizon)
+	if !ok {
+		return decision, nil
+	}
+	if decision.ShouldScale {
+		if up, err := config.IsUpscale(decision.CurrentType, decision.RecommendedType); err != nil || up {
+			return decision, forecast
+		}
+	}
+
+	target := instance.MachineType
+	if decision.ShouldScale {
+		target = decision.RecommendedType
+	}
+	breach, ok := a.forecastBreach(instance, summary, forecast, target)
+	if !ok {
+		return decision, forecast
+	}
+
+	if decision.ShouldScale {
+		return &cloudsql.ScalingDecision{
+			CurrentType:     instance.MachineType,
+			RecommendedType: instance.MachineType,
+			Reason:          fmt.Sprintf("Scale-down to %s held: %s", target, breach),
+			ReasonCodes:     append([]cloudsql.ReasonCode{cloudsql.ReasonForecastHold}, decision.ReasonCodes...),
+			Metrics:         decision.Metrics,
+		}, forecast
+	}
+	if decision.ReasonCode() != cloudsql.ReasonWithinTarget {
+		return decision, forecast
+	}
+	if scaleUp := a.engineFor(instance).ForecastScaleUp(instance, summary, breach); scaleUp != nil {
+		return scaleUp, forecast
+	}
+	return decision, forecast
+}
+
+// forecastBreach describes the forecast crossing of the scale-up threshold
+// on machineType, if there is one. Forecast peaks on the current machine type
+// are scaled by the ratio of its CPUs and memory to machineType's.
+func (a *Analyzer) forecastBreach(instance *config.InstanceInfo, summary *config.MetricsSummary, forecast *Forecast, machineType string) (string, bool) {
+	current, err := config.GetMachineType(instance.MachineType)
+	if err != nil {
+		return "", false
+	}
+	target, err := config.GetMachineType(machineType)
+	if err != nil || target.CPU == 0 || target.MemoryGB == 0 {
+		return "", false
+	}
+	threshold := a.engineFor(instance).ScaleUpThreshold(instance) * 100
+	on := ""
+	if machineType != instance.MachineType {
+		on = " on " + machineType
+	}
+
+	cpu := forecast.CPUPeakPct * float64(current.CPU) / float64(target.CPU)
+	if cpu >= threshold {
+		return fmt.Sprintf("CPU is forecast to peak at %.1f%%%s at %s, past the %.0f%% scale-up threshold",
+			cpu, on, config.FormatTime(forecast.CPUPeakAt), threshold), true
+	}
+
+	memory := forecast.MemoryPeakPct * current.MemoryGB / target.MemoryGB
+	if memory >= threshold && rules.MemoryPressureCorroborated(instance, summary, a.config) &&
+		!rules.DataCacheAbsorbsMemoryPressure(instance, summary, a.config) {
+		return fmt.Sprintf("memory is forecast to peak at %.1f%%%s at %s, past the %.0f%% scale-up threshold",
+			memory, on, config.FormatTime(forecast.MemoryPeakAt), threshold), true
+	}
+	return "", false
+}
@@ -0,0 +1,193 @@
+package analyzer
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// GroupBy selects how a project summary rolls instances up
+type GroupBy string
+
+const (
+	GroupByTeam   GroupBy = "team"   // Owning team, from configured ownership or the team label
+	GroupByRegion GroupBy = "region" // Instance region
+	GroupByEnv    GroupBy = "env"    // The env user label
+
+	// groupByLabelPrefix groups by an arbitrary user label, e.g. label:cost-center
+	groupByLabelPrefix = "label:"
+)
+
+// labelEnv is the user label instances are grouped by with GroupByEnv
+const labelEnv = "env"
+
+// ungrouped names the group of instances without a value for the grouping
+const ungrouped = "(none)"
+
+// ParseGroupBy validates a grouping: team, region, env or label:<key>
+func ParseGroupBy(s string) (GroupBy, error) {
+	switch by := GroupBy(strings.ToLower(s)); {
+	case by == GroupByTeam, by == GroupByRegion, by == GroupByEnv:
+		return by, nil
+	case strings.HasPrefix(string(by), groupByLabelPrefix) && len(by) > len(groupByLabelPrefix):
+		// Label keys are lowercase, so lowering the whole value is safe
+		return by, nil
+	default:
+		return "", fmt.Errorf("invalid grouping %q (must be team, region, env or label:<key>)", s)
+	}
+}
+
+// key returns the group result belongs to
+func (by GroupBy) key(result *AnalysisResult) string {
+	var key string
+	switch {
+	case by == GroupByTeam:
+		key = result.Owner.Team
+	case by == GroupByRegion:
+		key = result.Instance.Region
+	case by == GroupByEnv:
+		key = result.Instance.Labels[labelEnv]
+	default:
+		key = result.Instance.Labels[strings.TrimPrefix(string(by), groupByLabelPrefix)]
+	}
+	if key == "" {
+		return ungrouped
+	}
+	return key
+}
+
+// Distribution describes how a utilization percentage is spread across the
+// instances of a group
+type Distribution struct {
+	Min    float64 `json:"min"`
+	Median float64 `json:"median"`
+	P90    float64 `json:"p90"`
+	Max    float64 `json:"max"`
+}
+
+// newDistribution summarizes values, which must not be empty
+func newDistribution(values []float64) Distribution {
+	d := Distribution{
+		Median: cloudsql.Percentile(values, 50),
+		P90:    cloudsql.Percentile(values, 90),
+		Min:    values[0],
+		Max:    values[0],
+	}
+	for _, v := range values[1:] {
+		if v < d.Min {
+			d.Min = v
+		}
+		if v > d.Max {
+			d.Max = v
+		}
+	}
+	return d
+}
+
+// GroupSummary rolls up the analyzed instances of one group
+type GroupSummary struct {
+	Group            string       `json:"group"`
+	Instances        int          `json:"instances"`
+	NeedScaling      int          `json:"need_scaling"`
+	ScaleUp          int          `json:"scale_up"`
+	ScaleDown        int          `json:"scale_down"`
+	DowntimeExpected int          `json:"downtime_expected"`
+	TotalSavings     float64      `json:"total_estimated_monthly_savings"`
+	CPUP95           Distribution `json:"cpu_p95"`        // Spread of the instances' P95 CPU utilization
+	MemoryP95Pct     Distribution `json:"memory_p95_pct"` // Spread of the instances' P95 memory utilization
+}
+
+// Groups rolls the analyzed instances up by the given grouping. Groups are
+// ordered by estimated savings, highest first, so the most over-provisioned
+// come first; instances without a value for the grouping form the "(none)"
+// group.
+func (p *ProjectAnalysisResult) Groups(by GroupBy) []GroupSummary {
+	byKey := make(map[string]*GroupSummary)
+	cpu := make(map[string][]float64)
+	memory := make(map[string][]float64)
+
+	for _, result := range p.Results {
+		if result.Decision == nil {
+			continue
+		}
+		key := by.key(result)
+		g, ok := byKey[key]
+		if !ok {
+			g = &GroupSummary{Group: key}
+			byKey[key] = g
+		}
+		g.Instances++
+		if result.Summary != nil {
+			cpu[key] = append(cpu[key], result.Summary.CPUP95)
+			memory[key] = append(memory[key], result.Summary.MemoryP95Pct)
+		}
+		if !result.Decision.ShouldScale {
+			continue
+		}
+		g.NeedScaling++
+		switch DecisionAction(result.Decision) {
+		case ActionScaleUp:
+			g.ScaleUp++
+		case ActionScaleDown:
+			g.ScaleDown++
+		}
+		if result.Decision.DowntimeExpected {
+			g.DowntimeExpected++
+		}
+		g.TotalSavings += result.Decision.EstimatedSavings
+	}
+
+	groups := make([]GroupSummary, 0, len(byKey))
+	for key, g := range byKey {
+		if len(cpu[key]) > 0 {
+			g.CPUP95 = newDistribution(cpu[key])
+			g.MemoryP95Pct = newDistribution(memory[key])
+		}
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].TotalSavings != groups[j].TotalSavings {
+			return groups[i].TotalSavings > groups[j].TotalSavings
+		}
+		return groups[i].Group < groups[j].Group
+	})
+	return groups
+}
+
+// printGroups writes the group rollups as a table
+func printGroups(w io.Writer, by GroupBy, groups []GroupSummary, currency config.Currency) {
+	header := strings.TrimPrefix(string(by), groupByLabelPrefix)
+	header = strings.ToUpper(header[:1]) + header[1:]
+	rows := [][]string{{header, "Instances", "Up", "Down", "CPU P95 (median/max)", "Memory P95 (median/max)", "Savings/month"}}
+	for _, g := range groups {
+		rows = append(rows, []string{
+			g.Group,
+			fmt.Sprint(g.Instances),
+			fmt.Sprint(g.ScaleUp),
+			fmt.Sprint(g.ScaleDown),
+			fmt.Sprintf("%.1f%% / %.1f%%", g.CPUP95.Median, g.CPUP95.Max),
+			fmt.Sprintf("%.1f%% / %.1f%%", g.MemoryP95Pct.Median, g.MemoryP95Pct.Max),
+			currency.Format(g.TotalSavings),
+		})
+	}
+
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			if n := len([]rune(cell)); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = cell + strings.Repeat(" ", widths[i]-len([]rune(cell)))
+		}
+		fmt.Fprintf(w, "  %s\n", strings.TrimRight(strings.Join(cells, "  "), " "))
+	}
+}
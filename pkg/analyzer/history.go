@@ -0,0 +1,72 @@
+package analyzer
+
+import (
+	"context"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/state"
+)
+
+// scalingsSection is the state store section recording the last resize the
+// autoscaler applied to each instance, by its fully qualified ID. Records
+// written by earlier versions are by instance name.
+const scalingsSection = "scalings"
+
+// scalingRecord is the last machine type change applied to an instance
+type scalingRecord struct {
+	At         time.Time `json:"at"`
+	FromTier   string    `json:"from_tier"`
+	ToTier     string    `json:"to_tier"`
+	DecisionID string    `json:"decision_id,omitempty"`
+}
+
+// SetStateStore sets where the time each instance was last scaled is
+// persisted; nil reads it from the instance's operation list. Downtime
+// budget use and the sampling rotation are kept there as well.
+func (a *Analyzer) SetStateStore(s *state.Store) {
+	a.state = s
+}
+
+// lastScalingTime returns when the instance was last scaled: as recorded in
+// the state store when it has a record of the instance, and otherwise from
+// its operation list. It is zero when neither knows.
+func (a *Analyzer) lastScalingTime(ctx context.Context, instance *config.InstanceInfo) time.Time {
+	if a.state != nil {
+		var scalings map[string]scalingRecord
+		if _, err := a.state.Get(ctx, scalingsSection, &scalings); err != nil {
+			a.logf("Warning: %v; reading when %s was last scaled from its operations\n", err, instance.Name)
+		} else if rec, ok := scalings[instance.ID()]; ok {
+			return rec.At
+		} else if rec, ok := scalings[instance.Name]; ok {
+			return rec.At
+		}
+	}
+	at, _ := a.sqlClient.GetLastScalingTime(ctx, instance.Name)
+	return at
+}
+
+// recordScaling records in the state store that op resized its instance, so
+// its cooldown survives a restart
+func (a *Analyzer) recordScaling(ctx context.Context, op PendingOperation) {
+	if a.state == nil {
+		return
+	}
+	key := op.InstanceID
+	if key == "" {
+		key = op.Instance // Journaled by an earlier version
+	}
+	scalings := make(map[string]scalingRecord)
+	err := a.state.Update(ctx, scalingsSection, &scalings, func() {
+		delete(scalings, op.Instance)
+		scalings[key] = scalingRecord{
+			At:         op.StartedAt,
+			FromTier:   op.FromTier,
+			ToTier:     op.Decision.RecommendedType,
+			DecisionID: op.Decision.ID,
+		}
+	})
+	if err != nil {
+		a.logf("Warning: %v; when %s was scaled will not survive a restart\n", err, op.Instance)
+	}
+}
@@ -0,0 +1,45 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+)
+
+// decisionKey returns decision's idempotency key, computing it for a
+// decision made at now if it has none yet
+func (a *Analyzer) decisionKey(instanceName string, decision *cloudsql.ScalingDecision, now time.Time) string {
+	if decision.Key == "" {
+		decision.Key = cloudsql.DecisionKey(instanceName, decision, now, a.config.IdempotencyWindow)
+	}
+	return decision.Key
+}
+
+// appliedBefore reports why the change with idempotency key must not run:
+// an operation journaled with the key is still in flight, or the instance's
+// labels show the key was the last change applied to it. Retried cycles,
+// restarted daemons and repeated requests therefore never repeat a change.
+func (a *Analyzer) appliedBefore(ctx context.Context, instanceName, key string) (string, bool, error) {
+	if a.journal != nil {
+		pending, err := a.journal.Pending()
+		if err != nil {
+			a.logf("Warning: %v; checking labels only for an earlier apply of %s\n", err, key)
+		}
+		for _, op := range pending {
+			if op.Instance == instanceName && op.Decision != nil && op.Decision.Key == key {
+				return fmt.Sprintf("operation %s with decision key %s is still in flight", op.Operation, key), true, nil
+			}
+		}
+	}
+
+	instance, err := a.sqlClient.GetInstance(ctx, instanceName)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to check for an earlier apply: %w", err)
+	}
+	if instance.Labels[cloudsql.LabelDecisionKey] == key {
+		return fmt.Sprintf("decision key %s was already applied (decision %s)", key, instance.Labels[cloudsql.LabelDecisionID]), true, nil
+	}
+	return "", false, nil
+}
@@ -0,0 +1,292 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/audit"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// PendingOperation is a machine type change that was started but whose
+// outcome has not yet been confirmed
+type PendingOperation struct {
+	Operation  string                      `json:"operation"`
+	Instance   string                      `json:"instance"`
+	InstanceID string                      `json:"instance_id,omitempty"` // Fully qualified, see config.InstanceID
+	Primary    string                      `json:"primary,omitempty"`     // Set when resizing a replica for parity
+	FromTier   string                      `json:"from_tier"`
+	Decision   *cloudsql.ScalingDecision   `json:"decision"`
+	Before     *cloudsql.PreservedSettings `json:"before,omitempty"` // Settings to verify after the change
+	StartedAt  time.Time                   `json:"started_at"`
+}
+
+// OperationJournal persists in-flight operations so a restarted process can
+// resume waiting for and verifying them
+type OperationJournal interface {
+	Begin(op PendingOperation) error
+	Complete(operation string) error
+	Pending() ([]PendingOperation, error)
+}
+
+// FileJournal is an OperationJournal kept in a JSON file. Writes replace the
+// file atomically so a crash never leaves it truncated.
+type FileJournal struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileJournal creates a journal stored at path. The file is created on the
+// first Begin.
+func NewFileJournal(path string) *FileJournal {
+	return &FileJournal{path: path}
+}
+
+// Begin records op as in flight
+func (j *FileJournal) Begin(op PendingOperation) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	ops, err := j.read()
+	if err != nil {
+		return err
+	}
+	return j.write(append(ops, op))
+}
+
+// Complete removes the named operation from the journal
+func (j *FileJournal) Complete(operation string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	ops, err := j.read()
+	if err != nil {
+		return err
+	}
+	kept := ops[:0]
+	for _, op := range ops {
+		if op.Operation != operation {
+			kept = append(kept, op)
+		}
+	}
+	return j.write(kept)
+}
+
+// Pending returns the operations still in flight, oldest first
+func (j *FileJournal) Pending() ([]PendingOperation, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.read()
+}
+
+func (j *FileJournal) read() ([]PendingOperation, error) {
+	data, err := os.ReadFile(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read operation journal: %w", err)
+	}
+
+	var ops []PendingOperation
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return nil, fmt.Errorf("failed to parse operation journal %s: %w", j.path, err)
+	}
+	return ops, nil
+}
+
+func (j *FileJournal) write(ops []PendingOperation) error {
+	data, err := json.MarshalIndent(ops, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode operation journal: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(j.path), filepath.Base(j.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write operation journal: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write operation journal: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write operation journal: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write operation journal: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), j.path); err != nil {
+		return fmt.Errorf("failed to write operation journal: %w", err)
+	}
+	return nil
+}
+
+// SetOperationJournal sets where in-flight operations are persisted; nil
+// disables persistence
+func (a *Analyzer) SetOperationJournal(j OperationJournal) {
+	a.journal = j
+}
+
+// startResize starts a machine type change, journals it and waits for it to
+// complete. The journal entry is removed only once the outcome is known; the
+// caller completes it after any verification.
+func (a *Analyzer) startResize(ctx context.Context, op PendingOperation, labels map[string]string) (string, error) {
+	opName, err := a.sqlClient.StartMachineTypeUpdate(ctx, op.Instance, op.Decision.RecommendedType, labels)
+	if err != nil {
+		return "", err
+	}
+
+	op.Operation = opName
+	op.StartedAt = time.Now()
+	if a.journal != nil {
+		if err := a.journal.Begin(op); err != nil {
+			a.logf("Warning: %v; operation %s will not be resumed after a restart\n", err, opName)
+		}
+	}
+
+	if err := a.sqlClient.WaitForOperation(ctx, opName); err != nil {
+		if errors.Is(err, cloudsql.ErrOperationFailed) {
+			a.completeOperation(opName)
+		}
+		return opName, fmt.Errorf("machine type update operation failed: %w", err)
+	}
+	a.recordScaling(ctx, op)
+	return opName, nil
+}
+
+// completeOperation removes an operation whose outcome is known from the journal
+func (a *Analyzer) completeOperation(opName string) {
+	if a.journal == nil {
+		return
+	}
+	if err := a.journal.Complete(opName); err != nil {
+		a.logf("Warning: %v\n", err)
+	}
+}
+
+// ResumeOperations waits for and verifies operations journaled by a previous
+// process that exited before they completed. Operations whose status still
+// cannot be read are kept for the next attempt and reported in the error.
+func (a *Analyzer) ResumeOperations(ctx context.Context) error {
+	done, err := a.checkout()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if a.journal == nil {
+		return nil
+	}
+	pending, err := a.journal.Pending()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, op := range pending {
+		err := a.resumeOperation(ctx, op)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// resumeOperation waits for and verifies a journaled operation, holding its
+// replication chain meanwhile so no new operation starts alongside it. It
+// returns an error if the operation's status cannot be read.
+func (a *Analyzer) resumeOperation(ctx context.Context, op PendingOperation) error {
+	release, err := a.lockChain(ctx, op.Instance)
+	if err != nil {
+		return fmt.Errorf("operation %s on %s: %w", op.Operation, op.Instance, err)
+	}
+	defer release()
+
+	a.logf("Resuming operation %s on %s (%s → %s, started %s)\n", op.Operation, op.Instance,
+		op.FromTier, op.Decision.RecommendedType, config.FormatTime(op.StartedAt))
+
+	rec := audit.Record{
+		DecisionID: op.Decision.ID,
+		Project:    a.config.ProjectID,
+		Instance:   op.Instance,
+		InstanceID: op.InstanceID,
+		FromTier:   op.FromTier,
+		ToTier:     op.Decision.RecommendedType,
+		Operation:  op.Operation,
+		Reason:     op.Decision.Reason,
+		ReasonCode: string(op.Decision.ReasonCode()),
+	}
+	a.attribute(&rec)
+
+	err = a.sqlClient.WaitForOperation(ctx, op.Operation)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil && !errors.Is(err, cloudsql.ErrOperationFailed) {
+		return fmt.Errorf("operation %s on %s: %w", op.Operation, op.Instance, err)
+	}
+	if err != nil {
+		rec.Event = audit.EventScalingFailed
+		rec.Error = err.Error()
+		a.auditLog.Log(fmt.Sprintf("Resumed operation failed to scale instance %s to %s", op.Instance, op.Decision.RecommendedType), rec)
+		a.completeOperation(op.Operation)
+		return nil
+	}
+
+	a.recordScaling(ctx, op)
+	if op.Decision.DowntimeExpected {
+		// How long the operation ran is unknown, so the estimate is charged
+		if instance, err := a.sqlClient.GetInstance(ctx, op.Instance); err == nil {
+			a.recordDowntime(ctx, instance, op.Decision, 0, time.Now())
+		}
+	}
+	rec.Event = audit.EventOperationResumed
+	a.auditLog.Log(fmt.Sprintf("Resumed operation scaled instance %s to %s", op.Instance, op.Decision.RecommendedType), rec)
+
+	// Replica resizes are verified through their primary's decision
+	verified := true
+	if op.Primary == "" {
+		if err := a.verifyScaling(ctx, op.Instance, op.Decision, op.Before); err != nil {
+			rec.Event = audit.EventVerificationFailed
+			rec.Error = err.Error()
+			a.auditLog.Log(fmt.Sprintf("Verification failed for instance %s after scaling to %s", op.Instance, op.Decision.RecommendedType), rec)
+			verified = false
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	a.completeOperation(op.Operation)
+
+	if op.Primary == "" && verified {
+		return a.resumeReplicaParity(ctx, op)
+	}
+	return nil
+}
+
+// resumeReplicaParity brings a resumed primary's failover/DR replicas to its
+// new tier as Resize would have, had the process not exited mid-operation.
+// Replicas Resize grew before the primary are already at the target and are
+// left alone; a scale-down's replicas are shrunk now that the primary has
+// proven healthy.
+func (a *Analyzer) resumeReplicaParity(ctx context.Context, op PendingOperation) error {
+	instance, err := a.sqlClient.GetInstance(ctx, op.Instance)
+	if err != nil {
+		return fmt.Errorf("failed to read resumed instance %s for replica parity: %w", op.Instance, err)
+	}
+	return a.resizeReplicas(ctx, op.Instance, a.parityReplicas(instance), op.Decision)
+}
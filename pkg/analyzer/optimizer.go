@@ -0,0 +1,396 @@
+package analyzer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/rules"
+)
+
+// PlanScaling builds the fleet-optimized scaling plan for results
+func (a *Analyzer) PlanScaling(results *ProjectAnalysisResult) *ScalingPlan {
+	return results.GenerateScalingPlan().Optimize(a.config, a.Now())
+}
+
+// PlanInstance builds the scaling plan for a single result, for callers that
+// analyze instances one at a time. Cooldowns, intervals, blackouts and
+// freezes still defer the operation.
+func (a *Analyzer) PlanInstance(result *AnalysisResult) *ScalingPlan {
+	return a.PlanScaling(&ProjectAnalysisResult{ProjectID: a.config.ProjectID, Results: []*AnalysisResult{result}})
+}
+
+// lastScaled returns when the operation's instance was last scaled, if known
+func (op ScalingOperation) lastScaled() time.Time {
+	if op.Result == nil || op.Result.Instance == nil {
+		return time.Time{}
+	}
+	return op.Result.Instance.LastScaledTime
+}
+
+// downtimeBudget returns the downtime budget of the operation's instance, or
+// nil if it has none
+func (op ScalingOperation) downtimeBudget() *DowntimeBudget {
+	if op.Result == nil {
+		return nil
+	}
+	return op.Result.Downtime
+}
+
+// estimatedDowntime returns how long the operation is expected to take its
+// instance down: the calibrated estimate when the instance has a downtime
+// budget, else rules.EstimateDowntime
+func (op ScalingOperation) estimatedDowntime() time.Duration {
+	if op.Result == nil || op.Result.Instance == nil {
+		return 0
+	}
+	if b := op.Result.Downtime; b != nil && b.EstimatedSeconds > 0 {
+		return time.Duration(b.EstimatedSeconds * float64(time.Second))
+	}
+	return rules.EstimateDowntime(op.Result.Instance, op.CurrentType, op.TargetType)
+}
+
+// DeferKind classifies why an operation was deferred
+type DeferKind string
+
+const (
+	DeferCooldown       DeferKind = "cooldown"          // Instance is within its post-scaling cooldown
+	DeferInterval       DeferKind = "interval"          // Waiting for the minimum interval avoids downtime
+	DeferBlackout       DeferKind = "blackout"          // Operation would start inside a blackout window
+	DeferFreeze         DeferKind = "freeze"            // Instance is covered by a scaling freeze
+	DeferBundled        DeferKind = "bundled"           // Waiting for the shared downtime window
+	DeferMaintenance    DeferKind = "maintenance_night" // Waiting for the weekly maintenance night
+	DeferOperationLimit DeferKind = "operation_limit"   // Cycle operation limit reached
+	DeferCostCap        DeferKind = "cost_cap"          // Cycle cost increase cap reached
+	DeferInvalidTarget  DeferKind = "invalid_target"    // Target machine type failed validation
+	DeferRevertReview   DeferKind = "revert_review"     // Reverts are recommended only and await an operator
+	DeferApproval       DeferKind = "approval"          // Awaiting an operator's approval
+	DeferUnstable       DeferKind = "unstable"          // Recommendation not yet made enough consecutive cycles
+	DeferDowntimeBudget DeferKind = "downtime_budget"   // Instance's monthly downtime budget cannot cover the resize
+
+	// Waiting for the instance's own Cloud SQL maintenance window
+	DeferMaintenanceWindow DeferKind = "maintenance_window"
+)
+
+// deferReasonCodes maps each kind of deferral to its reason code
+var deferReasonCodes = map[DeferKind]cloudsql.ReasonCode{
+	DeferCooldown:       cloudsql.ReasonCooldownActive,
+	DeferInterval:       cloudsql.ReasonIntervalPending,
+	DeferBlackout:       cloudsql.ReasonBlackoutActive,
+	DeferFreeze:         cloudsql.ReasonFreezeActive,
+	DeferBundled:        cloudsql.ReasonDowntimeBundled,
+	DeferMaintenance:    cloudsql.ReasonMaintenanceWait,
+	DeferOperationLimit: cloudsql.ReasonOperationLimit,
+	DeferCostCap:        cloudsql.ReasonCostCapReached,
+	DeferInvalidTarget:  cloudsql.ReasonInvalidTarget,
+	DeferRevertReview:   cloudsql.ReasonRevertReview,
+	DeferApproval:       cloudsql.ReasonApprovalPending,
+	DeferUnstable:       cloudsql.ReasonUnstable,
+	DeferDowntimeBudget: cloudsql.ReasonDowntimeBudget,
+
+	DeferMaintenanceWindow: cloudsql.ReasonMaintenanceWindow,
+}
+
+// ReasonCode returns the machine-readable reason code for the deferral
+func (k DeferKind) ReasonCode() cloudsql.ReasonCode {
+	return deferReasonCodes[k]
+}
+
+// DeferredOperation is a scaling operation the fleet optimizer postponed.
+// NotBefore is when it becomes eligible again; zero means the next cycle.
+type DeferredOperation struct {
+	ScalingOperation
+	DeferKind   DeferKind           `json:"defer_kind"`
+	DeferCode   cloudsql.ReasonCode `json:"defer_code"` // Reason code for DeferKind
+	DeferReason string              `json:"defer_reason"`
+	NotBefore   time.Time           `json:"not_before,omitempty"`
+	Freeze      *config.Freeze      `json:"freeze,omitempty"` // Set when deferred by a scaling freeze
+}
+
+// Optimize makes fleet-wide trade-offs over a scaling plan instead of deciding
+// each instance in isolation. Operations are considered in priority order:
+//   - operations whose target machine type failed validation are deferred
+//     until a later analysis recommends a valid one
+//   - reverts of reactive scale-ups are deferred for an operator unless
+//     RevertApply is set
+//   - operations on instances still within their cooldown (see
+//     Config.CoolDownFor) of their last scaling are deferred until the
+//     cooldown ends, unless Force is set, except emergency scale-ups and
+//     rollbacks of regressed scale-downs
+//   - operations that would cause downtime only because the Enterprise Plus
+//     minimum interval has not passed are deferred until it has, unless Force is set
+//   - downtime-causing operations whose calibrated downtime exceeds what is
+//     left of their instance's monthly downtime budget are deferred until it
+//     renews, except emergency scale-ups and rollbacks
+//   - scale-ups that would push the cycle's net monthly cost increase past
+//     CycleCostIncreaseCap are deferred to a later cycle
+//   - at most MaxOperationsPerCycle operations run; the rest are deferred
+//   - when MaintenanceWindows is set, downtime-causing operations are deferred
+//     until their instance's Cloud SQL maintenance window starts
+//   - when a MaintenanceNight is set, other downtime-causing operations are
+//     deferred until it starts and run during it in priority order
+//   - emergency scale-ups and rollbacks wait for neither kind of window
+//   - otherwise, when BundleDowntimeOperations is set, all downtime-causing
+//     operations share the window of the highest-priority one and are
+//     deferred until it opens
+//   - an operation whose estimated downtime would run past the end of its
+//     window is deferred to the next one
+//   - operations that would start inside a blackout window are deferred until
+//     the blackout ends
+//   - operations on instances covered by a scaling freeze are deferred until
+//     the freeze expires, except emergency scale-ups
+func (p *ScalingPlan) Optimize(cfg *config.Config, now time.Time) *ScalingPlan {
+	optimized := &ScalingPlan{Deferred: append([]DeferredOperation(nil), p.Deferred...), ReplicaChanges: p.ReplicaChanges}
+
+	var night *rules.ScalingWindow
+	if cfg.MaintenanceNight != nil {
+		w := cfg.MaintenanceNight.Window(now)
+		night = &rules.ScalingWindow{Start: w.Start, End: w.End, Duration: w.End.Sub(w.Start)}
+	}
+
+	var sharedWindow *rules.ScalingWindow
+	if cfg.BundleDowntimeOperations && night == nil && !cfg.MaintenanceWindows {
+		for _, op := range p.Operations {
+			if op.DowntimeExpected && op.Window != nil {
+				sharedWindow = op.Window
+				break
+			}
+		}
+	}
+
+	costIncrease := 0.0
+	for _, op := range p.Operations {
+		if op.Result != nil && op.Result.TargetError != nil {
+			optimized.postpone(op, DeferInvalidTarget, fmt.Sprintf("Target machine type failed validation: %v", op.Result.TargetError),
+				time.Time{})
+			continue
+		}
+		if op.Result != nil && !cfg.RevertApply && op.Result.Decision.ReasonCode() == cloudsql.ReasonScaleUpRevert {
+			optimized.postpone(op, DeferRevertReview, "Scale-up revert is recommended only; apply it manually or enable --revert-apply",
+				time.Time{})
+			continue
+		}
+		if last, cooldown := op.lastScaled(), cfg.CoolDownFor(op.Instance); cooldown > 0 && !last.IsZero() && now.Before(last.Add(cooldown)) &&
+			!cfg.Force && !emergency(op, cfg.FreezeEmergencyThreshold) && !rollback(op) {
+			optimized.postpone(op, DeferCooldown, fmt.Sprintf("Cooldown after scaling at %s", config.FormatTime(last)),
+				last.Add(cooldown))
+			continue
+		}
+		if op.Result != nil && !cfg.Force && op.Result.Decision.DowntimeFreeAt.After(now) {
+			optimized.postpone(op, DeferInterval, "Waiting for the minimum interval since the last operation to scale without downtime",
+				op.Result.Decision.DowntimeFreeAt)
+			continue
+		}
+		if b := op.downtimeBudget(); b != nil && op.DowntimeExpected && b.Exceeded() &&
+			!emergency(op, cfg.FreezeEmergencyThreshold) && !rollback(op) {
+			optimized.postpone(op, DeferDowntimeBudget, downtimeBudgetReason(b), b.RenewsAt)
+			continue
+		}
+
+		if op.DowntimeExpected && !emergency(op, cfg.FreezeEmergencyThreshold) && !rollback(op) {
+			if window := instanceMaintenanceWindow(op, cfg, now); window != nil {
+				if !fitsWindow(op, window, now) {
+					window = instanceMaintenanceWindow(op, cfg, window.End)
+				}
+				op.Window = window
+				if now.Before(window.Start) {
+					optimized.postpone(op, DeferMaintenanceWindow, fmt.Sprintf("Held for the instance's maintenance window starting %s",
+						config.FormatTime(window.Start)), window.Start)
+					continue
+				}
+			} else if night != nil {
+				window := night
+				if !fitsWindow(op, window, now) {
+					w := cfg.MaintenanceNight.Window(night.End)
+					window = &rules.ScalingWindow{Start: w.Start, End: w.End, Duration: w.End.Sub(w.Start)}
+				}
+				op.Window = window
+				if now.Before(window.Start) {
+					optimized.postpone(op, DeferMaintenance, fmt.Sprintf("Held for the maintenance night starting %s",
+						config.FormatTime(window.Start)), window.Start)
+					continue
+				}
+			}
+		}
+
+		if sharedWindow != nil && op.DowntimeExpected {
+			window := sharedWindow
+			if !fitsWindow(op, window, now) {
+				// The shared window is a low-usage hour of the day, so it recurs daily
+				window = &rules.ScalingWindow{Start: window.Start.AddDate(0, 0, 1), End: window.End.AddDate(0, 0, 1), Duration: window.Duration}
+			}
+			op.Window = window
+			if now.Before(window.Start) {
+				optimized.postpone(op, DeferBundled, fmt.Sprintf("Bundled into shared downtime window starting %s",
+					config.FormatTime(window.Start)), window.Start)
+				continue
+			}
+		}
+
+		start := now
+		if op.Window != nil && op.Window.Start.After(now) {
+			start = op.Window.Start
+		}
+		if blackout, ok := cfg.ActiveBlackout(start); ok {
+			reason := fmt.Sprintf("Blackout window until %s", config.FormatTime(blackout.End))
+			if blackout.Reason != "" {
+				reason += ": " + blackout.Reason
+			}
+			optimized.postpone(op, DeferBlackout, reason, blackout.End)
+			continue
+		}
+		if freeze, ok := frozen(op, cfg.Freezes, cfg.ProjectID, cfg.FreezeEmergencyThreshold, start); ok {
+			optimized.postponeFrozen(op, freeze)
+			continue
+		}
+
+		if cfg.MaxOperationsPerCycle > 0 && len(optimized.Operations) >= cfg.MaxOperationsPerCycle {
+			optimized.postpone(op, DeferOperationLimit, fmt.Sprintf("Cycle operation limit of %d reached", cfg.MaxOperationsPerCycle), time.Time{})
+			continue
+		}
+
+		increase := -op.EstimatedSavings
+		if cfg.CycleCostIncreaseCap > 0 && increase > 0 && costIncrease+increase > cfg.CycleCostIncreaseCap {
+			optimized.postpone(op, DeferCostCap, fmt.Sprintf("Cycle cost increase cap of %s/month reached (%s already committed)",
+				cfg.Currency.Format(cfg.CycleCostIncreaseCap), cfg.Currency.Format(costIncrease)), time.Time{})
+			continue
+		}
+
+		costIncrease += increase
+		optimized.Operations = append(optimized.Operations, op)
+	}
+
+	return optimized
+}
+
+// ApplyFreezes defers the plan's operations on instances covered by freezes,
+// such as freezes set on a running daemon after the plan was optimized.
+// Scale-ups of instances at or above emergencyThreshold percent P95 CPU or
+// memory utilization are left in place.
+func (p *ScalingPlan) ApplyFreezes(freezes []config.Freeze, projectID string, emergencyThreshold float64, now time.Time) *ScalingPlan {
+	applied := &ScalingPlan{Deferred: append([]DeferredOperation(nil), p.Deferred...), ReplicaChanges: p.ReplicaChanges}
+	for _, op := range p.Operations {
+		if freeze, ok := frozen(op, freezes, projectID, emergencyThreshold, now); ok {
+			applied.postponeFrozen(op, freeze)
+			continue
+		}
+		applied.Operations = append(applied.Operations, op)
+	}
+	return applied
+}
+
+// AwaitApproval returns a copy of the plan in which the operations approved
+// does not approve are deferred for the reason it gives
+func (p *ScalingPlan) AwaitApproval(approved func(op ScalingOperation) (reason string, ok bool)) *ScalingPlan {
+	applied := &ScalingPlan{Deferred: append([]DeferredOperation(nil), p.Deferred...), ReplicaChanges: p.ReplicaChanges}
+	for _, op := range p.Operations {
+		if reason, ok := approved(op); !ok {
+			applied.postpone(op, DeferApproval, reason, time.Time{})
+			continue
+		}
+		applied.Operations = append(applied.Operations, op)
+	}
+	return applied
+}
+
+// frozen returns the freeze blocking op at t. Emergency scale-ups are never
+// blocked.
+func frozen(op ScalingOperation, freezes []config.Freeze, projectID string, emergencyThreshold float64, t time.Time) (config.Freeze, bool) {
+	if op.Result == nil {
+		return config.Freeze{}, false
+	}
+	freeze, ok := config.ActiveFreeze(freezes, projectID, op.Result.Instance, t)
+	if !ok {
+		return config.Freeze{}, false
+	}
+	if emergency(op, emergencyThreshold) {
+		return config.Freeze{}, false
+	}
+	return freeze, true
+}
+
+// instanceMaintenanceWindow returns the Cloud SQL maintenance window of op's
+// instance in progress at now, or else its next one, when MaintenanceWindows
+// is set. It returns nil when the instance has no maintenance window.
+func instanceMaintenanceWindow(op ScalingOperation, cfg *config.Config, now time.Time) *rules.ScalingWindow {
+	if !cfg.MaintenanceWindows || op.Result == nil || op.Result.Instance.MaintenanceWindow == nil {
+		return nil
+	}
+	w := op.Result.Instance.MaintenanceWindow.Window(now)
+	return &rules.ScalingWindow{Start: w.Start, End: w.End, Duration: w.End.Sub(w.Start)}
+}
+
+// fitsWindow reports whether op's estimated downtime, starting at the later of
+// now and window's start, ends by window's end. Downtime longer than the whole
+// window would never fit, so it only has to start inside the window.
+func fitsWindow(op ScalingOperation, window *rules.ScalingWindow, now time.Time) bool {
+	downtime := op.estimatedDowntime()
+	if downtime > window.End.Sub(window.Start) {
+		return true
+	}
+	start := window.Start
+	if now.After(start) {
+		start = now
+	}
+	return !start.Add(downtime).After(window.End)
+}
+
+// emergency reports whether op scales up an instance at or above
+// emergencyThreshold percent P95 CPU or memory utilization
+func emergency(op ScalingOperation, emergencyThreshold float64) bool {
+	if op.Result == nil || emergencyThreshold <= 0 {
+		return false
+	}
+	up, err := config.IsUpscale(op.CurrentType, op.TargetType)
+	return err == nil && up && op.Result.UtilizationPressure() >= emergencyThreshold
+}
+
+// rollback reports whether op rolls back a regressed scale-down
+func rollback(op ScalingOperation) bool {
+	return op.Result != nil && op.Result.Decision.ReasonCode() == cloudsql.ReasonRollback
+}
+
+// postponeFrozen records op as deferred by freeze
+func (p *ScalingPlan) postponeFrozen(op ScalingOperation, freeze config.Freeze) {
+	p.Deferred = append(p.Deferred, DeferredOperation{
+		ScalingOperation: op,
+		DeferKind:        DeferFreeze,
+		DeferCode:        DeferFreeze.ReasonCode(),
+		DeferReason:      fmt.Sprintf("Scaling freeze (%s) until %s: %s", freeze.Target(), config.FormatTime(freeze.Until), freeze.Reason),
+		NotBefore:        freeze.Until,
+		Freeze:           &freeze,
+	})
+}
+
+// postpone records op as deferred
+func (p *ScalingPlan) postpone(op ScalingOperation, kind DeferKind, reason string, notBefore time.Time) {
+	p.Deferred = append(p.Deferred, DeferredOperation{
+		ScalingOperation: op,
+		DeferKind:        kind,
+		DeferCode:        kind.ReasonCode(),
+		DeferReason:      reason,
+		NotBefore:        notBefore,
+	})
+}
+
+// Frozen counts the operations deferred by a scaling freeze
+func (p *ScalingPlan) Frozen() int {
+	count := 0
+	for _, d := range p.Deferred {
+		if d.Freeze != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// IsDeferred reports whether the plan deferred the named instance
+func (p *ScalingPlan) IsDeferred(instanceName string) (DeferredOperation, bool) {
+	for _, d := range p.Deferred {
+		if d.Instance == instanceName {
+			return d, true
+		}
+	}
+	return DeferredOperation{}, false
+}
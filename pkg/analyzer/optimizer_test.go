@@ -0,0 +1,384 @@
+package analyzer_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/analyzer"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/rules"
+)
+
+// planNow is a Thursday morning, half an hour into the hour
+var planNow = time.Date(2026, time.January, 1, 10, 30, 0, 0, time.UTC)
+
+// operation returns a scaling operation on orders-db from current to target
+// for code, with room in its downtime budget for a ten minute resize
+func operation(current, target string, code cloudsql.ReasonCode, savings float64) analyzer.ScalingOperation {
+	return analyzer.ScalingOperation{
+		Instance:         "orders-db",
+		CurrentType:      current,
+		TargetType:       target,
+		EstimatedSavings: savings,
+		Result: &analyzer.AnalysisResult{
+			Instance: &config.InstanceInfo{Name: "orders-db", Project: "shop", MachineType: current},
+			Summary:  &config.MetricsSummary{CPUP95: 40, MemoryP95Pct: 50},
+			Decision: &cloudsql.ScalingDecision{ShouldScale: true, CurrentType: current, RecommendedType: target,
+				ReasonCodes: []cloudsql.ReasonCode{code}},
+			Downtime: &analyzer.DowntimeBudget{BudgetSeconds: 3600, RemainingSeconds: 3600, EstimatedSeconds: 600,
+				RenewsAt: time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+}
+
+// scaleDown returns a routine scale-down of orders-db
+func scaleDown() analyzer.ScalingOperation {
+	return operation("db-custom-4-15360", "db-custom-2-7680", cloudsql.ReasonCPUP95Low, 100)
+}
+
+// emergencyScaleUp returns a scale-up of orders-db running near saturation
+func emergencyScaleUp() analyzer.ScalingOperation {
+	op := operation("db-custom-2-7680", "db-custom-4-15360", cloudsql.ReasonCPUP95High, -100)
+	op.Result.Summary.CPUP95 = 97
+	return op
+}
+
+// TestOptimizeDeferral checks which check defers a single operation, and
+// until when, when several could apply, and which operations bypass which
+// checks
+func TestOptimizeDeferral(t *testing.T) {
+	recentlyScaled := func(op *analyzer.ScalingOperation) {
+		op.Result.Instance.LastScaledTime = planNow.Add(-10 * time.Minute)
+	}
+	downtime := func(op *analyzer.ScalingOperation) { op.DowntimeExpected = true }
+	overBudget := func(op *analyzer.ScalingOperation) {
+		op.DowntimeExpected = true
+		op.Result.Downtime.RemainingSeconds = 60
+	}
+	waitForInterval := func(op *analyzer.ScalingOperation) { op.Result.Decision.DowntimeFreeAt = planNow.Add(2 * time.Hour) }
+	// The instance's maintenance window is Thursdays 10:00-11:00 UTC, in progress at planNow
+	maintenanceWindow := func(op *analyzer.ScalingOperation) {
+		op.DowntimeExpected = true
+		op.Result.Instance.MaintenanceWindow = &config.MaintenanceWindow{Day: time.Thursday, Hour: 10}
+	}
+	night := func(cfg *config.Config) {
+		n, err := config.ParseMaintenanceNight("sat 22:00-04:00")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.MaintenanceNight = n
+	}
+	blackout := config.TimeWindow{Start: planNow.Add(-time.Hour), End: planNow.Add(time.Hour), Reason: "launch"}
+	freeze := config.Freeze{Scope: config.FreezeGlobal, Until: planNow.Add(24 * time.Hour), Reason: "quarter end"}
+
+	tests := []struct {
+		name      string
+		op        analyzer.ScalingOperation
+		edit      []func(op *analyzer.ScalingOperation)
+		configure func(cfg *config.Config)
+		want      analyzer.DeferKind // Empty when the operation runs
+		notBefore time.Time
+	}{
+		{name: "runs", op: scaleDown()},
+		{
+			name: "invalid target before cooldown",
+			op:   scaleDown(),
+			edit: []func(op *analyzer.ScalingOperation){recentlyScaled, func(op *analyzer.ScalingOperation) {
+				op.Result.TargetError = errors.New("machine type not offered in region")
+			}},
+			want: analyzer.DeferInvalidTarget,
+		},
+		{
+			name: "revert awaits review before cooldown",
+			op:   operation("db-custom-4-15360", "db-custom-2-7680", cloudsql.ReasonScaleUpRevert, 100),
+			edit: []func(op *analyzer.ScalingOperation){recentlyScaled},
+			want: analyzer.DeferRevertReview,
+		},
+		{
+			name:      "revert applied",
+			op:        operation("db-custom-4-15360", "db-custom-2-7680", cloudsql.ReasonScaleUpRevert, 100),
+			configure: func(cfg *config.Config) { cfg.RevertApply = true },
+		},
+		{
+			name:      "cooldown",
+			op:        scaleDown(),
+			edit:      []func(op *analyzer.ScalingOperation){recentlyScaled},
+			want:      analyzer.DeferCooldown,
+			notBefore: planNow.Add(20 * time.Minute),
+		},
+		{
+			name:      "cooldown before interval",
+			op:        scaleDown(),
+			edit:      []func(op *analyzer.ScalingOperation){recentlyScaled, waitForInterval},
+			want:      analyzer.DeferCooldown,
+			notBefore: planNow.Add(20 * time.Minute),
+		},
+		{
+			name:      "force skips cooldown",
+			op:        scaleDown(),
+			edit:      []func(op *analyzer.ScalingOperation){recentlyScaled},
+			configure: func(cfg *config.Config) { cfg.Force = true },
+		},
+		{name: "emergency skips cooldown", op: emergencyScaleUp(), edit: []func(op *analyzer.ScalingOperation){recentlyScaled}},
+		{
+			name: "rollback skips cooldown",
+			op:   operation("db-custom-2-7680", "db-custom-4-15360", cloudsql.ReasonRollback, -100),
+			edit: []func(op *analyzer.ScalingOperation){recentlyScaled},
+		},
+		{
+			name:      "interval",
+			op:        scaleDown(),
+			edit:      []func(op *analyzer.ScalingOperation){waitForInterval},
+			want:      analyzer.DeferInterval,
+			notBefore: planNow.Add(2 * time.Hour),
+		},
+		{
+			name:      "interval before downtime budget",
+			op:        scaleDown(),
+			edit:      []func(op *analyzer.ScalingOperation){waitForInterval, overBudget},
+			want:      analyzer.DeferInterval,
+			notBefore: planNow.Add(2 * time.Hour),
+		},
+		{
+			name:      "force skips interval",
+			op:        scaleDown(),
+			edit:      []func(op *analyzer.ScalingOperation){waitForInterval},
+			configure: func(cfg *config.Config) { cfg.Force = true },
+		},
+		{
+			name:      "downtime budget",
+			op:        scaleDown(),
+			edit:      []func(op *analyzer.ScalingOperation){overBudget},
+			want:      analyzer.DeferDowntimeBudget,
+			notBefore: time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{name: "emergency skips downtime budget", op: emergencyScaleUp(), edit: []func(op *analyzer.ScalingOperation){overBudget}},
+		{
+			name:      "downtime budget before maintenance night",
+			op:        scaleDown(),
+			edit:      []func(op *analyzer.ScalingOperation){overBudget},
+			configure: night,
+			want:      analyzer.DeferDowntimeBudget,
+			notBefore: time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "maintenance night",
+			op:        scaleDown(),
+			edit:      []func(op *analyzer.ScalingOperation){downtime},
+			configure: night,
+			want:      analyzer.DeferMaintenance,
+			notBefore: time.Date(2026, time.January, 3, 22, 0, 0, 0, time.UTC),
+		},
+		{name: "maintenance night waits only for downtime", op: scaleDown(), configure: night},
+		{name: "emergency skips maintenance night", op: emergencyScaleUp(), edit: []func(op *analyzer.ScalingOperation){downtime}, configure: night},
+		{
+			name: "inside the maintenance window",
+			op:   scaleDown(),
+			edit: []func(op *analyzer.ScalingOperation){maintenanceWindow, func(op *analyzer.ScalingOperation) {
+				op.Result.Downtime.EstimatedSeconds = 20 * 60
+			}},
+			configure: func(cfg *config.Config) { cfg.MaintenanceWindows = true },
+		},
+		{
+			name:      "downtime past the maintenance window end",
+			op:        scaleDown(),
+			edit:      []func(op *analyzer.ScalingOperation){maintenanceWindow, func(op *analyzer.ScalingOperation) { op.Result.Downtime.EstimatedSeconds = 40 * 60 }},
+			configure: func(cfg *config.Config) { cfg.MaintenanceWindows = true },
+			want:      analyzer.DeferMaintenanceWindow,
+			notBefore: time.Date(2026, time.January, 8, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "maintenance window before maintenance night",
+			op:   scaleDown(),
+			edit: []func(op *analyzer.ScalingOperation){downtime, func(op *analyzer.ScalingOperation) {
+				op.Result.Instance.MaintenanceWindow = &config.MaintenanceWindow{Day: time.Sunday, Hour: 3}
+			}},
+			configure: func(cfg *config.Config) {
+				night(cfg)
+				cfg.MaintenanceWindows = true
+			},
+			want:      analyzer.DeferMaintenanceWindow,
+			notBefore: time.Date(2026, time.January, 4, 3, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "bundled",
+			op:   scaleDown(),
+			edit: []func(op *analyzer.ScalingOperation){downtime, func(op *analyzer.ScalingOperation) {
+				op.Window = &rules.ScalingWindow{Start: planNow.Add(3 * time.Hour), End: planNow.Add(4 * time.Hour), Duration: time.Hour}
+			}},
+			configure: func(cfg *config.Config) { cfg.BundleDowntimeOperations = true },
+			want:      analyzer.DeferBundled,
+			notBefore: planNow.Add(3 * time.Hour),
+		},
+		{
+			name: "bundled window moves to the next day when the downtime does not fit",
+			op:   scaleDown(),
+			edit: []func(op *analyzer.ScalingOperation){downtime, func(op *analyzer.ScalingOperation) {
+				op.Window = &rules.ScalingWindow{Start: planNow.Add(-50 * time.Minute), End: planNow.Add(5 * time.Minute), Duration: 55 * time.Minute}
+			}},
+			configure: func(cfg *config.Config) { cfg.BundleDowntimeOperations = true },
+			want:      analyzer.DeferBundled,
+			notBefore: planNow.Add(-50*time.Minute).AddDate(0, 0, 1),
+		},
+		{
+			name:      "blackout",
+			op:        scaleDown(),
+			configure: func(cfg *config.Config) { cfg.BlackoutWindows = []config.TimeWindow{blackout} },
+			want:      analyzer.DeferBlackout,
+			notBefore: blackout.End,
+		},
+		{
+			name: "blackout at the window start",
+			op:   scaleDown(),
+			edit: []func(op *analyzer.ScalingOperation){func(op *analyzer.ScalingOperation) {
+				op.Window = &rules.ScalingWindow{Start: planNow.Add(3 * time.Hour), End: planNow.Add(4 * time.Hour), Duration: time.Hour}
+			}},
+			configure: func(cfg *config.Config) {
+				cfg.BlackoutWindows = []config.TimeWindow{{Start: planNow.Add(2 * time.Hour), End: planNow.Add(5 * time.Hour)}}
+			},
+			want:      analyzer.DeferBlackout,
+			notBefore: planNow.Add(5 * time.Hour),
+		},
+		{
+			name: "blackout before freeze",
+			op:   scaleDown(),
+			configure: func(cfg *config.Config) {
+				cfg.BlackoutWindows = []config.TimeWindow{blackout}
+				cfg.Freezes = []config.Freeze{freeze}
+			},
+			want:      analyzer.DeferBlackout,
+			notBefore: blackout.End,
+		},
+		{
+			name:      "freeze",
+			op:        scaleDown(),
+			configure: func(cfg *config.Config) { cfg.Freezes = []config.Freeze{freeze} },
+			want:      analyzer.DeferFreeze,
+			notBefore: freeze.Until,
+		},
+		{name: "emergency skips freeze", op: emergencyScaleUp(), configure: func(cfg *config.Config) { cfg.Freezes = []config.Freeze{freeze} }},
+		{
+			name:      "emergency waits for a blackout",
+			op:        emergencyScaleUp(),
+			configure: func(cfg *config.Config) { cfg.BlackoutWindows = []config.TimeWindow{blackout} },
+			want:      analyzer.DeferBlackout,
+			notBefore: blackout.End,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.ProjectID = "shop"
+			if tt.configure != nil {
+				tt.configure(cfg)
+			}
+			op := tt.op
+			for _, edit := range tt.edit {
+				edit(&op)
+			}
+
+			plan := (&analyzer.ScalingPlan{Operations: []analyzer.ScalingOperation{op}}).Optimize(cfg, planNow)
+			if tt.want == "" {
+				if len(plan.Operations) != 1 || len(plan.Deferred) != 0 {
+					t.Fatalf("Optimize ran %d and deferred %+v, want the operation to run", len(plan.Operations), plan.Deferred)
+				}
+				return
+			}
+			if len(plan.Operations) != 0 || len(plan.Deferred) != 1 {
+				t.Fatalf("Optimize ran %d and deferred %d operations, want one deferred", len(plan.Operations), len(plan.Deferred))
+			}
+			d := plan.Deferred[0]
+			if d.DeferKind != tt.want || d.DeferCode != tt.want.ReasonCode() || !d.NotBefore.Equal(tt.notBefore) {
+				t.Errorf("deferred %s (%s) until %v: %s; want %s until %v", d.DeferKind, d.DeferCode, d.NotBefore, d.DeferReason, tt.want, tt.notBefore)
+			}
+			if (d.Freeze != nil) != (tt.want == analyzer.DeferFreeze) {
+				t.Errorf("deferral freeze = %+v, want one only for a freeze", d.Freeze)
+			}
+		})
+	}
+}
+
+// TestOptimizeCycleLimits checks that the operation limit and cost increase
+// cap take operations in plan order, that deferring an operation does not
+// count against either, and that earlier deferrals are kept
+func TestOptimizeCycleLimits(t *testing.T) {
+	named := func(op analyzer.ScalingOperation, name string) analyzer.ScalingOperation {
+		op.Instance = name
+		op.Result.Instance.Name = name
+		return op
+	}
+	scaleUp := func(name string, increase float64) analyzer.ScalingOperation {
+		return named(operation("db-custom-2-7680", "db-custom-4-15360", cloudsql.ReasonCPUP95High, -increase), name)
+	}
+	cooling := named(scaleDown(), "cooling")
+	cooling.Result.Instance.LastScaledTime = planNow.Add(-time.Minute)
+
+	tests := []struct {
+		name      string
+		ops       []analyzer.ScalingOperation
+		configure func(cfg *config.Config)
+		run       []string
+		deferred  map[string]analyzer.DeferKind
+	}{
+		{
+			name:      "operation limit",
+			ops:       []analyzer.ScalingOperation{scaleUp("a", 10), cooling, scaleUp("b", 10), scaleUp("c", 10)},
+			configure: func(cfg *config.Config) { cfg.MaxOperationsPerCycle = 2 },
+			run:       []string{"a", "b"},
+			deferred:  map[string]analyzer.DeferKind{"cooling": analyzer.DeferCooldown, "c": analyzer.DeferOperationLimit},
+		},
+		{
+			name:      "cost cap",
+			ops:       []analyzer.ScalingOperation{scaleUp("a", 60), scaleUp("b", 60), named(scaleDown(), "saver"), scaleUp("c", 30)},
+			configure: func(cfg *config.Config) { cfg.CycleCostIncreaseCap = 100 },
+			run:       []string{"a", "saver", "c"},
+			deferred:  map[string]analyzer.DeferKind{"b": analyzer.DeferCostCap},
+		},
+		{
+			name: "operation limit before cost cap",
+			ops:  []analyzer.ScalingOperation{scaleUp("a", 60), scaleUp("b", 60)},
+			configure: func(cfg *config.Config) {
+				cfg.MaxOperationsPerCycle = 1
+				cfg.CycleCostIncreaseCap = 100
+			},
+			run:      []string{"a"},
+			deferred: map[string]analyzer.DeferKind{"b": analyzer.DeferOperationLimit},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			tt.configure(cfg)
+			earlier := analyzer.DeferredOperation{ScalingOperation: named(scaleDown(), "unstable"), DeferKind: analyzer.DeferUnstable}
+			plan := (&analyzer.ScalingPlan{Operations: tt.ops, Deferred: []analyzer.DeferredOperation{earlier}}).Optimize(cfg, planNow)
+
+			var run []string
+			for _, op := range plan.Operations {
+				run = append(run, op.Instance)
+			}
+			if len(run) != len(tt.run) {
+				t.Fatalf("ran %v, want %v", run, tt.run)
+			}
+			for i := range run {
+				if run[i] != tt.run[i] {
+					t.Fatalf("ran %v, want %v", run, tt.run)
+				}
+			}
+
+			want := map[string]analyzer.DeferKind{"unstable": analyzer.DeferUnstable}
+			for name, kind := range tt.deferred {
+				want[name] = kind
+			}
+			if len(plan.Deferred) != len(want) {
+				t.Fatalf("deferred %+v, want %v", plan.Deferred, want)
+			}
+			for _, d := range plan.Deferred {
+				if want[d.Instance] != d.DeferKind {
+					t.Errorf("%s deferred for %s, want %s", d.Instance, d.DeferKind, want[d.Instance])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/api/option"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/audit"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/decisionhook"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/rules"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/state"
+)
+
+// SQLAdmin is the Cloud SQL Admin API surface the analyzer depends on.
+// *cloudsql.Client implements it.
+type SQLAdmin interface {
+	GetInstance(ctx context.Context, instanceName string) (*config.InstanceInfo, error)
+	ListInstances(ctx context.Context) ([]*config.InstanceInfo, []cloudsql.SkippedInstance, error)
+	GetLastScalingTime(ctx context.Context, instanceName string) (time.Time, error)
+	GetPreservedSettings(ctx context.Context, instanceName string) (*cloudsql.PreservedSettings, error)
+	StartMachineTypeUpdate(ctx context.Context, instanceName, machineType string, labels map[string]string) (string, error)
+	WaitForOperation(ctx context.Context, operationName string) error
+	RefreshTiers(ctx context.Context) error
+}
+
+// MachineTypeValidator is implemented by SQLAdmin clients that can check a
+// resize before it is applied. *cloudsql.Client implements it.
+type MachineTypeValidator interface {
+	ValidateMachineType(ctx context.Context, instance *config.InstanceInfo, machineType string) error
+}
+
+// DiskResizer is implemented by SQLAdmin clients that can grow an instance's
+// data disk. *cloudsql.Client implements it.
+type DiskResizer interface {
+	StartDiskResize(ctx context.Context, instanceName string, sizeGB int64, labels map[string]string) (string, error)
+}
+
+// ReplicaManager is implemented by SQLAdmin clients that can create and
+// delete read replicas. *cloudsql.Client implements it.
+type ReplicaManager interface {
+	StartReadReplicaCreate(ctx context.Context, primary, name, tier string, labels map[string]string) (string, error)
+	StartReadReplicaDelete(ctx context.Context, name string) (string, error)
+}
+
+// MetricsSource is the Cloud Monitoring surface the analyzer depends on.
+// *cloudsql.MetricsClient implements it.
+type MetricsSource interface {
+	GetInstanceMetrics(ctx context.Context, instance *config.InstanceInfo, cfg *config.Config) (*config.MetricsData, error)
+	QuotaBudget() *cloudsql.QuotaBudget
+	Close() error
+}
+
+// PriceSource refreshes the rates cost estimates are priced at.
+// *cloudsql.PriceCatalog implements it.
+type PriceSource interface {
+	Refresh(ctx context.Context) error
+}
+
+// Options configures an Analyzer built with New. Only Config is required;
+// every other field has a default.
+type Options struct {
+	// Config holds thresholds and behavior; Config.ProjectID must be set
+	Config *config.Config
+
+	// SQLAdmin and Metrics replace the Google API clients, e.g. with fakes or
+	// clients shared with the embedding service. When nil, clients are created
+	// with ClientOptions. Injected clients are not closed by Analyzer.Close.
+	SQLAdmin      SQLAdmin
+	Metrics       MetricsSource
+	ClientOptions []option.ClientOption
+
+	// Pool supplies Config.ProjectID's clients instead of ClientOptions, so
+	// analyzers for the same project reuse one client pair. Analyzer.Close
+	// returns them to the pool. Ignored for clients that are injected.
+	Pool *cloudsql.ClientPool
+
+	// Progress receives human-readable progress messages (default: discarded)
+	Progress io.Writer
+	// AuditLogger records applied scaling operations (default: discarded)
+	AuditLogger *audit.Logger
+	// Actor is who applies changes, recorded in audit records and instance
+	// labels (default: none)
+	Actor *audit.Actor
+	// Prober checks connectivity after scaling (default: TCP prober)
+	Prober cloudsql.Prober
+	// Journal persists in-flight operations for ResumeOperations (default: none)
+	Journal OperationJournal
+	// State persists when instances were last scaled (default: read from the
+	// instance's operation list)
+	State *state.Store
+	// Fleet supplies fleet signals when Config.FleetSignals is set (default:
+	// Recommender API client, unless the other clients are injected or a
+	// metrics dump replaces them)
+	Fleet FleetSource
+	// Pricing supplies Cloud Billing Catalog rates when Config.CatalogPricing
+	// is set (default: Cloud Billing client, unless the other clients are
+	// injected or a metrics dump replaces them)
+	Pricing PriceSource
+	// DecisionHook reviews proposed resizes (default: a client for
+	// Config.DecisionHookURL, when it is set)
+	DecisionHook DecisionHook
+	// Permissions tests IAM permissions in Preflight (default: Resource
+	// Manager client, unless the other clients are injected or a metrics dump
+	// replaces them)
+	Permissions PermissionSource
+}
+
+// New creates an analyzer from opts. Unlike NewAnalyzer it writes nothing to
+// stdout or stderr unless Progress or AuditLogger are set.
+func New(ctx context.Context, opts Options) (*Analyzer, error) {
+	cfg := opts.Config
+	if cfg == nil {
+		cfg = config.DefaultConfig()
+	}
+
+	a := &Analyzer{
+		sqlClient:     opts.SQLAdmin,
+		metricsClient: opts.Metrics,
+		config:        cfg,
+		progress:      opts.Progress,
+		auditLog:      opts.AuditLogger,
+		actor:         opts.Actor,
+		prober:        opts.Prober,
+		journal:       opts.Journal,
+		state:         opts.State,
+		fleet:         opts.Fleet,
+		pricing:       opts.Pricing,
+		hook:          opts.DecisionHook,
+		permissions:   opts.Permissions,
+		chains:        newChainGuard(),
+	}
+
+	// Only analyzers that create their own clients reach the Recommender,
+	// Cloud Billing and Resource Manager APIs
+	ownClients := opts.SQLAdmin == nil && opts.Metrics == nil
+
+	// A metrics dump replaces both APIs unless either client was injected
+	if path, ok := cloudsql.MetricsFilePath(cfg.MetricsSource); ok && a.sqlClient == nil && a.metricsClient == nil {
+		file, err := cloudsql.LoadMetricsFile(path)
+		if err != nil {
+			return nil, err
+		}
+		a.sqlClient = file
+		a.metricsClient = file
+		a.replayAt = file.ExportedAt()
+		ownClients = false
+	}
+	if opts.Pool != nil && a.sqlClient == nil && a.metricsClient == nil {
+		sqlClient, metricsClient, release, err := opts.Pool.Get(ctx, cfg.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+		a.sqlClient = sqlClient
+		a.metricsClient = metricsClient
+		a.release = release
+	}
+	if a.sqlClient == nil {
+		sqlClient, err := cloudsql.NewClient(ctx, cfg.ProjectID, opts.ClientOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Cloud SQL client: %w", err)
+		}
+		a.sqlClient = sqlClient
+	}
+	// Read-only mode refuses changes whichever client was chosen
+	if cfg.ReadOnly {
+		a.sqlClient = readOnlyAdmin{a.sqlClient}
+	}
+	if a.metricsClient == nil {
+		metricsClient, err := cloudsql.NewMetricsClient(ctx, cfg.ProjectID, opts.ClientOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create metrics client: %w", err)
+		}
+		metricsClient.SetQuotaBudget(cloudsql.NewQuotaBudget(cfg.MonitoringQuotaPerMinute))
+		metricsClient.SetHedgeDelay(cfg.MetricsHedgeDelay)
+		a.metricsClient = metricsClient
+		a.ownsMetrics = true
+	}
+	if !cfg.FleetSignals {
+		a.fleet = nil
+	} else if a.fleet == nil && ownClients {
+		fleet, err := cloudsql.NewFleetClient(ctx, cfg.ProjectID, opts.ClientOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create fleet client: %w", err)
+		}
+		a.fleet = fleet
+	}
+	if !cfg.CatalogPricing {
+		a.pricing = nil
+	} else if a.pricing == nil && ownClients {
+		pricing, err := cloudsql.NewPriceCatalog(ctx, opts.ClientOptions...)
+		if err != nil {
+			return nil, err
+		}
+		a.pricing = pricing
+	}
+	if a.permissions == nil && ownClients {
+		permissions, err := cloudsql.NewPermissionChecker(ctx, opts.ClientOptions...)
+		if err != nil {
+			return nil, err
+		}
+		a.permissions = permissions
+	}
+	if a.hook == nil && cfg.DecisionHookURL != "" {
+		hook, err := decisionhook.Open(ctx, cfg.DecisionHookURL, cfg.DecisionHookSecret, cfg.DecisionHookTimeout)
+		if err != nil {
+			return nil, err
+		}
+		a.hook = hook
+	}
+	if a.progress == nil {
+		a.progress = io.Discard
+	}
+	if a.auditLog == nil {
+		a.auditLog = audit.NewLogger(io.Discard)
+	}
+	if a.prober == nil {
+		a.prober = cloudsql.NewTCPProber(cfg)
+	}
+	if err := a.compileEngines(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// compileEngines builds the rules engines of the config and of every
+// configuration derived from it, so a custom rule that does not compile in
+// any of them fails construction instead of being ignored
+func (a *Analyzer) compileEngines() error {
+	cfg := a.config
+	var err error
+	if a.rulesEngine, err = rules.NewEngine(cfg); err != nil {
+		return err
+	}
+	for _, o := range cfg.InstanceOverrides {
+		if o.Config == nil {
+			continue
+		}
+		if o.Config.ScaleDownThreshold >= o.Config.ScaleUpThreshold {
+			return fmt.Errorf("override of %s: scale-down threshold %.2f must be below the scale-up threshold %.2f",
+				o.Name(), o.Config.ScaleDownThreshold, o.Config.ScaleUpThreshold)
+		}
+		if a.overrides == nil {
+			a.overrides = make(map[string]*rules.Engine)
+		}
+		if a.overrides[o.Name()], err = rules.NewEngine(o.Config); err != nil {
+			return fmt.Errorf("override of %s: %w", o.Name(), err)
+		}
+	}
+	for name, profileCfg := range cfg.ProfileConfigs {
+		if a.profiles == nil {
+			a.profiles = make(map[string]*rules.Engine)
+		}
+		if a.profiles[name], err = rules.NewEngine(profileCfg); err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+	}
+	for _, schedule := range cfg.Schedules {
+		if schedule.ProfileConfig == nil {
+			continue
+		}
+		if a.scheduled == nil {
+			a.scheduled = make(map[*config.Config]*rules.Engine)
+		}
+		if a.scheduled[schedule.ProfileConfig], err = rules.NewEngine(schedule.ProfileConfig); err != nil {
+			return fmt.Errorf("profile %s of schedule %q: %w", schedule.Profile, schedule.Cron, err)
+		}
+	}
+	if cfg.Shadow != nil {
+		if a.shadow, err = rules.NewEngine(cfg.Shadow); err != nil {
+			return fmt.Errorf("shadow config: %w", err)
+		}
+	}
+	return nil
+}
+
+// NewProject creates a project-wide analyzer from opts
+func NewProject(ctx context.Context, opts Options) (*ProjectAnalyzer, error) {
+	a, err := New(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &ProjectAnalyzer{Analyzer: a}, nil
+}
@@ -0,0 +1,30 @@
+package analyzer
+
+import (
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/rules"
+)
+
+// engineFor returns the rules engine that judges instance: that of its
+// override in the config file, else that of the profile its labels name,
+// else the active one
+func (a *Analyzer) engineFor(instance *config.InstanceInfo) *rules.Engine {
+	if o, ok := a.config.OverrideFor(instance.Name); ok && a.overrides[o.Name()] != nil {
+		return a.overrides[o.Name()]
+	}
+	if profile, ok := a.labelProfile(instance); ok {
+		return a.profiles[profile]
+	}
+	return a.rulesEngine
+}
+
+// labelProfile returns the profile instance's autoscaler-profile label names
+// if that profile judges it. The central config file has the last word, so
+// instances it overrides ignore the label.
+func (a *Analyzer) labelProfile(instance *config.InstanceInfo) (string, bool) {
+	if o, ok := a.config.OverrideFor(instance.Name); ok && a.overrides[o.Name()] != nil {
+		return "", false
+	}
+	_, ok := a.profiles[instance.ProfileLabel]
+	return instance.ProfileLabel, ok
+}
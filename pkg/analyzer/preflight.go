@@ -0,0 +1,324 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/rules"
+)
+
+// PermissionSource tests the caller's IAM permissions on a project.
+// *cloudsql.PermissionChecker implements it.
+type PermissionSource interface {
+	MissingPermissions(ctx context.Context, project string, permissions []string) ([]string, error)
+}
+
+// PreflightStatus is the outcome of a preflight check
+type PreflightStatus string
+
+const (
+	PreflightPassed  PreflightStatus = "passed"
+	PreflightWarning PreflightStatus = "warning" // Autoscaling runs, on a fallback or without a feature
+	PreflightFailed  PreflightStatus = "failed"  // Autoscaling cannot work until it is fixed
+	PreflightSkipped PreflightStatus = "skipped" // Not checked, see the detail
+)
+
+// Preflight checks, in the order they are run
+const (
+	CheckSQLAdmin     = "sqladmin"      // Listing instances with the Cloud SQL Admin API
+	CheckPermissions  = "permissions"   // IAM permissions on the project
+	CheckMonitoring   = "monitoring"    // Reading an instance's metrics from Cloud Monitoring
+	CheckTiers        = "tiers"         // Listing machine types with the Cloud SQL Admin API
+	CheckPricing      = "pricing"       // Listing SKUs from the Cloud Billing Catalog
+	CheckFleetSignals = "fleet_signals" // Reading an instance's Recommender signals
+	CheckDecisionHook = "decision_hook" // The external decision hook
+)
+
+// preflightLabels name the checks in text output
+var preflightLabels = map[string]string{
+	CheckSQLAdmin:     "Cloud SQL Admin API",
+	CheckPermissions:  "IAM permissions",
+	CheckMonitoring:   "Cloud Monitoring API",
+	CheckTiers:        "Machine type catalog",
+	CheckPricing:      "Cloud Billing Catalog",
+	CheckFleetSignals: "Recommender API",
+	CheckDecisionHook: "Decision hook",
+}
+
+// PreflightCheck is the outcome of one preflight check
+type PreflightCheck struct {
+	Check  string          `json:"check"`
+	Status PreflightStatus `json:"status"`
+	Detail string          `json:"detail,omitempty"`
+	Hint   string          `json:"hint,omitempty"` // Remediation for failures
+}
+
+// Where an instance's policy comes from
+const (
+	PolicyDefault  = "default"  // The active configuration
+	PolicyOverride = "override" // An instance override in the configuration file
+	PolicyLabel    = "label"    // The profile its autoscaler-profile label names
+)
+
+// InstancePolicy is the effective policy a discovered instance is judged with
+type InstancePolicy struct {
+	Instance        string         `json:"instance"`
+	MachineType     string         `json:"machine_type"`
+	Edition         config.Edition `json:"edition"`
+	DatabaseVersion string         `json:"database_version"`
+
+	Source  string `json:"source"`            // PolicyDefault, PolicyOverride or PolicyLabel
+	Profile string `json:"profile,omitempty"` // Profile named by the override or label
+	Match   string `json:"match,omitempty"`   // Pattern of the override, when it does not name the instance
+
+	CPUTargetUtilization    float64 `json:"cpu_target_utilization"`
+	MemoryTargetUtilization float64 `json:"memory_target_utilization"`
+	ScaleUpThreshold        float64 `json:"scale_up_threshold"`
+	ScaleDownThreshold      float64 `json:"scale_down_threshold"`
+	CoolDownPeriod          string  `json:"cool_down_period"`
+
+	MaxTier        string        `json:"max_tier,omitempty"`        // Cap set by the autoscaler-max-tier label
+	DowntimeBudget string        `json:"downtime_budget,omitempty"` // Monthly downtime budget, from the label or the default
+	Owner          *config.Owner `json:"owner,omitempty"`
+	Warnings       []string      `json:"warnings,omitempty"` // Policy labels whose values are ignored
+}
+
+// PreflightReport is the outcome of Preflight
+type PreflightReport struct {
+	ProjectID string                     `json:"project_id"`
+	Checks    []PreflightCheck           `json:"checks"`
+	Instances []InstancePolicy           `json:"instances"`
+	Skipped   []cloudsql.SkippedInstance `json:"skipped,omitempty"` // Listed instances that are not analyzed
+	CheckedAt time.Time                  `json:"checked_at"`
+}
+
+// Failed returns the checks that failed
+func (r *PreflightReport) Failed() []PreflightCheck {
+	var failed []PreflightCheck
+	for _, c := range r.Checks {
+		if c.Status == PreflightFailed {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+// Preflight verifies that the autoscaler can do its job in the project before
+// it runs: that the APIs it depends on answer and the caller holds the IAM
+// permissions it needs. It lists the instances it would analyze with the
+// policy each is judged with, and those it would skip. It makes no changes
+// and sends nothing to the decision hook. Failures are reported in the
+// checks, not returned.
+func (p *ProjectAnalyzer) Preflight(ctx context.Context) (*PreflightReport, error) {
+	done, err := p.checkout()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	report := &PreflightReport{ProjectID: p.config.ProjectID, Instances: []InstancePolicy{}, CheckedAt: time.Now()}
+	add := func(check string, status PreflightStatus, detail, hint string) {
+		report.Checks = append(report.Checks, PreflightCheck{Check: check, Status: status, Detail: detail, Hint: hint})
+	}
+
+	listed, skipped, err := p.sqlClient.ListInstances(ctx)
+	var instances []*config.InstanceInfo
+	if err != nil {
+		add(CheckSQLAdmin, PreflightFailed, err.Error(), cloudsql.Hint(err))
+	} else {
+		total := len(listed) + len(skipped)
+		for _, instance := range listed {
+			if skip, ok := p.skipInstance(instance, report.CheckedAt); ok {
+				skipped = append(skipped, skip)
+				continue
+			}
+			instances = append(instances, instance)
+			report.Instances = append(report.Instances, p.policyOf(instance))
+		}
+		report.Skipped = skipped
+		add(CheckSQLAdmin, PreflightPassed, fmt.Sprintf("%d instance(s) listed, %d to analyze", total, len(instances)), "")
+	}
+
+	if p.permissions == nil {
+		add(CheckPermissions, PreflightSkipped, "not verified with injected clients or a metrics dump", "")
+	} else if missing, err := p.permissions.MissingPermissions(ctx, p.config.ProjectID, cloudsql.RequiredPermissions(p.config)); err != nil {
+		add(CheckPermissions, PreflightWarning, err.Error(),
+			"Enable cloudresourcemanager.googleapis.com on the quota project to verify permissions before the first cycle.")
+	} else if len(missing) > 0 {
+		add(CheckPermissions, PreflightFailed, "missing "+strings.Join(missing, ", "), permissionsHint(p.config))
+	} else {
+		add(CheckPermissions, PreflightPassed, "", "")
+	}
+
+	// Reading one instance's metrics shows the Monitoring API answers; a short
+	// period keeps it to a few points
+	var sample *config.InstanceInfo
+	if len(instances) > 0 {
+		sample = instances[0]
+	}
+	if sample == nil {
+		add(CheckMonitoring, PreflightSkipped, "no instance to read metrics of", "")
+	} else {
+		probe := *p.config
+		probe.MetricsPeriod = max(time.Hour, p.config.MetricsInterval)
+		if _, err := p.metricsClient.GetInstanceMetrics(ctx, sample, &probe); err != nil {
+			hint := ""
+			if cloudsql.ClassifySkip(err) == cloudsql.SkipPermissionDenied {
+				hint = fmt.Sprintf("Grant the autoscaler's service account roles/monitoring.viewer on project %s.", p.config.ProjectID)
+			}
+			add(CheckMonitoring, PreflightFailed, fmt.Sprintf("reading metrics of %s: %v", sample.Name, err), hint)
+		} else {
+			add(CheckMonitoring, PreflightPassed, "read metrics of "+sample.Name, "")
+		}
+	}
+
+	if err := p.sqlClient.RefreshTiers(ctx); err != nil {
+		add(CheckTiers, PreflightWarning, err.Error()+"; the built-in machine type catalog is used", "")
+	} else {
+		add(CheckTiers, PreflightPassed, "", "")
+	}
+
+	if p.pricing != nil {
+		if err := p.pricing.Refresh(ctx); err != nil {
+			add(CheckPricing, PreflightWarning, err.Error()+"; built-in list prices are used",
+				"Enable cloudbilling.googleapis.com on the quota project, or set --catalog-pricing=false.")
+		} else {
+			add(CheckPricing, PreflightPassed, "", "")
+		}
+	}
+
+	if p.fleet != nil {
+		if sample == nil {
+			add(CheckFleetSignals, PreflightSkipped, "no instance to read signals of", "")
+		} else if _, err := p.fleet.FleetSignals(ctx, sample); err != nil {
+			add(CheckFleetSignals, PreflightWarning, err.Error()+"; instances are analyzed without fleet signals",
+				fmt.Sprintf("Grant the autoscaler's service account roles/recommender.cloudsqlViewer on project %s.", p.config.ProjectID))
+		} else {
+			add(CheckFleetSignals, PreflightPassed, "read signals of "+sample.Name, "")
+		}
+	}
+
+	if p.hook != nil {
+		failure := "held"
+		if p.config.DecisionHookFailOpen {
+			failure = "applied unreviewed"
+		}
+		add(CheckDecisionHook, PreflightSkipped,
+			fmt.Sprintf("not contacted, since it is only sent proposed resizes; while it fails they are %s", failure), "")
+	}
+
+	return report, nil
+}
+
+// permissionsHint suggests the roles granting RequiredPermissions(cfg)
+func permissionsHint(cfg *config.Config) string {
+	role := "roles/cloudsql.editor"
+	if cfg.ReadOnly {
+		role = "roles/cloudsql.viewer"
+	}
+	return fmt.Sprintf("Grant the autoscaler's service account %s and roles/monitoring.viewer on project %s.", role, cfg.ProjectID)
+}
+
+// policyOf returns the policy instance is judged with, resolved the way
+// engineFor resolves its engine
+func (a *Analyzer) policyOf(instance *config.InstanceInfo) InstancePolicy {
+	policy := InstancePolicy{
+		Instance:        instance.Name,
+		MachineType:     instance.MachineType,
+		Edition:         instance.Edition,
+		DatabaseVersion: instance.DatabaseVersion,
+		Source:          PolicyDefault,
+		MaxTier:         instance.MaxTier,
+	}
+	cfg := a.config
+	if o, ok := a.config.OverrideFor(instance.Name); ok && o.Config != nil {
+		cfg, policy.Source, policy.Profile, policy.Match = o.Config, PolicyOverride, o.Profile, o.Match
+	} else if profile, ok := a.labelProfile(instance); ok {
+		cfg, policy.Source, policy.Profile = a.config.ProfileConfigs[profile], PolicyLabel, profile
+	}
+
+	policy.CPUTargetUtilization = cfg.CPUTargetUtilization
+	policy.MemoryTargetUtilization = cfg.MemoryTargetUtilization
+	policy.ScaleUpThreshold = cfg.ScaleUpThreshold
+	policy.ScaleDownThreshold = cfg.ScaleDownThreshold
+	policy.CoolDownPeriod = cfg.CoolDownPeriod.String()
+	if budget, _, ok := a.downtimeBudgetOf(instance); ok {
+		policy.DowntimeBudget = budget.String()
+	}
+	if owner := a.config.OwnerOf(instance); !owner.IsZero() {
+		policy.Owner = &owner
+	}
+	for _, w := range rules.PolicyLabelWarnings(instance, a.config) {
+		policy.Warnings = append(policy.Warnings, w.Message)
+	}
+	return policy
+}
+
+// Print writes the report as human-readable text
+func (r *PreflightReport) Print(w io.Writer) {
+	fmt.Fprintf(w, "Preflight for project %s:\n", r.ProjectID)
+	for _, c := range r.Checks {
+		line := fmt.Sprintf("  [%s] %s", c.Status, preflightLabels[c.Check])
+		if c.Detail != "" {
+			line += ": " + c.Detail
+		}
+		fmt.Fprintln(w, line)
+		if c.Hint != "" {
+			fmt.Fprintf(w, "    Hint: %s\n", c.Hint)
+		}
+	}
+
+	fmt.Fprintf(w, "Instances to analyze: %d\n", len(r.Instances))
+	for _, p := range r.Instances {
+		fmt.Fprintf(w, "  - %s (%s, %s, %s): %s\n", p.Instance, p.MachineType, p.Edition, p.DatabaseVersion, p.describe())
+		for _, warning := range p.Warnings {
+			fmt.Fprintf(w, "    Warning: %s\n", warning)
+		}
+	}
+	if len(r.Skipped) > 0 {
+		fmt.Fprintf(w, "Skipped: %d\n", len(r.Skipped))
+		for _, s := range r.Skipped {
+			fmt.Fprintf(w, "  - %s (%s): %s\n", s.Name, s.Reason, s.Detail)
+		}
+	}
+
+	if failed := r.Failed(); len(failed) > 0 {
+		fmt.Fprintf(w, "Preflight failed: %d check(s) failed\n", len(failed))
+	} else {
+		fmt.Fprintln(w, "Preflight passed")
+	}
+}
+
+// describe summarizes the policy on one line
+func (p InstancePolicy) describe() string {
+	source := "default policy"
+	switch p.Source {
+	case PolicyOverride:
+		source = "config file override"
+		if p.Match != "" {
+			source += " matching " + p.Match
+		}
+		if p.Profile != "" {
+			source += " (profile " + p.Profile + ")"
+		}
+	case PolicyLabel:
+		source = fmt.Sprintf("profile %s by label %s", p.Profile, cloudsql.LabelProfile)
+	}
+	s := fmt.Sprintf("%s, scale up at %.0f%%, down at %.0f%%, cooldown %s",
+		source, p.ScaleUpThreshold*100, p.ScaleDownThreshold*100, p.CoolDownPeriod)
+	if p.MaxTier != "" {
+		s += ", max tier " + p.MaxTier
+	}
+	if p.DowntimeBudget != "" {
+		s += ", downtime budget " + p.DowntimeBudget + "/month"
+	}
+	if p.Owner != nil && p.Owner.Team != "" {
+		s += ", owned by " + p.Owner.Team
+	}
+	return s
+}
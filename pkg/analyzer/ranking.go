@@ -0,0 +1,96 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// SortKey selects how analysis results are ordered in reports
+type SortKey string
+
+const (
+	SortByName     SortKey = "name"     // Instance name, ascending
+	SortBySavings  SortKey = "savings"  // Estimated monthly savings, highest first
+	SortByPressure SortKey = "pressure" // Peak CPU/memory P95 utilization, highest first
+	SortByPriority SortKey = "priority" // Scaling priority, highest first
+)
+
+// ParseSortKey validates a sort key string
+func ParseSortKey(s string) (SortKey, error) {
+	switch key := SortKey(strings.ToLower(s)); key {
+	case SortByName, SortBySavings, SortByPressure, SortByPriority:
+		return key, nil
+	case "":
+		return SortByName, nil
+	default:
+		return "", fmt.Errorf("invalid sort key %q (must be name, savings, pressure or priority)", s)
+	}
+}
+
+// UtilizationPressure returns the higher of CPU and memory P95 utilization
+func (r *AnalysisResult) UtilizationPressure() float64 {
+	if r.Summary == nil {
+		return 0
+	}
+	return math.Max(r.Summary.CPUP95, r.Summary.MemoryP95Pct)
+}
+
+// Priority returns the scaling priority of the result
+func (r *AnalysisResult) Priority() int {
+	if r.Decision == nil || r.Summary == nil {
+		return 0
+	}
+	return calculatePriority(r)
+}
+
+// RankResults returns a copy of results ordered by key. Ties are broken by
+// instance name so output is stable across runs.
+func RankResults(results []*AnalysisResult, key SortKey) []*AnalysisResult {
+	ranked := make([]*AnalysisResult, len(results))
+	copy(ranked, results)
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		switch key {
+		case SortBySavings:
+			if sa, sb := savingsOf(a), savingsOf(b); sa != sb {
+				return sa > sb
+			}
+		case SortByPressure:
+			if pa, pb := a.UtilizationPressure(), b.UtilizationPressure(); pa != pb {
+				return pa > pb
+			}
+		case SortByPriority:
+			if pa, pb := a.Priority(), b.Priority(); pa != pb {
+				return pa > pb
+			}
+		}
+		return a.Instance.Name < b.Instance.Name
+	})
+
+	return ranked
+}
+
+// TopN returns at most n results; n <= 0 returns all results
+func TopN(results []*AnalysisResult, n int) []*AnalysisResult {
+	if n <= 0 || n >= len(results) {
+		return results
+	}
+	return results[:n]
+}
+
+// savingsOf returns the estimated savings of a scaling result, or zero when no
+// scaling is recommended
+func savingsOf(r *AnalysisResult) float64 {
+	if r.Decision == nil || !r.Decision.ShouldScale {
+		return 0
+	}
+	return r.Decision.EstimatedSavings
+}
+
+// Ranked returns the project's results ordered by key
+func (p *ProjectAnalysisResult) Ranked(key SortKey) []*AnalysisResult {
+	return RankResults(p.Results, key)
+}
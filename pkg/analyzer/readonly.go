@@ -0,0 +1,55 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// ErrReadOnly is returned when a change is attempted by an analyzer in
+// read-only mode
+var ErrReadOnly = errors.New("read-only mode: Cloud SQL instances cannot be changed")
+
+// readOnlyAdmin passes reads through to a SQL Admin client and refuses every
+// mutating method before it reaches the client, so an analyzer in read-only
+// mode never changes an instance whatever its callers do. It implements the
+// optional interfaces of SQLAdmin so their mutating methods are refused too.
+type readOnlyAdmin struct {
+	SQLAdmin
+}
+
+// ReadOnly reports whether the analyzer refuses every Cloud SQL change
+func (a *Analyzer) ReadOnly() bool {
+	_, ok := a.sqlClient.(readOnlyAdmin)
+	return ok
+}
+
+// StartMachineTypeUpdate always fails with ErrReadOnly
+func (r readOnlyAdmin) StartMachineTypeUpdate(ctx context.Context, instanceName, machineType string, labels map[string]string) (string, error) {
+	return "", ErrReadOnly
+}
+
+// StartDiskResize always fails with ErrReadOnly
+func (r readOnlyAdmin) StartDiskResize(ctx context.Context, instanceName string, sizeGB int64, labels map[string]string) (string, error) {
+	return "", ErrReadOnly
+}
+
+// StartReadReplicaCreate always fails with ErrReadOnly
+func (r readOnlyAdmin) StartReadReplicaCreate(ctx context.Context, primary, name, tier string, labels map[string]string) (string, error) {
+	return "", ErrReadOnly
+}
+
+// StartReadReplicaDelete always fails with ErrReadOnly
+func (r readOnlyAdmin) StartReadReplicaDelete(ctx context.Context, name string) (string, error) {
+	return "", ErrReadOnly
+}
+
+// ValidateMachineType validates with the wrapped client when it supports
+// validation, and against the edition's machine types otherwise
+func (r readOnlyAdmin) ValidateMachineType(ctx context.Context, instance *config.InstanceInfo, machineType string) error {
+	if validator, ok := r.SQLAdmin.(MachineTypeValidator); ok {
+		return validator.ValidateMachineType(ctx, instance, machineType)
+	}
+	return config.CheckAvailability(instance.Edition, instance.DatabaseVersion, machineType)
+}
@@ -0,0 +1,223 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/audit"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/rules"
+)
+
+// ErrReplicaChangeUnsupported is returned by ApplyReplicaChange when the SQL
+// Admin client cannot create or delete read replicas
+var ErrReplicaChangeUnsupported = errors.New("SQL Admin client does not support read replica changes")
+
+// ReplicaChange is a read replica count change in a scaling plan. Replica
+// changes run before the plan's machine type changes, so a primary is never
+// resized while its read capacity is changing.
+type ReplicaChange struct {
+	Decision *cloudsql.ReplicaDecision `json:"decision"`
+	Owner    *config.Owner             `json:"owner,omitempty"`
+	Primary  *config.InstanceInfo      `json:"-"`
+}
+
+// ReplicaScalingEnabled reports whether recommended read replica count
+// changes are applied. New replicas add cost and removed ones drop read
+// capacity, so changes are only applied when configured; otherwise replica
+// decisions are advisory.
+func (a *Analyzer) ReplicaScalingEnabled() bool {
+	return a.config.ReplicaScaling
+}
+
+// readReplicaDecisions recommends read replica counts for the analyzed
+// primaries with read replicas. Each replica is judged on its own analysis,
+// so a primary whose replicas were not all analyzed in this run gets no
+// recommendation.
+func (a *Analyzer) readReplicaDecisions(results []*AnalysisResult) []*cloudsql.ReplicaDecision {
+	if a.config.ReplicaScaleUpThreshold <= 0 {
+		return nil
+	}
+
+	byName := make(map[string]*AnalysisResult, len(results))
+	for _, result := range results {
+		byName[result.Instance.Name] = result
+	}
+
+	var decisions []*cloudsql.ReplicaDecision
+	for _, primary := range results {
+		instance := primary.Instance
+		if instance.PrimaryInstance != "" || len(instance.Replicas) == 0 {
+			continue
+		}
+
+		failover := make(map[string]bool, len(instance.FailoverReplicas))
+		for _, name := range instance.FailoverReplicas {
+			failover[name] = true
+		}
+		var replicas []rules.ReadReplica
+		var missing []string
+		for _, name := range instance.Replicas {
+			if failover[name] {
+				continue
+			}
+			result, ok := byName[name]
+			if !ok {
+				missing = append(missing, name)
+				continue
+			}
+			if cloudsql.IsReadReplica(result.Instance) {
+				replicas = append(replicas, rules.ReadReplica{Instance: result.Instance, Summary: result.Summary})
+			}
+		}
+
+		if len(missing) > 0 {
+			decisions = append(decisions, &cloudsql.ReplicaDecision{
+				Primary:          instance.Name,
+				CurrentCount:     len(replicas) + len(missing),
+				RecommendedCount: len(replicas) + len(missing),
+				Reason:           fmt.Sprintf("Read replicas %v were not analyzed in this run", missing),
+				ReasonCodes:      []cloudsql.ReasonCode{cloudsql.ReasonReplicaIncompleteSet},
+			})
+			continue
+		}
+		if decision := a.rulesEngine.AnalyzeReadReplicas(instance, replicas); decision != nil {
+			decisions = append(decisions, decision)
+		}
+	}
+
+	sort.Slice(decisions, func(i, j int) bool { return decisions[i].Primary < decisions[j].Primary })
+	return decisions
+}
+
+// GetReplicaChanges returns the analyzed primaries whose read replica count should change
+func (p *ProjectAnalysisResult) GetReplicaChanges() []ReplicaChange {
+	byName := make(map[string]*AnalysisResult, len(p.Results))
+	for _, result := range p.Results {
+		byName[result.Instance.Name] = result
+	}
+
+	var changes []ReplicaChange
+	for _, decision := range p.Replicas {
+		primary, ok := byName[decision.Primary]
+		if !decision.ShouldChange() || !ok {
+			continue
+		}
+		change := ReplicaChange{Decision: decision, Primary: primary.Instance}
+		if !primary.Owner.IsZero() {
+			owner := primary.Owner
+			change.Owner = &owner
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// ReplicaDeferral reports why change should wait: a blackout window, or one
+// of freezes covering its primary at now
+func (a *Analyzer) ReplicaDeferral(change ReplicaChange, freezes []config.Freeze, now time.Time) (string, bool) {
+	if w, ok := a.config.ActiveBlackout(now); ok {
+		return fmt.Sprintf("Blackout window until %s: %s", config.FormatTime(w.End), w.Reason), true
+	}
+	if freeze, ok := config.ActiveFreeze(freezes, a.config.ProjectID, change.Primary, now); ok {
+		return fmt.Sprintf("Scaling freeze (%s) until %s: %s", freeze.Target(), config.FormatTime(freeze.Until), freeze.Reason), true
+	}
+	return "", false
+}
+
+// ApplyReplicaChange adds or removes a read replica as decision recommends
+// and waits for the change to complete. Only replicas the autoscaler created
+// are removed.
+func (a *Analyzer) ApplyReplicaChange(ctx context.Context, decision *cloudsql.ReplicaDecision) error {
+	done, err := a.checkout()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if decision == nil || !decision.ShouldChange() {
+		return fmt.Errorf("no read replica change recommended")
+	}
+	manager, ok := a.sqlClient.(ReplicaManager)
+	if !ok {
+		return ErrReplicaChangeUnsupported
+	}
+
+	replica := decision.RemoveReplica
+	if decision.Change > 0 {
+		replica = cloudsql.ReplicaName(decision)
+		a.logf("Adding %s read replica %s to %s...\n", decision.Tier, replica, decision.Primary)
+	} else {
+		a.logf("Removing read replica %s of %s...\n", replica, decision.Primary)
+	}
+
+	if a.config.DryRun {
+		a.logf("DRY RUN: No changes will be made\n")
+		return nil
+	}
+
+	// Replica changes share the chain lock so they never overlap a resize of the primary
+	release, err := a.lockChain(ctx, decision.Primary)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if decision.ID == "" {
+		decision.ID = cloudsql.NewDecisionID()
+	}
+	rec := audit.Record{
+		DecisionID: decision.ID,
+		Project:    a.config.ProjectID,
+		Instance:   replica,
+		Primary:    decision.Primary,
+		Reason:     decision.Reason,
+		ReasonCode: string(decision.ReasonCode()),
+	}
+
+	var opName string
+	if decision.Change > 0 {
+		rec.ToTier = decision.Tier
+		rec.Labels = cloudsql.ReplicaLabels(decision, time.Now())
+		a.attribute(&rec)
+		opName, err = manager.StartReadReplicaCreate(ctx, decision.Primary, replica, decision.Tier, rec.Labels)
+	} else {
+		a.attribute(&rec)
+		// Re-check the labels right before deleting, in case the replica changed hands
+		var info *config.InstanceInfo
+		if info, err = a.sqlClient.GetInstance(ctx, replica); err == nil && !cloudsql.IsAutoscalerReplica(info.Labels) {
+			err = fmt.Errorf("refusing to delete %s: it was not created by the autoscaler", replica)
+		}
+		if err == nil {
+			rec.FromTier = info.MachineType
+			opName, err = manager.StartReadReplicaDelete(ctx, replica)
+		}
+	}
+	if err == nil {
+		rec.Operation = opName
+		if err = a.sqlClient.WaitForOperation(ctx, opName); err != nil {
+			err = fmt.Errorf("read replica operation failed: %w", err)
+		}
+	}
+	if err != nil {
+		rec.Event = audit.EventReplicaChangeFailed
+		rec.Error = err.Error()
+		a.auditLog.Log(fmt.Sprintf("Failed to change read replicas of %s", decision.Primary), rec)
+		return fmt.Errorf("failed to change read replicas: %w", err)
+	}
+
+	if decision.Change > 0 {
+		rec.Event = audit.EventReplicaCreated
+		a.auditLog.Log(fmt.Sprintf("Created read replica %s of %s", replica, decision.Primary), rec)
+		a.logf("Successfully created read replica %s\n", replica)
+	} else {
+		rec.Event = audit.EventReplicaDeleted
+		a.auditLog.Log(fmt.Sprintf("Deleted read replica %s of %s", replica, decision.Primary), rec)
+		a.logf("Successfully deleted read replica %s\n", replica)
+	}
+	return nil
+}
@@ -4,14 +4,21 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/audit"
 	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
 	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/rules"
 )
 
 // ProjectAnalyzer analyzes all instances in a project
 type ProjectAnalyzer struct {
 	*Analyzer
+
+	samples sampleHistory
 }
 
 // NewProjectAnalyzer creates a new project-wide analyzer
@@ -28,22 +35,21 @@ func NewProjectAnalyzer(ctx context.Context, cfg *config.Config) (*ProjectAnalyz
 
 // AnalyzeAllInstances analyzes all Cloud SQL instances in the project
 func (p *ProjectAnalyzer) AnalyzeAllInstances(ctx context.Context) (*ProjectAnalysisResult, error) {
-	fmt.Println("Listing all Cloud SQL instances in the project...")
-
-	// First, get the raw list to know total count
-	rawResp, err := p.sqlClient.Service.Instances.List(p.config.ProjectID).Context(ctx).Do()
+	done, err := p.checkout()
 	if err != nil {
-		return nil, fmt.Errorf("failed to list instances: %w", err)
+		return nil, err
 	}
+	defer done()
 
-	totalCount := len(rawResp.Items)
+	p.logf("Listing all Cloud SQL instances in the project...\n")
+	start := time.Now()
 
-	// Now get detailed info for instances we can process
-	instances, err := p.sqlClient.ListInstances(ctx)
+	listed, skipped, err := p.sqlClient.ListInstances(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get instance details: %w", err)
 	}
 
+	totalCount := len(listed) + len(skipped)
 	if totalCount == 0 {
 		return &ProjectAnalysisResult{
 			ProjectID: p.config.ProjectID,
@@ -51,34 +57,259 @@ func (p *ProjectAnalyzer) AnalyzeAllInstances(ctx context.Context) (*ProjectAnal
 		}, nil
 	}
 
-	fmt.Printf("Found %d instances (%d processable). Analyzing each instance...\n\n", totalCount, len(instances))
-
-	results := make([]*AnalysisResult, 0, len(instances))
-	for _, instance := range instances {
-		fmt.Printf("Analyzing instance: %s\n", instance.Name)
-		result, err := p.AnalyzeInstance(ctx, instance.Name)
-		if err != nil {
-			fmt.Printf("  Error analyzing instance %s: %v\n", instance.Name, err)
+	// Only running instances that have not opted out are analyzed
+	instances := make([]*config.InstanceInfo, 0, len(listed))
+	for _, instance := range listed {
+		if skip, ok := p.skipInstance(instance, p.Now()); ok {
+			skipped = append(skipped, skip)
 			continue
 		}
-		results = append(results, result)
-		fmt.Println()
+		instances = append(instances, instance)
+	}
+	for _, skip := range skipped {
+		p.logf("Skipping instance %s (%s): %s\n", skip.Name, skip.Reason, skip.Detail)
+	}
+
+	processable := len(instances)
+	instances = p.sampleInstances(ctx, instances, start)
+	if len(instances) < processable {
+		p.logf("Found %d instances (%d processable). Sampling %d this cycle...\n\n", totalCount, processable, len(instances))
+	} else {
+		p.logf("Found %d instances (%d processable). Analyzing each instance...\n\n", totalCount, processable)
+	}
+
+	pace := p.analysisPace(instances)
+	if pace > 0 {
+		p.logf("Fleet exceeds monitoring quota; spreading analysis at one instance every %v\n", pace.Round(time.Second))
+	}
+
+	results, failed, err := p.analyzeInstances(ctx, instances, pace)
+	if err != nil {
+		return nil, err
 	}
+	skipped = append(skipped, failed...)
+	p.recordSample(ctx, instances, results, start)
 
 	return &ProjectAnalysisResult{
 		ProjectID:         p.config.ProjectID,
 		Results:           results,
 		TotalInstances:    totalCount,
 		AnalyzedInstances: len(results),
+		Skipped:           skipped,
+		NotSampled:        processable - len(instances),
+		Duration:          time.Since(start),
+		Replicas:          p.readReplicaDecisions(results),
+		currency:          p.config.Currency,
 	}, nil
 }
 
+// analyzeInstances analyzes instances, up to Config.AnalysisConcurrency at a
+// time and starting one every pace when pace is set. Results and the
+// instances that failed keep the order of instances. Once ctx is done no
+// further analysis starts, and ctx's error is returned after the running ones
+// have returned.
+func (p *ProjectAnalyzer) analyzeInstances(ctx context.Context, instances []*config.InstanceInfo, pace time.Duration) ([]*AnalysisResult, []cloudsql.SkippedInstance, error) {
+	workers := p.config.AnalysisConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(instances) {
+		workers = len(instances)
+	}
+
+	analyzed := make([]*AnalysisResult, len(instances))
+	errs := make([]error, len(instances))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				name := instances[i].Name
+				p.logf("Analyzing instance: %s\n", name)
+				if analyzed[i], errs[i] = p.analyzeWithTimeout(ctx, name); errs[i] != nil {
+					p.logf("  Error analyzing instance %s (%s): %v\n", name, cloudsql.ClassifySkip(errs[i]), errs[i])
+					continue
+				}
+				p.logf("\n")
+			}
+		}()
+	}
+
+	var err error
+dispatch:
+	for i := range instances {
+		if i > 0 && pace > 0 {
+			select {
+			case <-ctx.Done():
+				err = ctx.Err()
+				break dispatch
+			case <-time.After(pace):
+			}
+		}
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			break dispatch
+		case next <- i:
+		}
+	}
+	close(next)
+	wg.Wait()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results := make([]*AnalysisResult, 0, len(instances))
+	var failed []cloudsql.SkippedInstance
+	for i, instance := range instances {
+		if errs[i] != nil {
+			failed = append(failed, cloudsql.NewSkippedInstance(instance.Name, errs[i]))
+			continue
+		}
+		results = append(results, analyzed[i])
+	}
+	return results, failed, nil
+}
+
+// skipInstance reports whether a listed instance should not be analyzed at
+// now. Stopped instances serve no load, so their metrics are not read; those
+// stopped longer than Config.StoppedReviewAge are marked for deletion review.
+func (a *Analyzer) skipInstance(instance *config.InstanceInfo, now time.Time) (cloudsql.SkippedInstance, bool) {
+	if instance.Labels[cloudsql.LabelExclude] == "true" {
+		return cloudsql.SkippedInstance{Name: instance.Name, Reason: cloudsql.SkipExcludedByLabel,
+			Detail: fmt.Sprintf("label %s=true", cloudsql.LabelExclude)}, true
+	}
+	if instance.Stopped() {
+		skip := cloudsql.SkippedInstance{Name: instance.Name, Reason: cloudsql.SkipStopped, Detail: "instance is stopped"}
+		if stopped := instance.StoppedAt; !stopped.IsZero() {
+			skip.StoppedAt = &stopped
+			days := int(now.Sub(stopped).Hours() / 24)
+			skip.Detail = fmt.Sprintf("instance is stopped, since %s (%d days)", config.FormatTime(stopped), days)
+			if age := a.config.StoppedReviewAge; age > 0 && now.Sub(stopped) >= age {
+				skip.DeletionReview = true
+				skip.Hint = fmt.Sprintf("Stopped for %d days; review whether it can be deleted, since its storage, backups and IP addresses are still billed.", days)
+			}
+		}
+		return skip, true
+	}
+	if instance.State != "RUNNABLE" {
+		return cloudsql.SkippedInstance{Name: instance.Name, Reason: cloudsql.SkipNotRunnable,
+			Detail: fmt.Sprintf("instance state is %s", instance.State)}, true
+	}
+	return cloudsql.SkippedInstance{}, false
+}
+
+// sortedSkipReasons returns the reasons in counts in a stable order
+func sortedSkipReasons(counts map[cloudsql.SkipReason]int) []cloudsql.SkipReason {
+	reasons := make([]cloudsql.SkipReason, 0, len(counts))
+	for reason := range counts {
+		reasons = append(reasons, reason)
+	}
+	sort.Slice(reasons, func(i, j int) bool { return reasons[i] < reasons[j] })
+	return reasons
+}
+
+// SkippedByReason counts skipped instances by reason
+func (p *ProjectAnalysisResult) SkippedByReason() map[cloudsql.SkipReason]int {
+	counts := make(map[cloudsql.SkipReason]int)
+	for _, s := range p.Skipped {
+		counts[s.Reason]++
+	}
+	return counts
+}
+
+// DeletionReview returns the names of the skipped instances stopped for long
+// enough that their deletion should be reviewed
+func (p *ProjectAnalysisResult) DeletionReview() []string {
+	var names []string
+	for _, s := range p.Skipped {
+		if s.DeletionReview {
+			names = append(names, s.Name)
+		}
+	}
+	return names
+}
+
+// analysisPace returns the delay between instance analyses needed to keep the
+// fleet within the monitoring quota, or zero when no pacing is required. The
+// calls the fleet needs are counted per instance, since the metrics read
+// depend on its engine, replica role and data cache as well as the config.
+func (p *ProjectAnalyzer) analysisPace(instances []*config.InstanceInfo) time.Duration {
+	quota := p.config.MonitoringQuotaPerMinute
+	window := p.config.AnalysisSpreadWindow
+	if quota <= 0 || window <= 0 || len(instances) <= 1 {
+		return 0
+	}
+	calls := 0
+	for _, instance := range instances {
+		calls += cloudsql.MetricCalls(instance, p.config)
+	}
+	if calls <= quota {
+		return 0
+	}
+	return window / time.Duration(len(instances))
+}
+
 // ProjectAnalysisResult contains analysis results for all instances in a project
 type ProjectAnalysisResult struct {
 	ProjectID         string
 	Results           []*AnalysisResult
 	TotalInstances    int
 	AnalyzedInstances int
+	Skipped           []cloudsql.SkippedInstance // Instances not analyzed, with the reason
+	NotSampled        int                        // Processable instances left for a later sampled cycle
+	Duration          time.Duration              // Wall time of the whole analysis
+
+	// Read replica count recommendations for analyzed primaries with read replicas
+	Replicas []*cloudsql.ReplicaDecision
+
+	currency config.Currency // Formats cost estimates in reports
+}
+
+// InstanceNames returns the names of every instance the analysis covers,
+// their primaries and replicas and the instances skipped, without duplicates
+func (p *ProjectAnalysisResult) InstanceNames() []string {
+	seen := make(map[string]bool)
+	var names []string
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, result := range p.Results {
+		add(result.Instance.Name)
+		add(result.Instance.PrimaryInstance)
+		for _, replica := range result.Instance.Replicas {
+			add(replica)
+		}
+	}
+	for _, skipped := range p.Skipped {
+		add(skipped.Name)
+	}
+	return names
+}
+
+// Find returns the result of the instance named by instance, its name or
+// fully qualified ID, or nil if it was not analyzed
+func (p *ProjectAnalysisResult) Find(instance string) *AnalysisResult {
+	for _, result := range p.Results {
+		if result.Instance.Name == instance || result.Instance.ID() == instance {
+			return result
+		}
+	}
+	return nil
+}
+
+// InstanceID returns the fully qualified ID of the analyzed instance name,
+// or "" if it was not analyzed
+func (p *ProjectAnalysisResult) InstanceID(name string) string {
+	if result := p.Find(name); result != nil {
+		return result.Instance.ID()
+	}
+	return ""
 }
 
 // GetScalableInstances returns instances that need scaling
@@ -92,12 +323,33 @@ func (p *ProjectAnalysisResult) GetScalableInstances() []*AnalysisResult {
 	return scalable
 }
 
+// GetStorageIncreases returns the instances whose data disk should grow
+func (p *ProjectAnalysisResult) GetStorageIncreases() []*AnalysisResult {
+	var increases []*AnalysisResult
+	for _, result := range p.Results {
+		if result.Storage != nil && result.Storage.ShouldResize {
+			increases = append(increases, result)
+		}
+	}
+	return increases
+}
+
 // PrintProjectSummary prints a summary of all instances
 func (p *ProjectAnalysisResult) PrintProjectSummary() {
 	fmt.Printf("\n=== Project Analysis Summary ===\n")
 	fmt.Printf("Project ID: %s\n", p.ProjectID)
 	fmt.Printf("Total Instances: %d\n", p.TotalInstances)
 	fmt.Printf("Analyzed: %d\n", p.AnalyzedInstances)
+	if p.NotSampled > 0 {
+		fmt.Printf("Not sampled this cycle: %d\n", p.NotSampled)
+	}
+	counts := p.SkippedByReason()
+	for _, reason := range sortedSkipReasons(counts) {
+		fmt.Printf("Skipped (%s): %d\n", reason, counts[reason])
+	}
+	if review := p.DeletionReview(); len(review) > 0 {
+		fmt.Printf("Stopped instances to review for deletion: %s\n", strings.Join(review, ", "))
+	}
 
 	scalable := p.GetScalableInstances()
 	fmt.Printf("Instances Needing Scaling: %d\n\n", len(scalable))
@@ -141,7 +393,7 @@ func (p *ProjectAnalysisResult) PrintProjectSummary() {
 				r.Instance.Name, r.Decision.CurrentType, r.Decision.RecommendedType,
 				r.Summary.CPUP95, r.Summary.MemoryP95Pct)
 			if r.Decision.EstimatedSavings > 0 {
-				fmt.Printf("    💰 Estimated monthly savings: $%.2f\n", r.Decision.EstimatedSavings)
+				fmt.Printf("    💰 Estimated monthly savings: %s\n", p.currency.Format(r.Decision.EstimatedSavings))
 			}
 			if r.Decision.DowntimeExpected {
 				fmt.Printf("    ⚠️  %s\n", r.Decision.DowntimeReason)
@@ -151,9 +403,9 @@ func (p *ProjectAnalysisResult) PrintProjectSummary() {
 	}
 
 	if totalSavings > 0 {
-		fmt.Printf("Total Estimated Monthly Savings: $%.2f\n", totalSavings)
+		fmt.Printf("Total Estimated Monthly Savings: %s\n", p.currency.Format(totalSavings))
 	} else if totalSavings < 0 {
-		fmt.Printf("Total Estimated Monthly Cost Increase: $%.2f\n", -totalSavings)
+		fmt.Printf("Total Estimated Monthly Cost Increase: %s\n", p.currency.Format(-totalSavings))
 	}
 }
 
@@ -162,17 +414,27 @@ func (p *ProjectAnalysisResult) GenerateScalingPlan() *ScalingPlan {
 	scalable := p.GetScalableInstances()
 
 	plan := &ScalingPlan{
-		Operations: make([]ScalingOperation, 0, len(scalable)),
+		Operations:     make([]ScalingOperation, 0, len(scalable)),
+		ReplicaChanges: p.GetReplicaChanges(),
 	}
 
 	for _, result := range scalable {
 		op := ScalingOperation{
 			Instance:         result.Instance.Name,
+			InstanceID:       result.Instance.ID(),
 			CurrentType:      result.Decision.CurrentType,
 			TargetType:       result.Decision.RecommendedType,
 			Reason:           result.Decision.Reason,
+			ReasonCodes:      result.Decision.ReasonCodes,
 			DowntimeExpected: result.Decision.DowntimeExpected,
+			EstimatedSavings: result.Decision.EstimatedSavings,
 			Priority:         calculatePriority(result),
+			Window:           result.ScalingWindow,
+			Result:           result,
+		}
+		if !result.Owner.IsZero() {
+			owner := result.Owner
+			op.Owner = &owner
 		}
 		plan.Operations = append(plan.Operations, op)
 	}
@@ -185,19 +447,28 @@ func (p *ProjectAnalysisResult) GenerateScalingPlan() *ScalingPlan {
 	return plan
 }
 
-// ScalingPlan represents an ordered plan for scaling operations
+// ScalingPlan represents an ordered plan for scaling operations. Its
+// replica changes are applied before its operations.
 type ScalingPlan struct {
-	Operations []ScalingOperation
+	Operations     []ScalingOperation  `json:"operations"`
+	Deferred       []DeferredOperation `json:"deferred,omitempty"`
+	ReplicaChanges []ReplicaChange     `json:"replica_changes,omitempty"`
 }
 
 // ScalingOperation represents a single scaling operation
 type ScalingOperation struct {
-	Instance         string
-	CurrentType      string
-	TargetType       string
-	Reason           string
-	DowntimeExpected bool
-	Priority         int
+	Instance         string                `json:"instance"`
+	InstanceID       string                `json:"instance_id"` // Fully qualified, see config.InstanceID
+	CurrentType      string                `json:"current_type"`
+	TargetType       string                `json:"target_type"`
+	Reason           string                `json:"reason"`
+	ReasonCodes      []cloudsql.ReasonCode `json:"reason_codes,omitempty"`
+	DowntimeExpected bool                  `json:"downtime_expected"`
+	EstimatedSavings float64               `json:"estimated_savings"`
+	Priority         int                   `json:"priority"`
+	Window           *rules.ScalingWindow  `json:"window,omitempty"`
+	Owner            *config.Owner         `json:"owner,omitempty"` // Team notifications about the operation go to
+	Result           *AnalysisResult       `json:"-"`
 }
 
 // calculatePriority determines the priority of a scaling operation
@@ -224,30 +495,151 @@ func calculatePriority(result *AnalysisResult) int {
 	return priority
 }
 
-// ApplyScaling applies the recommended scaling to an instance
+// ApplyScaling applies the recommended scaling to an instance. A target the
+// machine type allow- or denylist restricts, or the instance cannot be
+// resized to, is refused whichever path chose it.
 func (a *Analyzer) ApplyScaling(ctx context.Context, instanceName string, decision *cloudsql.ScalingDecision) error {
+	_, err := a.Resize(ctx, instanceName, decision)
+	return err
+}
+
+// Resize applies decision as ApplyScaling does and reports whether it resized
+// the instance: false in dry-run mode, when the change was skipped as already
+// applied within the idempotency window, and when it failed before the
+// resize started
+func (a *Analyzer) Resize(ctx context.Context, instanceName string, decision *cloudsql.ScalingDecision) (bool, error) {
+	done, err := a.checkout()
+	if err != nil {
+		return false, err
+	}
+	defer done()
+
 	if !decision.ShouldScale {
-		return fmt.Errorf("no scaling recommended for instance %s", instanceName)
+		return false, fmt.Errorf("no scaling recommended for instance %s", instanceName)
 	}
 
 	// Validate the scaling decision
 	if err := a.rulesEngine.ValidateScalingDecision(decision, a.config.Force); err != nil {
-		return err
+		return false, err
+	}
+	instance, err := a.sqlClient.GetInstance(ctx, instanceName)
+	if err != nil {
+		return false, fmt.Errorf("failed to get instance info: %w", err)
+	}
+	if err := a.ValidateTarget(ctx, instance, decision.RecommendedType); err != nil {
+		return false, fmt.Errorf("cannot scale instance %s to %s: %w", instanceName, decision.RecommendedType, err)
 	}
 
-	fmt.Printf("Scaling instance %s from %s to %s...\n",
+	a.logf("Scaling instance %s from %s to %s...\n",
 		instanceName, decision.CurrentType, decision.RecommendedType)
 
 	if a.config.DryRun {
-		fmt.Println("DRY RUN: No changes will be made")
-		return nil
+		a.logf("DRY RUN: No changes will be made\n")
+		return false, nil
+	}
+
+	// Never change a primary and its replicas at the same time; the chain is
+	// held until the primary and its parity replicas are all resized
+	release, err := a.lockChain(ctx, instanceName)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	if decision.ID == "" {
+		decision.ID = cloudsql.NewDecisionID()
+	}
+	key := a.decisionKey(instanceName, decision, time.Now())
+	rec := audit.Record{
+		DecisionID: decision.ID,
+		Project:    a.config.ProjectID,
+		Instance:   instanceName,
+		FromTier:   decision.CurrentType,
+		ToTier:     decision.RecommendedType,
+		Reason:     decision.Reason,
+		ReasonCode: string(decision.ReasonCode()),
+		Labels:     cloudsql.ScalingLabels(decision, time.Now()),
+	}
+	a.tagRevert(decision, rec.Labels, time.Now())
+	tagRollback(decision, rec.Labels)
+	a.attribute(&rec)
+
+	// The chain lock is held, so nothing can apply the key between this check
+	// and the resize below
+	why, applied, err := a.appliedBefore(ctx, instanceName, key)
+	if err != nil {
+		return false, err
+	}
+	if applied {
+		rec.Event = audit.EventDuplicateSkipped
+		rec.Reason = why
+		rec.Labels = nil
+		a.auditLog.Log(fmt.Sprintf("Skipped scaling instance %s to %s: %s", instanceName, decision.RecommendedType, why), rec)
+		a.logf("Skipping instance %s: %s\n", instanceName, why)
+		return false, nil
+	}
+
+	// Capture settings that must survive the tier change
+	before, err := a.sqlClient.GetPreservedSettings(ctx, instanceName)
+	if err != nil {
+		return false, fmt.Errorf("failed to capture settings before scaling: %w", err)
+	}
+	rec.InstanceID = instance.ID()
+
+	// Failover/DR replicas are grown before the primary and shrunk after it,
+	// so a failover mid-operation never lands on a smaller machine
+	replicas := a.parityReplicas(instance)
+	upscale, err := config.IsUpscale(decision.CurrentType, decision.RecommendedType)
+	if err != nil && len(replicas) > 0 {
+		return false, fmt.Errorf("cannot order the resize of failover replicas: %w", err)
+	}
+	if upscale {
+		if err := a.resizeReplicas(ctx, instanceName, replicas, decision); err != nil {
+			return false, err
+		}
 	}
 
 	// Perform the scaling operation
-	if err := a.sqlClient.UpdateMachineType(ctx, instanceName, decision.RecommendedType); err != nil {
-		return fmt.Errorf("failed to update machine type: %w", err)
+	started := time.Now()
+	opName, err := a.startResize(ctx, PendingOperation{
+		Instance:   instanceName,
+		InstanceID: rec.InstanceID,
+		FromTier:   decision.CurrentType,
+		Decision:   decision,
+		Before:     before,
+	}, rec.Labels)
+	rec.Operation = opName
+	if err != nil {
+		rec.Event = audit.EventScalingFailed
+		rec.Error = err.Error()
+		a.auditLog.Log(fmt.Sprintf("Failed to scale instance %s to %s", instanceName, decision.RecommendedType), rec)
+		return false, fmt.Errorf("failed to update machine type: %w", err)
 	}
 
-	fmt.Printf("Successfully scaled instance %s to %s\n", instanceName, decision.RecommendedType)
-	return nil
+	rec.Event = audit.EventScalingApplied
+	a.auditLog.Log(fmt.Sprintf("Scaled instance %s from %s to %s", instanceName, decision.CurrentType, decision.RecommendedType), rec)
+
+	// The operation's duration bounds the downtime it caused
+	a.recordDowntime(ctx, instance, decision, time.Since(started), time.Now())
+
+	// Keep the verification window open until the instance proves healthy
+	err = a.verifyScaling(ctx, instanceName, decision, before)
+	if ctx.Err() == nil {
+		a.completeOperation(opName)
+	}
+	if err != nil {
+		rec.Event = audit.EventVerificationFailed
+		rec.Error = err.Error()
+		a.auditLog.Log(fmt.Sprintf("Verification failed for instance %s after scaling to %s", instanceName, decision.RecommendedType), rec)
+		return true, fmt.Errorf("scaled instance %s but verification failed: %w", instanceName, err)
+	}
+
+	if !upscale {
+		if err := a.resizeReplicas(ctx, instanceName, replicas, decision); err != nil {
+			return true, err
+		}
+	}
+
+	a.logf("Successfully scaled instance %s to %s\n", instanceName, decision.RecommendedType)
+	return true, nil
 }
@@ -0,0 +1,174 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	recommender "google.golang.org/api/recommender/v1"
+)
+
+// Recommender IDs and insight types used in exported names, matching the
+// Cloud SQL recommenders FinOps pipelines already route on
+const (
+	recommenderOverprovisioned  = "google.cloudsql.instance.OverprovisionedRecommender"
+	recommenderUnderprovisioned = "google.cloudsql.instance.PerformanceRecommender"
+	insightOverprovisioned      = "google.cloudsql.instance.OverprovisionedInsight"
+	insightUnderprovisioned     = "google.cloudsql.instance.PerformanceInsight"
+
+	// recommenderSubtype marks exported recommendations as ours rather than Google's
+	recommenderSubtypeDown = "CLOUDSQL_AUTOSCALER_DOWNSIZE"
+	recommenderSubtypeUp   = "CLOUDSQL_AUTOSCALER_UPSIZE"
+)
+
+// RecommenderExport holds decisions in the Cloud Recommender
+// recommendation and insight JSON structure
+type RecommenderExport struct {
+	Recommendations []*recommender.GoogleCloudRecommenderV1Recommendation `json:"recommendations"`
+	Insights        []*recommender.GoogleCloudRecommenderV1Insight        `json:"insights"`
+}
+
+// ExportRecommender converts the scaling decisions in results into Recommender
+// recommendations, each with an associated insight describing the
+// utilization that led to it. Costs are in US dollars over 30 days, negative
+// for savings as Recommender reports them.
+func ExportRecommender(results *ProjectAnalysisResult, now time.Time) *RecommenderExport {
+	export := &RecommenderExport{
+		Recommendations: []*recommender.GoogleCloudRecommenderV1Recommendation{},
+		Insights:        []*recommender.GoogleCloudRecommenderV1Insight{},
+	}
+
+	for _, result := range results.GetScalableInstances() {
+		rec, insight := recommenderEntries(results.ProjectID, result, now)
+		export.Recommendations = append(export.Recommendations, rec)
+		export.Insights = append(export.Insights, insight)
+	}
+	return export
+}
+
+// recommenderEntries builds the recommendation and insight for one result
+func recommenderEntries(projectID string, result *AnalysisResult, now time.Time) (*recommender.GoogleCloudRecommenderV1Recommendation, *recommender.GoogleCloudRecommenderV1Insight) {
+	decision := result.Decision
+	instance := result.Instance
+	resource := fmt.Sprintf("//cloudsql.googleapis.com/projects/%s/instances/%s", projectID, instance.Name)
+	location := fmt.Sprintf("projects/%s/locations/%s", projectID, instance.Region)
+
+	upscale := DecisionAction(decision) == ActionScaleUp
+	recommenderID, insightType, subtype := recommenderOverprovisioned, insightOverprovisioned, recommenderSubtypeDown
+	category, severity := "COST", "LOW"
+	if upscale {
+		recommenderID, insightType, subtype = recommenderUnderprovisioned, insightUnderprovisioned, recommenderSubtypeUp
+		category, severity = "PERFORMANCE", "MEDIUM"
+		if result.UtilizationPressure() > 90 {
+			severity = "HIGH"
+		}
+	}
+
+	recName := fmt.Sprintf("%s/recommenders/%s/recommendations/%s", location, recommenderID, decision.ID)
+	insightName := fmt.Sprintf("%s/insightTypes/%s/insights/%s", location, insightType, decision.ID)
+	refreshed := result.AnalyzedAt
+	if refreshed.IsZero() {
+		refreshed = now
+	}
+
+	costImpact := &recommender.GoogleCloudRecommenderV1Impact{
+		Category: "COST",
+		CostProjection: &recommender.GoogleCloudRecommenderV1CostProjection{
+			Cost:     usdMoney(-decision.EstimatedSavings),
+			Duration: "2592000s", // 30 days
+		},
+	}
+	rec := &recommender.GoogleCloudRecommenderV1Recommendation{
+		Name:               recName,
+		Description:        fmt.Sprintf("Change the machine type of %s from %s to %s. %s", instance.Name, decision.CurrentType, decision.RecommendedType, decision.Reason),
+		RecommenderSubtype: subtype,
+		LastRefreshTime:    refreshed.UTC().Format(time.RFC3339),
+		Priority:           recommenderPriority(result.Priority()),
+		TargetResources:    []string{resource},
+		StateInfo:          &recommender.GoogleCloudRecommenderV1RecommendationStateInfo{State: "ACTIVE"},
+		Etag:               fmt.Sprintf("%q", decision.ID),
+		AssociatedInsights: []*recommender.GoogleCloudRecommenderV1RecommendationInsightReference{{Insight: insightName}},
+		Content: &recommender.GoogleCloudRecommenderV1RecommendationContent{
+			OperationGroups: []*recommender.GoogleCloudRecommenderV1OperationGroup{{
+				Operations: []*recommender.GoogleCloudRecommenderV1Operation{
+					{Action: "test", ResourceType: "sqladmin.googleapis.com/Instance", Resource: resource, Path: "/settings/tier", Value: decision.CurrentType},
+					{Action: "replace", ResourceType: "sqladmin.googleapis.com/Instance", Resource: resource, Path: "/settings/tier", Value: decision.RecommendedType},
+				},
+			}},
+			Overview: rawJSON(map[string]interface{}{
+				"currentMachineType":     decision.CurrentType,
+				"recommendedMachineType": decision.RecommendedType,
+				"downtimeExpected":       decision.DowntimeExpected,
+				"downtimeReason":         decision.DowntimeReason,
+				"reasonCodes":            decision.ReasonCodes,
+			}),
+		},
+	}
+	if upscale {
+		rec.PrimaryImpact = &recommender.GoogleCloudRecommenderV1Impact{Category: "PERFORMANCE"}
+		rec.AdditionalImpact = []*recommender.GoogleCloudRecommenderV1Impact{costImpact}
+	} else {
+		rec.PrimaryImpact = costImpact
+	}
+
+	insight := &recommender.GoogleCloudRecommenderV1Insight{
+		Name:                      insightName,
+		Description:               decision.Reason,
+		InsightSubtype:            subtype,
+		Category:                  category,
+		Severity:                  severity,
+		LastRefreshTime:           rec.LastRefreshTime,
+		TargetResources:           []string{resource},
+		StateInfo:                 &recommender.GoogleCloudRecommenderV1InsightStateInfo{State: "ACTIVE"},
+		Etag:                      rec.Etag,
+		AssociatedRecommendations: []*recommender.GoogleCloudRecommenderV1InsightRecommendationReference{{Recommendation: recName}},
+	}
+	if s := result.Summary; s != nil {
+		insight.ObservationPeriod = fmt.Sprintf("%ds", int64(s.Period.Seconds()))
+		insight.Content = rawJSON(map[string]interface{}{
+			"cpuUtilizationP95":    s.CPUP95,
+			"cpuUtilizationP99":    s.CPUP99,
+			"memoryUtilizationP95": s.MemoryP95Pct,
+			"memoryUtilizationP99": s.MemoryP99Pct,
+			"dataPoints":           s.DataPoints,
+		})
+	}
+
+	return rec, insight
+}
+
+// recommenderPriority maps a scaling priority to Recommender's P1-P4
+func recommenderPriority(priority int) string {
+	switch {
+	case priority >= 70:
+		return "P1"
+	case priority >= 50:
+		return "P2"
+	case priority >= 30:
+		return "P3"
+	default:
+		return "P4"
+	}
+}
+
+// usdMoney converts a dollar amount to google.type.Money
+func usdMoney(amount float64) *recommender.GoogleTypeMoney {
+	units, frac := math.Modf(amount)
+	return &recommender.GoogleTypeMoney{
+		CurrencyCode: "USD",
+		Units:        int64(units),
+		Nanos:        int64(math.Round(frac * 1e9)),
+	}
+}
+
+// rawJSON encodes v for a free-form content field, omitting it if v cannot
+// be encoded
+func rawJSON(v interface{}) googleapi.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return data
+}
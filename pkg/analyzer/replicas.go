@@ -0,0 +1,67 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/audit"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// parityReplicas returns the failover/DR replicas of instance that must be
+// resized alongside it under the configured replica policy
+func (a *Analyzer) parityReplicas(instance *config.InstanceInfo) []string {
+	if a.config.ReplicaPolicy != config.ReplicaPolicyParity {
+		return nil
+	}
+	return instance.FailoverReplicas
+}
+
+// resizeReplicas brings each replica to the decision's target tier so a
+// failover never lands on an undersized machine
+func (a *Analyzer) resizeReplicas(ctx context.Context, primary string, replicas []string, decision *cloudsql.ScalingDecision) error {
+	for _, replica := range replicas {
+		info, err := a.sqlClient.GetInstance(ctx, replica)
+		if err != nil {
+			return fmt.Errorf("failed to read failover replica %s: %w", replica, err)
+		}
+		if info.MachineType == decision.RecommendedType {
+			continue
+		}
+
+		a.logf("Resizing failover replica %s of %s from %s to %s...\n",
+			replica, primary, info.MachineType, decision.RecommendedType)
+
+		rec := audit.Record{
+			DecisionID: decision.ID,
+			Project:    a.config.ProjectID,
+			Instance:   replica,
+			InstanceID: info.ID(),
+			FromTier:   info.MachineType,
+			ToTier:     decision.RecommendedType,
+			Reason:     fmt.Sprintf("Replica parity with primary %s", primary),
+			Labels:     cloudsql.ScalingLabels(decision, time.Now()),
+		}
+		a.attribute(&rec)
+		opName, err := a.startResize(ctx, PendingOperation{
+			Instance:   replica,
+			InstanceID: info.ID(),
+			Primary:    primary,
+			FromTier:   info.MachineType,
+			Decision:   decision,
+		}, rec.Labels)
+		rec.Operation = opName
+		if err != nil {
+			rec.Event = audit.EventScalingFailed
+			rec.Error = err.Error()
+			a.auditLog.Log(fmt.Sprintf("Failed to resize failover replica %s", replica), rec)
+			return fmt.Errorf("failed to resize failover replica %s: %w", replica, err)
+		}
+		a.completeOperation(opName)
+		rec.Event = audit.EventScalingApplied
+		a.auditLog.Log(fmt.Sprintf("Resized failover replica %s to %s", replica, decision.RecommendedType), rec)
+	}
+	return nil
+}
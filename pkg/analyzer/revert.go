@@ -0,0 +1,67 @@
+package analyzer
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// RevertEnabled reports whether the auto-revert policy for reactive
+// scale-ups is on
+func (a *Analyzer) RevertEnabled() bool {
+	return a.config.RevertDeadline > 0
+}
+
+// isReactiveScaleUp reports whether decision grows the instance because
+// utilization is already over the scale-up threshold, as opposed to a
+// trend, a pre-scale or a revert
+func isReactiveScaleUp(decision *cloudsql.ScalingDecision) bool {
+	switch decision.ReasonCode() {
+	case cloudsql.ReasonCPUP95High, cloudsql.ReasonMemoryP95High:
+		up, err := config.IsUpscale(decision.CurrentType, decision.RecommendedType)
+		return err == nil && up
+	default:
+		return false
+	}
+}
+
+// tagRevert adds the revert deadline to the labels written when applying
+// decision. Any other operation clears an earlier deadline, so only the
+// latest reactive scale-up is ever reverted.
+func (a *Analyzer) tagRevert(decision *cloudsql.ScalingDecision, labels map[string]string, at time.Time) {
+	if !a.RevertEnabled() {
+		return
+	}
+	labels[cloudsql.LabelRevertBy] = ""
+	if isReactiveScaleUp(decision) {
+		labels[cloudsql.LabelRevertBy] = strconv.FormatInt(at.Add(a.config.RevertDeadline).Unix(), 10)
+	}
+}
+
+// revertScaleUp returns the decision undoing the instance's latest reactive
+// scale-up, if it is still within its deadline and utilization projected
+// onto the original machine type stayed below target for the quiet period.
+// The revert replaces decision when it recommends no change, or when it
+// recommends a scale-down and reverts are applied; recommend-only reverts
+// never hold back a regular scale-down.
+func (a *Analyzer) revertScaleUp(instance *config.InstanceInfo, metrics *config.MetricsData, decision *cloudsql.ScalingDecision, now time.Time) *cloudsql.ScalingDecision {
+	if !a.RevertEnabled() || instance.IsFailoverReplica || instance.UnsupportedTier {
+		return nil
+	}
+	if decision.ShouldScale {
+		if up, err := config.IsUpscale(decision.CurrentType, decision.RecommendedType); !a.config.RevertApply || err != nil || up {
+			return nil
+		}
+	}
+	tag, ok := cloudsql.ParseRevertTag(instance.Labels)
+	if !ok {
+		return nil
+	}
+	since := now.Add(-a.config.RevertQuietPeriod)
+	quiet := cloudsql.CalculateMetricsSummary(cloudsql.FilterMetrics(metrics, func(ts time.Time) bool {
+		return !ts.Before(since)
+	}))
+	return a.engineFor(instance).RevertScaleUp(instance, tag, quiet, now)
+}
@@ -0,0 +1,49 @@
+package analyzer
+
+import (
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// rollbackSettle is how long after a scale-down its metrics are ignored, so
+// the restart and a cold buffer cache are not taken for a regression
+const rollbackSettle = 10 * time.Minute
+
+// RollbackEnabled reports whether regressed scale-downs are rolled back
+func (a *Analyzer) RollbackEnabled() bool {
+	return a.config.RollbackWindow > 0
+}
+
+// applyRollback returns the decision rolling back the instance's last
+// scale-down when it is within the rollback window and regressed since it
+// settled. Otherwise a scale-down in decision to the machine type an earlier
+// rollback left, or smaller, is held back.
+func (a *Analyzer) applyRollback(instance *config.InstanceInfo, metrics *config.MetricsData, decision *cloudsql.ScalingDecision, now time.Time) *cloudsql.ScalingDecision {
+	if instance.IsFailoverReplica || instance.UnsupportedTier {
+		return decision
+	}
+	engine := a.engineFor(instance)
+	if last, ok := cloudsql.ParseLastScaling(instance.Labels); ok && a.RollbackEnabled() && now.Sub(last.ScaledAt) <= a.config.RollbackWindow {
+		settled := last.ScaledAt.Add(rollbackSettle)
+		after := cloudsql.CalculateMetricsSummary(cloudsql.FilterMetrics(metrics, func(ts time.Time) bool {
+			return !ts.Before(settled)
+		}))
+		if rollback := engine.RollbackScaleDown(instance, last, after); rollback != nil {
+			return rollback
+		}
+	}
+	if floor := instance.Labels[cloudsql.LabelRollbackFloor]; floor != "" {
+		return engine.HoldAboveRollback(instance, cloudsql.TierFromLabel(floor), decision)
+	}
+	return decision
+}
+
+// tagRollback adds the rollback floor to the labels written when applying a
+// rollback, so the regressed machine type is not chosen again
+func tagRollback(decision *cloudsql.ScalingDecision, labels map[string]string) {
+	if decision.ReasonCode() == cloudsql.ReasonRollback {
+		labels[cloudsql.LabelRollbackFloor] = cloudsql.TierLabelValue(decision.CurrentType)
+	}
+}
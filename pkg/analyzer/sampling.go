@@ -0,0 +1,161 @@
+package analyzer
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// samplingSection is the state store section recording when each instance was
+// last analyzed in a sampled cycle and its priority then, so a restart does
+// not send the rotation back to the start of the fleet
+const samplingSection = "sampling"
+
+// sampleState is when each instance was last analyzed and what its priority
+// was, as kept in the sampling section
+type sampleState struct {
+	Started      time.Time            `json:"started"`
+	LastAnalyzed map[string]time.Time `json:"last_analyzed,omitempty"` // By fully qualified instance ID
+	LastPriority map[string]int       `json:"last_priority,omitempty"` // By fully qualified instance ID
+}
+
+// sampleHistory remembers when each instance was last analyzed and what its
+// priority was, so sampled cycles can rotate through the fleet
+type sampleHistory struct {
+	mu    sync.Mutex
+	state sampleState
+}
+
+// sampling reports whether cfg analyzes a share of the fleet each cycle
+func sampling(cfg *config.Config) bool {
+	return cfg.SampleFraction > 0 && cfg.SampleFraction < 1
+}
+
+// sampleInstances returns the instances to analyze this cycle, rotating
+// through the fleet from where the state store, if any, left it
+func (p *ProjectAnalyzer) sampleInstances(ctx context.Context, instances []*config.InstanceInfo, now time.Time) []*config.InstanceInfo {
+	if p.state != nil && sampling(p.config) {
+		var saved sampleState
+		if _, err := p.state.Get(ctx, samplingSection, &saved); err != nil {
+			p.logf("Warning: %v; sampling from this process's record only\n", err)
+		} else if !saved.Started.IsZero() {
+			p.samples.mu.Lock()
+			p.samples.state = saved
+			p.samples.mu.Unlock()
+		}
+	}
+	return p.samples.sample(instances, p.config, now)
+}
+
+// recordSample notes that instances were analyzed at now with results, and
+// keeps the record in the state store, if any
+func (p *ProjectAnalyzer) recordSample(ctx context.Context, instances []*config.InstanceInfo, results []*AnalysisResult, now time.Time) {
+	saved := p.samples.record(instances, results, now)
+	if p.state == nil || !sampling(p.config) {
+		return
+	}
+	if err := p.state.Put(ctx, samplingSection, saved); err != nil {
+		p.logf("Warning: %v; the sampling rotation will start over after a restart\n", err)
+	}
+}
+
+// sample returns the instances to analyze this cycle. Instances not analyzed
+// within cfg.SampleMaxAge are always included, even when that exceeds the
+// sample size; the remainder is filled according to cfg.SampleStrategy.
+func (h *sampleHistory) sample(instances []*config.InstanceInfo, cfg *config.Config, now time.Time) []*config.InstanceInfo {
+	if !sampling(cfg) || len(instances) == 0 {
+		return instances
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.state.Started.IsZero() {
+		h.state.Started = now
+	}
+
+	size := int(math.Ceil(cfg.SampleFraction * float64(len(instances))))
+	overdue := func(age time.Duration) bool {
+		return cfg.SampleMaxAge > 0 && age >= cfg.SampleMaxAge
+	}
+
+	type candidate struct {
+		instance *config.InstanceInfo
+		age      time.Duration
+		score    float64
+	}
+	candidates := make([]candidate, 0, len(instances))
+	for _, instance := range instances {
+		last, ok := h.state.LastAnalyzed[instance.ID()]
+		if !ok {
+			last = h.state.Started
+		}
+		age := now.Sub(last)
+
+		// Never-analyzed instances sort ahead of everything analyzed since startup
+		score := age.Hours() + 1
+		if !ok {
+			score = math.Inf(1)
+		} else if cfg.SampleStrategy == config.SamplePriority {
+			score *= 1 + float64(h.state.LastPriority[instance.ID()])/50
+		}
+		candidates = append(candidates, candidate{instance: instance, age: age, score: score})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if overdueA, overdueB := overdue(a.age), overdue(b.age); overdueA != overdueB {
+			return overdueA
+		}
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		return a.instance.Name < b.instance.Name
+	})
+
+	selected := make([]*config.InstanceInfo, 0, size)
+	for _, c := range candidates {
+		if len(selected) >= size && !overdue(c.age) {
+			break
+		}
+		selected = append(selected, c.instance)
+	}
+	return selected
+}
+
+// record notes that instances were analyzed at now with the given results,
+// and returns a copy of the updated history
+func (h *sampleHistory) record(instances []*config.InstanceInfo, results []*AnalysisResult, now time.Time) sampleState {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.state.LastAnalyzed == nil {
+		h.state.LastAnalyzed = make(map[string]time.Time)
+	}
+	if h.state.LastPriority == nil {
+		h.state.LastPriority = make(map[string]int)
+	}
+	for _, instance := range instances {
+		h.state.LastAnalyzed[instance.ID()] = now
+		delete(h.state.LastPriority, instance.ID())
+	}
+	for _, result := range results {
+		h.state.LastPriority[result.Instance.ID()] = result.Priority()
+	}
+
+	saved := sampleState{
+		Started:      h.state.Started,
+		LastAnalyzed: make(map[string]time.Time, len(h.state.LastAnalyzed)),
+		LastPriority: make(map[string]int, len(h.state.LastPriority)),
+	}
+	for id, at := range h.state.LastAnalyzed {
+		saved.LastAnalyzed[id] = at
+	}
+	for id, priority := range h.state.LastPriority {
+		saved.LastPriority[id] = priority
+	}
+	return saved
+}
@@ -0,0 +1,81 @@
+package analyzer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// applySchedule merges the schedule window covering instance at now, if any,
+// with the metric-driven decision:
+//   - a profile schedule replaces the decision with one judged by the
+//     profile's thresholds
+//   - a machine type schedule scales the instance up to that machine type
+//     unless metrics already call for at least as much, and holds back
+//     scale-downs below it until the window ends
+//
+// Failover replicas follow their primary and are never scheduled.
+func (a *Analyzer) applySchedule(instance *config.InstanceInfo, summary *config.MetricsSummary, decision *cloudsql.ScalingDecision, now time.Time) (*cloudsql.ScalingDecision, error) {
+	if instance.IsFailoverReplica || instance.UnsupportedTier {
+		return decision, nil
+	}
+	schedule, window, ok := config.ActiveSchedule(a.config.Schedules, instance, now, a.config.ScheduleLead)
+	if !ok {
+		return decision, nil
+	}
+	why := fmt.Sprintf("schedule %q until %s", schedule.Cron, config.FormatTime(window.End))
+	if schedule.Reason != "" {
+		why += " (" + schedule.Reason + ")"
+	}
+
+	if schedule.Profile != "" {
+		engine := a.scheduled[schedule.ProfileConfig]
+		if engine == nil {
+			return decision, nil
+		}
+		scheduled, err := engine.AnalyzeInstance(instance, summary)
+		if err != nil {
+			return nil, fmt.Errorf("failed to analyze instance with profile %s: %w", schedule.Profile, err)
+		}
+		scheduled.Reason = fmt.Sprintf("%s [profile %s by %s]", scheduled.Reason, schedule.Profile, why)
+		scheduled.ReasonCodes = append(scheduled.ReasonCodes, cloudsql.ReasonScheduledProfile)
+		return scheduled, nil
+	}
+
+	floor := schedule.MachineType
+	target := instance.MachineType
+	if decision.ShouldScale {
+		target = decision.RecommendedType
+	}
+	switch {
+	case atLeast(target, floor):
+		return decision, nil
+	case !atLeast(instance.MachineType, floor):
+		return a.rulesEngine.ScheduledScaleUp(instance, summary, floor,
+			fmt.Sprintf("Scheduled scale-up to %s for %s", floor, why)), nil
+	default:
+		// A scale-down below the floor waits for the window to end
+		return &cloudsql.ScalingDecision{
+			CurrentType:     instance.MachineType,
+			RecommendedType: instance.MachineType,
+			Reason:          fmt.Sprintf("Scale-down to %s held at %s or larger by %s", target, floor, why),
+			ReasonCodes:     append([]cloudsql.ReasonCode{cloudsql.ReasonScheduleHold}, decision.ReasonCodes...),
+			Metrics:         decision.Metrics,
+		}, nil
+	}
+}
+
+// atLeast reports whether machineType has at least the CPUs and memory of floor
+func atLeast(machineType, floor string) bool {
+	mt, err := config.GetMachineType(machineType)
+	if err != nil {
+		return false
+	}
+	f, err := config.GetMachineType(floor)
+	if err != nil {
+		return true
+	}
+	return mt.CPU >= f.CPU && mt.MemoryGB >= f.MemoryGB
+}
@@ -0,0 +1,97 @@
+package analyzer
+
+import (
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/rules"
+)
+
+// Decision actions compared in shadow evaluation
+const (
+	ActionScaleUp   = "scale_up"
+	ActionScaleDown = "scale_down"
+	ActionResize    = "resize" // To or from a machine type whose size is unknown
+	ActionNone      = "no_action"
+)
+
+// ShadowDifference is an instance the candidate configuration would decide
+// differently from the active one
+type ShadowDifference struct {
+	Instance        string `json:"instance"`
+	ActiveAction    string `json:"active_action"`
+	ActiveTarget    string `json:"active_target,omitempty"`
+	CandidateAction string `json:"candidate_action"`
+	CandidateTarget string `json:"candidate_target,omitempty"`
+	CandidateReason string `json:"candidate_reason"`
+}
+
+// ShadowReport compares the active configuration's decisions with those a
+// candidate configuration would have made on the same metrics
+type ShadowReport struct {
+	Evaluated   int                `json:"evaluated"`
+	Differences []ShadowDifference `json:"differences"`
+}
+
+// EvaluateShadow re-runs the scaling rules of the active and the shadow
+// configuration against the metrics already collected for results, or
+// returns nil without a shadow. The metrics window and granularity are the
+// active configuration's; only rule thresholds and behavior are compared, so
+// both decisions are taken before schedules, the decision hook, sizing
+// checks and fleet signals change them. Nothing is applied.
+func (a *Analyzer) EvaluateShadow(results *ProjectAnalysisResult) *ShadowReport {
+	if a.shadow == nil {
+		return nil
+	}
+	return evaluateShadow(results, a.engineFor, a.shadow)
+}
+
+// evaluateShadow compares, for each instance of results, the decision of the
+// engine active returns for it with candidate's
+func evaluateShadow(results *ProjectAnalysisResult, active func(*config.InstanceInfo) *rules.Engine, candidate *rules.Engine) *ShadowReport {
+	report := &ShadowReport{}
+
+	for _, result := range results.Results {
+		if result.Decision == nil || result.Summary == nil {
+			continue
+		}
+		decision, err := active(result.Instance).AnalyzeInstance(result.Instance, result.Summary)
+		if err != nil {
+			continue
+		}
+		shadow, err := candidate.AnalyzeInstance(result.Instance, result.Summary)
+		if err != nil {
+			continue
+		}
+		report.Evaluated++
+
+		activeAction, candidateAction := DecisionAction(decision), DecisionAction(shadow)
+		if activeAction == candidateAction && decision.RecommendedType == shadow.RecommendedType {
+			continue
+		}
+		report.Differences = append(report.Differences, ShadowDifference{
+			Instance:        result.Instance.Name,
+			ActiveAction:    activeAction,
+			ActiveTarget:    decision.RecommendedType,
+			CandidateAction: candidateAction,
+			CandidateTarget: shadow.RecommendedType,
+			CandidateReason: shadow.Reason,
+		})
+	}
+
+	return report
+}
+
+// DecisionAction classifies a decision as a scale-up, scale-down or no action
+func DecisionAction(decision *cloudsql.ScalingDecision) string {
+	if !decision.ShouldScale {
+		return ActionNone
+	}
+	up, err := config.IsUpscale(decision.CurrentType, decision.RecommendedType)
+	switch {
+	case err != nil:
+		return ActionResize
+	case up:
+		return ActionScaleUp
+	}
+	return ActionScaleDown
+}
@@ -0,0 +1,330 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// SnapshotVersion is the version of the snapshot file format. It changes only
+// when a snapshot written by one version cannot be diffed by another.
+const SnapshotVersion = "1"
+
+// FleetSnapshot is the state of a project's fleet at a point in time, as
+// written by the snapshot command, for diffing against a later snapshot
+type FleetSnapshot struct {
+	Version    string                     `json:"version"`
+	ProjectID  string                     `json:"project_id"`
+	TakenAt    time.Time                  `json:"taken_at"`
+	Instances  []SnapshotInstance         `json:"instances"`
+	Tiers      map[string]int             `json:"tiers"` // Analyzed instances by machine type
+	Skipped    []cloudsql.SkippedInstance `json:"skipped,omitempty"`
+	Summary    *ProjectSummary            `json:"summary"`
+	MonthlyUSD float64                    `json:"estimated_monthly_cost"` // Compute cost of the analyzed instances, on demand
+}
+
+// SnapshotInstance is one analyzed instance in a FleetSnapshot
+type SnapshotInstance struct {
+	Name             string            `json:"instance"`
+	MachineType      string            `json:"machine_type"`
+	Edition          config.Edition    `json:"edition"`
+	DatabaseVersion  string            `json:"database_version"`
+	Region           string            `json:"region"`
+	State            string            `json:"state"`
+	HighAvailability bool              `json:"high_availability"`
+	DiskSizeGB       int64             `json:"disk_size_gb"`
+	DiskType         string            `json:"disk_type,omitempty"`
+	Labels           map[string]string `json:"labels,omitempty"`
+	MonthlyUSD       float64           `json:"estimated_monthly_cost"`
+
+	Policy InstancePolicy `json:"policy"`
+
+	CPUP95         float64 `json:"cpu_p95"`
+	MemoryP95Pct   float64 `json:"memory_p95_pct"`
+	ConnectionsMax int     `json:"connections_max"`
+
+	TargetType       string                `json:"target_type,omitempty"` // Recommended machine type; empty for no action
+	ReasonCodes      []cloudsql.ReasonCode `json:"reason_codes,omitempty"`
+	EstimatedSavings float64               `json:"estimated_savings,omitempty"`
+}
+
+// Snapshot captures the analyzed fleet: each instance's machine type, policy,
+// utilization and recommendation, the machine types in use and the project
+// summary
+func (p *ProjectAnalyzer) Snapshot(results *ProjectAnalysisResult, now time.Time) *FleetSnapshot {
+	snapshot := &FleetSnapshot{
+		Version:   SnapshotVersion,
+		ProjectID: results.ProjectID,
+		TakenAt:   now.UTC(),
+		Instances: []SnapshotInstance{},
+		Tiers:     make(map[string]int),
+		Skipped:   results.Skipped,
+		Summary:   results.Summarize(0, p.config.AnalysisLatencyBudget),
+	}
+	for _, result := range results.Results {
+		instance := result.Instance
+		if instance == nil || result.Decision == nil {
+			continue
+		}
+		s := SnapshotInstance{
+			Name:             instance.Name,
+			MachineType:      instance.MachineType,
+			Edition:          instance.Edition,
+			DatabaseVersion:  instance.DatabaseVersion,
+			Region:           instance.Region,
+			State:            instance.State,
+			HighAvailability: instance.HighAvailability,
+			DiskSizeGB:       instance.DiskSizeGB,
+			DiskType:         instance.DiskType,
+			Labels:           instance.Labels,
+			MonthlyUSD:       cloudsql.EstimateMonthlyCost(instance.MachineType, instance.Region, instance.Edition, instance.DatabaseVersion),
+			Policy:           p.policyOf(instance),
+		}
+		if result.Summary != nil {
+			s.CPUP95, s.MemoryP95Pct, s.ConnectionsMax = result.Summary.CPUP95, result.Summary.MemoryP95Pct, result.Summary.ConnectionsMax
+		}
+		if result.Decision.ShouldScale {
+			s.TargetType, s.ReasonCodes, s.EstimatedSavings = result.Decision.RecommendedType, result.Decision.ReasonCodes, result.Decision.EstimatedSavings
+		}
+		snapshot.Instances = append(snapshot.Instances, s)
+		snapshot.Tiers[instance.MachineType]++
+		snapshot.MonthlyUSD += s.MonthlyUSD
+	}
+	sort.Slice(snapshot.Instances, func(i, j int) bool { return snapshot.Instances[i].Name < snapshot.Instances[j].Name })
+	return snapshot
+}
+
+// LoadSnapshot reads a snapshot written by the snapshot command
+func LoadSnapshot(path string) (*FleetSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var snapshot FleetSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", path, err)
+	}
+	if snapshot.Version != SnapshotVersion {
+		return nil, fmt.Errorf("snapshot %s has version %q, this build reads version %s", path, snapshot.Version, SnapshotVersion)
+	}
+	return &snapshot, nil
+}
+
+// SnapshotChange is a value that differs between two snapshots
+type SnapshotChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// InstanceChanges are the changes to one instance present in both snapshots
+type InstanceChanges struct {
+	Instance string           `json:"instance"`
+	Changes  []SnapshotChange `json:"changes"`
+}
+
+// TierChange is a machine type whose number of instances changed
+type TierChange struct {
+	MachineType string `json:"machine_type"`
+	Before      int    `json:"before"`
+	After       int    `json:"after"`
+}
+
+// SnapshotDiff is what changed between two snapshots of a project
+type SnapshotDiff struct {
+	ProjectID string            `json:"project_id"`
+	From      time.Time         `json:"from"`
+	To        time.Time         `json:"to"`
+	Added     []string          `json:"added,omitempty"`   // Instances only in the later snapshot
+	Removed   []string          `json:"removed,omitempty"` // Instances only in the earlier snapshot
+	Changed   []InstanceChanges `json:"changed,omitempty"`
+	Tiers     []TierChange      `json:"tiers,omitempty"`
+	Summary   []SnapshotChange  `json:"summary,omitempty"` // Changes to fleet totals
+}
+
+// Empty reports whether nothing changed between the snapshots
+func (d *SnapshotDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && len(d.Tiers) == 0 && len(d.Summary) == 0
+}
+
+// DiffSnapshots returns what changed from snapshot a to the later snapshot b.
+// Values are compared as they are printed, so utilization moves of less than
+// a tenth of a point and cost moves of less than a cent are not changes.
+func DiffSnapshots(a, b *FleetSnapshot) *SnapshotDiff {
+	diff := &SnapshotDiff{ProjectID: b.ProjectID, From: a.TakenAt, To: b.TakenAt}
+
+	before := make(map[string]SnapshotInstance, len(a.Instances))
+	for _, s := range a.Instances {
+		before[s.Name] = s
+	}
+	after := make(map[string]SnapshotInstance, len(b.Instances))
+	for _, s := range b.Instances {
+		after[s.Name] = s
+		old, ok := before[s.Name]
+		if !ok {
+			diff.Added = append(diff.Added, s.Name)
+			continue
+		}
+		if changes := instanceChanges(old, s); len(changes) > 0 {
+			diff.Changed = append(diff.Changed, InstanceChanges{Instance: s.Name, Changes: changes})
+		}
+	}
+	for _, s := range a.Instances {
+		if _, ok := after[s.Name]; !ok {
+			diff.Removed = append(diff.Removed, s.Name)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].Instance < diff.Changed[j].Instance })
+
+	var types []string
+	for machineType := range a.Tiers {
+		types = append(types, machineType)
+	}
+	for machineType := range b.Tiers {
+		if _, ok := a.Tiers[machineType]; !ok {
+			types = append(types, machineType)
+		}
+	}
+	sort.Strings(types)
+	for _, machineType := range types {
+		if a.Tiers[machineType] != b.Tiers[machineType] {
+			diff.Tiers = append(diff.Tiers, TierChange{MachineType: machineType, Before: a.Tiers[machineType], After: b.Tiers[machineType]})
+		}
+	}
+
+	changes := &changeSet{}
+	changes.add("instances", len(a.Instances), len(b.Instances))
+	changes.add("skipped", len(a.Skipped), len(b.Skipped))
+	changes.add("estimated_monthly_cost", money(a.MonthlyUSD), money(b.MonthlyUSD))
+	if a.Summary != nil && b.Summary != nil {
+		changes.add("need_scaling", a.Summary.NeedScaling, b.Summary.NeedScaling)
+		changes.add("scale_up", a.Summary.ScaleUp, b.Summary.ScaleUp)
+		changes.add("scale_down", a.Summary.ScaleDown, b.Summary.ScaleDown)
+		changes.add("total_estimated_monthly_savings", money(a.Summary.TotalSavings), money(b.Summary.TotalSavings))
+	}
+	diff.Summary = changes.changes
+	return diff
+}
+
+// instanceChanges returns the changes from a to b of the same instance
+func instanceChanges(a, b SnapshotInstance) []SnapshotChange {
+	changes := &changeSet{}
+	changes.add("machine_type", a.MachineType, b.MachineType)
+	changes.add("edition", a.Edition, b.Edition)
+	changes.add("database_version", a.DatabaseVersion, b.DatabaseVersion)
+	changes.add("state", a.State, b.State)
+	changes.add("high_availability", a.HighAvailability, b.HighAvailability)
+	changes.add("disk_size_gb", a.DiskSizeGB, b.DiskSizeGB)
+	changes.add("disk_type", a.DiskType, b.DiskType)
+	changes.add("estimated_monthly_cost", money(a.MonthlyUSD), money(b.MonthlyUSD))
+
+	changes.add("policy", a.Policy.describe(), b.Policy.describe())
+	changes.add("cpu_p95", percent(a.CPUP95), percent(b.CPUP95))
+	changes.add("memory_p95_pct", percent(a.MemoryP95Pct), percent(b.MemoryP95Pct))
+	changes.add("connections_max", a.ConnectionsMax, b.ConnectionsMax)
+	changes.add("recommendation", describeRecommendation(a), describeRecommendation(b))
+
+	var keys []string
+	for key := range a.Labels {
+		keys = append(keys, key)
+	}
+	for key := range b.Labels {
+		if _, ok := a.Labels[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		changes.add("label "+key, labelValue(a.Labels, key), labelValue(b.Labels, key))
+	}
+	return changes.changes
+}
+
+// changeSet collects the values that differ as printed
+type changeSet struct {
+	changes []SnapshotChange
+}
+
+// add records field's change when before and after differ
+func (c *changeSet) add(field string, before, after interface{}) {
+	b, a := fmt.Sprint(before), fmt.Sprint(after)
+	if b != a {
+		c.changes = append(c.changes, SnapshotChange{Field: field, Before: b, After: a})
+	}
+}
+
+// describeRecommendation summarizes an instance's recommendation on one line
+func describeRecommendation(s SnapshotInstance) string {
+	if s.TargetType == "" {
+		return "no action"
+	}
+	return "resize to " + s.TargetType
+}
+
+// labelValue returns the value of label key, or "(unset)"
+func labelValue(labels map[string]string, key string) string {
+	if v, ok := labels[key]; ok {
+		return v
+	}
+	return "(unset)"
+}
+
+// percent formats a utilization percentage as a snapshot diff compares it
+func percent(v float64) string {
+	return fmt.Sprintf("%.1f%%", v)
+}
+
+// money formats a USD amount as a snapshot diff compares it
+func money(v float64) string {
+	if v < 0 {
+		return fmt.Sprintf("-$%.2f", -v)
+	}
+	return fmt.Sprintf("$%.2f", v)
+}
+
+// Print writes the diff as human-readable text
+func (d *SnapshotDiff) Print(w io.Writer) {
+	fmt.Fprintf(w, "Fleet changes in project %s from %s to %s:\n", d.ProjectID,
+		config.InDisplayLocation(d.From).Format(time.RFC3339), config.InDisplayLocation(d.To).Format(time.RFC3339))
+	if d.Empty() {
+		fmt.Fprintln(w, "  No changes.")
+		return
+	}
+
+	if len(d.Summary) > 0 {
+		fmt.Fprintln(w, "Fleet:")
+		for _, c := range d.Summary {
+			fmt.Fprintf(w, "  %s: %s → %s\n", c.Field, c.Before, c.After)
+		}
+	}
+	if len(d.Tiers) > 0 {
+		fmt.Fprintln(w, "Machine types:")
+		for _, t := range d.Tiers {
+			fmt.Fprintf(w, "  %s: %d → %d (%+d)\n", t.MachineType, t.Before, t.After, t.After-t.Before)
+		}
+	}
+	if len(d.Added) > 0 {
+		fmt.Fprintf(w, "Added: %s\n", strings.Join(d.Added, ", "))
+	}
+	if len(d.Removed) > 0 {
+		fmt.Fprintf(w, "Removed: %s\n", strings.Join(d.Removed, ", "))
+	}
+	if len(d.Changed) > 0 {
+		fmt.Fprintf(w, "Changed: %d\n", len(d.Changed))
+		for _, i := range d.Changed {
+			fmt.Fprintf(w, "  - %s\n", i.Instance)
+			for _, c := range i.Changes {
+				fmt.Fprintf(w, "      %s: %s → %s\n", c.Field, c.Before, c.After)
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package analyzer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// StabilityWindow is the number of recent cycles a stability score covers
+const StabilityWindow = 10
+
+// Stability describes how consistently the daemon's recent cycles made an
+// instance's current recommendation, a target machine type or no action
+type Stability struct {
+	Cycles int     `json:"cycles"` // Consecutive cycles, this one included, that made it
+	Score  float64 `json:"score"`  // Fraction of the last Window cycles that made it
+	Window int     `json:"window"` // Cycles the score covers, up to StabilityWindow
+}
+
+// StabilityTracker remembers the recommendations of recent cycles by
+// instance. The zero value has no history and is ready to use. It is safe
+// for concurrent use.
+type StabilityTracker struct {
+	mu      sync.Mutex
+	history map[string][]recommendation // Recent cycles' recommendations by fully qualified instance ID, oldest first
+}
+
+// recommendation is what a cycle recommended for an instance on currentType
+type recommendation struct {
+	currentType string
+	targetType  string // Empty for no action
+}
+
+// Observe records the recommendations of a cycle's results and sets the
+// stability of each. Instances not analyzed this cycle, e.g. outside the
+// sample, keep their history; the history of a resized instance starts over,
+// since its recommendations are then made from a new machine type.
+func (t *StabilityTracker) Observe(results []*AnalysisResult) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.history == nil {
+		t.history = make(map[string][]recommendation)
+	}
+	for _, result := range results {
+		if result.Instance == nil || result.Decision == nil {
+			continue
+		}
+		current := recommendation{currentType: result.Instance.MachineType}
+		if result.Decision.ShouldScale {
+			current.targetType = result.Decision.RecommendedType
+		}
+
+		history := t.history[result.Instance.ID()]
+		if len(history) > 0 && history[len(history)-1].currentType != current.currentType {
+			history = nil
+		}
+		history = append(history, current)
+		if len(history) > StabilityWindow {
+			history = history[len(history)-StabilityWindow:]
+		}
+		t.history[result.Instance.ID()] = history
+
+		stability := &Stability{Window: len(history)}
+		agreeing := 0
+		for _, past := range history {
+			if past == current {
+				agreeing++
+			}
+		}
+		for i := len(history) - 1; i >= 0 && history[i] == current; i-- {
+			stability.Cycles++
+		}
+		stability.Score = float64(agreeing) / float64(len(history))
+		result.Stability = stability
+	}
+}
+
+// AwaitStability returns a copy of the plan in which operations whose
+// recommendation was made fewer than cycles consecutive cycles are deferred
+// to a later cycle. Emergency scale-ups at emergencyThreshold and rollbacks
+// of regressed scale-downs are not held back.
+func (p *ScalingPlan) AwaitStability(cycles int, emergencyThreshold float64) *ScalingPlan {
+	applied := &ScalingPlan{Deferred: append([]DeferredOperation(nil), p.Deferred...), ReplicaChanges: p.ReplicaChanges}
+	for _, op := range p.Operations {
+		if op.Result == nil || op.Result.Stability == nil || op.Result.Stability.Cycles >= cycles ||
+			emergency(op, emergencyThreshold) || rollback(op) {
+			applied.Operations = append(applied.Operations, op)
+			continue
+		}
+		applied.postpone(op, DeferUnstable, fmt.Sprintf("Recommendation made %d of %d consecutive cycles required (stability %.0f%%)",
+			op.Result.Stability.Cycles, cycles, op.Result.Stability.Score*100), time.Time{})
+	}
+	return applied
+}
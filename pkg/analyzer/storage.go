@@ -0,0 +1,108 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/audit"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// ErrDiskResizeUnsupported is returned by ApplyStorage when the SQL Admin
+// client cannot resize disks
+var ErrDiskResizeUnsupported = errors.New("SQL Admin client does not support disk resizes")
+
+// StorageScalingEnabled reports whether recommended disk size increases are
+// applied. Increases are irreversible, so they are only applied when
+// configured; otherwise storage decisions are advisory.
+func (a *Analyzer) StorageScalingEnabled() bool {
+	return a.config.StorageScaling
+}
+
+// StorageDeferral reports why result's disk increase should wait: a blackout
+// window, or one of freezes covering the instance at now. Like emergency
+// scale-ups, increases run through a freeze once the disk is fuller than
+// the freeze emergency threshold, since a full disk stops writes.
+func (a *Analyzer) StorageDeferral(result *AnalysisResult, freezes []config.Freeze, now time.Time) (string, bool) {
+	if w, ok := a.config.ActiveBlackout(now); ok {
+		return fmt.Sprintf("Blackout window until %s: %s", config.FormatTime(w.End), w.Reason), true
+	}
+	freeze, ok := config.ActiveFreeze(freezes, a.config.ProjectID, result.Instance, now)
+	if !ok {
+		return "", false
+	}
+	if threshold := a.config.FreezeEmergencyThreshold; threshold > 0 && result.Storage != nil && result.Storage.UsedPct >= threshold {
+		return "", false
+	}
+	return fmt.Sprintf("Scaling freeze (%s) until %s: %s", freeze.Target(), config.FormatTime(freeze.Until), freeze.Reason), true
+}
+
+// ApplyStorage grows an instance's data disk to the size decision recommends
+// and waits for the resize to complete. Resizing a disk causes no downtime.
+func (a *Analyzer) ApplyStorage(ctx context.Context, instanceName string, decision *cloudsql.StorageDecision) error {
+	done, err := a.checkout()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if decision == nil || !decision.ShouldResize {
+		return fmt.Errorf("no disk resize recommended for instance %s", instanceName)
+	}
+	resizer, ok := a.sqlClient.(DiskResizer)
+	if !ok {
+		return ErrDiskResizeUnsupported
+	}
+
+	a.logf("Growing disk of instance %s from %d GB to %d GB...\n",
+		instanceName, decision.CurrentSizeGB, decision.RecommendedSizeGB)
+
+	if a.config.DryRun {
+		a.logf("DRY RUN: No changes will be made\n")
+		return nil
+	}
+
+	// Resizes share the chain lock so they never overlap a machine type change
+	release, err := a.lockChain(ctx, instanceName)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if decision.ID == "" {
+		decision.ID = cloudsql.NewDecisionID()
+	}
+	rec := audit.Record{
+		DecisionID: decision.ID,
+		Project:    a.config.ProjectID,
+		Instance:   instanceName,
+		FromDiskGB: decision.CurrentSizeGB,
+		ToDiskGB:   decision.RecommendedSizeGB,
+		Reason:     decision.Reason,
+		ReasonCode: string(decision.ReasonCode()),
+		Labels:     cloudsql.StorageLabels(decision, time.Now()),
+	}
+	a.attribute(&rec)
+
+	opName, err := resizer.StartDiskResize(ctx, instanceName, decision.RecommendedSizeGB, rec.Labels)
+	if err == nil {
+		rec.Operation = opName
+		if err = a.sqlClient.WaitForOperation(ctx, opName); err != nil {
+			err = fmt.Errorf("disk resize operation failed: %w", err)
+		}
+	}
+	if err != nil {
+		rec.Event = audit.EventStorageResizeFailed
+		rec.Error = err.Error()
+		a.auditLog.Log(fmt.Sprintf("Failed to grow disk of instance %s to %d GB", instanceName, decision.RecommendedSizeGB), rec)
+		return fmt.Errorf("failed to resize disk: %w", err)
+	}
+
+	rec.Event = audit.EventStorageResized
+	a.auditLog.Log(fmt.Sprintf("Grew disk of instance %s from %d GB to %d GB", instanceName, decision.CurrentSizeGB, decision.RecommendedSizeGB), rec)
+	a.logf("Successfully grew disk of instance %s to %d GB\n", instanceName, decision.RecommendedSizeGB)
+	return nil
+}
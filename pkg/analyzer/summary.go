@@ -0,0 +1,166 @@
+package analyzer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// ProjectSummary is the aggregate view of a project analysis
+type ProjectSummary struct {
+	ProjectID         string                      `json:"project_id"`
+	TotalInstances    int                         `json:"total_instances"`
+	AnalyzedInstances int                         `json:"analyzed_instances"`
+	NotSampled        int                         `json:"not_sampled,omitempty"`
+	Skipped           map[cloudsql.SkipReason]int `json:"skipped,omitempty"`
+	DeletionReview    []string                    `json:"deletion_review,omitempty"` // Instances stopped longer than the review age
+	NeedScaling       int                         `json:"need_scaling"`
+	ScaleUp           int                         `json:"scale_up"`
+	ScaleDown         int                         `json:"scale_down"`
+	DowntimeExpected  int                         `json:"downtime_expected"`
+	TotalSavings      float64                     `json:"total_estimated_monthly_savings"`
+	TopActions        []ScalingOperation          `json:"top_actions"`
+	AnalysisDuration  time.Duration               `json:"analysis_duration"`
+	OverLatencyBudget int                         `json:"over_latency_budget"`
+	SlowestInstances  []InstanceTiming            `json:"slowest_instances,omitempty"`
+	GroupBy           GroupBy                     `json:"group_by,omitempty"`
+	Groups            []GroupSummary              `json:"groups,omitempty"` // Rollups by GroupBy, set with GroupResults
+
+	// Over-provisioned disks, see cloudsql.DiskAdvisory. Their savings need a
+	// migration and are not part of TotalSavings.
+	OverProvisionedDisks int     `json:"overprovisioned_disks,omitempty"`
+	ReclaimableDiskGB    int64   `json:"reclaimable_disk_gb,omitempty"`
+	DiskShrinkSavings    float64 `json:"disk_shrink_monthly_savings,omitempty"`
+
+	// Currency formats cost figures in Print; JSON amounts are always USD
+	Currency config.Currency `json:"-"`
+}
+
+// slowestInstancesShown is the number of slowest analyses included in a summary
+const slowestInstancesShown = 3
+
+// Summarize aggregates the project results, listing at most topActions of the
+// highest-priority scaling operations and the slowest analyses against the
+// per-instance latency budget
+func (p *ProjectAnalysisResult) Summarize(topActions int, latencyBudget time.Duration) *ProjectSummary {
+	summary := &ProjectSummary{
+		ProjectID:         p.ProjectID,
+		TotalInstances:    p.TotalInstances,
+		AnalyzedInstances: p.AnalyzedInstances,
+		NotSampled:        p.NotSampled,
+		Skipped:           p.SkippedByReason(),
+		DeletionReview:    p.DeletionReview(),
+		AnalysisDuration:  p.Duration,
+		OverLatencyBudget: p.OverBudget(latencyBudget),
+		SlowestInstances:  p.Slowest(slowestInstancesShown, latencyBudget),
+		Currency:          p.currency,
+	}
+
+	scalable := p.GetScalableInstances()
+	summary.NeedScaling = len(scalable)
+	for _, result := range scalable {
+		// Savings do not tell the direction: a scale-up to a cheaper family
+		// saves, and one priced at list rates may show none
+		// Resizes to or from unknown machine types count in neither direction
+		switch DecisionAction(result.Decision) {
+		case ActionScaleUp:
+			summary.ScaleUp++
+		case ActionScaleDown:
+			summary.ScaleDown++
+		}
+		if result.Decision.DowntimeExpected {
+			summary.DowntimeExpected++
+		}
+		summary.TotalSavings += result.Decision.EstimatedSavings
+	}
+	for _, result := range p.Results {
+		if d := result.DiskShrink; d != nil {
+			summary.OverProvisionedDisks++
+			summary.ReclaimableDiskGB += d.ReclaimableGB
+			summary.DiskShrinkSavings += d.EstimatedSavings
+		}
+	}
+
+	plan := p.GenerateScalingPlan()
+	summary.TopActions = plan.Operations
+	if topActions > 0 && len(summary.TopActions) > topActions {
+		summary.TopActions = summary.TopActions[:topActions]
+	}
+
+	return summary
+}
+
+// GroupResults adds rollups of p's instances by the given grouping to the summary
+func (s *ProjectSummary) GroupResults(p *ProjectAnalysisResult, by GroupBy) {
+	s.GroupBy = by
+	s.Groups = p.Groups(by)
+}
+
+// Print writes the summary as human-readable text
+func (s *ProjectSummary) Print(w io.Writer) {
+	fmt.Fprintf(w, "Project: %s\n", s.ProjectID)
+	fmt.Fprintf(w, "Instances: %d total, %d analyzed, %d need scaling (%d up, %d down)\n",
+		s.TotalInstances, s.AnalyzedInstances, s.NeedScaling, s.ScaleUp, s.ScaleDown)
+	if s.NotSampled > 0 {
+		fmt.Fprintf(w, "Not sampled this cycle: %d (analyzed in a later cycle)\n", s.NotSampled)
+	}
+	if len(s.Skipped) > 0 {
+		var parts []string
+		for _, reason := range sortedSkipReasons(s.Skipped) {
+			parts = append(parts, fmt.Sprintf("%d %s", s.Skipped[reason], reason))
+		}
+		fmt.Fprintf(w, "Skipped: %s\n", strings.Join(parts, ", "))
+	}
+	if len(s.DeletionReview) > 0 {
+		fmt.Fprintf(w, "Stopped instances to review for deletion: %s\n", strings.Join(s.DeletionReview, ", "))
+	}
+	if s.DowntimeExpected > 0 {
+		fmt.Fprintf(w, "Operations expecting downtime: %d\n", s.DowntimeExpected)
+	}
+
+	if s.TotalSavings > 0 {
+		fmt.Fprintf(w, "Total Estimated Monthly Savings: %s\n", s.Currency.Format(s.TotalSavings))
+	} else if s.TotalSavings < 0 {
+		fmt.Fprintf(w, "Total Estimated Monthly Cost Increase: %s\n", s.Currency.Format(-s.TotalSavings))
+	}
+	if s.OverProvisionedDisks > 0 {
+		fmt.Fprintf(w, "Over-provisioned disks: %d, %d GB reclaimable by migrating to smaller disks (%s/month)\n",
+			s.OverProvisionedDisks, s.ReclaimableDiskGB, s.Currency.Format(s.DiskShrinkSavings))
+	}
+
+	if len(s.Groups) > 0 {
+		fmt.Fprintf(w, "By %s (savings negative for net cost increases):\n", s.GroupBy)
+		printGroups(w, s.GroupBy, s.Groups, s.Currency)
+	}
+
+	if len(s.SlowestInstances) > 0 {
+		fmt.Fprintf(w, "Analysis took %v", s.AnalysisDuration.Round(time.Second))
+		if s.OverLatencyBudget > 0 {
+			fmt.Fprintf(w, " (%d instance(s) over the latency budget)", s.OverLatencyBudget)
+		}
+		fmt.Fprintf(w, "; slowest instances:\n")
+		for _, t := range s.SlowestInstances {
+			fmt.Fprintf(w, "  - %s: %v (instance API %v, metrics %v, %d points)\n", t.Instance,
+				t.Total.Round(time.Millisecond), t.InstanceAPI.Round(time.Millisecond),
+				t.MetricsAPI.Round(time.Millisecond), t.MetricPoints)
+		}
+	}
+
+	if len(s.TopActions) == 0 {
+		fmt.Fprintln(w, "No instances require scaling at this time.")
+		return
+	}
+
+	fmt.Fprintf(w, "Highest-priority actions:\n")
+	for _, op := range s.TopActions {
+		line := fmt.Sprintf("  - %s: %s → %s (priority %d)", op.Instance, op.CurrentType, op.TargetType, op.Priority)
+		if op.DowntimeExpected {
+			line += " [downtime]"
+		}
+		fmt.Fprintln(w, line)
+	}
+}
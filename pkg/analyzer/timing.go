@@ -0,0 +1,88 @@
+package analyzer
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// slowLogInterval is how often the over-budget analysis warning may be
+// logged; a slow Cloud Monitoring backend would otherwise log it for every
+// instance of every cycle
+const slowLogInterval = time.Minute
+
+// slowLogLimiter allows one warning per slowLogInterval and counts the rest
+type slowLogLimiter struct {
+	mu         sync.Mutex
+	last       time.Time
+	suppressed int
+}
+
+// allow reports whether a warning may be logged at now, and how many were
+// suppressed since the last one that was
+func (l *slowLogLimiter) allow(now time.Time) (bool, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.last.IsZero() && now.Sub(l.last) < slowLogInterval {
+		l.suppressed++
+		return false, 0
+	}
+	suppressed := l.suppressed
+	l.last, l.suppressed = now, 0
+	return true, suppressed
+}
+
+// AnalysisTiming records where the time analyzing one instance went
+type AnalysisTiming struct {
+	Total        time.Duration `json:"total"`
+	InstanceAPI  time.Duration `json:"instance_api"` // Cloud SQL Admin API calls
+	MetricsAPI   time.Duration `json:"metrics_api"`  // Cloud Monitoring calls, including quota waits
+	MetricPoints int           `json:"metric_points"`
+}
+
+// InstanceTiming pairs an instance with its analysis timing
+type InstanceTiming struct {
+	Instance   string `json:"instance"`
+	InstanceID string `json:"instance_id"`
+	AnalysisTiming
+	OverBudget bool `json:"over_budget"`
+}
+
+// Slowest returns the timings of the n slowest analyses, slowest first. n <= 0
+// returns all of them.
+func (p *ProjectAnalysisResult) Slowest(n int, budget time.Duration) []InstanceTiming {
+	timings := make([]InstanceTiming, 0, len(p.Results))
+	for _, r := range p.Results {
+		if r.Timing == nil {
+			continue
+		}
+		timings = append(timings, InstanceTiming{
+			Instance:       r.Instance.Name,
+			InstanceID:     r.Instance.ID(),
+			AnalysisTiming: *r.Timing,
+			OverBudget:     budget > 0 && r.Timing.Total > budget,
+		})
+	}
+
+	sort.SliceStable(timings, func(i, j int) bool {
+		return timings[i].Total > timings[j].Total
+	})
+	if n > 0 && len(timings) > n {
+		timings = timings[:n]
+	}
+	return timings
+}
+
+// OverBudget counts analyses that took longer than budget
+func (p *ProjectAnalysisResult) OverBudget(budget time.Duration) int {
+	if budget <= 0 {
+		return 0
+	}
+	count := 0
+	for _, r := range p.Results {
+		if r.Timing != nil && r.Timing.Total > budget {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,54 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+)
+
+// SetProber overrides the connectivity prober used during post-scale verification
+func (a *Analyzer) SetProber(p cloudsql.Prober) {
+	a.prober = p
+}
+
+// verifyScaling confirms a resize took effect, that settings captured in
+// before were preserved and, when probing is enabled, that the instance is
+// accepting connections again
+func (a *Analyzer) verifyScaling(ctx context.Context, instanceName string, decision *cloudsql.ScalingDecision, before *cloudsql.PreservedSettings) error {
+	instance, err := a.sqlClient.GetInstance(ctx, instanceName)
+	if err != nil {
+		return fmt.Errorf("failed to re-read instance after scaling: %w", err)
+	}
+
+	if instance.MachineType != decision.RecommendedType {
+		return fmt.Errorf("instance %s reports tier %s after scaling, expected %s",
+			instanceName, instance.MachineType, decision.RecommendedType)
+	}
+
+	if before != nil {
+		after, err := a.sqlClient.GetPreservedSettings(ctx, instanceName)
+		if err != nil {
+			return fmt.Errorf("failed to read settings after scaling: %w", err)
+		}
+		if diffs := before.Diff(after); len(diffs) > 0 {
+			return fmt.Errorf("settings not preserved: %s", strings.Join(diffs, "; "))
+		}
+		a.logf("Settings preserved on %s (zone, secondary zone, availability, flags)\n", instanceName)
+	}
+
+	if !a.config.ProbeEnabled {
+		return nil
+	}
+
+	a.logf("Probing %s for connectivity...\n", instanceName)
+	result, err := a.prober.Probe(ctx, instance)
+	if err != nil {
+		return fmt.Errorf("connectivity probe failed: %w", err)
+	}
+	a.logf("Instance %s accepting connections on %s (%d attempts, %v)\n",
+		instanceName, result.Address, result.Attempts, result.Latency.Round(1e6))
+
+	return nil
+}
@@ -0,0 +1,262 @@
+// Package approval keeps the scaling plans that wait for an operator's
+// sign-off before the daemon applies them, for organizations that cannot
+// allow fully automatic changes. Each planned change becomes a pending
+// request; it is applied only once approved, and a request that is no longer
+// planned expires, so an approval never outlives the recommendation it was
+// given for.
+package approval
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+)
+
+// retention is how long finished requests are kept in the store as a record
+// of who approved what
+const retention = 30 * 24 * time.Hour
+
+// ErrNotFound is returned when no request has the given ID
+var ErrNotFound = errors.New("approval request not found")
+
+// ErrDecided is returned when deciding a request that is no longer awaiting a decision
+var ErrDecided = errors.New("approval request already decided")
+
+// Status is where a request is in the approval workflow
+type Status string
+
+const (
+	StatusPending  Status = "pending"  // Awaiting a decision
+	StatusApproved Status = "approved" // Will be applied once the operation is eligible
+	StatusRejected Status = "rejected" // Will not be applied while it stays planned
+	StatusApplied  Status = "applied"  // Applied after approval
+	StatusFailed   Status = "failed"   // Applying failed; a new request is made if it is planned again
+	StatusExpired  Status = "expired"  // No longer planned before it was applied
+)
+
+// Open reports whether the request is still awaiting a decision or its application
+func (s Status) Open() bool {
+	return s == StatusPending || s == StatusApproved || s == StatusRejected
+}
+
+// Request is a planned machine type change awaiting or given a decision
+type Request struct {
+	ID               string                `json:"id"`
+	Project          string                `json:"project"`
+	Instance         string                `json:"instance"`
+	InstanceID       string                `json:"instance_id,omitempty"` // Fully qualified PROJECT:REGION:NAME
+	CurrentType      string                `json:"current_type"`
+	TargetType       string                `json:"target_type"`
+	Reason           string                `json:"reason"`
+	ReasonCodes      []cloudsql.ReasonCode `json:"reason_codes,omitempty"`
+	EstimatedSavings float64               `json:"estimated_savings"` // Monthly, in USD
+	DowntimeExpected bool                  `json:"downtime_expected"`
+	Status           Status                `json:"status"`
+	CreatedAt        time.Time             `json:"created_at"`
+	UpdatedAt        time.Time             `json:"updated_at"`
+	DecidedBy        string                `json:"decided_by,omitempty"`
+	DecidedAt        time.Time             `json:"decided_at,omitempty"`
+	Comment          string                `json:"comment,omitempty"`
+	Error            string                `json:"error,omitempty"` // Why applying failed
+}
+
+// key identifies the change a request is for; a request for the same
+// instance with another target, or for a namesake in another project, is a
+// different request. Names are unique within a project, so requests recorded
+// before they carried an InstanceID still match.
+func (r Request) key() string {
+	return r.Project + "/" + r.Instance + "/" + r.CurrentType + "/" + r.TargetType
+}
+
+// FileStore keeps approval requests in a JSON file. Writes replace the file
+// atomically so a crash never leaves it truncated. The daemon is the file's
+// only writer; operators decide requests through its API.
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileStore creates a store kept at path. The file is created on the first
+// Propose.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Propose records the changes planned this cycle and returns their requests
+// as stored. A change with an open request keeps it, and its decision; any
+// other gets a new pending request. Open requests for changes no longer
+// planned expire.
+func (s *FileStore) Propose(planned []Request, now time.Time) ([]Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	open := make(map[string]int)
+	for i, r := range stored {
+		if r.Status.Open() {
+			open[r.key()] = i
+		}
+	}
+
+	proposed := make([]Request, 0, len(planned))
+	kept := make(map[int]bool)
+	for _, p := range planned {
+		if i, ok := open[p.key()]; ok {
+			r := &stored[i]
+			r.Reason, r.ReasonCodes, r.EstimatedSavings, r.DowntimeExpected = p.Reason, p.ReasonCodes, p.EstimatedSavings, p.DowntimeExpected
+			r.InstanceID = p.InstanceID
+			r.UpdatedAt = now
+			kept[i] = true
+			proposed = append(proposed, *r)
+			continue
+		}
+		p.ID = cloudsql.NewDecisionID()
+		p.Status = StatusPending
+		p.CreatedAt, p.UpdatedAt = now, now
+		p.DecidedBy, p.DecidedAt, p.Comment, p.Error = "", time.Time{}, "", ""
+		stored = append(stored, p)
+		kept[len(stored)-1] = true
+		open[p.key()] = len(stored) - 1
+		proposed = append(proposed, p)
+	}
+
+	for i := range stored {
+		if stored[i].Status.Open() && !kept[i] {
+			stored[i].Status = StatusExpired
+			stored[i].UpdatedAt = now
+		}
+	}
+	return proposed, s.write(stored, now)
+}
+
+// Decide approves or rejects the pending or rejected request with id on
+// behalf of by. A rejected request may still be approved while it is planned;
+// an approved one can be rejected until it is applied.
+func (s *FileStore) Decide(id string, approve bool, by, comment string, now time.Time) (Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.read()
+	if err != nil {
+		return Request{}, err
+	}
+	for i := range stored {
+		r := &stored[i]
+		if r.ID != id {
+			continue
+		}
+		if !r.Status.Open() {
+			return *r, fmt.Errorf("%w: %s is %s", ErrDecided, id, r.Status)
+		}
+		r.Status = StatusRejected
+		if approve {
+			r.Status = StatusApproved
+		}
+		r.DecidedBy, r.DecidedAt, r.Comment = by, now, comment
+		r.UpdatedAt = now
+		return *r, s.write(stored, now)
+	}
+	return Request{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+}
+
+// Complete records the outcome of applying the approved request with id;
+// applyErr is nil if it was applied
+func (s *FileStore) Complete(id string, applyErr error, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.read()
+	if err != nil {
+		return err
+	}
+	for i := range stored {
+		r := &stored[i]
+		if r.ID != id {
+			continue
+		}
+		r.Status = StatusApplied
+		if applyErr != nil {
+			r.Status = StatusFailed
+			r.Error = applyErr.Error()
+		}
+		r.UpdatedAt = now
+		return s.write(stored, now)
+	}
+	return fmt.Errorf("%w: %s", ErrNotFound, id)
+}
+
+// List returns the stored requests, newest first
+func (s *FileStore) List() ([]Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(stored, func(i, j int) bool { return stored[i].CreatedAt.After(stored[j].CreatedAt) })
+	return stored, nil
+}
+
+func (s *FileStore) read() ([]Request, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read approval store: %w", err)
+	}
+
+	var requests []Request
+	if err := json.Unmarshal(data, &requests); err != nil {
+		return nil, fmt.Errorf("failed to parse approval store %s: %w", s.path, err)
+	}
+	return requests, nil
+}
+
+// write replaces the stored requests, dropping finished ones past retention
+func (s *FileStore) write(requests []Request, now time.Time) error {
+	kept := requests[:0]
+	for _, r := range requests {
+		if r.Status.Open() || now.Sub(r.UpdatedAt) < retention {
+			kept = append(kept, r)
+		}
+	}
+	data, err := json.MarshalIndent(kept, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode approval store: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write approval store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write approval store: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write approval store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write approval store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write approval store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package approval_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/approval"
+)
+
+var epoch = time.Date(2026, time.January, 1, 9, 0, 0, 0, time.UTC)
+
+// planned returns a planned scale-down of instance
+func planned(instance string, savings float64) approval.Request {
+	return approval.Request{
+		Project:          "shop",
+		Instance:         instance,
+		InstanceID:       "shop:europe-west1:" + instance,
+		CurrentType:      "db-custom-4-16384",
+		TargetType:       "db-custom-2-8192",
+		Reason:           "CPU P95 below the scale-down threshold",
+		EstimatedSavings: savings,
+	}
+}
+
+// newStore returns a store in a fresh directory
+func newStore(t *testing.T) *approval.FileStore {
+	t.Helper()
+	return approval.NewFileStore(filepath.Join(t.TempDir(), "approvals.json"))
+}
+
+// byInstance returns the requests keyed by instance, failing on duplicates
+func byInstance(t *testing.T, requests []approval.Request) map[string]approval.Request {
+	t.Helper()
+	m := make(map[string]approval.Request, len(requests))
+	for _, r := range requests {
+		if _, dup := m[r.Instance]; dup {
+			t.Fatalf("two requests for %s", r.Instance)
+		}
+		m[r.Instance] = r
+	}
+	return m
+}
+
+// TestPropose checks that replanned changes keep their request and decision,
+// that changes no longer planned expire and that a change planned again after
+// its request closed gets a new one
+func TestPropose(t *testing.T) {
+	s := newStore(t)
+
+	first, err := s.Propose([]approval.Request{planned("orders", 100), planned("billing", 50)}, epoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqs := byInstance(t, first)
+	for _, r := range first {
+		if r.ID == "" || r.Status != approval.StatusPending || !r.CreatedAt.Equal(epoch) {
+			t.Errorf("new request %+v, want a pending request with an ID created at %v", r, epoch)
+		}
+	}
+	if _, err := s.Decide(reqs["orders"].ID, true, "alice", "ok", epoch.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	// orders is planned again with new savings; billing is not
+	second, err := s.Propose([]approval.Request{planned("orders", 120)}, epoch.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second) != 1 {
+		t.Fatalf("Propose returned %d requests, want 1", len(second))
+	}
+	orders := second[0]
+	if orders.ID != reqs["orders"].ID || orders.Status != approval.StatusApproved || orders.DecidedBy != "alice" {
+		t.Errorf("replanned request %+v, want %s still approved by alice", orders, reqs["orders"].ID)
+	}
+	if orders.EstimatedSavings != 120 || !orders.UpdatedAt.Equal(epoch.Add(time.Hour)) {
+		t.Errorf("replanned request has savings %v updated at %v, want 120 at %v", orders.EstimatedSavings, orders.UpdatedAt, epoch.Add(time.Hour))
+	}
+
+	all, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if billing := byInstance(t, all)["billing"]; billing.Status != approval.StatusExpired {
+		t.Errorf("billing is %s after it stopped being planned, want expired", billing.Status)
+	}
+
+	// A change planned again once its request closed starts over
+	third, err := s.Propose([]approval.Request{planned("orders", 120), planned("billing", 50)}, epoch.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	billing := byInstance(t, third)["billing"]
+	if billing.ID == reqs["billing"].ID || billing.Status != approval.StatusPending {
+		t.Errorf("replanned expired change got %+v, want a new pending request", billing)
+	}
+
+	// Another target for the same instance is another change
+	retargeted := planned("orders", 150)
+	retargeted.TargetType = "db-custom-1-3840"
+	fourth, err := s.Propose([]approval.Request{retargeted}, epoch.Add(3*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fourth[0].ID == orders.ID || fourth[0].Status != approval.StatusPending {
+		t.Errorf("retargeted change got %+v, want a new pending request", fourth[0])
+	}
+}
+
+// TestDecide checks which requests can be decided and what deciding records
+func TestDecide(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, s *approval.FileStore, id string) // Brings the request to the state under test
+		approve bool
+		want    approval.Status
+		wantErr error
+	}{
+		{name: "approve pending", approve: true, want: approval.StatusApproved},
+		{name: "reject pending", approve: false, want: approval.StatusRejected},
+		{
+			name:    "approve rejected",
+			setup:   func(t *testing.T, s *approval.FileStore, id string) { decide(t, s, id, false) },
+			approve: true,
+			want:    approval.StatusApproved,
+		},
+		{
+			name:    "reject approved",
+			setup:   func(t *testing.T, s *approval.FileStore, id string) { decide(t, s, id, true) },
+			approve: false,
+			want:    approval.StatusRejected,
+		},
+		{
+			name: "approve applied",
+			setup: func(t *testing.T, s *approval.FileStore, id string) {
+				decide(t, s, id, true)
+				if err := s.Complete(id, nil, epoch); err != nil {
+					t.Fatal(err)
+				}
+			},
+			approve: true,
+			wantErr: approval.ErrDecided,
+		},
+		{
+			name: "approve expired",
+			setup: func(t *testing.T, s *approval.FileStore, id string) {
+				if _, err := s.Propose(nil, epoch); err != nil {
+					t.Fatal(err)
+				}
+			},
+			approve: true,
+			wantErr: approval.ErrDecided,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStore(t)
+			proposed, err := s.Propose([]approval.Request{planned("orders", 100)}, epoch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			id := proposed[0].ID
+			if tt.setup != nil {
+				tt.setup(t, s, id)
+			}
+
+			at := epoch.Add(time.Hour)
+			r, err := s.Decide(id, tt.approve, "bob", "looks right", at)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Decide = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Status != tt.want || r.DecidedBy != "bob" || r.Comment != "looks right" || !r.DecidedAt.Equal(at) {
+				t.Errorf("Decide = %+v, want %s by bob at %v", r, tt.want, at)
+			}
+		})
+	}
+
+	t.Run("unknown request", func(t *testing.T) {
+		_, err := newStore(t).Decide("no-such-id", true, "bob", "", epoch)
+		if !errors.Is(err, approval.ErrNotFound) {
+			t.Errorf("Decide = %v, want %v", err, approval.ErrNotFound)
+		}
+	})
+}
+
+// decide approves or rejects the request with id, failing the test on error
+func decide(t *testing.T, s *approval.FileStore, id string, approve bool) {
+	t.Helper()
+	if _, err := s.Decide(id, approve, "alice", "", epoch); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestComplete checks that applying an approved request records its outcome
+func TestComplete(t *testing.T) {
+	tests := []struct {
+		name      string
+		applyErr  error
+		want      approval.Status
+		wantError string
+	}{
+		{name: "applied", want: approval.StatusApplied},
+		{name: "failed", applyErr: errors.New("operation conflict"), want: approval.StatusFailed, wantError: "operation conflict"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStore(t)
+			proposed, err := s.Propose([]approval.Request{planned("orders", 100)}, epoch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			id := proposed[0].ID
+			decide(t, s, id, true)
+			if err := s.Complete(id, tt.applyErr, epoch.Add(time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+
+			all, err := s.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(all) != 1 || all[0].Status != tt.want || all[0].Error != tt.wantError {
+				t.Errorf("after Complete: %+v, want one %s request with error %q", all, tt.want, tt.wantError)
+			}
+		})
+	}
+
+	t.Run("unknown request", func(t *testing.T) {
+		if err := newStore(t).Complete("no-such-id", nil, epoch); !errors.Is(err, approval.ErrNotFound) {
+			t.Errorf("Complete = %v, want %v", err, approval.ErrNotFound)
+		}
+	})
+}
+
+// TestRetention checks that finished requests are dropped once past the
+// 30-day retention while open ones are kept however old
+func TestRetention(t *testing.T) {
+	s := newStore(t)
+	proposed, err := s.Propose([]approval.Request{planned("orders", 100), planned("billing", 50)}, epoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqs := byInstance(t, proposed)
+	decide(t, s, reqs["orders"].ID, true)
+	if err := s.Complete(reqs["orders"].ID, nil, epoch); err != nil {
+		t.Fatal(err)
+	}
+
+	// billing stays planned and pending throughout
+	for _, days := range []int{29, 31} {
+		if _, err := s.Propose([]approval.Request{planned("billing", 50)}, epoch.AddDate(0, 0, days)); err != nil {
+			t.Fatal(err)
+		}
+		all, err := s.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := byInstance(t, all)
+		if _, kept := got["orders"]; kept != (days < 30) {
+			t.Errorf("after %d days the applied request kept = %v, want %v", days, kept, days < 30)
+		}
+		if got["billing"].Status != approval.StatusPending {
+			t.Errorf("after %d days the open request is %+v, want it pending", days, got["billing"])
+		}
+	}
+}
+
+// TestList checks that requests are listed newest first and that a missing
+// file is an empty store but a corrupt one is an error
+func TestList(t *testing.T) {
+	s := newStore(t)
+	if all, err := s.List(); err != nil || len(all) != 0 {
+		t.Fatalf("List of a new store = %v, %v; want no requests", all, err)
+	}
+	// Each cycle plans one more change, keeping the earlier ones open
+	var plan []approval.Request
+	for i, instance := range []string{"orders", "billing", "search"} {
+		plan = append(plan, planned(instance, 10))
+		if _, err := s.Propose(plan, epoch.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	all, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for _, r := range all {
+		order = append(order, r.Instance)
+	}
+	if len(order) != 3 || order[0] != "search" || order[1] != "billing" || order[2] != "orders" {
+		t.Errorf("List order = %v, want [search billing orders]", order)
+	}
+
+	path := filepath.Join(t.TempDir(), "approvals.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := approval.NewFileStore(path).List(); err == nil {
+		t.Error("List of a corrupt store succeeded, want an error")
+	}
+}
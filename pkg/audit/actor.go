@@ -0,0 +1,25 @@
+package audit
+
+import "os"
+
+// Actor modes, telling the daemon's changes apart from ad-hoc CLI runs
+const (
+	ModeDaemon = "daemon"
+	ModeCLI    = "cli"
+)
+
+// Actor identifies who applied a change: the principal the autoscaler's
+// credentials act as, the host it ran on and the command line it ran with
+type Actor struct {
+	Principal string   `json:"principal,omitempty"` // Email of the Application Default Credentials principal, empty if unknown
+	Mode      string   `json:"mode"`                // ModeDaemon or ModeCLI
+	Host      string   `json:"host,omitempty"`
+	Args      []string `json:"args,omitempty"` // Command-line arguments, with secret values redacted
+}
+
+// NewActor returns the actor of this process running in mode as principal,
+// on the local hostname
+func NewActor(principal, mode string, args []string) *Actor {
+	host, _ := os.Hostname()
+	return &Actor{Principal: principal, Mode: mode, Host: host, Args: args}
+}
@@ -0,0 +1,119 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Event types recorded in the audit log
+const (
+	EventScalingApplied = "scaling_applied"
+	EventScalingFailed  = "scaling_failed"
+
+	EventVerificationFailed = "verification_failed"
+
+	EventOperationResumed = "operation_resumed"
+
+	EventDuplicateSkipped = "duplicate_skipped"
+
+	EventStorageResized      = "storage_resized"
+	EventStorageResizeFailed = "storage_resize_failed"
+
+	EventReplicaCreated      = "replica_created"
+	EventReplicaDeleted      = "replica_deleted"
+	EventReplicaChangeFailed = "replica_change_failed"
+)
+
+// Record is a single audit entry describing an action taken against an instance
+type Record struct {
+	Event      string            `json:"event"`
+	DecisionID string            `json:"decision_id,omitempty"`
+	Project    string            `json:"project"`
+	Instance   string            `json:"instance"`
+	InstanceID string            `json:"instance_id,omitempty"` // Fully qualified PROJECT:REGION:NAME, when known
+	Primary    string            `json:"primary,omitempty"`     // Primary of a read replica that was created or deleted
+	FromTier   string            `json:"from_tier,omitempty"`
+	ToTier     string            `json:"to_tier,omitempty"`
+	FromDiskGB int64             `json:"from_disk_gb,omitempty"`
+	ToDiskGB   int64             `json:"to_disk_gb,omitempty"`
+	Operation  string            `json:"operation,omitempty"`
+	Reason     string            `json:"reason,omitempty"`
+	ReasonCode string            `json:"reason_code,omitempty"` // Primary machine-readable reason, see cloudsql.ReasonCode
+	Error      string            `json:"error,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Actor      *Actor            `json:"actor,omitempty"` // Who applied the change, when known
+	Time       time.Time         `json:"time"`
+}
+
+// entry is the Cloud Logging structured log envelope for a Record.
+// See https://cloud.google.com/logging/docs/structured-logging
+type entry struct {
+	Severity string            `json:"severity"`
+	Message  string            `json:"message"`
+	Labels   map[string]string `json:"logging.googleapis.com/labels,omitempty"`
+	Record
+}
+
+// Logger writes audit records as structured JSON lines that Cloud Logging
+// ingests from container stdout/stderr
+type Logger struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewLogger creates an audit logger writing to w
+func NewLogger(w io.Writer) *Logger {
+	return &Logger{out: w}
+}
+
+// DefaultLogger returns an audit logger writing to stderr
+func DefaultLogger() *Logger {
+	return NewLogger(os.Stderr)
+}
+
+// Log writes a record with the given message
+func (l *Logger) Log(message string, rec Record) error {
+	if l == nil || l.out == nil {
+		return nil
+	}
+	if rec.Time.IsZero() {
+		rec.Time = time.Now().UTC()
+	}
+
+	severity := "NOTICE"
+	if rec.Error != "" {
+		severity = "ERROR"
+	}
+
+	labels := map[string]string{
+		"component": "cloudsql-autoscaler",
+		"instance":  rec.Instance,
+	}
+	if rec.DecisionID != "" {
+		labels["decision_id"] = rec.DecisionID
+	}
+	if rec.Actor != nil {
+		labels["mode"] = rec.Actor.Mode
+		if rec.Actor.Principal != "" {
+			labels["principal"] = rec.Actor.Principal
+		}
+	}
+
+	data, err := json.Marshal(entry{
+		Severity: severity,
+		Message:  message,
+		Labels:   labels,
+		Record:   rec,
+	})
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.out.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,91 @@
+// Package cloudevents wraps the events the autoscaler publishes in the
+// CloudEvents 1.0 envelope, so platforms routing infrastructure events by
+// their type, source and subject can route these the same way. Events are
+// sent in structured mode over HTTP (the whole event as the JSON body) and in
+// binary mode over Pub/Sub (the attributes as ce- message attributes and the
+// data as the message body), following the CloudEvents protocol bindings.
+package cloudevents
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+const (
+	// SpecVersion is the version of the CloudEvents specification events follow
+	SpecVersion = "1.0"
+
+	// ContentType is the content type of an event in structured mode
+	ContentType = "application/cloudevents+json"
+
+	// DataContentType is the content type of every event's data
+	DataContentType = "application/json"
+
+	// TypePrefix is the reverse-DNS prefix of every event type
+	TypePrefix = "io.github.fraser-isbester.cloudsql-autoscaler."
+)
+
+// Event types. The version suffix changes only when the data of a type
+// changes incompatibly.
+const (
+	TypeAnalysisCompleted  = TypePrefix + "analysis.completed.v1"      // A daemon cycle analyzed the fleet
+	TypeScalingRecommended = TypePrefix + "scaling.recommended.v1"     // New scaling recommendations
+	TypeScalingPlanned     = TypePrefix + "scaling.planned.v1"         // A resize is planned this cycle
+	TypeScalingApplied     = TypePrefix + "scaling.applied.v1"         // A resize was applied
+	TypeScalingFailed      = TypePrefix + "scaling.failed.v1"          // A resize failed
+	TypeCycleFailed        = TypePrefix + "cycle.failed.v1"            // A daemon cycle could not analyze the fleet
+	TypeInstanceOverloaded = TypePrefix + "instance.overloaded.v1"     // Instances stayed above the scale-up thresholds
+	TypeOverloadEnded      = TypePrefix + "instance.overload_ended.v1" // Overloaded instances fell back below the thresholds
+)
+
+// Event is a CloudEvent in its JSON format
+type Event struct {
+	SpecVersion     string      `json:"specversion"`
+	ID              string      `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	Subject         string      `json:"subject,omitempty"` // The instance the event concerns, if one
+	Time            time.Time   `json:"time"`
+	DataContentType string      `json:"datacontenttype"`
+	Data            interface{} `json:"data,omitempty"`
+}
+
+// New creates an event of eventType about subject, an instance name or empty,
+// in project at t. Its ID is random, so it is unique across restarts.
+func New(eventType, project, subject string, t time.Time, data interface{}) Event {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return Event{
+		SpecVersion:     SpecVersion,
+		ID:              hex.EncodeToString(id),
+		Source:          Source(project),
+		Type:            eventType,
+		Subject:         subject,
+		Time:            t.UTC(),
+		DataContentType: DataContentType,
+		Data:            data,
+	}
+}
+
+// Source returns the source of the events about project's instances
+func Source(project string) string {
+	return "//cloudsql-autoscaler/projects/" + project
+}
+
+// Attributes returns the event's attributes as Pub/Sub message attributes,
+// for binary mode; the data content type is the content-type attribute
+func (e Event) Attributes() map[string]string {
+	attributes := map[string]string{
+		"ce-specversion": e.SpecVersion,
+		"ce-id":          e.ID,
+		"ce-source":      e.Source,
+		"ce-type":        e.Type,
+		"ce-time":        e.Time.Format(time.RFC3339Nano),
+		"content-type":   e.DataContentType,
+	}
+	if e.Subject != "" {
+		attributes["ce-subject"] = e.Subject
+	}
+	return attributes
+}
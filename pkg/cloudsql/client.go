@@ -3,6 +3,8 @@ package cloudsql
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"google.golang.org/api/option"
@@ -15,6 +17,7 @@ import (
 type Client struct {
 	Service   *sqladmin.Service // Exported for raw API access
 	projectID string
+	tiers     tierCache
 }
 
 // NewClient creates a new Cloud SQL client
@@ -34,13 +37,17 @@ func NewClient(ctx context.Context, projectID string, opts ...option.ClientOptio
 func (c *Client) GetInstance(ctx context.Context, instanceName string) (*config.InstanceInfo, error) {
 	instance, err := c.Service.Instances.Get(c.projectID, instanceName).Context(ctx).Do()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get instance %s: %w", instanceName, err)
+		return nil, c.classifyAPIError("get instance", instanceName, err)
 	}
 
-	// Parse machine type to get CPU and memory
+	// Parse machine type to get CPU and memory. Tiers the catalog does not
+	// know are still reported, with resources read from the API, but the
+	// autoscaler only advises on them.
+	unsupportedTier := false
 	machineType, err := config.GetMachineType(instance.Settings.Tier)
 	if err != nil {
-		return nil, fmt.Errorf("unknown machine type %s: %w", instance.Settings.Tier, err)
+		unsupportedTier = true
+		machineType, _ = c.resolveUnknownTier(ctx, instance.Settings.Tier)
 	}
 
 	// Determine edition from settings
@@ -60,75 +67,308 @@ func (c *Client) GetInstance(ctx context.Context, instanceName string) (*config.
 		MachineType:      instance.Settings.Tier,
 		Edition:          edition,
 		State:            instance.State,
+		ActivationPolicy: instance.Settings.ActivationPolicy,
 		LastScaledTime:   lastScaledTime,
 		CurrentCPU:       machineType.CPU,
 		CurrentMemoryGB:  machineType.MemoryGB,
 		BackupEnabled:    instance.Settings.BackupConfiguration.Enabled,
 		HighAvailability: instance.Settings.AvailabilityType == "REGIONAL",
 		Region:           instance.Region,
+		Labels:           instance.Settings.UserLabels,
+		UnsupportedTier:  unsupportedTier,
 	}
 
 	// Extract zone from gceZone if available
 	if instance.GceZone != "" {
 		info.Zone = instance.GceZone
 	}
+	if dc := instance.Settings.DataCacheConfig; dc != nil && edition == config.EditionEnterprisePlus {
+		info.DataCacheEnabled = dc.DataCacheEnabled
+	}
+
+	populateReplication(info, instance)
+
+	if instance.SecondaryGceZone != "" {
+		info.SecondaryZone = instance.SecondaryGceZone
+	}
+
+	if len(instance.IpAddresses) > 0 {
+		info.IPAddresses = make(map[string]string, len(instance.IpAddresses))
+		for _, addr := range instance.IpAddresses {
+			info.IPAddresses[addr.Type] = addr.IpAddress
+		}
+	}
+
+	if info.Stopped() {
+		info.StoppedAt = c.stoppedAt(ctx, instance.Name)
+	}
+
+	populateStorage(info, instance.Settings)
+	populateMaintenanceWindow(info, instance.Settings)
+	populatePolicy(info)
 
 	// Get max connections from database flags if set
-	for _, flag := range instance.Settings.DatabaseFlags {
-		if flag.Name == "max_connections" {
-			// Parse max connections value
-			// Note: Proper parsing would be needed here
+	if len(instance.Settings.DatabaseFlags) > 0 {
+		info.DatabaseFlags = make(map[string]string, len(instance.Settings.DatabaseFlags))
+		for _, flag := range instance.Settings.DatabaseFlags {
+			info.DatabaseFlags[flag.Name] = flag.Value
+		}
+		if n, err := strconv.Atoi(info.DatabaseFlags["max_connections"]); err == nil {
+			info.MaxConnections = n
 		}
 	}
 
 	return info, nil
 }
 
-// ListInstances lists all Cloud SQL instances in the project
-func (c *Client) ListInstances(ctx context.Context) ([]*config.InstanceInfo, error) {
+// stoppedAt returns when a stopped instance was stopped: the end of its most
+// recent completed update, which set its activation policy to NEVER unless it
+// was updated again since, so how long it has been stopped is never
+// overstated. It returns the zero time when no update is in the recent
+// operations.
+func (c *Client) stoppedAt(ctx context.Context, instanceName string) time.Time {
+	operations, err := c.GetRecentOperations(ctx, instanceName, 50)
+	if err != nil {
+		return time.Time{}
+	}
+	for _, op := range operations {
+		if op.OperationType != "UPDATE" || op.Status != "DONE" {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, op.EndTime); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// populateStorage fills the storage and pricing settings of info
+func populateStorage(info *config.InstanceInfo, settings *sqladmin.Settings) {
+	info.DiskSizeGB = settings.DataDiskSizeGb
+	info.DiskType = settings.DataDiskType
+	info.StorageAutoResize = settings.StorageAutoResize != nil && *settings.StorageAutoResize
+	info.StorageAutoResizeLimitGB = settings.StorageAutoResizeLimit
+	info.PricingPlan = settings.PricingPlan
+}
+
+// populateMaintenanceWindow fills the maintenance window of info. The API
+// numbers days 1 (Monday) to 7 (Sunday); day 0 means no preference is set.
+func populateMaintenanceWindow(info *config.InstanceInfo, settings *sqladmin.Settings) {
+	mw := settings.MaintenanceWindow
+	if mw == nil || mw.Day < 1 || mw.Day > 7 {
+		return
+	}
+	info.MaintenanceWindow = &config.MaintenanceWindow{Day: time.Weekday(mw.Day % 7), Hour: int(mw.Hour)}
+}
+
+// populateReplication fills the replication topology of info, identifying
+// failover and DR replicas on both sides of the relationship
+func populateReplication(info *config.InstanceInfo, instance *sqladmin.DatabaseInstance) {
+	info.InstanceType = instance.InstanceType
+	info.PrimaryInstance = shortInstanceName(instance.MasterInstanceName)
+	for _, name := range instance.ReplicaNames {
+		info.Replicas = append(info.Replicas, shortInstanceName(name))
+	}
+
+	// Legacy MySQL failover replica
+	if instance.FailoverReplica != nil && instance.FailoverReplica.Name != "" {
+		info.FailoverReplicas = append(info.FailoverReplicas, shortInstanceName(instance.FailoverReplica.Name))
+	}
+	if instance.ReplicaConfiguration != nil && instance.ReplicaConfiguration.FailoverTarget {
+		info.IsFailoverReplica = true
+	}
+
+	// Enterprise Plus disaster recovery replica
+	if rc := instance.ReplicationCluster; rc != nil {
+		if rc.FailoverDrReplicaName != "" {
+			info.FailoverReplicas = append(info.FailoverReplicas, shortInstanceName(rc.FailoverDrReplicaName))
+		}
+		if rc.DrReplica {
+			info.IsFailoverReplica = true
+		}
+	}
+}
+
+// shortInstanceName strips any "project:" or resource path prefix from an instance reference
+func shortInstanceName(name string) string {
+	if i := strings.LastIndexAny(name, ":/"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// ListInstances lists all Cloud SQL instances in the project. Instances whose
+// details cannot be loaded are returned as skipped with the reason.
+func (c *Client) ListInstances(ctx context.Context) ([]*config.InstanceInfo, []SkippedInstance, error) {
 	var instances []*config.InstanceInfo
+	var skipped []SkippedInstance
 
-	resp, err := c.Service.Instances.List(c.projectID).Context(ctx).Do()
+	// List pages name at most 500 instances; following nextPageToken reaches the rest
+	var names []string
+	err := c.Service.Instances.List(c.projectID).Context(ctx).Pages(ctx, func(resp *sqladmin.InstancesListResponse) error {
+		for _, instance := range resp.Items {
+			names = append(names, instance.Name)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list instances: %w", err)
+		return nil, nil, c.classifyAPIError("list instances", "", err)
 	}
 
-	for _, instance := range resp.Items {
-		info, err := c.GetInstance(ctx, instance.Name)
+	for _, name := range names {
+		info, err := c.GetInstance(ctx, name)
 		if err != nil {
-			// Log error but continue with other instances
-			fmt.Printf("Warning: failed to get details for instance %s: %v\n", instance.Name, err)
+			skipped = append(skipped, NewSkippedInstance(name, err))
 			continue
 		}
 		instances = append(instances, info)
 	}
 
-	return instances, nil
+	return instances, skipped, nil
 }
 
-// UpdateMachineType updates the machine type of an instance
-func (c *Client) UpdateMachineType(ctx context.Context, instanceName string, newMachineType string) error {
-	// Get current instance to preserve settings
+// UpdateMachineType updates the machine type of an instance, merging labels
+// into the instance's user labels, and waits for the change to complete. It
+// returns the name of the operation.
+func (c *Client) UpdateMachineType(ctx context.Context, instanceName string, newMachineType string, labels map[string]string) (string, error) {
+	opName, err := c.StartMachineTypeUpdate(ctx, instanceName, newMachineType, labels)
+	if err != nil {
+		return "", err
+	}
+	if err := c.WaitForOperation(ctx, opName); err != nil {
+		return opName, fmt.Errorf("machine type update operation failed: %w", err)
+	}
+	return opName, nil
+}
+
+// StartMachineTypeUpdate starts a machine type change without waiting for it
+// and returns the name of the operation.
+//
+// The change is sent as a minimal Patch containing only the tier and labels so
+// that zone placement, flags and other settings are never clobbered by a
+// stale full-instance Update.
+func (c *Client) StartMachineTypeUpdate(ctx context.Context, instanceName string, newMachineType string, labels map[string]string) (string, error) {
+	// Get current instance for its settings version and existing labels
 	instance, err := c.Service.Instances.Get(c.projectID, instanceName).Context(ctx).Do()
 	if err != nil {
-		return fmt.Errorf("failed to get instance for update: %w", err)
+		return "", c.classifyAPIError("get instance for update", instanceName, err)
 	}
 
-	// Create patch request with new machine type
-	instance.Settings.Tier = newMachineType
+	patch := &sqladmin.DatabaseInstance{
+		Settings: &sqladmin.Settings{
+			Tier:            newMachineType,
+			SettingsVersion: instance.Settings.SettingsVersion,
+		},
+	}
+	patch.Settings.UserLabels = mergeLabels(instance.Settings.UserLabels, labels)
 
 	// Perform the update
-	operation, err := c.Service.Instances.Update(c.projectID, instanceName, instance).Context(ctx).Do()
+	operation, err := c.Service.Instances.Patch(c.projectID, instanceName, patch).Context(ctx).Do()
+	if err != nil {
+		return "", c.classifyAPIError("update machine type to "+newMachineType, instanceName, err)
+	}
+
+	return operation.Name, nil
+}
+
+// StartDiskResize starts growing an instance's data disk to sizeGB without
+// waiting for it and returns the name of the operation. Like
+// StartMachineTypeUpdate it sends a minimal Patch. Disk size can never be
+// decreased, so sizes at or below the current size are rejected.
+func (c *Client) StartDiskResize(ctx context.Context, instanceName string, sizeGB int64, labels map[string]string) (string, error) {
+	instance, err := c.Service.Instances.Get(c.projectID, instanceName).Context(ctx).Do()
+	if err != nil {
+		return "", c.classifyAPIError("get instance for disk resize", instanceName, err)
+	}
+	if sizeGB <= instance.Settings.DataDiskSizeGb {
+		return "", fmt.Errorf("cannot resize disk of %s to %d GB: disk is already %d GB and cannot shrink",
+			instanceName, sizeGB, instance.Settings.DataDiskSizeGb)
+	}
+
+	patch := &sqladmin.DatabaseInstance{
+		Settings: &sqladmin.Settings{
+			DataDiskSizeGb:  sizeGB,
+			SettingsVersion: instance.Settings.SettingsVersion,
+			UserLabels:      mergeLabels(instance.Settings.UserLabels, labels),
+		},
+	}
+
+	operation, err := c.Service.Instances.Patch(c.projectID, instanceName, patch).Context(ctx).Do()
+	if err != nil {
+		return "", c.classifyAPIError(fmt.Sprintf("resize disk to %d GB", sizeGB), instanceName, err)
+	}
+
+	return operation.Name, nil
+}
+
+// StartReadReplicaCreate starts creating a read replica of primary named
+// name on machine type tier, without waiting for it, and returns the name of
+// the operation. The replica is placed in the primary's region with the
+// primary's edition and disk, which a replica may not be smaller than.
+func (c *Client) StartReadReplicaCreate(ctx context.Context, primary, name, tier string, labels map[string]string) (string, error) {
+	instance, err := c.Service.Instances.Get(c.projectID, primary).Context(ctx).Do()
+	if err != nil {
+		return "", c.classifyAPIError("get primary for replica create", primary, err)
+	}
+
+	replica := &sqladmin.DatabaseInstance{
+		Name:               name,
+		MasterInstanceName: primary,
+		Region:             instance.Region,
+		DatabaseVersion:    instance.DatabaseVersion,
+		Settings: &sqladmin.Settings{
+			Tier:           tier,
+			Edition:        instance.Settings.Edition,
+			DataDiskSizeGb: instance.Settings.DataDiskSizeGb,
+			DataDiskType:   instance.Settings.DataDiskType,
+			UserLabels:     labels,
+		},
+	}
+
+	operation, err := c.Service.Instances.Insert(c.projectID, replica).Context(ctx).Do()
+	if err != nil {
+		return "", c.classifyAPIError("create read replica "+name, primary, err)
+	}
+
+	return operation.Name, nil
+}
+
+// StartReadReplicaDelete starts deleting a read replica without waiting for
+// it and returns the name of the operation. It refuses anything that is not
+// a read replica, so a primary or failover target is never deleted.
+func (c *Client) StartReadReplicaDelete(ctx context.Context, name string) (string, error) {
+	instance, err := c.Service.Instances.Get(c.projectID, name).Context(ctx).Do()
 	if err != nil {
-		return fmt.Errorf("failed to update instance machine type: %w", err)
+		return "", c.classifyAPIError("get instance for replica delete", name, err)
+	}
+	info := &config.InstanceInfo{}
+	populateReplication(info, instance)
+	if !IsReadReplica(info) {
+		return "", fmt.Errorf("refusing to delete %s: not a read replica", name)
 	}
 
-	// Wait for operation to complete
-	if err := c.waitForOperation(ctx, operation); err != nil {
-		return fmt.Errorf("machine type update operation failed: %w", err)
+	operation, err := c.Service.Instances.Delete(c.projectID, name).Context(ctx).Do()
+	if err != nil {
+		return "", c.classifyAPIError("delete read replica", name, err)
 	}
 
-	return nil
+	return operation.Name, nil
+}
+
+// mergeLabels returns existing with labels added, or nil when there are no
+// labels to add so a Patch leaves the instance's labels alone
+func mergeLabels(existing, labels map[string]string) map[string]string {
+	if len(labels) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(existing)+len(labels))
+	for k, v := range existing {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return merged
 }
 
 // GetRecentOperations retrieves recent operations for an instance
@@ -138,7 +378,7 @@ func (c *Client) GetRecentOperations(ctx context.Context, instanceName string, l
 		Context(ctx).
 		Do()
 	if err != nil {
-		return nil, fmt.Errorf("failed to list operations: %w", err)
+		return nil, c.classifyAPIError("list operations", instanceName, err)
 	}
 
 	// Filter operations for the target instance
@@ -152,17 +392,19 @@ func (c *Client) GetRecentOperations(ctx context.Context, instanceName string, l
 	return filteredOps, nil
 }
 
-// waitForOperation waits for a Cloud SQL operation to complete
-func (c *Client) waitForOperation(ctx context.Context, operation *sqladmin.Operation) error {
+// WaitForOperation waits for the named Cloud SQL operation to complete. An
+// operation that completed unsuccessfully returns an error wrapping
+// ErrOperationFailed; any other error means the outcome is still unknown.
+func (c *Client) WaitForOperation(ctx context.Context, operationName string) error {
 	for {
-		op, err := c.Service.Operations.Get(c.projectID, operation.Name).Context(ctx).Do()
+		op, err := c.Service.Operations.Get(c.projectID, operationName).Context(ctx).Do()
 		if err != nil {
-			return fmt.Errorf("failed to get operation status: %w", err)
+			return c.classifyAPIError("get status of operation "+operationName, "", err)
 		}
 
 		if op.Status == "DONE" {
 			if op.Error != nil {
-				return fmt.Errorf("operation failed: %v", op.Error)
+				return fmt.Errorf("%w: %s", ErrOperationFailed, operationErrorMessage(op.Error))
 			}
 			return nil
 		}
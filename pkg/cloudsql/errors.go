@@ -0,0 +1,143 @@
+package cloudsql
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+	sqladmin "google.golang.org/api/sqladmin/v1"
+)
+
+// Sentinel errors for classified Cloud SQL Admin API failures. Use errors.Is
+// to test for them and Hint for the remediation.
+var (
+	ErrPermissionDenied  = errors.New("permission denied")
+	ErrQuotaExceeded     = errors.New("quota exceeded")
+	ErrInvalidTier       = errors.New("invalid tier")
+	ErrOperationConflict = errors.New("operation conflict")
+	ErrInstanceNotFound  = errors.New("instance not found")
+
+	// ErrOperationFailed is returned for an operation that ran to completion
+	// but reported an error
+	ErrOperationFailed = errors.New("operation failed")
+)
+
+// APIError is a classified Cloud SQL Admin API failure
+type APIError struct {
+	Kind     error  // One of the sentinel errors above
+	Op       string // What was being attempted, e.g. "get instance"
+	Instance string
+	Hint     string // Remediation for the operator
+	Err      error  // Underlying googleapi error
+}
+
+func (e *APIError) Error() string {
+	target := ""
+	if e.Instance != "" {
+		target = " " + e.Instance
+	}
+	return fmt.Sprintf("failed to %s%s: %v: %s", e.Op, target, e.Kind, apiMessage(e.Err))
+}
+
+func (e *APIError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// Hint returns the remediation hint attached to err, if any
+func Hint(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Hint
+	}
+	return ""
+}
+
+// classifyAPIError wraps a googleapi error from op on instance into an
+// APIError with a remediation hint. Errors it cannot classify are wrapped
+// with the op and instance only.
+func (c *Client) classifyAPIError(op, instance string, err error) error {
+	target := op
+	if instance != "" {
+		target += " " + instance
+	}
+	var gErr *googleapi.Error
+	if !errors.As(err, &gErr) {
+		return fmt.Errorf("failed to %s: %w", target, err)
+	}
+
+	apiErr := &APIError{Op: op, Instance: instance, Err: err}
+	switch {
+	case gErr.Code == http.StatusTooManyRequests || hasReason(gErr, "rateLimitExceeded", "quotaExceeded", "userRateLimitExceeded"):
+		apiErr.Kind = ErrQuotaExceeded
+		apiErr.Hint = fmt.Sprintf("The Cloud SQL Admin API quota for project %s is exhausted. Raise --interval, "+
+			"analyze fewer instances per cycle with --sample, or request a higher \"Queries per minute per user\" "+
+			"quota for sqladmin.googleapis.com.", c.projectID)
+	case gErr.Code == http.StatusForbidden || gErr.Code == http.StatusUnauthorized:
+		apiErr.Kind = ErrPermissionDenied
+		apiErr.Hint = fmt.Sprintf("Grant the autoscaler's service account roles/cloudsql.viewer on project %s to analyze, "+
+			"or roles/cloudsql.editor to scale. Check which identity is in use with "+
+			"`gcloud auth application-default print-access-token`.", c.projectID)
+	case gErr.Code == http.StatusConflict || hasReason(gErr, "operationInProgress"):
+		apiErr.Kind = ErrOperationConflict
+		apiErr.Hint = "Another operation (backup, maintenance or an earlier resize) is running on the instance. " +
+			"It will be retried next cycle; check `gcloud sql operations list --instance` for what is running."
+	case gErr.Code == http.StatusNotFound:
+		apiErr.Kind = ErrInstanceNotFound
+		apiErr.Hint = fmt.Sprintf("The instance does not exist in project %s. Check the name and --project.", c.projectID)
+	case gErr.Code == http.StatusBadRequest && mentionsTier(gErr):
+		apiErr.Kind = ErrInvalidTier
+		apiErr.Hint = "The target machine type is not offered for this instance's region, edition or engine. " +
+			"List valid tiers with `gcloud sql tiers list`."
+	default:
+		return fmt.Errorf("failed to %s: %w", target, err)
+	}
+	return apiErr
+}
+
+// hasReason reports whether any error item of e carries one of reasons
+func hasReason(e *googleapi.Error, reasons ...string) bool {
+	for _, item := range e.Errors {
+		for _, reason := range reasons {
+			if item.Reason == reason {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mentionsTier reports whether a bad request concerns the machine type
+func mentionsTier(e *googleapi.Error) bool {
+	if strings.Contains(strings.ToLower(e.Message), "tier") {
+		return true
+	}
+	for _, item := range e.Errors {
+		if strings.Contains(strings.ToLower(item.Message), "tier") || item.Reason == "invalidTier" {
+			return true
+		}
+	}
+	return false
+}
+
+// operationErrorMessage joins the errors reported by a completed operation
+func operationErrorMessage(e *sqladmin.OperationErrors) string {
+	var parts []string
+	for _, item := range e.Errors {
+		parts = append(parts, fmt.Sprintf("%s (%s)", item.Message, item.Code))
+	}
+	if len(parts) == 0 {
+		return "unknown error"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// apiMessage returns the human-readable part of an API error
+func apiMessage(err error) string {
+	var gErr *googleapi.Error
+	if errors.As(err, &gErr) && gErr.Message != "" {
+		return gErr.Message
+	}
+	return err.Error()
+}
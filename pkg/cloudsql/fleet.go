@@ -0,0 +1,203 @@
+package cloudsql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/option"
+	recommender "google.golang.org/api/recommender/v1"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// fleetRecommenders and fleetInsightTypes are the Recommender API sources
+// Database Center surfaces for Cloud SQL instances
+var (
+	fleetRecommenders = []string{
+		"google.cloudsql.instance.IdleRecommender",
+		"google.cloudsql.instance.OverprovisionedRecommender",
+		"google.cloudsql.instance.OutOfDiskRecommender",
+		"google.cloudsql.instance.PerformanceRecommender",
+		"google.cloudsql.instance.ReliabilityRecommender",
+	}
+	fleetInsightTypes = []string{
+		"google.cloudsql.instance.PerformanceInsight",
+		"google.cloudsql.instance.ReliabilityInsight",
+	}
+)
+
+// fleetCacheTTL is how long a region's signals are reused. Recommender
+// refreshes them about daily, so an hour keeps daemon cycles cheap.
+const fleetCacheTTL = time.Hour
+
+// fleetFailureTTL is how long a region whose signals could not be listed is
+// left alone: long enough that one analysis cycle asks once rather than
+// once per instance, short enough that the next cycle tries again
+const fleetFailureTTL = 5 * time.Minute
+
+// Fleet signal kinds
+const (
+	FleetRecommendation = "recommendation"
+	FleetInsight        = "insight"
+)
+
+// FleetSignal is a recommendation or health insight Google's fleet tooling
+// holds for an instance
+type FleetSignal struct {
+	Kind        string `json:"kind"` // FleetRecommendation or FleetInsight
+	Type        string `json:"type"` // Recommender or insight type
+	Subtype     string `json:"subtype,omitempty"`
+	Description string `json:"description"`
+	Priority    string `json:"priority,omitempty"`    // P1 (highest) to P4 for recommendations, severity for insights
+	TargetTier  string `json:"target_tier,omitempty"` // Machine type a recommendation resizes the instance to
+	Name        string `json:"name"`                  // Resource name of the recommendation or insight
+}
+
+// FleetClient reads the active Recommender API recommendations and insights
+// for a project's Cloud SQL instances: the signals Database Center shows in
+// its fleet view. It is safe for concurrent use.
+type FleetClient struct {
+	service   *recommender.Service
+	projectID string
+
+	mu    sync.Mutex
+	cache map[string]fleetRegion // By region
+}
+
+// fleetRegion is a region's signals, by instance name, or why they could not
+// be listed
+type fleetRegion struct {
+	signals   map[string][]FleetSignal
+	err       error
+	fetchedAt time.Time
+}
+
+// fresh reports whether the region's signals or failure may still be reused
+func (r fleetRegion) fresh(now time.Time) bool {
+	ttl := fleetCacheTTL
+	if r.err != nil {
+		ttl = fleetFailureTTL
+	}
+	return now.Sub(r.fetchedAt) <= ttl
+}
+
+// NewFleetClient creates a fleet client for projectID
+func NewFleetClient(ctx context.Context, projectID string, opts ...option.ClientOption) (*FleetClient, error) {
+	service, err := recommender.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Recommender service: %w", err)
+	}
+	return &FleetClient{service: service, projectID: projectID, cache: make(map[string]fleetRegion)}, nil
+}
+
+// FleetSignals returns the active recommendations and insights for instance.
+// Signals are listed for the instance's whole region at once and cached; a
+// failure to list them is cached for fleetFailureTTL, so the rest of the
+// region's instances are not held up retrying it.
+func (c *FleetClient) FleetSignals(ctx context.Context, instance *config.InstanceInfo) ([]FleetSignal, error) {
+	c.mu.Lock()
+	region, ok := c.cache[instance.Region]
+	c.mu.Unlock()
+	if !ok || !region.fresh(time.Now()) {
+		signals, err := c.listRegion(ctx, instance.Region)
+		if err != nil && ctx.Err() != nil {
+			// The caller gave up; that says nothing about the region
+			return nil, err
+		}
+		region = fleetRegion{signals: signals, err: err, fetchedAt: time.Now()}
+		c.mu.Lock()
+		c.cache[instance.Region] = region
+		c.mu.Unlock()
+	}
+	if region.err != nil {
+		return nil, region.err
+	}
+	return region.signals[instance.Name], nil
+}
+
+// listRegion lists the active signals of every fleet source in region
+func (c *FleetClient) listRegion(ctx context.Context, region string) (map[string][]FleetSignal, error) {
+	parent := fmt.Sprintf("projects/%s/locations/%s", c.projectID, region)
+	signals := make(map[string][]FleetSignal)
+
+	for _, r := range fleetRecommenders {
+		err := c.service.Projects.Locations.Recommenders.Recommendations.List(parent+"/recommenders/"+r).
+			Filter("stateInfo.state = ACTIVE").Context(ctx).
+			Pages(ctx, func(resp *recommender.GoogleCloudRecommenderV1ListRecommendationsResponse) error {
+				for _, rec := range resp.Recommendations {
+					signal := FleetSignal{
+						Kind:        FleetRecommendation,
+						Type:        r,
+						Subtype:     rec.RecommenderSubtype,
+						Description: rec.Description,
+						Priority:    rec.Priority,
+						TargetTier:  recommendedTier(rec),
+						Name:        rec.Name,
+					}
+					for _, name := range fleetInstances(rec.TargetResources) {
+						signals[name] = append(signals[name], signal)
+					}
+				}
+				return nil
+			})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s recommendations in %s: %w", r, region, err)
+		}
+	}
+
+	for _, t := range fleetInsightTypes {
+		err := c.service.Projects.Locations.InsightTypes.Insights.List(parent+"/insightTypes/"+t).
+			Filter("stateInfo.state = ACTIVE").Context(ctx).
+			Pages(ctx, func(resp *recommender.GoogleCloudRecommenderV1ListInsightsResponse) error {
+				for _, insight := range resp.Insights {
+					signal := FleetSignal{
+						Kind:        FleetInsight,
+						Type:        t,
+						Subtype:     insight.InsightSubtype,
+						Description: insight.Description,
+						Priority:    insight.Severity,
+						Name:        insight.Name,
+					}
+					for _, name := range fleetInstances(insight.TargetResources) {
+						signals[name] = append(signals[name], signal)
+					}
+				}
+				return nil
+			})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s insights in %s: %w", t, region, err)
+		}
+	}
+	return signals, nil
+}
+
+// fleetInstances returns the Cloud SQL instance names among resources, full
+// resource names such as //sqladmin.googleapis.com/projects/P/instances/NAME
+func fleetInstances(resources []string) []string {
+	var names []string
+	for _, r := range resources {
+		if i := strings.LastIndex(r, "/instances/"); i >= 0 && strings.HasPrefix(r, "//sqladmin.googleapis.com/") {
+			names = append(names, r[i+len("/instances/"):])
+		}
+	}
+	return names
+}
+
+// recommendedTier returns the machine type a recommendation resizes its
+// instance to, or "" if it does not change the tier
+func recommendedTier(rec *recommender.GoogleCloudRecommenderV1Recommendation) string {
+	if rec.Content == nil {
+		return ""
+	}
+	for _, group := range rec.Content.OperationGroups {
+		for _, op := range group.Operations {
+			if tier, ok := op.Value.(string); ok && op.Path == "/settings/tier" && op.Action == "replace" {
+				return tier
+			}
+		}
+	}
+	return ""
+}
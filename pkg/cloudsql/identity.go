@@ -0,0 +1,78 @@
+package cloudsql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/compute/metadata"
+	"golang.org/x/oauth2/google"
+	oauth2api "google.golang.org/api/oauth2/v2"
+	"google.golang.org/api/option"
+)
+
+// principalScopes are requested of Application Default Credentials to look
+// up their principal; the email scope lets tokeninfo report it
+var principalScopes = []string{
+	"https://www.googleapis.com/auth/cloud-platform",
+	"https://www.googleapis.com/auth/userinfo.email",
+}
+
+// Principal returns the email of the identity Application Default
+// Credentials act as:
+//   - a service account key's client_email
+//   - the service account impersonated through the IAM Credentials API, as
+//     gcloud auth application-default login --impersonate-service-account sets
+//   - the attached service account on Google Cloud, from the metadata server
+//   - otherwise the email tokeninfo reports for the credentials' access token,
+//     e.g. of a user's gcloud credentials
+func Principal(ctx context.Context) (string, error) {
+	creds, err := google.FindDefaultCredentials(ctx, principalScopes...)
+	if err != nil {
+		return "", fmt.Errorf("failed to find Application Default Credentials: %w", err)
+	}
+
+	var file struct {
+		ClientEmail                    string `json:"client_email"`
+		ServiceAccountImpersonationURL string `json:"service_account_impersonation_url"`
+	}
+	if len(creds.JSON) > 0 {
+		if err := json.Unmarshal(creds.JSON, &file); err != nil {
+			return "", fmt.Errorf("failed to read Application Default Credentials: %w", err)
+		}
+	}
+	switch {
+	case file.ClientEmail != "":
+		return file.ClientEmail, nil
+	case file.ServiceAccountImpersonationURL != "":
+		// .../projects/-/serviceAccounts/EMAIL:generateAccessToken
+		_, account, _ := strings.Cut(file.ServiceAccountImpersonationURL, "/serviceAccounts/")
+		if email, _, _ := strings.Cut(account, ":"); email != "" {
+			return email, nil
+		}
+	case len(creds.JSON) == 0 && metadata.OnGCE():
+		email, err := metadata.EmailWithContext(ctx, "default")
+		if err != nil {
+			return "", fmt.Errorf("failed to read the attached service account: %w", err)
+		}
+		return email, nil
+	}
+
+	token, err := creds.TokenSource.Token()
+	if err != nil {
+		return "", fmt.Errorf("failed to get an access token: %w", err)
+	}
+	service, err := oauth2api.NewService(ctx, option.WithoutAuthentication())
+	if err != nil {
+		return "", fmt.Errorf("failed to create OAuth2 client: %w", err)
+	}
+	info, err := service.Tokeninfo().AccessToken(token.AccessToken).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to look up the access token's principal: %w", err)
+	}
+	if info.Email == "" {
+		return "", fmt.Errorf("the access token carries no email; grant the credentials the userinfo.email scope")
+	}
+	return info.Email, nil
+}
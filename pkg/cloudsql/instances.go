@@ -3,6 +3,7 @@ package cloudsql
 import (
 	"context"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
@@ -10,12 +11,16 @@ import (
 
 // ScalingDecision represents a scaling recommendation
 type ScalingDecision struct {
+	ID               string // Unique decision identifier, attached to applied operations
+	Key              string // Idempotency key, see DecisionKey
 	ShouldScale      bool
 	CurrentType      string
 	RecommendedType  string
 	Reason           string
+	ReasonCodes      []ReasonCode // Machine-readable reasons, primary first
 	DowntimeExpected bool
 	DowntimeReason   string
+	DowntimeFreeAt   time.Time // When waiting would let the change run without downtime; zero if it would not
 	EstimatedSavings float64
 	Metrics          *config.MetricsSummary
 }
@@ -90,24 +95,59 @@ func ValidateScaling(instance *config.InstanceInfo, targetMachineType string) er
 	return nil
 }
 
-// EstimateCostSavings estimates monthly cost savings for a scaling operation
-func EstimateCostSavings(currentType, recommendedType string, region string) float64 {
-	// This is a simplified estimation - in reality, you'd use GCP pricing API
-	// or maintain a pricing table
+// EstimateCostSavings estimates monthly cost savings for a scaling operation,
+// including per-vCPU license costs for licensed engines such as SQL Server.
+// The vCPU and memory spend commitment covers on the current machine type is
+// paid either way, so a reduction saves only what lies above it; scale-ups
+// and licenses are priced on demand.
+func EstimateCostSavings(currentType, recommendedType, region string, edition config.Edition, databaseVersion string, commitment config.Commitment) float64 {
+	current := estimateComputeCost(currentType, region, edition)
+	recommended := estimateComputeCost(recommendedType, region, edition)
+	committed := EstimateCommittedCost(currentType, region, edition, commitment)
+
+	return math.Max(current-committed, 0) - math.Max(recommended-committed, 0) -
+		EstimateLicenseCostDelta(currentType, recommendedType, databaseVersion)
+}
 
-	currentMT, _ := config.GetMachineType(currentType)
-	recommendedMT, _ := config.GetMachineType(recommendedType)
+// EstimateMonthlyCost estimates the monthly on-demand compute cost of a zonal
+// instance of machineType, including per-vCPU license costs. vCPU and memory
+// are priced at the region and edition's Cloud Billing Catalog rates once a
+// PriceCatalog has been refreshed, and at the built-in list prices otherwise.
+func EstimateMonthlyCost(machineType, region string, edition config.Edition, databaseVersion string) float64 {
+	return estimateComputeCost(machineType, region, edition) + estimateLicenseCost(machineType, databaseVersion)
+}
 
-	// Rough estimation based on CPU and memory
-	// Actual pricing varies by region and commitment type
-	cpuHourlyRate := 0.0475    // $/vCPU/hour (example)
-	memoryHourlyRate := 0.0080 // $/GB/hour (example)
+// EstimateCommittedCost estimates the monthly vCPU and memory spend of an
+// instance of machineType that commitment covers, at on-demand prices
+func EstimateCommittedCost(machineType, region string, edition config.Edition, commitment config.Commitment) float64 {
+	if !commitment.Enabled() {
+		return 0
+	}
+	return estimateComputeCost(machineType, region, edition) * commitment.Coverage
+}
 
-	currentMonthlyCost := (float64(currentMT.CPU)*cpuHourlyRate +
-		currentMT.MemoryGB*memoryHourlyRate) * 24 * 30
+// estimateComputeCost estimates the monthly on-demand vCPU and memory cost of
+// a zonal instance of machineType
+func estimateComputeCost(machineType, region string, edition config.Edition) float64 {
+	mt, _ := config.GetMachineType(machineType)
+	rates, _ := RatesFor(region, edition)
+	return (float64(mt.CPU)*rates.VCPU + mt.MemoryGB*rates.MemoryGB) * 24 * 30
+}
 
-	recommendedMonthlyCost := (float64(recommendedMT.CPU)*cpuHourlyRate +
-		recommendedMT.MemoryGB*memoryHourlyRate) * 24 * 30
+// EstimateLicenseCostDelta estimates the monthly change in license cost for a
+// scaling operation; positive values are cost increases
+func EstimateLicenseCostDelta(currentType, recommendedType string, databaseVersion string) float64 {
+	return estimateLicenseCost(recommendedType, databaseVersion) - estimateLicenseCost(currentType, databaseVersion)
+}
 
-	return currentMonthlyCost - recommendedMonthlyCost
+// estimateLicenseCost estimates the monthly license cost of an instance of
+// machineType running databaseVersion. Licenses are billed for a minimum
+// number of vCPUs, so resizes below it change nothing.
+func estimateLicenseCost(machineType, databaseVersion string) float64 {
+	rate := config.LicenseHourlyRatePerVCPU(databaseVersion)
+	if rate == 0 {
+		return 0
+	}
+	mt, _ := config.GetMachineType(machineType)
+	return float64(config.LicensedVCPUs(databaseVersion, mt.CPU)) * rate * 24 * 30
 }
@@ -0,0 +1,221 @@
+package cloudsql
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// User label keys written to instances the autoscaler resizes, so Cloud Audit
+// Logs entries can be joined back to the analysis that caused them
+const (
+	LabelManagedBy  = "managed-by"
+	LabelDecisionID = "cloudsql-autoscaler-decision"
+	LabelScaledAt   = "cloudsql-autoscaler-scaled-at"
+	LabelFromTier   = "cloudsql-autoscaler-from-tier"
+
+	// Idempotency key of the last change applied, see DecisionKey
+	LabelDecisionKey = "cloudsql-autoscaler-decision-key"
+
+	// Deadline for reverting a reactive scale-up, see RevertTag; empty once
+	// a later operation supersedes it
+	LabelRevertBy = "cloudsql-autoscaler-revert-by"
+
+	// Machine type a regressed scale-down was rolled back from; scale-downs
+	// to it or smaller are held until the label is removed
+	LabelRollbackFloor = "cloudsql-autoscaler-rollback-floor"
+
+	// Written by disk resizes instead of the scaled-at and from-tier labels
+	LabelDiskResizedAt = "cloudsql-autoscaler-disk-resized-at"
+	LabelFromDiskGB    = "cloudsql-autoscaler-from-disk-gb"
+
+	// Written to read replicas the autoscaler creates; only those are ever deleted
+	LabelReplicaCreatedAt = "cloudsql-autoscaler-replica-created-at"
+
+	// Who applied the change, see ActorLabels
+	LabelAppliedBy  = "cloudsql-autoscaler-applied-by"  // Principal, with characters labels cannot hold as '_'
+	LabelAppliedVia = "cloudsql-autoscaler-applied-via" // daemon or cli
+	LabelAppliedOn  = "cloudsql-autoscaler-applied-on"  // Hostname
+
+	managedByValue = "cloudsql-autoscaler"
+)
+
+// User label keys teams set on their instances to tune the autoscaler for
+// them without changing the central configuration. Label keys cannot contain
+// '/', so these stand in for autoscaler/profile and autoscaler/max-tier.
+const (
+	LabelProfile        = "autoscaler-profile"         // Scaling profile the instance is judged with
+	LabelMaxTier        = "autoscaler-max-tier"        // Largest machine type metric-driven scale-ups may choose
+	LabelDowntimeBudget = "autoscaler-downtime-budget" // Monthly downtime budget of resizes, a duration such as 10m
+)
+
+// populatePolicy fills in the policy overrides of info's labels
+func populatePolicy(info *config.InstanceInfo) {
+	info.ProfileLabel = info.Labels[LabelProfile]
+	info.MaxTier = info.Labels[LabelMaxTier]
+	info.DowntimeBudget = info.Labels[LabelDowntimeBudget]
+}
+
+// NewDecisionID returns a random identifier for a scaling decision. It is
+// lowercase hex so it can be used directly as a label value.
+func NewDecisionID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// DecisionKey returns the idempotency key of changing instance from
+// decision's current to its recommended machine type at some time in the
+// window of the given length containing at. The key is the same for every
+// decision making that change in that window, wherever and however often it
+// is computed, so an applied key identifies a change that must not run again.
+func DecisionKey(instance string, decision *ScalingDecision, at time.Time, window time.Duration) string {
+	var start int64
+	if window > 0 {
+		start = at.Truncate(window).Unix()
+	}
+	sum := sha256.Sum256(fmt.Appendf(nil, "%s\x00%s\x00%s\x00%d", instance, decision.CurrentType, decision.RecommendedType, start))
+	// Half the digest is plenty and keeps the key well inside label value limits
+	return hex.EncodeToString(sum[:16])
+}
+
+// ScalingLabels returns the labels to attach to an instance when applying decision
+func ScalingLabels(decision *ScalingDecision, at time.Time) map[string]string {
+	return map[string]string{
+		LabelManagedBy:   managedByValue,
+		LabelDecisionID:  decision.ID,
+		LabelDecisionKey: decision.Key,
+		LabelScaledAt:    strconv.FormatInt(at.Unix(), 10),
+		LabelFromTier:    TierLabelValue(decision.CurrentType),
+	}
+}
+
+// TierLabelValue encodes a machine type as a label value TierFromLabel reads
+// back. Label values cannot hold uppercase letters, so each is written as '_'
+// and its lowercase, and '_' itself as "__": db-perf-optimized-N-2 becomes
+// db-perf-optimized-_n-2.
+func TierLabelValue(machineType string) string {
+	var b strings.Builder
+	for _, r := range machineType {
+		switch {
+		case r == '_':
+			b.WriteString("__")
+		case unicode.IsUpper(r):
+			b.WriteRune('_')
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// TierFromLabel decodes a machine type written by TierLabelValue
+func TierFromLabel(value string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range value {
+		switch {
+		case escaped && r == '_':
+			b.WriteRune('_')
+		case escaped:
+			b.WriteRune(unicode.ToUpper(r))
+		case r == '_':
+			escaped = true
+			continue
+		default:
+			b.WriteRune(r)
+		}
+		escaped = false
+	}
+	return b.String()
+}
+
+// ActorLabels returns the labels recording who applied a change: the
+// principal and host made label values, and the mode. Empty values are
+// written as well, so a change never keeps the previous change's actor.
+func ActorLabels(principal, mode, host string) map[string]string {
+	return map[string]string{
+		LabelAppliedBy:  labelValue(principal),
+		LabelAppliedVia: labelValue(mode),
+		LabelAppliedOn:  labelValue(host),
+	}
+}
+
+// labelValue converts s to a label value: lowercase letters, digits, '_'
+// and '-', at most 63 characters. alice@example.com becomes
+// alice_example_com.
+func labelValue(s string) string {
+	value := []rune(strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLower(r), unicode.IsDigit(r), r == '_', r == '-':
+			return r
+		case unicode.IsUpper(r):
+			return unicode.ToLower(r)
+		}
+		return '_'
+	}, s))
+	return string(value[:min(len(value), 63)])
+}
+
+// RevertTag is what an instance's labels say about a reactive scale-up that
+// may be reverted
+type RevertTag struct {
+	FromTier string    // Machine type before the scale-up
+	ScaledAt time.Time // When the scale-up was applied
+	Deadline time.Time // Last moment a revert is considered
+}
+
+// ParseRevertTag reads the revert tag written with a reactive scale-up, if
+// the instance has one
+func ParseRevertTag(labels map[string]string) (RevertTag, bool) {
+	deadline, err := strconv.ParseInt(labels[LabelRevertBy], 10, 64)
+	if err != nil {
+		return RevertTag{}, false
+	}
+	scaledAt, err := strconv.ParseInt(labels[LabelScaledAt], 10, 64)
+	if err != nil || labels[LabelFromTier] == "" {
+		return RevertTag{}, false
+	}
+	return RevertTag{
+		FromTier: TierFromLabel(labels[LabelFromTier]),
+		ScaledAt: time.Unix(scaledAt, 0),
+		Deadline: time.Unix(deadline, 0),
+	}, true
+}
+
+// LastScaling is what an instance's labels say about its last machine type change
+type LastScaling struct {
+	FromTier string    // Machine type before the change
+	ScaledAt time.Time // When the change was applied
+}
+
+// ParseLastScaling reads the labels written with the instance's last machine
+// type change, if it has them
+func ParseLastScaling(labels map[string]string) (LastScaling, bool) {
+	scaledAt, err := strconv.ParseInt(labels[LabelScaledAt], 10, 64)
+	if err != nil || labels[LabelFromTier] == "" {
+		return LastScaling{}, false
+	}
+	return LastScaling{FromTier: TierFromLabel(labels[LabelFromTier]), ScaledAt: time.Unix(scaledAt, 0)}, true
+}
+
+// StorageLabels returns the labels to attach to an instance when applying a
+// disk size decision
+func StorageLabels(decision *StorageDecision, at time.Time) map[string]string {
+	return map[string]string{
+		LabelManagedBy:     managedByValue,
+		LabelDecisionID:    decision.ID,
+		LabelDiskResizedAt: strconv.FormatInt(at.Unix(), 10),
+		LabelFromDiskGB:    strconv.FormatInt(decision.CurrentSizeGB, 10),
+	}
+}
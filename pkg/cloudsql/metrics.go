@@ -4,11 +4,17 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
 	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
 	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
@@ -19,11 +25,26 @@ import (
 type MetricsClient struct {
 	client    *monitoring.MetricClient
 	projectID string
+	budget    *QuotaBudget
+
+	hedgeDelay time.Duration // Zero disables hedging
+	hedged     atomic.Int64
+	hedgesWon  atomic.Int64
+
+	cacheMu sync.Mutex
+	cache   map[string]cachedSeries
+}
+
+// cachedSeries holds a previously fetched time series and when it was fetched
+type cachedSeries struct {
+	data      map[time.Time]float64
+	fetchedAt time.Time
+	ttl       time.Duration
 }
 
 // NewMetricsClient creates a new metrics client
-func NewMetricsClient(ctx context.Context, projectID string) (*MetricsClient, error) {
-	client, err := monitoring.NewMetricClient(ctx)
+func NewMetricsClient(ctx context.Context, projectID string, opts ...option.ClientOption) (*MetricsClient, error) {
+	client, err := monitoring.NewMetricClient(ctx, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create metrics client: %w", err)
 	}
@@ -31,16 +52,165 @@ func NewMetricsClient(ctx context.Context, projectID string) (*MetricsClient, er
 	return &MetricsClient{
 		client:    client,
 		projectID: projectID,
+		cache:     make(map[string]cachedSeries),
 	}, nil
 }
 
+// SetQuotaBudget attaches a quota budget that all ListTimeSeries calls draw from
+func (m *MetricsClient) SetQuotaBudget(budget *QuotaBudget) {
+	m.budget = budget
+}
+
+// SetHedgeDelay enables hedged requests: a ListTimeSeries call that has not
+// returned after d is sent again, and whichever copy answers first is used.
+// Duplicates draw from the quota budget and are skipped when it has no room.
+// Zero disables hedging.
+func (m *MetricsClient) SetHedgeDelay(d time.Duration) {
+	m.hedgeDelay = d
+}
+
+// HedgeStats counts hedged Monitoring requests
+type HedgeStats struct {
+	Delay  time.Duration `json:"delay"`
+	Hedged int64         `json:"hedged"` // Requests a duplicate was sent for
+	Won    int64         `json:"won"`    // Requests the duplicate answered first
+}
+
+// HedgeStats returns how many requests were hedged and how many duplicates won
+func (m *MetricsClient) HedgeStats() HedgeStats {
+	return HedgeStats{Delay: m.hedgeDelay, Hedged: m.hedged.Load(), Won: m.hedgesWon.Load()}
+}
+
+// QuotaBudget returns the attached quota budget, if any
+func (m *MetricsClient) QuotaBudget() *QuotaBudget {
+	return m.budget
+}
+
 // Close closes the metrics client
 func (m *MetricsClient) Close() error {
 	return m.client.Close()
 }
 
-// GetInstanceMetrics retrieves metrics for a Cloud SQL instance
-func (m *MetricsClient) GetInstanceMetrics(ctx context.Context, instanceID string, cfg *config.Config) (*config.MetricsData, error) {
+// Series GetInstanceMetrics assembles its data from; they also name the
+// series in errors
+const (
+	seriesCPU             = "CPU"
+	seriesMemory          = "memory"
+	seriesMemoryBytes     = "memory usage"
+	seriesConnections     = "connections"
+	seriesNonCache        = "non-cache memory"
+	seriesBufferPoolFree  = "free buffer pool pages"
+	seriesSwapIn          = "swap-in"
+	seriesGrantsPending   = "memory grants pending"
+	seriesDiskUsed        = "disk usage"
+	seriesDiskReadOps     = "disk read operations"
+	seriesDiskWriteOps    = "disk write operations"
+	seriesDiskReadBytes   = "disk read bytes"
+	seriesDiskWriteBytes  = "disk write bytes"
+	seriesReplicaLag      = "replica lag"
+	seriesDataCacheUsed   = "data cache usage"
+	seriesDataCacheHits   = "data cache hits"
+	seriesDataCacheMisses = "data cache misses"
+)
+
+// seriesFetch is one ListTimeSeries call GetInstanceMetrics may make
+type seriesFetch struct {
+	series      string // Series the result is stored as
+	metricType  string
+	labelFilter string
+	required    bool   // A failed fetch fails GetInstanceMetrics; others leave the series empty
+	fallbackFor string // Only fetched when this series came back empty
+	needs       string // Only fetched when this series came back non-empty
+}
+
+// metricFetches returns the ListTimeSeries calls GetInstanceMetrics makes
+// for instance under cfg, in order
+func metricFetches(instance *config.InstanceInfo, cfg *config.Config) []seriesFetch {
+	engine := config.ParseEngine(instance.DatabaseVersion)
+	fetches := []seriesFetch{
+		{series: seriesCPU, metricType: "cloudsql.googleapis.com/database/cpu/utilization", required: true},
+		{series: seriesMemory, metricType: "cloudsql.googleapis.com/database/memory/utilization", required: true},
+		// Not every instance reports memory usage in bytes
+		{series: seriesMemoryBytes, metricType: "cloudsql.googleapis.com/database/memory/usage"},
+	}
+
+	// Connections open on the instance: server processes on PostgreSQL,
+	// connected threads on MySQL and user connections on SQL Server. Older
+	// MySQL versions only report the instance's network connections.
+	switch engine {
+	case config.EngineMySQL:
+		fetches = append(fetches,
+			seriesFetch{series: seriesConnections, metricType: "cloudsql.googleapis.com/database/mysql/threads", labelFilter: `metric.labels.thread_kind="threads_connected"`},
+			seriesFetch{series: seriesConnections, metricType: "cloudsql.googleapis.com/database/network/connections", fallbackFor: seriesConnections})
+	case config.EngineSQLServer:
+		fetches = append(fetches,
+			seriesFetch{series: seriesConnections, metricType: "cloudsql.googleapis.com/database/sqlserver/connections/user_connections"},
+			seriesFetch{series: seriesConnections, metricType: "cloudsql.googleapis.com/database/network/connections", fallbackFor: seriesConnections})
+	default:
+		fetches = append(fetches, seriesFetch{series: seriesConnections, metricType: "cloudsql.googleapis.com/database/postgresql/num_backends"})
+	}
+
+	// The signals the engine's memory pressure mode judges memory by
+	switch cfg.MemoryPressureFor(instance.DatabaseVersion) {
+	case config.MemoryPressureNonCache:
+		fetches = append(fetches, seriesFetch{series: seriesNonCache, metricType: "cloudsql.googleapis.com/database/memory/components", labelFilter: `metric.labels.component="Usage"`})
+		if engine == config.EngineMySQL && instance.CurrentMemoryGB > 0 {
+			fetches = append(fetches, seriesFetch{series: seriesBufferPoolFree, metricType: "cloudsql.googleapis.com/database/mysql/innodb_buffer_pool_pages_free", needs: seriesNonCache})
+		}
+	case config.MemoryPressureCorroborated:
+		fetches = append(fetches, seriesFetch{series: seriesSwapIn, metricType: "cloudsql.googleapis.com/database/swap/pages_swapped_in_count"})
+		if engine == config.EngineSQLServer {
+			// SQL Server manages its own memory and rarely swaps; queries
+			// queued for memory are its sign of pressure
+			fetches = append(fetches, seriesFetch{series: seriesGrantsPending, metricType: "cloudsql.googleapis.com/database/sqlserver/memory/memory_grants_pending"})
+		}
+	}
+
+	// Data disk usage when storage autoscaling is on
+	if cfg.StorageScaleUpThreshold > 0 {
+		fetches = append(fetches, seriesFetch{series: seriesDiskUsed, metricType: "cloudsql.googleapis.com/database/disk/bytes_used"})
+	}
+
+	// Disk operations and bytes when IO-bound detection is on
+	if cfg.IOBoundThreshold > 0 {
+		fetches = append(fetches,
+			seriesFetch{series: seriesDiskReadOps, metricType: "cloudsql.googleapis.com/database/disk/read_ops_count"},
+			seriesFetch{series: seriesDiskWriteOps, metricType: "cloudsql.googleapis.com/database/disk/write_ops_count"},
+			seriesFetch{series: seriesDiskReadBytes, metricType: "cloudsql.googleapis.com/database/disk/read_bytes_count"},
+			seriesFetch{series: seriesDiskWriteBytes, metricType: "cloudsql.googleapis.com/database/disk/write_bytes_count"})
+	}
+
+	// Replication lag of read replicas when replica count autoscaling or the
+	// maximum replication lag is on
+	if (cfg.ReplicaScaleUpThreshold > 0 || cfg.MaxReplicaLag > 0) && IsReadReplica(instance) {
+		fetches = append(fetches, seriesFetch{series: seriesReplicaLag, metricType: "cloudsql.googleapis.com/database/replication/replica_lag"})
+	}
+
+	// Data cache metrics for Enterprise Plus instances with the cache enabled
+	if instance.DataCacheEnabled {
+		cacheEngine := "postgresql"
+		if strings.HasPrefix(instance.DatabaseVersion, "MYSQL") {
+			cacheEngine = "mysql"
+		}
+		fetches = append(fetches,
+			seriesFetch{series: seriesDataCacheUsed, metricType: "cloudsql.googleapis.com/database/data_cache/bytes_used"},
+			seriesFetch{series: seriesDataCacheHits, metricType: fmt.Sprintf("cloudsql.googleapis.com/database/%s/data_cache/hit_count", cacheEngine)},
+			seriesFetch{series: seriesDataCacheMisses, metricType: fmt.Sprintf("cloudsql.googleapis.com/database/%s/data_cache/miss_count", cacheEngine)})
+	}
+	return fetches
+}
+
+// MetricCalls returns the most ListTimeSeries calls GetInstanceMetrics makes
+// for instance under cfg, counting fallbacks it only makes when a series is
+// empty but not hedged duplicates
+func MetricCalls(instance *config.InstanceInfo, cfg *config.Config) int {
+	return len(metricFetches(instance, cfg))
+}
+
+// GetInstanceMetrics retrieves metrics for a Cloud SQL instance, making the
+// calls metricFetches lists
+func (m *MetricsClient) GetInstanceMetrics(ctx context.Context, instance *config.InstanceInfo, cfg *config.Config) (*config.MetricsData, error) {
+	instanceID := instance.Name
 	endTime := time.Now()
 	startTime := endTime.Add(-cfg.MetricsPeriod)
 
@@ -54,31 +224,36 @@ func (m *MetricsClient) GetInstanceMetrics(ctx context.Context, instanceID strin
 		DiskIOPS:       []float64{},
 	}
 
-	// Fetch CPU utilization
-	cpuData, err := m.fetchMetric(ctx, instanceID, "cloudsql.googleapis.com/database/cpu/utilization", startTime, endTime, cfg.MetricsInterval)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch CPU metrics: %w", err)
-	}
-
-	// Fetch memory utilization
-	memoryData, err := m.fetchMetric(ctx, instanceID, "cloudsql.googleapis.com/database/memory/utilization", startTime, endTime, cfg.MetricsInterval)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch memory metrics: %w", err)
-	}
-
-	// Fetch memory usage in bytes
-	memoryBytesData, err := m.fetchMetric(ctx, instanceID, "cloudsql.googleapis.com/database/memory/usage", startTime, endTime, cfg.MetricsInterval)
-	if err != nil {
-		// Non-fatal: some instances might not report this metric
-		memoryBytesData = make(map[time.Time]float64)
+	got := make(map[string]map[time.Time]float64)
+	for _, f := range metricFetches(instance, cfg) {
+		if f.fallbackFor != "" && len(got[f.fallbackFor]) > 0 {
+			continue
+		}
+		if f.needs != "" && len(got[f.needs]) == 0 {
+			continue
+		}
+		data, err := m.fetchSeries(ctx, instanceID, f.metricType, f.labelFilter, startTime, endTime, cfg.MetricsInterval)
+		if err != nil {
+			if f.required {
+				return nil, fmt.Errorf("failed to fetch %s metrics: %w", f.series, err)
+			}
+			data = make(map[time.Time]float64)
+		}
+		got[f.series] = data
 	}
 
-	// Fetch active connections
-	connectionsData, err := m.fetchMetric(ctx, instanceID, "cloudsql.googleapis.com/database/postgresql/num_backends", startTime, endTime, cfg.MetricsInterval)
-	if err != nil {
-		// Non-fatal: metric name varies by database type
-		connectionsData = make(map[time.Time]float64)
-	}
+	cpuData := got[seriesCPU]
+	memoryData := got[seriesMemory]
+	memoryBytesData := got[seriesMemoryBytes]
+	connectionsData := got[seriesConnections]
+	nonCacheData := discountFreeBufferPool(instance, got[seriesNonCache], got[seriesBufferPoolFree])
+	swapInData := got[seriesSwapIn]
+	grantsPendingData := got[seriesGrantsPending]
+	diskUsedData := got[seriesDiskUsed]
+	diskIOPSData := perSecond(1, got[seriesDiskReadOps], got[seriesDiskWriteOps])
+	diskThroughputData := perSecond(1<<20, got[seriesDiskReadBytes], got[seriesDiskWriteBytes])
+	replicaLagData := got[seriesReplicaLag]
+	cacheUsedData, cacheHitData, cacheMissData := got[seriesDataCacheUsed], got[seriesDataCacheHits], got[seriesDataCacheMisses]
 
 	// Combine all metrics into aligned time series
 	allTimestamps := make(map[time.Time]bool)
@@ -119,16 +294,179 @@ func (m *MetricsClient) GetInstanceMetrics(ctx context.Context, instanceID strin
 		} else {
 			metrics.Connections = append(metrics.Connections, 0)
 		}
+
+		// Already a percentage; gaps are left as zero like the other series
+		if len(nonCacheData) > 0 {
+			metrics.MemoryNonCachePercent = append(metrics.MemoryNonCachePercent, nonCacheData[ts])
+		}
+		if len(swapInData) > 0 {
+			metrics.SwapInPages = append(metrics.SwapInPages, swapInData[ts])
+		}
+		if len(grantsPendingData) > 0 {
+			metrics.MemoryGrantsPending = append(metrics.MemoryGrantsPending, grantsPendingData[ts])
+		}
+		if len(diskUsedData) > 0 {
+			metrics.DiskUsageGB = append(metrics.DiskUsageGB, diskUsedData[ts]/1024/1024/1024) // Convert to GB
+		}
+		if len(replicaLagData) > 0 {
+			metrics.ReplicaLagSeconds = append(metrics.ReplicaLagSeconds, replicaLagData[ts])
+		}
+		if len(diskIOPSData) > 0 {
+			metrics.DiskIOPS = append(metrics.DiskIOPS, diskIOPSData[ts])
+		}
+		if len(diskThroughputData) > 0 {
+			metrics.DiskThroughputMBps = append(metrics.DiskThroughputMBps, diskThroughputData[ts])
+		}
+
+		if instance.DataCacheEnabled {
+			metrics.DataCacheUsedGB = append(metrics.DataCacheUsedGB, cacheUsedData[ts]/1024/1024/1024)
+			hits, misses := cacheHitData[ts], cacheMissData[ts]
+			if hits+misses > 0 {
+				metrics.DataCacheHitRatio = append(metrics.DataCacheHitRatio, hits/(hits+misses)*100)
+			}
+		}
 	}
 
 	return metrics, nil
 }
 
-// fetchMetric retrieves a specific metric time series
-func (m *MetricsClient) fetchMetric(ctx context.Context, instanceID string, metricType string, startTime, endTime time.Time, interval time.Duration) (map[time.Time]float64, error) {
+// diskSampleSeconds is the sampling period of Cloud SQL's disk operation and
+// byte counts; each point is the count over one sample
+const diskSampleSeconds = 60
+
+// perSecond sums disk operation or byte counts into rates per second, divided
+// by scale
+func perSecond(scale float64, counts ...map[time.Time]float64) map[time.Time]float64 {
+	total := make(map[time.Time]float64)
+	for _, series := range counts {
+		for ts, v := range series {
+			total[ts] += v / diskSampleSeconds / scale
+		}
+	}
+	return total
+}
+
+// innodbPageBytes is the size of an InnoDB buffer pool page; Cloud SQL does
+// not let it be changed from MySQL's default
+const innodbPageBytes = 16 << 10
+
+// discountFreeBufferPool returns a MySQL instance's non-cache memory
+// percentages without the free pages of its InnoDB buffer pool. The pool is
+// allocated up front and counted as used whether or not it holds data, so
+// only the pages in use are memory pressure. nonCache, which may be cached,
+// is left as it is; without free page counts it is returned as it is.
+func discountFreeBufferPool(instance *config.InstanceInfo, nonCache, free map[time.Time]float64) map[time.Time]float64 {
+	memoryBytes := instance.CurrentMemoryGB * 1024 * 1024 * 1024
+	if len(nonCache) == 0 || len(free) == 0 || memoryBytes <= 0 {
+		return nonCache
+	}
+	discounted := make(map[time.Time]float64, len(nonCache))
+	for ts, pct := range nonCache {
+		if pages, ok := free[ts]; ok {
+			pct = max(pct-pages*innodbPageBytes/memoryBytes*100, 0)
+		}
+		discounted[ts] = pct
+	}
+	return discounted
+}
+
+// fetchSeries retrieves the time series of a metric, narrowed by labelFilter
+// when set, serving from cache when fresh and degrading granularity when the
+// quota budget is under pressure
+func (m *MetricsClient) fetchSeries(ctx context.Context, instanceID string, metricType, labelFilter string, startTime, endTime time.Time, interval time.Duration) (map[time.Time]float64, error) {
+	degrade := m.budget.DegradeFactor()
+	effective := interval * time.Duration(degrade)
+	// Series fetched at another granularity are not interchangeable
+	cacheKey := fmt.Sprintf("%s|%s|%s|%s|%s", instanceID, metricType, labelFilter, endTime.Sub(startTime), effective)
+
+	m.cacheMu.Lock()
+	cached, ok := m.cache[cacheKey]
+	m.cacheMu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < cached.ttl*time.Duration(degrade) {
+		return cached.data, nil
+	}
+
+	if err := m.budget.Acquire(ctx); err != nil {
+		return nil, fmt.Errorf("waiting for monitoring quota budget: %w", err)
+	}
+
+	data, err := m.hedgedListTimeSeries(ctx, instanceID, metricType, labelFilter, startTime, endTime, effective)
+	if err != nil {
+		if status.Code(err) == codes.ResourceExhausted {
+			m.budget.RecordThrottle()
+			// Serve stale data rather than failing outright
+			if ok {
+				return cached.data, nil
+			}
+		}
+		return nil, err
+	}
+
+	m.cacheMu.Lock()
+	m.cache[cacheKey] = cachedSeries{data: data, fetchedAt: time.Now(), ttl: interval}
+	m.cacheMu.Unlock()
+
+	return data, nil
+}
+
+// hedgedListTimeSeries performs listTimeSeries, sending a duplicate request
+// when the first has not returned within the hedge delay and using whichever
+// succeeds first. The slower request is cancelled.
+func (m *MetricsClient) hedgedListTimeSeries(ctx context.Context, instanceID string, metricType, labelFilter string, startTime, endTime time.Time, interval time.Duration) (map[time.Time]float64, error) {
+	if m.hedgeDelay <= 0 {
+		return m.listTimeSeries(ctx, instanceID, metricType, labelFilter, startTime, endTime, interval)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type reply struct {
+		data  map[time.Time]float64
+		err   error
+		hedge bool
+	}
+	replies := make(chan reply, 2)
+	send := func(hedge bool) {
+		data, err := m.listTimeSeries(ctx, instanceID, metricType, labelFilter, startTime, endTime, interval)
+		replies <- reply{data: data, err: err, hedge: hedge}
+	}
+	go send(false)
+
+	timer := time.NewTimer(m.hedgeDelay)
+	defer timer.Stop()
+
+	inFlight := 1
+	for {
+		select {
+		case <-timer.C:
+			if m.budget.TryAcquire() {
+				m.hedged.Add(1)
+				inFlight++
+				go send(true)
+			}
+		case r := <-replies:
+			inFlight--
+			// A failure only counts once no other copy can still succeed
+			if r.err == nil || inFlight == 0 {
+				if r.err == nil && r.hedge {
+					m.hedgesWon.Add(1)
+				}
+				return r.data, r.err
+			}
+		}
+	}
+}
+
+// listTimeSeries performs the ListTimeSeries call for a single metric
+func (m *MetricsClient) listTimeSeries(ctx context.Context, instanceID string, metricType, labelFilter string, startTime, endTime time.Time, interval time.Duration) (map[time.Time]float64, error) {
+	filter := fmt.Sprintf(`resource.type="cloudsql_database" AND resource.labels.database_id="%s:%s" AND metric.type="%s"`, m.projectID, instanceID, metricType)
+	if labelFilter != "" {
+		filter += " AND " + labelFilter
+	}
+
 	req := &monitoringpb.ListTimeSeriesRequest{
 		Name:   fmt.Sprintf("projects/%s", m.projectID),
-		Filter: fmt.Sprintf(`resource.type="cloudsql_database" AND resource.labels.database_id="%s:%s" AND metric.type="%s"`, m.projectID, instanceID, metricType),
+		Filter: filter,
 		Interval: &monitoringpb.TimeInterval{
 			StartTime: timestamppb.New(startTime),
 			EndTime:   timestamppb.New(endTime),
@@ -190,19 +528,38 @@ func CalculateMetricsSummary(data *config.MetricsData) *config.MetricsSummary {
 
 	// Calculate CPU statistics
 	summary.CPUAvg = calculateAverage(data.CPUUtilization)
-	summary.CPUP95 = calculatePercentile(data.CPUUtilization, 95)
-	summary.CPUP99 = calculatePercentile(data.CPUUtilization, 99)
+	summary.CPUP95 = Percentile(data.CPUUtilization, 95)
+	summary.CPUP99 = Percentile(data.CPUUtilization, 99)
 	summary.CPUMax = calculateMax(data.CPUUtilization)
 
 	// Calculate Memory statistics
 	summary.MemoryAvgGB = calculateAverage(data.MemoryUsageGB)
-	summary.MemoryP95GB = calculatePercentile(data.MemoryUsageGB, 95)
-	summary.MemoryP99GB = calculatePercentile(data.MemoryUsageGB, 99)
+	summary.MemoryP95GB = Percentile(data.MemoryUsageGB, 95)
+	summary.MemoryP99GB = Percentile(data.MemoryUsageGB, 99)
 	summary.MemoryMaxGB = calculateMax(data.MemoryUsageGB)
 
 	summary.MemoryAvgPct = calculateAverage(data.MemoryPercent)
-	summary.MemoryP95Pct = calculatePercentile(data.MemoryPercent, 95)
-	summary.MemoryP99Pct = calculatePercentile(data.MemoryPercent, 99)
+	summary.MemoryP95Pct = Percentile(data.MemoryPercent, 95)
+	summary.MemoryP99Pct = Percentile(data.MemoryPercent, 99)
+
+	// Calculate data cache statistics
+	summary.DataCacheUsedGB = calculateAverage(data.DataCacheUsedGB)
+	summary.DataCacheHitRatio = calculateAverage(data.DataCacheHitRatio)
+
+	// Calculate memory pressure signal statistics
+	summary.MemoryNonCacheP95Pct = Percentile(data.MemoryNonCachePercent, 95)
+	summary.SwapInP95 = Percentile(data.SwapInPages, 95)
+	summary.MemoryGrantsPendingP95 = Percentile(data.MemoryGrantsPending, 95)
+
+	// Calculate disk usage statistics, skipping gaps in the series
+	summary.DiskUsedGB, summary.DiskUsedMaxGB = diskUsage(data.DiskUsageGB)
+	summary.DiskGrowthGBPerDay = diskGrowth(data.Timestamps, data.DiskUsageGB)
+	summary.DiskIOPSP95 = Percentile(data.DiskIOPS, 95)
+	summary.DiskThroughputP95MBps = Percentile(data.DiskThroughputMBps, 95)
+
+	// Calculate replication lag statistics
+	summary.ReplicaLagP95Seconds = Percentile(data.ReplicaLagSeconds, 95)
+	summary.ReplicaLagMaxSeconds = calculateMax(data.ReplicaLagSeconds)
 
 	// Calculate connection statistics
 	summary.ConnectionsAvg = calculateAverage(toFloat64Slice(data.Connections))
@@ -211,6 +568,53 @@ func CalculateMetricsSummary(data *config.MetricsData) *config.MetricsSummary {
 	return summary
 }
 
+// FilterMetrics returns the data points whose timestamps keep accepts. Series
+// not aligned to the timestamps, such as the data cache hit ratio, are left out.
+func FilterMetrics(data *config.MetricsData, keep func(time.Time) bool) *config.MetricsData {
+	var idx []int
+	for i, ts := range data.Timestamps {
+		if keep(ts) {
+			idx = append(idx, i)
+		}
+	}
+
+	pick := func(values []float64) []float64 {
+		if len(values) != len(data.Timestamps) {
+			return nil
+		}
+		out := make([]float64, len(idx))
+		for j, i := range idx {
+			out[j] = values[i]
+		}
+		return out
+	}
+
+	filtered := &config.MetricsData{
+		Timestamps:            make([]time.Time, len(idx)),
+		CPUUtilization:        pick(data.CPUUtilization),
+		MemoryUsageGB:         pick(data.MemoryUsageGB),
+		MemoryPercent:         pick(data.MemoryPercent),
+		DiskUsageGB:           pick(data.DiskUsageGB),
+		DiskIOPS:              pick(data.DiskIOPS),
+		DiskThroughputMBps:    pick(data.DiskThroughputMBps),
+		DataCacheUsedGB:       pick(data.DataCacheUsedGB),
+		MemoryNonCachePercent: pick(data.MemoryNonCachePercent),
+		SwapInPages:           pick(data.SwapInPages),
+		MemoryGrantsPending:   pick(data.MemoryGrantsPending),
+		ReplicaLagSeconds:     pick(data.ReplicaLagSeconds),
+	}
+	for j, i := range idx {
+		filtered.Timestamps[j] = data.Timestamps[i]
+	}
+	if len(data.Connections) == len(data.Timestamps) {
+		filtered.Connections = make([]int, len(idx))
+		for j, i := range idx {
+			filtered.Connections[j] = data.Connections[i]
+		}
+	}
+	return filtered
+}
+
 // Statistical helper functions
 func calculateAverage(values []float64) float64 {
 	if len(values) == 0 {
@@ -249,7 +653,40 @@ func calculateMaxInt(values []int) int {
 	return max
 }
 
-func calculatePercentile(values []float64, percentile float64) float64 {
+// diskUsage returns the latest and peak disk usage. Zeros are gaps in the
+// series rather than an empty disk, so the latest is the last non-zero value.
+func diskUsage(values []float64) (latest, peak float64) {
+	for _, v := range values {
+		if v > 0 {
+			latest = v
+		}
+		if v > peak {
+			peak = v
+		}
+	}
+	return latest, peak
+}
+
+// diskGrowth returns how fast disk usage grew over timestamps, in GB per day,
+// skipping gaps in the series
+func diskGrowth(timestamps []time.Time, values []float64) float64 {
+	if len(values) != len(timestamps) || len(timestamps) == 0 {
+		return 0
+	}
+	var xs, ys []float64
+	for i, v := range values {
+		if v > 0 {
+			xs = append(xs, timestamps[i].Sub(timestamps[0]).Hours()/24)
+			ys = append(ys, v)
+		}
+	}
+	slope, _ := leastSquaresSlope(xs, ys)
+	return slope
+}
+
+// Percentile returns the given percentile (0-100) of values, interpolating
+// linearly between the nearest ranks
+func Percentile(values []float64, percentile float64) float64 {
 	if len(values) == 0 {
 		return 0
 	}
@@ -0,0 +1,171 @@
+package cloudsql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// metricsFileScheme prefixes a metrics source read from a local dump
+const metricsFileScheme = "file://"
+
+// ErrMetricsFileReadOnly is returned when a change is attempted against
+// instances loaded from a metrics dump
+var ErrMetricsFileReadOnly = errors.New("instances loaded from a metrics file cannot be changed")
+
+// MetricsDump is a point-in-time export of a project's instances and the
+// metrics collected for them, as written by export-metrics
+type MetricsDump struct {
+	ProjectID     string           `json:"project_id"`
+	ExportedAt    time.Time        `json:"exported_at"`
+	MetricsPeriod string           `json:"metrics_period"`
+	Instances     []DumpedInstance `json:"instances"`
+}
+
+// DumpedInstance is one instance in a MetricsDump. Metrics is nil for
+// instances that were not running or were excluded when exported.
+type DumpedInstance struct {
+	Instance *config.InstanceInfo `json:"instance"`
+	Metrics  *config.MetricsData  `json:"metrics,omitempty"`
+}
+
+// MetricsFilePath returns the path of a file:// metrics source
+func MetricsFilePath(source string) (string, bool) {
+	if !strings.HasPrefix(source, metricsFileScheme) {
+		return "", false
+	}
+	path := strings.TrimPrefix(source, metricsFileScheme)
+	return path, path != ""
+}
+
+// MetricsFile serves instances and metrics from a MetricsDump so analysis can
+// run without access to the Cloud SQL Admin or Monitoring APIs. The metrics
+// are used as recorded; the configured metrics period is not reapplied.
+type MetricsFile struct {
+	path      string
+	dump      *MetricsDump
+	instances map[string]DumpedInstance
+}
+
+// LoadMetricsFile reads a metrics dump written by export-metrics
+func LoadMetricsFile(path string) (*MetricsFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metrics file: %w", err)
+	}
+
+	var dump MetricsDump
+	if err := json.Unmarshal(data, &dump); err != nil {
+		return nil, fmt.Errorf("failed to parse metrics file %s: %w", path, err)
+	}
+
+	f := &MetricsFile{path: path, dump: &dump, instances: make(map[string]DumpedInstance, len(dump.Instances))}
+	for _, d := range dump.Instances {
+		if d.Instance == nil || d.Instance.Name == "" {
+			return nil, fmt.Errorf("metrics file %s has an instance without a name", path)
+		}
+		f.instances[d.Instance.Name] = d
+	}
+	return f, nil
+}
+
+// ProjectID returns the project the dump was exported from
+func (f *MetricsFile) ProjectID() string {
+	return f.dump.ProjectID
+}
+
+// ExportedAt returns when the dump was exported
+func (f *MetricsFile) ExportedAt() time.Time {
+	return f.dump.ExportedAt
+}
+
+// GetInstance returns a copy of the named instance as exported
+func (f *MetricsFile) GetInstance(ctx context.Context, instanceName string) (*config.InstanceInfo, error) {
+	d, ok := f.instances[instanceName]
+	if !ok {
+		return nil, fmt.Errorf("instance %s not in metrics file %s: %w", instanceName, f.path, ErrInstanceNotFound)
+	}
+	instance := *d.Instance
+	populatePolicy(&instance) // Dumps taken before labels were read as policy
+	return &instance, nil
+}
+
+// ListInstances returns copies of every exported instance
+func (f *MetricsFile) ListInstances(ctx context.Context) ([]*config.InstanceInfo, []SkippedInstance, error) {
+	instances := make([]*config.InstanceInfo, 0, len(f.dump.Instances))
+	for _, d := range f.dump.Instances {
+		instance := *d.Instance
+		populatePolicy(&instance)
+		instances = append(instances, &instance)
+	}
+	return instances, nil, nil
+}
+
+// GetLastScalingTime returns the last scaling time recorded at export
+func (f *MetricsFile) GetLastScalingTime(ctx context.Context, instanceName string) (time.Time, error) {
+	d, ok := f.instances[instanceName]
+	if !ok {
+		return time.Time{}, fmt.Errorf("instance %s not in metrics file %s: %w", instanceName, f.path, ErrInstanceNotFound)
+	}
+	return d.Instance.LastScaledTime, nil
+}
+
+// GetPreservedSettings is not supported; settings are only read around changes
+func (f *MetricsFile) GetPreservedSettings(ctx context.Context, instanceName string) (*PreservedSettings, error) {
+	return nil, ErrMetricsFileReadOnly
+}
+
+// StartMachineTypeUpdate always fails; a metrics file is read only
+func (f *MetricsFile) StartMachineTypeUpdate(ctx context.Context, instanceName, machineType string, labels map[string]string) (string, error) {
+	return "", ErrMetricsFileReadOnly
+}
+
+// StartDiskResize always fails; a metrics file is read only
+func (f *MetricsFile) StartDiskResize(ctx context.Context, instanceName string, sizeGB int64, labels map[string]string) (string, error) {
+	return "", ErrMetricsFileReadOnly
+}
+
+// StartReadReplicaCreate always fails; a metrics file is read only
+func (f *MetricsFile) StartReadReplicaCreate(ctx context.Context, primary, name, tier string, labels map[string]string) (string, error) {
+	return "", ErrMetricsFileReadOnly
+}
+
+// StartReadReplicaDelete always fails; a metrics file is read only
+func (f *MetricsFile) StartReadReplicaDelete(ctx context.Context, name string) (string, error) {
+	return "", ErrMetricsFileReadOnly
+}
+
+// WaitForOperation always fails; a metrics file has no operations
+func (f *MetricsFile) WaitForOperation(ctx context.Context, operationName string) error {
+	return ErrMetricsFileReadOnly
+}
+
+// RefreshTiers does nothing; the built-in machine type catalog is used
+func (f *MetricsFile) RefreshTiers(ctx context.Context) error {
+	return nil
+}
+
+// GetInstanceMetrics returns the metrics exported for instance
+func (f *MetricsFile) GetInstanceMetrics(ctx context.Context, instance *config.InstanceInfo, cfg *config.Config) (*config.MetricsData, error) {
+	d, ok := f.instances[instance.Name]
+	if !ok || d.Metrics == nil {
+		return nil, fmt.Errorf("no metrics for instance %s in metrics file %s", instance.Name, f.path)
+	}
+	return d.Metrics, nil
+}
+
+// QuotaBudget returns nil; reading a file uses no Monitoring quota
+func (f *MetricsFile) QuotaBudget() *QuotaBudget {
+	return nil
+}
+
+// Close does nothing
+func (f *MetricsFile) Close() error {
+	return nil
+}
@@ -0,0 +1,69 @@
+package cloudsql
+
+import (
+	"context"
+	"fmt"
+
+	cloudresourcemanager "google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/option"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// RequiredPermissions returns the IAM permissions on the project the
+// autoscaler needs with cfg: reading instances and their metrics, and
+// resizing and managing replicas unless it runs read-only
+func RequiredPermissions(cfg *config.Config) []string {
+	permissions := []string{
+		"cloudsql.instances.list",
+		"cloudsql.instances.get",
+		"monitoring.timeSeries.list",
+	}
+	if cfg.ReadOnly {
+		return permissions
+	}
+	permissions = append(permissions, "cloudsql.instances.update")
+	if cfg.ReplicaScaling {
+		permissions = append(permissions, "cloudsql.instances.create", "cloudsql.instances.delete")
+	}
+	return permissions
+}
+
+// PermissionChecker tests which IAM permissions the caller holds on a project
+// with the Resource Manager API. It is safe for concurrent use.
+type PermissionChecker struct {
+	service *cloudresourcemanager.Service
+}
+
+// NewPermissionChecker creates a Resource Manager client. Testing permissions
+// needs no IAM role of its own.
+func NewPermissionChecker(ctx context.Context, opts ...option.ClientOption) (*PermissionChecker, error) {
+	service, err := cloudresourcemanager.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Resource Manager client: %w", err)
+	}
+	return &PermissionChecker{service: service}, nil
+}
+
+// MissingPermissions returns those of permissions the caller does not hold
+// on project, in the order given
+func (p *PermissionChecker) MissingPermissions(ctx context.Context, project string, permissions []string) ([]string, error) {
+	resp, err := p.service.Projects.TestIamPermissions(project, &cloudresourcemanager.TestIamPermissionsRequest{
+		Permissions: permissions,
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to test IAM permissions on project %s: %w", project, err)
+	}
+
+	held := make(map[string]bool, len(resp.Permissions))
+	for _, permission := range resp.Permissions {
+		held[permission] = true
+	}
+	var missing []string
+	for _, permission := range permissions {
+		if !held[permission] {
+			missing = append(missing, permission)
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,189 @@
+package cloudsql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/api/option"
+)
+
+// DefaultPoolIdleTimeout is how long a project's clients stay in a
+// ClientPool unused before they are closed
+const DefaultPoolIdleTimeout = 15 * time.Minute
+
+// ClientPool creates and caches one Cloud SQL Admin and Monitoring client
+// pair per project, so services analyzing many projects authenticate and
+// dial once per project rather than once per analysis. Clients unused for
+// the idle timeout are closed. It is safe for concurrent use.
+type ClientPool struct {
+	mu             sync.Mutex
+	sets           map[string]*clientSet
+	idleTimeout    time.Duration
+	quotaPerMinute int
+	hedgeDelay     time.Duration
+	opts           []option.ClientOption
+	closed         bool
+}
+
+// clientSet is a project's pooled clients
+type clientSet struct {
+	sql      *Client
+	metrics  *MetricsClient
+	refs     int // Callers holding or waiting for the set; held sets never expire
+	lastUsed time.Time
+	ready    chan struct{} // Closed once the clients are dialed, or dialing failed
+	err      error         // Why dialing failed; set before ready is closed
+}
+
+// NewClientPool creates an empty pool. Clients are created with opts and
+// share a Monitoring quota budget of quotaPerMinute calls per project
+// (zero disables budgeting). An idle timeout of zero or less uses
+// DefaultPoolIdleTimeout.
+func NewClientPool(idleTimeout time.Duration, quotaPerMinute int, opts ...option.ClientOption) *ClientPool {
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultPoolIdleTimeout
+	}
+	return &ClientPool{
+		sets:           make(map[string]*clientSet),
+		idleTimeout:    idleTimeout,
+		quotaPerMinute: quotaPerMinute,
+		opts:           opts,
+	}
+}
+
+// SetHedgeDelay enables hedged Monitoring requests on clients the pool creates
+// from now on; see MetricsClient.SetHedgeDelay
+func (p *ClientPool) SetHedgeDelay(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.hedgeDelay = d
+}
+
+// Get returns projectID's clients, creating them on first use. release must
+// be called when the caller is done with them; the clients must not be
+// closed directly. Clients are dialed outside the pool's lock, so a slow dial
+// only holds up callers for the same project.
+func (p *ClientPool) Get(ctx context.Context, projectID string) (sqlClient *Client, metricsClient *MetricsClient, release func(), err error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, nil, nil, errors.New("client pool is closed")
+	}
+	p.expireLocked(time.Now())
+
+	set, ok := p.sets[projectID]
+	if !ok {
+		set = &clientSet{ready: make(chan struct{})}
+		p.sets[projectID] = set
+	}
+	set.refs++
+	hedgeDelay := p.hedgeDelay
+	p.mu.Unlock()
+
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			set.refs--
+			set.lastUsed = time.Now()
+		})
+	}
+
+	if !ok {
+		sqlClient, metricsClient, err := p.dial(ctx, projectID, hedgeDelay)
+		p.mu.Lock()
+		switch {
+		case err != nil:
+			set.err = err
+		case p.closed:
+			// Close ran while dialing and skipped this set
+			_ = metricsClient.Close()
+			set.err = errors.New("client pool is closed")
+		default:
+			set.sql, set.metrics = sqlClient, metricsClient
+		}
+		if set.err != nil && p.sets[projectID] == set {
+			delete(p.sets, projectID)
+		}
+		p.mu.Unlock()
+		close(set.ready)
+	} else {
+		select {
+		case <-set.ready:
+		case <-ctx.Done():
+			release()
+			return nil, nil, nil, ctx.Err()
+		}
+	}
+	if set.err != nil {
+		release()
+		return nil, nil, nil, set.err
+	}
+	return set.sql, set.metrics, release, nil
+}
+
+// dial creates projectID's clients
+func (p *ClientPool) dial(ctx context.Context, projectID string, hedgeDelay time.Duration) (*Client, *MetricsClient, error) {
+	sqlClient, err := NewClient(ctx, projectID, p.opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create Cloud SQL client for project %s: %w", projectID, err)
+	}
+	metricsClient, err := NewMetricsClient(ctx, projectID, p.opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create metrics client for project %s: %w", projectID, err)
+	}
+	metricsClient.SetQuotaBudget(NewQuotaBudget(p.quotaPerMinute))
+	metricsClient.SetHedgeDelay(hedgeDelay)
+	return sqlClient, metricsClient, nil
+}
+
+// expireLocked closes the clients of projects idle for longer than the idle
+// timeout; p.mu must be held
+func (p *ClientPool) expireLocked(now time.Time) {
+	for projectID, set := range p.sets {
+		if set.refs == 0 && now.Sub(set.lastUsed) > p.idleTimeout {
+			_ = set.metrics.Close()
+			delete(p.sets, projectID)
+		}
+	}
+}
+
+// Expire closes the clients of projects idle for longer than the idle
+// timeout. Get does this itself; long-running services that may stop
+// calling Get can also call it periodically.
+func (p *ClientPool) Expire() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.expireLocked(time.Now())
+}
+
+// Len returns how many projects currently have pooled clients
+func (p *ClientPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.sets)
+}
+
+// Close closes every pooled client, including those still held. Get fails
+// after Close.
+func (p *ClientPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var errs []error
+	for projectID, set := range p.sets {
+		if set.metrics == nil {
+			continue // Still dialing; the dialer closes it
+		}
+		if err := set.metrics.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close metrics client for project %s: %w", projectID, err))
+		}
+		delete(p.sets, projectID)
+	}
+	p.closed = true
+	return errors.Join(errs...)
+}
@@ -0,0 +1,202 @@
+package cloudsql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	cloudbilling "google.golang.org/api/cloudbilling/v1"
+	"google.golang.org/api/option"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// cloudSQLService is Cloud SQL's service in the Cloud Billing Catalog
+const cloudSQLService = "services/9662-B51E-5089"
+
+const (
+	priceCacheTTL   = 24 * time.Hour  // How long a SKU listing is reused
+	priceRetryDelay = 5 * time.Minute // How long to wait before retrying a failed SKU listing
+)
+
+// ComputeRates are the on-demand hourly USD prices of a zonal instance's
+// vCPUs and memory
+type ComputeRates struct {
+	VCPU     float64 // $/vCPU/hour
+	MemoryGB float64 // $/GB/hour
+}
+
+// DefaultComputeRates are the built-in list prices, used for regions and
+// editions the Cloud Billing Catalog has not priced
+var DefaultComputeRates = ComputeRates{VCPU: 0.0475, MemoryGB: 0.0080}
+
+// priceKey identifies the rates of an edition in a region
+type priceKey struct {
+	region  string
+	edition config.Edition
+}
+
+// Rates priced from the Cloud Billing Catalog at runtime. They are shared by
+// every PriceCatalog, since list prices do not depend on the project.
+var (
+	pricesMu sync.RWMutex
+	prices   map[priceKey]ComputeRates
+
+	// Serializes listings so concurrent analyses fetch the SKUs once
+	priceFetch struct {
+		sync.Mutex
+		fetchedAt time.Time
+		lastErr   error
+		failedAt  time.Time
+	}
+)
+
+// RatesFor returns the compute rates of edition in region, and whether they
+// came from the Cloud Billing Catalog rather than the built-in list prices
+func RatesFor(region string, edition config.Edition) (ComputeRates, bool) {
+	if edition == "" {
+		edition = config.EditionEnterprise
+	}
+	pricesMu.RLock()
+	defer pricesMu.RUnlock()
+	if rates, ok := prices[priceKey{region, edition}]; ok {
+		return rates, true
+	}
+	return DefaultComputeRates, false
+}
+
+// PriceCatalog prices cost estimates from the Cloud Billing Catalog API's
+// Cloud SQL SKUs. It is safe for concurrent use.
+type PriceCatalog struct {
+	service *cloudbilling.APIService
+}
+
+// NewPriceCatalog creates a Cloud Billing Catalog client. Listing public SKUs
+// needs no IAM role, only the Cloud Billing API enabled on the quota project.
+func NewPriceCatalog(ctx context.Context, opts ...option.ClientOption) (*PriceCatalog, error) {
+	opts = append([]option.ClientOption{option.WithScopes(cloudbilling.CloudBillingReadonlyScope)}, opts...)
+	service, err := cloudbilling.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud Billing client: %w", err)
+	}
+	return &PriceCatalog{service: service}, nil
+}
+
+// Refresh lists Cloud SQL's SKUs and registers their vCPU and memory rates by
+// region and edition, so EstimateMonthlyCost uses them instead of the
+// built-in list prices. The listing is cached for priceCacheTTL; on failure
+// the previous rates (or the built-in ones) stay in effect and the call is
+// not retried, or reported again, for priceRetryDelay.
+func (p *PriceCatalog) Refresh(ctx context.Context) error {
+	priceFetch.Lock()
+	defer priceFetch.Unlock()
+
+	if !priceFetch.fetchedAt.IsZero() && time.Since(priceFetch.fetchedAt) < priceCacheTTL {
+		return nil
+	}
+	if priceFetch.lastErr != nil && time.Since(priceFetch.failedAt) < priceRetryDelay {
+		// Already reported; keep using the previous rates until the retry
+		return nil
+	}
+
+	found := make(map[priceKey]ComputeRates)
+	err := p.service.Services.Skus.List(cloudSQLService).CurrencyCode("USD").Context(ctx).
+		Pages(ctx, func(resp *cloudbilling.ListSkusResponse) error {
+			for _, sku := range resp.Skus {
+				addSKURate(found, sku)
+			}
+			return nil
+		})
+	if err != nil {
+		priceFetch.lastErr = fmt.Errorf("failed to list Cloud SQL SKUs from the Cloud Billing Catalog: %w", err)
+		priceFetch.failedAt = time.Now()
+		return priceFetch.lastErr
+	}
+
+	// A region priced for only vCPUs or only memory borrows the other rate
+	for key, rates := range found {
+		if rates.VCPU == 0 {
+			rates.VCPU = DefaultComputeRates.VCPU
+		}
+		if rates.MemoryGB == 0 {
+			rates.MemoryGB = DefaultComputeRates.MemoryGB
+		}
+		found[key] = rates
+	}
+
+	pricesMu.Lock()
+	prices = found
+	pricesMu.Unlock()
+
+	priceFetch.fetchedAt = time.Now()
+	priceFetch.lastErr = nil
+	return nil
+}
+
+// addSKURate records sku's rate in found if it is the on-demand vCPU or
+// memory price of a zonal MySQL or PostgreSQL instance. SKUs are told apart
+// by their descriptions, such as "Cloud SQL for PostgreSQL: Zonal -
+// Enterprise Plus vCPU in Iowa"; the first SKU seen for a region, edition
+// and resource wins.
+func addSKURate(found map[priceKey]ComputeRates, sku *cloudbilling.Sku) {
+	if sku.Category == nil || sku.Category.UsageType != "OnDemand" || len(sku.PricingInfo) == 0 {
+		return
+	}
+	desc := sku.Description
+	if strings.Contains(desc, "Regional") || strings.Contains(desc, "SQL Server") || strings.Contains(desc, "Licens") {
+		return
+	}
+
+	var memory bool
+	switch {
+	case strings.Contains(desc, "vCPU"):
+	case strings.Contains(desc, "RAM") || strings.Contains(desc, "Memory"):
+		memory = true
+	default:
+		return
+	}
+	edition := config.EditionEnterprise
+	if strings.Contains(desc, "Enterprise Plus") {
+		edition = config.EditionEnterprisePlus
+	}
+
+	rate, ok := skuHourlyRate(sku, memory)
+	if !ok {
+		return
+	}
+	for _, region := range sku.ServiceRegions {
+		key := priceKey{region, edition}
+		rates := found[key]
+		if memory && rates.MemoryGB == 0 {
+			rates.MemoryGB = rate
+		} else if !memory && rates.VCPU == 0 {
+			rates.VCPU = rate
+		}
+		found[key] = rates
+	}
+}
+
+// skuHourlyRate returns the USD unit price of sku's current pricing, which
+// must be per hour, or per GiB-hour for memory. Of tiered rates the last,
+// beyond any free tier, is used.
+func skuHourlyRate(sku *cloudbilling.Sku, memory bool) (float64, bool) {
+	expr := sku.PricingInfo[len(sku.PricingInfo)-1].PricingExpression
+	if expr == nil || len(expr.TieredRates) == 0 {
+		return 0, false
+	}
+	unit := "h"
+	if memory {
+		unit = "GiBy.h"
+	}
+	if expr.UsageUnit != unit {
+		return 0, false
+	}
+	price := expr.TieredRates[len(expr.TieredRates)-1].UnitPrice
+	if price == nil || (price.CurrencyCode != "" && price.CurrencyCode != "USD") {
+		return 0, false
+	}
+	rate := float64(price.Units) + float64(price.Nanos)/1e9
+	return rate, rate > 0
+}
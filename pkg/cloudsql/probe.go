@@ -0,0 +1,164 @@
+package cloudsql
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// Prober checks whether an instance is accepting connections
+type Prober interface {
+	Probe(ctx context.Context, instance *config.InstanceInfo) (*ProbeResult, error)
+}
+
+// ProbeResult describes the outcome of a connectivity probe
+type ProbeResult struct {
+	Address  string
+	Attempts int
+	Latency  time.Duration
+}
+
+// TCPProber probes an instance by connecting to its database port and
+// opening the engine's wire protocol handshake, retrying until the timeout
+// elapses. An open port alone does not show that the database accepts
+// sessions, so the server must answer: PostgreSQL an SSLRequest, MySQL with
+// its greeting, and SQL Server a TDS pre-login. Credentials are never sent.
+// The prober dials the instance's IP address directly, so instances only
+// reachable through the Cloud SQL connectors or Auth Proxy cannot be probed.
+type TCPProber struct {
+	IPType   string        // Address type to probe (PRIMARY, PRIVATE, OUTGOING)
+	Port     int           // Port to probe; zero uses the engine default
+	Timeout  time.Duration // Overall time allowed for the instance to accept connections
+	Interval time.Duration // Delay between attempts
+}
+
+// NewTCPProber creates a TCP prober from the probe settings in cfg
+func NewTCPProber(cfg *config.Config) *TCPProber {
+	return &TCPProber{
+		IPType:   cfg.ProbeIPType,
+		Port:     cfg.ProbePort,
+		Timeout:  cfg.ProbeTimeout,
+		Interval: cfg.ProbeInterval,
+	}
+}
+
+// probeHandshakeTimeout bounds how long the server has to answer the
+// handshake of an attempt
+const probeHandshakeTimeout = 10 * time.Second
+
+// Probe connects to the instance until its database answers the handshake or
+// the timeout expires
+func (p *TCPProber) Probe(ctx context.Context, instance *config.InstanceInfo) (*ProbeResult, error) {
+	if p.Interval <= 0 {
+		return nil, fmt.Errorf("invalid probe interval %v: must be positive", p.Interval)
+	}
+	ip, err := probeAddress(instance, p.IPType)
+	if err != nil {
+		return nil, err
+	}
+
+	port := p.Port
+	if port == 0 {
+		port = config.DefaultPort(instance.DatabaseVersion)
+	}
+	address := net.JoinHostPort(ip, strconv.Itoa(port))
+
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	result := &ProbeResult{Address: address}
+	start := time.Now()
+	for {
+		result.Attempts++
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err == nil {
+			err = handshake(conn, config.ParseEngine(instance.DatabaseVersion))
+			conn.Close()
+		}
+		if err == nil {
+			result.Latency = time.Since(start)
+			return result, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return result, fmt.Errorf("instance %s not accepting connections on %s after %d attempts: %w",
+				instance.Name, address, result.Attempts, err)
+		case <-time.After(p.Interval):
+		}
+	}
+}
+
+// handshake opens engine's wire protocol handshake on conn and checks that
+// the server answers it as a database accepting sessions does
+func handshake(conn net.Conn, engine config.DatabaseEngine) error {
+	if err := conn.SetDeadline(time.Now().Add(probeHandshakeTimeout)); err != nil {
+		return err
+	}
+	switch engine {
+	case config.EngineMySQL:
+		// The server greets first: a packet header, then protocol version 10,
+		// or an error packet (0xff) when it refuses connections
+		reply := make([]byte, 5)
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return fmt.Errorf("no MySQL greeting: %w", err)
+		}
+		if reply[4] != 10 {
+			return fmt.Errorf("MySQL refused the connection (packet type %#x)", reply[4])
+		}
+		return nil
+	case config.EngineSQLServer:
+		// A TDS pre-login packet with only the VERSION option; the server
+		// answers with a tabular result packet (0x04)
+		payload := []byte{0x00, 0x00, 0x06, 0x00, 0x06, 0xff, 0, 0, 0, 0, 0, 0}
+		packet := append([]byte{0x12, 0x01, 0, 0, 0, 0, 0x01, 0x00}, payload...)
+		binary.BigEndian.PutUint16(packet[2:4], uint16(len(packet)))
+		if _, err := conn.Write(packet); err != nil {
+			return err
+		}
+		reply := make([]byte, 1)
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return fmt.Errorf("no TDS pre-login response: %w", err)
+		}
+		if reply[0] != 0x04 {
+			return fmt.Errorf("SQL Server answered the pre-login with packet type %#x", reply[0])
+		}
+		return nil
+	default:
+		// An SSLRequest, which the postmaster answers with S or N before
+		// authentication, or E when it cannot accept the connection
+		request := make([]byte, 8)
+		binary.BigEndian.PutUint32(request[0:4], 8)
+		binary.BigEndian.PutUint32(request[4:8], 80877103)
+		if _, err := conn.Write(request); err != nil {
+			return err
+		}
+		reply := make([]byte, 1)
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return fmt.Errorf("no PostgreSQL response to SSLRequest: %w", err)
+		}
+		if reply[0] != 'S' && reply[0] != 'N' {
+			return fmt.Errorf("PostgreSQL refused the connection (response %q)", reply[0])
+		}
+		return nil
+	}
+}
+
+// probeAddress selects the instance IP address of the requested type
+func probeAddress(instance *config.InstanceInfo, ipType string) (string, error) {
+	if ipType == "" {
+		ipType = "PRIMARY"
+	}
+	if ip, ok := instance.IPAddresses[strings.ToUpper(ipType)]; ok && ip != "" {
+		return ip, nil
+	}
+	return "", fmt.Errorf("instance %s has no %s IP address to probe", instance.Name, ipType)
+}
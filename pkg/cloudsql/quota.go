@@ -0,0 +1,180 @@
+package cloudsql
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Degradation levels applied when the Monitoring quota budget is under pressure
+const (
+	DegradeNone   = 1 // Full granularity, normal cache TTL
+	DegradeCoarse = 2 // Double the alignment period and cache TTL
+	DegradeSevere = 4 // Quadruple the alignment period and cache TTL
+)
+
+// throttlePenalty is how long the budget stays degraded after a 429 response
+const throttlePenalty = 2 * time.Minute
+
+// QuotaBudget tracks Cloud Monitoring ListTimeSeries calls per minute and
+// applies backpressure before the project quota is exhausted
+type QuotaBudget struct {
+	mu             sync.Mutex
+	limit          int
+	calls          []time.Time
+	throttledAt    time.Time
+	totalCalls     int
+	totalWaits     int
+	totalThrottled int
+}
+
+// NewQuotaBudget creates a budget allowing limit calls per rolling minute.
+// A limit of zero or less disables budgeting.
+func NewQuotaBudget(limit int) *QuotaBudget {
+	return &QuotaBudget{limit: limit}
+}
+
+// Acquire blocks until a call can be made within the budget or ctx is done
+func (q *QuotaBudget) Acquire(ctx context.Context) error {
+	if q == nil || q.limit <= 0 {
+		return nil
+	}
+
+	for {
+		q.mu.Lock()
+		now := time.Now()
+		q.pruneLocked(now)
+		if len(q.calls) < q.effectiveLimitLocked(now) {
+			q.calls = append(q.calls, now)
+			q.totalCalls++
+			q.mu.Unlock()
+			return nil
+		}
+		// Wait until the oldest call leaves the rolling window
+		wait := q.calls[0].Add(time.Minute).Sub(now)
+		q.totalWaits++
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// TryAcquire records a call if one can be made within the budget right now,
+// without waiting
+func (q *QuotaBudget) TryAcquire() bool {
+	if q == nil || q.limit <= 0 {
+		return true
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	q.pruneLocked(now)
+	if len(q.calls) >= q.effectiveLimitLocked(now) {
+		return false
+	}
+	q.calls = append(q.calls, now)
+	q.totalCalls++
+	return true
+}
+
+// RecordThrottle notes that the API rejected a call with a quota error, which
+// halves the effective budget and raises the degradation level for a while
+func (q *QuotaBudget) RecordThrottle() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.throttledAt = time.Now()
+	q.totalThrottled++
+}
+
+// Pressure returns the fraction of the per-minute budget currently in use
+func (q *QuotaBudget) Pressure() float64 {
+	if q == nil || q.limit <= 0 {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	q.pruneLocked(now)
+	return float64(len(q.calls)) / float64(q.effectiveLimitLocked(now))
+}
+
+// DegradeFactor returns the multiplier to apply to metric granularity and
+// cache TTLs given current budget pressure
+func (q *QuotaBudget) DegradeFactor() int {
+	if q == nil || q.limit <= 0 {
+		return DegradeNone
+	}
+
+	q.mu.Lock()
+	throttled := time.Since(q.throttledAt) < throttlePenalty
+	q.mu.Unlock()
+	if throttled {
+		return DegradeSevere
+	}
+
+	if q.Pressure() >= 0.75 {
+		return DegradeCoarse
+	}
+	return DegradeNone
+}
+
+// Limit returns the configured calls-per-minute limit
+func (q *QuotaBudget) Limit() int {
+	if q == nil {
+		return 0
+	}
+	return q.limit
+}
+
+// QuotaStats is a point-in-time view of budget usage
+type QuotaStats struct {
+	Limit         int     `json:"limit_per_minute"`
+	Pressure      float64 `json:"pressure"`
+	DegradeFactor int     `json:"degrade_factor"`
+	TotalCalls    int     `json:"total_calls"`
+	TotalWaits    int     `json:"total_waits"`
+	TotalThrottle int     `json:"total_throttled"`
+}
+
+// Stats returns current budget statistics
+func (q *QuotaBudget) Stats() QuotaStats {
+	if q == nil {
+		return QuotaStats{DegradeFactor: DegradeNone}
+	}
+	stats := QuotaStats{
+		Limit:         q.limit,
+		Pressure:      q.Pressure(),
+		DegradeFactor: q.DegradeFactor(),
+	}
+	q.mu.Lock()
+	stats.TotalCalls = q.totalCalls
+	stats.TotalWaits = q.totalWaits
+	stats.TotalThrottle = q.totalThrottled
+	q.mu.Unlock()
+	return stats
+}
+
+// pruneLocked drops call records older than one minute
+func (q *QuotaBudget) pruneLocked(now time.Time) {
+	cutoff := now.Add(-time.Minute)
+	i := 0
+	for i < len(q.calls) && q.calls[i].Before(cutoff) {
+		i++
+	}
+	q.calls = q.calls[i:]
+}
+
+// effectiveLimitLocked returns the limit, halved while recovering from a throttle
+func (q *QuotaBudget) effectiveLimitLocked(now time.Time) int {
+	if now.Sub(q.throttledAt) < throttlePenalty {
+		return max(1, q.limit/2)
+	}
+	return q.limit
+}
@@ -0,0 +1,108 @@
+package cloudsql
+
+// ReasonCode is a stable, machine-readable explanation of a scaling decision
+// or deferral. Codes are never renamed or reused; new ones may be added.
+type ReasonCode string
+
+const (
+	ReasonCPUP95High        ReasonCode = "CPU_P95_HIGH"        // P95 CPU utilization is above the scale-up threshold
+	ReasonMemoryP95High     ReasonCode = "MEMORY_P95_HIGH"     // P95 memory pressure is above the scale-up threshold
+	ReasonCPUTrendRising    ReasonCode = "CPU_TREND_RISING"    // CPU is climbing toward the scale-up threshold
+	ReasonMemoryTrendRising ReasonCode = "MEMORY_TREND_RISING" // Memory is climbing toward the scale-up threshold
+	ReasonCPUP95Low         ReasonCode = "CPU_P95_LOW"         // P95 CPU utilization is below the scale-down threshold
+	ReasonMemoryP95Low      ReasonCode = "MEMORY_P95_LOW"      // P95 memory utilization is below the scale-down threshold
+	ReasonWithinTarget      ReasonCode = "WITHIN_TARGET"       // Utilization is between the thresholds
+	ReasonInsufficientData  ReasonCode = "INSUFFICIENT_DATA"   // Too few data points to decide
+	ReasonAtMaxSize         ReasonCode = "AT_MAX_SIZE"         // No larger machine type is offered
+	ReasonAtMinSize         ReasonCode = "AT_MIN_SIZE"         // No smaller machine type is offered
+	ReasonFailoverReplica   ReasonCode = "FAILOVER_REPLICA"    // Failover/DR replicas follow their primary
+	ReasonPreScale          ReasonCode = "PRESCALE"            // Operator requested a pre-scale through the daemon API
+	ReasonPreScaleRevert    ReasonCode = "PRESCALE_REVERT"     // A pre-scale ended and the instance returns to its original tier
+	ReasonUnsupportedTier   ReasonCode = "UNSUPPORTED_TIER"    // Tier is not in the machine type catalog; advisory only
+	ReasonScaleUpRevert     ReasonCode = "SCALE_UP_REVERT"     // An emergency scale-up is no longer needed and is reverted
+	ReasonRollback          ReasonCode = "SCALE_DOWN_ROLLBACK" // A scale-down regressed and is rolled back
+	ReasonRollbackHold      ReasonCode = "ROLLBACK_HOLD"       // A scale-down is held back above the machine type a rollback left
+	ReasonScheduledScaleUp  ReasonCode = "SCHEDULED_SCALE_UP"  // A schedule window needs at least a larger machine type
+	ReasonScheduleHold      ReasonCode = "SCHEDULE_HOLD"       // A schedule window holds back a scale-down below its machine type
+	ReasonScheduledProfile  ReasonCode = "SCHEDULED_PROFILE"   // The decision used the thresholds of a schedule window's profile
+	ReasonTargetDenylisted  ReasonCode = "TARGET_DENYLISTED"   // The nearest machine type is denylisted; another or none was chosen
+	ReasonTargetNotAllowed  ReasonCode = "TARGET_NOT_ALLOWED"  // The nearest machine type is not allowlisted; another or none was chosen
+	ReasonForecastBreach    ReasonCode = "FORECAST_BREACH"     // Utilization is forecast to cross the scale-up threshold within the horizon
+	ReasonForecastHold      ReasonCode = "FORECAST_HOLD"       // A scale-down is held back because the smaller machine type would cross the threshold within the horizon
+
+	// Replication lag of a read replica, see Config.MaxReplicaLag
+	ReasonReplicaLagSustained ReasonCode = "REPLICA_LAG_SUSTAINED" // Replication lag stayed above the maximum throughout the stable duration
+	ReasonReplicaLagHold      ReasonCode = "REPLICA_LAG_HOLD"      // A scale-down is held back because the replica's P95 replication lag is above the maximum
+
+	// Peak connections would saturate the smaller machine type's default max_connections
+	ReasonConnectionCapacity ReasonCode = "CONNECTION_CAPACITY"
+
+	// The smaller machine type falls short of a minimum the workload sets, see CheckTargetSizing in package rules
+	ReasonTargetUndersized ReasonCode = "TARGET_UNDERSIZED"
+
+	// Disk IOPS or throughput nears the data disk's limit, see Config.IOBoundThreshold
+	ReasonIOBound     ReasonCode = "IO_BOUND"      // A CPU scale-up is held; the larger machine type does not raise the disk limit the instance is at
+	ReasonIOBoundHold ReasonCode = "IO_BOUND_HOLD" // A scale-down is held back on an IO-bound instance
+
+	// Custom conditions, see Config.ScaleUpRule and Config.ScaleDownRule
+	ReasonScaleUpRule   ReasonCode = "SCALE_UP_RULE"   // The custom scale-up rule matched
+	ReasonScaleDownRule ReasonCode = "SCALE_DOWN_RULE" // The custom scale-down rule matched
+
+	// Policy overrides teams set with autoscaler labels, see LabelProfile
+	ReasonLabelProfile ReasonCode = "LABEL_PROFILE" // The decision used the thresholds of the profile the instance's label names
+	ReasonMaxTier      ReasonCode = "MAX_TIER"      // The next larger machine type is above the instance's max-tier label
+
+	// Fleet signals merged into the decision, see FleetSignal
+	ReasonFleetAgrees ReasonCode = "FLEET_AGREES" // A Recommender API recommendation resizes the instance the same way
+
+	// Decision hook verdicts, see package decisionhook
+	ReasonDecisionHookDenied      ReasonCode = "DECISION_HOOK_DENIED"      // The decision hook denied the resize
+	ReasonDecisionHookModified    ReasonCode = "DECISION_HOOK_MODIFIED"    // The decision hook changed the target machine type
+	ReasonDecisionHookUnavailable ReasonCode = "DECISION_HOOK_UNAVAILABLE" // The decision hook failed and the resize is held
+
+	// Cold start codes, see ColdStart in package analyzer
+	ReasonColdStart     ReasonCode = "COLD_START"      // The decision is based on less history than the metrics period
+	ReasonColdStartHold ReasonCode = "COLD_START_HOLD" // A scale-down is held back until more history accumulates
+
+	// Storage decision codes, see StorageDecision
+	ReasonStorageHigh         ReasonCode = "STORAGE_HIGH"          // Data disk usage is above the storage threshold
+	ReasonStorageWithinTarget ReasonCode = "STORAGE_WITHIN_TARGET" // Data disk usage is below the storage threshold
+	ReasonStorageAutoResize   ReasonCode = "STORAGE_AUTO_RESIZE"   // Cloud SQL grows the disk itself
+	ReasonStorageAtMaxSize    ReasonCode = "STORAGE_AT_MAX_SIZE"   // Disk is already at the maximum size
+
+	// Read replica count decision codes, see ReplicaDecision
+	ReasonReplicaCPUHigh       ReasonCode = "REPLICA_CPU_HIGH"       // Read replicas' mean P95 CPU is above the replica threshold
+	ReasonReplicaLagHigh       ReasonCode = "REPLICA_LAG_HIGH"       // A read replica's P95 replication lag is above the maximum
+	ReasonReplicaCPULow        ReasonCode = "REPLICA_CPU_LOW"        // Read replicas' mean P95 CPU is below the scale-down threshold
+	ReasonReplicaWithinTarget  ReasonCode = "REPLICA_WITHIN_TARGET"  // Read replica load is between the thresholds
+	ReasonReplicaAtMaxCount    ReasonCode = "REPLICA_AT_MAX_COUNT"   // Primary already has the most read replicas allowed
+	ReasonReplicaAtMinCount    ReasonCode = "REPLICA_AT_MIN_COUNT"   // Primary already has the fewest read replicas allowed
+	ReasonReplicaNotRemovable  ReasonCode = "REPLICA_NOT_REMOVABLE"  // No read replica was created by the autoscaler
+	ReasonReplicaIncompleteSet ReasonCode = "REPLICA_INCOMPLETE_SET" // Not every read replica was analyzed this run
+
+	// Deferral codes, see DeferKind in package analyzer
+	ReasonCooldownActive  ReasonCode = "COOLDOWN_ACTIVE"          // Instance is within its post-scaling cooldown
+	ReasonIntervalPending ReasonCode = "INTERVAL_PENDING"         // Waiting for the minimum interval avoids downtime
+	ReasonBlackoutActive  ReasonCode = "BLACKOUT_ACTIVE"          // Operation would start inside a blackout window
+	ReasonFreezeActive    ReasonCode = "FREEZE_ACTIVE"            // Instance is covered by a scaling freeze
+	ReasonDowntimeBundled ReasonCode = "DOWNTIME_BUNDLED"         // Waiting for the shared downtime window
+	ReasonMaintenanceWait ReasonCode = "MAINTENANCE_NIGHT"        // Waiting for the weekly maintenance night
+	ReasonOperationLimit  ReasonCode = "OPERATION_LIMIT"          // Cycle operation limit reached
+	ReasonCostCapReached  ReasonCode = "COST_CAP_REACHED"         // Cycle cost increase cap reached
+	ReasonInvalidTarget   ReasonCode = "INVALID_TARGET"           // Target machine type failed validation
+	ReasonRevertReview    ReasonCode = "REVERT_REVIEW"            // Reverts are recommended only and await an operator
+	ReasonApprovalPending ReasonCode = "APPROVAL_PENDING"         // Awaiting an operator's approval
+	ReasonUnstable        ReasonCode = "RECOMMENDATION_UNSTABLE"  // Recommendation not yet made enough consecutive cycles
+	ReasonDowntimeBudget  ReasonCode = "DOWNTIME_BUDGET_EXCEEDED" // Resize would exceed the instance's monthly downtime budget
+
+	// Waiting for the instance's own Cloud SQL maintenance window
+	ReasonMaintenanceWindow ReasonCode = "MAINTENANCE_WINDOW"
+)
+
+// ReasonCode returns the decision's primary reason code, or "" if it has none
+func (d *ScalingDecision) ReasonCode() ReasonCode {
+	if len(d.ReasonCodes) == 0 {
+		return ""
+	}
+	return d.ReasonCodes[0]
+}
@@ -0,0 +1,69 @@
+package cloudsql
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// ReplicaDecision represents a read replica count recommendation for a
+// primary. Change is +1 to add a replica, -1 to remove RemoveReplica and 0
+// to leave the replicas as they are.
+type ReplicaDecision struct {
+	ID               string       `json:"id"` // Unique decision identifier, attached to applied operations
+	Primary          string       `json:"primary"`
+	Change           int          `json:"change"`
+	CurrentCount     int          `json:"current_count"`
+	RecommendedCount int          `json:"recommended_count"`
+	Tier             string       `json:"tier,omitempty"`           // Machine type of an added replica
+	RemoveReplica    string       `json:"remove_replica,omitempty"` // Replica to delete when removing one
+	CPUP95           float64      `json:"cpu_p95"`                  // Mean P95 CPU across the read replicas
+	LagP95Seconds    float64      `json:"lag_p95_seconds"`          // Highest P95 replication lag of a read replica
+	Reason           string       `json:"reason"`
+	ReasonCodes      []ReasonCode `json:"reason_codes,omitempty"` // Machine-readable reasons, primary first
+	EstimatedCost    float64      `json:"estimated_monthly_cost_increase"`
+}
+
+// ShouldChange reports whether the decision adds or removes a replica
+func (d *ReplicaDecision) ShouldChange() bool {
+	return d.Change != 0
+}
+
+// ReasonCode returns the decision's primary reason code, or "" if it has none
+func (d *ReplicaDecision) ReasonCode() ReasonCode {
+	if len(d.ReasonCodes) == 0 {
+		return ""
+	}
+	return d.ReasonCodes[0]
+}
+
+// IsReadReplica reports whether instance serves reads for a primary, as
+// opposed to being a failover or DR target
+func IsReadReplica(instance *config.InstanceInfo) bool {
+	return instance.PrimaryInstance != "" && !instance.IsFailoverReplica
+}
+
+// IsAutoscalerReplica reports whether the autoscaler created the replica
+// with these labels, and so may delete it again
+func IsAutoscalerReplica(labels map[string]string) bool {
+	return labels[LabelManagedBy] == managedByValue && labels[LabelReplicaCreatedAt] != ""
+}
+
+// ReplicaLabels returns the labels to attach to a read replica created for decision
+func ReplicaLabels(decision *ReplicaDecision, at time.Time) map[string]string {
+	return map[string]string{
+		LabelManagedBy:        managedByValue,
+		LabelDecisionID:       decision.ID,
+		LabelReplicaCreatedAt: strconv.FormatInt(at.Unix(), 10),
+	}
+}
+
+// ReplicaName returns the name of the read replica created for decision
+func ReplicaName(decision *ReplicaDecision) string {
+	suffix := decision.ID
+	if len(suffix) > 6 {
+		suffix = suffix[:6]
+	}
+	return decision.Primary + "-rr-" + suffix
+}
@@ -0,0 +1,91 @@
+package cloudsql
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	sqladmin "google.golang.org/api/sqladmin/v1"
+)
+
+// PreservedSettings captures instance settings that a tier change must never alter
+type PreservedSettings struct {
+	Zone             string
+	SecondaryZone    string
+	AvailabilityType string
+	Edition          string
+	ActivationPolicy string
+	DataDiskType     string
+	DataDiskSizeGb   int64
+	DatabaseFlags    map[string]string
+}
+
+// GetPreservedSettings reads the settings of an instance that must survive scaling
+func (c *Client) GetPreservedSettings(ctx context.Context, instanceName string) (*PreservedSettings, error) {
+	instance, err := c.Service.Instances.Get(c.projectID, instanceName).Context(ctx).Do()
+	if err != nil {
+		return nil, c.classifyAPIError("get instance", instanceName, err)
+	}
+	return preservedSettingsFrom(instance), nil
+}
+
+// preservedSettingsFrom extracts preserved settings from an Admin API instance
+func preservedSettingsFrom(instance *sqladmin.DatabaseInstance) *PreservedSettings {
+	p := &PreservedSettings{DatabaseFlags: make(map[string]string)}
+	settings := instance.Settings
+	if settings == nil {
+		return p
+	}
+
+	if settings.LocationPreference != nil {
+		p.Zone = settings.LocationPreference.Zone
+		p.SecondaryZone = settings.LocationPreference.SecondaryZone
+	}
+	p.AvailabilityType = settings.AvailabilityType
+	p.Edition = settings.Edition
+	p.ActivationPolicy = settings.ActivationPolicy
+	p.DataDiskType = settings.DataDiskType
+	p.DataDiskSizeGb = settings.DataDiskSizeGb
+	for _, flag := range settings.DatabaseFlags {
+		p.DatabaseFlags[flag.Name] = flag.Value
+	}
+	return p
+}
+
+// Diff returns a description of every preserved setting that differs in after
+func (p *PreservedSettings) Diff(after *PreservedSettings) []string {
+	var diffs []string
+	compare := func(name, before, now string) {
+		if before != now {
+			diffs = append(diffs, fmt.Sprintf("%s changed from %q to %q", name, before, now))
+		}
+	}
+
+	compare("zone", p.Zone, after.Zone)
+	compare("secondary zone", p.SecondaryZone, after.SecondaryZone)
+	compare("availability type", p.AvailabilityType, after.AvailabilityType)
+	compare("edition", p.Edition, after.Edition)
+	compare("activation policy", p.ActivationPolicy, after.ActivationPolicy)
+	compare("data disk type", p.DataDiskType, after.DataDiskType)
+
+	// Storage auto-resize may legitimately grow the disk, but never shrink it
+	if after.DataDiskSizeGb < p.DataDiskSizeGb {
+		diffs = append(diffs, fmt.Sprintf("data disk size shrank from %d GB to %d GB", p.DataDiskSizeGb, after.DataDiskSizeGb))
+	}
+
+	names := make([]string, 0, len(p.DatabaseFlags)+len(after.DatabaseFlags))
+	for name := range p.DatabaseFlags {
+		names = append(names, name)
+	}
+	for name := range after.DatabaseFlags {
+		if _, ok := p.DatabaseFlags[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		compare("database flag "+name, p.DatabaseFlags[name], after.DatabaseFlags[name])
+	}
+
+	return diffs
+}
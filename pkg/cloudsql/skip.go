@@ -0,0 +1,84 @@
+package cloudsql
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SkipReason explains why an instance was not analyzed
+type SkipReason string
+
+const (
+	SkipPermissionDenied   SkipReason = "permission_denied"   // Caller lacks access to the instance or its metrics
+	SkipNotFound           SkipReason = "not_found"           // Instance was deleted between listing and analysis
+	SkipStopped            SkipReason = "stopped"             // Instance was stopped (activation policy NEVER)
+	SkipNotRunnable        SkipReason = "not_runnable"        // Instance is suspended, in maintenance or being created
+	SkipExcludedByLabel    SkipReason = "excluded_by_label"   // Instance opted out via the exclude label
+	SkipMetricsUnavailable SkipReason = "metrics_unavailable" // Cloud Monitoring returned no usable data
+	SkipAnalysisTimeout    SkipReason = "analysis_timeout"    // Analysis did not finish within the per-instance timeout
+	SkipAPIError           SkipReason = "api_error"           // Any other API failure
+)
+
+// LabelExclude is the user label that opts an instance out of autoscaling when
+// set to "true"
+const LabelExclude = "cloudsql-autoscaler-exclude"
+
+// ErrAnalysisTimeout is returned for an instance whose analysis did not
+// finish within the per-instance timeout
+var ErrAnalysisTimeout = errors.New("analysis timed out")
+
+// SkippedInstance records an instance that was not analyzed and why
+type SkippedInstance struct {
+	Name   string     `json:"instance"`
+	Reason SkipReason `json:"reason"`
+	Detail string     `json:"detail,omitempty"`
+	Hint   string     `json:"hint,omitempty"` // Remediation for API errors, or what to do with a long-stopped instance
+
+	StoppedAt      *time.Time `json:"stopped_at,omitempty"`      // When a stopped instance was stopped, if known
+	DeletionReview bool       `json:"deletion_review,omitempty"` // Stopped longer than Config.StoppedReviewAge
+}
+
+// NewSkippedInstance classifies err into a skip record for the named instance
+func NewSkippedInstance(name string, err error) SkippedInstance {
+	return SkippedInstance{Name: name, Reason: ClassifySkip(err), Detail: err.Error(), Hint: Hint(err)}
+}
+
+// ClassifySkip maps an error from the Cloud SQL Admin or Cloud Monitoring
+// APIs to a skip reason
+func ClassifySkip(err error) SkipReason {
+	switch {
+	case errors.Is(err, ErrPermissionDenied):
+		return SkipPermissionDenied
+	case errors.Is(err, ErrInstanceNotFound):
+		return SkipNotFound
+	case errors.Is(err, ErrAnalysisTimeout):
+		return SkipAnalysisTimeout
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case http.StatusForbidden, http.StatusUnauthorized:
+			return SkipPermissionDenied
+		case http.StatusNotFound:
+			return SkipNotFound
+		}
+		return SkipAPIError
+	}
+
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.PermissionDenied, codes.Unauthenticated:
+			return SkipPermissionDenied
+		case codes.NotFound:
+			return SkipMetricsUnavailable
+		}
+	}
+
+	return SkipAPIError
+}
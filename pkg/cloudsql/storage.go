@@ -0,0 +1,118 @@
+package cloudsql
+
+import "github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+
+// PlatformMaxDiskSizeGB is the largest data disk Cloud SQL provisions
+const PlatformMaxDiskSizeGB = 65536
+
+// StorageDecision represents a data disk size recommendation. Disks can only
+// grow, so a decision never recommends a smaller size.
+type StorageDecision struct {
+	ID                string       `json:"id"` // Unique decision identifier, attached to applied operations
+	ShouldResize      bool         `json:"should_resize"`
+	CurrentSizeGB     int64        `json:"current_size_gb"`
+	RecommendedSizeGB int64        `json:"recommended_size_gb"`
+	UsedGB            float64      `json:"used_gb"`
+	UsedPct           float64      `json:"used_pct"`
+	Reason            string       `json:"reason"`
+	ReasonCodes       []ReasonCode `json:"reason_codes,omitempty"` // Machine-readable reasons, primary first
+	EstimatedCost     float64      `json:"estimated_monthly_cost_increase"`
+}
+
+// ReasonCode returns the decision's primary reason code, or "" if it has none
+func (d *StorageDecision) ReasonCode() ReasonCode {
+	if len(d.ReasonCodes) == 0 {
+		return ""
+	}
+	return d.ReasonCodes[0]
+}
+
+// DiskAdvisory quantifies an over-provisioned data disk. Cloud SQL disks
+// cannot shrink in place, so the savings are only realized by migrating to a
+// new instance with a smaller disk; advisories are reported for capacity
+// planning and never applied.
+type DiskAdvisory struct {
+	CurrentSizeGB     int64   `json:"current_size_gb"`
+	UsedGB            float64 `json:"used_gb"`
+	UsedPct           float64 `json:"used_pct"`
+	GrowthGBPerMonth  float64 `json:"growth_gb_per_month"`
+	ProjectedUsedGB   float64 `json:"projected_used_gb"` // Usage at the end of the growth horizon
+	RecommendedSizeGB int64   `json:"recommended_size_gb"`
+	ReclaimableGB     int64   `json:"reclaimable_gb"`
+	EstimatedSavings  float64 `json:"estimated_monthly_savings"`
+	Reason            string  `json:"reason"`
+}
+
+// DiskLimits are the IOPS and throughput a data disk sustains. Persistent
+// disk performance grows with the disk's size up to a cap set by the
+// instance's vCPUs; BySize reports whether the size is what limits it, so a
+// larger machine type would not raise the limits.
+type DiskLimits struct {
+	IOPS           float64 `json:"iops"`
+	ThroughputMBps float64 `json:"throughput_mbps"`
+	BySize         bool    `json:"by_size"`
+}
+
+// Persistent disk performance per GB provisioned
+var diskPerGB = map[string]struct{ iops, mbps float64 }{
+	"PD_SSD": {30, 0.48},
+	"PD_HDD": {0.75, 0.12},
+}
+
+// ssdVCPUCaps are the SSD persistent disk limits of an instance by vCPU
+// count: the last row whose vCPUs the instance has applies. HDD disks are
+// capped by the first row.
+var ssdVCPUCaps = []struct {
+	vcpus      int
+	iops, mbps float64
+}{
+	{1, 15000, 240},
+	{8, 15000, 800},
+	{16, 25000, 1200},
+	{32, 60000, 1200},
+	{64, 100000, 1200},
+}
+
+// DiskLimitsFor returns the limits of a diskType data disk of sizeGB on an
+// instance with cpus vCPUs. Unknown disk types are treated as SSD.
+func DiskLimitsFor(diskType string, sizeGB int64, cpus int) DiskLimits {
+	perGB, ok := diskPerGB[diskType]
+	if !ok {
+		perGB = diskPerGB["PD_SSD"]
+	}
+	vcpuCap := ssdVCPUCaps[0]
+	if diskType != "PD_HDD" {
+		for _, row := range ssdVCPUCaps {
+			if cpus >= row.vcpus {
+				vcpuCap = row
+			}
+		}
+	}
+
+	iops, mbps := float64(sizeGB)*perGB.iops, float64(sizeGB)*perGB.mbps
+	return DiskLimits{
+		IOPS:           min(iops, vcpuCap.iops),
+		ThroughputMBps: min(mbps, vcpuCap.mbps),
+		BySize:         iops <= vcpuCap.iops && mbps <= vcpuCap.mbps,
+	}
+}
+
+// EstimateStorageCost estimates the monthly cost increase of growing a disk
+// of diskType by increaseGB
+func EstimateStorageCost(diskType string, increaseGB int64) float64 {
+	// Rough list prices; actual pricing varies by region
+	rate := 0.17 // $/GB/month for PD_SSD (example)
+	if diskType == "PD_HDD" {
+		rate = 0.09
+	}
+	return float64(increaseGB) * rate
+}
+
+// StorageUsedPct returns how full instance's data disk is according to
+// summary, or 0 if usage or the disk size is unknown
+func StorageUsedPct(instance *config.InstanceInfo, summary *config.MetricsSummary) float64 {
+	if instance.DiskSizeGB <= 0 || summary == nil || summary.DiskUsedGB <= 0 {
+		return 0
+	}
+	return summary.DiskUsedGB / float64(instance.DiskSizeGB) * 100
+}
@@ -0,0 +1,145 @@
+package cloudsql
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+const (
+	tierCacheTTL   = 24 * time.Hour  // How long a tiers.list response is reused
+	tierRetryDelay = 5 * time.Minute // How long to wait before retrying a failed tiers.list
+)
+
+// tierCache holds the RAM of every tier from the last tiers.list call
+type tierCache struct {
+	mu        sync.Mutex
+	ram       map[string]int64    // Bytes by tier name
+	regions   map[string][]string // Regions offering each tier
+	fetchedAt time.Time
+	lastErr   error
+	failedAt  time.Time
+}
+
+// RefreshTiers loads the project's tiers from tiers.list and registers them
+// as discovered machine types, so CPU and memory come from the API rather
+// than the static registry. The response is cached for tierCacheTTL; on
+// failure the previous catalog (or the static registry) stays in effect and
+// the call is not retried, or reported again, for tierRetryDelay.
+func (c *Client) RefreshTiers(ctx context.Context) error {
+	c.tiers.mu.Lock()
+	defer c.tiers.mu.Unlock()
+
+	if c.tiers.ram != nil && time.Since(c.tiers.fetchedAt) < tierCacheTTL {
+		return nil
+	}
+	if c.tiers.lastErr != nil && time.Since(c.tiers.failedAt) < tierRetryDelay {
+		// Already reported; keep using the previous catalog until the retry
+		return nil
+	}
+
+	resp, err := c.Service.Tiers.List(c.projectID).Context(ctx).Do()
+	if err != nil {
+		c.tiers.lastErr = c.classifyAPIError("list tiers", "", err)
+		c.tiers.failedAt = time.Now()
+		return c.tiers.lastErr
+	}
+
+	ram := make(map[string]int64, len(resp.Items))
+	regions := make(map[string][]string, len(resp.Items))
+	var types []config.MachineType
+	for _, t := range resp.Items {
+		ram[t.Tier] = t.RAM
+		regions[t.Tier] = t.Region
+		if mt, ok := config.MachineTypeFromTier(t.Tier, t.RAM); ok {
+			types = append(types, mt)
+		}
+	}
+	config.SetDiscoveredMachineTypes(types)
+
+	c.tiers.ram = ram
+	c.tiers.regions = regions
+	c.tiers.fetchedAt = time.Now()
+	c.tiers.lastErr = nil
+	return nil
+}
+
+// resolveUnknownTier builds a best-effort machine type for a tier the catalog
+// does not know, using the RAM reported by tiers.list and the vCPU count
+// encoded in the tier name where there is one
+func (c *Client) resolveUnknownTier(ctx context.Context, tier string) (config.MachineType, error) {
+	mt := config.MachineType{Name: tier, CPU: cpuFromTierName(tier), Series: "unknown"}
+
+	if err := c.RefreshTiers(ctx); err != nil {
+		return mt, err
+	}
+
+	c.tiers.mu.Lock()
+	ram, ok := c.tiers.ram[tier]
+	c.tiers.mu.Unlock()
+	if !ok {
+		return mt, fmt.Errorf("tier %s not returned by tiers.list", tier)
+	}
+
+	mt.MemoryGB = float64(ram) / 1024 / 1024 / 1024
+	return mt, nil
+}
+
+// cpuFromTierName returns the trailing vCPU count of tier names such as
+// db-c3-standard-8, or 0 when the name does not encode one
+func cpuFromTierName(tier string) int {
+	idx := strings.LastIndex(tier, "-")
+	if idx < 0 {
+		return 0
+	}
+	cpu, err := strconv.Atoi(tier[idx+1:])
+	if err != nil || cpu <= 0 {
+		return 0
+	}
+	return cpu
+}
+
+// ValidateMachineType checks that instance can be resized to machineType
+// before a patch is sent, so a plan fails where an apply would. The Admin API
+// has no validate-only mode for instances.patch; the check is made against
+// the project's tiers.list catalog, which names the regions each tier is
+// offered in, and the edition and engine machine type rules. Tiers tiers.list
+// does not enumerate, such as custom tiers, are checked against those rules
+// only, as are all tiers when tiers.list cannot be read.
+func (c *Client) ValidateMachineType(ctx context.Context, instance *config.InstanceInfo, machineType string) error {
+	if err := config.CheckAvailability(instance.Edition, instance.DatabaseVersion, machineType); err != nil {
+		return c.invalidTier(instance.Name, machineType, err)
+	}
+	if err := c.RefreshTiers(ctx); err != nil {
+		return nil
+	}
+
+	c.tiers.mu.Lock()
+	regions := c.tiers.regions[machineType]
+	c.tiers.mu.Unlock()
+	if instance.Region == "" || len(regions) == 0 {
+		return nil
+	}
+	for _, region := range regions {
+		if region == instance.Region {
+			return nil
+		}
+	}
+	return c.invalidTier(instance.Name, machineType, fmt.Errorf("tier %s is not offered in region %s", machineType, instance.Region))
+}
+
+// invalidTier reports a target machine type that failed validation
+func (c *Client) invalidTier(instanceName, machineType string, err error) error {
+	return &APIError{
+		Kind:     ErrInvalidTier,
+		Op:       "validate machine type " + machineType + " for",
+		Instance: instanceName,
+		Hint:     "The target machine type is not offered for this instance's region or edition. List valid tiers with `gcloud sql tiers list`.",
+		Err:      err,
+	}
+}
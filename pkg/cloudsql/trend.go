@@ -0,0 +1,115 @@
+package cloudsql
+
+import (
+	"math"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// ApplyTrends records on summary how fast CPU and memory utilization have
+// been climbing over the trailing window of data. Each trend is the slowest
+// hourly rise within the window, in percentage points per hour, so a positive
+// value means utilization climbed at least that fast throughout. Trends are
+// left at zero when the data does not span the window.
+func ApplyTrends(summary *config.MetricsSummary, data *config.MetricsData, window time.Duration) {
+	summary.TrendWindow = window
+	summary.CPUTrendPerHour = 0
+	summary.MemoryTrendPerHour = 0
+	summary.MemoryNonCacheTrendPerHour = 0
+	if window <= 0 || len(data.Timestamps) < 2 {
+		return
+	}
+
+	end := data.Timestamps[len(data.Timestamps)-1]
+	start := end.Add(-window)
+	if data.Timestamps[0].After(start) {
+		return
+	}
+
+	summary.CPUTrendPerHour = sustainedRise(data.Timestamps, data.CPUUtilization, start, end)
+	summary.MemoryTrendPerHour = sustainedRise(data.Timestamps, data.MemoryPercent, start, end)
+	summary.MemoryNonCacheTrendPerHour = sustainedRise(data.Timestamps, data.MemoryNonCachePercent, start, end)
+}
+
+// ApplySustainedLag records on summary the lowest replication lag over the
+// trailing window of data, so lag above a limit there has stayed above it
+// throughout. Gaps count as no lag. It is left at zero when the data does not
+// span the window or has no replication lag.
+func ApplySustainedLag(summary *config.MetricsSummary, data *config.MetricsData, window time.Duration) {
+	summary.ReplicaLagSustainedSeconds = 0
+	if window <= 0 || len(data.ReplicaLagSeconds) != len(data.Timestamps) || len(data.Timestamps) < 2 {
+		return
+	}
+
+	end := data.Timestamps[len(data.Timestamps)-1]
+	start := end.Add(-window)
+	if data.Timestamps[0].After(start) {
+		return
+	}
+
+	lowest := math.Inf(1)
+	for i, ts := range data.Timestamps {
+		if !ts.Before(start) {
+			lowest = math.Min(lowest, data.ReplicaLagSeconds[i])
+		}
+	}
+	summary.ReplicaLagSustainedSeconds = lowest
+}
+
+// sustainedRise splits [start, end] into hour-long segments and returns the
+// smallest least-squares slope among them, in units per hour. Zero values are
+// gaps in the aligned series and are ignored.
+func sustainedRise(timestamps []time.Time, values []float64, start, end time.Time) float64 {
+	if len(values) != len(timestamps) {
+		return 0
+	}
+
+	segments := int(math.Ceil(end.Sub(start).Hours()))
+	if segments < 1 {
+		segments = 1
+	}
+	segment := end.Sub(start) / time.Duration(segments)
+
+	rise := math.Inf(1)
+	for s := 0; s < segments; s++ {
+		from := start.Add(time.Duration(s) * segment)
+		to := from.Add(segment)
+
+		var xs, ys []float64
+		for i, ts := range timestamps {
+			if ts.Before(from) || ts.After(to) || values[i] == 0 {
+				continue
+			}
+			xs = append(xs, ts.Sub(start).Hours())
+			ys = append(ys, values[i])
+		}
+		slope, ok := leastSquaresSlope(xs, ys)
+		if !ok {
+			return 0
+		}
+		rise = math.Min(rise, slope)
+	}
+	return rise
+}
+
+// leastSquaresSlope fits y = a + bx and returns b
+func leastSquaresSlope(xs, ys []float64) (float64, bool) {
+	n := float64(len(xs))
+	if len(xs) < 2 {
+		return 0, false
+	}
+
+	var sumX, sumY, sumXY, sumXX float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+		sumXY += xs[i] * ys[i]
+		sumXX += xs[i] * xs[i]
+	}
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0, false
+	}
+	return (n*sumXY - sumX*sumY) / denom, true
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CommitmentTerm is the length of a Cloud SQL committed use discount
+type CommitmentTerm string
+
+const (
+	CommitmentOneYear   CommitmentTerm = "1yr"
+	CommitmentThreeYear CommitmentTerm = "3yr"
+)
+
+// Commitment is the committed use discount (CUD) covering instances' vCPU
+// and memory. Committed spend is paid whether or not it is used, so shrinking
+// an instance saves only the part of its spend the commitment does not cover.
+// The zero value is no commitment.
+type Commitment struct {
+	Term     CommitmentTerm
+	Coverage float64 // Fraction of each instance's vCPU and memory spend committed, 0 to 1
+}
+
+// Enabled reports whether any spend is committed
+func (c Commitment) Enabled() bool {
+	return c.Term != "" && c.Coverage > 0
+}
+
+// Discount returns the fraction of the on-demand price committed spend is
+// billed below it: 25% for one year and 52% for three
+func (c Commitment) Discount() float64 {
+	switch c.Term {
+	case CommitmentOneYear:
+		return 0.25
+	case CommitmentThreeYear:
+		return 0.52
+	default:
+		return 0
+	}
+}
+
+// String formats the commitment as ParseCommitment accepts it, e.g. 3yr:70
+func (c Commitment) String() string {
+	if !c.Enabled() {
+		return ""
+	}
+	return fmt.Sprintf("%s:%s", c.Term, strconv.FormatFloat(c.Coverage*100, 'f', -1, 64))
+}
+
+// ParseCommitment parses TERM:PERCENT, a term of 1yr or 3yr and the
+// percentage of vCPU and memory spend it covers, e.g. 3yr:70. An empty
+// string is no commitment.
+func ParseCommitment(s string) (Commitment, error) {
+	if s == "" {
+		return Commitment{}, nil
+	}
+	term, percent, ok := strings.Cut(s, ":")
+	if !ok {
+		return Commitment{}, fmt.Errorf("invalid commitment %q (must be TERM:PERCENT, e.g. 3yr:70)", s)
+	}
+	c := Commitment{Term: CommitmentTerm(strings.TrimSpace(term))}
+	if c.Term != CommitmentOneYear && c.Term != CommitmentThreeYear {
+		return Commitment{}, fmt.Errorf("invalid commitment term %q (must be 1yr or 3yr)", term)
+	}
+	coverage, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(percent), "%"), 64)
+	if err != nil || coverage <= 0 || coverage > 100 {
+		return Commitment{}, fmt.Errorf("invalid commitment coverage %q (must be a percentage above 0, up to 100)", percent)
+	}
+	c.Coverage = coverage / 100
+	return c, nil
+}
@@ -1,6 +1,11 @@
 package config
 
-import "time"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // Config holds the configuration for the autoscaler
 type Config struct {
@@ -10,6 +15,7 @@ type Config struct {
 	// Telemetry settings
 	MetricsPeriod   time.Duration
 	MetricsInterval time.Duration // Granularity of metrics
+	MetricsSource   string        // Cloud Monitoring when empty, or file://path to a metrics dump
 
 	// Scaling thresholds
 	CPUTargetUtilization    float64
@@ -17,28 +23,311 @@ type Config struct {
 	ScaleUpThreshold        float64 // e.g., 0.8 = 80%
 	ScaleDownThreshold      float64 // e.g., 0.5 = 50%
 
+	// Custom conditions replacing the threshold checks, as CEL expressions
+	// over the metrics summary (see rules.Rule); empty uses the thresholds
+	ScaleUpRule   string
+	ScaleDownRule string
+
 	// Scaling behavior
-	MinStableDuration time.Duration // Minimum time at threshold before scaling
-	CoolDownPeriod    time.Duration // Time to wait after scaling
+	MinStableDuration time.Duration  // Minimum time at threshold before scaling
+	CoolDownPeriod    time.Duration  // Time to wait after scaling
+	BusinessHours     *BusinessHours // Hours scale-down decisions are based on (nil = all hours)
+
+	// Machine types never recommended as a scaling target, and the only ones
+	// that are when the allowlist is not empty
+	DeniedMachineTypes  MachineTypeDenylist
+	AllowedMachineTypes MachineTypeAllowlist
 
 	// Operation settings
 	DryRun bool
 	Force  bool // Force scaling even if it causes downtime
+
+	// Read-only audit mode: every mutating Cloud SQL Admin API method is
+	// refused by the client, whatever DryRun says
+	ReadOnly bool
+
+	// Period covered by a decision's idempotency key: the same change to an
+	// instance is applied at most once per window, however often it is retried
+	IdempotencyWindow time.Duration
+
+	// Monitoring API quota management
+	MonitoringQuotaPerMinute int           // Max ListTimeSeries calls per minute (0 = unlimited)
+	AnalysisSpreadWindow     time.Duration // Window to spread analysis over when the fleet exceeds the quota
+
+	// Instances analyzed at once; 1 or less analyzes them one at a time
+	AnalysisConcurrency int
+
+	// Per-instance analysis latency budget; slower instances are reported
+	AnalysisLatencyBudget time.Duration
+
+	// Tail latency containment
+	InstanceAnalysisTimeout time.Duration // Longest one instance's analysis may take before it is skipped (0 = no limit)
+	MetricsHedgeDelay       time.Duration // Send a duplicate Monitoring request when one is slower than this (0 = never)
+
+	// Fleet sampling: analyze a rotating subset of instances each cycle
+	SampleFraction float64        // Fraction of instances analyzed per cycle (0 = all)
+	SampleStrategy SampleStrategy // How the subset is chosen
+	SampleMaxAge   time.Duration  // Longest an instance may go without being analyzed
+
+	// Post-scale connectivity probe
+	ProbeEnabled  bool          // Probe the instance after resizing before declaring success
+	ProbeIPType   string        // IP address type to probe (PRIMARY, PRIVATE)
+	ProbePort     int           // Port to probe (0 = engine default)
+	ProbeTimeout  time.Duration // How long the instance has to accept connections
+	ProbeInterval time.Duration // Delay between probe attempts
+
+	// Rate-of-change triggers: scale up ahead of the threshold when utilization
+	// has climbed faster than these rates (percentage points per hour, 0 = off)
+	// throughout TrendWindow and would cross the scale-up threshold within it
+	TrendWindow          time.Duration
+	CPUTrendThreshold    float64
+	MemoryTrendThreshold float64
+
+	// Predictive scaling: forecast utilization ForecastHorizon ahead from the
+	// trend and daily seasonality of the metrics period, and scale up before
+	// the scale-up threshold is crossed
+	Predictive      bool
+	ForecastHorizon time.Duration
+
+	// Cold start: instances with less history than MetricsPeriod are judged on
+	// the history they have once it spans ColdStartWindow, instead of waiting
+	// out the whole period
+	ColdStart       bool
+	ColdStartWindow time.Duration
+
+	// Merge the Recommender API recommendations and insights Database Center
+	// shows for each instance into its analysis
+	FleetSignals bool
+
+	// Price cost estimates at the Cloud Billing Catalog's rates for each
+	// instance's region and edition instead of the built-in list prices
+	CatalogPricing bool
+
+	// Enterprise Plus data cache
+	DataCacheHitRatioThreshold float64 // Hit ratio above which memory pressure alone won't trigger scale-up
+
+	// Memory pressure mode by engine; engines not listed use total utilization
+	MemoryPressureModes map[DatabaseEngine]MemoryPressureMode
+
+	// What to do with scale-downs that would cut max_connections below peak connections
+	ConnectionCapacityPolicy ConnectionCapacityPolicy
+
+	// Estimated memory per connection a scale-down's target must hold next to
+	// its buffer pool (0 = the engine's estimate)
+	PerConnectionMemoryMB float64
+
+	// Failover and DR replica handling
+	ReplicaPolicy ReplicaPolicy // How failover/DR replicas are kept in line with their primary
+
+	// Fleet-level optimization
+	CycleCostIncreaseCap     float64 // Max net monthly cost increase applied per cycle (0 = unlimited)
+	MaxOperationsPerCycle    int     // Max scaling operations applied per cycle (0 = unlimited)
+	BundleDowntimeOperations bool    // Run all downtime operations in one shared window
+
+	// Weekly window downtime-causing operations are held for (nil = none)
+	MaintenanceNight *MaintenanceNight
+	// Hold downtime-causing operations for each instance's own Cloud SQL
+	// maintenance window instead; instances without one use MaintenanceNight
+	MaintenanceWindows bool
+
+	// Monthly downtime an instance may spend on downtime-causing resizes;
+	// instances override it with the autoscaler-downtime-budget label (0 = no budget)
+	DowntimeBudget time.Duration
+
+	// Currency and locale cost estimates are reported in
+	Currency Currency
+
+	// Committed use discount savings estimates account for
+	Commitment Commitment
+
+	// What is masked in JSON output and notifications
+	Redaction Redaction
+
+	// External decision hook: each proposed resize is sent to DecisionHookURL,
+	// which allows, denies or modifies it. The URL and the key signing
+	// requests and responses may be secrets references; an empty key leaves
+	// them unsigned. When the hook fails, proposals are held unless
+	// DecisionHookFailOpen is set.
+	DecisionHookURL      string
+	DecisionHookSecret   string
+	DecisionHookTimeout  time.Duration
+	DecisionHookFailOpen bool
+
+	// Change freezes during which no scaling runs
+	BlackoutWindows []TimeWindow
+
+	// Scaling freezes blocking all but emergency scale-ups in their scope
+	Freezes                  []Freeze
+	FreezeEmergencyThreshold float64 // P95 CPU or memory percentage at which scale-ups run despite a freeze (0 = never)
+
+	// Scheduled scaling for known recurring load, merged with metric-driven decisions
+	Schedules    []Schedule
+	ScheduleLead time.Duration // How long before a schedule window its instances are scaled
+
+	// How long an instance may stay stopped before its deletion is suggested
+	// for review (0 = never)
+	StoppedReviewAge time.Duration
+
+	// Instances judged with their own profile, thresholds and cooldown, from
+	// the config file; see OverrideFor
+	InstanceOverrides []InstanceOverride
+
+	// ProfileConfigs are the configuration with each profile's thresholds,
+	// by profile name, for instances whose labels name a profile; set by
+	// whoever resolves profile names
+	ProfileConfigs map[string]*Config
+
+	// Ownership by instance name; instance labels fill in what is not configured
+	Owners map[string]Owner
+
+	// SQL Server licensing
+	SQLServerScaleUpThreshold float64 // Stricter scale-up threshold for per-core licensed SQL Server instances
+
+	// Auto-revert of emergency scale-ups: reactive scale-ups are tagged with a
+	// review deadline, before which the instance is returned to its original
+	// machine type once utilization there would have stayed below target for
+	// RevertQuietPeriod
+	RevertDeadline    time.Duration // How long after a reactive scale-up a revert is considered (0 = off)
+	RevertQuietPeriod time.Duration // How long utilization must stay below target before reverting
+	RevertApply       bool          // Apply reverts; otherwise they are recommended only
+
+	// Rollback of scale-downs that regress: within RollbackWindow of a
+	// scale-down, P95 CPU or memory at RollbackThreshold, or peak connections
+	// saturating max_connections, since it settled returns the instance to its
+	// previous machine type and holds later scale-downs back above the one
+	// that regressed
+	RollbackWindow    time.Duration // How long after a scale-down a regression rolls it back (0 = off)
+	RollbackThreshold float64       // P95 CPU or memory utilization (0-1) after a scale-down that counts as a regression
+
+	// Read replica count autoscaling: add a read replica when a primary's
+	// replicas are busy or falling behind, and remove replicas the autoscaler
+	// added once they idle. Replicas are only created or deleted when
+	// ReplicaScaling is set.
+	ReplicaScaling            bool          // Apply recommended read replica count changes
+	ReplicaScaleUpThreshold   float64       // Mean P95 CPU of the read replicas (0-1) at which one is added (0 = off)
+	ReplicaScaleDownThreshold float64       // Mean P95 CPU of the read replicas (0-1) below which one is removed
+	MaxReplicaLag             time.Duration // Replication lag at which a read replica is added, a lagging replica is not scaled down, and sustained lag scales it up (0 = ignore lag)
+	MinReadReplicas           int           // Fewest read replicas kept per primary
+	MaxReadReplicas           int           // Most read replicas per primary
+
+	// Storage autoscaling: grow the data disk when it fills up. Disk size can
+	// never be decreased, so increases are only applied when StorageScaling is set.
+	StorageScaling           bool    // Apply recommended disk size increases
+	StorageScaleUpThreshold  float64 // Fraction of the disk used at which an increase is recommended (0 = off)
+	StorageTargetUtilization float64 // Fraction of the disk used after an increase
+	StorageMinIncreaseGB     int64   // Smallest increase recommended
+	MaxDiskSizeGB            int64   // Largest disk size recommended (0 = platform limit)
+
+	// Disk shrink advisories: disks cannot shrink in place, so a mostly empty
+	// disk is reported with the savings of migrating to a smaller one
+	DiskShrinkThreshold float64       // Fraction of the disk used below which an advisory is made (0 = off)
+	DiskShrinkHorizon   time.Duration // Usage growth the smaller disk leaves room for
+
+	// IO-bound detection: an instance whose P95 disk IOPS or throughput
+	// nears its disk's limit is not helped by a CPU-based resize
+	IOBoundThreshold float64 // Fraction of the disk's IOPS or throughput limit at which an instance is IO-bound (0 = off)
+
+	// Shadow is a candidate configuration evaluated alongside this one each
+	// daemon cycle; its decisions are reported but never applied
+	Shadow *Config
+}
+
+// ReplicaPolicy controls how failover and DR replicas are scaled
+type ReplicaPolicy string
+
+const (
+	// ReplicaPolicyParity resizes failover/DR replicas together with their primary
+	ReplicaPolicyParity ReplicaPolicy = "parity"
+	// ReplicaPolicyExclude leaves failover/DR replicas untouched
+	ReplicaPolicyExclude ReplicaPolicy = "exclude"
+)
+
+// SampleStrategy controls which instances a sampled cycle analyzes
+type SampleStrategy string
+
+const (
+	// SampleRotate analyzes the instances that have gone longest without analysis
+	SampleRotate SampleStrategy = "rotate"
+	// SamplePriority favors instances whose last analysis found them under
+	// pressure or worth scaling, weighted by time since they were analyzed
+	SamplePriority SampleStrategy = "priority"
+)
+
+// ParseSampleFraction parses a sample size given as a percentage ("20%") or a
+// fraction ("0.2"). An empty string means no sampling.
+func ParseSampleFraction(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	var fraction float64
+	if pct, ok := strings.CutSuffix(s, "%"); ok {
+		v, err := strconv.ParseFloat(pct, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid sample size %q", s)
+		}
+		fraction = v / 100
+	} else {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid sample size %q", s)
+		}
+		fraction = v
+	}
+
+	if fraction <= 0 || fraction > 1 {
+		return 0, fmt.Errorf("sample size %q must be between 0%% and 100%%", s)
+	}
+	return fraction, nil
 }
 
 // DefaultConfig returns a config with sensible defaults
 func DefaultConfig() *Config {
 	return &Config{
-		MetricsPeriod:           3 * 24 * time.Hour, // 3 days
-		MetricsInterval:         5 * time.Minute,    // 5 minute granularity
-		CPUTargetUtilization:    0.7,                // 70%
-		MemoryTargetUtilization: 0.8,                // 80%
-		ScaleUpThreshold:        0.8,                // Scale up at 80% utilization
-		ScaleDownThreshold:      0.5,                // Scale down at 50% utilization
-		MinStableDuration:       1 * time.Hour,      // Sustained for 1 hour
-		CoolDownPeriod:          30 * time.Minute,   // Wait 30 minutes after scaling
-		DryRun:                  false,
-		Force:                   false,
+		MetricsPeriod:              3 * 24 * time.Hour, // 3 days
+		MetricsInterval:            5 * time.Minute,    // 5 minute granularity
+		CPUTargetUtilization:       0.7,                // 70%
+		MemoryTargetUtilization:    0.8,                // 80%
+		ScaleUpThreshold:           0.8,                // Scale up at 80% utilization
+		ScaleDownThreshold:         0.5,                // Scale down at 50% utilization
+		MinStableDuration:          1 * time.Hour,      // Sustained for 1 hour
+		CoolDownPeriod:             30 * time.Minute,   // Wait 30 minutes after scaling
+		IdempotencyWindow:          time.Hour,          // Apply the same change at most once an hour
+		DryRun:                     false,
+		Force:                      false,
+		MonitoringQuotaPerMinute:   600,              // Well under the default project read quota
+		AnalysisConcurrency:        4,                // Analyze four instances at a time
+		AnalysisLatencyBudget:      30 * time.Second, // Flag instances taking over 30s to analyze
+		InstanceAnalysisTimeout:    2 * time.Minute,  // Skip an instance rather than let it hold up the cycle
+		SampleStrategy:             SampleRotate,     // Analyze the stalest instances first when sampling
+		SampleMaxAge:               6 * time.Hour,    // Every instance analyzed at least every 6 hours
+		ProbeIPType:                "PRIMARY",        // Probe the public address by default
+		ProbeTimeout:               5 * time.Minute,  // Allow 5 minutes to accept connections
+		ProbeInterval:              10 * time.Second, // Retry every 10 seconds
+		TrendWindow:                3 * time.Hour,    // Look for climbs sustained over 3 hours
+		ForecastHorizon:            24 * time.Hour,   // Forecast a day ahead when predictive
+		ColdStartWindow:            24 * time.Hour,   // Decide on a day of history in cold start
+		DataCacheHitRatioThreshold: 0.95,             // Cache serving 95% of reads
+		SQLServerScaleUpThreshold:  0.9,              // Scale up SQL Server only at 90% utilization
+		RevertQuietPeriod:          24 * time.Hour,   // A full day back below target before reverting
+		RollbackThreshold:          0.9,              // Roll back a scale-down running at 90% utilization
+		ReplicaScaleUpThreshold:    0.75,             // Add a read replica when they average 75% CPU
+		ReplicaScaleDownThreshold:  0.3,              // Remove one when they average under 30%
+		MaxReplicaLag:              time.Minute,      // or when replication falls a minute behind
+		MinReadReplicas:            1,                // Never remove the last read replica
+		MaxReadReplicas:            5,                // and never add more than five
+		StorageScaleUpThreshold:    0.85,             // Grow the disk once it is 85% full
+		StorageTargetUtilization:   0.7,              // to bring usage back to 70%
+		StorageMinIncreaseGB:       10,               // by at least 10GB
+		DiskShrinkThreshold:        0.4,              // Advise on disks under 40% full
+		DiskShrinkHorizon:          4320 * time.Hour, // sized for six months of growth
+		IOBoundThreshold:           0.8,              // IO-bound at 80% of the disk's IOPS or throughput limit
+		FreezeEmergencyThreshold:   95,               // Scale up through a freeze only when near saturation
+		ScheduleLead:               15 * time.Minute, // Resize ahead of scheduled load
+		CatalogPricing:             true,             // Price estimates at the Cloud Billing Catalog's rates
+		ReplicaPolicy:              ReplicaPolicyParity,
+		ConnectionCapacityPolicy:   ConnectionCapacityBlock,
 	}
 }
 
@@ -50,6 +339,8 @@ type InstanceInfo struct {
 	MachineType      string
 	Edition          Edition
 	State            string
+	ActivationPolicy string    // ALWAYS, ON_DEMAND, or NEVER for a stopped instance
+	StoppedAt        time.Time // When a stopped instance was stopped; zero if running or unknown
 	LastScaledTime   time.Time
 	CurrentCPU       int
 	CurrentMemoryGB  float64
@@ -58,6 +349,77 @@ type InstanceInfo struct {
 	HighAvailability bool
 	Region           string
 	Zone             string
+	SecondaryZone    string
+	DataCacheEnabled bool              // Enterprise Plus data cache on local SSD
+	Labels           map[string]string // User labels
+	UnsupportedTier  bool              // Tier is not in the machine type catalog; advisory analysis only
+
+	// Cloud SQL maintenance window (nil = no preference set)
+	MaintenanceWindow *MaintenanceWindow
+
+	// Policy overrides from the instance's labels, see cloudsql.LabelProfile
+	ProfileLabel   string // Scaling profile to judge the instance with
+	MaxTier        string // Largest machine type metric-driven scale-ups may choose
+	DowntimeBudget string // Monthly downtime budget, a duration such as 10m
+
+	// Storage, pricing and database flags
+	DiskSizeGB               int64             // Provisioned data disk size
+	DiskType                 string            // PD_SSD or PD_HDD
+	StorageAutoResize        bool              // Storage grows automatically as it fills
+	StorageAutoResizeLimitGB int64             // Ceiling for automatic growth (0 = none)
+	PricingPlan              string            // PER_USE or PACKAGE
+	DatabaseFlags            map[string]string // Database flag values by name
+
+	// Replication topology
+	InstanceType      string            // CLOUD_SQL_INSTANCE, READ_REPLICA_INSTANCE, ...
+	PrimaryInstance   string            // Name of the primary if this instance is a replica
+	Replicas          []string          // Names of this instance's replicas
+	FailoverReplicas  []string          // Replicas that act as failover/DR targets for this instance
+	IsFailoverReplica bool              // This instance is a failover or DR replica of its primary
+	IPAddresses       map[string]string // IP address by type (PRIMARY, PRIVATE, OUTGOING)
+}
+
+// Stopped reports whether the instance has been stopped, which Cloud SQL
+// records by setting its activation policy to NEVER while it stays RUNNABLE
+func (i *InstanceInfo) Stopped() bool {
+	return i.ActivationPolicy == "NEVER" || i.State == "STOPPED"
+}
+
+// ID returns the instance's fully qualified identifier, see InstanceID
+func (i *InstanceInfo) ID() string {
+	return InstanceID(i.Project, i.Region, i.Name)
+}
+
+// InstanceID returns the fully qualified identifier of instance name in
+// project and region, PROJECT:REGION:NAME. It is the instance's Cloud SQL
+// connection name, and tells apart instances of the same name in different
+// projects where the name alone would collide.
+func InstanceID(project, region, name string) string {
+	return project + ":" + region + ":" + name
+}
+
+// ParseInstanceID splits a fully qualified instance identifier into its
+// project, region and name. Domain-scoped projects, such as
+// example.com:my-project, keep their colon. It reports false unless all
+// three parts are present.
+func ParseInstanceID(id string) (project, region, name string, ok bool) {
+	rest, name, found := cutLast(id, ":")
+	if !found {
+		return "", "", "", false
+	}
+	project, region, found = cutLast(rest, ":")
+	if !found || project == "" || region == "" || name == "" {
+		return "", "", "", false
+	}
+	return project, region, name, true
+}
+
+// cutLast slices s around the last instance of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
 }
 
 // MetricsData holds time series metrics data
@@ -68,7 +430,23 @@ type MetricsData struct {
 	MemoryPercent  []float64 // Memory utilization percentage
 	Connections    []int
 	DiskUsageGB    []float64
-	DiskIOPS       []float64
+	DiskIOPS       []float64 // Data disk read and write operations per second
+
+	DiskThroughputMBps []float64 // Data disk read and write MB per second (only populated when IO-bound detection is on)
+
+	// Enterprise Plus data cache (only populated when the cache is enabled)
+	DataCacheUsedGB   []float64
+	DataCacheHitRatio []float64 // Percentage (0-100)
+
+	// Memory pressure signals (only populated when the engine's memory
+	// pressure mode needs them)
+	MemoryNonCachePercent []float64 // Memory used outside the page cache, percentage (0-100)
+	SwapInPages           []float64 // Pages swapped in per interval
+	MemoryGrantsPending   []float64 // SQL Server queries waiting for a workspace memory grant
+
+	// Replication lag in seconds (only populated for read replicas when
+	// replica count autoscaling or the maximum replication lag is on)
+	ReplicaLagSeconds []float64
 }
 
 // MetricsSummary holds statistical summary of metrics
@@ -86,6 +464,37 @@ type MetricsSummary struct {
 	MemoryP99Pct   float64
 	ConnectionsAvg float64
 	ConnectionsMax int
-	Period         time.Duration
-	DataPoints     int
+
+	DataCacheUsedGB   float64 // Average data cache usage
+	DataCacheHitRatio float64 // Average data cache hit ratio percentage (0 if unavailable)
+
+	MemoryNonCacheP95Pct float64 // P95 memory used outside the page cache (0 if unavailable)
+	SwapInP95            float64 // P95 pages swapped in per interval
+
+	MemoryGrantsPendingP95 float64 // P95 SQL Server queries waiting for a memory grant
+
+	// Sustained rise over the trailing trend window, in percentage points per hour
+	CPUTrendPerHour    float64
+	MemoryTrendPerHour float64
+	TrendWindow        time.Duration
+
+	MemoryNonCacheTrendPerHour float64 // As MemoryTrendPerHour, for memory used outside the page cache
+
+	DiskUsedGB    float64 // Latest data disk usage (0 if unavailable)
+	DiskUsedMaxGB float64 // Peak data disk usage
+
+	DiskGrowthGBPerDay float64 // Least-squares growth of data disk usage over the period
+
+	DiskIOPSP95           float64 // P95 data disk read and write operations per second (0 if unavailable)
+	DiskThroughputP95MBps float64 // P95 data disk read and write MB per second (0 if unavailable)
+
+	ReplicaLagP95Seconds       float64 // P95 replication lag of a read replica (0 if unavailable)
+	ReplicaLagMaxSeconds       float64 // Peak replication lag of a read replica
+	ReplicaLagSustainedSeconds float64 // Lowest replication lag over the trailing stable duration (0 if the data does not span it)
+
+	Period     time.Duration
+	DataPoints int
+
+	// Summary of business hours only, when configured; scale-down is judged on it
+	BusinessHours *MetricsSummary
 }
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"golang.org/x/text/currency"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Currency formats cost estimates in the reporting currency and locale. Cost
+// estimates are computed in US dollars and converted at PerUSD. The zero
+// value formats US dollars for en-US.
+type Currency struct {
+	Code   string  // ISO 4217 code, e.g. EUR
+	PerUSD float64 // Units of Code per US dollar
+	Locale string  // BCP 47 tag controlling separators and symbol placement, e.g. de-DE
+}
+
+// symbolAfterAmount lists languages that write the currency symbol after the amount
+var symbolAfterAmount = map[string]bool{
+	"cs": true, "da": true, "de": true, "es": true, "fi": true, "fr": true,
+	"it": true, "nb": true, "pl": true, "sv": true,
+}
+
+// ParseCurrency validates a currency code, conversion rate and locale.
+// perUSD may be zero for USD.
+func ParseCurrency(code string, perUSD float64, locale string) (Currency, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		code = "USD"
+	}
+	if _, err := currency.ParseISO(code); err != nil {
+		return Currency{}, fmt.Errorf("unknown currency %q", code)
+	}
+	if perUSD == 0 && code == "USD" {
+		perUSD = 1
+	}
+	if perUSD <= 0 {
+		return Currency{}, fmt.Errorf("currency %s needs a positive conversion rate from USD", code)
+	}
+	if locale != "" {
+		if _, err := language.Parse(locale); err != nil {
+			return Currency{}, fmt.Errorf("invalid locale %q: %w", locale, err)
+		}
+	}
+	return Currency{Code: code, PerUSD: perUSD, Locale: locale}, nil
+}
+
+// Format converts a US dollar amount and formats it, e.g. "$1,234.50" or
+// "1.234,50 €"
+func (c Currency) Format(usd float64) string {
+	unit, tag := c.resolve()
+	amount := usd * c.rate()
+	scale, _ := currency.Standard.Rounding(unit)
+
+	p := message.NewPrinter(tag)
+	number := p.Sprintf("%.*f", scale, math.Abs(amount))
+	symbol := p.Sprint(currency.NarrowSymbol(unit))
+
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+	}
+	base, _ := tag.Base()
+	if symbolAfterAmount[base.String()] {
+		return sign + number + " " + symbol
+	}
+	return sign + symbol + number
+}
+
+// FormatSigned formats an amount with an explicit sign, e.g. "+$12.00"
+func (c Currency) FormatSigned(usd float64) string {
+	if usd < 0 {
+		return c.Format(usd)
+	}
+	return "+" + c.Format(usd)
+}
+
+// resolve returns the currency unit and locale, defaulting to USD and en-US
+func (c Currency) resolve() (currency.Unit, language.Tag) {
+	unit, err := currency.ParseISO(c.Code)
+	if err != nil || c.Code == "" {
+		unit = currency.USD
+	}
+	tag, err := language.Parse(c.Locale)
+	if err != nil || c.Locale == "" {
+		tag = language.AmericanEnglish
+	}
+	return unit, tag
+}
+
+// rate returns the conversion rate from US dollars
+func (c Currency) rate() float64 {
+	if c.PerUSD <= 0 {
+		return 1
+	}
+	return c.PerUSD
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// DenySharedCore is the denylist pattern matching the shared-core machine types
+const DenySharedCore = "shared-core"
+
+// MachineTypeDenylist lists machine types that are never recommended as a
+// scaling target. Each pattern is a machine type name, a shell glob such as
+// "db-e2-*", or "shared-core".
+type MachineTypeDenylist []string
+
+// ParseMachineTypeDenylist parses comma-separated denylist patterns
+func ParseMachineTypeDenylist(patterns []string) (MachineTypeDenylist, error) {
+	return parseMachineTypePatterns(patterns)
+}
+
+// parseMachineTypePatterns parses comma-separated machine type patterns
+func parseMachineTypePatterns(patterns []string) ([]string, error) {
+	var parsed []string
+	for _, p := range patterns {
+		for _, pattern := range strings.Split(p, ",") {
+			pattern = strings.TrimSpace(pattern)
+			if pattern == "" {
+				continue
+			}
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid machine type pattern %q: %w", pattern, err)
+			}
+			parsed = append(parsed, pattern)
+		}
+	}
+	return parsed, nil
+}
+
+// matchMachineType returns the first of patterns matching machineType, if any
+func matchMachineType(patterns []string, machineType string) (string, bool) {
+	for _, pattern := range patterns {
+		if pattern == DenySharedCore && isSharedCore(machineType) {
+			return pattern, true
+		}
+		if ok, _ := path.Match(pattern, machineType); ok {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// Denies returns the first pattern matching machineType, if any
+func (d MachineTypeDenylist) Denies(machineType string) (string, bool) {
+	return matchMachineType(d, machineType)
+}
+
+// Admitting narrows allowed to the machine types the denylist does not match
+func (d MachineTypeDenylist) Admitting(allowed MachineTypeFilter) MachineTypeFilter {
+	if len(d) == 0 {
+		return allowed
+	}
+	return func(name string) bool {
+		if _, denied := d.Denies(name); denied {
+			return false
+		}
+		return allowed == nil || allowed(name)
+	}
+}
+
+// MachineTypeAllowlist lists the only machine types recommended as a scaling
+// target, as organization policy may restrict them; empty allows all. Its
+// patterns are those of MachineTypeDenylist.
+type MachineTypeAllowlist []string
+
+// ParseMachineTypeAllowlist parses comma-separated allowlist patterns
+func ParseMachineTypeAllowlist(patterns []string) (MachineTypeAllowlist, error) {
+	return parseMachineTypePatterns(patterns)
+}
+
+// Allows reports whether the allowlist is empty or a pattern matches machineType
+func (l MachineTypeAllowlist) Allows(machineType string) bool {
+	_, ok := matchMachineType(l, machineType)
+	return len(l) == 0 || ok
+}
+
+// Admitting narrows allowed to the machine types the allowlist matches
+func (l MachineTypeAllowlist) Admitting(allowed MachineTypeFilter) MachineTypeFilter {
+	if len(l) == 0 {
+		return allowed
+	}
+	return func(name string) bool {
+		return l.Allows(name) && (allowed == nil || allowed(name))
+	}
+}
+
+// RestrictsTarget reports whether machineType may not be recommended as a
+// scaling target and why, e.g. "db-e2-standard-4 is denylisted (db-e2-*)"
+// or "db-n1-standard-4 is not allowlisted (db-custom-*)"
+func (c *Config) RestrictsTarget(machineType string) (string, bool) {
+	if pattern, denied := c.DeniedMachineTypes.Denies(machineType); denied {
+		return fmt.Sprintf("%s is denylisted (%s)", machineType, pattern), true
+	}
+	if !c.AllowedMachineTypes.Allows(machineType) {
+		return fmt.Sprintf("%s is not allowlisted (%s)", machineType, strings.Join(c.AllowedMachineTypes, ", ")), true
+	}
+	return "", false
+}
+
+// AdmittingTargets narrows allowed to the machine types that may be
+// recommended as a scaling target, see RestrictsTarget
+func (c *Config) AdmittingTargets(allowed MachineTypeFilter) MachineTypeFilter {
+	return c.AllowedMachineTypes.Admitting(c.DeniedMachineTypes.Admitting(allowed))
+}
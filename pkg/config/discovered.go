@@ -0,0 +1,83 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Machine types discovered at runtime from the Admin API's tiers.list. They
+// take precedence over MachineTypeRegistry, which remains the offline fallback.
+var (
+	discoveredMu    sync.RWMutex
+	discoveredTypes map[string]MachineType
+)
+
+// SetDiscoveredMachineTypes replaces the set of machine types discovered at runtime
+func SetDiscoveredMachineTypes(types []MachineType) {
+	discovered := make(map[string]MachineType, len(types))
+	for _, mt := range types {
+		discovered[mt.Name] = mt
+	}
+
+	discoveredMu.Lock()
+	discoveredTypes = discovered
+	discoveredMu.Unlock()
+}
+
+// discoveredMachineType looks up a machine type discovered at runtime
+func discoveredMachineType(name string) (MachineType, bool) {
+	discoveredMu.RLock()
+	defer discoveredMu.RUnlock()
+	mt, ok := discoveredTypes[name]
+	return mt, ok
+}
+
+// allMachineTypes returns the static registry overlaid with discovered machine types
+func allMachineTypes() map[string]MachineType {
+	discoveredMu.RLock()
+	defer discoveredMu.RUnlock()
+
+	all := make(map[string]MachineType, len(MachineTypeRegistry)+len(discoveredTypes))
+	for name, mt := range MachineTypeRegistry {
+		all[name] = mt
+	}
+	for name, mt := range discoveredTypes {
+		all[name] = mt
+	}
+	return all
+}
+
+// MachineTypeFromTier builds a machine type from a tiers.list entry. The API
+// reports RAM but not vCPUs, so the CPU count comes from the static registry
+// or from the tier name (db-<series>-<tier>-<vcpus>). It returns false for
+// tiers whose vCPU count cannot be determined and for custom and
+// performance-optimized tiers, which are parsed from their names instead.
+func MachineTypeFromTier(name string, ramBytes int64) (MachineType, bool) {
+	if strings.HasPrefix(name, "db-custom-") || strings.HasPrefix(name, "db-perf-optimized-") || ramBytes <= 0 {
+		return MachineType{}, false
+	}
+
+	memoryGB := float64(ramBytes) / 1024 / 1024 / 1024
+	if mt, ok := MachineTypeRegistry[name]; ok {
+		mt.MemoryGB = memoryGB
+		return mt, true
+	}
+
+	parts := strings.Split(strings.TrimPrefix(name, "db-"), "-")
+	if len(parts) != 3 {
+		return MachineType{}, false
+	}
+	cpu, err := strconv.Atoi(parts[2])
+	if err != nil || cpu <= 0 {
+		return MachineType{}, false
+	}
+
+	return MachineType{
+		Name:     name,
+		CPU:      cpu,
+		MemoryGB: memoryGB,
+		Series:   parts[0],
+		Tier:     parts[1],
+	}, true
+}
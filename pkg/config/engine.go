@@ -0,0 +1,253 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DatabaseEngine identifies the database engine of a Cloud SQL instance
+type DatabaseEngine string
+
+const (
+	EnginePostgreSQL DatabaseEngine = "POSTGRES"
+	EngineMySQL      DatabaseEngine = "MYSQL"
+	EngineSQLServer  DatabaseEngine = "SQLSERVER"
+)
+
+// ParseEngine derives the engine from a database version such as POSTGRES_15,
+// MYSQL_8_0 or SQLSERVER_2019_STANDARD
+func ParseEngine(databaseVersion string) DatabaseEngine {
+	switch {
+	case strings.HasPrefix(databaseVersion, "MYSQL"):
+		return EngineMySQL
+	case strings.HasPrefix(databaseVersion, "SQLSERVER"):
+		return EngineSQLServer
+	default:
+		return EnginePostgreSQL
+	}
+}
+
+// DefaultPort returns the default database port for a Cloud SQL database version
+func DefaultPort(databaseVersion string) int {
+	switch ParseEngine(databaseVersion) {
+	case EngineMySQL:
+		return 3306
+	case EngineSQLServer:
+		return 1433
+	default:
+		return 5432
+	}
+}
+
+// SQL Server license rates in $/vCPU/hour by edition (example on-demand rates)
+var sqlServerLicenseRates = map[string]float64{
+	"ENTERPRISE": 0.47,
+	"STANDARD":   0.13,
+	"WEB":        0.01,
+	"EXPRESS":    0,
+}
+
+// SQL Server's per-edition limit on the vCPUs an instance uses; Enterprise
+// uses every vCPU
+var sqlServerMaxVCPUs = map[string]int{
+	"STANDARD": 24,
+	"WEB":      16,
+	"EXPRESS":  4,
+}
+
+// sqlServerMinLicensedVCPUs is the fewest vCPUs a per-core SQL Server license
+// is billed for, whatever the machine type
+const sqlServerMinLicensedVCPUs = 4
+
+// SQLServerEdition returns the SQL Server edition of a database version such
+// as SQLSERVER_2019_STANDARD, Standard when it is not recognized, or empty
+// for other engines
+func SQLServerEdition(databaseVersion string) string {
+	if ParseEngine(databaseVersion) != EngineSQLServer {
+		return ""
+	}
+	for edition := range sqlServerLicenseRates {
+		if strings.HasSuffix(databaseVersion, "_"+edition) {
+			return edition
+		}
+	}
+	return "STANDARD"
+}
+
+// LicenseHourlyRatePerVCPU returns the per-vCPU license cost of the database
+// version. Only SQL Server carries a license cost; other engines return zero.
+func LicenseHourlyRatePerVCPU(databaseVersion string) float64 {
+	return sqlServerLicenseRates[SQLServerEdition(databaseVersion)]
+}
+
+// LicensedVCPUs returns how many vCPUs of an instance with cpus vCPUs running
+// databaseVersion are billed a license: at least four on licensed SQL Server
+// editions, and none on unlicensed engines and editions
+func LicensedVCPUs(databaseVersion string, cpus int) int {
+	if LicenseHourlyRatePerVCPU(databaseVersion) == 0 {
+		return 0
+	}
+	return max(cpus, sqlServerMinLicensedVCPUs)
+}
+
+// MaxVCPUs returns the most vCPUs an instance running databaseVersion can
+// use, or 0 when the engine and edition set no limit
+func MaxVCPUs(databaseVersion string) int {
+	return sqlServerMaxVCPUs[SQLServerEdition(databaseVersion)]
+}
+
+// MemoryPressureMode controls which memory reading counts as pressure when
+// deciding to scale up. PostgreSQL memory utilization includes the page cache
+// and often reads above 90% on a healthy instance, and SQL Server holds on to
+// nearly all of the instance's memory for its buffer pool.
+type MemoryPressureMode string
+
+const (
+	MemoryPressureTotal        MemoryPressureMode = "total"        // Memory utilization including the page cache
+	MemoryPressureNonCache     MemoryPressureMode = "noncache"     // Memory used outside the page cache
+	MemoryPressureCorroborated MemoryPressureMode = "corroborated" // Memory utilization, only alongside swapping or connection saturation
+)
+
+// ParseMemoryPressure parses an engine memory pressure mode of the form
+// ENGINE=MODE, e.g. postgres=noncache
+func ParseMemoryPressure(s string) (DatabaseEngine, MemoryPressureMode, error) {
+	name, mode, ok := strings.Cut(s, "=")
+	if !ok {
+		return "", "", fmt.Errorf("invalid memory pressure %q (must be ENGINE=MODE)", s)
+	}
+
+	engine := DatabaseEngine(strings.ToUpper(name))
+	switch engine {
+	case EnginePostgreSQL, EngineMySQL, EngineSQLServer:
+	default:
+		return "", "", fmt.Errorf("invalid engine %q (must be 'postgres', 'mysql' or 'sqlserver')", name)
+	}
+
+	switch m := MemoryPressureMode(mode); m {
+	case MemoryPressureTotal, MemoryPressureNonCache, MemoryPressureCorroborated:
+		return engine, m, nil
+	default:
+		return "", "", fmt.Errorf("invalid memory pressure mode %q (must be 'total', 'noncache' or 'corroborated')", mode)
+	}
+}
+
+// MemoryPressureFor returns the memory pressure mode of the database
+// version's engine unless configured otherwise: corroborated for SQL Server,
+// whose memory utilization reads near full on a healthy instance, and total
+// for the others
+func (c *Config) MemoryPressureFor(databaseVersion string) MemoryPressureMode {
+	engine := ParseEngine(databaseVersion)
+	if mode, ok := c.MemoryPressureModes[engine]; ok {
+		return mode
+	}
+	if engine == EngineSQLServer {
+		return MemoryPressureCorroborated
+	}
+	return MemoryPressureTotal
+}
+
+// postgresMaxConnections are Cloud SQL for PostgreSQL's default
+// max_connections by instance memory: the first row whose memory the
+// instance is below applies, and larger instances get 1000
+var postgresMaxConnections = []struct {
+	belowGB float64
+	limit   int
+}{
+	{1, 25},
+	{3.75, 50},
+	{6, 100},
+	{7.5, 200},
+	{15, 400},
+	{30, 500},
+	{60, 600},
+	{120, 800},
+}
+
+// DefaultMaxConnections returns the max_connections Cloud SQL sets on an
+// instance of machineType running databaseVersion when the flag is not set,
+// or 0 when the engine sets no fixed limit (SQL Server) or machineType is
+// unknown. Resizing an instance without the flag changes its limit.
+func DefaultMaxConnections(databaseVersion, machineType string) int {
+	mt, err := GetMachineType(machineType)
+	if err != nil {
+		return 0
+	}
+	switch ParseEngine(databaseVersion) {
+	case EnginePostgreSQL:
+		for _, row := range postgresMaxConnections {
+			if mt.MemoryGB < row.belowGB {
+				return row.limit
+			}
+		}
+		return 1000
+	case EngineMySQL:
+		switch machineType {
+		case "db-f1-micro":
+			return 250
+		case "db-g1-small":
+			return 1000
+		}
+		return 4000
+	default:
+		return 0
+	}
+}
+
+// ConnectionCapacityPolicy controls what happens to a scale-down whose
+// smaller machine type gets a default max_connections below observed peak
+// connections
+type ConnectionCapacityPolicy string
+
+const (
+	// ConnectionCapacityBlock holds the scale-down back
+	ConnectionCapacityBlock ConnectionCapacityPolicy = "block"
+	// ConnectionCapacityWarn recommends the scale-down with a critical warning
+	ConnectionCapacityWarn ConnectionCapacityPolicy = "warn"
+)
+
+// connectionMemoryMB estimates the memory each connection holds by engine:
+// a PostgreSQL backend with its work_mem, a MySQL thread with its per-thread
+// buffers, and a SQL Server session, whose queries draw on the buffer pool
+var connectionMemoryMB = map[DatabaseEngine]float64{
+	EnginePostgreSQL: 10,
+	EngineMySQL:      4,
+	EngineSQLServer:  0.5,
+}
+
+// ConnectionMemoryMB returns the estimated memory a connection holds on
+// databaseVersion's engine, or Config.PerConnectionMemoryMB when set
+func (c *Config) ConnectionMemoryMB(databaseVersion string) float64 {
+	if c.PerConnectionMemoryMB > 0 {
+		return c.PerConnectionMemoryMB
+	}
+	return connectionMemoryMB[ParseEngine(databaseVersion)]
+}
+
+// bufferPoolShare is the share of instance memory Cloud SQL gives the buffer
+// pool by engine when its flag is not set: shared_buffers, the InnoDB buffer
+// pool and SQL Server's max server memory
+var bufferPoolShare = map[DatabaseEngine]float64{
+	EnginePostgreSQL: 1.0 / 3,
+	EngineMySQL:      0.72,
+	EngineSQLServer:  0.8,
+}
+
+// BufferPoolGB returns the buffer pool of an instance running databaseVersion
+// with flags on a machine type with memoryGB, and whether a flag sets it
+// rather than Cloud SQL's share of memory. A flag keeps its size across
+// resizes.
+func BufferPoolGB(databaseVersion string, flags map[string]string, memoryGB float64) (float64, bool) {
+	engine := ParseEngine(databaseVersion)
+	flag, unitBytes := "shared_buffers", 8192.0 // 8 kB pages
+	switch engine {
+	case EngineMySQL:
+		flag, unitBytes = "innodb_buffer_pool_size", 1
+	case EngineSQLServer:
+		flag, unitBytes = "max server memory (mb)", 1<<20
+	}
+	if value, err := strconv.ParseFloat(flags[flag], 64); err == nil && value > 0 {
+		return value * unitBytes / (1 << 30), true
+	}
+	return memoryGB * bufferPoolShare[engine], false
+}
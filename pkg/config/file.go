@@ -0,0 +1,354 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is a parsed YAML configuration file. Settings are keyed by the
+// command-line flag they set, so every flag can be configured in the file;
+// the CLI applies them to flags not given on the command line. Thresholds
+// are the scaling thresholds no flag sets, and Instances the per-instance
+// overrides of them.
+type File struct {
+	Path       string
+	Settings   []Setting
+	Thresholds Thresholds
+	Instances  []InstanceOverride
+}
+
+// Setting is a flag value from a configuration file
+type Setting struct {
+	Section string   // "daemon" for daemon settings, empty at the top level
+	Key     string   // Flag name; under daemon, "enabled" stands for --daemon
+	Values  []string // The value, or each element of a list
+	Line    int
+}
+
+// Name is the setting's path in the file, e.g. "daemon.interval"
+func (s Setting) Name() string {
+	if s.Section != "" {
+		return s.Section + "." + s.Key
+	}
+	return s.Key
+}
+
+// Thresholds are scaling thresholds set by a configuration file. Nil fields
+// keep the value of the profile.
+type Thresholds struct {
+	CPUTargetUtilization    *float64
+	MemoryTargetUtilization *float64
+	ScaleUpThreshold        *float64
+	ScaleDownThreshold      *float64
+	MinStableDuration       *time.Duration
+	CoolDownPeriod          *time.Duration
+	MetricsPeriod           *time.Duration
+	MetricsInterval         *time.Duration
+}
+
+// ApplyTo sets the thresholds of cfg that t sets
+func (t Thresholds) ApplyTo(cfg *Config) {
+	setFloat := func(dst *float64, v *float64) {
+		if v != nil {
+			*dst = *v
+		}
+	}
+	setDuration := func(dst *time.Duration, v *time.Duration) {
+		if v != nil {
+			*dst = *v
+		}
+	}
+	setFloat(&cfg.CPUTargetUtilization, t.CPUTargetUtilization)
+	setFloat(&cfg.MemoryTargetUtilization, t.MemoryTargetUtilization)
+	setFloat(&cfg.ScaleUpThreshold, t.ScaleUpThreshold)
+	setFloat(&cfg.ScaleDownThreshold, t.ScaleDownThreshold)
+	setDuration(&cfg.MinStableDuration, t.MinStableDuration)
+	setDuration(&cfg.CoolDownPeriod, t.CoolDownPeriod)
+	setDuration(&cfg.MetricsPeriod, t.MetricsPeriod)
+	setDuration(&cfg.MetricsInterval, t.MetricsInterval)
+}
+
+// InstanceOverride judges one instance, or the instances whose names match
+// a regular expression, with its own profile, thresholds and cooldown instead
+// of the active ones
+type InstanceOverride struct {
+	Instance   string     `json:"instance,omitempty"`
+	Match      string     `json:"match,omitempty"` // Regular expression the whole instance name must match, when Instance is empty
+	Profile    string     `json:"profile,omitempty"`
+	Thresholds Thresholds `json:"-"`
+	Line       int        `json:"-"` // Line of the override in the configuration file
+
+	// Config is the active configuration with Profile's thresholds and then
+	// Thresholds applied, set by whoever resolves profile names
+	Config *Config `json:"-"`
+
+	pattern *regexp.Regexp // Match, anchored at both ends
+}
+
+// Name identifies the override: its instance name or its Match pattern
+func (o InstanceOverride) Name() string {
+	if o.Instance != "" {
+		return o.Instance
+	}
+	return o.Match
+}
+
+// Matches reports whether the override judges the instance called name
+func (o InstanceOverride) Matches(name string) bool {
+	if o.Instance != "" {
+		return o.Instance == name
+	}
+	return o.pattern != nil && o.pattern.MatchString(name)
+}
+
+// OverrideFor returns the override judging the instance called name: the one
+// naming it, else the first in the file whose pattern matches it. It reports
+// false when no override applies.
+func (c *Config) OverrideFor(name string) (InstanceOverride, bool) {
+	for _, o := range c.InstanceOverrides {
+		if o.Instance == name {
+			return o, true
+		}
+	}
+	for _, o := range c.InstanceOverrides {
+		if o.Instance == "" && o.Matches(name) {
+			return o, true
+		}
+	}
+	return InstanceOverride{}, false
+}
+
+// CoolDownFor returns how long after scaling the instance called name waits
+// before it is scaled again: its override's cooldown, else CoolDownPeriod
+func (c *Config) CoolDownFor(name string) time.Duration {
+	if o, ok := c.OverrideFor(name); ok && o.Config != nil {
+		return o.Config.CoolDownPeriod
+	}
+	return c.CoolDownPeriod
+}
+
+// LoadFile reads and validates the configuration file at path
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	f := &File{Path: path}
+	if len(doc.Content) == 0 {
+		return f, nil // Empty file
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, f.errorf(root, "", "must be a mapping of settings")
+	}
+	for i := 0; i < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch key.Value {
+		case "daemon":
+			if value.Kind != yaml.MappingNode {
+				return nil, f.errorf(value, key.Value, "must be a mapping of daemon settings")
+			}
+			for j := 0; j < len(value.Content); j += 2 {
+				s, err := f.setting("daemon", value.Content[j], value.Content[j+1])
+				if err != nil {
+					return nil, err
+				}
+				f.Settings = append(f.Settings, s)
+			}
+		case "instances":
+			if err := f.parseInstances(value); err != nil {
+				return nil, err
+			}
+		case "config":
+			return nil, f.errorf(key, key.Value, "a config file cannot load another")
+		default:
+			known, err := f.threshold(&f.Thresholds, key.Value, "", value)
+			if err != nil {
+				return nil, err
+			}
+			if known {
+				continue
+			}
+			s, err := f.setting("", key, value)
+			if err != nil {
+				return nil, err
+			}
+			f.Settings = append(f.Settings, s)
+		}
+	}
+
+	if t := f.Thresholds; t.ScaleUpThreshold != nil && t.ScaleDownThreshold != nil && *t.ScaleDownThreshold >= *t.ScaleUpThreshold {
+		return nil, f.errorf(root, "scale-down-threshold", "must be below scale-up-threshold")
+	}
+	return f, nil
+}
+
+// Errorf returns an error about the setting s of the file
+func (f *File) Errorf(s Setting, format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s: %s", f.Path, s.Line, s.Name(), fmt.Sprintf(format, args...))
+}
+
+// errorf returns an error about the setting named key at node
+func (f *File) errorf(node *yaml.Node, key, format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	if key != "" {
+		msg = key + ": " + msg
+	}
+	return fmt.Errorf("%s:%d: %s", f.Path, node.Line, msg)
+}
+
+// setting parses a flag setting: a scalar, or a list of scalars
+func (f *File) setting(section string, key, value *yaml.Node) (Setting, error) {
+	s := Setting{Section: section, Key: key.Value, Line: key.Line}
+	switch value.Kind {
+	case yaml.ScalarNode:
+		s.Values = []string{value.Value}
+	case yaml.SequenceNode:
+		for _, item := range value.Content {
+			if item.Kind != yaml.ScalarNode {
+				return Setting{}, f.errorf(item, s.Name(), "list elements must be values")
+			}
+			s.Values = append(s.Values, item.Value)
+		}
+	default:
+		return Setting{}, f.errorf(value, s.Name(), "must be a value or a list of values")
+	}
+	return s, nil
+}
+
+// parseInstances parses the list of per-instance overrides
+func (f *File) parseInstances(node *yaml.Node) error {
+	if node.Kind != yaml.SequenceNode {
+		return f.errorf(node, "instances", "must be a list of instance overrides")
+	}
+	seen := make(map[string]bool)
+	for n, item := range node.Content {
+		path := fmt.Sprintf("instances[%d]", n)
+		if item.Kind != yaml.MappingNode {
+			return f.errorf(item, path, "must be a mapping with an instance name and the settings it overrides")
+		}
+		o := InstanceOverride{Line: item.Line}
+		for i := 0; i < len(item.Content); i += 2 {
+			key, value := item.Content[i], item.Content[i+1]
+			switch key.Value {
+			case "instance", "profile":
+				if value.Kind != yaml.ScalarNode || value.Value == "" {
+					return f.errorf(value, path+"."+key.Value, "must be a name")
+				}
+				if key.Value == "instance" {
+					o.Instance = value.Value
+				} else {
+					o.Profile = value.Value
+				}
+			case "match":
+				if value.Kind != yaml.ScalarNode || value.Value == "" {
+					return f.errorf(value, path+".match", "must be a regular expression")
+				}
+				if _, err := regexp.Compile(value.Value); err != nil {
+					return f.errorf(value, path+".match", "invalid regular expression: %v", err)
+				}
+				o.Match, o.pattern = value.Value, regexp.MustCompile("^(?:"+value.Value+")$")
+			case "metrics-period", "metrics-interval":
+				return f.errorf(key, path+"."+key.Value, "cannot be set per instance")
+			default:
+				known, err := f.threshold(&o.Thresholds, key.Value, path+".", value)
+				if err != nil {
+					return err
+				}
+				if !known {
+					return f.errorf(key, path+"."+key.Value, "unknown instance setting (must be instance, match, profile, cpu-target, memory-target, scale-up-threshold, scale-down-threshold, min-stable-duration or cool-down)")
+				}
+			}
+		}
+		switch {
+		case o.Instance == "" && o.Match == "":
+			return f.errorf(item, path, "missing instance name or match pattern")
+		case o.Instance != "" && o.Match != "":
+			return f.errorf(item, path, "sets both instance and match; an override judges one instance or those its pattern matches")
+		case seen[o.Name()] && o.Instance != "":
+			return f.errorf(item, path, "instance %s is already overridden", o.Instance)
+		case seen[o.Name()]:
+			return f.errorf(item, path, "pattern %s is already overridden", o.Match)
+		}
+		if t := o.Thresholds; t.ScaleUpThreshold != nil && t.ScaleDownThreshold != nil && *t.ScaleDownThreshold >= *t.ScaleUpThreshold {
+			return f.errorf(item, path+".scale-down-threshold", "must be below scale-up-threshold")
+		}
+		seen[o.Name()] = true
+		f.Instances = append(f.Instances, o)
+	}
+	return nil
+}
+
+// threshold parses the threshold named key into t, reporting whether key
+// names one. prefix is the path of t in the file.
+func (f *File) threshold(t *Thresholds, key, prefix string, value *yaml.Node) (bool, error) {
+	v := value.Value
+	if value.Kind != yaml.ScalarNode {
+		v = ""
+	}
+	known, err := t.Set(key, v)
+	if err != nil {
+		return true, f.errorf(value, prefix+key, "%v", err)
+	}
+	return known, nil
+}
+
+// Set parses value into the threshold named key, e.g. scale-up-threshold or
+// metrics-period, reporting whether key names one
+func (t *Thresholds) Set(key, value string) (bool, error) {
+	fraction := func(dst **float64) error {
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		if v <= 0 || v > 1 {
+			return fmt.Errorf("must be a fraction above 0 and at most 1")
+		}
+		*dst = &v
+		return nil
+	}
+	duration := func(dst **time.Duration, positive bool) error {
+		v, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		if positive && v <= 0 {
+			return fmt.Errorf("must be a positive duration")
+		}
+		if v < 0 {
+			return fmt.Errorf("must not be negative")
+		}
+		*dst = &v
+		return nil
+	}
+
+	switch key {
+	case "cpu-target":
+		return true, fraction(&t.CPUTargetUtilization)
+	case "memory-target":
+		return true, fraction(&t.MemoryTargetUtilization)
+	case "scale-up-threshold":
+		return true, fraction(&t.ScaleUpThreshold)
+	case "scale-down-threshold":
+		return true, fraction(&t.ScaleDownThreshold)
+	case "min-stable-duration":
+		return true, duration(&t.MinStableDuration, false)
+	case "cool-down":
+		return true, duration(&t.CoolDownPeriod, false)
+	case "metrics-period":
+		return true, duration(&t.MetricsPeriod, true)
+	case "metrics-interval":
+		return true, duration(&t.MetricsInterval, true)
+	}
+	return false, nil
+}
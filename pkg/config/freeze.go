@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FreezeScope is the set of instances a scaling freeze covers
+type FreezeScope string
+
+const (
+	FreezeGlobal  FreezeScope = "global"  // Every instance
+	FreezeProject FreezeScope = "project" // Instances in Project
+	FreezeLabel   FreezeScope = "label"   // Instances labelled LabelKey=LabelValue
+)
+
+// Freeze blocks scaling of the instances in its scope until it expires, such
+// as during a change freeze around a peak sales period. Emergency scale-ups
+// still run; see Config.FreezeEmergencyThreshold.
+type Freeze struct {
+	ID         string      `json:"id,omitempty"`
+	Scope      FreezeScope `json:"scope"`
+	Project    string      `json:"project,omitempty"`
+	LabelKey   string      `json:"label_key,omitempty"`
+	LabelValue string      `json:"label_value,omitempty"`
+	Until      time.Time   `json:"until"`
+	Reason     string      `json:"reason"`
+	Requester  string      `json:"requester,omitempty"`
+	CreatedAt  time.Time   `json:"created_at,omitempty"`
+}
+
+// ParseFreeze parses a freeze of the form SCOPE/UNTIL=REASON where SCOPE is
+// global, project:ID or label:KEY:VALUE and UNTIL is an RFC3339 timestamp
+func ParseFreeze(s string) (Freeze, error) {
+	spec, reason, _ := strings.Cut(s, "=")
+	scope, untilStr, ok := strings.Cut(spec, "/")
+	if !ok {
+		return Freeze{}, fmt.Errorf("invalid freeze %q (must be SCOPE/UNTIL=REASON)", s)
+	}
+
+	until, err := time.Parse(time.RFC3339, untilStr)
+	if err != nil {
+		return Freeze{}, fmt.Errorf("invalid freeze expiry %q: %w", untilStr, err)
+	}
+
+	f := Freeze{Until: until, Reason: reason}
+	kind, target, _ := strings.Cut(scope, ":")
+	f.Scope = FreezeScope(kind)
+	switch f.Scope {
+	case FreezeProject:
+		f.Project = target
+	case FreezeLabel:
+		f.LabelKey, f.LabelValue, _ = strings.Cut(target, ":")
+	}
+	if err := f.Validate(); err != nil {
+		return Freeze{}, err
+	}
+	return f, nil
+}
+
+// Validate checks that the freeze has a usable scope, expiry and reason
+func (f Freeze) Validate() error {
+	switch f.Scope {
+	case FreezeGlobal:
+	case FreezeProject:
+		if f.Project == "" {
+			return fmt.Errorf("project freeze requires a project")
+		}
+	case FreezeLabel:
+		if f.LabelKey == "" || f.LabelValue == "" {
+			return fmt.Errorf("label freeze requires a label key and value")
+		}
+	default:
+		return fmt.Errorf("invalid freeze scope %q (must be 'global', 'project' or 'label')", f.Scope)
+	}
+	if f.Until.IsZero() {
+		return fmt.Errorf("freeze requires an expiry")
+	}
+	if f.Reason == "" {
+		return fmt.Errorf("freeze requires a reason")
+	}
+	return nil
+}
+
+// Active reports whether the freeze has not yet expired at t
+func (f Freeze) Active(t time.Time) bool {
+	return t.Before(f.Until)
+}
+
+// Covers reports whether instance in project is within the freeze's scope
+func (f Freeze) Covers(project string, instance *InstanceInfo) bool {
+	switch f.Scope {
+	case FreezeGlobal:
+		return true
+	case FreezeProject:
+		if instance.Project != "" {
+			project = instance.Project
+		}
+		return project == f.Project
+	case FreezeLabel:
+		value, ok := instance.Labels[f.LabelKey]
+		return ok && value == f.LabelValue
+	}
+	return false
+}
+
+// Target describes the freeze's scope, e.g. "label team=payments"
+func (f Freeze) Target() string {
+	switch f.Scope {
+	case FreezeProject:
+		return "project " + f.Project
+	case FreezeLabel:
+		return fmt.Sprintf("label %s=%s", f.LabelKey, f.LabelValue)
+	}
+	return string(f.Scope)
+}
+
+// ActiveFreeze returns the first freeze that covers instance at t, if any
+func ActiveFreeze(freezes []Freeze, project string, instance *InstanceInfo, t time.Time) (Freeze, bool) {
+	for _, f := range freezes {
+		if f.Active(t) && f.Covers(project, instance) {
+			return f, true
+		}
+	}
+	return Freeze{}, false
+}
@@ -0,0 +1,80 @@
+package config_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// TestParseFreeze checks the scope, expiry and reason of parsed freezes, and
+// that malformed ones are rejected
+func TestParseFreeze(t *testing.T) {
+	until := time.Date(2026, time.November, 30, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in      string
+		want    config.Freeze
+		wantErr string // Substring of the error; empty when it parses
+	}{
+		{in: "global/2026-11-30T00:00:00Z=Black Friday", want: config.Freeze{Scope: config.FreezeGlobal, Until: until, Reason: "Black Friday"}},
+		{in: "project:shop/2026-11-30T00:00:00Z=release", want: config.Freeze{Scope: config.FreezeProject, Project: "shop", Until: until, Reason: "release"}},
+		{in: "label:team:payments/2026-11-30T00:00:00Z=audit", want: config.Freeze{
+			Scope: config.FreezeLabel, LabelKey: "team", LabelValue: "payments", Until: until, Reason: "audit"}},
+		{in: "global=Black Friday", wantErr: "must be SCOPE/UNTIL=REASON"},
+		{in: "global/2026-11-30=Black Friday", wantErr: "invalid freeze expiry"},
+		{in: "global/2026-11-30T00:00:00Z", wantErr: "requires a reason"},
+		{in: "region:us-east1/2026-11-30T00:00:00Z=outage", wantErr: "invalid freeze scope"},
+		{in: "project/2026-11-30T00:00:00Z=release", wantErr: "requires a project"},
+		{in: "label:team/2026-11-30T00:00:00Z=audit", wantErr: "requires a label key and value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := config.ParseFreeze(tt.in)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseFreeze(%q) = %v, want an error containing %q", tt.in, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFreeze(%q): %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseFreeze(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestActiveFreeze checks which instances freezes cover, and that expired
+// freezes cover none
+func TestActiveFreeze(t *testing.T) {
+	now := time.Date(2026, time.November, 27, 9, 0, 0, 0, time.UTC)
+	freezes := []config.Freeze{
+		{Scope: config.FreezeGlobal, Until: now, Reason: "expired"},
+		{Scope: config.FreezeProject, Project: "shop", Until: now.Add(time.Hour), Reason: "project"},
+		{Scope: config.FreezeLabel, LabelKey: "team", LabelValue: "payments", Until: now.Add(time.Hour), Reason: "label"},
+	}
+
+	tests := []struct {
+		name     string
+		project  string // Project being analyzed
+		instance *config.InstanceInfo
+		want     string // Reason of the covering freeze; empty when none does
+	}{
+		{"project being analyzed", "shop", &config.InstanceInfo{Name: "orders-db"}, "project"},
+		{"instance's own project", "billing", &config.InstanceInfo{Name: "orders-db", Project: "shop"}, "project"},
+		{"other project", "shop", &config.InstanceInfo{Name: "orders-db", Project: "billing"}, ""},
+		{"label", "billing", &config.InstanceInfo{Name: "ledger-db", Labels: map[string]string{"team": "payments"}}, "label"},
+		{"other label value", "billing", &config.InstanceInfo{Name: "ledger-db", Labels: map[string]string{"team": "search"}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := config.ActiveFreeze(freezes, tt.project, tt.instance, now)
+			if ok != (tt.want != "") || got.Reason != tt.want {
+				t.Errorf("ActiveFreeze = %+v, %v; want the %q freeze", got, ok, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// weekdays maps the day names accepted in business hours to time.Weekday
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// HourRange is a daily range of wall-clock time, as offsets from midnight
+type HourRange struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// BusinessHours is the recurring weekly period a database serves its main
+// workload in. Scale-down decisions are based on metrics from these hours
+// only, so overnight and weekend idleness does not shrink an instance that
+// is busy during the day.
+type BusinessHours struct {
+	Days     [7]bool // Indexed by time.Weekday
+	Ranges   []HourRange
+	Location *time.Location
+}
+
+// ParseBusinessHours parses business hours of the form "DAYS RANGES [TZ]",
+// e.g. "mon-fri 09:00-18:00 America/New_York". DAYS is a comma-separated
+// list of days or day ranges, RANGES a comma-separated list of HH:MM-HH:MM
+// ranges and TZ an IANA time zone (UTC by default).
+func ParseBusinessHours(s string) (*BusinessHours, error) {
+	fields := strings.Fields(s)
+	if len(fields) < 2 || len(fields) > 3 {
+		return nil, fmt.Errorf("invalid business hours %q (must be DAYS RANGES [TZ], e.g. 'mon-fri 09:00-18:00 UTC')", s)
+	}
+
+	bh := &BusinessHours{Location: time.UTC}
+	for _, spec := range strings.Split(fields[0], ",") {
+		from, to, isRange := strings.Cut(strings.ToLower(spec), "-")
+		first, ok := weekdays[from]
+		if !ok {
+			return nil, fmt.Errorf("invalid business day %q", from)
+		}
+		last := first
+		if isRange {
+			if last, ok = weekdays[to]; !ok {
+				return nil, fmt.Errorf("invalid business day %q", to)
+			}
+		}
+		// Ranges may wrap the week, e.g. sun-thu or fri-mon
+		for d := first; ; d = (d + 1) % 7 {
+			bh.Days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+
+	for _, spec := range strings.Split(fields[1], ",") {
+		startStr, endStr, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid business hours range %q (must be HH:MM-HH:MM)", spec)
+		}
+		start, err := parseClock(startStr)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClock(endStr)
+		if err != nil {
+			return nil, err
+		}
+		if end <= start {
+			return nil, fmt.Errorf("invalid business hours range %q: end must be after start", spec)
+		}
+		bh.Ranges = append(bh.Ranges, HourRange{Start: start, End: end})
+	}
+
+	if len(fields) == 3 {
+		loc, err := time.LoadLocation(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid business hours time zone %q: %w", fields[2], err)
+		}
+		bh.Location = loc
+	}
+	return bh, nil
+}
+
+// parseClock parses a wall-clock time HH:MM, allowing 24:00 for end of day
+func parseClock(s string) (time.Duration, error) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(s, "%d:%d", &hour, &minute); err != nil ||
+		hour < 0 || minute < 0 || minute > 59 || hour*60+minute > 24*60 {
+		return 0, fmt.Errorf("invalid time of day %q (must be HH:MM)", s)
+	}
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute, nil
+}
+
+// Contains reports whether t falls within business hours
+func (b *BusinessHours) Contains(t time.Time) bool {
+	local := t.In(b.Location)
+	if !b.Days[local.Weekday()] {
+		return false
+	}
+	offset := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute
+	for _, r := range b.Ranges {
+		if offset >= r.Start && offset < r.End {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the business hours in the form ParseBusinessHours accepts
+func (b *BusinessHours) String() string {
+	var days []string
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if b.Days[d] {
+			days = append(days, strings.ToLower(d.String()[:3]))
+		}
+	}
+	ranges := make([]string, len(b.Ranges))
+	for i, r := range b.Ranges {
+		ranges[i] = fmt.Sprintf("%s-%s", formatClock(r.Start), formatClock(r.End))
+	}
+	return fmt.Sprintf("%s %s %s", strings.Join(days, ","), strings.Join(ranges, ","), b.Location)
+}
+
+// formatClock formats an offset from midnight as HH:MM
+func formatClock(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
@@ -119,7 +119,12 @@ var MachineTypeRegistry = map[string]MachineType{
 
 // GetMachineType returns a machine type by name
 func GetMachineType(name string) (MachineType, error) {
-	// Check registry first
+	// Tiers reported by the API win over the static registry
+	if mt, ok := discoveredMachineType(name); ok {
+		return mt, nil
+	}
+
+	// Check registry next
 	mt, exists := MachineTypeRegistry[name]
 	if exists {
 		return mt, nil
@@ -138,8 +143,17 @@ func GetMachineType(name string) (MachineType, error) {
 	return MachineType{}, fmt.Errorf("machine type %s not found", name)
 }
 
+// MachineTypeFilter reports whether a candidate machine type may be recommended
+type MachineTypeFilter func(name string) bool
+
 // GetNextLargerMachineType returns the next larger machine type in the same series/tier
 func GetNextLargerMachineType(currentType string) (string, error) {
+	return GetNextLargerMachineTypeWhere(currentType, nil)
+}
+
+// GetNextLargerMachineTypeWhere returns the next larger machine type in the
+// same series/tier that allowed admits; a nil filter admits every type
+func GetNextLargerMachineTypeWhere(currentType string, allowed MachineTypeFilter) (string, error) {
 	current, err := GetMachineType(currentType)
 	if err != nil {
 		return "", err
@@ -147,18 +161,22 @@ func GetNextLargerMachineType(currentType string) (string, error) {
 
 	// Handle custom machine types
 	if current.Series == "custom" {
-		return getNextCustomMachineType(current, true)
+		next, err := getNextCustomMachineType(current, true)
+		if err == nil && allowed != nil && !allowed(next) {
+			return "", fmt.Errorf("no larger machine type available for %s", currentType)
+		}
+		return next, err
 	}
 
 	// Handle performance-optimized types
 	if current.Series == "perf-optimized" {
-		return getNextPerformanceOptimizedType(current, true)
+		return getNextPerformanceOptimizedType(current, true, allowed)
 	}
 
 	var candidates []MachineType
-	for _, mt := range MachineTypeRegistry {
+	for _, mt := range allMachineTypes() {
 		// Same series and tier, but more resources
-		if mt.Series == current.Series && mt.Tier == current.Tier {
+		if mt.Series == current.Series && mt.Tier == current.Tier && (allowed == nil || allowed(mt.Name)) {
 			if mt.CPU > current.CPU || mt.MemoryGB > current.MemoryGB {
 				candidates = append(candidates, mt)
 			}
@@ -182,6 +200,12 @@ func GetNextLargerMachineType(currentType string) (string, error) {
 
 // GetNextSmallerMachineType returns the next smaller machine type in the same series/tier
 func GetNextSmallerMachineType(currentType string) (string, error) {
+	return GetNextSmallerMachineTypeWhere(currentType, nil)
+}
+
+// GetNextSmallerMachineTypeWhere returns the next smaller machine type in the
+// same series/tier that allowed admits; a nil filter admits every type
+func GetNextSmallerMachineTypeWhere(currentType string, allowed MachineTypeFilter) (string, error) {
 	current, err := GetMachineType(currentType)
 	if err != nil {
 		return "", err
@@ -189,18 +213,22 @@ func GetNextSmallerMachineType(currentType string) (string, error) {
 
 	// Handle custom machine types
 	if current.Series == "custom" {
-		return getNextCustomMachineType(current, false)
+		next, err := getNextCustomMachineType(current, false)
+		if err == nil && allowed != nil && !allowed(next) {
+			return "", fmt.Errorf("no smaller machine type available for %s", currentType)
+		}
+		return next, err
 	}
 
 	// Handle performance-optimized types
 	if current.Series == "perf-optimized" {
-		return getNextPerformanceOptimizedType(current, false)
+		return getNextPerformanceOptimizedType(current, false, allowed)
 	}
 
 	var candidates []MachineType
-	for _, mt := range MachineTypeRegistry {
+	for _, mt := range allMachineTypes() {
 		// Same series and tier, but fewer resources
-		if mt.Series == current.Series && mt.Tier == current.Tier {
+		if mt.Series == current.Series && mt.Tier == current.Tier && (allowed == nil || allowed(mt.Name)) {
 			if mt.CPU < current.CPU && mt.MemoryGB < current.MemoryGB {
 				candidates = append(candidates, mt)
 			}
@@ -222,6 +250,21 @@ func GetNextSmallerMachineType(currentType string) (string, error) {
 	return next.Name, nil
 }
 
+// IsUpscale reports whether moving from currentType to targetType adds CPU or
+// memory. It returns an error when either machine type is unknown, as the
+// direction cannot be told then.
+func IsUpscale(currentType, targetType string) (bool, error) {
+	current, err := GetMachineType(currentType)
+	if err != nil {
+		return false, err
+	}
+	target, err := GetMachineType(targetType)
+	if err != nil {
+		return false, err
+	}
+	return target.CPU > current.CPU || target.MemoryGB > current.MemoryGB, nil
+}
+
 // ParseEdition converts a string to Edition type
 func ParseEdition(s string) Edition {
 	switch strings.ToUpper(s) {
@@ -234,6 +277,58 @@ func ParseEdition(s string) Edition {
 	}
 }
 
+// CheckEditionSupport reports an error if an instance of edition cannot use
+// machineType: performance-optimized machine types need Enterprise Plus, and
+// Enterprise Plus does not offer shared-core or custom machine types
+func CheckEditionSupport(edition Edition, machineType string) error {
+	switch {
+	case strings.HasPrefix(machineType, "db-perf-optimized-") && edition != EditionEnterprisePlus:
+		return fmt.Errorf("%s requires Enterprise Plus edition, instance is %s", machineType, edition)
+	case edition == EditionEnterprisePlus && isSharedCore(machineType):
+		return fmt.Errorf("Enterprise Plus edition does not offer shared-core machine type %s", machineType)
+	case edition == EditionEnterprisePlus && strings.HasPrefix(machineType, "db-custom-"):
+		return fmt.Errorf("Enterprise Plus edition does not offer custom machine type %s", machineType)
+	}
+	return nil
+}
+
+// CheckAvailability reports an error if machineType is not offered to
+// instances of edition running databaseVersion. On top of the edition rules,
+// SQL Server does not run on shared-core machine types, and vCPUs beyond its
+// edition's limit would be licensed but never used.
+func CheckAvailability(edition Edition, databaseVersion, machineType string) error {
+	if err := CheckEditionSupport(edition, machineType); err != nil {
+		return err
+	}
+	if ParseEngine(databaseVersion) != EngineSQLServer {
+		return nil
+	}
+	if isSharedCore(machineType) {
+		return fmt.Errorf("SQL Server does not support shared-core machine type %s", machineType)
+	}
+	if limit := MaxVCPUs(databaseVersion); limit > 0 {
+		if mt, err := GetMachineType(machineType); err == nil && mt.CPU > limit {
+			edition := SQLServerEdition(databaseVersion)
+			return fmt.Errorf("SQL Server %s%s edition uses at most %d vCPUs; machine type %s has %d",
+				edition[:1], strings.ToLower(edition[1:]), limit, machineType, mt.CPU)
+		}
+	}
+	return nil
+}
+
+// AvailableFor returns a filter admitting the machine types offered to
+// instances of edition running databaseVersion
+func AvailableFor(edition Edition, databaseVersion string) MachineTypeFilter {
+	return func(name string) bool {
+		return CheckAvailability(edition, databaseVersion, name) == nil
+	}
+}
+
+// isSharedCore reports whether machineType is a shared-core machine type
+func isSharedCore(machineType string) bool {
+	return machineType == "db-f1-micro" || machineType == "db-g1-small"
+}
+
 // parseCustomMachineType parses custom machine types like "db-custom-4-16384"
 func parseCustomMachineType(name string) (MachineType, error) {
 	if !strings.HasPrefix(name, "db-custom-") {
@@ -396,8 +491,9 @@ func getNextCustomMachineType(current MachineType, scaleUp bool) (string, error)
 	return fmt.Sprintf("db-custom-%d-%d", nextCPU, nextMemoryMB), nil
 }
 
-// getNextPerformanceOptimizedType returns next performance-optimized type
-func getNextPerformanceOptimizedType(current MachineType, scaleUp bool) (string, error) {
+// getNextPerformanceOptimizedType returns the next performance-optimized type
+// that allowed admits
+func getNextPerformanceOptimizedType(current MachineType, scaleUp bool, allowed MachineTypeFilter) (string, error) {
 	// Define the sequence of performance-optimized types
 	sequence := []string{"N-2", "N-4", "N-8", "N-16"}
 	cpuMap := map[string]int{"N-2": 2, "N-4": 4, "N-8": 8, "N-16": 16}
@@ -428,21 +524,21 @@ func getNextPerformanceOptimizedType(current MachineType, scaleUp bool) (string,
 		return "", fmt.Errorf("invalid performance-optimized type")
 	}
 
-	// Get next type
-	var nextIdx int
+	// Get next type, skipping sizes the filter rejects
+	step := -1
 	if scaleUp {
-		nextIdx = currentIdx + 1
-		if nextIdx >= len(sequence) {
-			return "", fmt.Errorf("already at maximum performance-optimized size")
-		}
-	} else {
-		nextIdx = currentIdx - 1
-		if nextIdx < 0 {
-			return "", fmt.Errorf("already at minimum performance-optimized size")
+		step = 1
+	}
+	for nextIdx := currentIdx + step; nextIdx >= 0 && nextIdx < len(sequence); nextIdx += step {
+		next := fmt.Sprintf("db-perf-optimized-%s", sequence[nextIdx])
+		if allowed == nil || allowed(next) {
+			return next, nil
 		}
 	}
-
-	return fmt.Sprintf("db-perf-optimized-%s", sequence[nextIdx]), nil
+	if scaleUp {
+		return "", fmt.Errorf("already at maximum performance-optimized size")
+	}
+	return "", fmt.Errorf("already at minimum performance-optimized size")
 }
 
 // Helper functions
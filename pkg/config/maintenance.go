@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaintenanceNight is the weekly window disruptive operations are batched
+// into, so teams see one downtime window a week rather than one per change.
+// The window may run past midnight, e.g. sat 23:00-04:00.
+type MaintenanceNight struct {
+	Day      time.Weekday
+	Start    time.Duration // Offset from midnight on Day
+	Length   time.Duration
+	Location *time.Location
+}
+
+// ParseMaintenanceNight parses a maintenance night of the form
+// "DAY HH:MM-HH:MM [TZ]", e.g. "sat 22:00-04:00 Europe/London". An end at or
+// before the start is on the next day. TZ is an IANA time zone (UTC by
+// default).
+func ParseMaintenanceNight(s string) (*MaintenanceNight, error) {
+	fields := strings.Fields(s)
+	if len(fields) < 2 || len(fields) > 3 {
+		return nil, fmt.Errorf("invalid maintenance night %q (must be DAY HH:MM-HH:MM [TZ], e.g. 'sat 22:00-04:00 UTC')", s)
+	}
+
+	day, ok := weekdays[strings.ToLower(fields[0])]
+	if !ok {
+		return nil, fmt.Errorf("invalid maintenance night day %q", fields[0])
+	}
+	startStr, endStr, ok := strings.Cut(fields[1], "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid maintenance night hours %q (must be HH:MM-HH:MM)", fields[1])
+	}
+	start, err := parseClock(startStr)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClock(endStr)
+	if err != nil {
+		return nil, err
+	}
+	if start == 24*time.Hour {
+		return nil, fmt.Errorf("invalid maintenance night start %q", startStr)
+	}
+	if end <= start {
+		end += 24 * time.Hour
+	}
+
+	night := &MaintenanceNight{Day: day, Start: start, Length: end - start, Location: time.UTC}
+	if len(fields) == 3 {
+		loc, err := time.LoadLocation(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid maintenance night time zone %q: %w", fields[2], err)
+		}
+		night.Location = loc
+	}
+	return night, nil
+}
+
+// Window returns the maintenance night in progress at t, or else the next
+// one to start
+func (n *MaintenanceNight) Window(t time.Time) TimeWindow {
+	local := t.In(n.Location)
+	// A night that started yesterday may still be running
+	for days := -1; ; days++ {
+		date := local.AddDate(0, 0, days)
+		if date.Weekday() != n.Day {
+			continue
+		}
+		start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, n.Location).Add(n.Start)
+		end := start.Add(n.Length)
+		if t.Before(end) {
+			return TimeWindow{Start: start, End: end, Reason: "maintenance night"}
+		}
+	}
+}
+
+// String returns the maintenance night in the form ParseMaintenanceNight accepts
+func (n *MaintenanceNight) String() string {
+	end := (n.Start + n.Length) % (24 * time.Hour)
+	return fmt.Sprintf("%s %s-%s %s", strings.ToLower(n.Day.String()[:3]), formatClock(n.Start), formatClock(end), n.Location)
+}
+
+// MaintenanceWindow is the hour of the week an instance's Cloud SQL
+// maintenance window starts at, in UTC. Cloud SQL applies disruptive updates in
+// it, so owners expect downtime then.
+type MaintenanceWindow struct {
+	Day  time.Weekday
+	Hour int
+}
+
+// maintenanceWindowLength is how long a Cloud SQL maintenance window lasts
+const maintenanceWindowLength = time.Hour
+
+// Window returns the maintenance window in progress at t, or else the next
+// one to start
+func (w MaintenanceWindow) Window(t time.Time) TimeWindow {
+	window := w.night().Window(t)
+	window.Reason = "maintenance window"
+	return window
+}
+
+// String returns the maintenance window as e.g. "sun 03:00-04:00 UTC"
+func (w MaintenanceWindow) String() string {
+	return w.night().String()
+}
+
+// night returns the maintenance window as a maintenance night an hour long
+func (w MaintenanceWindow) night() *MaintenanceNight {
+	return &MaintenanceNight{Day: w.Day, Start: time.Duration(w.Hour) * time.Hour, Length: maintenanceWindowLength, Location: time.UTC}
+}
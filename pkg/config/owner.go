@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// User label keys an instance's ownership is read from when it is not
+// configured. Slack channel names are stored without the leading '#', which
+// label values cannot contain.
+const (
+	LabelOwnerTeam    = "team"
+	LabelOwnerContact = "owner"
+	LabelOwnerChannel = "slack-channel"
+)
+
+// Owner identifies who is responsible for an instance, so recommendations and
+// notifications reach people who can act on them
+type Owner struct {
+	Team    string `json:"team,omitempty"`
+	Contact string `json:"contact,omitempty"` // Person or address to reach
+	Channel string `json:"channel,omitempty"` // Slack channel notifications are routed to with a bot token, e.g. #payments-db
+	Notes   string `json:"notes,omitempty"`
+}
+
+// IsZero reports whether no ownership is known
+func (o Owner) IsZero() bool {
+	return o == Owner{}
+}
+
+// String describes the owner, e.g. "payments (#payments-db, alice@example.com)"
+func (o Owner) String() string {
+	var via []string
+	for _, s := range []string{o.Channel, o.Contact} {
+		if s != "" {
+			via = append(via, s)
+		}
+	}
+	switch {
+	case o.Team == "":
+		return strings.Join(via, ", ")
+	case len(via) == 0:
+		return o.Team
+	}
+	return fmt.Sprintf("%s (%s)", o.Team, strings.Join(via, ", "))
+}
+
+// ParseOwner parses an instance's ownership of the form
+// INSTANCE:KEY=VALUE,... where KEY is team, contact, channel or notes.
+// Values cannot contain commas.
+func ParseOwner(s string) (string, Owner, error) {
+	instance, spec, ok := strings.Cut(s, ":")
+	if !ok || instance == "" {
+		return "", Owner{}, fmt.Errorf("invalid owner %q (must be INSTANCE:KEY=VALUE,...)", s)
+	}
+
+	var o Owner
+	for _, field := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || value == "" {
+			return "", Owner{}, fmt.Errorf("invalid owner field %q (must be KEY=VALUE)", field)
+		}
+		switch key {
+		case "team":
+			o.Team = value
+		case "contact":
+			o.Contact = value
+		case "channel":
+			o.Channel = value
+		case "notes":
+			o.Notes = value
+		default:
+			return "", Owner{}, fmt.Errorf("invalid owner field %q (must be 'team', 'contact', 'channel' or 'notes')", key)
+		}
+	}
+	return instance, o, nil
+}
+
+// OwnerOf returns who owns instance. Configured ownership takes precedence
+// field by field over the instance's team, owner and slack-channel labels.
+func (c *Config) OwnerOf(instance *InstanceInfo) Owner {
+	o := c.Owners[instance.Name]
+	if o.Team == "" {
+		o.Team = instance.Labels[LabelOwnerTeam]
+	}
+	if o.Contact == "" {
+		o.Contact = instance.Labels[LabelOwnerContact]
+	}
+	if o.Channel == "" {
+		if channel := instance.Labels[LabelOwnerChannel]; channel != "" {
+			o.Channel = "#" + channel
+		}
+	}
+	return o
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RedactionMode is how redacted names are masked
+type RedactionMode string
+
+const (
+	RedactHash     RedactionMode = "hash"     // Replaced by a stable pseudonym, so redacted outputs can still be correlated
+	RedactTruncate RedactionMode = "truncate" // Cut to their first few characters
+)
+
+// Redaction is what is masked in JSON output and notifications, for outputs
+// forwarded outside the organization. Costs are removed rather than masked.
+type Redaction struct {
+	Instances bool
+	Projects  bool
+	Costs     bool
+	Mode      RedactionMode
+	Salt      string // Keys hashed pseudonyms, so they cannot be reversed by hashing known names
+}
+
+// Enabled reports whether anything is redacted
+func (r Redaction) Enabled() bool {
+	return r.Instances || r.Projects || r.Costs
+}
+
+// Fields lists what is redacted: instances, projects and costs
+func (r Redaction) Fields() []string {
+	var fields []string
+	if r.Instances {
+		fields = append(fields, "instances")
+	}
+	if r.Projects {
+		fields = append(fields, "projects")
+	}
+	if r.Costs {
+		fields = append(fields, "costs")
+	}
+	return fields
+}
+
+// ParseRedaction parses the fields to redact, any of instances, projects and
+// costs, and the mode names are masked with
+func ParseRedaction(fields []string, mode, salt string) (Redaction, error) {
+	r := Redaction{Mode: RedactionMode(mode), Salt: salt}
+	if r.Mode != RedactHash && r.Mode != RedactTruncate {
+		return Redaction{}, fmt.Errorf("invalid redaction mode %q (must be hash or truncate)", mode)
+	}
+	for _, field := range fields {
+		switch strings.TrimSpace(field) {
+		case "instances":
+			r.Instances = true
+		case "projects":
+			r.Projects = true
+		case "costs":
+			r.Costs = true
+		case "":
+		default:
+			return Redaction{}, fmt.Errorf("invalid redaction field %q (must be instances, projects or costs)", field)
+		}
+	}
+	return r, nil
+}
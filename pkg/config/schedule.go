@@ -0,0 +1,293 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule scales instances for a known recurring load, such as a nightly
+// batch job. For Duration after each time its cron expression fires, the
+// instances it selects run at least MachineType, or are judged with the
+// thresholds of Profile instead of the active ones.
+type Schedule struct {
+	Instance    string        `json:"instance,omitempty"`  // Instance name; empty when selecting by label
+	LabelKey    string        `json:"label_key,omitempty"` // Selects instances labelled LabelKey=LabelValue
+	LabelValue  string        `json:"label_value,omitempty"`
+	Cron        string        `json:"cron"` // Five-field cron expression, optionally followed by an IANA time zone
+	Duration    time.Duration `json:"duration"`
+	MachineType string        `json:"machine_type,omitempty"`
+	Profile     string        `json:"profile,omitempty"`
+	Reason      string        `json:"reason,omitempty"`
+
+	// ProfileConfig is the configuration with Profile's thresholds, set by
+	// whoever resolves profile names
+	ProfileConfig *Config `json:"-"`
+
+	cron *cronSpec
+}
+
+// ParseSchedule parses a schedule of the form
+// SELECTOR=TARGET@CRON[ TZ]/DURATION[=REASON], e.g.
+// "orders-db=db-custom-8-32768@0 2 * * * Europe/Berlin/3h=nightly batch".
+// SELECTOR is an instance name or label:KEY:VALUE, TARGET a machine type or
+// profile:NAME, CRON a five-field cron expression or a macro such as @daily,
+// and DURATION how long each window lasts.
+func ParseSchedule(s string) (Schedule, error) {
+	selector, rest, ok := strings.Cut(s, "=")
+	if !ok || selector == "" {
+		return Schedule{}, fmt.Errorf("invalid schedule %q (must be SELECTOR=TARGET@CRON/DURATION[=REASON])", s)
+	}
+	target, rest, ok := strings.Cut(rest, "@")
+	if !ok || target == "" {
+		return Schedule{}, fmt.Errorf("invalid schedule %q (must be SELECTOR=TARGET@CRON/DURATION[=REASON])", s)
+	}
+	spec, reason, _ := strings.Cut(rest, "=")
+	// Cron expressions and time zones contain '/', durations never do
+	slash := strings.LastIndex(spec, "/")
+	if slash < 0 {
+		return Schedule{}, fmt.Errorf("invalid schedule %q: missing /DURATION", s)
+	}
+	duration, err := time.ParseDuration(spec[slash+1:])
+	if err != nil || duration <= 0 {
+		return Schedule{}, fmt.Errorf("invalid schedule duration %q: must be a positive duration", spec[slash+1:])
+	}
+
+	sched := Schedule{Cron: strings.TrimSpace(spec[:slash]), Duration: duration, Reason: reason}
+	if kind, label, isLabel := strings.Cut(selector, ":"); isLabel {
+		if kind != "label" {
+			return Schedule{}, fmt.Errorf("invalid schedule selector %q (must be INSTANCE or label:KEY:VALUE)", selector)
+		}
+		sched.LabelKey, sched.LabelValue, _ = strings.Cut(label, ":")
+		if sched.LabelKey == "" || sched.LabelValue == "" {
+			return Schedule{}, fmt.Errorf("invalid schedule selector %q: label requires a key and value", selector)
+		}
+	} else {
+		sched.Instance = selector
+	}
+	if profile, isProfile := strings.CutPrefix(target, "profile:"); isProfile {
+		sched.Profile = profile
+	} else {
+		if _, err := GetMachineType(target); err != nil {
+			return Schedule{}, fmt.Errorf("invalid schedule target: %w", err)
+		}
+		sched.MachineType = target
+	}
+
+	if sched.cron, err = parseCron(sched.Cron); err != nil {
+		return Schedule{}, err
+	}
+	return sched, nil
+}
+
+// Covers reports whether the schedule selects instance
+func (s Schedule) Covers(instance *InstanceInfo) bool {
+	if s.Instance != "" {
+		return instance.Name == s.Instance
+	}
+	value, ok := instance.Labels[s.LabelKey]
+	return ok && value == s.LabelValue
+}
+
+// Target describes what the schedule scales to, e.g. "db-custom-8-32768" or
+// "profile aggressive"
+func (s Schedule) Target() string {
+	if s.Profile != "" {
+		return "profile " + s.Profile
+	}
+	return s.MachineType
+}
+
+// Next returns the start of the schedule's first window after t, or the zero
+// time if the cron expression never fires
+func (s Schedule) Next(t time.Time) time.Time {
+	if s.cron == nil {
+		return time.Time{}
+	}
+	return s.cron.next(t)
+}
+
+// Window returns the schedule's window that has started by t+lead and not
+// yet ended at t. Scaling ahead of a window by lead gives the resize time to
+// finish before the load arrives.
+func (s Schedule) Window(t time.Time, lead time.Duration) (TimeWindow, bool) {
+	// The latest start that is still running at t is the first after t-Duration
+	start := s.Next(t.Add(-s.Duration))
+	if start.IsZero() || start.After(t.Add(lead)) {
+		return TimeWindow{}, false
+	}
+	return TimeWindow{Start: start, End: start.Add(s.Duration), Reason: s.Reason}, true
+}
+
+// ActiveSchedule returns the first schedule selecting instance whose window
+// covers t, allowing lead ahead of each window, and that window
+func ActiveSchedule(schedules []Schedule, instance *InstanceInfo, t time.Time, lead time.Duration) (Schedule, TimeWindow, bool) {
+	for _, s := range schedules {
+		if !s.Covers(instance) {
+			continue
+		}
+		if w, ok := s.Window(t, lead); ok {
+			return s, w, true
+		}
+	}
+	return Schedule{}, TimeWindow{}, false
+}
+
+// cronSpec is a parsed cron expression: the minutes, hours, days of the
+// month, months and weekdays it fires at, in a time zone
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64 // Bit sets of the values each field allows
+	domStar, dowStar              bool   // The field was '*', see matchesDay
+	location                      *time.Location
+}
+
+// cronMacros are the shorthand expressions accepted in place of five fields
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronMonths and cronDays are the names accepted in the month and weekday fields
+var (
+	cronMonths = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+	cronDays = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+)
+
+// parseCron parses "MIN HOUR DOM MONTH DOW [TZ]" or "@MACRO [TZ]". Fields
+// accept '*', values, ranges, lists and steps; weekday 7 is Sunday.
+func parseCron(expr string) (*cronSpec, error) {
+	fields := strings.Fields(expr)
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "@") {
+		macro, ok := cronMacros[strings.ToLower(fields[0])]
+		if !ok {
+			return nil, fmt.Errorf("invalid cron expression %q: unknown macro %s", expr, fields[0])
+		}
+		fields = append(strings.Fields(macro), fields[1:]...)
+	}
+	if len(fields) < 5 || len(fields) > 6 {
+		return nil, fmt.Errorf("invalid cron expression %q (must be MIN HOUR DOM MONTH DOW [TZ])", expr)
+	}
+
+	spec := &cronSpec{location: time.UTC}
+	var err error
+	if spec.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("invalid cron minute: %w", err)
+	}
+	if spec.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("invalid cron hour: %w", err)
+	}
+	if spec.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("invalid cron day of month: %w", err)
+	}
+	if spec.month, err = parseCronField(fields[3], 1, 12, cronMonths); err != nil {
+		return nil, fmt.Errorf("invalid cron month: %w", err)
+	}
+	if spec.dow, err = parseCronField(fields[4], 0, 7, cronDays); err != nil {
+		return nil, fmt.Errorf("invalid cron day of week: %w", err)
+	}
+	if spec.dow&(1<<7) != 0 {
+		spec.dow |= 1 // 7 is Sunday too
+	}
+	spec.domStar, spec.dowStar = fields[2] == "*", fields[4] == "*"
+	if len(fields) == 6 {
+		if spec.location, err = time.LoadLocation(fields[5]); err != nil {
+			return nil, fmt.Errorf("invalid cron time zone %q: %w", fields[5], err)
+		}
+	}
+	return spec, nil
+}
+
+// parseCronField parses one comma-separated cron field into the bit set of
+// the values it allows between lo and hi
+func parseCronField(field string, lo, hi int, names map[string]int) (uint64, error) {
+	value := func(s string) (int, error) {
+		if n, ok := names[strings.ToLower(s)]; ok {
+			return n, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < lo || n > hi {
+			return 0, fmt.Errorf("%q is not between %d and %d", s, lo, hi)
+		}
+		return n, nil
+	}
+
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangeSpec, stepSpec, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepSpec); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepSpec)
+			}
+		}
+
+		first, last := lo, hi
+		if rangeSpec != "*" {
+			from, to, isRange := strings.Cut(rangeSpec, "-")
+			var err error
+			if first, err = value(from); err != nil {
+				return 0, err
+			}
+			last = first
+			if isRange {
+				if last, err = value(to); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				last = hi // N/STEP runs from N to the end of the range
+			}
+			if last < first {
+				return 0, fmt.Errorf("invalid range %q", rangeSpec)
+			}
+		}
+		for v := first; v <= last; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// cronSearchLimit bounds how far ahead next looks for a matching time, so
+// expressions that never fire, such as 30 February, terminate
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// next returns the first time after t the expression fires, or the zero time
+func (c *cronSpec) next(t time.Time) time.Time {
+	t = t.In(c.location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronSearchLimit)
+
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<t.Month()) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.location)
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.location)
+		case c.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, c.location)
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// matchesDay reports whether t's day is allowed. As in cron, when both the
+// day of month and day of week are restricted either one matching is enough.
+func (c *cronSpec) matchesDay(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<t.Weekday()) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
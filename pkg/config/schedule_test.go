@@ -0,0 +1,176 @@
+package config_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// scheduleWithCron parses a schedule that fires on cron for an hour
+func scheduleWithCron(t *testing.T, cron string) config.Schedule {
+	t.Helper()
+	s, err := config.ParseSchedule("orders-db=db-custom-2-7680@" + cron + "/1h")
+	if err != nil {
+		t.Fatalf("ParseSchedule with cron %q: %v", cron, err)
+	}
+	return s
+}
+
+// TestCronNext checks when cron expressions next fire after a Thursday
+// morning, covering steps, ranges, lists, names, macros, the day of month
+// and day of week rule and time zones
+func TestCronNext(t *testing.T) {
+	from := time.Date(2026, time.January, 1, 10, 30, 0, 0, time.UTC) // A Thursday
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		cron string
+		want time.Time
+	}{
+		{"0 2 * * *", at(time.January, 2, 2, 0)},
+		{"30 10 * * *", at(time.January, 2, 10, 30)}, // Strictly after from
+		{"*/15 * * * *", at(time.January, 1, 10, 45)},
+		{"5,10 * * * *", at(time.January, 1, 11, 5)},
+		{"0 9-17/4 * * *", at(time.January, 1, 13, 0)},
+		{"0 20/2 * * *", at(time.January, 1, 20, 0)},
+		{"0 0 1 * *", at(time.February, 1, 0, 0)},
+		{"0 0 1 feb-mar *", at(time.February, 1, 0, 0)},
+		{"0 0 * * mon", at(time.January, 5, 0, 0)},
+		{"0 0 * * 7", at(time.January, 4, 0, 0)}, // 7 is Sunday
+		{"0 0 * * SAT,sun", at(time.January, 3, 0, 0)},
+		{"0 0 13 * fri", at(time.January, 2, 0, 0)}, // Either day field matching is enough
+		{"0 0 13 * *", at(time.January, 13, 0, 0)},
+		{"@hourly", at(time.January, 1, 11, 0)},
+		{"@DAILY", at(time.January, 2, 0, 0)},
+		{"@yearly", time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 2 * * * Europe/Berlin", at(time.January, 2, 1, 0)}, // CET is UTC+1 in January
+		{"@daily America/New_York", at(time.January, 2, 5, 0)},
+		{"0 0 30 2 *", time.Time{}}, // Never fires
+	}
+	for _, tt := range tests {
+		t.Run(tt.cron, func(t *testing.T) {
+			got := scheduleWithCron(t, tt.cron).Next(from)
+			if !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", from, got.UTC(), tt.want)
+			}
+		})
+	}
+}
+
+// TestCronErrors checks that malformed cron expressions are rejected with
+// an error naming the bad field
+func TestCronErrors(t *testing.T) {
+	tests := []struct {
+		cron string
+		want string // Substring of the error
+	}{
+		{"0 2 * *", "must be MIN HOUR DOM MONTH DOW"},
+		{"0 2 * * * UTC extra", "must be MIN HOUR DOM MONTH DOW"},
+		{"@fortnightly", "unknown macro"},
+		{"60 * * * *", "invalid cron minute"},
+		{"* 24 * * *", "invalid cron hour"},
+		{"* * 0 * *", "invalid cron day of month"},
+		{"* * * 13 *", "invalid cron month"},
+		{"* * * smarch *", "invalid cron month"},
+		{"* * * * 8", "invalid cron day of week"},
+		{"*/0 * * * *", "invalid step"},
+		{"*/x * * * *", "invalid step"},
+		{"30-10 * * * *", "invalid range"},
+		{"0 2 * * * Mars/Olympus_Mons", "invalid cron time zone"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cron, func(t *testing.T) {
+			_, err := config.ParseSchedule("orders-db=db-custom-2-7680@" + tt.cron + "/1h")
+			if err == nil {
+				t.Fatalf("cron %q parsed, want an error containing %q", tt.cron, tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("cron %q: %v, want an error containing %q", tt.cron, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestScheduleWindow checks which window is active at a time, allowing a
+// lead ahead of each window
+func TestScheduleWindow(t *testing.T) {
+	s, err := config.ParseSchedule("orders-db=db-custom-8-30720@0 2 * * */3h=nightly batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, time.January, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name  string
+		t     time.Time
+		lead  time.Duration
+		start time.Time // Zero when no window is active
+	}{
+		{"before the window", at(1, 0), 0, time.Time{}},
+		{"within the lead", at(1, 50), 15 * time.Minute, at(2, 0)},
+		{"before the lead", at(1, 40), 15 * time.Minute, time.Time{}},
+		{"at the start", at(2, 0), 0, at(2, 0)},
+		{"inside the window", at(4, 59), 0, at(2, 0)},
+		{"at the end", at(5, 0), 0, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, ok := s.Window(tt.t, tt.lead)
+			if ok != !tt.start.IsZero() {
+				t.Fatalf("Window(%v, %v) active = %v, want %v", tt.t, tt.lead, ok, !ok)
+			}
+			if !ok {
+				return
+			}
+			if !w.Start.Equal(tt.start) || !w.End.Equal(tt.start.Add(3*time.Hour)) || w.Reason != "nightly batch" {
+				t.Errorf("Window(%v, %v) = %+v, want 3h from %v for the nightly batch", tt.t, tt.lead, w, tt.start)
+			}
+		})
+	}
+}
+
+// TestParseSchedule checks the selector, target, duration and reason of
+// parsed schedules, and that malformed ones are rejected
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    config.Schedule // Cron and ProfileConfig are not compared
+		wantErr string          // Substring of the error; empty when it parses
+	}{
+		{in: "orders-db=db-custom-8-30720@@daily/2h", want: config.Schedule{Instance: "orders-db", MachineType: "db-custom-8-30720", Duration: 2 * time.Hour}},
+		{in: "label:team:billing=profile:aggressive@0 1 * * 1-5 Europe/Berlin/90m=month end", want: config.Schedule{
+			LabelKey: "team", LabelValue: "billing", Profile: "aggressive", Duration: 90 * time.Minute, Reason: "month end"}},
+		{in: "orders-db", wantErr: "must be SELECTOR=TARGET@CRON/DURATION"},
+		{in: "orders-db=db-custom-8-30720", wantErr: "must be SELECTOR=TARGET@CRON/DURATION"},
+		{in: "orders-db=db-custom-8-30720@@daily", wantErr: "missing /DURATION"},
+		{in: "orders-db=db-custom-8-30720@@daily/0s", wantErr: "must be a positive duration"},
+		{in: "team:billing=db-custom-8-30720@@daily/1h", wantErr: "must be INSTANCE or label:KEY:VALUE"},
+		{in: "label:team=db-custom-8-30720@@daily/1h", wantErr: "label requires a key and value"},
+		{in: "orders-db=db-huge@@daily/1h", wantErr: "invalid schedule target"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := config.ParseSchedule(tt.in)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseSchedule(%q) = %v, want an error containing %q", tt.in, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSchedule(%q): %v", tt.in, err)
+			}
+			if got.Instance != tt.want.Instance || got.LabelKey != tt.want.LabelKey || got.LabelValue != tt.want.LabelValue ||
+				got.MachineType != tt.want.MachineType || got.Profile != tt.want.Profile ||
+				got.Duration != tt.want.Duration || got.Reason != tt.want.Reason {
+				t.Errorf("ParseSchedule(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
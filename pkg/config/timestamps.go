@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// displayLocation is the time zone human-readable timestamps are shown in.
+// It is set once at startup, before any goroutine formats a timestamp.
+var displayLocation = time.UTC
+
+// ParseTimezone resolves an IANA time zone name such as Europe/London, or UTC
+// or Local; empty is UTC
+func ParseTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return loc, nil
+}
+
+// SetDisplayLocation sets the time zone FormatTime shows timestamps in; nil
+// restores UTC
+func SetDisplayLocation(loc *time.Location) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	displayLocation = loc
+}
+
+// DisplayLocation returns the time zone human-readable timestamps are shown in
+func DisplayLocation() *time.Location {
+	return displayLocation
+}
+
+// FormatTime formats t as RFC3339 in the display time zone, e.g.
+// 2025-03-01T14:00:00Z, or 2025-03-01T15:00:00+01:00 in Europe/Paris. Every
+// timestamp in table output, logs, reasons and notifications goes through it,
+// so all of them read the same way.
+func FormatTime(t time.Time) string {
+	return t.In(displayLocation).Format(time.RFC3339)
+}
+
+// InDisplayLocation returns t in the display time zone, for layouts other
+// than RFC3339 such as calendar days
+func InDisplayLocation(t time.Time) time.Time {
+	return t.In(displayLocation)
+}
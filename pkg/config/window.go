@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeWindow is an absolute time range, such as a change freeze during which
+// no scaling may run
+type TimeWindow struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// Contains reports whether t falls inside the window
+func (w TimeWindow) Contains(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+// ParseTimeWindow parses a window of the form START/END[=REASON] where START
+// and END are RFC3339 timestamps
+func ParseTimeWindow(s string) (TimeWindow, error) {
+	spec, reason, _ := strings.Cut(s, "=")
+	startStr, endStr, ok := strings.Cut(spec, "/")
+	if !ok {
+		return TimeWindow{}, fmt.Errorf("invalid window %q (must be START/END in RFC3339)", s)
+	}
+
+	start, err := time.Parse(time.RFC3339, startStr)
+	if err != nil {
+		return TimeWindow{}, fmt.Errorf("invalid window start %q: %w", startStr, err)
+	}
+	end, err := time.Parse(time.RFC3339, endStr)
+	if err != nil {
+		return TimeWindow{}, fmt.Errorf("invalid window end %q: %w", endStr, err)
+	}
+	if !end.After(start) {
+		return TimeWindow{}, fmt.Errorf("invalid window %q: end must be after start", s)
+	}
+
+	return TimeWindow{Start: start, End: end, Reason: reason}, nil
+}
+
+// ActiveBlackout returns the blackout window covering t, if any
+func (c *Config) ActiveBlackout(t time.Time) (TimeWindow, bool) {
+	for _, w := range c.BlackoutWindows {
+		if w.Contains(t) {
+			return w, true
+		}
+	}
+	return TimeWindow{}, false
+}
@@ -0,0 +1,455 @@
+package daemon
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/analyzer"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/rules"
+)
+
+// RecommendationView is the API representation of a single recommendation
+type RecommendationView struct {
+	DecisionID       string  `json:"decision_id"`
+	DecisionKey      string  `json:"decision_key,omitempty"` // Idempotency key of the change, see cloudsql.DecisionKey
+	Instance         string  `json:"instance"`
+	InstanceID       string  `json:"instance_id"`
+	CurrentType      string  `json:"current_type"`
+	RecommendedType  string  `json:"recommended_type"`
+	Reason           string  `json:"reason"`
+	ReasonCode       string  `json:"reason_code,omitempty"`
+	CPUP95           float64 `json:"cpu_p95"`
+	MemoryP95Pct     float64 `json:"memory_p95_pct"`
+	EstimatedSavings float64 `json:"estimated_savings"`
+	Priority         int     `json:"priority"`
+	DowntimeExpected bool    `json:"downtime_expected"`
+	DowntimeReason   string  `json:"downtime_reason,omitempty"`
+
+	ReasonCodes []cloudsql.ReasonCode `json:"reason_codes,omitempty"` // All machine-readable reasons, primary first
+	Warnings    []rules.Warning       `json:"warnings,omitempty"`
+	Stability   *analyzer.Stability   `json:"stability,omitempty"` // Consistency of the recommendation across recent cycles
+}
+
+// RecommendationList is the response body of /api/v1/recommendations
+type RecommendationList struct {
+	ProjectID       string               `json:"project_id"`
+	Sort            analyzer.SortKey     `json:"sort"`
+	Total           int                  `json:"total"`
+	Returned        int                  `json:"returned"`
+	Recommendations []RecommendationView `json:"recommendations"`
+	Timestamp       time.Time            `json:"timestamp"`
+}
+
+// newRecommendationView converts an analysis result into its API representation
+func newRecommendationView(r *analyzer.AnalysisResult) RecommendationView {
+	return RecommendationView{
+		DecisionID:       r.Decision.ID,
+		DecisionKey:      r.Decision.Key,
+		Instance:         r.Instance.Name,
+		InstanceID:       r.Instance.ID(),
+		CurrentType:      r.Decision.CurrentType,
+		RecommendedType:  r.Decision.RecommendedType,
+		Reason:           r.Decision.Reason,
+		ReasonCode:       string(r.Decision.ReasonCode()),
+		ReasonCodes:      r.Decision.ReasonCodes,
+		CPUP95:           r.Summary.CPUP95,
+		MemoryP95Pct:     r.Summary.MemoryP95Pct,
+		EstimatedSavings: r.Decision.EstimatedSavings,
+		Priority:         r.Priority(),
+		DowntimeExpected: r.Decision.DowntimeExpected,
+		DowntimeReason:   r.Decision.DowntimeReason,
+		Warnings:         r.Warnings,
+		Stability:        r.Stability,
+	}
+}
+
+// writeJSON writes v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{"error": message})
+}
+
+// recommendationsHandler lists scaling recommendations from the last cycle,
+// supporting ?sort=savings|pressure|priority|name and ?top=N
+func (s *HTTPServer) recommendationsHandler(w http.ResponseWriter, r *http.Request) {
+	if s.daemon == nil {
+		writeError(w, http.StatusServiceUnavailable, "daemon not available")
+		return
+	}
+
+	key, err := analyzer.ParseSortKey(r.URL.Query().Get("sort"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	top := 0
+	if v := r.URL.Query().Get("top"); v != "" {
+		top, err = strconv.Atoi(v)
+		if err != nil || top < 0 {
+			writeError(w, http.StatusBadRequest, "top must be a non-negative integer")
+			return
+		}
+	}
+
+	results := s.daemon.LastResults()
+	if results == nil {
+		writeError(w, http.StatusServiceUnavailable, "no analysis cycle has completed yet")
+		return
+	}
+
+	scalable := analyzer.RankResults(results.GetScalableInstances(), key)
+	selected := analyzer.TopN(scalable, top)
+
+	list := RecommendationList{
+		ProjectID:       results.ProjectID,
+		Sort:            key,
+		Total:           len(scalable),
+		Returned:        len(selected),
+		Recommendations: make([]RecommendationView, 0, len(selected)),
+		Timestamp:       time.Now().UTC(),
+	}
+	for _, result := range selected {
+		list.Recommendations = append(list.Recommendations, newRecommendationView(result))
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+// authorized checks the request's bearer token against the configured API token.
+// Mutating endpoints are disabled entirely when no token is configured.
+func (s *HTTPServer) authorized(w http.ResponseWriter, r *http.Request) bool {
+	apiToken := s.apiToken.Value()
+	if apiToken == "" {
+		writeError(w, http.StatusForbidden, "endpoint disabled: no API token configured")
+		return false
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(apiToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+		return false
+	}
+	return true
+}
+
+// preScaleHandler accepts temporary pre-scale requests (POST) and lists known
+// pre-scales (GET)
+func (s *HTTPServer) preScaleHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+	if s.daemon == nil {
+		writeError(w, http.StatusServiceUnavailable, "daemon not available")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"prescales": s.daemon.preScaler.List()})
+	case http.MethodPost:
+		var req PreScaleRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+		if req.Instance == "" || req.MachineType == "" {
+			writeError(w, http.StatusBadRequest, "instance and machine_type are required")
+			return
+		}
+
+		ps, err := s.daemon.preScaler.Submit(r.Context(), req, time.Now())
+		if errors.Is(err, ErrPreScaleRejected) {
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusAccepted, ps)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// freezesHandler sets scaling freezes (POST), lists those in effect (GET) and
+// lifts them (DELETE ?id=ID)
+func (s *HTTPServer) freezesHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+	if s.daemon == nil {
+		writeError(w, http.StatusServiceUnavailable, "daemon not available")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"freezes": s.daemon.freezer.Active(time.Now())})
+	case http.MethodPost:
+		var req config.Freeze
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+
+		freeze, err := s.daemon.freezer.Add(r.Context(), req, time.Now())
+		if errors.Is(err, ErrFreezeRejected) {
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		log.Printf("Scaling freeze %s set (%s) until %s: %s", freeze.ID, freeze.Target(), config.FormatTime(freeze.Until), freeze.Reason)
+		s.daemon.events.Publish(EventFreezeSet, "", fmt.Sprintf("Scaling freeze set (%s) until %s: %s",
+			freeze.Target(), config.FormatTime(freeze.Until), freeze.Reason), freeze)
+		writeJSON(w, http.StatusCreated, freeze)
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			writeError(w, http.StatusBadRequest, "id is required")
+			return
+		}
+		if !s.daemon.freezer.Lift(r.Context(), id) {
+			writeError(w, http.StatusNotFound, "no freeze with id "+id+" set through the API")
+			return
+		}
+		log.Printf("Scaling freeze %s lifted", id)
+		s.daemon.events.Publish(EventFreezeLifted, "", "Scaling freeze "+id+" lifted", map[string]string{"id": id})
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+const (
+	// eventKeepalive is how often an idle event stream sends a comment so
+	// proxies keep the connection open
+	eventKeepalive = 15 * time.Second
+	// maxEventWait is the longest a long poll of /api/v1/events waits
+	maxEventWait = 60 * time.Second
+)
+
+// eventFilter selects events by ?type=a,b and ?instance=NAME
+type eventFilter struct {
+	types    map[EventType]bool
+	instance string
+}
+
+// newEventFilter reads an event filter from the request's query
+func newEventFilter(r *http.Request) eventFilter {
+	f := eventFilter{instance: r.URL.Query().Get("instance")}
+	if v := r.URL.Query().Get("type"); v != "" {
+		f.types = make(map[EventType]bool)
+		for _, t := range strings.Split(v, ",") {
+			f.types[EventType(strings.TrimSpace(t))] = true
+		}
+	}
+	return f
+}
+
+// match reports whether event passes the filter
+func (f eventFilter) match(event Event) bool {
+	if f.types != nil && !f.types[event.Type] {
+		return false
+	}
+	return f.instance == "" || event.Instance == f.instance
+}
+
+// eventsHandler streams daemon events as Server-Sent Events. Clients resume
+// after a disconnect with the Last-Event-ID header. With ?since=ID it instead
+// long-polls: it returns the events after ID as JSON, waiting up to ?wait
+// (default 30s) for one if there are none yet.
+func (s *HTTPServer) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	if s.daemon == nil {
+		writeError(w, http.StatusServiceUnavailable, "daemon not available")
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	// Streams and long polls outlive the server's write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		writeError(w, http.StatusInternalServerError, "event streaming not supported")
+		return
+	}
+
+	filter := newEventFilter(r)
+	if since := r.URL.Query().Get("since"); since != "" {
+		s.pollEvents(w, r, since, filter)
+		return
+	}
+	s.streamEvents(w, r, filter)
+}
+
+// pollEvents answers a long poll for the events after since
+func (s *HTTPServer) pollEvents(w http.ResponseWriter, r *http.Request, since string, filter eventFilter) {
+	lastID, err := strconv.ParseUint(since, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "since must be an event ID")
+		return
+	}
+	wait := 30 * time.Second
+	if v := r.URL.Query().Get("wait"); v != "" {
+		wait, err = time.ParseDuration(v)
+		if err != nil || wait < 0 || wait > maxEventWait {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("wait must be a duration up to %v", maxEventWait))
+			return
+		}
+	}
+
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+	for {
+		events, changed := s.daemon.events.Since(lastID)
+		matched := make([]Event, 0, len(events))
+		for _, event := range events {
+			lastID = event.ID
+			if filter.match(event) {
+				matched = append(matched, event)
+			}
+		}
+		if len(matched) > 0 {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"events": matched, "last_event_id": lastID})
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-timeout.C:
+			writeJSON(w, http.StatusOK, map[string]interface{}{"events": matched, "last_event_id": lastID})
+			return
+		case <-r.Context().Done():
+			return
+		case <-s.daemon.ctx.Done():
+			return
+		}
+	}
+}
+
+// streamEvents writes events to w as Server-Sent Events until the client
+// disconnects or falls too far behind
+func (s *HTTPServer) streamEvents(w http.ResponseWriter, r *http.Request, filter eventFilter) {
+	var lastID uint64
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Last-Event-ID must be an event ID")
+			return
+		}
+		lastID = id
+	}
+
+	backlog, events, cancel := s.daemon.events.Subscribe(lastID)
+	defer cancel()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event Event) error {
+		if !filter.match(event) {
+			return nil
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	for _, event := range backlog {
+		if err := send(event); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	keepalive := time.NewTicker(eventKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				// Fell behind; the client reconnects with Last-Event-ID
+				return
+			}
+			if err := send(event); err != nil {
+				return
+			}
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-s.daemon.ctx.Done():
+			return
+		}
+	}
+}
+
+// versionHandler returns the running build's version and API versions
+func (s *HTTPServer) versionHandler(w http.ResponseWriter, r *http.Request) {
+	if s.daemon == nil {
+		writeError(w, http.StatusServiceUnavailable, "daemon not available")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.daemon.Build())
+}
+
+// configHandler returns the effective configuration with secrets redacted
+func (s *HTTPServer) configHandler(w http.ResponseWriter, r *http.Request) {
+	if s.daemon == nil {
+		writeError(w, http.StatusServiceUnavailable, "daemon not available")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"config":    s.daemon.EffectiveConfig(),
+		"timestamp": time.Now().UTC(),
+	})
+}
@@ -0,0 +1,204 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/analyzer"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/approval"
+)
+
+// approvalKey identifies the change an operation makes, as approval requests do
+func approvalKey(instance, currentType, targetType string) string {
+	return instance + "/" + currentType + "/" + targetType
+}
+
+// newApprovalRequest describes op for an approver
+func newApprovalRequest(project string, op analyzer.ScalingOperation) approval.Request {
+	return approval.Request{
+		Project:          project,
+		Instance:         op.Instance,
+		InstanceID:       op.InstanceID,
+		CurrentType:      op.CurrentType,
+		TargetType:       op.TargetType,
+		Reason:           op.Reason,
+		ReasonCodes:      op.ReasonCodes,
+		EstimatedSavings: op.EstimatedSavings,
+		DowntimeExpected: op.DowntimeExpected,
+	}
+}
+
+// awaitApproval records the plan's operations, deferred ones included, as
+// approval requests and defers those not yet approved. Operations deferred for
+// an invalid target or revert review are never applied by the cycle, and
+// unstable ones may not be recommended again, so no approval is asked for
+// them. A store that cannot be updated defers every
+// operation.
+func (r *autoscalingRunner) awaitApproval(plan *analyzer.ScalingPlan, now time.Time) *analyzer.ScalingPlan {
+	if r.approvals == nil || r.config.IsDryRun() {
+		return plan
+	}
+
+	var planned []approval.Request
+	for _, op := range plan.Operations {
+		planned = append(planned, newApprovalRequest(r.config.GetProjectID(), op))
+	}
+	for _, d := range plan.Deferred {
+		if d.DeferKind == analyzer.DeferInvalidTarget || d.DeferKind == analyzer.DeferRevertReview || d.DeferKind == analyzer.DeferUnstable {
+			continue
+		}
+		planned = append(planned, newApprovalRequest(r.config.GetProjectID(), d.ScalingOperation))
+	}
+
+	requests, err := r.approvals.Propose(planned, now)
+	if err != nil {
+		log.Printf("Failed to update approval requests: %v", err)
+		r.metrics.RecordError("approval_store_failed")
+		return plan.AwaitApproval(func(analyzer.ScalingOperation) (string, bool) {
+			return "Approval store unavailable: " + err.Error(), false
+		})
+	}
+
+	byKey := make(map[string]approval.Request, len(requests))
+	for _, req := range requests {
+		byKey[approvalKey(req.Instance, req.CurrentType, req.TargetType)] = req
+		if req.CreatedAt.Equal(now) {
+			message := fmt.Sprintf("Scaling from %s to %s awaits approval of request %s", req.CurrentType, req.TargetType, req.ID)
+			log.Printf("Scaling of %s from %s to %s awaits approval of request %s", req.Instance, req.CurrentType, req.TargetType, req.ID)
+			r.publish(EventApprovalRequested, req.Instance, message, req)
+		}
+	}
+
+	approved := make(map[string]string)
+	plan = plan.AwaitApproval(func(op analyzer.ScalingOperation) (string, bool) {
+		req := byKey[approvalKey(op.Instance, op.CurrentType, op.TargetType)]
+		switch req.Status {
+		case approval.StatusApproved:
+			approved[op.Instance] = req.ID
+			return "", true
+		case approval.StatusRejected:
+			return fmt.Sprintf("Request %s rejected by %s%s", req.ID, req.DecidedBy, commentSuffix(req.Comment)), false
+		default:
+			return "Awaiting approval of request " + req.ID, false
+		}
+	})
+
+	r.notifyMu.Lock()
+	r.approved = approved
+	r.notifyMu.Unlock()
+	return plan
+}
+
+// completeApproval records the outcome of applying op against the request
+// that approved it
+func (r *autoscalingRunner) completeApproval(op analyzer.ScalingOperation, applyErr error) {
+	if r.approvals == nil {
+		return
+	}
+	r.notifyMu.Lock()
+	id, ok := r.approved[op.Instance]
+	r.notifyMu.Unlock()
+	if !ok {
+		return
+	}
+	if err := r.approvals.Complete(id, applyErr, time.Now()); err != nil {
+		log.Printf("Failed to record outcome of approval request %s: %v", id, err)
+		r.metrics.RecordError("approval_store_failed")
+	}
+}
+
+// commentSuffix formats an approver's comment for a message
+func commentSuffix(comment string) string {
+	if comment == "" {
+		return ""
+	}
+	return ": " + comment
+}
+
+// approvalDecision is the body of a request to approve or reject
+type approvalDecision struct {
+	By      string `json:"by"`
+	Comment string `json:"comment"`
+}
+
+// approvalsHandler lists approval requests (GET /api/v1/approvals, optionally
+// ?status=S) and decides them (POST /api/v1/approvals/ID/approve or /reject)
+func (s *HTTPServer) approvalsHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+	if s.daemon == nil {
+		writeError(w, http.StatusServiceUnavailable, "daemon not available")
+		return
+	}
+	if s.daemon.approvals == nil {
+		writeError(w, http.StatusNotFound, "approvals are not required")
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/approvals"), "/")
+	if path == "" {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		requests, err := s.daemon.approvals.List()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		status := approval.Status(r.URL.Query().Get("status"))
+		listed := make([]approval.Request, 0, len(requests))
+		for _, req := range requests {
+			if status == "" || req.Status == status {
+				listed = append(listed, req)
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"approvals": listed})
+		return
+	}
+
+	id, action, ok := strings.Cut(path, "/")
+	if !ok || (action != "approve" && action != "reject") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var decision approvalDecision
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&decision); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if decision.By == "" {
+		writeError(w, http.StatusBadRequest, "by is required")
+		return
+	}
+
+	req, err := s.daemon.approvals.Decide(id, action == "approve", decision.By, decision.Comment, time.Now())
+	switch {
+	case errors.Is(err, approval.ErrNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, approval.ErrDecided):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	message := fmt.Sprintf("Request %s to scale from %s to %s %s by %s%s", req.ID, req.CurrentType, req.TargetType,
+		req.Status, req.DecidedBy, commentSuffix(req.Comment))
+	log.Printf("Approval request %s for %s %s by %s%s", req.ID, req.Instance, req.Status, req.DecidedBy, commentSuffix(req.Comment))
+	s.daemon.events.Publish(EventApprovalDecided, req.Instance, message, req)
+	writeJSON(w, http.StatusOK, req)
+}
@@ -14,16 +14,29 @@ type daemonConfig struct {
 	metricsEnabled bool
 	projectID      string
 	dryRun         bool
+	latencyBudget  time.Duration
+	shadow         *config.Config
+	emergencyAt    float64
+	overloadCycles int
+	stableCycles   int
 }
 
-// NewDaemonConfig creates a new daemon configuration
-func NewDaemonConfig(cfg *config.Config, interval time.Duration, httpPort int, metricsEnabled bool) Config {
+// NewDaemonConfig creates a new daemon configuration. Instances are notified
+// as overloaded after overloadCycles consecutive cycles above the scale-up
+// thresholds; zero disables overload notifications. Operations are applied
+// once their recommendation was made stableCycles consecutive cycles.
+func NewDaemonConfig(cfg *config.Config, interval time.Duration, httpPort int, metricsEnabled bool, overloadCycles, stableCycles int) Config {
 	return &daemonConfig{
 		interval:       interval,
 		httpPort:       httpPort,
 		metricsEnabled: metricsEnabled,
 		projectID:      cfg.ProjectID,
 		dryRun:         cfg.DryRun,
+		latencyBudget:  cfg.AnalysisLatencyBudget,
+		shadow:         cfg.Shadow,
+		emergencyAt:    cfg.FreezeEmergencyThreshold,
+		overloadCycles: overloadCycles,
+		stableCycles:   stableCycles,
 	}
 }
 
@@ -52,6 +65,34 @@ func (c *daemonConfig) GetProjectID() string {
 	return c.projectID
 }
 
+// GetAnalysisLatencyBudget returns the per-instance analysis latency budget
+func (c *daemonConfig) GetAnalysisLatencyBudget() time.Duration {
+	return c.latencyBudget
+}
+
+// GetShadowConfig returns the candidate configuration evaluated in shadow, or nil
+func (c *daemonConfig) GetShadowConfig() *config.Config {
+	return c.shadow
+}
+
+// GetFreezeEmergencyThreshold returns the utilization percentage at which
+// scale-ups run despite a freeze
+func (c *daemonConfig) GetFreezeEmergencyThreshold() float64 {
+	return c.emergencyAt
+}
+
+// GetOverloadCycles returns the consecutive cycles above the scale-up
+// thresholds after which an instance is notified as overloaded; zero disables
+func (c *daemonConfig) GetOverloadCycles() int {
+	return c.overloadCycles
+}
+
+// GetStableCycles returns the consecutive cycles a recommendation must be
+// made before it is applied; zero or one applies it at once
+func (c *daemonConfig) GetStableCycles() int {
+	return c.stableCycles
+}
+
 // validateConfig validates daemon configuration
 // Following explicit error handling patterns
 func validateConfig(cfg *config.Config, interval time.Duration, httpPort int) error {
@@ -0,0 +1,423 @@
+package daemon
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// redacted replaces secret values in the effective configuration
+const redacted = "[redacted]"
+
+// ConfigView is the API representation of the configuration a running
+// daemon uses, after profiles and flags are resolved. Durations are Go
+// duration strings and utilization thresholds fractions (0-1).
+type ConfigView struct {
+	ProjectID  string `json:"project_id"`
+	Profile    string `json:"profile,omitempty"`
+	ConfigFile string `json:"config_file,omitempty"`
+	DryRun     bool   `json:"dry_run"`
+	ReadOnly   bool   `json:"read_only"`
+	Force      bool   `json:"force"`
+
+	IdempotencyWindow string `json:"idempotency_window"`
+
+	MetricsSource   string `json:"metrics_source,omitempty"`
+	MetricsPeriod   string `json:"metrics_period"`
+	MetricsInterval string `json:"metrics_interval"`
+
+	CPUTargetUtilization    float64 `json:"cpu_target_utilization"`
+	MemoryTargetUtilization float64 `json:"memory_target_utilization"`
+	ScaleUpThreshold        float64 `json:"scale_up_threshold"`
+	ScaleDownThreshold      float64 `json:"scale_down_threshold"`
+	MinStableDuration       string  `json:"min_stable_duration"`
+	CoolDownPeriod          string  `json:"cool_down_period"`
+	BusinessHours           string  `json:"business_hours,omitempty"`
+	ScaleUpRule             string  `json:"scale_up_rule,omitempty"`
+	ScaleDownRule           string  `json:"scale_down_rule,omitempty"`
+
+	DeniedMachineTypes  []string `json:"denied_machine_types,omitempty"`
+	AllowedMachineTypes []string `json:"allowed_machine_types,omitempty"`
+
+	TrendWindow          string  `json:"trend_window"`
+	CPUTrendThreshold    float64 `json:"cpu_trend_threshold"`    // Percentage points per hour; 0 = off
+	MemoryTrendThreshold float64 `json:"memory_trend_threshold"` // Percentage points per hour; 0 = off
+
+	Predictive      bool   `json:"predictive"`
+	ForecastHorizon string `json:"forecast_horizon"`
+
+	ColdStart       bool   `json:"cold_start"`
+	ColdStartWindow string `json:"cold_start_window"`
+
+	FleetSignals   bool `json:"fleet_signals"`
+	CatalogPricing bool `json:"catalog_pricing"`
+
+	DataCacheHitRatioThreshold float64                                             `json:"data_cache_hit_ratio_threshold"`
+	MemoryPressureModes        map[config.DatabaseEngine]config.MemoryPressureMode `json:"memory_pressure_modes,omitempty"`
+	SQLServerScaleUpThreshold  float64                                             `json:"sqlserver_scale_up_threshold"`
+	ReplicaPolicy              config.ReplicaPolicy                                `json:"replica_policy"`
+	ConnectionCapacityPolicy   config.ConnectionCapacityPolicy                     `json:"connection_capacity_policy"`
+	PerConnectionMemoryMB      float64                                             `json:"per_connection_memory_mb,omitempty"` // 0 = engine estimate
+
+	RevertDeadline    string `json:"revert_deadline"` // 0s = off
+	RevertQuietPeriod string `json:"revert_quiet_period"`
+	RevertApply       bool   `json:"revert_apply"`
+
+	RollbackWindow    string  `json:"rollback_window"` // 0s = off
+	RollbackThreshold float64 `json:"rollback_threshold"`
+
+	StorageScaling           bool    `json:"storage_scaling"`
+	StorageScaleUpThreshold  float64 `json:"storage_scale_up_threshold"` // 0 = off
+	StorageTargetUtilization float64 `json:"storage_target_utilization"`
+	StorageMinIncreaseGB     int64   `json:"storage_min_increase_gb"`
+	MaxDiskSizeGB            int64   `json:"max_disk_size_gb"` // 0 = platform limit
+
+	DiskShrinkThreshold float64 `json:"disk_shrink_threshold"` // 0 = off
+	DiskShrinkHorizon   string  `json:"disk_shrink_horizon"`
+
+	IOBoundThreshold float64 `json:"io_bound_threshold"` // 0 = off
+
+	ReplicaScaling            bool    `json:"replica_scaling"`
+	ReplicaScaleUpThreshold   float64 `json:"replica_scale_up_threshold"` // 0 = off
+	ReplicaScaleDownThreshold float64 `json:"replica_scale_down_threshold"`
+	MaxReplicaLag             string  `json:"max_replica_lag"` // 0s = ignore lag
+	MinReadReplicas           int     `json:"min_read_replicas"`
+	MaxReadReplicas           int     `json:"max_read_replicas"` // 0 = no limit
+
+	MonitoringQuotaPerMinute int                   `json:"monitoring_quota_per_minute"`
+	AnalysisSpreadWindow     string                `json:"analysis_spread_window"`
+	AnalysisConcurrency      int                   `json:"analysis_concurrency"`
+	AnalysisLatencyBudget    string                `json:"analysis_latency_budget"`
+	InstanceAnalysisTimeout  string                `json:"instance_analysis_timeout"`
+	MetricsHedgeDelay        string                `json:"metrics_hedge_delay"`
+	SampleFraction           float64               `json:"sample_fraction"`
+	SampleStrategy           config.SampleStrategy `json:"sample_strategy,omitempty"`
+	SampleMaxAge             string                `json:"sample_max_age"`
+
+	ProbeEnabled  bool   `json:"probe_enabled"`
+	ProbeIPType   string `json:"probe_ip_type,omitempty"`
+	ProbePort     int    `json:"probe_port,omitempty"`
+	ProbeTimeout  string `json:"probe_timeout"`
+	ProbeInterval string `json:"probe_interval"`
+
+	CycleCostIncreaseCap     float64 `json:"cycle_cost_increase_cap"`
+	MaxOperationsPerCycle    int     `json:"max_operations_per_cycle"`
+	BundleDowntimeOperations bool    `json:"bundle_downtime_operations"`
+	MaintenanceNight         string  `json:"maintenance_night,omitempty"`
+	MaintenanceWindows       bool    `json:"maintenance_windows"`
+	DowntimeBudget           string  `json:"downtime_budget,omitempty"`
+	Currency                 string  `json:"currency,omitempty"`
+	CurrencyPerUSD           float64 `json:"currency_per_usd,omitempty"`
+	CurrencyLocale           string  `json:"currency_locale,omitempty"`
+	Commitment               string  `json:"cud,omitempty"` // TERM:PERCENT committed use discount savings account for
+	Timezone                 string  `json:"timezone"`      // Time zone of human-readable timestamps; JSON timestamps are always UTC
+
+	Redact     []string `json:"redact,omitempty"` // What is masked in JSON output and notifications
+	RedactMode string   `json:"redact_mode,omitempty"`
+	RedactSalt string   `json:"redact_salt,omitempty"` // Redacted when set
+
+	DecisionHookURL      string `json:"decision_hook_url,omitempty"`    // Redacted when set
+	DecisionHookSecret   string `json:"decision_hook_secret,omitempty"` // Redacted when set
+	DecisionHookTimeout  string `json:"decision_hook_timeout,omitempty"`
+	DecisionHookFailOpen bool   `json:"decision_hook_fail_open,omitempty"`
+
+	BlackoutWindows          []config.TimeWindow     `json:"blackout_windows"`
+	Freezes                  []config.Freeze         `json:"freezes"` // Configured at startup; see /api/v1/freezes for those in effect
+	FreezeEmergencyThreshold float64                 `json:"freeze_emergency_threshold"`
+	Schedules                []ScheduleView          `json:"schedules,omitempty"`
+	ScheduleLead             string                  `json:"schedule_lead"`
+	Owners                   map[string]config.Owner `json:"owners,omitempty"`
+
+	InstanceOverrides []InstanceOverrideView `json:"instance_overrides,omitempty"`
+
+	Daemon *DaemonConfigView `json:"daemon,omitempty"`
+	Shadow *ConfigView       `json:"shadow,omitempty"` // Candidate configuration evaluated in shadow
+}
+
+// ScheduleView is the API representation of a scheduled scaling policy
+type ScheduleView struct {
+	Instance    string `json:"instance,omitempty"`
+	LabelKey    string `json:"label_key,omitempty"`
+	LabelValue  string `json:"label_value,omitempty"`
+	Cron        string `json:"cron"`
+	Duration    string `json:"duration"`
+	MachineType string `json:"machine_type,omitempty"`
+	Profile     string `json:"profile,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// InstanceOverrideView is the API representation of an instance, or the
+// instances matching a pattern, judged with their own profile, thresholds and
+// cooldown; the thresholds are the resolved ones
+type InstanceOverrideView struct {
+	Instance                string  `json:"instance,omitempty"`
+	Match                   string  `json:"match,omitempty"`
+	Profile                 string  `json:"profile,omitempty"`
+	CPUTargetUtilization    float64 `json:"cpu_target_utilization"`
+	MemoryTargetUtilization float64 `json:"memory_target_utilization"`
+	ScaleUpThreshold        float64 `json:"scale_up_threshold"`
+	ScaleDownThreshold      float64 `json:"scale_down_threshold"`
+	MinStableDuration       string  `json:"min_stable_duration"`
+	CoolDownPeriod          string  `json:"cool_down_period"`
+}
+
+// DaemonConfigView is the API representation of daemon-only settings
+type DaemonConfigView struct {
+	Interval            string `json:"interval"`
+	HTTPPort            int    `json:"http_port"`
+	EnableMetrics       bool   `json:"enable_metrics"`
+	APIToken            string `json:"api_token"`                  // Redacted when set
+	APITokenSource      string `json:"api_token_source,omitempty"` // Reference the token is loaded from, if not given literally
+	SecretRefresh       string `json:"secret_refresh"`
+	SlackWebhook        string `json:"slack_webhook,omitempty"`          // Redacted when set
+	SlackWebhookSource  string `json:"slack_webhook_source,omitempty"`   // Reference the webhook URL is loaded from, if not given literally
+	SlackBotToken       string `json:"slack_bot_token,omitempty"`        // Redacted when set
+	SlackBotTokenSource string `json:"slack_bot_token_source,omitempty"` // Reference the bot token is loaded from, if not given literally
+	MaxPreScaleDuration string `json:"max_prescale_duration"`
+	OperationJournal    string `json:"operation_journal,omitempty"`
+	StateStore          string `json:"state_store,omitempty"`
+	RequireApproval     bool   `json:"require_approval"`
+	ApprovalStore       string `json:"approval_store,omitempty"`
+	CycleDeadline       string `json:"cycle_deadline"`
+	StableCycles        int    `json:"stable_cycles"`
+
+	DatadogAPIKey       string   `json:"datadog_api_key,omitempty"`        // Redacted when set
+	DatadogAPIKeySource string   `json:"datadog_api_key_source,omitempty"` // Reference the key is loaded from, if not given literally
+	DatadogSite         string   `json:"datadog_site,omitempty"`
+	DatadogTags         []string `json:"datadog_tags,omitempty"`
+
+	PagerDutyRoutingKey       string `json:"pagerduty_routing_key,omitempty"`        // Redacted when set
+	PagerDutyRoutingKeySource string `json:"pagerduty_routing_key_source,omitempty"` // Reference the key is loaded from, if not given literally
+	OverloadCycles            int    `json:"overload_cycles"`
+
+	PubSubTopic string `json:"pubsub_topic,omitempty"`
+
+	IssueTracker            string `json:"issue_tracker,omitempty"`
+	IssueTrackerToken       string `json:"issue_tracker_token,omitempty"`        // Redacted when set
+	IssueTrackerTokenSource string `json:"issue_tracker_token_source,omitempty"` // Reference the token is loaded from, if not given literally
+	IssueAfter              string `json:"issue_after,omitempty"`
+
+	WebhookURL       string   `json:"webhook_url,omitempty"`        // Redacted when set
+	WebhookURLSource string   `json:"webhook_url_source,omitempty"` // Reference the URL is loaded from, if not given literally
+	WebhookHeaders   []string `json:"webhook_headers,omitempty"`    // Names only, values redacted
+	WebhookTemplate  string   `json:"webhook_template,omitempty"`
+
+	EventFormat string `json:"event_format,omitempty"`
+}
+
+// newConfigView converts the effective configuration into its API
+// representation, redacting secrets
+func newConfigView(cfg *config.Config, daemonCfg DaemonConfig) ConfigView {
+	view := newAnalysisConfigView(cfg)
+	view.Profile = daemonCfg.Profile
+	view.ConfigFile = daemonCfg.ConfigFile
+
+	view.Daemon = &DaemonConfigView{
+		Interval:            daemonCfg.Interval.String(),
+		HTTPPort:            daemonCfg.HTTPPort,
+		EnableMetrics:       daemonCfg.EnableMetrics,
+		MaxPreScaleDuration: daemonCfg.MaxPreScaleDuration.String(),
+		OperationJournal:    daemonCfg.OperationJournal,
+		StateStore:          daemonCfg.StateStore,
+		RequireApproval:     daemonCfg.RequireApproval,
+		ApprovalStore:       daemonCfg.ApprovalStore,
+		CycleDeadline:       daemonCfg.CycleDeadline.String(),
+		StableCycles:        daemonCfg.StableCycles,
+		SecretRefresh:       daemonCfg.SecretRefresh.String(),
+		OverloadCycles:      daemonCfg.OverloadCycles,
+		PubSubTopic:         daemonCfg.PubSubTopic,
+		EventFormat:         daemonCfg.EventFormat,
+	}
+	if daemonCfg.APIToken != "" {
+		view.Daemon.APIToken = redacted
+	}
+	if daemonCfg.SlackWebhook != "" {
+		view.Daemon.SlackWebhook = redacted
+	}
+	if daemonCfg.SlackBotToken != "" {
+		view.Daemon.SlackBotToken = redacted
+	}
+	if daemonCfg.DatadogAPIKey != "" {
+		view.Daemon.DatadogAPIKey = redacted
+		view.Daemon.DatadogSite = daemonCfg.DatadogSite
+		view.Daemon.DatadogTags = daemonCfg.DatadogTags
+	}
+	if daemonCfg.PagerDutyRoutingKey != "" {
+		view.Daemon.PagerDutyRoutingKey = redacted
+	}
+	if daemonCfg.IssueTracker != "" {
+		view.Daemon.IssueTracker = daemonCfg.IssueTracker
+		view.Daemon.IssueAfter = daemonCfg.IssueAfter.String()
+	}
+	if daemonCfg.IssueTrackerToken != "" {
+		view.Daemon.IssueTrackerToken = redacted
+	}
+	if daemonCfg.WebhookURL != "" {
+		view.Daemon.WebhookURL = redacted
+		view.Daemon.WebhookTemplate = daemonCfg.WebhookTemplate
+		for _, header := range daemonCfg.WebhookHeaders {
+			name, _, _ := strings.Cut(header, ":")
+			view.Daemon.WebhookHeaders = append(view.Daemon.WebhookHeaders, strings.TrimSpace(name)+": "+redacted)
+		}
+	}
+	return view
+}
+
+// newAnalysisConfigView converts the settings analysis and planning use
+func newAnalysisConfigView(cfg *config.Config) ConfigView {
+	view := ConfigView{
+		ProjectID: cfg.ProjectID,
+		DryRun:    cfg.DryRun,
+		ReadOnly:  cfg.ReadOnly,
+		Force:     cfg.Force,
+
+		IdempotencyWindow: cfg.IdempotencyWindow.String(),
+
+		MetricsSource:   redactURL(cfg.MetricsSource),
+		MetricsPeriod:   cfg.MetricsPeriod.String(),
+		MetricsInterval: cfg.MetricsInterval.String(),
+
+		CPUTargetUtilization:    cfg.CPUTargetUtilization,
+		MemoryTargetUtilization: cfg.MemoryTargetUtilization,
+		ScaleUpThreshold:        cfg.ScaleUpThreshold,
+		ScaleDownThreshold:      cfg.ScaleDownThreshold,
+		MinStableDuration:       cfg.MinStableDuration.String(),
+		CoolDownPeriod:          cfg.CoolDownPeriod.String(),
+		ScaleUpRule:             cfg.ScaleUpRule,
+		ScaleDownRule:           cfg.ScaleDownRule,
+
+		DeniedMachineTypes:  cfg.DeniedMachineTypes,
+		AllowedMachineTypes: cfg.AllowedMachineTypes,
+
+		TrendWindow:          cfg.TrendWindow.String(),
+		CPUTrendThreshold:    cfg.CPUTrendThreshold,
+		MemoryTrendThreshold: cfg.MemoryTrendThreshold,
+
+		Predictive:      cfg.Predictive,
+		ForecastHorizon: cfg.ForecastHorizon.String(),
+		ColdStart:       cfg.ColdStart,
+		ColdStartWindow: cfg.ColdStartWindow.String(),
+		FleetSignals:    cfg.FleetSignals,
+		CatalogPricing:  cfg.CatalogPricing,
+
+		DataCacheHitRatioThreshold: cfg.DataCacheHitRatioThreshold,
+		MemoryPressureModes:        cfg.MemoryPressureModes,
+		SQLServerScaleUpThreshold:  cfg.SQLServerScaleUpThreshold,
+		ReplicaPolicy:              cfg.ReplicaPolicy,
+		ConnectionCapacityPolicy:   cfg.ConnectionCapacityPolicy,
+		PerConnectionMemoryMB:      cfg.PerConnectionMemoryMB,
+
+		RevertDeadline:    cfg.RevertDeadline.String(),
+		RevertQuietPeriod: cfg.RevertQuietPeriod.String(),
+		RevertApply:       cfg.RevertApply,
+
+		RollbackWindow:    cfg.RollbackWindow.String(),
+		RollbackThreshold: cfg.RollbackThreshold,
+
+		StorageScaling:           cfg.StorageScaling,
+		StorageScaleUpThreshold:  cfg.StorageScaleUpThreshold,
+		StorageTargetUtilization: cfg.StorageTargetUtilization,
+		StorageMinIncreaseGB:     cfg.StorageMinIncreaseGB,
+		MaxDiskSizeGB:            cfg.MaxDiskSizeGB,
+
+		DiskShrinkThreshold: cfg.DiskShrinkThreshold,
+		DiskShrinkHorizon:   cfg.DiskShrinkHorizon.String(),
+
+		IOBoundThreshold: cfg.IOBoundThreshold,
+
+		ReplicaScaling:            cfg.ReplicaScaling,
+		ReplicaScaleUpThreshold:   cfg.ReplicaScaleUpThreshold,
+		ReplicaScaleDownThreshold: cfg.ReplicaScaleDownThreshold,
+		MaxReplicaLag:             cfg.MaxReplicaLag.String(),
+		MinReadReplicas:           cfg.MinReadReplicas,
+		MaxReadReplicas:           cfg.MaxReadReplicas,
+
+		MonitoringQuotaPerMinute: cfg.MonitoringQuotaPerMinute,
+		AnalysisSpreadWindow:     cfg.AnalysisSpreadWindow.String(),
+		AnalysisConcurrency:      cfg.AnalysisConcurrency,
+		AnalysisLatencyBudget:    cfg.AnalysisLatencyBudget.String(),
+		InstanceAnalysisTimeout:  cfg.InstanceAnalysisTimeout.String(),
+		MetricsHedgeDelay:        cfg.MetricsHedgeDelay.String(),
+		SampleFraction:           cfg.SampleFraction,
+		SampleStrategy:           cfg.SampleStrategy,
+		SampleMaxAge:             cfg.SampleMaxAge.String(),
+
+		ProbeEnabled:  cfg.ProbeEnabled,
+		ProbeIPType:   cfg.ProbeIPType,
+		ProbePort:     cfg.ProbePort,
+		ProbeTimeout:  cfg.ProbeTimeout.String(),
+		ProbeInterval: cfg.ProbeInterval.String(),
+
+		CycleCostIncreaseCap:     cfg.CycleCostIncreaseCap,
+		MaxOperationsPerCycle:    cfg.MaxOperationsPerCycle,
+		BundleDowntimeOperations: cfg.BundleDowntimeOperations,
+		MaintenanceWindows:       cfg.MaintenanceWindows,
+		Currency:                 cfg.Currency.Code,
+		CurrencyPerUSD:           cfg.Currency.PerUSD,
+		CurrencyLocale:           cfg.Currency.Locale,
+		Commitment:               cfg.Commitment.String(),
+		Timezone:                 config.DisplayLocation().String(),
+
+		BlackoutWindows:          append([]config.TimeWindow{}, cfg.BlackoutWindows...),
+		Freezes:                  append([]config.Freeze{}, cfg.Freezes...),
+		FreezeEmergencyThreshold: cfg.FreezeEmergencyThreshold,
+		ScheduleLead:             cfg.ScheduleLead.String(),
+		Owners:                   cfg.Owners,
+	}
+	if cfg.Redaction.Enabled() {
+		view.Redact = cfg.Redaction.Fields()
+		view.RedactMode = string(cfg.Redaction.Mode)
+		if cfg.Redaction.Salt != "" {
+			view.RedactSalt = redacted
+		}
+	}
+	if cfg.DecisionHookURL != "" {
+		view.DecisionHookURL = redacted
+		view.DecisionHookTimeout = cfg.DecisionHookTimeout.String()
+		view.DecisionHookFailOpen = cfg.DecisionHookFailOpen
+		if cfg.DecisionHookSecret != "" {
+			view.DecisionHookSecret = redacted
+		}
+	}
+	for _, s := range cfg.Schedules {
+		view.Schedules = append(view.Schedules, ScheduleView{
+			Instance: s.Instance, LabelKey: s.LabelKey, LabelValue: s.LabelValue, Cron: s.Cron,
+			Duration: s.Duration.String(), MachineType: s.MachineType, Profile: s.Profile, Reason: s.Reason,
+		})
+	}
+	for _, o := range cfg.InstanceOverrides {
+		if o.Config == nil {
+			continue
+		}
+		view.InstanceOverrides = append(view.InstanceOverrides, InstanceOverrideView{
+			Instance: o.Instance, Match: o.Match, Profile: o.Profile,
+			CPUTargetUtilization: o.Config.CPUTargetUtilization, MemoryTargetUtilization: o.Config.MemoryTargetUtilization,
+			ScaleUpThreshold: o.Config.ScaleUpThreshold, ScaleDownThreshold: o.Config.ScaleDownThreshold,
+			MinStableDuration: o.Config.MinStableDuration.String(), CoolDownPeriod: o.Config.CoolDownPeriod.String(),
+		})
+	}
+	if cfg.DowntimeBudget > 0 {
+		view.DowntimeBudget = cfg.DowntimeBudget.String()
+	}
+	if cfg.MaintenanceNight != nil {
+		view.MaintenanceNight = cfg.MaintenanceNight.String()
+	}
+	if cfg.BusinessHours != nil {
+		view.BusinessHours = cfg.BusinessHours.String()
+	}
+	if cfg.Shadow != nil {
+		shadow := newAnalysisConfigView(cfg.Shadow)
+		view.Shadow = &shadow
+	}
+	return view
+}
+
+// redactURL masks any password in a URL-valued setting
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	return u.Redacted()
+}
@@ -2,12 +2,28 @@ package daemon
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"os"
+	"strings"
 	"sync"
+	"text/template"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+
 	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/analyzer"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/approval"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/audit"
 	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/datadog"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/decisionhook"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/issues"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/notify"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/redact"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/secrets"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/state"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/version"
 )
 
 // Daemon represents the continuous autoscaler daemon
@@ -15,8 +31,32 @@ import (
 type Daemon struct {
 	config        Config
 	runner        CycleRunner
+	analyzer      *analyzer.ProjectAnalyzer // Runs the startup preflight
 	httpServer    HTTPServerInterface
 	signalHandler SignalHandler
+	preScaler     *preScaler
+	freezer       *freezer
+	approvals     *approval.FileStore // Nil when approvals are not required
+	events        *eventBroker
+	apiToken      *secrets.Secret
+	slackWebhook  *secrets.Secret // Empty literal when Slack notifications are off
+	slackBotToken *secrets.Secret // Empty literal when Slack messages are not routed by owner channel
+	datadogAPIKey *secrets.Secret // Empty literal when Datadog is off
+	datadog       *datadog.Exporter
+	pagerDutyKey  *secrets.Secret   // Empty literal when PagerDuty is off
+	pubsub        *pubsubPublisher  // Nil when Pub/Sub publishing is off
+	issueToken    *secrets.Secret   // Empty literal when issue filing is off
+	webhook       []*secrets.Secret // Webhook URL and header values; empty when the webhook is off
+	hookSecrets   []*secrets.Secret // Decision hook URL and key; empty when the hook is off
+	secretRefresh time.Duration
+	cycleDeadline time.Duration   // Longest a cycle may run before the watchdog aborts it; zero disables
+	abandoned     *abandonedCycle // Cycle the watchdog abandoned, until it returns; only the autoscaling loop uses it
+	effective     ConfigView      // Resolved configuration, secrets redacted
+	build         version.Info
+
+	mu          sync.Mutex
+	lastTimeout *CycleTimeout
+	lastUsage   *CycleUsage
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -28,6 +68,54 @@ type DaemonConfig struct {
 	Interval      time.Duration // How often to run autoscaling checks
 	HTTPPort      int           // Port for health checks and metrics
 	EnableMetrics bool          // Whether to enable Prometheus metrics
+
+	APIToken            string        // Bearer token for mutating API endpoints, or a secrets reference to it; empty disables them
+	MaxPreScaleDuration time.Duration // Longest pre-scale an external system may request
+
+	SecretRefresh time.Duration // How often file and Secret Manager secrets are re-read; zero disables
+
+	SlackWebhook  string // Slack incoming webhook URL, or a secrets reference to it; empty disables Slack notifications
+	SlackBotToken string // Slack bot token, or a secrets reference to it, changes are posted to their owner's channel with; empty posts everything to SlackWebhook
+
+	DatadogAPIKey string   // Datadog API key, or a secrets reference to it; empty disables Datadog metrics and events
+	DatadogSite   string   // Datadog site, e.g. datadoghq.eu; empty is datadog.DefaultSite
+	DatadogTags   []string // Tags added to every Datadog metric and event
+
+	PagerDutyRoutingKey string // PagerDuty Events API v2 integration key, or a secrets reference to it; empty disables paging
+	OverloadCycles      int    // Consecutive cycles above the scale-up thresholds before an instance is notified as overloaded; zero disables
+	StableCycles        int    // Consecutive cycles a recommendation must be made before it is applied; zero or one applies it at once
+
+	PubSubTopic string // Pub/Sub topic ID or projects/P/topics/T the analysis and scaling events are published to; empty disables
+
+	IssueTracker      string        // github:OWNER/REPO or jira:URL/PROJECT standing scale-down recommendations are filed in; empty disables
+	IssueTrackerToken string        // Token for the issue tracker, or a secrets reference to it
+	IssueAfter        time.Duration // How long a scale-down recommendation stands unapplied before it is filed
+
+	WebhookURL      string   // URL notifications are posted to, or a secrets reference to it; empty disables the webhook
+	WebhookHeaders  []string // NAME: VALUE headers sent to the webhook; values may be secrets references
+	WebhookTemplate string   // File with the Go template rendering webhook bodies; empty sends notify.WebhookPayload as JSON
+
+	EventFormat string // EventFormatNative or EventFormatCloudEvents, how Pub/Sub messages and webhook bodies are encoded; empty is native
+
+	OperationJournal string // File persisting in-flight operations across restarts; empty disables
+	StateStore       string // File, gs://BUCKET/OBJECT or firestore://COLLECTION/DOCUMENT persisting last-scaled times, pre-scales and API freezes across restarts; empty disables
+
+	RequireApproval bool   // Apply scaling operations only once approved through the API
+	ApprovalStore   string // File approval requests are kept in; required with RequireApproval
+
+	CycleDeadline time.Duration // Longest a cycle may run before it is aborted; zero disables the watchdog
+
+	Profile    string // Scaling profile the configuration was built from, for reporting
+	ConfigFile string // Configuration file the configuration was loaded from, for reporting
+
+	Build version.Info // Build served at /api/v1/version; zero uses version.Get
+
+	Actor *audit.Actor // Who the daemon applies changes as, recorded in audit records and instance labels; nil records none
+
+	// SQLAdmin and Metrics replace the Google API clients, e.g. with the
+	// sandbox's fake project. Both must be set to take effect.
+	SQLAdmin analyzer.SQLAdmin
+	Metrics  analyzer.MetricsSource
 }
 
 // NewDaemon creates a new daemon instance with improved composition
@@ -36,53 +124,343 @@ func NewDaemon(cfg *config.Config, daemonCfg *DaemonConfig) (*Daemon, error) {
 	if err := validateConfig(cfg, daemonCfg.Interval, daemonCfg.HTTPPort); err != nil {
 		return nil, err
 	}
+	if daemonCfg.CycleDeadline < 0 {
+		return nil, NewDaemonError("validate", "config", fmt.Errorf("%w: negative cycle deadline", ErrInvalidConfig))
+	}
+	if daemonCfg.RequireApproval && daemonCfg.ApprovalStore == "" {
+		return nil, NewDaemonError("validate", "config", fmt.Errorf("%w: requiring approval needs an approval store", ErrInvalidConfig))
+	}
+	if daemonCfg.RequireApproval && daemonCfg.APIToken == "" {
+		return nil, NewDaemonError("validate", "config", fmt.Errorf("%w: requiring approval needs an API token to approve through", ErrInvalidConfig))
+	}
+	switch daemonCfg.EventFormat {
+	case "", EventFormatNative, EventFormatCloudEvents:
+	default:
+		return nil, NewDaemonError("validate", "config", fmt.Errorf("%w: unknown event format %q (must be native or cloudevents)", ErrInvalidConfig, daemonCfg.EventFormat))
+	}
+	if daemonCfg.EventFormat == EventFormatCloudEvents && daemonCfg.WebhookTemplate != "" {
+		return nil, NewDaemonError("validate", "config", fmt.Errorf("%w: a webhook template renders its own body and cannot be sent as a CloudEvent", ErrInvalidConfig))
+	}
+	if daemonCfg.RequireApproval && cfg.ReadOnly {
+		return nil, NewDaemonError("validate", "config", fmt.Errorf("%w: approved operations cannot be applied in read-only mode", ErrInvalidConfig))
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
+	// Credentials may be references to files or Secret Manager
+	apiToken, err := secrets.Resolve(ctx, daemonCfg.APIToken)
+	if err != nil {
+		cancel()
+		return nil, NewDaemonError("resolve_secret", "api_token", err)
+	}
+	slackWebhook, err := secrets.Resolve(ctx, daemonCfg.SlackWebhook)
+	if err != nil {
+		cancel()
+		return nil, NewDaemonError("resolve_secret", "slack_webhook", err)
+	}
+	slackBotToken, err := secrets.Resolve(ctx, daemonCfg.SlackBotToken)
+	if err != nil {
+		cancel()
+		return nil, NewDaemonError("resolve_secret", "slack_bot_token", err)
+	}
+	datadogAPIKey, err := secrets.Resolve(ctx, daemonCfg.DatadogAPIKey)
+	if err != nil {
+		cancel()
+		return nil, NewDaemonError("resolve_secret", "datadog_api_key", err)
+	}
+	pagerDutyKey, err := secrets.Resolve(ctx, daemonCfg.PagerDutyRoutingKey)
+	if err != nil {
+		cancel()
+		return nil, NewDaemonError("resolve_secret", "pagerduty_routing_key", err)
+	}
+	issueToken, err := secrets.Resolve(ctx, daemonCfg.IssueTrackerToken)
+	if err != nil {
+		cancel()
+		return nil, NewDaemonError("resolve_secret", "issue_tracker_token", err)
+	}
+	var webhook *notify.Webhook
+	var webhookSecrets []*secrets.Secret
+	if daemonCfg.WebhookURL != "" {
+		if webhook, webhookSecrets, err = newWebhook(ctx, daemonCfg, cfg.Currency); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+
+	// Proposed resizes reviewed by an external endpoint
+	var hook *decisionhook.Client
+	var hookSecrets []*secrets.Secret
+	if cfg.DecisionHookURL != "" {
+		if hook, err = decisionhook.Open(ctx, cfg.DecisionHookURL, cfg.DecisionHookSecret, cfg.DecisionHookTimeout); err != nil {
+			cancel()
+			return nil, NewDaemonError("resolve_secret", "decision_hook", err)
+		}
+		hookSecrets = hook.Secrets()
+	}
+
 	// Create analyzer - keeping this concrete type as it's the main dependency
-	projectAnalyzer, err := analyzer.NewProjectAnalyzer(ctx, cfg)
+	projectAnalyzer, err := newProjectAnalyzer(ctx, cfg, daemonCfg, hook)
 	if err != nil {
 		cancel()
 		return nil, NewDaemonError("create_analyzer", "startup", err)
 	}
+	if daemonCfg.OperationJournal != "" {
+		projectAnalyzer.SetOperationJournal(analyzer.NewFileJournal(daemonCfg.OperationJournal))
+	}
+	var store *state.Store
+	if daemonCfg.StateStore != "" {
+		if store, err = state.Open(ctx, daemonCfg.StateStore, cfg.ProjectID); err != nil {
+			cancel()
+			return nil, NewDaemonError("open_state_store", "state_store", err)
+		}
+		projectAnalyzer.SetStateStore(store)
+	}
 
 	// Create configuration wrapper
-	daemonConfig := NewDaemonConfig(cfg, daemonCfg.Interval, daemonCfg.HTTPPort, daemonCfg.EnableMetrics)
+	daemonConfig := NewDaemonConfig(cfg, daemonCfg.Interval, daemonCfg.HTTPPort, daemonCfg.EnableMetrics, daemonCfg.OverloadCycles, daemonCfg.StableCycles)
 
 	// Create metrics reporter based on configuration
 	var metricsReporter MetricsReporter
-	if daemonCfg.EnableMetrics {
+	if daemonCfg.EnableMetrics || daemonCfg.DatadogAPIKey != "" {
 		metricsReporter = NewPrometheusMetricsReporter()
 	} else {
 		metricsReporter = NewSimpleMetricsReporter()
 	}
 
+	// Events streamed to /api/v1/events subscribers and published to Pub/Sub
+	events := newEventBroker()
+	var publisher *pubsubPublisher
+	if daemonCfg.PubSubTopic != "" {
+		if publisher, err = newPubSubPublisher(ctx, daemonCfg.PubSubTopic, cfg.ProjectID, daemonCfg.EventFormat == EventFormatCloudEvents, events); err != nil {
+			cancel()
+			return nil, NewDaemonError("create_pubsub_publisher", "pubsub_topic", err)
+		}
+	}
+
+	// Freezes configured at startup; more can be set through the API
+	freezer := newFreezer(cfg.Freezes)
+	if store != nil {
+		if err := freezer.restore(ctx, store, time.Now()); err != nil {
+			cancel()
+			return nil, NewDaemonError("restore_freezes", "state_store", err)
+		}
+	}
+
+	// Pre-scale requests pin instances outside of regular autoscaling
+	preScaler := newPreScaler(projectAnalyzer, cfg.ProjectID, cfg.Force, cfg.DryRun, cfg.ReadOnly, daemonCfg.MaxPreScaleDuration, freezer, events)
+	if store != nil {
+		if err := preScaler.restore(ctx, store); err != nil {
+			cancel()
+			return nil, NewDaemonError("restore_prescales", "state_store", err)
+		}
+	}
+
+	// Recommendations, applied changes and failures posted to Slack, Datadog
+	// and the webhook; failures and overloads paged through PagerDuty
+	var notifiers notify.Multi
+	if daemonCfg.SlackWebhook != "" || daemonCfg.SlackBotToken != "" {
+		notifiers = append(notifiers, notify.NewSlack(slackWebhook, slackBotToken, cfg.Currency))
+	}
+	var datadogExporter *datadog.Exporter
+	if daemonCfg.DatadogAPIKey != "" {
+		client := datadog.NewClient(datadogAPIKey, daemonCfg.DatadogSite, daemonCfg.DatadogTags)
+		notifiers = append(notifiers, datadog.NewEvents(client, cfg.Currency))
+		datadogExporter = datadog.NewExporter(client, prometheus.DefaultGatherer)
+	}
+	if daemonCfg.PagerDutyRoutingKey != "" {
+		notifiers = append(notifiers, notify.NewPagerDuty(pagerDutyKey))
+	}
+	if webhook != nil {
+		notifiers = append(notifiers, webhook)
+	}
+	var runner CycleRunner
+	// Mask every fleet name, not just the one an event or change concerns,
+	// wherever a message mentions it
+	fleetNames := func() []string {
+		if results := runner.LastResults(); results != nil {
+			return results.InstanceNames()
+		}
+		return nil
+	}
+	var notifier notify.Notifier
+	if len(notifiers) > 0 {
+		notifier = notifiers
+		if cfg.Redaction.Enabled() {
+			notifier = redact.Notifier(notifier, redact.New(cfg.Redaction, cfg.Currency), fleetNames)
+		}
+	}
+	if publisher != nil && cfg.Redaction.Enabled() {
+		publisher.setRedactor(redact.New(cfg.Redaction, cfg.Currency), fleetNames)
+	}
+
+	// Scale-down recommendations left standing filed as tickets
+	var filer IssueFiler
+	if daemonCfg.IssueTracker != "" {
+		tracker, err := issues.ParseTracker(daemonCfg.IssueTracker, issueToken)
+		if err != nil {
+			cancel()
+			return nil, NewDaemonError("create_issue_tracker", "issue_tracker", err)
+		}
+		issueFiler := newIssueFiler(tracker, cfg.ProjectID, daemonCfg.IssueAfter, cfg.Currency)
+		if store != nil {
+			if err := issueFiler.restore(ctx, store); err != nil {
+				cancel()
+				return nil, NewDaemonError("restore_standing_recommendations", "state_store", err)
+			}
+		}
+		filer = issueFiler
+	}
+
+	// Planned operations held for an operator's approval
+	var approvals *approval.FileStore
+	var approvalStore ApprovalStore
+	if daemonCfg.RequireApproval {
+		approvals = approval.NewFileStore(daemonCfg.ApprovalStore)
+		approvalStore = approvals
+	}
+
 	// Create cycle runner with dependencies injected
-	runner := NewAutoscalingRunner(projectAnalyzer, daemonConfig, metricsReporter)
+	runner = NewAutoscalingRunner(projectAnalyzer, daemonConfig, metricsReporter, preScaler, freezer, events, notifier, filer, approvalStore)
+	events.instanceIDs = func(name string) string {
+		if results := runner.LastResults(); results != nil {
+			return results.InstanceID(name)
+		}
+		return ""
+	}
 
 	// Create HTTP server for health checks and metrics
 	httpServer := &HTTPServer{
-		port:   daemonCfg.HTTPPort,
-		daemon: nil, // Will be set after daemon creation
+		port:     daemonCfg.HTTPPort,
+		apiToken: apiToken,
+		daemon:   nil, // Will be set after daemon creation
 	}
 
 	// Create signal handler
 	signalHandler := NewOSSignalHandler()
 
-	return &Daemon{
+	d := &Daemon{
 		config:        daemonConfig,
 		runner:        runner,
+		analyzer:      projectAnalyzer,
 		httpServer:    httpServer,
 		signalHandler: signalHandler,
+		preScaler:     preScaler,
+		freezer:       freezer,
+		approvals:     approvals,
+		events:        events,
+		apiToken:      apiToken,
+		slackWebhook:  slackWebhook,
+		slackBotToken: slackBotToken,
+		datadogAPIKey: datadogAPIKey,
+		datadog:       datadogExporter,
+		pagerDutyKey:  pagerDutyKey,
+		pubsub:        publisher,
+		issueToken:    issueToken,
+		webhook:       webhookSecrets,
+		hookSecrets:   hookSecrets,
+		secretRefresh: daemonCfg.SecretRefresh,
+		cycleDeadline: daemonCfg.CycleDeadline,
+		effective:     newConfigView(cfg, *daemonCfg),
+		build:         daemonCfg.Build,
 		ctx:           ctx,
 		cancel:        cancel,
-	}, nil
+	}
+	httpServer.daemon = d
+	if apiToken.Kind() != secrets.KindLiteral {
+		d.effective.Daemon.APITokenSource = apiToken.String()
+	}
+	if slackWebhook.Kind() != secrets.KindLiteral {
+		d.effective.Daemon.SlackWebhookSource = slackWebhook.String()
+	}
+	if slackBotToken.Kind() != secrets.KindLiteral {
+		d.effective.Daemon.SlackBotTokenSource = slackBotToken.String()
+	}
+	if datadogAPIKey.Kind() != secrets.KindLiteral {
+		d.effective.Daemon.DatadogAPIKeySource = datadogAPIKey.String()
+	}
+	if pagerDutyKey.Kind() != secrets.KindLiteral {
+		d.effective.Daemon.PagerDutyRoutingKeySource = pagerDutyKey.String()
+	}
+	if issueToken.Kind() != secrets.KindLiteral {
+		d.effective.Daemon.IssueTrackerTokenSource = issueToken.String()
+	}
+	if len(webhookSecrets) > 0 && webhookSecrets[0].Kind() != secrets.KindLiteral {
+		d.effective.Daemon.WebhookURLSource = webhookSecrets[0].String()
+	}
+	if d.build.Version == "" {
+		d.build = version.Get()
+	}
+	RecordBuildInfo(d.build)
+
+	return d, nil
+}
+
+// newWebhook creates the notifier posting to daemonCfg's webhook, resolving
+// its URL and header values, and returns it with the secrets it resolved
+func newWebhook(ctx context.Context, daemonCfg *DaemonConfig, currency config.Currency) (*notify.Webhook, []*secrets.Secret, error) {
+	url, err := secrets.Resolve(ctx, daemonCfg.WebhookURL)
+	if err != nil {
+		return nil, nil, NewDaemonError("resolve_secret", "webhook_url", err)
+	}
+	resolved := []*secrets.Secret{url}
+	headers := make(map[string]*secrets.Secret, len(daemonCfg.WebhookHeaders))
+	for _, header := range daemonCfg.WebhookHeaders {
+		name, value, err := notify.ParseWebhookHeader(header)
+		if err != nil {
+			return nil, nil, NewDaemonError("validate", "webhook_header", err)
+		}
+		secret, err := secrets.Resolve(ctx, value)
+		if err != nil {
+			return nil, nil, NewDaemonError("resolve_secret", "webhook_header", fmt.Errorf("%s: %w", name, err))
+		}
+		headers[name] = secret
+		resolved = append(resolved, secret)
+	}
+	var body *template.Template
+	if daemonCfg.WebhookTemplate != "" {
+		if body, err = notify.ParseWebhookTemplate(daemonCfg.WebhookTemplate, currency); err != nil {
+			return nil, nil, NewDaemonError("validate", "webhook_template", err)
+		}
+	}
+	webhook := notify.NewWebhook(url, headers, body)
+	webhook.SetCloudEvents(daemonCfg.EventFormat == EventFormatCloudEvents)
+	return webhook, resolved, nil
+}
+
+// newProjectAnalyzer creates the daemon's analyzer, on the injected clients
+// when daemonCfg has them, reviewing proposed resizes with hook if it is set
+func newProjectAnalyzer(ctx context.Context, cfg *config.Config, daemonCfg *DaemonConfig, hook *decisionhook.Client) (*analyzer.ProjectAnalyzer, error) {
+	opts := analyzer.Options{
+		Config:      cfg,
+		Progress:    os.Stdout,
+		AuditLogger: audit.DefaultLogger(),
+		Actor:       daemonCfg.Actor,
+	}
+	if daemonCfg.SQLAdmin != nil && daemonCfg.Metrics != nil {
+		opts.SQLAdmin = daemonCfg.SQLAdmin
+		opts.Metrics = daemonCfg.Metrics
+	}
+	if hook != nil {
+		opts.DecisionHook = hook
+	}
+	return analyzer.NewProject(ctx, opts)
 }
 
 // Start begins the daemon operation using improved composition
 func (d *Daemon) Start() error {
-	log.Printf("Starting CloudSQL Autoscaler daemon (interval: %v, project: %s)",
-		d.config.GetInterval(), d.config.GetProjectID())
+	log.Printf("Starting CloudSQL Autoscaler daemon %s (commit: %s, interval: %v, project: %s)",
+		d.build.Version, d.build.ShortCommit(), d.config.GetInterval(), d.config.GetProjectID())
+
+	// Report what the first cycle will run into; failures are logged, not fatal
+	if report, err := d.Preflight(d.ctx); err != nil {
+		log.Printf("Preflight failed to run: %v", err)
+	} else {
+		var text strings.Builder
+		report.Print(&text)
+		for _, line := range strings.Split(strings.TrimSuffix(text.String(), "\n"), "\n") {
+			log.Print(line)
+		}
+	}
 
 	// Start HTTP server for health checks and metrics
 	if d.config.GetHTTPPort() > 0 {
@@ -94,6 +472,73 @@ func (d *Daemon) Start() error {
 	d.wg.Add(1)
 	go d.autoscalingLoop()
 
+	// Apply and revert pre-scale requests
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.preScaler.run(d.ctx)
+	}()
+
+	// Forward analysis and scaling events to Pub/Sub
+	if d.pubsub != nil {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.pubsub.run(d.ctx)
+		}()
+	}
+
+	// Pick up rotated credentials
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.apiToken.Watch(d.ctx, d.secretRefresh)
+	}()
+	if d.slackWebhook.Kind() != secrets.KindLiteral {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.slackWebhook.Watch(d.ctx, d.secretRefresh)
+		}()
+	}
+	if d.slackBotToken.Kind() != secrets.KindLiteral {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.slackBotToken.Watch(d.ctx, d.secretRefresh)
+		}()
+	}
+	if d.datadogAPIKey.Kind() != secrets.KindLiteral {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.datadogAPIKey.Watch(d.ctx, d.secretRefresh)
+		}()
+	}
+	if d.pagerDutyKey.Kind() != secrets.KindLiteral {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.pagerDutyKey.Watch(d.ctx, d.secretRefresh)
+		}()
+	}
+	if d.issueToken.Kind() != secrets.KindLiteral {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.issueToken.Watch(d.ctx, d.secretRefresh)
+		}()
+	}
+	for _, secret := range append(append([]*secrets.Secret{}, d.webhook...), d.hookSecrets...) {
+		if secret.Kind() != secrets.KindLiteral {
+			d.wg.Add(1)
+			go func() {
+				defer d.wg.Done()
+				secret.Watch(d.ctx, d.secretRefresh)
+			}()
+		}
+	}
+
 	// Wait for shutdown signal
 	<-d.signalHandler.WaitForShutdown()
 
@@ -107,6 +552,12 @@ func (d *Daemon) Start() error {
 	return nil
 }
 
+// Preflight checks the APIs and permissions the daemon depends on and
+// reports the policy of each instance it would analyze, without starting it
+func (d *Daemon) Preflight(ctx context.Context) (*analyzer.PreflightReport, error) {
+	return d.analyzer.Preflight(ctx)
+}
+
 // Stop gracefully stops the daemon
 func (d *Daemon) Stop() {
 	log.Println("Initiating graceful shutdown...")
@@ -121,6 +572,11 @@ func (d *Daemon) autoscalingLoop() {
 	ticker := time.NewTicker(d.config.GetInterval())
 	defer ticker.Stop()
 
+	// Finish operations a previous process left in flight before planning new ones
+	if err := d.runner.ResumeOperations(d.ctx); err != nil {
+		log.Printf("Failed to resume in-flight operations: %v", err)
+	}
+
 	// Run once immediately on startup
 	d.runAutoscalingCycle()
 
@@ -137,7 +593,11 @@ func (d *Daemon) autoscalingLoop() {
 
 // runAutoscalingCycle executes a single autoscaling cycle using the CycleRunner
 func (d *Daemon) runAutoscalingCycle() {
-	if err := d.runner.RunCycle(d.ctx); err != nil {
+	before, previous := takeUsage(), d.runner.LastResults()
+	err := d.runWithWatchdog()
+	d.reportUsage(before, previous)
+	d.exportDatadog()
+	if err != nil {
 		// Log error but continue - following the principle of robustness
 		log.Printf("Autoscaling cycle failed: %v", err)
 		if !IsRecoverable(err) {
@@ -146,6 +606,34 @@ func (d *Daemon) runAutoscalingCycle() {
 	}
 }
 
+// reportUsage logs, records and retains the daemon's resource usage since
+// before, taken when the cycle started. Instances are counted from the
+// cycle's results, unless it failed before replacing previous.
+func (d *Daemon) reportUsage(before usageSnapshot, previous *analyzer.ProjectAnalysisResult) {
+	instances := 0
+	if results := d.runner.LastResults(); results != nil && results != previous {
+		instances = results.AnalyzedInstances
+	}
+	usage := cycleUsage(before, takeUsage(), instances)
+	log.Print(usage)
+	RecordCycleUsage(usage)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lastUsage = &usage
+}
+
+// exportDatadog forwards the cycle's metrics to Datadog, when it is configured
+func (d *Daemon) exportDatadog() {
+	if d.datadog == nil {
+		return
+	}
+	if err := d.datadog.Export(d.ctx, time.Now()); err != nil {
+		log.Printf("Failed to export metrics to Datadog: %v", err)
+		RecordError("datadog_export_failed")
+	}
+}
+
 // startHTTPServer starts the HTTP server for health checks and metrics
 func (d *Daemon) startHTTPServer() {
 	defer d.wg.Done()
@@ -170,6 +658,11 @@ func (d *Daemon) startHTTPServer() {
 
 // GetStatus returns the current daemon status
 func (d *Daemon) GetStatus() *DaemonStatus {
+	d.mu.Lock()
+	lastTimeout := d.lastTimeout
+	lastUsage := d.lastUsage
+	d.mu.Unlock()
+
 	return &DaemonStatus{
 		ProjectID: d.config.GetProjectID(),
 		Interval:  d.config.GetInterval(),
@@ -177,9 +670,30 @@ func (d *Daemon) GetStatus() *DaemonStatus {
 		HTTPPort:  d.config.GetHTTPPort(),
 		Running:   true,
 		StartTime: time.Now(), // This would be set properly in a real implementation
+		Freezes:   d.freezer.Active(time.Now()),
+
+		CycleDeadline:    d.cycleDeadline,
+		LastCycleTimeout: lastTimeout,
+		LastCycleUsage:   lastUsage,
 	}
 }
 
+// EffectiveConfig returns the configuration the daemon runs with, after
+// profiles and flags are resolved, with secrets redacted
+func (d *Daemon) EffectiveConfig() ConfigView {
+	return d.effective
+}
+
+// Build returns the metadata of the running build
+func (d *Daemon) Build() version.Info {
+	return d.build
+}
+
+// LastResults returns the most recent project analysis, or nil before the first cycle
+func (d *Daemon) LastResults() *analyzer.ProjectAnalysisResult {
+	return d.runner.LastResults()
+}
+
 // DaemonStatus represents the current status of the daemon
 type DaemonStatus struct {
 	ProjectID string        `json:"project_id"`
@@ -190,4 +704,10 @@ type DaemonStatus struct {
 	StartTime time.Time     `json:"start_time"`
 	LastCycle time.Time     `json:"last_cycle,omitempty"`
 	NextCycle time.Time     `json:"next_cycle,omitempty"`
+
+	Freezes []config.Freeze `json:"freezes"` // Scaling freezes in effect
+
+	CycleDeadline    time.Duration `json:"cycle_deadline,omitempty"`     // Zero when the watchdog is disabled
+	LastCycleTimeout *CycleTimeout `json:"last_cycle_timeout,omitempty"` // Most recent cycle the watchdog aborted
+	LastCycleUsage   *CycleUsage   `json:"last_cycle_usage,omitempty"`   // The daemon's own resource usage over the last cycle
 }
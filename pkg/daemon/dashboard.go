@@ -0,0 +1,25 @@
+package daemon
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// dashboardPage is a read-only view of the daemon built on the status,
+// recommendations and events endpoints
+//
+//go:embed dashboard.html
+var dashboardPage []byte
+
+// dashboardHandler serves the dashboard
+func (s *HTTPServer) dashboardHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(dashboardPage)
+}
@@ -0,0 +1,151 @@
+package daemon
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType identifies a kind of daemon event
+type EventType string
+
+const (
+	EventCycleStarted    EventType = "cycle_started"    // An autoscaling cycle began
+	EventCycleCompleted  EventType = "cycle_completed"  // An autoscaling cycle finished its analysis and plan
+	EventCycleFailed     EventType = "cycle_failed"     // An autoscaling cycle could not analyze the fleet
+	EventCycleTimedOut   EventType = "cycle_timed_out"  // The watchdog aborted a cycle that overran its deadline
+	EventRecommendation  EventType = "recommendation"   // Analysis recommends scaling an instance
+	EventDeferred        EventType = "deferred"         // A recommended operation was deferred
+	EventPlanned         EventType = "planned"          // A scaling operation is planned for this cycle
+	EventScaled          EventType = "scaled"           // An instance was resized
+	EventScalingFailed   EventType = "scaling_failed"   // Resizing an instance failed
+	EventFreezeSet       EventType = "freeze_set"       // A scaling freeze was set through the API
+	EventFreezeLifted    EventType = "freeze_lifted"    // A scaling freeze was lifted through the API
+	EventPreScaleApplied EventType = "prescale_applied" // A pre-scale resized its instance
+	EventPreScaleEnded   EventType = "prescale_ended"   // A pre-scale was reverted, abandoned or failed
+
+	EventStorageRecommendation EventType = "storage_recommendation" // Analysis recommends growing an instance's disk
+	EventStorageResized        EventType = "storage_resized"        // An instance's disk was grown
+	EventStorageResizeFailed   EventType = "storage_resize_failed"  // Growing an instance's disk failed
+
+	EventReplicaRecommendation EventType = "replica_recommendation" // Analysis recommends adding or removing a read replica
+	EventReplicaCreated        EventType = "replica_created"        // A read replica was added to a primary
+	EventReplicaDeleted        EventType = "replica_deleted"        // A read replica was removed from a primary
+	EventReplicaChangeFailed   EventType = "replica_change_failed"  // Adding or removing a read replica failed
+
+	EventApprovalRequested EventType = "approval_requested" // A planned operation awaits an operator's approval
+	EventApprovalDecided   EventType = "approval_decided"   // An approval request was approved or rejected through the API
+)
+
+// Event is something the daemon did or decided, streamed to subscribers of
+// /api/v1/events. IDs increase by one per event within a daemon process.
+type Event struct {
+	ID         uint64      `json:"id"`
+	Type       EventType   `json:"type"`
+	Time       time.Time   `json:"time"`
+	Instance   string      `json:"instance,omitempty"`
+	InstanceID string      `json:"instance_id,omitempty"` // Fully qualified, once the instance was analyzed
+	Message    string      `json:"message"`
+	Data       interface{} `json:"data,omitempty"`
+}
+
+// EventPublisher receives daemon events
+type EventPublisher interface {
+	Publish(eventType EventType, instance, message string, data interface{})
+}
+
+const (
+	// eventHistorySize is how many recent events are kept for reconnecting
+	// subscribers and long polls
+	eventHistorySize = 500
+	// eventBufferSize is how many events a subscriber may fall behind by
+	// before it is disconnected
+	eventBufferSize = 64
+)
+
+// eventBroker fans events out to subscribers and keeps a short history so
+// subscribers that reconnect with the last ID they saw miss nothing
+type eventBroker struct {
+	mu          sync.Mutex
+	nextID      uint64
+	history     []Event
+	subscribers map[chan Event]struct{}
+	changed     chan struct{}            // Closed and replaced on every publish
+	instanceIDs func(name string) string // Fully qualifies instance names; nil leaves events without IDs
+}
+
+// newEventBroker creates an event broker with no subscribers
+func newEventBroker() *eventBroker {
+	return &eventBroker{
+		nextID:      1,
+		subscribers: make(map[chan Event]struct{}),
+		changed:     make(chan struct{}),
+	}
+}
+
+// Publish records an event and sends it to every subscriber. Subscribers
+// too far behind to take it are disconnected rather than blocking the daemon.
+func (b *eventBroker) Publish(eventType EventType, instance, message string, data interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event := Event{ID: b.nextID, Type: eventType, Time: time.Now().UTC(), Instance: instance, Message: message, Data: data}
+	if instance != "" && b.instanceIDs != nil {
+		event.InstanceID = b.instanceIDs(instance)
+	}
+	b.nextID++
+
+	b.history = append(b.history, event)
+	if len(b.history) > eventHistorySize {
+		b.history = b.history[len(b.history)-eventHistorySize:]
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// Subscribe returns the retained events after lastID and a channel of the
+// events that follow. The channel is closed if the subscriber falls behind;
+// cancel must be called when the subscriber is done.
+func (b *eventBroker) Subscribe(lastID uint64) (backlog []Event, events <-chan Event, cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan Event, eventBufferSize)
+	b.subscribers[ch] = struct{}{}
+	cancel = func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+	return b.since(lastID), ch, cancel
+}
+
+// Since returns the retained events after lastID and a channel that is closed
+// when the next event is published
+func (b *eventBroker) Since(lastID uint64) ([]Event, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.since(lastID), b.changed
+}
+
+// since returns the retained events after lastID; b.mu must be held
+func (b *eventBroker) since(lastID uint64) []Event {
+	for i, event := range b.history {
+		if event.ID > lastID {
+			return append([]Event(nil), b.history[i:]...)
+		}
+	}
+	return nil
+}
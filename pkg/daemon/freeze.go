@@ -0,0 +1,137 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/state"
+)
+
+// ErrFreezeRejected is returned when a freeze request is invalid
+var ErrFreezeRejected = errors.New("freeze request rejected")
+
+// freezesSection is the state store section API freezes are kept in
+const freezesSection = "freezes"
+
+// freezer holds the scaling freezes in effect: those configured at startup
+// and those set through the API. API freezes last until they expire or are
+// lifted; without a state store, a restart lifts them too.
+type freezer struct {
+	static []config.Freeze
+
+	mu      sync.Mutex
+	entries map[string]config.Freeze // By ID
+	store   *state.Store             // Persists entries across restarts; nil keeps them in memory only
+}
+
+// newFreezer creates a freezer seeded with the configured freezes
+func newFreezer(static []config.Freeze) *freezer {
+	return &freezer{
+		static:  static,
+		entries: make(map[string]config.Freeze),
+	}
+}
+
+// restore loads the API freezes kept in store that are still in effect at
+// now, and persists every later change to them there
+func (f *freezer) restore(ctx context.Context, store *state.Store, now time.Time) error {
+	var saved []config.Freeze
+	if _, err := store.Get(ctx, freezesSection, &saved); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.store = store
+	for _, freeze := range saved {
+		if !freeze.Active(now) {
+			continue
+		}
+		f.entries[freeze.ID] = freeze
+		log.Printf("Restored scaling freeze %s (%s) until %s: %s", freeze.ID, freeze.Target(), config.FormatTime(freeze.Until), freeze.Reason)
+	}
+	return nil
+}
+
+// persistLocked writes the API freezes to the state store, if any; f.mu must
+// be held
+func (f *freezer) persistLocked(ctx context.Context) error {
+	if f.store == nil {
+		return nil
+	}
+	list := make([]config.Freeze, 0, len(f.entries))
+	for _, freeze := range f.entries {
+		list = append(list, freeze)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return f.store.Put(ctx, freezesSection, list)
+}
+
+// Add validates f and puts it into effect. A freeze that cannot be persisted
+// is not set, as a restart would silently lift it.
+func (f *freezer) Add(ctx context.Context, freeze config.Freeze, now time.Time) (config.Freeze, error) {
+	if err := freeze.Validate(); err != nil {
+		return config.Freeze{}, fmt.Errorf("%w: %v", ErrFreezeRejected, err)
+	}
+	if !freeze.Active(now) {
+		return config.Freeze{}, fmt.Errorf("%w: until must be in the future", ErrFreezeRejected)
+	}
+
+	freeze.ID = cloudsql.NewDecisionID()
+	freeze.CreatedAt = now
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.entries[freeze.ID] = freeze
+	if err := f.persistLocked(ctx); err != nil {
+		delete(f.entries, freeze.ID)
+		return config.Freeze{}, fmt.Errorf("failed to persist freeze: %w", err)
+	}
+	return freeze, nil
+}
+
+// Lift removes the API freeze with the given ID, reporting whether it existed.
+// Configured freezes cannot be lifted. A failed write is logged: the freeze
+// is lifted, but a restart would put it back into effect.
+func (f *freezer) Lift(ctx context.Context, id string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.entries[id]; !ok {
+		return false
+	}
+	delete(f.entries, id)
+	if err := f.persistLocked(ctx); err != nil {
+		log.Printf("Failed to persist lifting freeze %s: %v", id, err)
+	}
+	return true
+}
+
+// Active returns the freezes in effect at now, soonest expiry first
+func (f *freezer) Active(now time.Time) []config.Freeze {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	active := make([]config.Freeze, 0, len(f.static)+len(f.entries))
+	for _, freeze := range f.static {
+		if freeze.Active(now) {
+			active = append(active, freeze)
+		}
+	}
+	for id, freeze := range f.entries {
+		if !freeze.Active(now) {
+			delete(f.entries, id)
+			continue
+		}
+		active = append(active, freeze)
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].Until.Before(active[j].Until) })
+	return active
+}
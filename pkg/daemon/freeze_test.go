@@ -0,0 +1,189 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/state"
+)
+
+var freezeNow = time.Date(2026, time.November, 27, 9, 0, 0, 0, time.UTC)
+
+// globalFreeze returns a global freeze lasting for d from freezeNow
+func globalFreeze(d time.Duration, reason string) config.Freeze {
+	return config.Freeze{Scope: config.FreezeGlobal, Until: freezeNow.Add(d), Reason: reason}
+}
+
+// readOnlyBackend is a state backend whose writes fail
+type readOnlyBackend struct{}
+
+func (readOnlyBackend) Read(ctx context.Context) ([]byte, error) {
+	return nil, nil
+}
+
+func (readOnlyBackend) Write(ctx context.Context, data []byte) error {
+	return errors.New("permission denied")
+}
+
+func (readOnlyBackend) String() string {
+	return "read-only"
+}
+
+// TestFreezerAdd checks which freezes are accepted and what accepting one
+// records
+func TestFreezerAdd(t *testing.T) {
+	tests := []struct {
+		name    string
+		freeze  config.Freeze
+		wantErr bool
+	}{
+		{name: "global", freeze: globalFreeze(time.Hour, "Black Friday")},
+		{name: "project", freeze: config.Freeze{Scope: config.FreezeProject, Project: "shop", Until: freezeNow.Add(time.Hour), Reason: "release"}},
+		{name: "label", freeze: config.Freeze{Scope: config.FreezeLabel, LabelKey: "team", LabelValue: "payments", Until: freezeNow.Add(time.Hour), Reason: "audit"}},
+		{name: "expired", freeze: globalFreeze(-time.Minute, "too late"), wantErr: true},
+		{name: "expires now", freeze: globalFreeze(0, "too late"), wantErr: true},
+		{name: "no reason", freeze: globalFreeze(time.Hour, ""), wantErr: true},
+		{name: "unknown scope", freeze: config.Freeze{Scope: "region", Until: freezeNow.Add(time.Hour), Reason: "outage"}, wantErr: true},
+		{name: "project without a project", freeze: config.Freeze{Scope: config.FreezeProject, Until: freezeNow.Add(time.Hour), Reason: "release"}, wantErr: true},
+		{name: "label without a value", freeze: config.Freeze{Scope: config.FreezeLabel, LabelKey: "team", Until: freezeNow.Add(time.Hour), Reason: "audit"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFreezer(nil)
+			got, err := f.Add(context.Background(), tt.freeze, freezeNow)
+			if tt.wantErr {
+				if !errors.Is(err, ErrFreezeRejected) {
+					t.Fatalf("Add = %v, want %v", err, ErrFreezeRejected)
+				}
+				if active := f.Active(freezeNow); len(active) != 0 {
+					t.Errorf("rejected freeze is in effect: %+v", active)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ID == "" || !got.CreatedAt.Equal(freezeNow) {
+				t.Errorf("Add = %+v, want an ID and a creation time of %v", got, freezeNow)
+			}
+			if active := f.Active(freezeNow); len(active) != 1 || active[0].ID != got.ID {
+				t.Errorf("Active = %+v, want the added freeze", active)
+			}
+		})
+	}
+}
+
+// TestFreezerActive checks that configured and API freezes are both in
+// effect until they expire, soonest expiry first
+func TestFreezerActive(t *testing.T) {
+	f := newFreezer([]config.Freeze{globalFreeze(3*time.Hour, "configured"), globalFreeze(-time.Hour, "configured, expired")})
+	for _, freeze := range []config.Freeze{globalFreeze(time.Hour, "first"), globalFreeze(2*time.Hour, "second")} {
+		if _, err := f.Add(context.Background(), freeze, freezeNow); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		at   time.Time
+		want []string // Reasons of the freezes in effect, in order
+	}{
+		{freezeNow, []string{"first", "second", "configured"}},
+		{freezeNow.Add(time.Hour), []string{"second", "configured"}},
+		{freezeNow.Add(150 * time.Minute), []string{"configured"}},
+		{freezeNow.Add(3 * time.Hour), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.at.Format(time.Kitchen), func(t *testing.T) {
+			var got []string
+			for _, freeze := range f.Active(tt.at) {
+				got = append(got, freeze.Reason)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Active(%v) = %v, want %v", tt.at, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Active(%v) = %v, want %v", tt.at, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// TestFreezerLift checks that API freezes can be lifted once and configured
+// ones not at all
+func TestFreezerLift(t *testing.T) {
+	f := newFreezer([]config.Freeze{{ID: "configured", Scope: config.FreezeGlobal, Until: freezeNow.Add(time.Hour), Reason: "configured"}})
+	added, err := f.Add(context.Background(), globalFreeze(time.Hour, "api"), freezeNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		id   string
+		want bool
+	}{
+		{"api freeze", added.ID, true},
+		{"lifted again", added.ID, false},
+		{"configured freeze", "configured", false},
+		{"unknown", "no-such-id", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := f.Lift(context.Background(), tt.id); got != tt.want {
+				t.Errorf("Lift(%q) = %v, want %v", tt.id, got, tt.want)
+			}
+		})
+	}
+	if active := f.Active(freezeNow); len(active) != 1 || active[0].ID != "configured" {
+		t.Errorf("Active after lifting = %+v, want only the configured freeze", active)
+	}
+}
+
+// TestFreezerRestore checks that API freezes survive a restart through the
+// state store, except those lifted or expired in the meantime, and that a
+// freeze which cannot be persisted is not set
+func TestFreezerRestore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	before := newFreezer(nil)
+	if err := before.restore(ctx, state.New(state.NewFileBackend(path)), freezeNow); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, freeze := range []config.Freeze{globalFreeze(time.Hour, "short"), globalFreeze(4*time.Hour, "long"), globalFreeze(4*time.Hour, "lifted")} {
+		added, err := before.Add(ctx, freeze, freezeNow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, added.ID)
+	}
+	before.Lift(ctx, ids[2])
+
+	after := newFreezer(nil)
+	if err := after.restore(ctx, state.New(state.NewFileBackend(path)), freezeNow.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if active := after.Active(freezeNow.Add(2 * time.Hour)); len(active) != 1 || active[0].ID != ids[1] {
+		t.Errorf("restored freezes = %+v, want only %s", active, ids[1])
+	}
+
+	t.Run("unpersisted", func(t *testing.T) {
+		f := newFreezer(nil)
+		if err := f.restore(ctx, state.New(readOnlyBackend{}), freezeNow); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Add(ctx, globalFreeze(time.Hour, "api"), freezeNow); err == nil {
+			t.Error("Add succeeded without persisting the freeze, want an error")
+		}
+		if active := f.Active(freezeNow); len(active) != 0 {
+			t.Errorf("unpersisted freeze is in effect: %+v", active)
+		}
+	})
+}
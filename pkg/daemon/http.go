@@ -5,14 +5,22 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/secrets"
 )
 
 // HTTPServer provides health checks and metrics endpoints
 type HTTPServer struct {
-	port   int
-	daemon *Daemon
-	server *http.Server
+	port     int
+	apiToken *secrets.Secret
+	daemon   *Daemon
+
+	// Start and Shutdown run on different goroutines
+	mu       sync.Mutex
+	server   *http.Server
+	shutdown bool
 }
 
 // NewHTTPServer creates a new HTTP server
@@ -36,12 +44,27 @@ func (s *HTTPServer) Start() error {
 	// Status endpoint
 	mux.HandleFunc("/status", s.statusHandler)
 
+	// API endpoints
+	mux.HandleFunc("/api/v1/recommendations", s.recommendationsHandler)
+	mux.HandleFunc("/api/v1/instances", s.instancesHandler)
+	mux.HandleFunc("/api/v1/instances/", s.instancesHandler)
+	mux.HandleFunc("/api/v1/prescale", s.preScaleHandler)
+	mux.HandleFunc("/api/v1/freezes", s.freezesHandler)
+	mux.HandleFunc("/api/v1/approvals", s.approvalsHandler)
+	mux.HandleFunc("/api/v1/approvals/", s.approvalsHandler)
+	mux.HandleFunc("/api/v1/events", s.eventsHandler)
+	mux.HandleFunc("/api/v1/config", s.configHandler)
+	mux.HandleFunc("/api/v1/version", s.versionHandler)
+
+	// Read-only dashboard over the endpoints above
+	mux.HandleFunc("/dashboard", s.dashboardHandler)
+
 	// Metrics endpoint (if Prometheus is enabled)
 	if metricsEnabled {
 		mux.Handle("/metrics", GetMetricsHandler())
 	}
 
-	s.server = &http.Server{
+	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", s.port),
 		Handler:      mux,
 		ReadTimeout:  10 * time.Second,
@@ -49,12 +72,29 @@ func (s *HTTPServer) Start() error {
 		IdleTimeout:  60 * time.Second,
 	}
 
-	return s.server.ListenAndServe()
+	s.mu.Lock()
+	if s.shutdown {
+		s.mu.Unlock()
+		return http.ErrServerClosed
+	}
+	s.server = server
+	s.mu.Unlock()
+
+	return server.ListenAndServe()
 }
 
-// Shutdown gracefully shuts down the HTTP server
+// Shutdown gracefully shuts down the HTTP server. A server shut down before
+// Start runs never serves.
 func (s *HTTPServer) Shutdown(ctx context.Context) error {
-	return s.server.Shutdown(ctx)
+	s.mu.Lock()
+	s.shutdown = true
+	server := s.server
+	s.mu.Unlock()
+
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
 }
 
 // healthHandler responds to health check requests
@@ -0,0 +1,204 @@
+package daemon
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/analyzer"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/rules"
+)
+
+// InstanceView is the API representation of an analyzed instance and what
+// the last cycle decided for it
+type InstanceView struct {
+	Instance         string            `json:"instance"`
+	InstanceID       string            `json:"instance_id"`
+	Project          string            `json:"project"`
+	Region           string            `json:"region"`
+	Zone             string            `json:"zone,omitempty"`
+	DatabaseVersion  string            `json:"database_version"`
+	Edition          string            `json:"edition,omitempty"`
+	MachineType      string            `json:"machine_type"`
+	CPU              int               `json:"cpu"`
+	MemoryGB         float64           `json:"memory_gb"`
+	HighAvailability bool              `json:"high_availability"`
+	Labels           map[string]string `json:"labels,omitempty"`
+	Owner            *config.Owner     `json:"owner,omitempty"`
+
+	Action           string                   `json:"action"` // scale_up, scale_down or no_action
+	RecommendedType  string                   `json:"recommended_type,omitempty"`
+	Reason           string                   `json:"reason"`
+	ReasonCode       string                   `json:"reason_code,omitempty"`
+	ReasonCodes      []cloudsql.ReasonCode    `json:"reason_codes,omitempty"`
+	CPUP95           float64                  `json:"cpu_p95"`
+	MemoryP95Pct     float64                  `json:"memory_p95_pct"`
+	ReplicaLagP95    float64                  `json:"replica_lag_p95_seconds,omitempty"` // Read replicas only
+	EstimatedSavings float64                  `json:"estimated_savings"`
+	Priority         int                      `json:"priority"`
+	DowntimeExpected bool                     `json:"downtime_expected"`
+	Warnings         []rules.Warning          `json:"warnings,omitempty"`
+	Stability        *analyzer.Stability      `json:"stability,omitempty"`       // Consistency of the recommendation across recent cycles
+	DowntimeBudget   *analyzer.DowntimeBudget `json:"downtime_budget,omitempty"` // Monthly downtime budget and this month's use
+	AnalyzedAt       time.Time                `json:"analyzed_at"`
+}
+
+// InstanceList is the response body of /api/v1/instances
+type InstanceList struct {
+	ProjectID string           `json:"project_id"`
+	Filter    string           `json:"filter,omitempty"` // Normalized filter the instances matched
+	Sort      analyzer.SortKey `json:"sort"`
+	Total     int              `json:"total"`   // Instances analyzed in the last cycle
+	Matched   int              `json:"matched"` // Instances the filter matched
+	Returned  int              `json:"returned"`
+	Instances []InstanceView   `json:"instances"`
+	Timestamp time.Time        `json:"timestamp"`
+}
+
+// instanceQueryParams are the /api/v1/instances query parameters that stand
+// for a filter comparison; a comma-separated list matches any of its values
+var instanceQueryParams = []struct {
+	param, field, op string
+}{
+	{"instance", "instance", "="},
+	{"action", "action", "="},
+	{"region", "region", "="},
+	{"engine", "engine", "="},
+	{"machine_type", "machine_type", "="},
+	{"team", "team", "="},
+	{"reason_code", "reason_codes", "="},
+	{"warning", "warnings", "="},
+	{"min_savings", "savings", ">="},
+	{"min_cpu", "cpu_p95", ">="},
+	{"min_memory", "memory_p95", ">="},
+}
+
+// newInstanceView converts an analysis result into its API representation
+func newInstanceView(r *analyzer.AnalysisResult) InstanceView {
+	v := InstanceView{
+		Instance:         r.Instance.Name,
+		InstanceID:       r.Instance.ID(),
+		Project:          r.Instance.Project,
+		Region:           r.Instance.Region,
+		Zone:             r.Instance.Zone,
+		DatabaseVersion:  r.Instance.DatabaseVersion,
+		Edition:          string(r.Instance.Edition),
+		MachineType:      r.Instance.MachineType,
+		CPU:              r.Instance.CurrentCPU,
+		MemoryGB:         r.Instance.CurrentMemoryGB,
+		HighAvailability: r.Instance.HighAvailability,
+		Labels:           r.Instance.Labels,
+		Action:           analyzer.ActionNone,
+		Priority:         r.Priority(),
+		Warnings:         r.Warnings,
+		Stability:        r.Stability,
+		DowntimeBudget:   r.Downtime,
+		AnalyzedAt:       r.AnalyzedAt,
+	}
+	if !r.Owner.IsZero() {
+		owner := r.Owner
+		v.Owner = &owner
+	}
+	if d := r.Decision; d != nil {
+		v.Action = analyzer.DecisionAction(d)
+		v.Reason = d.Reason
+		v.ReasonCode = string(d.ReasonCode())
+		v.ReasonCodes = d.ReasonCodes
+		v.DowntimeExpected = d.DowntimeExpected
+		if d.ShouldScale {
+			v.RecommendedType = d.RecommendedType
+			v.EstimatedSavings = d.EstimatedSavings
+		}
+	}
+	if r.Summary != nil {
+		v.CPUP95 = r.Summary.CPUP95
+		v.MemoryP95Pct = r.Summary.MemoryP95Pct
+		v.ReplicaLagP95 = r.Summary.ReplicaLagP95Seconds
+	}
+	return v
+}
+
+// instancesHandler lists the instances analyzed in the last cycle that match
+// ?filter=EXPR and the shorthand parameters in instanceQueryParams, supporting
+// ?sort=savings|pressure|priority|name and ?top=N. /api/v1/instances/NAME
+// returns one instance, named or by its fully qualified ID.
+func (s *HTTPServer) instancesHandler(w http.ResponseWriter, r *http.Request) {
+	if s.daemon == nil {
+		writeError(w, http.StatusServiceUnavailable, "daemon not available")
+		return
+	}
+
+	results := s.daemon.LastResults()
+	if results == nil {
+		writeError(w, http.StatusServiceUnavailable, "no analysis cycle has completed yet")
+		return
+	}
+
+	if name := strings.TrimPrefix(r.URL.Path, "/api/v1/instances/"); name != r.URL.Path && name != "" {
+		if result := results.Find(name); result != nil {
+			writeJSON(w, http.StatusOK, newInstanceView(result))
+			return
+		}
+		writeError(w, http.StatusNotFound, "instance "+name+" was not analyzed in the last cycle")
+		return
+	}
+
+	query := r.URL.Query()
+	filter, err := analyzer.ParseFilter(query.Get("filter"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, p := range instanceQueryParams {
+		v := query.Get(p.param)
+		if v == "" {
+			continue
+		}
+		var alternatives []string
+		for _, value := range strings.Split(v, ",") {
+			alternatives = append(alternatives, p.field+" "+p.op+" "+strconv.Quote(strings.TrimSpace(value)))
+		}
+		param, err := analyzer.ParseFilter(strings.Join(alternatives, " OR "))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid "+p.param+": "+err.Error())
+			return
+		}
+		filter = filter.And(param)
+	}
+
+	key, err := analyzer.ParseSortKey(query.Get("sort"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	top := 0
+	if v := query.Get("top"); v != "" {
+		top, err = strconv.Atoi(v)
+		if err != nil || top < 0 {
+			writeError(w, http.StatusBadRequest, "top must be a non-negative integer")
+			return
+		}
+	}
+
+	matched := analyzer.RankResults(analyzer.FilterResults(results.Results, filter), key)
+	selected := analyzer.TopN(matched, top)
+
+	list := InstanceList{
+		ProjectID: results.ProjectID,
+		Filter:    filter.String(),
+		Sort:      key,
+		Total:     len(results.Results),
+		Matched:   len(matched),
+		Returned:  len(selected),
+		Instances: make([]InstanceView, 0, len(selected)),
+		Timestamp: time.Now().UTC(),
+	}
+	for _, result := range selected {
+		list.Instances = append(list.Instances, newInstanceView(result))
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
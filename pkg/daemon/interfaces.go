@@ -5,7 +5,9 @@ import (
 	"time"
 
 	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/analyzer"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/approval"
 	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
 )
 
 // Analyzer defines the interface for instance analysis
@@ -13,6 +15,11 @@ import (
 type Analyzer interface {
 	AnalyzeAllInstances(ctx context.Context) (*analyzer.ProjectAnalysisResult, error)
 	ApplyScaling(ctx context.Context, instanceName string, decision *cloudsql.ScalingDecision) error
+	Resize(ctx context.Context, instanceName string, decision *cloudsql.ScalingDecision) (bool, error)
+	ValidateTarget(ctx context.Context, instance *config.InstanceInfo, machineType string) error
+	PlanScaling(results *analyzer.ProjectAnalysisResult) *analyzer.ScalingPlan
+	GetInstance(ctx context.Context, instanceName string) (*config.InstanceInfo, error)
+	ResumeOperations(ctx context.Context) error
 	Close() error
 }
 
@@ -40,6 +47,31 @@ type SignalHandler interface {
 // Clear single responsibility: run autoscaling logic
 type CycleRunner interface {
 	RunCycle(ctx context.Context) error
+	ResumeOperations(ctx context.Context) error
+	LastResults() *analyzer.ProjectAnalysisResult
+}
+
+// InstanceHolder reports instances temporarily pinned outside of regular
+// autoscaling, such as by an external pre-scale request
+type InstanceHolder interface {
+	Held(instanceID string) (reason string, held bool) // By fully qualified instance ID
+}
+
+// FreezeLister reports the scaling freezes in effect
+type FreezeLister interface {
+	Active(now time.Time) []config.Freeze
+}
+
+// IssueFiler tracks standing recommendations as tickets in an issue tracker
+type IssueFiler interface {
+	Sync(ctx context.Context, results []*analyzer.AnalysisResult) error
+}
+
+// ApprovalStore keeps the planned operations that wait for an operator's
+// approval before they are applied
+type ApprovalStore interface {
+	Propose(planned []approval.Request, now time.Time) ([]approval.Request, error)
+	Complete(id string, applyErr error, now time.Time) error
 }
 
 // Config provides read-only access to daemon configuration
@@ -50,4 +82,9 @@ type Config interface {
 	IsMetricsEnabled() bool
 	IsDryRun() bool
 	GetProjectID() string
+	GetAnalysisLatencyBudget() time.Duration
+	GetShadowConfig() *config.Config
+	GetFreezeEmergencyThreshold() float64
+	GetOverloadCycles() int
+	GetStableCycles() int
 }
@@ -0,0 +1,197 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/analyzer"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/issues"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/state"
+)
+
+// standingSection is the state store section standing recommendations are
+// kept in
+const standingSection = "standing_recommendations"
+
+// standingRecommendation is a scale-down recommendation that has been made
+// every cycle since it was first seen
+type standingRecommendation struct {
+	Target string    `json:"target"`
+	Since  time.Time `json:"since"`
+	Filed  bool      `json:"filed,omitempty"` // A ticket was filed for it
+}
+
+// issueFiler files a ticket once an instance has been recommended the same
+// scale-down for longer than a threshold, and closes it once the instance is
+// no longer recommended for one. How long a recommendation has stood is kept
+// in the state store, if any, and otherwise restarts with the daemon; open
+// tickets are found again through their tracking keys.
+type issueFiler struct {
+	tracker  issues.Tracker
+	project  string
+	after    time.Duration
+	currency config.Currency
+
+	mu       sync.Mutex
+	standing map[string]*standingRecommendation // By fully qualified instance ID
+	store    *state.Store                       // Persists standing across restarts; nil keeps it in memory only
+}
+
+// newIssueFiler creates a filer of tickets in tracker for recommendations
+// standing longer than after
+func newIssueFiler(tracker issues.Tracker, project string, after time.Duration, currency config.Currency) *issueFiler {
+	return &issueFiler{
+		tracker:  tracker,
+		project:  project,
+		after:    after,
+		currency: currency,
+		standing: make(map[string]*standingRecommendation),
+	}
+}
+
+// restore loads the standing recommendations kept in store and persists
+// every later change to them there, so a redeploy does not restart the
+// clock of --issue-after-days. Those of another project's instances are left
+// out.
+func (f *issueFiler) restore(ctx context.Context, store *state.Store) error {
+	saved := make(map[string]*standingRecommendation)
+	if _, err := store.Get(ctx, standingSection, &saved); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.store = store
+	for id, standing := range saved {
+		if project, _, _, ok := config.ParseInstanceID(id); ok && project == f.project && standing != nil {
+			f.standing[id] = standing
+		}
+	}
+	return nil
+}
+
+// persistLocked writes the standing recommendations to the state store, if
+// any; f.mu must be held. A failed write is logged: filing goes on, but a
+// restart would restart the recommendations' clocks.
+func (f *issueFiler) persistLocked(ctx context.Context) {
+	if f.store == nil {
+		return
+	}
+	if err := f.store.Put(ctx, standingSection, f.standing); err != nil {
+		log.Printf("Failed to persist standing recommendations: %v", err)
+	}
+}
+
+// Sync brings the tracker's tickets in line with the recommendations in
+// results. Instances not analyzed this cycle, e.g. outside the sample, keep
+// their tickets and how long their recommendation has stood.
+func (f *issueFiler) Sync(ctx context.Context, results []*analyzer.AnalysisResult) error {
+	now := time.Now()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	changed := false
+	defer func() {
+		if changed {
+			f.persistLocked(ctx)
+		}
+	}()
+
+	due := make(map[string]*analyzer.AnalysisResult)
+	resolved := make(map[string]*analyzer.AnalysisResult)
+	for _, result := range results {
+		id, name := result.Instance.ID(), result.Instance.Name
+		if result.Decision == nil {
+			continue // Not resolved just because its analysis failed
+		}
+		if analyzer.DecisionAction(result.Decision) != analyzer.ActionScaleDown {
+			if _, ok := f.standing[id]; ok {
+				delete(f.standing, id)
+				changed = true
+			}
+			resolved[issues.TrackingKey(f.project, name)] = result
+			continue
+		}
+		target := result.Decision.RecommendedType
+		standing := f.standing[id]
+		if standing == nil || standing.Target != target {
+			standing = &standingRecommendation{Target: target, Since: now}
+			f.standing[id] = standing
+			changed = true
+		}
+		if !standing.Filed && now.Sub(standing.Since) >= f.after {
+			due[issues.TrackingKey(f.project, name)] = result
+		}
+	}
+	if len(due) == 0 && len(resolved) == 0 {
+		return nil
+	}
+
+	open, err := f.tracker.OpenIssues(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, issue := range open {
+		if result, ok := resolved[issue.Key]; ok {
+			comment := fmt.Sprintf("%s is no longer recommended for a scale-down. %s", result.Instance.Name, resolution(result))
+			if err := f.tracker.Close(ctx, issue, comment); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			log.Printf("Closed %s issue %s for %s", f.tracker.Name(), issue.ID, result.Instance.Name)
+		}
+		// A ticket that is already open, e.g. filed before a restart, is not filed again
+		if result, ok := due[issue.Key]; ok {
+			f.standing[result.Instance.ID()].Filed = true
+			changed = true
+			delete(due, issue.Key)
+		}
+	}
+
+	for _, result := range due {
+		standing := f.standing[result.Instance.ID()]
+		rec := issues.Recommendation{
+			Project:          f.project,
+			Instance:         result.Instance.Name,
+			CurrentType:      result.Decision.CurrentType,
+			TargetType:       result.Decision.RecommendedType,
+			Reason:           result.Decision.Reason,
+			EstimatedSavings: result.Decision.EstimatedSavings,
+			Since:            standing.Since,
+		}
+		if result.Summary != nil {
+			rec.CPUP95 = result.Summary.CPUP95
+			rec.MemoryP95Pct = result.Summary.MemoryP95Pct
+		}
+		if !result.Owner.IsZero() {
+			owner := result.Owner
+			rec.Owner = &owner
+		}
+		issue, err := f.tracker.Create(ctx, issues.NewIssue(rec, f.currency, now))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// Filed once per recommendation, so a ticket closed by hand is not reopened
+		standing.Filed = true
+		changed = true
+		log.Printf("Filed %s issue %s for %s, recommended %s since %s", f.tracker.Name(), issue.URL, rec.Instance, rec.TargetType, config.FormatTime(rec.Since))
+	}
+	return errors.Join(errs...)
+}
+
+// resolution describes what result decided instead of a scale-down
+func resolution(result *analyzer.AnalysisResult) string {
+	d := result.Decision
+	if analyzer.DecisionAction(d) == analyzer.ActionScaleUp {
+		return "It is on " + d.CurrentType + " and now recommended for a scale-up to " + d.RecommendedType + ": " + d.Reason
+	}
+	return "It is on " + d.CurrentType + ": " + d.Reason
+}
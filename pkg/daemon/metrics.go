@@ -2,15 +2,26 @@ package daemon
 
 import (
 	"net/http"
+	"sync/atomic"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/analyzer"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/version"
 )
 
 var (
 	// Global flag to track if metrics are enabled
 	metricsEnabled = false
 
+	// Running totals the analyzer reports each cycle, exported as counters
+	quotaThrottles atomic.Int64
+	hedged         atomic.Int64
+	hedgesWon      atomic.Int64
+
 	// Prometheus metrics
 	autoscalingCycleDuration = prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "cloudsql_autoscaler_cycle_duration_seconds",
@@ -50,7 +61,23 @@ var (
 			Name: "cloudsql_autoscaler_scaling_operations_total",
 			Help: "Total number of scaling operations by instance and result",
 		},
-		[]string{"instance", "result"},
+		[]string{"instance", "instance_id", "result"},
+	)
+
+	storageResizes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cloudsql_autoscaler_storage_resizes_total",
+			Help: "Total number of data disk size increases by instance and result",
+		},
+		[]string{"instance", "instance_id", "result"},
+	)
+
+	replicaChanges = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cloudsql_autoscaler_replica_changes_total",
+			Help: "Total number of read replica additions and removals by primary, change and result",
+		},
+		[]string{"primary", "primary_id", "change", "result"},
 	)
 
 	instanceMetrics = prometheus.NewGaugeVec(
@@ -58,15 +85,165 @@ var (
 			Name: "cloudsql_autoscaler_instance_cpu_utilization",
 			Help: "Current CPU utilization of Cloud SQL instances",
 		},
-		[]string{"instance", "project"},
+		[]string{"instance", "project", "instance_id"},
+	)
+
+	monitoringQuotaPressure = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cloudsql_autoscaler_monitoring_quota_pressure",
+		Help: "Fraction of the per-minute Cloud Monitoring call budget in use",
+	})
+
+	monitoringQuotaDegrade = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cloudsql_autoscaler_monitoring_quota_degrade_factor",
+		Help: "Current metric granularity degradation factor (1 = full granularity)",
+	})
+
+	monitoringQuotaThrottles = prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "cloudsql_autoscaler_monitoring_quota_throttles_total",
+		Help: "Total number of Cloud Monitoring quota rejections observed",
+	}, func() float64 { return float64(quotaThrottles.Load()) })
+
+	monitoringHedged = prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "cloudsql_autoscaler_monitoring_hedged_requests_total",
+		Help: "Total number of Cloud Monitoring requests a duplicate was sent for after the hedge delay",
+	}, func() float64 { return float64(hedged.Load()) })
+
+	monitoringHedgesWon = prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "cloudsql_autoscaler_monitoring_hedges_won_total",
+		Help: "Total number of hedged Cloud Monitoring requests the duplicate answered first",
+	}, func() float64 { return float64(hedgesWon.Load()) })
+
+	instanceAnalysisDuration = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cloudsql_autoscaler_instance_analysis_duration_seconds",
+			Help: "Time spent analyzing each instance in the last cycle by phase",
+		},
+		[]string{"instance", "project", "instance_id", "phase"},
+	)
+
+	instanceMetricPoints = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cloudsql_autoscaler_instance_metric_points",
+			Help: "Number of metric data points analyzed for each instance in the last cycle",
+		},
+		[]string{"instance", "project", "instance_id"},
+	)
+
+	recommendationStability = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cloudsql_autoscaler_recommendation_stability",
+			Help: "Fraction of recent cycles that made each instance's current recommendation",
+		},
+		[]string{"instance", "project", "instance_id"},
+	)
+
+	downtimeBudgetSeconds = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cloudsql_autoscaler_downtime_budget_seconds",
+			Help: "Monthly downtime budget of each instance with one",
+		},
+		[]string{"instance", "project", "instance_id"},
+	)
+
+	downtimeUsedSeconds = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cloudsql_autoscaler_downtime_used_seconds",
+			Help: "Downtime each instance with a downtime budget spent on resizes this month",
+		},
+		[]string{"instance", "project", "instance_id"},
+	)
+
+	instancesOverLatencyBudget = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cloudsql_autoscaler_instances_over_latency_budget",
+		Help: "Number of instances whose analysis exceeded the latency budget in the last cycle",
+	})
+
+	instancesSkipped = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cloudsql_autoscaler_instances_skipped",
+			Help: "Number of instances not analyzed in the last cycle by reason",
+		},
+		[]string{"reason"},
+	)
+
+	shadowEvaluated = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cloudsql_autoscaler_shadow_evaluated_instances",
+		Help: "Number of instances evaluated against the shadow config in the last cycle",
+	})
+
+	shadowDifferences = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cloudsql_autoscaler_shadow_decision_differences",
+			Help: "Number of instances where the shadow config would decide differently, by active and candidate action",
+		},
+		[]string{"active", "candidate"},
+	)
+
+	activeFreezes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cloudsql_autoscaler_active_freezes",
+			Help: "Number of scaling freezes in effect by scope",
+		},
+		[]string{"scope"},
 	)
 
+	frozenOperations = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cloudsql_autoscaler_frozen_operations",
+		Help: "Number of scaling operations deferred by a freeze in the last cycle",
+	})
+
+	cycleTimeouts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cloudsql_autoscaler_cycle_timeouts_total",
+			Help: "Total number of autoscaling cycles aborted for exceeding their deadline, by the phase they were stuck in",
+		},
+		[]string{"phase"},
+	)
+
+	buildInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cloudsql_autoscaler_build_info",
+			Help: "Always 1; labeled with the version, commit and Go version of the running build",
+		},
+		[]string{"version", "commit", "go_version"},
+	)
+
+	cycleCPUSeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cloudsql_autoscaler_cycle_cpu_seconds",
+		Help: "CPU time the daemon process used during the last autoscaling cycle",
+	})
+
+	cycleAllocatedBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cloudsql_autoscaler_cycle_allocated_bytes",
+		Help: "Heap bytes the daemon allocated during the last autoscaling cycle",
+	})
+
+	cycleGCRuns = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cloudsql_autoscaler_cycle_gc_runs",
+		Help: "Garbage collections during the last autoscaling cycle",
+	})
+
+	cycleGCPauseSeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cloudsql_autoscaler_cycle_gc_pause_seconds",
+		Help: "Total garbage collection pause during the last autoscaling cycle",
+	})
+
+	cycleHeapInUseBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cloudsql_autoscaler_cycle_heap_inuse_bytes",
+		Help: "Heap bytes in use when the last autoscaling cycle ended",
+	})
+
+	cycleGoroutines = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cloudsql_autoscaler_cycle_goroutines",
+		Help: "Goroutines running when the last autoscaling cycle ended",
+	})
+
 	instanceMemoryMetrics = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "cloudsql_autoscaler_instance_memory_utilization",
 			Help: "Current memory utilization of Cloud SQL instances",
 		},
-		[]string{"instance", "project"},
+		[]string{"instance", "project", "instance_id"},
 	)
 )
 
@@ -83,8 +260,34 @@ func InitMetrics() {
 		instancesAnalyzed,
 		instancesScalable,
 		scalingOperations,
+		storageResizes,
+		replicaChanges,
 		instanceMetrics,
 		instanceMemoryMetrics,
+		monitoringQuotaPressure,
+		monitoringQuotaDegrade,
+		monitoringQuotaThrottles,
+		monitoringHedged,
+		monitoringHedgesWon,
+		instanceAnalysisDuration,
+		instanceMetricPoints,
+		recommendationStability,
+		downtimeBudgetSeconds,
+		downtimeUsedSeconds,
+		instancesOverLatencyBudget,
+		instancesSkipped,
+		shadowEvaluated,
+		shadowDifferences,
+		activeFreezes,
+		frozenOperations,
+		cycleTimeouts,
+		buildInfo,
+		cycleCPUSeconds,
+		cycleAllocatedBytes,
+		cycleGCRuns,
+		cycleGCPauseSeconds,
+		cycleHeapInUseBytes,
+		cycleGoroutines,
 	)
 }
 
@@ -94,17 +297,170 @@ func GetMetricsHandler() http.Handler {
 }
 
 // UpdateInstanceMetrics updates the instance-specific metrics
-func UpdateInstanceMetrics(projectID, instanceName string, cpuUtil, memoryUtil float64) {
+func UpdateInstanceMetrics(instance *config.InstanceInfo, cpuUtil, memoryUtil float64) {
 	if metricsEnabled {
-		instanceMetrics.WithLabelValues(instanceName, projectID).Set(cpuUtil)
-		instanceMemoryMetrics.WithLabelValues(instanceName, projectID).Set(memoryUtil)
+		instanceMetrics.WithLabelValues(instance.Name, instance.Project, instance.ID()).Set(cpuUtil)
+		instanceMemoryMetrics.WithLabelValues(instance.Name, instance.Project, instance.ID()).Set(memoryUtil)
+	}
+}
+
+// RecordInstanceUtilization records the latest CPU and memory utilization of
+// each result's instance, dropping instances no longer analyzed
+func RecordInstanceUtilization(results []*analyzer.AnalysisResult) {
+	if metricsEnabled {
+		instanceMetrics.Reset()
+		instanceMemoryMetrics.Reset()
+		for _, r := range results {
+			if m := r.Metrics; m != nil && len(m.CPUUtilization) > 0 && len(m.MemoryPercent) > 0 {
+				UpdateInstanceMetrics(r.Instance, m.CPUUtilization[len(m.CPUUtilization)-1], m.MemoryPercent[len(m.MemoryPercent)-1])
+			}
+		}
 	}
 }
 
 // RecordScalingOperation records a scaling operation result
-func RecordScalingOperation(instanceName, result string) {
+func RecordScalingOperation(instance *config.InstanceInfo, result string) {
+	if metricsEnabled {
+		scalingOperations.WithLabelValues(instance.Name, instance.ID(), result).Inc()
+	}
+}
+
+// RecordStorageResize records a data disk size increase result
+func RecordStorageResize(instance *config.InstanceInfo, result string) {
+	if metricsEnabled {
+		storageResizes.WithLabelValues(instance.Name, instance.ID(), result).Inc()
+	}
+}
+
+// RecordReplicaChange records a read replica addition or removal result
+func RecordReplicaChange(primary *config.InstanceInfo, change, result string) {
+	if metricsEnabled {
+		replicaChanges.WithLabelValues(primary.Name, primary.ID(), change, result).Inc()
+	}
+}
+
+// RecordQuotaStats records Cloud Monitoring quota budget usage
+func RecordQuotaStats(stats cloudsql.QuotaStats) {
+	if metricsEnabled {
+		monitoringQuotaPressure.Set(stats.Pressure)
+		monitoringQuotaDegrade.Set(float64(stats.DegradeFactor))
+		quotaThrottles.Store(int64(stats.TotalThrottle))
+	}
+}
+
+// RecordHedgeStats records hedged Cloud Monitoring requests
+func RecordHedgeStats(stats cloudsql.HedgeStats) {
+	if metricsEnabled {
+		hedged.Store(stats.Hedged)
+		hedgesWon.Store(stats.Won)
+	}
+}
+
+// RecordInstanceTimings records how long each instance's analysis took in
+// the last cycle, dropping instances it did not analyze
+func RecordInstanceTimings(projectID string, timings []analyzer.InstanceTiming) {
+	if metricsEnabled {
+		instanceAnalysisDuration.Reset()
+		instanceMetricPoints.Reset()
+		for _, t := range timings {
+			instanceAnalysisDuration.WithLabelValues(t.Instance, projectID, t.InstanceID, "total").Set(t.Total.Seconds())
+			instanceAnalysisDuration.WithLabelValues(t.Instance, projectID, t.InstanceID, "instance_api").Set(t.InstanceAPI.Seconds())
+			instanceAnalysisDuration.WithLabelValues(t.Instance, projectID, t.InstanceID, "metrics_api").Set(t.MetricsAPI.Seconds())
+			instanceMetricPoints.WithLabelValues(t.Instance, projectID, t.InstanceID).Set(float64(t.MetricPoints))
+		}
+	}
+}
+
+// RecordStability records the stability score of each result's recommendation
+func RecordStability(results []*analyzer.AnalysisResult) {
+	if metricsEnabled {
+		for _, r := range results {
+			if r.Stability != nil {
+				recommendationStability.WithLabelValues(r.Instance.Name, r.Instance.Project, r.Instance.ID()).Set(r.Stability.Score)
+			}
+		}
+	}
+}
+
+// RecordDowntimeBudgets records the downtime budget and this month's use of
+// each result's instance that has one
+func RecordDowntimeBudgets(results []*analyzer.AnalysisResult) {
+	if metricsEnabled {
+		for _, r := range results {
+			if r.Downtime != nil {
+				downtimeBudgetSeconds.WithLabelValues(r.Instance.Name, r.Instance.Project, r.Instance.ID()).Set(r.Downtime.BudgetSeconds)
+				downtimeUsedSeconds.WithLabelValues(r.Instance.Name, r.Instance.Project, r.Instance.ID()).Set(r.Downtime.UsedSeconds)
+			}
+		}
+	}
+}
+
+// RecordOverLatencyBudget records how many instances exceeded the latency budget
+func RecordOverLatencyBudget(count int) {
+	if metricsEnabled {
+		instancesOverLatencyBudget.Set(float64(count))
+	}
+}
+
+// RecordSkippedInstances records how many instances were skipped by reason
+func RecordSkippedInstances(counts map[cloudsql.SkipReason]int) {
+	if metricsEnabled {
+		instancesSkipped.Reset()
+		for reason, count := range counts {
+			instancesSkipped.WithLabelValues(string(reason)).Set(float64(count))
+		}
+	}
+}
+
+// RecordShadowReport records how the shadow config's decisions differed
+func RecordShadowReport(report *analyzer.ShadowReport) {
+	if metricsEnabled {
+		shadowEvaluated.Set(float64(report.Evaluated))
+		shadowDifferences.Reset()
+		for _, d := range report.Differences {
+			shadowDifferences.WithLabelValues(d.ActiveAction, d.CandidateAction).Inc()
+		}
+	}
+}
+
+// RecordFreezes records the freezes in effect and how many operations they deferred
+func RecordFreezes(freezes []config.Freeze, deferred int) {
+	if metricsEnabled {
+		activeFreezes.Reset()
+		for _, scope := range []config.FreezeScope{config.FreezeGlobal, config.FreezeProject, config.FreezeLabel} {
+			activeFreezes.WithLabelValues(string(scope))
+		}
+		for _, f := range freezes {
+			activeFreezes.WithLabelValues(string(f.Scope)).Inc()
+		}
+		frozenOperations.Set(float64(deferred))
+	}
+}
+
+// RecordCycleTimeout records a cycle aborted by the watchdog in phase
+func RecordCycleTimeout(phase string) {
+	if metricsEnabled {
+		cycleTimeouts.WithLabelValues(phase).Inc()
+	}
+}
+
+// RecordCycleUsage records the daemon's own resource usage over a cycle
+func RecordCycleUsage(u CycleUsage) {
+	if metricsEnabled {
+		cycleCPUSeconds.Set(u.CPUTime.Seconds())
+		cycleAllocatedBytes.Set(float64(u.AllocatedBytes))
+		cycleGCRuns.Set(float64(u.GCRuns))
+		cycleGCPauseSeconds.Set(u.GCPause.Seconds())
+		cycleHeapInUseBytes.Set(float64(u.HeapInUseBytes))
+		cycleGoroutines.Set(float64(u.Goroutines))
+	}
+}
+
+// RecordBuildInfo publishes the running build's metadata
+func RecordBuildInfo(info version.Info) {
 	if metricsEnabled {
-		scalingOperations.WithLabelValues(instanceName, result).Inc()
+		buildInfo.Reset()
+		buildInfo.WithLabelValues(info.Version, info.Commit, info.GoVersion).Set(1)
 	}
 }
 
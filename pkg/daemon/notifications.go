@@ -0,0 +1,144 @@
+package daemon
+
+import (
+	"context"
+	"log"
+	"slices"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/analyzer"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/notify"
+)
+
+// notify delivers m to the runner's notifier, if it has one. Delivery is
+// best effort: failures are logged and counted, and the cycle goes on. Aborted
+// cycles still report, so a timed-out cycle's failure reaches the channel.
+func (r *autoscalingRunner) notify(ctx context.Context, m notify.Message) {
+	if r.notifier == nil {
+		return
+	}
+	m.Project = r.config.GetProjectID()
+	if err := r.notifier.Notify(context.WithoutCancel(ctx), m); err != nil {
+		log.Printf("Failed to send %s notification: %v", m.Kind, err)
+		r.metrics.RecordError("notification_failed")
+	}
+}
+
+// notifyRecommendations notifies the recommendations among scalable that
+// were not notified in the last cycle, so a standing recommendation is posted
+// once rather than every cycle. It is posted again if it changes target, or
+// once it lapses for a cycle and returns.
+func (r *autoscalingRunner) notifyRecommendations(ctx context.Context, scalable []*analyzer.AnalysisResult) {
+	if r.notifier == nil {
+		return
+	}
+	r.notifyMu.Lock()
+	current := make(map[string]string, len(scalable))
+	var changes []notify.Change
+	for _, result := range scalable {
+		target := result.Decision.RecommendedType
+		current[result.Instance.Name] = target
+		if r.notified[result.Instance.Name] != target {
+			changes = append(changes, newChange(result))
+		}
+	}
+	r.notified = current
+	r.notifyMu.Unlock()
+
+	if len(changes) > 0 {
+		r.notify(ctx, notify.Message{Kind: notify.KindRecommendations, DryRun: r.config.IsDryRun(), Changes: changes})
+	}
+}
+
+// notifyOverloaded notifies instances among results once they have been
+// above the scale-up thresholds for the configured number of consecutive
+// cycles, whether or not they can be scaled, and again once they fall back
+// below them. Instances not analyzed this cycle, e.g. outside the sample,
+// keep their count.
+func (r *autoscalingRunner) notifyOverloaded(ctx context.Context, results []*analyzer.AnalysisResult) {
+	cycles := r.config.GetOverloadCycles()
+	if r.notifier == nil || cycles <= 0 {
+		return
+	}
+	r.notifyMu.Lock()
+	if r.overloaded == nil {
+		r.overloaded = make(map[string]int)
+	}
+	var overloaded, ended []notify.Change
+	for _, result := range results {
+		id := result.Instance.ID()
+		if !aboveScaleUpThresholds(result.Decision) {
+			if r.overloaded[id] >= cycles {
+				ended = append(ended, newChange(result))
+			}
+			delete(r.overloaded, id)
+			continue
+		}
+		r.overloaded[id]++
+		if r.overloaded[id] == cycles {
+			overloaded = append(overloaded, newChange(result))
+		}
+	}
+	r.notifyMu.Unlock()
+
+	if len(overloaded) > 0 {
+		log.Printf("%d instance(s) above the scale-up thresholds for %d cycles", len(overloaded), cycles)
+		r.notify(ctx, notify.Message{Kind: notify.KindOverloaded, Changes: overloaded, Cycles: cycles})
+	}
+	if len(ended) > 0 {
+		r.notify(ctx, notify.Message{Kind: notify.KindOverloadEnded, Changes: ended})
+	}
+}
+
+// syncIssues files and closes tickets for the standing recommendations among
+// results, if the runner has a filer. Like notifications, this is best
+// effort: failures are logged and counted, and the cycle goes on.
+func (r *autoscalingRunner) syncIssues(ctx context.Context, results []*analyzer.AnalysisResult) {
+	if r.filer == nil {
+		return
+	}
+	if err := r.filer.Sync(context.WithoutCancel(ctx), results); err != nil {
+		log.Printf("Failed to sync recommendation issues: %v", err)
+		r.metrics.RecordError("issue_sync_failed")
+	}
+}
+
+// aboveScaleUpThresholds reports whether decision found P95 CPU or memory
+// over the scale-up threshold, a custom scale-up rule matching or replication
+// lag above the maximum throughout the stable duration
+func aboveScaleUpThresholds(decision *cloudsql.ScalingDecision) bool {
+	if decision == nil {
+		return false
+	}
+	for _, code := range []cloudsql.ReasonCode{
+		cloudsql.ReasonCPUP95High, cloudsql.ReasonMemoryP95High, cloudsql.ReasonScaleUpRule, cloudsql.ReasonReplicaLagSustained,
+	} {
+		if slices.Contains(decision.ReasonCodes, code) {
+			return true
+		}
+	}
+	return false
+}
+
+// newChange describes result's recommended scaling operation
+func newChange(result *analyzer.AnalysisResult) notify.Change {
+	c := notify.Change{
+		Instance:         result.Instance.Name,
+		InstanceID:       result.Instance.ID(),
+		CurrentType:      result.Decision.CurrentType,
+		TargetType:       result.Decision.RecommendedType,
+		Reason:           result.Decision.Reason,
+		ReasonCodes:      result.Decision.ReasonCodes,
+		EstimatedSavings: result.Decision.EstimatedSavings,
+		DowntimeExpected: result.Decision.DowntimeExpected,
+	}
+	if result.Summary != nil {
+		c.CPUP95 = result.Summary.CPUP95
+		c.MemoryP95Pct = result.Summary.MemoryP95Pct
+	}
+	if !result.Owner.IsZero() {
+		owner := result.Owner
+		c.Owner = &owner
+	}
+	return c
+}
@@ -0,0 +1,452 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/state"
+)
+
+// ErrPreScaleRejected is returned when a pre-scale request violates policy
+var ErrPreScaleRejected = errors.New("pre-scale request rejected")
+
+// preScaleCheckInterval is how often pending and expired pre-scales are reconciled
+const preScaleCheckInterval = 30 * time.Second
+
+// preScalesSection is the state store section pre-scales are kept in
+const preScalesSection = "prescales"
+
+// PreScaleRequest asks for an instance to be resized ahead of expected load
+// and returned to its original tier afterwards
+type PreScaleRequest struct {
+	Instance    string    `json:"instance"` // Name, or fully qualified ID, of an instance in the daemon's project
+	MachineType string    `json:"machine_type"`
+	Start       time.Time `json:"start,omitempty"` // Defaults to now
+	Until       time.Time `json:"until"`
+	Reason      string    `json:"reason"`
+	Requester   string    `json:"requester,omitempty"`
+}
+
+// PreScaleState is the lifecycle state of a pre-scale
+type PreScaleState string
+
+const (
+	PreScalePending   PreScaleState = "pending"   // Waiting for its start time
+	PreScaleActive    PreScaleState = "active"    // Instance is running at the requested tier
+	PreScaleReverted  PreScaleState = "reverted"  // Instance was returned to its original tier
+	PreScaleAbandoned PreScaleState = "abandoned" // Ended without a revert, as the instance's tier was changed while it was active
+	PreScaleFailed    PreScaleState = "failed"    // Applying or reverting failed
+)
+
+// PreScale is an accepted pre-scale request. While pending or active the
+// instance is held outside of regular autoscaling.
+type PreScale struct {
+	ID string `json:"id"`
+	PreScaleRequest
+	InstanceID   string        `json:"instance_id,omitempty"` // Fully qualified; Instance is its name
+	OriginalType string        `json:"original_type"`
+	State        PreScaleState `json:"state"`
+	Error        string        `json:"error,omitempty"`
+	CreatedAt    time.Time     `json:"created_at"`
+}
+
+// preScaler validates, applies and reverts pre-scale requests
+type preScaler struct {
+	analyzer    Analyzer
+	projectID   string
+	force       bool
+	dryRun      bool
+	readOnly    bool
+	maxDuration time.Duration
+	freezes     FreezeLister
+	events      EventPublisher
+
+	mu      sync.Mutex
+	entries map[string]*PreScale // By fully qualified instance ID
+	store   *state.Store         // Persists entries across restarts; nil keeps them in memory only
+	wake    chan struct{}
+}
+
+// newPreScaler creates a pre-scaler of instances in projectID. Requests
+// longer than maxDuration, for instances frozen in freezes when they start,
+// and every request in dry-run or read-only mode, are rejected; pre-scales
+// due while their instance is frozen wait for the freeze to end. Pre-scales
+// applied and ended are published to events, if set.
+func newPreScaler(analyzer Analyzer, projectID string, force, dryRun, readOnly bool, maxDuration time.Duration, freezes FreezeLister, events EventPublisher) *preScaler {
+	return &preScaler{
+		analyzer:    analyzer,
+		projectID:   projectID,
+		force:       force,
+		dryRun:      dryRun,
+		readOnly:    readOnly,
+		maxDuration: maxDuration,
+		freezes:     freezes,
+		events:      events,
+		entries:     make(map[string]*PreScale),
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// restore loads the pre-scales kept in store and persists every later change
+// to them there, so pre-scales pending or active when the daemon stopped are
+// applied and reverted after it restarts. Pre-scales of another project's
+// instances are left out, so a store reused with another --project never
+// resizes a namesake.
+func (p *preScaler) restore(ctx context.Context, store *state.Store) error {
+	var saved []PreScale
+	if _, err := store.Get(ctx, preScalesSection, &saved); err != nil {
+		return err
+	}
+	for i, ps := range saved {
+		if ps.InstanceID != "" {
+			continue
+		}
+		// Recorded before pre-scales carried the instance ID
+		instance, err := p.analyzer.GetInstance(ctx, ps.Instance)
+		if err != nil {
+			return fmt.Errorf("failed to identify instance %s of pre-scale %s: %w", ps.Instance, ps.ID, err)
+		}
+		saved[i].InstanceID = instance.ID()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.store = store
+	for i := range saved {
+		ps := saved[i]
+		if project, _, _, ok := config.ParseInstanceID(ps.InstanceID); ok && project != p.projectID {
+			log.Printf("Ignoring pre-scale %s of %s: it is for project %s", ps.ID, ps.InstanceID, project)
+			continue
+		}
+		p.entries[ps.InstanceID] = &ps
+		if ps.State == PreScalePending || ps.State == PreScaleActive {
+			log.Printf("Restored %s pre-scale %s of %s to %s until %s", ps.State, ps.ID, ps.Instance, ps.MachineType, config.FormatTime(ps.Until))
+		}
+	}
+	return nil
+}
+
+// persistLocked writes the pre-scales to the state store, if any; p.mu must
+// be held. A failed write is logged: the pre-scales still run, but would be
+// forgotten by a restart.
+func (p *preScaler) persistLocked(ctx context.Context) {
+	if p.store == nil {
+		return
+	}
+	list := make([]PreScale, 0, len(p.entries))
+	for _, ps := range p.entries {
+		list = append(list, *ps)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Start.Before(list[j].Start) })
+	if err := p.store.Put(ctx, preScalesSection, list); err != nil {
+		log.Printf("Failed to persist pre-scales: %v", err)
+	}
+}
+
+// Held reports whether the instance with the fully qualified instanceID is
+// pinned by a pending or active pre-scale
+func (p *preScaler) Held(instanceID string) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ps, ok := p.entries[instanceID]
+	if !ok || (ps.State != PreScalePending && ps.State != PreScaleActive) {
+		return "", false
+	}
+	return fmt.Sprintf("Pre-scaled to %s until %s (%s)", ps.MachineType, config.FormatTime(ps.Until), ps.ID), true
+}
+
+// List returns all known pre-scales ordered by start time
+func (p *preScaler) List() []PreScale {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	list := make([]PreScale, 0, len(p.entries))
+	for _, ps := range p.entries {
+		list = append(list, *ps)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Start.Before(list[j].Start) })
+	return list
+}
+
+// Submit validates a request against policy and schedules it
+func (p *preScaler) Submit(ctx context.Context, req PreScaleRequest, now time.Time) (*PreScale, error) {
+	if p.readOnly {
+		return nil, fmt.Errorf("%w: the autoscaler is in read-only mode", ErrPreScaleRejected)
+	}
+	if p.dryRun {
+		return nil, fmt.Errorf("%w: the autoscaler is in dry-run mode", ErrPreScaleRejected)
+	}
+	if req.Start.IsZero() || req.Start.Before(now) {
+		req.Start = now
+	}
+
+	project, region, name, qualified := config.ParseInstanceID(req.Instance)
+	if !qualified {
+		name = req.Instance
+	} else if project != p.projectID {
+		return nil, fmt.Errorf("%w: instance %s is not in project %s", ErrPreScaleRejected, req.Instance, p.projectID)
+	}
+	instance, err := p.analyzer.GetInstance(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instance %s: %w", req.Instance, err)
+	}
+	if qualified && instance.Region != region {
+		return nil, fmt.Errorf("%w: instance %s is in %s, not %s", ErrPreScaleRejected, name, instance.Region, region)
+	}
+	req.Instance = instance.Name
+	if err := p.validate(ctx, req, instance, now); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPreScaleRejected, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if existing, ok := p.entries[instance.ID()]; ok && (existing.State == PreScalePending || existing.State == PreScaleActive) {
+		return nil, fmt.Errorf("%w: instance %s already has pre-scale %s until %s",
+			ErrPreScaleRejected, req.Instance, existing.ID, config.FormatTime(existing.Until))
+	}
+
+	ps := &PreScale{
+		ID:              cloudsql.NewDecisionID(),
+		PreScaleRequest: req,
+		InstanceID:      instance.ID(),
+		OriginalType:    instance.MachineType,
+		State:           PreScalePending,
+		CreatedAt:       now,
+	}
+	p.entries[ps.InstanceID] = ps
+	p.persistLocked(ctx)
+
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+
+	copied := *ps
+	return &copied, nil
+}
+
+// validate checks a request against the pre-scale policy, and that both the
+// requested tier and the revert to the current one would be applied
+func (p *preScaler) validate(ctx context.Context, req PreScaleRequest, instance *config.InstanceInfo, now time.Time) error {
+	if req.Reason == "" {
+		return fmt.Errorf("reason is required")
+	}
+	if !req.Until.After(req.Start) {
+		return fmt.Errorf("until must be after start")
+	}
+	if p.maxDuration > 0 && req.Until.Sub(req.Start) > p.maxDuration {
+		return fmt.Errorf("duration %v exceeds the maximum of %v", req.Until.Sub(req.Start).Round(time.Minute), p.maxDuration)
+	}
+	if _, err := config.GetMachineType(req.MachineType); err != nil {
+		return fmt.Errorf("unknown machine type %s", req.MachineType)
+	}
+	up, err := config.IsUpscale(instance.MachineType, req.MachineType)
+	if err != nil {
+		return fmt.Errorf("cannot compare %s with the current tier: %v", req.MachineType, err)
+	}
+	if !up {
+		return fmt.Errorf("%s is not larger than the current tier %s", req.MachineType, instance.MachineType)
+	}
+	if freeze, frozen := p.frozen(instance, req.Start); frozen {
+		return fmt.Errorf("%s is frozen (%s) until %s: %s", instance.Name, freeze.Target(), config.FormatTime(freeze.Until), freeze.Reason)
+	}
+	if err := p.analyzer.ValidateTarget(ctx, instance, req.MachineType); err != nil {
+		return fmt.Errorf("cannot scale %s to %s: %v", instance.Name, req.MachineType, err)
+	}
+	if err := p.analyzer.ValidateTarget(ctx, instance, instance.MachineType); err != nil {
+		return fmt.Errorf("cannot revert %s to %s afterwards: %v", instance.Name, instance.MachineType, err)
+	}
+	if instance.IsFailoverReplica {
+		return fmt.Errorf("%s is a failover replica and is resized with its primary %s", instance.Name, instance.PrimaryInstance)
+	}
+	if config.GetScalingConstraints(instance.Edition).DowntimeOnScale && !p.force {
+		return fmt.Errorf("resizing %s edition instances causes downtime and the daemon is not running with force", instance.Edition)
+	}
+	return nil
+}
+
+// frozen returns the freeze in effect covering instance at t, if any
+func (p *preScaler) frozen(instance *config.InstanceInfo, t time.Time) (config.Freeze, bool) {
+	if p.freezes == nil {
+		return config.Freeze{}, false
+	}
+	return config.ActiveFreeze(p.freezes.Active(time.Now()), p.projectID, instance, t)
+}
+
+// run reconciles pre-scales until ctx is cancelled
+func (p *preScaler) run(ctx context.Context) {
+	ticker := time.NewTicker(preScaleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-p.wake:
+		}
+		p.reconcile(ctx, time.Now())
+	}
+}
+
+// reconcile applies pre-scales whose start has passed and reverts those that
+// have expired
+func (p *preScaler) reconcile(ctx context.Context, now time.Time) {
+	var due []*PreScale
+	p.mu.Lock()
+	for _, ps := range p.entries {
+		if (ps.State == PreScalePending && !now.Before(ps.Start)) || (ps.State == PreScaleActive && !now.Before(ps.Until)) {
+			due = append(due, ps)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, ps := range due {
+		// Only a resize moves a pre-scale on; one skipped as applied before is
+		// retried until it ends
+		next := ps.State
+		var resized bool
+		var err error
+		switch {
+		case ps.State == PreScalePending && !now.Before(ps.Until):
+			// The daemon was down for the whole pre-scale
+			err = fmt.Errorf("ended at %s before it could be applied", config.FormatTime(ps.Until))
+		case ps.State == PreScalePending:
+			resized, err = p.apply(ctx, ps)
+			if resized {
+				next = PreScaleActive
+			}
+		default:
+			var drifted bool
+			drifted, resized, err = p.revert(ctx, ps)
+			switch {
+			case drifted:
+				next = PreScaleAbandoned
+			case resized:
+				next = PreScaleReverted
+			}
+		}
+		if err == nil && next == ps.State {
+			continue
+		}
+
+		p.mu.Lock()
+		if err != nil {
+			log.Printf("Pre-scale %s of %s failed: %v", ps.ID, ps.Instance, err)
+			ps.State, ps.Error = PreScaleFailed, err.Error()
+		} else {
+			ps.State = next
+		}
+		p.persistLocked(ctx)
+		snapshot := *ps
+		p.mu.Unlock()
+
+		if p.events == nil {
+			continue
+		}
+		switch snapshot.State {
+		case PreScaleActive:
+			p.events.Publish(EventPreScaleApplied, ps.Instance, fmt.Sprintf("Pre-scaled to %s until %s: %s",
+				ps.MachineType, config.FormatTime(ps.Until), ps.Reason), snapshot)
+		case PreScaleReverted:
+			p.events.Publish(EventPreScaleEnded, ps.Instance, "Pre-scale reverted to "+ps.OriginalType, snapshot)
+		case PreScaleAbandoned:
+			p.events.Publish(EventPreScaleEnded, ps.Instance, "Pre-scale ended without a revert: the tier was changed while it was active", snapshot)
+		case PreScaleFailed:
+			p.events.Publish(EventPreScaleEnded, ps.Instance, "Pre-scale failed: "+snapshot.Error, snapshot)
+		}
+	}
+}
+
+// apply resizes the instance to the requested tier and reports whether it
+// runs at it now
+func (p *preScaler) apply(ctx context.Context, ps *PreScale) (bool, error) {
+	instance, err := p.analyzer.GetInstance(ctx, ps.Instance)
+	if err != nil {
+		return false, fmt.Errorf("failed to get instance: %w", err)
+	}
+	if instance.MachineType == ps.MachineType && ps.OriginalType != ps.MachineType {
+		// Applied before a restart; the original tier is already recorded
+		return true, nil
+	}
+	if freeze, frozen := p.frozen(instance, time.Now()); frozen {
+		log.Printf("Deferred pre-scale %s of %s: frozen (%s) until %s", ps.ID, ps.Instance, freeze.Target(), config.FormatTime(freeze.Until))
+		return false, nil
+	}
+
+	// Revert to whatever the instance runs when the pre-scale starts
+	p.mu.Lock()
+	ps.OriginalType = instance.MachineType
+	p.mu.Unlock()
+
+	requester := ps.Requester
+	if requester == "" {
+		requester = "external request"
+	}
+	decision := p.decision(instance, ps.MachineType, cloudsql.ReasonPreScale, fmt.Sprintf("Pre-scale requested by %s until %s: %s",
+		requester, config.FormatTime(ps.Until), ps.Reason))
+	decision.ID = ps.ID
+
+	log.Printf("Applying pre-scale %s: %s %s → %s until %s", ps.ID, ps.Instance, instance.MachineType, ps.MachineType, config.FormatTime(ps.Until))
+	resized, err := p.analyzer.Resize(ctx, ps.Instance, decision)
+	if err == nil && !resized {
+		log.Printf("Pre-scale %s of %s was not applied; retrying until %s", ps.ID, ps.Instance, config.FormatTime(ps.Until))
+	}
+	return resized, err
+}
+
+// revert returns the instance to its original tier and reports whether it
+// did. If its tier was changed by someone else while the pre-scale was
+// active, it is left alone and drifted is reported instead.
+func (p *preScaler) revert(ctx context.Context, ps *PreScale) (drifted, resized bool, err error) {
+	instance, err := p.analyzer.GetInstance(ctx, ps.Instance)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to get instance: %w", err)
+	}
+	if instance.MachineType == ps.OriginalType {
+		// Reverted before a restart
+		return false, true, nil
+	}
+	if instance.MachineType != ps.MachineType {
+		log.Printf("Not reverting pre-scale %s: %s is now %s, not %s", ps.ID, ps.Instance, instance.MachineType, ps.MachineType)
+		return true, false, nil
+	}
+	if freeze, frozen := p.frozen(instance, time.Now()); frozen {
+		log.Printf("Deferred reverting pre-scale %s of %s: frozen (%s) until %s", ps.ID, ps.Instance, freeze.Target(), config.FormatTime(freeze.Until))
+		return false, false, nil
+	}
+
+	decision := p.decision(instance, ps.OriginalType, cloudsql.ReasonPreScaleRevert, fmt.Sprintf("Reverting pre-scale %s: %s", ps.ID, ps.Reason))
+
+	log.Printf("Reverting pre-scale %s: %s %s → %s", ps.ID, ps.Instance, instance.MachineType, ps.OriginalType)
+	resized, err = p.analyzer.Resize(ctx, ps.Instance, decision)
+	if err == nil && !resized {
+		log.Printf("Pre-scale %s of %s was not reverted; retrying", ps.ID, ps.Instance)
+	}
+	return false, resized, err
+}
+
+// decision builds the scaling decision that moves instance to machineType
+func (p *preScaler) decision(instance *config.InstanceInfo, machineType string, code cloudsql.ReasonCode, reason string) *cloudsql.ScalingDecision {
+	decision := &cloudsql.ScalingDecision{
+		ShouldScale:     true,
+		CurrentType:     instance.MachineType,
+		RecommendedType: machineType,
+		Reason:          reason,
+		ReasonCodes:     []cloudsql.ReasonCode{code},
+	}
+	if config.GetScalingConstraints(instance.Edition).DowntimeOnScale {
+		decision.DowntimeExpected = true
+		decision.DowntimeReason = fmt.Sprintf("%s edition requires downtime for all scaling operations", instance.Edition)
+	}
+	return decision
+}
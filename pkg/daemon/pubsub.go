@@ -0,0 +1,233 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"google.golang.org/api/option"
+	pubsub "google.golang.org/api/pubsub/v1"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudevents"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/redact"
+)
+
+// Formats Pub/Sub messages and webhook bodies are encoded in
+const (
+	EventFormatNative      = "native"      // PubSubMessage and notify.WebhookPayload as JSON
+	EventFormatCloudEvents = "cloudevents" // The same, as the data of a CloudEvents 1.0 event
+)
+
+// pubsubEventTypes maps the daemon events published to Pub/Sub to the event
+// types downstream automation subscribes to
+var pubsubEventTypes = map[EventType]string{
+	EventCycleCompleted: "analysis_completed",
+	EventPlanned:        "scaling_planned",
+	EventScaled:         "scaling_applied",
+	EventScalingFailed:  "scaling_failed",
+}
+
+// pubsubCloudEventTypes are the CloudEvents types of the published events
+var pubsubCloudEventTypes = map[EventType]string{
+	EventCycleCompleted: cloudevents.TypeAnalysisCompleted,
+	EventPlanned:        cloudevents.TypeScalingPlanned,
+	EventScaled:         cloudevents.TypeScalingApplied,
+	EventScalingFailed:  cloudevents.TypeScalingFailed,
+}
+
+const (
+	// pubsubBatchSize is the most messages sent in one publish request
+	pubsubBatchSize = 100
+	// pubsubTimeout bounds a publish request
+	pubsubTimeout = 30 * time.Second
+)
+
+// topicName matches a topic ID or a full topic name projects/P/topics/T
+var topicName = regexp.MustCompile(`^(projects/[a-z][a-z0-9-]{4,28}[a-z0-9]/topics/)?[A-Za-z][A-Za-z0-9._~+%-]{2,254}$`)
+
+// PubSubMessage is the JSON body of a message published to Pub/Sub. Messages
+// also carry event_type, project, instance and instance_id attributes for
+// subscription filters and, in the CloudEvents format, the event's ce-
+// attributes.
+type PubSubMessage struct {
+	Type       string      `json:"type"` // analysis_completed, scaling_planned, scaling_applied or scaling_failed
+	ID         uint64      `json:"id"`   // The event's ID in /api/v1/events
+	Time       time.Time   `json:"time"`
+	Project    string      `json:"project"`
+	Instance   string      `json:"instance,omitempty"`
+	InstanceID string      `json:"instance_id,omitempty"`
+	Message    string      `json:"message"`
+	Data       interface{} `json:"data,omitempty"`
+}
+
+// pubsubPublisher publishes the daemon's analysis and scaling events to a
+// Pub/Sub topic
+type pubsubPublisher struct {
+	topic       string // projects/P/topics/T
+	project     string
+	cloudEvents bool // Add CloudEvents attributes in binary mode
+	service     *pubsub.Service
+	events      *eventBroker
+
+	redactor  *redact.Redactor // Masks messages and their attributes; nil publishes them as they are
+	instances func() []string  // The fleet's instance names, masked wherever a message names them
+}
+
+// newPubSubPublisher creates a publisher of events to topic, a topic ID in
+// project or a full topic name, as CloudEvents if cloudEvents is set.
+// PUBSUB_EMULATOR_HOST directs it to the Pub/Sub emulator instead, as with
+// the Google client libraries.
+func newPubSubPublisher(ctx context.Context, topic, project string, cloudEvents bool, events *eventBroker) (*pubsubPublisher, error) {
+	if !topicName.MatchString(topic) {
+		return nil, fmt.Errorf("invalid Pub/Sub topic %q (must be a topic ID or projects/PROJECT/topics/TOPIC)", topic)
+	}
+	if !strings.HasPrefix(topic, "projects/") {
+		topic = "projects/" + project + "/topics/" + topic
+	}
+
+	opts := []option.ClientOption{option.WithScopes(pubsub.PubsubScope)}
+	if host := os.Getenv("PUBSUB_EMULATOR_HOST"); host != "" {
+		opts = []option.ClientOption{option.WithEndpoint("http://" + host + "/"), option.WithoutAuthentication()}
+	}
+	service, err := pubsub.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Pub/Sub client: %w", err)
+	}
+	return &pubsubPublisher{topic: topic, project: project, cloudEvents: cloudEvents, service: service, events: events}, nil
+}
+
+// setRedactor has every message masked by r before it is published, with
+// the names instances returns masked wherever its text names them. It must
+// be called before run.
+func (p *pubsubPublisher) setRedactor(r *redact.Redactor, instances func() []string) {
+	p.redactor, p.instances = r, instances
+}
+
+// run publishes events until ctx is done. It follows the broker as a
+// reconnecting /api/v1/events client does, so a slow Pub/Sub loses no events
+// unless it falls behind the broker's whole history.
+func (p *pubsubPublisher) run(ctx context.Context) {
+	var lastID uint64
+	for {
+		backlog, events, cancel := p.events.Subscribe(lastID)
+		lastID = p.publish(ctx, backlog, lastID)
+	follow:
+		for {
+			select {
+			case <-ctx.Done():
+				cancel()
+				return
+			case event, ok := <-events:
+				if !ok {
+					break follow
+				}
+				batch := []Event{event}
+				for len(batch) < pubsubBatchSize && len(events) > 0 {
+					batch = append(batch, <-events)
+				}
+				lastID = p.publish(ctx, batch, lastID)
+			}
+		}
+		cancel()
+	}
+}
+
+// publish sends the events Pub/Sub subscribers get among events, in batches,
+// and returns the ID of the last event. Publishing is best effort: failures
+// are logged and counted, and those events are not retried.
+func (p *pubsubPublisher) publish(ctx context.Context, events []Event, lastID uint64) uint64 {
+	var messages []*pubsub.PubsubMessage
+	for _, event := range events {
+		lastID = event.ID
+		if message := p.message(event); message != nil {
+			messages = append(messages, message)
+		}
+	}
+
+	for len(messages) > 0 {
+		n := min(len(messages), pubsubBatchSize)
+		// Events of a cycle that is shutting down still go out
+		callCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), pubsubTimeout)
+		_, err := p.service.Projects.Topics.Publish(p.topic, &pubsub.PublishRequest{Messages: messages[:n]}).Context(callCtx).Do()
+		cancel()
+		if err != nil {
+			log.Printf("Failed to publish %d event(s) to %s: %v", n, p.topic, err)
+			RecordError("pubsub_publish_failed")
+		}
+		messages = messages[n:]
+	}
+	return lastID
+}
+
+// encode encodes message as JSON, redacted when a redactor is set. The fleet's
+// instances are masked wherever they appear, as is instance when it is not
+// among them yet.
+func (p *pubsubPublisher) encode(message PubSubMessage, instance string) ([]byte, error) {
+	if !p.redactor.Enabled() {
+		return json.Marshal(message)
+	}
+	var names []string
+	if p.instances != nil {
+		names = p.instances()
+	}
+	if instance != "" {
+		names = append(names, instance)
+	}
+	data, err := p.redactor.JSON(message, p.project, names)
+	if err != nil {
+		return nil, err
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return nil, err
+	}
+	return compact.Bytes(), nil
+}
+
+// message converts event into a Pub/Sub message, or nil if it is not
+// published
+func (p *pubsubPublisher) message(event Event) *pubsub.PubsubMessage {
+	eventType, ok := pubsubEventTypes[event.Type]
+	if !ok {
+		return nil
+	}
+	data, err := p.encode(PubSubMessage{
+		Type:       eventType,
+		ID:         event.ID,
+		Time:       event.Time,
+		Project:    p.project,
+		Instance:   event.Instance,
+		InstanceID: event.InstanceID,
+		Message:    event.Message,
+		Data:       event.Data,
+	}, event.Instance)
+	if err != nil {
+		log.Printf("Failed to encode %s event for Pub/Sub: %v", eventType, err)
+		return nil
+	}
+
+	project, instance, instanceID := p.project, event.Instance, event.InstanceID
+	if p.redactor.Enabled() {
+		project, instance = p.redactor.Project(project), p.redactor.Instance(instance)
+		instanceID = p.redactor.Text(instanceID, p.project, []string{event.Instance})
+	}
+	attributes := map[string]string{"event_type": eventType, "project": project}
+	if p.cloudEvents {
+		attributes = cloudevents.New(pubsubCloudEventTypes[event.Type], project, instance, event.Time, nil).Attributes()
+		attributes["event_type"], attributes["project"] = eventType, project
+	}
+	if instance != "" {
+		attributes["instance"] = instance
+	}
+	if instanceID != "" {
+		attributes["instance_id"] = instanceID
+	}
+	return &pubsub.PubsubMessage{Data: base64.StdEncoding.EncodeToString(data), Attributes: attributes}
+}
@@ -2,26 +2,99 @@ package daemon
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/analyzer"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/notify"
 )
 
+// quotaReporter is implemented by analyzers that track Monitoring quota usage
+type quotaReporter interface {
+	QuotaStats() cloudsql.QuotaStats
+}
+
+// hedgeReporter is implemented by analyzers that hedge slow Monitoring requests
+type hedgeReporter interface {
+	HedgeStats() cloudsql.HedgeStats
+}
+
+// shadowEvaluator is implemented by analyzers that evaluate a shadow config
+type shadowEvaluator interface {
+	EvaluateShadow(results *analyzer.ProjectAnalysisResult) *analyzer.ShadowReport
+}
+
+// storageApplier is implemented by analyzers that can grow data disks
+type storageApplier interface {
+	StorageScalingEnabled() bool
+	StorageDeferral(result *analyzer.AnalysisResult, freezes []config.Freeze, now time.Time) (string, bool)
+	ApplyStorage(ctx context.Context, instanceName string, decision *cloudsql.StorageDecision) error
+}
+
+// replicaApplier is implemented by analyzers that can add and remove read replicas
+type replicaApplier interface {
+	ReplicaScalingEnabled() bool
+	ReplicaDeferral(change analyzer.ReplicaChange, freezes []config.Freeze, now time.Time) (string, bool)
+	ApplyReplicaChange(ctx context.Context, decision *cloudsql.ReplicaDecision) error
+}
+
 // autoscalingRunner implements CycleRunner interface
 // Following single responsibility principle
 type autoscalingRunner struct {
-	analyzer Analyzer
-	config   Config
-	metrics  MetricsReporter
+	analyzer  Analyzer
+	config    Config
+	metrics   MetricsReporter
+	holds     InstanceHolder
+	freezes   FreezeLister
+	events    EventPublisher
+	notifier  notify.Notifier
+	filer     IssueFiler
+	approvals ApprovalStore
+	stability analyzer.StabilityTracker
+	phase     phaseTracker
+
+	mu          sync.RWMutex
+	lastResults *analyzer.ProjectAnalysisResult
+
+	notifyMu   sync.Mutex
+	notified   map[string]string // Target machine type last notified, by instance
+	overloaded map[string]int    // Consecutive cycles above the scale-up thresholds, by fully qualified instance ID
+	approved   map[string]string // Approval request ID of this cycle's operations, by instance
 }
 
-// NewAutoscalingRunner creates a new cycle runner
-func NewAutoscalingRunner(analyzer Analyzer, config Config, metrics MetricsReporter) CycleRunner {
+// NewAutoscalingRunner creates a new cycle runner. Instances reported by holds
+// are left alone, operations covered by freezes are deferred and what each
+// cycle decides and does is published to events and sent to notifier.
+// Standing scale-down recommendations are filed as tickets through filer, and
+// operations are applied only once approved in approvals. holds, freezes,
+// events, notifier, filer and approvals may be nil.
+func NewAutoscalingRunner(analyzer Analyzer, config Config, metrics MetricsReporter, holds InstanceHolder, freezes FreezeLister, events EventPublisher, notifier notify.Notifier, filer IssueFiler, approvals ApprovalStore) CycleRunner {
 	return &autoscalingRunner{
-		analyzer: analyzer,
-		config:   config,
-		metrics:  metrics,
+		analyzer:  analyzer,
+		config:    config,
+		metrics:   metrics,
+		holds:     holds,
+		freezes:   freezes,
+		events:    events,
+		notifier:  notifier,
+		filer:     filer,
+		approvals: approvals,
+	}
+}
+
+// CurrentPhase returns the phase the current or last cycle is in
+func (r *autoscalingRunner) CurrentPhase() CyclePhase {
+	return r.phase.CurrentPhase()
+}
+
+// publish sends an event if the runner has a publisher
+func (r *autoscalingRunner) publish(eventType EventType, instance, message string, data interface{}) {
+	if r.events != nil {
+		r.events.Publish(eventType, instance, message, data)
 	}
 }
 
@@ -43,54 +116,280 @@ func (r *autoscalingRunner) RunCycle(ctx context.Context) error {
 	}()
 
 	log.Printf("Starting autoscaling cycle for project: %s", r.config.GetProjectID())
+	r.publish(EventCycleStarted, "", "Starting autoscaling cycle for project "+r.config.GetProjectID(), nil)
 
 	// Analyze all instances
+	r.phase.enter(PhaseAnalyze, "")
 	results, err := r.analyzer.AnalyzeAllInstances(ctx)
 	if err != nil {
 		r.metrics.RecordError("analysis_error")
+		r.publish(EventCycleFailed, "", fmt.Sprintf("Analysis failed: %v", err), nil)
+		r.notify(ctx, notify.Message{Kind: notify.KindCycleFailed, Error: fmt.Sprintf("Analysis failed: %v", err)})
 		return WrapError("analyze_instances", err)
 	}
 
+	r.stability.Observe(results.Results)
+	RecordStability(results.Results)
+	RecordDowntimeBudgets(results.Results)
+	RecordInstanceUtilization(results.Results)
+
+	r.mu.Lock()
+	r.lastResults = results
+	r.mu.Unlock()
+
+	r.phase.enter(PhasePlan, "")
 	scalableInstances := results.GetScalableInstances()
 
+	if qr, ok := r.analyzer.(quotaReporter); ok {
+		stats := qr.QuotaStats()
+		RecordQuotaStats(stats)
+		if stats.DegradeFactor > cloudsql.DegradeNone {
+			log.Printf("Monitoring quota under pressure (%.0f%% used), metric granularity degraded %dx",
+				stats.Pressure*100, stats.DegradeFactor)
+		}
+	}
+	if hr, ok := r.analyzer.(hedgeReporter); ok {
+		RecordHedgeStats(hr.HedgeStats())
+	}
+
 	// Record metrics
 	r.metrics.RecordInstanceCounts(
 		results.TotalInstances,
 		results.AnalyzedInstances,
 		len(scalableInstances),
 	)
+	r.reportTimings(results)
+	r.reportShadow(results)
+	RecordSkippedInstances(results.SkippedByReason())
+	for _, skip := range results.Skipped {
+		log.Printf("Skipped instance %s (%s): %s", skip.Name, skip.Reason, skip.Detail)
+		if skip.DeletionReview {
+			log.Printf("Instance %s: %s", skip.Name, skip.Hint)
+		}
+	}
 
 	log.Printf("Found %d instances needing scaling out of %d total instances",
 		len(scalableInstances), results.TotalInstances)
+	for _, result := range scalableInstances {
+		r.publish(EventRecommendation, result.Instance.Name, result.Decision.Reason, newRecommendationView(result))
+	}
+	r.notifyRecommendations(ctx, scalableInstances)
+	r.notifyOverloaded(ctx, results.Results)
+	r.syncIssues(ctx, results.Results)
+	storageIncreases := results.GetStorageIncreases()
+	for _, result := range storageIncreases {
+		r.publish(EventStorageRecommendation, result.Instance.Name, result.Storage.Reason, result.Storage)
+	}
+
+	now := time.Now()
+	plan := r.applyFreezes(r.analyzer.PlanScaling(results), now)
+	plan.Operations = r.withoutHeld(plan.Operations)
+	plan = r.awaitStability(plan)
+	plan = r.awaitApproval(plan, now)
+	for _, change := range plan.ReplicaChanges {
+		r.publish(EventReplicaRecommendation, change.Decision.Primary, change.Decision.Reason, change.Decision)
+	}
+	for _, d := range plan.Deferred {
+		r.publish(EventDeferred, d.Instance, d.DeferReason, d)
+		if d.NotBefore.IsZero() {
+			log.Printf("Deferred scaling of %s (%s → %s): %s%s", d.Instance, d.CurrentType, d.TargetType, d.DeferReason,
+				ownedBy(d.ScalingOperation))
+			continue
+		}
+		log.Printf("Deferred scaling of %s (%s → %s) until %s: %s%s", d.Instance, d.CurrentType, d.TargetType,
+			config.FormatTime(d.NotBefore), d.DeferReason, ownedBy(d.ScalingOperation))
+	}
+
+	r.publish(EventCycleCompleted, "", fmt.Sprintf("Analyzed %d of %d instances; %d operation(s) planned, %d deferred",
+		results.AnalyzedInstances, results.TotalInstances, len(plan.Operations), len(plan.Deferred)),
+		map[string]interface{}{
+			"total_instances":    results.TotalInstances,
+			"analyzed_instances": results.AnalyzedInstances,
+			"scalable_instances": len(scalableInstances),
+			"operations":         len(plan.Operations),
+			"deferred":           len(plan.Deferred),
+			"dry_run":            r.config.IsDryRun(),
+		})
+	for _, op := range plan.Operations {
+		message := fmt.Sprintf("Planned scaling from %s to %s", op.CurrentType, op.TargetType)
+		if r.config.IsDryRun() {
+			message += " (dry run)"
+		}
+		r.publish(EventPlanned, op.Instance, message, op)
+	}
 
 	if r.config.IsDryRun() {
-		log.Printf("Dry-run mode: would scale %d instances", len(scalableInstances))
+		log.Printf("Dry-run mode: would scale %d instances (%d deferred)", len(plan.Operations), len(plan.Deferred))
 		return nil
 	}
 
+	// Change read capacity before any primary is resized
+	replicaErr := r.applyReplicaChanges(ctx, plan.ReplicaChanges)
+
+	// Then grow full disks; disk resizes cause no downtime
+	storageErr := r.applyStorageIncreases(ctx, storageIncreases)
+
 	// Apply scaling decisions
-	return r.applyScalingDecisions(ctx, scalableInstances)
+	if err := r.applyScalingDecisions(ctx, plan.Operations); err != nil {
+		return err
+	}
+	if replicaErr != nil {
+		return replicaErr
+	}
+	return storageErr
+}
+
+// ResumeOperations waits for and verifies scaling operations left in flight by
+// a previous daemon process
+func (r *autoscalingRunner) ResumeOperations(ctx context.Context) error {
+	if r.config.IsDryRun() {
+		return nil
+	}
+	if err := r.analyzer.ResumeOperations(ctx); err != nil {
+		r.metrics.RecordError("resume_failed")
+		return WrapError("resume_operations", err)
+	}
+	return nil
+}
+
+// LastResults returns the results of the most recent successful analysis, or
+// nil if no cycle has completed yet
+func (r *autoscalingRunner) LastResults() *analyzer.ProjectAnalysisResult {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.lastResults
+}
+
+// slowestInstancesLogged is the number of slowest analyses logged each cycle
+const slowestInstancesLogged = 3
+
+// reportTimings records per-instance analysis latency and logs the slowest
+// instances of the cycle
+func (r *autoscalingRunner) reportTimings(results *analyzer.ProjectAnalysisResult) {
+	budget := r.config.GetAnalysisLatencyBudget()
+	RecordInstanceTimings(results.ProjectID, results.Slowest(0, budget))
+	RecordOverLatencyBudget(results.OverBudget(budget))
+
+	slowest := results.Slowest(slowestInstancesLogged, budget)
+	if len(slowest) == 0 {
+		return
+	}
+	log.Printf("Analysis took %v; %d instance(s) over the %v latency budget",
+		results.Duration.Round(time.Second), results.OverBudget(budget), budget)
+	for _, t := range slowest {
+		log.Printf("  slow instance %s: %v (instance API %v, metrics %v, %d points)", t.Instance,
+			t.Total.Round(time.Millisecond), t.InstanceAPI.Round(time.Millisecond),
+			t.MetricsAPI.Round(time.Millisecond), t.MetricPoints)
+	}
+}
+
+// reportShadow evaluates the candidate configuration against this cycle's
+// metrics and logs where its decisions would differ from the active ones
+func (r *autoscalingRunner) reportShadow(results *analyzer.ProjectAnalysisResult) {
+	evaluator, ok := r.analyzer.(shadowEvaluator)
+	if !ok || r.config.GetShadowConfig() == nil {
+		return
+	}
+
+	report := evaluator.EvaluateShadow(results)
+	if report == nil {
+		return
+	}
+	RecordShadowReport(report)
+	log.Printf("Shadow config: %d of %d instance decisions would differ", len(report.Differences), report.Evaluated)
+	for _, d := range report.Differences {
+		log.Printf("  shadow %s: active %s %s, candidate %s %s (%s)", d.Instance,
+			d.ActiveAction, d.ActiveTarget, d.CandidateAction, d.CandidateTarget, d.CandidateReason)
+	}
+}
+
+// applyFreezes defers operations covered by the freezes in effect, including
+// those set since the daemon started
+func (r *autoscalingRunner) applyFreezes(plan *analyzer.ScalingPlan, now time.Time) *analyzer.ScalingPlan {
+	if r.freezes == nil {
+		return plan
+	}
+
+	active := r.freezes.Active(now)
+	plan = plan.ApplyFreezes(active, r.config.GetProjectID(), r.config.GetFreezeEmergencyThreshold(), now)
+	RecordFreezes(active, plan.Frozen())
+	if len(active) > 0 {
+		log.Printf("%d scaling freeze(s) in effect; %d operation(s) frozen", len(active), plan.Frozen())
+	}
+	return plan
+}
+
+// awaitStability defers operations whose recommendation was not yet made
+// the configured number of consecutive cycles, except emergency scale-ups
+// and rollbacks
+func (r *autoscalingRunner) awaitStability(plan *analyzer.ScalingPlan) *analyzer.ScalingPlan {
+	cycles := r.config.GetStableCycles()
+	if cycles <= 1 {
+		return plan
+	}
+	return plan.AwaitStability(cycles, r.config.GetFreezeEmergencyThreshold())
+}
+
+// withoutHeld drops operations on instances pinned outside of autoscaling
+func (r *autoscalingRunner) withoutHeld(operations []analyzer.ScalingOperation) []analyzer.ScalingOperation {
+	if r.holds == nil {
+		return operations
+	}
+
+	kept := operations[:0]
+	for _, op := range operations {
+		if reason, held := r.heldReason(op.InstanceID); held {
+			log.Printf("Skipping scaling of %s: %s", op.Instance, reason)
+			continue
+		}
+		kept = append(kept, op)
+	}
+	return kept
+}
+
+// heldReason reports whether the instance with the fully qualified instanceID
+// is pinned outside of autoscaling
+func (r *autoscalingRunner) heldReason(instanceID string) (string, bool) {
+	if r.holds == nil {
+		return "", false
+	}
+	return r.holds.Held(instanceID)
 }
 
 // applyScalingDecisions applies scaling to instances that need it
-func (r *autoscalingRunner) applyScalingDecisions(ctx context.Context, instances []*analyzer.AnalysisResult) error {
+func (r *autoscalingRunner) applyScalingDecisions(ctx context.Context, operations []analyzer.ScalingOperation) error {
 	successCount := 0
 	var lastErr error
 
-	for _, result := range instances {
-		err := r.analyzer.ApplyScaling(ctx, result.Instance.Name, result.Decision)
+	for i, op := range operations {
+		if stopApplying(ctx, len(operations)-i) {
+			break
+		}
+		result := op.Result
+		r.phase.enter(PhaseApply, result.Instance.Name)
+		opCtx, cancel := detachApply(ctx)
+		err := r.analyzer.ApplyScaling(opCtx, result.Instance.Name, result.Decision)
+		r.completeApproval(op, err)
 		if err != nil {
-			log.Printf("Failed to scale instance %s: %v", result.Instance.Name, err)
+			log.Printf("Failed to scale instance %s: %v%s", result.Instance.Name, err, ownedBy(op))
+			r.publish(EventScalingFailed, result.Instance.Name, err.Error(), op)
+			failed := newChange(result)
+			failed.Error = err.Error()
+			r.notify(opCtx, notify.Message{Kind: notify.KindFailed, Changes: []notify.Change{failed}})
 			r.metrics.RecordError("scaling_failed")
 			lastErr = err
 		} else {
-			log.Printf("Successfully scaled instance %s from %s to %s",
-				result.Instance.Name, result.Decision.CurrentType, result.Decision.RecommendedType)
+			log.Printf("Successfully scaled instance %s from %s to %s%s",
+				result.Instance.Name, result.Decision.CurrentType, result.Decision.RecommendedType, ownedBy(op))
+			r.publish(EventScaled, result.Instance.Name, fmt.Sprintf("Scaled from %s to %s",
+				result.Decision.CurrentType, result.Decision.RecommendedType), op)
+			r.notify(opCtx, notify.Message{Kind: notify.KindApplied, Changes: []notify.Change{newChange(result)}})
 			successCount++
 		}
+		cancel()
 	}
 
-	log.Printf("Applied scaling to %d/%d instances", successCount, len(instances))
+	log.Printf("Applied scaling to %d/%d instances", successCount, len(operations))
 
 	// Return the last error if any scaling failed
 	// This follows Go's pattern of returning the most recent error
@@ -101,6 +400,131 @@ func (r *autoscalingRunner) applyScalingDecisions(ctx context.Context, instances
 	return nil
 }
 
+// stopApplying reports whether the cycle was aborted before the next of
+// remaining operations, which are then left for the next cycle
+func stopApplying(ctx context.Context, remaining int) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	log.Printf("Cycle aborted (%v); leaving %d operation(s) for the next cycle", ctx.Err(), remaining)
+	return true
+}
+
+// applyStorageIncreases grows the disks of results when the analyzer supports
+// it and storage scaling is enabled. Held instances are left alone and
+// increases wait out blackout windows and freezes.
+func (r *autoscalingRunner) applyStorageIncreases(ctx context.Context, results []*analyzer.AnalysisResult) error {
+	applier, ok := r.analyzer.(storageApplier)
+	if !ok || !applier.StorageScalingEnabled() || len(results) == 0 {
+		return nil
+	}
+
+	var freezes []config.Freeze
+	if r.freezes != nil {
+		freezes = r.freezes.Active(time.Now())
+	}
+
+	var lastErr error
+	for i, result := range results {
+		if stopApplying(ctx, len(results)-i) {
+			break
+		}
+		name, storage := result.Instance.Name, result.Storage
+		if reason, held := r.heldReason(result.Instance.ID()); held {
+			log.Printf("Skipping disk resize of %s: %s", name, reason)
+			continue
+		}
+		if reason, deferred := applier.StorageDeferral(result, freezes, time.Now()); deferred {
+			log.Printf("Deferred disk resize of %s (%d → %d GB): %s", name, storage.CurrentSizeGB, storage.RecommendedSizeGB, reason)
+			continue
+		}
+
+		r.phase.enter(PhaseApply, name)
+		opCtx, cancel := detachApply(ctx)
+		err := applier.ApplyStorage(opCtx, name, storage)
+		cancel()
+		if err != nil {
+			log.Printf("Failed to grow disk of instance %s: %v", name, err)
+			r.publish(EventStorageResizeFailed, name, err.Error(), storage)
+			r.metrics.RecordError("storage_resize_failed")
+			RecordStorageResize(result.Instance, "failed")
+			lastErr = err
+			continue
+		}
+		log.Printf("Grew disk of instance %s from %d GB to %d GB", name, storage.CurrentSizeGB, storage.RecommendedSizeGB)
+		r.publish(EventStorageResized, name, fmt.Sprintf("Grew disk from %d GB to %d GB",
+			storage.CurrentSizeGB, storage.RecommendedSizeGB), storage)
+		RecordStorageResize(result.Instance, "success")
+	}
+
+	if lastErr != nil {
+		return WrapError("apply_storage", lastErr)
+	}
+	return nil
+}
+
+// applyReplicaChanges adds and removes read replicas as planned, when the
+// analyzer supports it and replica scaling is enabled. Held primaries and
+// changes inside a blackout or freeze are skipped; a failed change does not
+// stop the others.
+func (r *autoscalingRunner) applyReplicaChanges(ctx context.Context, changes []analyzer.ReplicaChange) error {
+	applier, ok := r.analyzer.(replicaApplier)
+	if !ok || !applier.ReplicaScalingEnabled() || len(changes) == 0 {
+		return nil
+	}
+
+	var freezes []config.Freeze
+	if r.freezes != nil {
+		freezes = r.freezes.Active(time.Now())
+	}
+
+	var lastErr error
+	for i, change := range changes {
+		if stopApplying(ctx, len(changes)-i) {
+			break
+		}
+		decision := change.Decision
+		name, kind := decision.Primary, "add"
+		if decision.Change < 0 {
+			kind = "remove"
+		}
+		if reason, held := r.heldReason(change.Primary.ID()); held {
+			log.Printf("Skipping read replica change of %s: %s", name, reason)
+			continue
+		}
+		if reason, deferred := applier.ReplicaDeferral(change, freezes, time.Now()); deferred {
+			log.Printf("Deferred read replica change of %s (%d → %d): %s", name, decision.CurrentCount, decision.RecommendedCount, reason)
+			continue
+		}
+
+		r.phase.enter(PhaseApply, name)
+		opCtx, cancel := detachApply(ctx)
+		err := applier.ApplyReplicaChange(opCtx, decision)
+		cancel()
+		if err != nil {
+			log.Printf("Failed to change read replicas of %s: %v", name, err)
+			r.publish(EventReplicaChangeFailed, name, err.Error(), decision)
+			r.metrics.RecordError("replica_change_failed")
+			RecordReplicaChange(change.Primary, kind, "failed")
+			lastErr = err
+			continue
+		}
+		if decision.Change > 0 {
+			log.Printf("Added read replica %s to %s", cloudsql.ReplicaName(decision), name)
+			r.publish(EventReplicaCreated, name, "Added read replica "+cloudsql.ReplicaName(decision), decision)
+		} else {
+			log.Printf("Removed read replica %s from %s", decision.RemoveReplica, name)
+			r.publish(EventReplicaDeleted, name, "Removed read replica "+decision.RemoveReplica, decision)
+		}
+		RecordReplicaChange(change.Primary, kind, "success")
+	}
+
+	if lastErr != nil {
+		return WrapError("apply_replicas", lastErr)
+	}
+	return nil
+}
+
 // simpleMetricsReporter provides a no-op implementation when metrics are disabled
 type simpleMetricsReporter struct{}
 
@@ -147,3 +571,12 @@ func (r *prometheusMetricsReporter) RecordInstanceCounts(total, analyzed, scalab
 func NewPrometheusMetricsReporter() MetricsReporter {
 	return &prometheusMetricsReporter{}
 }
+
+// ownedBy names the operation's owner in log lines, so log-based alerting can
+// route them to the owning team
+func ownedBy(op analyzer.ScalingOperation) string {
+	if op.Owner == nil {
+		return ""
+	}
+	return fmt.Sprintf(" [owner: %s]", op.Owner)
+}
@@ -0,0 +1,65 @@
+package daemon
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// CycleUsage is the daemon's own resource usage over one cycle, for sizing
+// the controller as the fleet it manages grows
+type CycleUsage struct {
+	Started        time.Time     `json:"started"`
+	Duration       time.Duration `json:"duration"`
+	Instances      int           `json:"instances"`          // Instances the cycle analyzed
+	CPUTime        time.Duration `json:"cpu_time,omitempty"` // User and system CPU time of the process; zero where unsupported
+	AllocatedBytes uint64        `json:"allocated_bytes"`    // Heap allocated during the cycle
+	GCRuns         uint32        `json:"gc_runs"`
+	GCPause        time.Duration `json:"gc_pause"`         // Total stop-the-world pause of the cycle's GC runs
+	HeapInUseBytes uint64        `json:"heap_inuse_bytes"` // Heap in use when the cycle ended
+	SysBytes       uint64        `json:"sys_bytes"`        // Memory obtained from the OS when the cycle ended
+	Goroutines     int           `json:"goroutines"`       // Goroutines when the cycle ended
+}
+
+// usageSnapshot is the process's cumulative resource counters at one moment
+type usageSnapshot struct {
+	at  time.Time
+	cpu time.Duration
+	mem runtime.MemStats
+}
+
+// takeUsage reads the process's cumulative resource counters
+func takeUsage() usageSnapshot {
+	s := usageSnapshot{at: time.Now(), cpu: processCPUTime()}
+	runtime.ReadMemStats(&s.mem)
+	return s
+}
+
+// cycleUsage returns the usage between the snapshots taken before and after
+// a cycle that analyzed instances
+func cycleUsage(before, after usageSnapshot, instances int) CycleUsage {
+	return CycleUsage{
+		Started:        before.at,
+		Duration:       after.at.Sub(before.at),
+		Instances:      instances,
+		CPUTime:        after.cpu - before.cpu,
+		AllocatedBytes: after.mem.TotalAlloc - before.mem.TotalAlloc,
+		GCRuns:         after.mem.NumGC - before.mem.NumGC,
+		GCPause:        time.Duration(after.mem.PauseTotalNs - before.mem.PauseTotalNs),
+		HeapInUseBytes: after.mem.HeapInuse,
+		SysBytes:       after.mem.Sys,
+		Goroutines:     runtime.NumGoroutine(),
+	}
+}
+
+// String summarizes the usage for the daemon log
+func (u CycleUsage) String() string {
+	return fmt.Sprintf("Cycle resource usage: %v CPU, %.1f MiB allocated over %d instances, %d GC runs (%v paused); heap %.1f MiB, %.1f MiB from the OS, %d goroutines",
+		u.CPUTime.Round(time.Millisecond), mib(u.AllocatedBytes), u.Instances, u.GCRuns, u.GCPause.Round(time.Microsecond),
+		mib(u.HeapInUseBytes), mib(u.SysBytes), u.Goroutines)
+}
+
+// mib converts bytes to mebibytes
+func mib(b uint64) float64 {
+	return float64(b) / (1 << 20)
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package daemon
+
+import "time"
+
+// processCPUTime returns zero: process CPU time is only read on Unix
+func processCPUTime() time.Duration {
+	return 0
+}
@@ -0,0 +1,195 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Phases of an autoscaling cycle, reported when a cycle overruns its deadline
+const (
+	PhaseAnalyze = "analyze" // Reading instances and metrics
+	PhasePlan    = "plan"    // Planning, freezes and holds
+	PhaseApply   = "apply"   // Applying scaling operations
+	PhaseUnknown = "unknown" // The runner does not report its phase
+)
+
+// watchdogGrace is how long an aborted cycle has to stop before the watchdog
+// abandons it
+const watchdogGrace = 30 * time.Second
+
+// applyTimeout bounds each operation of the apply phase. Operations are not
+// cut off by the cycle deadline or shutdown, which would leave a resize
+// running that nobody waits for or is told about; a cycle aborted while
+// applying stops once the operation in flight completes.
+const applyTimeout = 2 * time.Hour
+
+// detachApply returns the context an apply-phase operation and the
+// notifications of its outcome run with: ctx's values without its
+// cancellation, bounded by applyTimeout
+func detachApply(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), applyTimeout)
+}
+
+// CyclePhase is the part of a cycle being worked on and since when
+type CyclePhase struct {
+	Name     string    `json:"name"`
+	Instance string    `json:"instance,omitempty"` // Instance being changed, during apply
+	Since    time.Time `json:"since"`
+}
+
+// phaseReporter is implemented by cycle runners that track their phase
+type phaseReporter interface {
+	CurrentPhase() CyclePhase
+}
+
+// phaseTracker records the current phase of a cycle
+type phaseTracker struct {
+	mu    sync.Mutex
+	phase CyclePhase
+}
+
+// enter records that the cycle moved to phase, working on instance if any
+func (t *phaseTracker) enter(phase, instance string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.phase = CyclePhase{Name: phase, Instance: instance, Since: time.Now()}
+}
+
+// CurrentPhase returns the phase last entered; after a cycle ends, the phase
+// it ended in
+func (t *phaseTracker) CurrentPhase() CyclePhase {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.phase
+}
+
+// CycleTimeout describes a cycle the watchdog aborted for overrunning its
+// deadline
+type CycleTimeout struct {
+	Started   time.Time     `json:"started"`
+	Deadline  time.Duration `json:"deadline"`
+	Phase     CyclePhase    `json:"phase"`               // Phase the cycle was stuck in
+	Abandoned bool          `json:"abandoned,omitempty"` // The cycle did not stop within the grace period after being aborted
+}
+
+// String describes the timeout for logs and events
+func (t CycleTimeout) String() string {
+	stuck := t.Phase.Name
+	if t.Phase.Instance != "" {
+		stuck += " of " + t.Phase.Instance
+	}
+	if !t.Phase.Since.IsZero() {
+		stuck += fmt.Sprintf(" for %v", time.Since(t.Phase.Since).Round(time.Second))
+	}
+	msg := fmt.Sprintf("Autoscaling cycle exceeded its %v deadline, stuck in %s", t.Deadline, stuck)
+	if t.Abandoned {
+		grace := watchdogGrace
+		if t.Phase.Name == PhaseApply {
+			grace = applyTimeout
+		}
+		msg += fmt.Sprintf("; it did not stop within %v of being aborted and was abandoned", grace)
+	}
+	return msg
+}
+
+// abandonedCycle is a cycle the watchdog stopped waiting for
+type abandonedCycle struct {
+	started time.Time
+	done    <-chan error // Receives once its RunCycle returns
+}
+
+// errCycleStillRunning reports a cycle skipped because an abandoned one has
+// not returned yet
+var errCycleStillRunning = errors.New("the abandoned cycle is still running")
+
+// runWithWatchdog runs one cycle, aborting it once it exceeds the daemon's
+// cycle deadline. A cycle stuck in a call that ignores cancellation is given
+// watchdogGrace to stop and then abandoned, so the loop does not block on
+// it; one aborted while applying is given until its operation in flight
+// completes, up to applyTimeout. Cycles are skipped until an abandoned one returns: the runner's state
+// belongs to one cycle at a time.
+func (d *Daemon) runWithWatchdog() error {
+	if d.abandoned != nil {
+		select {
+		case <-d.abandoned.done:
+			d.abandoned = nil
+		default:
+			RecordError("cycle_skipped")
+			return WrapError("cycle_deadline", fmt.Errorf("skipped: %w, started at %s",
+				errCycleStillRunning, d.abandoned.started.Format(time.RFC3339)))
+		}
+	}
+	if d.cycleDeadline <= 0 {
+		return d.runner.RunCycle(d.ctx)
+	}
+
+	started := time.Now()
+	ctx, cancel := context.WithTimeout(d.ctx, d.cycleDeadline)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- d.runner.RunCycle(ctx) }()
+
+	var err error
+	finished := false
+	select {
+	case err = <-done:
+		finished = true
+	case <-ctx.Done():
+	}
+
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) || d.ctx.Err() != nil {
+		if finished {
+			return err
+		}
+		// Shutting down, not timed out
+		return waitCycle(done, watchdogGrace)
+	}
+
+	timeout := CycleTimeout{Started: started, Deadline: d.cycleDeadline, Phase: CyclePhase{Name: PhaseUnknown}}
+	if pr, ok := d.runner.(phaseReporter); ok {
+		timeout.Phase = pr.CurrentPhase()
+	}
+	if !finished {
+		grace := watchdogGrace
+		if timeout.Phase.Name == PhaseApply {
+			log.Printf("Autoscaling cycle exceeded its %v deadline while applying to %s; waiting for the operation to complete",
+				d.cycleDeadline, timeout.Phase.Instance)
+			grace = applyTimeout
+		}
+		if err = waitCycle(done, grace); errors.Is(err, errCycleAbandoned) {
+			timeout.Abandoned = true
+			d.abandoned = &abandonedCycle{started: started, done: done}
+		}
+	}
+	d.reportTimeout(timeout)
+	return WrapError("cycle_deadline", fmt.Errorf("aborted in phase %s: %w", timeout.Phase.Name, context.DeadlineExceeded))
+}
+
+// errCycleAbandoned reports a cycle that did not stop within its grace period
+var errCycleAbandoned = errors.New("cycle did not stop after being aborted")
+
+// waitCycle waits up to grace for an aborted cycle to return
+func waitCycle(done <-chan error, grace time.Duration) error {
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(grace):
+		return errCycleAbandoned
+	}
+}
+
+// reportTimeout logs, records and publishes a cycle timeout
+func (d *Daemon) reportTimeout(timeout CycleTimeout) {
+	log.Print(timeout)
+	RecordCycleTimeout(timeout.Phase.Name)
+	d.events.Publish(EventCycleTimedOut, timeout.Phase.Instance, timeout.String(), timeout)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lastTimeout = &timeout
+}
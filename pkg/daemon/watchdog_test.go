@@ -0,0 +1,213 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/analyzer"
+)
+
+// noResults is the part of a CycleRunner the watchdog does not use
+type noResults struct{}
+
+func (noResults) ResumeOperations(ctx context.Context) error {
+	return nil
+}
+
+func (noResults) LastResults() *analyzer.ProjectAnalysisResult {
+	return nil
+}
+
+// stubRunner runs cycles with run, reporting the phase it enters
+type stubRunner struct {
+	noResults
+	phaseTracker
+	run func(ctx context.Context, phase *phaseTracker) error
+}
+
+func (r *stubRunner) RunCycle(ctx context.Context) error {
+	return r.run(ctx, &r.phaseTracker)
+}
+
+// unreportedRunner runs cycles with run without reporting its phase
+type unreportedRunner struct {
+	noResults
+	run func(ctx context.Context) error
+}
+
+func (r unreportedRunner) RunCycle(ctx context.Context) error {
+	return r.run(ctx)
+}
+
+// watchdogDaemon returns a daemon running cycles with runner under deadline
+func watchdogDaemon(t *testing.T, runner CycleRunner, deadline time.Duration) *Daemon {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return &Daemon{runner: runner, cycleDeadline: deadline, events: newEventBroker(), ctx: ctx, cancel: cancel}
+}
+
+// TestWatchdog checks that cycles finishing within the deadline are left
+// alone and that overrunning ones are aborted and reported with the phase
+// they were stuck in
+func TestWatchdog(t *testing.T) {
+	errCycle := errors.New("listing instances failed")
+	untilAborted := func(phase string) func(ctx context.Context, p *phaseTracker) error {
+		return func(ctx context.Context, p *phaseTracker) error {
+			p.enter(phase, "")
+			<-ctx.Done()
+			return ctx.Err()
+		}
+	}
+
+	tests := []struct {
+		name      string
+		runner    CycleRunner
+		deadline  time.Duration
+		wantErr   error
+		wantPhase string // Phase the timeout is reported in; empty when the cycle did not time out
+	}{
+		{
+			name:     "finishes in time",
+			runner:   &stubRunner{run: func(ctx context.Context, p *phaseTracker) error { p.enter(PhaseAnalyze, ""); return nil }},
+			deadline: time.Minute,
+		},
+		{
+			name:     "fails in time",
+			runner:   &stubRunner{run: func(ctx context.Context, p *phaseTracker) error { return errCycle }},
+			deadline: time.Minute,
+			wantErr:  errCycle,
+		},
+		{
+			name: "watchdog disabled",
+			runner: unreportedRunner{run: func(ctx context.Context) error {
+				if _, ok := ctx.Deadline(); ok {
+					return errors.New("cycle has a deadline")
+				}
+				return nil
+			}},
+		},
+		{
+			name:      "stuck analyzing",
+			runner:    &stubRunner{run: untilAborted(PhaseAnalyze)},
+			deadline:  20 * time.Millisecond,
+			wantErr:   context.DeadlineExceeded,
+			wantPhase: PhaseAnalyze,
+		},
+		{
+			name:      "stuck planning",
+			runner:    &stubRunner{run: untilAborted(PhasePlan)},
+			deadline:  20 * time.Millisecond,
+			wantErr:   context.DeadlineExceeded,
+			wantPhase: PhasePlan,
+		},
+		{
+			name: "runner without phases",
+			runner: unreportedRunner{run: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			}},
+			deadline:  20 * time.Millisecond,
+			wantErr:   context.DeadlineExceeded,
+			wantPhase: PhaseUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := watchdogDaemon(t, tt.runner, tt.deadline)
+			err := d.runWithWatchdog()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("runWithWatchdog = %v, want %v", err, tt.wantErr)
+			}
+
+			timeout := d.lastTimeout
+			if tt.wantPhase == "" {
+				if timeout != nil {
+					t.Errorf("timeout reported: %v", timeout)
+				}
+				return
+			}
+			if timeout == nil {
+				t.Fatal("no timeout reported")
+			}
+			if timeout.Phase.Name != tt.wantPhase || timeout.Deadline != tt.deadline || timeout.Abandoned {
+				t.Errorf("timeout = %+v, want one in phase %s after %v that was not abandoned", timeout, tt.wantPhase, tt.deadline)
+			}
+			if events := d.events.history; len(events) != 1 || events[0].Type != EventCycleTimedOut {
+				t.Errorf("events = %+v, want one %s", events, EventCycleTimedOut)
+			}
+		})
+	}
+}
+
+// TestWatchdogShutdown checks that a cycle cut short by shutdown is not
+// reported as timed out
+func TestWatchdogShutdown(t *testing.T) {
+	var d *Daemon
+	d = watchdogDaemon(t, unreportedRunner{run: func(ctx context.Context) error {
+		d.cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	}}, time.Minute)
+
+	if err := d.runWithWatchdog(); !errors.Is(err, context.Canceled) {
+		t.Errorf("runWithWatchdog = %v, want %v", err, context.Canceled)
+	}
+	if d.lastTimeout != nil {
+		t.Errorf("timeout reported on shutdown: %v", d.lastTimeout)
+	}
+}
+
+// TestWatchdogSkipsWhileAbandoned checks that no cycle starts while an
+// abandoned one is still running, and that cycles resume once it returns
+func TestWatchdogSkipsWhileAbandoned(t *testing.T) {
+	runs := 0
+	d := watchdogDaemon(t, unreportedRunner{run: func(ctx context.Context) error {
+		runs++
+		return nil
+	}}, time.Minute)
+	done := make(chan error, 1)
+	d.abandoned = &abandonedCycle{started: time.Now().Add(-time.Hour), done: done}
+
+	if err := d.runWithWatchdog(); !errors.Is(err, errCycleStillRunning) || runs != 0 {
+		t.Fatalf("runWithWatchdog = %v after %d runs, want %v without running", err, runs, errCycleStillRunning)
+	}
+
+	done <- context.DeadlineExceeded
+	if err := d.runWithWatchdog(); err != nil || runs != 1 {
+		t.Fatalf("runWithWatchdog = %v after %d runs, want a run once the abandoned cycle returned", err, runs)
+	}
+	if d.abandoned != nil {
+		t.Error("abandoned cycle kept after it returned")
+	}
+}
+
+// TestCycleTimeoutString checks how timeouts are described
+func TestCycleTimeoutString(t *testing.T) {
+	tests := []struct {
+		timeout CycleTimeout
+		want    string
+	}{
+		{
+			CycleTimeout{Deadline: 15 * time.Minute, Phase: CyclePhase{Name: PhaseAnalyze}},
+			"Autoscaling cycle exceeded its 15m0s deadline, stuck in analyze",
+		},
+		{
+			CycleTimeout{Deadline: 15 * time.Minute, Phase: CyclePhase{Name: PhasePlan}, Abandoned: true},
+			"Autoscaling cycle exceeded its 15m0s deadline, stuck in plan; it did not stop within 30s of being aborted and was abandoned",
+		},
+		{
+			CycleTimeout{Deadline: 15 * time.Minute, Phase: CyclePhase{Name: PhaseApply, Instance: "orders-db"}, Abandoned: true},
+			"Autoscaling cycle exceeded its 15m0s deadline, stuck in apply of orders-db; it did not stop within 2h0m0s of being aborted and was abandoned",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.timeout.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+// Package datadog ships the daemon's metrics and scaling events to Datadog,
+// for teams whose observability lives there rather than in Prometheus.
+package datadog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/secrets"
+)
+
+// DefaultSite is the Datadog site used when none is configured
+const DefaultSite = "datadoghq.com"
+
+// requestTimeout bounds a Datadog API call, so a slow intake never holds up a cycle for long
+const requestTimeout = 10 * time.Second
+
+// Client submits metrics and events to the Datadog API of one site
+type Client struct {
+	apiKey *secrets.Secret // Re-read on refresh, so rotated keys are picked up
+	site   string
+	tags   []string // Added to every metric and event, e.g. env:prod
+	client *http.Client
+}
+
+// NewClient creates a Datadog client for site (e.g. datadoghq.eu; empty for
+// DefaultSite) that tags everything it sends with tags
+func NewClient(apiKey *secrets.Secret, site string, tags []string) *Client {
+	if site == "" {
+		site = DefaultSite
+	}
+	return &Client{
+		apiKey: apiKey,
+		site:   site,
+		tags:   tags,
+		client: &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// ValidateTag checks that tag is a Datadog tag: a KEY:VALUE pair or a bare
+// value, starting with a letter
+func ValidateTag(tag string) error {
+	if tag == "" || !(tag[0] >= 'a' && tag[0] <= 'z' || tag[0] >= 'A' && tag[0] <= 'Z') {
+		return fmt.Errorf("invalid Datadog tag %q (must start with a letter)", tag)
+	}
+	if len(tag) > 200 {
+		return fmt.Errorf("invalid Datadog tag %q (longer than 200 characters)", tag)
+	}
+	return nil
+}
+
+// post sends body as JSON to the API path
+func (c *Client) post(ctx context.Context, path string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode Datadog request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api."+c.site+path, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create Datadog request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("DD-API-KEY", c.apiKey.Value())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call Datadog %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to call Datadog %s: %s: %s", path, resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// withTags returns the client's tags followed by extra
+func (c *Client) withTags(extra ...string) []string {
+	return append(append([]string(nil), c.tags...), extra...)
+}
@@ -0,0 +1,122 @@
+package datadog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/notify"
+)
+
+// event is a v1 event submission
+type event struct {
+	Title          string   `json:"title"`
+	Text           string   `json:"text"`
+	Tags           []string `json:"tags,omitempty"`
+	AlertType      string   `json:"alert_type"` // info, success, warning or error
+	AggregationKey string   `json:"aggregation_key,omitempty"`
+	SourceTypeName string   `json:"source_type_name,omitempty"`
+}
+
+// Events posts the daemon's notifications as Datadog events, one per
+// instance so they can be filtered and overlaid by instance tag
+type Events struct {
+	client   *Client
+	currency config.Currency
+}
+
+// NewEvents creates a notifier posting events through client, with savings
+// estimates formatted in currency
+func NewEvents(client *Client, currency config.Currency) *Events {
+	return &Events{client: client, currency: currency}
+}
+
+// Notify posts an event for each change in m, or for the failed cycle
+func (e *Events) Notify(ctx context.Context, m notify.Message) error {
+	project := "project:" + m.Project
+	if m.Kind == notify.KindCycleFailed {
+		return e.client.post(ctx, "/api/v1/events", event{
+			Title:          "Autoscaling cycle failed in " + m.Project,
+			Text:           m.Error,
+			Tags:           e.client.withTags(project, "event:"+string(m.Kind)),
+			AlertType:      "error",
+			AggregationKey: m.Project,
+			SourceTypeName: "cloudsql-autoscaler",
+		})
+	}
+
+	for _, c := range m.Changes {
+		ev := event{
+			Text:           e.text(c, !m.CostsRedacted),
+			Tags:           e.client.withTags(project, "instance:"+c.Instance, "event:"+string(m.Kind)),
+			AggregationKey: m.Project + "/" + c.Instance,
+			SourceTypeName: "cloudsql-autoscaler",
+		}
+		if c.InstanceID != "" {
+			ev.Tags = append(ev.Tags, "instance_id:"+c.InstanceID)
+		}
+		if c.Owner != nil && c.Owner.Team != "" {
+			ev.Tags = append(ev.Tags, "team:"+c.Owner.Team)
+		}
+		switch m.Kind {
+		case notify.KindRecommendations:
+			ev.Title = fmt.Sprintf("Recommend scaling %s from %s to %s", c.Instance, c.CurrentType, c.TargetType)
+			ev.AlertType = "info"
+			if m.DryRun {
+				ev.Tags = append(ev.Tags, "dry_run:true")
+			}
+		case notify.KindApplied:
+			ev.Title = fmt.Sprintf("Scaled %s from %s to %s", c.Instance, c.CurrentType, c.TargetType)
+			ev.AlertType = "success"
+		case notify.KindFailed:
+			ev.Title = fmt.Sprintf("Failed to scale %s from %s to %s", c.Instance, c.CurrentType, c.TargetType)
+			ev.AlertType = "error"
+		case notify.KindOverloaded:
+			ev.Title = fmt.Sprintf("%s above the scale-up thresholds for %d cycles", c.Instance, m.Cycles)
+			ev.AlertType = "warning"
+		case notify.KindOverloadEnded:
+			ev.Title = c.Instance + " is back below the scale-up thresholds"
+			ev.AlertType = "success"
+		default:
+			continue
+		}
+		if err := e.client.post(ctx, "/api/v1/events", ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// text describes a change in an event body, with its savings when
+// withSavings is set
+func (e *Events) text(c notify.Change, withSavings bool) string {
+	lines := []string{
+		c.Reason,
+		fmt.Sprintf("CPU P95: %.1f%%, memory P95: %.1f%%", c.CPUP95, c.MemoryP95Pct),
+	}
+	switch {
+	case !withSavings:
+	case c.EstimatedSavings < 0:
+		lines = append(lines, "Cost increase: "+e.currency.Format(-c.EstimatedSavings)+"/month")
+	default:
+		lines = append(lines, "Savings: "+e.currency.Format(c.EstimatedSavings)+"/month")
+	}
+	if c.DowntimeExpected {
+		lines = append(lines, "Downtime expected")
+	}
+	if c.Owner != nil && !c.Owner.IsZero() {
+		lines = append(lines, "Owner: "+c.Owner.String())
+	}
+	if c.Error != "" {
+		lines = append(lines, "Error: "+c.Error)
+	}
+	if len(c.ReasonCodes) > 0 {
+		codes := make([]string, len(c.ReasonCodes))
+		for i, code := range c.ReasonCodes {
+			codes[i] = string(code)
+		}
+		lines = append(lines, "Reason codes: "+strings.Join(codes, ", "))
+	}
+	return strings.Join(lines, "\n")
+}
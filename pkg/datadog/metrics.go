@@ -0,0 +1,102 @@
+package datadog
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// metricPrefix selects the autoscaler's own Prometheus metrics; Go runtime and
+// process metrics are left to the Datadog agent
+const metricPrefix = "cloudsql_autoscaler_"
+
+// series is a metric series in a v1 series submission
+type series struct {
+	Metric   string       `json:"metric"`
+	Type     string       `json:"type"` // gauge or count
+	Points   [][2]float64 `json:"points"`
+	Interval int64        `json:"interval,omitempty"` // Seconds a count covers
+	Tags     []string     `json:"tags,omitempty"`
+}
+
+// Exporter forwards the autoscaler's Prometheus metrics to Datadog, so both
+// stacks see the same metrics. Gauges are sent as gauges, and counters as
+// counts of their increase since the last export.
+type Exporter struct {
+	client   *Client
+	gatherer prometheus.Gatherer
+
+	mu       sync.Mutex
+	counters map[string]float64 // Counter values at the last export, by series
+	lastAt   time.Time
+}
+
+// NewExporter creates an exporter of the metrics gatherer collects
+func NewExporter(client *Client, gatherer prometheus.Gatherer) *Exporter {
+	return &Exporter{client: client, gatherer: gatherer, counters: make(map[string]float64)}
+}
+
+// Export submits the current value of every autoscaler metric, timestamped now
+func (e *Exporter) Export(ctx context.Context, now time.Time) error {
+	families, err := e.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	e.mu.Lock()
+	var interval int64
+	if !e.lastAt.IsZero() {
+		interval = int64(now.Sub(e.lastAt).Seconds())
+	}
+	var out []series
+	for _, family := range families {
+		if !strings.HasPrefix(family.GetName(), metricPrefix) {
+			continue
+		}
+		name := "cloudsql_autoscaler." + strings.TrimPrefix(family.GetName(), metricPrefix)
+		for _, m := range family.GetMetric() {
+			tags := metricTags(m)
+			s := series{Metric: name, Type: "gauge", Tags: e.client.withTags(tags...)}
+			switch family.GetType() {
+			case dto.MetricType_GAUGE:
+				s.Points = [][2]float64{{float64(now.Unix()), m.GetGauge().GetValue()}}
+			case dto.MetricType_COUNTER:
+				key := name + "|" + strings.Join(tags, ",")
+				value := m.GetCounter().GetValue()
+				increase := value - e.counters[key]
+				if increase < 0 {
+					increase = value // The counter was reset
+				}
+				e.counters[key] = value
+				s.Type, s.Interval = "count", interval
+				s.Points = [][2]float64{{float64(now.Unix()), increase}}
+			default:
+				continue
+			}
+			out = append(out, s)
+		}
+	}
+	e.lastAt = now
+	e.mu.Unlock()
+
+	if len(out) == 0 {
+		return nil
+	}
+	return e.client.post(ctx, "/api/v1/series", map[string]interface{}{"series": out})
+}
+
+// metricTags converts a metric's labels to Datadog tags, in label order
+func metricTags(m *dto.Metric) []string {
+	tags := make([]string, 0, len(m.GetLabel()))
+	for _, l := range m.GetLabel() {
+		tags = append(tags, l.GetName()+":"+l.GetValue())
+	}
+	sort.Strings(tags)
+	return tags
+}
@@ -0,0 +1,219 @@
+// Package decisionhook sends each resize the autoscaler proposes to an
+// external endpoint that has the final say over it. The endpoint allows the
+// proposal, denies it or modifies its target machine type, for example to
+// cap the tier a team may scale to, so an organization can centralize that
+// policy without changing the engine.
+//
+// Requests are POSTed as the JSON encoding of Request. With a shared key,
+// each request carries TimestampHeader and SignatureHeader, and the endpoint
+// must sign its response the same way and echo the request's ID, so neither
+// side acts on a forged or replayed message. A signature is "sha256=" and the
+// hex HMAC-SHA256, keyed with the shared key, of the timestamp, a period and
+// the body; see Sign.
+package decisionhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/secrets"
+)
+
+// Headers signed requests and responses carry
+const (
+	TimestampHeader = "X-Autoscaler-Timestamp" // Unix time the message was signed at
+	SignatureHeader = "X-Autoscaler-Signature" // sha256=HEX, see Sign
+)
+
+const (
+	// DefaultTimeout bounds a review when no timeout is configured
+	DefaultTimeout = 5 * time.Second
+
+	// maxSkew is how far a signed response's timestamp may be from now
+	maxSkew = 5 * time.Minute
+
+	// maxResponseSize bounds the response body read
+	maxResponseSize = 64 << 10
+)
+
+// Action is the endpoint's verdict on a proposal
+type Action string
+
+const (
+	ActionAllow  Action = "allow"  // Apply the proposal as it is
+	ActionDeny   Action = "deny"   // Hold the instance at its current machine type
+	ActionModify Action = "modify" // Resize to Response.TargetType instead
+)
+
+// Request is a proposed resize sent for review
+type Request struct {
+	ID               string                `json:"id"` // Random; a signed response must echo it
+	Project          string                `json:"project"`
+	Instance         string                `json:"instance"`
+	Region           string                `json:"region"`
+	Edition          config.Edition        `json:"edition"`
+	DatabaseVersion  string                `json:"database_version"`
+	Labels           map[string]string     `json:"labels,omitempty"`
+	Owner            *config.Owner         `json:"owner,omitempty"`
+	CurrentType      string                `json:"current_type"`
+	TargetType       string                `json:"target_type"`
+	ScaleUp          bool                  `json:"scale_up"`
+	Reason           string                `json:"reason"`
+	ReasonCodes      []cloudsql.ReasonCode `json:"reason_codes,omitempty"`
+	CPUP95           float64               `json:"cpu_p95"`
+	MemoryP95Pct     float64               `json:"memory_p95_pct"`
+	EstimatedSavings float64               `json:"estimated_savings"` // Monthly, in USD
+	DowntimeExpected bool                  `json:"downtime_expected"`
+	DryRun           bool                  `json:"dry_run"`
+	Time             time.Time             `json:"time"`
+}
+
+// Response is the endpoint's verdict
+type Response struct {
+	RequestID  string `json:"request_id,omitempty"` // Required when responses are signed
+	Action     Action `json:"action"`
+	TargetType string `json:"target_type,omitempty"` // Required with ActionModify
+	Reason     string `json:"reason,omitempty"`      // Reported in the decision's reason
+}
+
+// Client posts proposals to a decision endpoint. It is safe for concurrent use.
+type Client struct {
+	url    *secrets.Secret
+	key    *secrets.Secret // Empty literal when messages are unsigned
+	client *http.Client
+}
+
+// New creates a client posting to url, signing with key when it is set, and
+// giving up on a review after timeout (DefaultTimeout if not positive)
+func New(url, key *secrets.Secret, timeout time.Duration) *Client {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Client{url: url, key: key, client: &http.Client{Timeout: timeout}}
+}
+
+// Open resolves url and key, either of which may be a secrets reference,
+// and creates a client for them
+func Open(ctx context.Context, url, key string, timeout time.Duration) (*Client, error) {
+	resolvedURL, err := secrets.Resolve(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve decision hook URL: %w", err)
+	}
+	resolvedKey, err := secrets.Resolve(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve decision hook secret: %w", err)
+	}
+	return New(resolvedURL, resolvedKey, timeout), nil
+}
+
+// Secrets returns the URL and key, for refreshing when they are references
+func (c *Client) Secrets() []*secrets.Secret {
+	return []*secrets.Secret{c.url, c.key}
+}
+
+// Review posts req and returns the endpoint's verdict. It fails when the
+// endpoint cannot be reached within the timeout, answers with anything but
+// a 2xx status and a valid verdict, or, with a key, signs its response
+// badly, too long ago or for another request. The verdict is returned as
+// sent; checking a modified target is up to the caller.
+func (c *Client) Review(ctx context.Context, req Request) (*Response, error) {
+	if req.ID == "" {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return nil, fmt.Errorf("failed to generate decision hook request ID: %w", err)
+		}
+		req.ID = hex.EncodeToString(id)
+	}
+	if req.Time.IsZero() {
+		req.Time = time.Now().UTC()
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode decision hook request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url.Value(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create decision hook request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	key := c.key.Value()
+	if key != "" {
+		timestamp := time.Now().Unix()
+		httpReq.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+		httpReq.Header.Set(SignatureHeader, Sign(key, timestamp, body))
+	}
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach decision hook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read decision hook response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("decision hook returned HTTP %d", resp.StatusCode)
+	}
+	if key != "" {
+		if err := verify(key, resp.Header, respBody, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	var verdict Response
+	if err := json.Unmarshal(respBody, &verdict); err != nil {
+		return nil, fmt.Errorf("invalid decision hook response: %w", err)
+	}
+	if key != "" && verdict.RequestID != req.ID {
+		return nil, fmt.Errorf("invalid decision hook response: request_id %q does not match the request", verdict.RequestID)
+	}
+	switch verdict.Action {
+	case ActionAllow, ActionDeny:
+	case ActionModify:
+		if verdict.TargetType == "" {
+			return nil, fmt.Errorf("invalid decision hook response: %s without a target_type", ActionModify)
+		}
+	default:
+		return nil, fmt.Errorf("invalid decision hook response: unknown action %q (must be allow, deny or modify)", verdict.Action)
+	}
+	return &verdict, nil
+}
+
+// Sign returns the signature of a message with body signed at timestamp:
+// "sha256=" and the hex HMAC-SHA256 of "TIMESTAMP.BODY" keyed with key
+func Sign(key string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify checks that a response was signed with key within maxSkew of now
+func verify(key string, header http.Header, body []byte, now time.Time) error {
+	timestamp, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid decision hook response: missing or malformed %s header", TimestampHeader)
+	}
+	if skew := now.Sub(time.Unix(timestamp, 0)); skew > maxSkew || skew < -maxSkew {
+		return fmt.Errorf("invalid decision hook response: signed %v from now, more than %v", skew.Round(time.Second), maxSkew)
+	}
+	if !hmac.Equal([]byte(header.Get(SignatureHeader)), []byte(Sign(key, timestamp, body))) {
+		return fmt.Errorf("invalid decision hook response: %s does not match", SignatureHeader)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package decisionhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/decisionhook"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/secrets"
+)
+
+const hookKey = "hook-key"
+
+// TestSign checks signatures against a known HMAC-SHA256 and that they
+// depend on the key, timestamp and body
+func TestSign(t *testing.T) {
+	body := []byte(`{"action":"allow"}`)
+	const timestamp = 1767225600
+	want := "sha256=92798823b05caeaad374255bc50b13fc68eefe325181ce4f3e12b7eedc39a9ba"
+	if got := decisionhook.Sign(hookKey, timestamp, body); got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+
+	tests := []struct {
+		name      string
+		key       string
+		timestamp int64
+		body      string
+	}{
+		{"other key", "other-key", timestamp, string(body)},
+		{"other timestamp", hookKey, timestamp + 1, string(body)},
+		{"other body", hookKey, timestamp, `{"action":"deny"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decisionhook.Sign(tt.key, tt.timestamp, []byte(tt.body)); got == want {
+				t.Errorf("Sign = %s for a different message", got)
+			}
+		})
+	}
+}
+
+// endpoint is a decision hook answering with verdict, signed with key unless
+// it is empty. sign may then tamper with the response's signature.
+type endpoint struct {
+	key     string
+	status  int                                                    // Zero is 200
+	verdict func(id string) string                                 // Response body for the request with id
+	sign    func(header http.Header, timestamp int64, body []byte) // Nil signs correctly
+}
+
+func (e endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if e.key != "" {
+		timestamp, err := strconv.ParseInt(r.Header.Get(decisionhook.TimestampHeader), 10, 64)
+		if err != nil || r.Header.Get(decisionhook.SignatureHeader) != decisionhook.Sign(e.key, timestamp, body) {
+			http.Error(w, "bad request signature", http.StatusUnauthorized)
+			return
+		}
+	}
+	var req decisionhook.Request
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := []byte(e.verdict(req.ID))
+	if e.key != "" {
+		timestamp := time.Now().Unix()
+		if e.sign != nil {
+			e.sign(w.Header(), timestamp, resp)
+		} else {
+			w.Header().Set(decisionhook.TimestampHeader, strconv.FormatInt(timestamp, 10))
+			w.Header().Set(decisionhook.SignatureHeader, decisionhook.Sign(e.key, timestamp, resp))
+		}
+	}
+	if e.status != 0 {
+		w.WriteHeader(e.status)
+	}
+	w.Write(resp)
+}
+
+// verdict returns a response body echoing the request ID
+func verdict(action, target string) func(id string) string {
+	return func(id string) string {
+		b, _ := json.Marshal(decisionhook.Response{RequestID: id, Action: decisionhook.Action(action), TargetType: target})
+		return string(b)
+	}
+}
+
+// TestReview checks the verdicts a client accepts, and that it rejects
+// responses that are unsigned, badly signed, stale or for another request
+func TestReview(t *testing.T) {
+	signedAt := func(skew time.Duration) func(header http.Header, timestamp int64, body []byte) {
+		return func(header http.Header, timestamp int64, body []byte) {
+			timestamp += int64(skew / time.Second)
+			header.Set(decisionhook.TimestampHeader, strconv.FormatInt(timestamp, 10))
+			header.Set(decisionhook.SignatureHeader, decisionhook.Sign(hookKey, timestamp, body))
+		}
+	}
+
+	tests := []struct {
+		name      string
+		clientKey string
+		endpoint  endpoint
+		want      decisionhook.Action
+		wantErr   string // Substring of the error; empty when the verdict is accepted
+	}{
+		{name: "allow", clientKey: hookKey, endpoint: endpoint{key: hookKey, verdict: verdict("allow", "")}, want: decisionhook.ActionAllow},
+		{name: "deny", clientKey: hookKey, endpoint: endpoint{key: hookKey, verdict: verdict("deny", "")}, want: decisionhook.ActionDeny},
+		{
+			name:      "modify",
+			clientKey: hookKey,
+			endpoint:  endpoint{key: hookKey, verdict: verdict("modify", "db-custom-4-15360")},
+			want:      decisionhook.ActionModify,
+		},
+		{name: "unsigned", endpoint: endpoint{verdict: func(string) string { return `{"action":"allow"}` }}, want: decisionhook.ActionAllow},
+		{
+			name:      "modify without a target",
+			clientKey: hookKey,
+			endpoint:  endpoint{key: hookKey, verdict: verdict("modify", "")},
+			wantErr:   "modify without a target_type",
+		},
+		{name: "unknown action", clientKey: hookKey, endpoint: endpoint{key: hookKey, verdict: verdict("approve", "")}, wantErr: "unknown action"},
+		{
+			name:      "not JSON",
+			clientKey: hookKey,
+			endpoint:  endpoint{key: hookKey, verdict: func(string) string { return "ok" }},
+			wantErr:   "invalid decision hook response",
+		},
+		{
+			name:      "error status",
+			clientKey: hookKey,
+			endpoint:  endpoint{key: hookKey, status: http.StatusServiceUnavailable, verdict: verdict("allow", "")},
+			wantErr:   "HTTP 503",
+		},
+		{name: "request signed with another key", clientKey: "other-key", endpoint: endpoint{key: hookKey, verdict: verdict("allow", "")}, wantErr: "HTTP 401"},
+		{
+			name:      "response signed with another key",
+			clientKey: hookKey,
+			endpoint: endpoint{key: hookKey, verdict: verdict("allow", ""), sign: func(header http.Header, timestamp int64, body []byte) {
+				header.Set(decisionhook.TimestampHeader, strconv.FormatInt(timestamp, 10))
+				header.Set(decisionhook.SignatureHeader, decisionhook.Sign("other-key", timestamp, body))
+			}},
+			wantErr: "X-Autoscaler-Signature does not match",
+		},
+		{
+			name:      "response not signed",
+			clientKey: hookKey,
+			endpoint:  endpoint{key: hookKey, verdict: verdict("allow", ""), sign: func(http.Header, int64, []byte) {}},
+			wantErr:   "missing or malformed X-Autoscaler-Timestamp",
+		},
+		{name: "response signed a minute ago", clientKey: hookKey, endpoint: endpoint{key: hookKey, verdict: verdict("allow", ""), sign: signedAt(-time.Minute)}, want: decisionhook.ActionAllow},
+		{name: "response signed too long ago", clientKey: hookKey, endpoint: endpoint{key: hookKey, verdict: verdict("allow", ""), sign: signedAt(-10 * time.Minute)}, wantErr: "more than 5m0s"},
+		{name: "response signed in the future", clientKey: hookKey, endpoint: endpoint{key: hookKey, verdict: verdict("allow", ""), sign: signedAt(10 * time.Minute)}, wantErr: "more than 5m0s"},
+		{
+			name:      "response for another request",
+			clientKey: hookKey,
+			endpoint:  endpoint{key: hookKey, verdict: func(string) string { return `{"request_id":"replayed","action":"allow"}` }},
+			wantErr:   `request_id "replayed" does not match`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.endpoint)
+			defer server.Close()
+
+			client := decisionhook.New(secrets.Literal(server.URL), secrets.Literal(tt.clientKey), time.Second)
+			got, err := client.Review(context.Background(), decisionhook.Request{
+				Project:     "shop",
+				Instance:    "orders-db",
+				CurrentType: "db-custom-2-7680",
+				TargetType:  "db-custom-8-30720",
+				ScaleUp:     true,
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Review = %+v, %v; want an error containing %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Review: %v", err)
+			}
+			if got.Action != tt.want {
+				t.Errorf("Review action = %s, want %s", got.Action, tt.want)
+			}
+		})
+	}
+}
+
+// TestReviewTimeout checks that a review gives up on an endpoint that does
+// not answer in time
+func TestReviewTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := decisionhook.New(secrets.Literal(server.URL), secrets.Literal(""), 50*time.Millisecond)
+	if _, err := client.Review(context.Background(), decisionhook.Request{Instance: "orders-db"}); err == nil ||
+		!strings.Contains(err.Error(), "failed to reach decision hook") {
+		t.Errorf("Review = %v, want a failure to reach the hook", err)
+	}
+}
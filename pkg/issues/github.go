@@ -0,0 +1,121 @@
+package issues
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/secrets"
+)
+
+// gitHubAPIURL is the GitHub REST API; GITHUB_API_URL overrides it for
+// GitHub Enterprise Server, as in GitHub Actions
+const gitHubAPIURL = "https://api.github.com"
+
+// gitHubPageSize is the most issues listed per request
+const gitHubPageSize = 100
+
+// gitHubRepo matches OWNER/REPO
+var gitHubRepo = regexp.MustCompile(`^[A-Za-z0-9-]+/[A-Za-z0-9._-]+$`)
+
+// GitHub files tickets as issues in a GitHub repository, labelled Label
+type GitHub struct {
+	repo   string
+	apiURL string
+	token  *secrets.Secret // Re-read on refresh, so rotated tokens are picked up
+	client *http.Client
+}
+
+// NewGitHub creates a tracker filing issues in repo, OWNER/REPO, with token
+func NewGitHub(repo string, token *secrets.Secret) (*GitHub, error) {
+	if !gitHubRepo.MatchString(repo) {
+		return nil, fmt.Errorf("invalid GitHub repository %q (must be OWNER/REPO)", repo)
+	}
+	apiURL := gitHubAPIURL
+	if u := os.Getenv("GITHUB_API_URL"); u != "" {
+		apiURL = strings.TrimSuffix(u, "/")
+	}
+	return &GitHub{
+		repo:   repo,
+		apiURL: apiURL,
+		token:  token,
+		client: &http.Client{Timeout: trackerTimeout},
+	}, nil
+}
+
+// gitHubIssue is an issue in the GitHub REST API
+type gitHubIssue struct {
+	Number      int       `json:"number"`
+	HTMLURL     string    `json:"html_url"`
+	Title       string    `json:"title"`
+	Body        string    `json:"body"`
+	PullRequest *struct{} `json:"pull_request,omitempty"` // Set for pull requests, which the issues API also lists
+}
+
+// Name returns github:OWNER/REPO
+func (g *GitHub) Name() string {
+	return "github:" + g.repo
+}
+
+// OpenIssues lists the repository's open issues labelled Label
+func (g *GitHub) OpenIssues(ctx context.Context) ([]Issue, error) {
+	var open []Issue
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("%s/repos/%s/issues?state=open&labels=%s&per_page=%d&page=%d", g.apiURL, g.repo, Label, gitHubPageSize, page)
+		var issues []gitHubIssue
+		if err := doJSON(ctx, g.client, http.MethodGet, url, g.authorize, nil, &issues); err != nil {
+			return nil, fmt.Errorf("failed to list GitHub issues: %w", err)
+		}
+		for _, issue := range issues {
+			if issue.PullRequest != nil {
+				continue
+			}
+			if key := parseTrackingKey(issue.Body); key != "" {
+				open = append(open, Issue{Key: key, ID: strconv.Itoa(issue.Number), URL: issue.HTMLURL, Title: issue.Title, Body: issue.Body})
+			}
+		}
+		if len(issues) < gitHubPageSize {
+			return open, nil
+		}
+	}
+}
+
+// Create opens issue in the repository
+func (g *GitHub) Create(ctx context.Context, issue Issue) (Issue, error) {
+	request := map[string]interface{}{
+		"title":  issue.Title,
+		"body":   issue.Body,
+		"labels": []string{Label},
+	}
+	var created gitHubIssue
+	if err := doJSON(ctx, g.client, http.MethodPost, g.apiURL+"/repos/"+g.repo+"/issues", g.authorize, request, &created); err != nil {
+		return issue, fmt.Errorf("failed to create GitHub issue: %w", err)
+	}
+	issue.ID = strconv.Itoa(created.Number)
+	issue.URL = created.HTMLURL
+	return issue, nil
+}
+
+// Close comments on issue and closes it as completed
+func (g *GitHub) Close(ctx context.Context, issue Issue, comment string) error {
+	url := g.apiURL + "/repos/" + g.repo + "/issues/" + issue.ID
+	if err := doJSON(ctx, g.client, http.MethodPost, url+"/comments", g.authorize, map[string]string{"body": comment}, nil); err != nil {
+		return fmt.Errorf("failed to comment on GitHub issue #%s: %w", issue.ID, err)
+	}
+	request := map[string]string{"state": "closed", "state_reason": "completed"}
+	if err := doJSON(ctx, g.client, http.MethodPatch, url, g.authorize, request, nil); err != nil {
+		return fmt.Errorf("failed to close GitHub issue #%s: %w", issue.ID, err)
+	}
+	return nil
+}
+
+// authorize sets the GitHub token on req
+func (g *GitHub) authorize(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+g.token.Value())
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+}
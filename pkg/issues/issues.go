@@ -0,0 +1,172 @@
+// Package issues turns long-standing right-sizing recommendations into
+// tickets in an issue tracker such as GitHub or Jira, so right-sizing debt is
+// tracked as work rather than re-posted to a channel every cycle. Each ticket
+// carries a tracking key naming its project and instance, which is how open
+// tickets are found again after a restart.
+package issues
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/secrets"
+)
+
+// Label marks the tickets the autoscaler files, so it only ever lists and
+// closes its own
+const Label = "cloudsql-autoscaler"
+
+// trackerTimeout bounds a request to the tracker, so a slow tracker never
+// holds up a cycle for long
+const trackerTimeout = 30 * time.Second
+
+// Issue is a ticket filed for a recommendation
+type Issue struct {
+	Key   string // Tracking key, see TrackingKey
+	ID    string // The tracker's identifier, e.g. 42 or OPS-42
+	URL   string
+	Title string
+	Body  string
+}
+
+// Tracker files and closes tickets in an issue tracker
+type Tracker interface {
+	// Name identifies the tracker and where tickets go, e.g. github:acme/infra
+	Name() string
+	// OpenIssues returns the open tickets labelled Label that carry a tracking key
+	OpenIssues(ctx context.Context) ([]Issue, error)
+	// Create files issue and returns it with its ID and URL set
+	Create(ctx context.Context, issue Issue) (Issue, error)
+	// Close closes issue as done, after commenting why
+	Close(ctx context.Context, issue Issue, comment string) error
+}
+
+// ParseTracker creates the tracker spec names:
+//
+//	github:OWNER/REPO                         issues in a GitHub repository
+//	jira:https://example.atlassian.net/KEY    tasks in a Jira project
+//
+// token authenticates to the tracker: a GitHub token with issues write
+// access, a Jira EMAIL:API_TOKEN pair on Jira Cloud, or a personal access
+// token on Jira Data Center. Jira hosted on atlassian.net is taken to be
+// Jira Cloud.
+func ParseTracker(spec string, token *secrets.Secret) (Tracker, error) {
+	kind, target, ok := strings.Cut(spec, ":")
+	if !ok || target == "" {
+		return nil, fmt.Errorf("invalid issue tracker %q (must be github:OWNER/REPO or jira:URL/PROJECT)", spec)
+	}
+	switch kind {
+	case "github":
+		return NewGitHub(target, token)
+	case "jira":
+		return NewJira(target, token)
+	}
+	return nil, fmt.Errorf("unknown issue tracker %q (must be github or jira)", kind)
+}
+
+// TrackingKey identifies the ticket for a standing scale-down recommendation
+// for instance, e.g. cloudsql-autoscaler/my-project/orders-db/scale-down
+func TrackingKey(project, instance string) string {
+	return Label + "/" + project + "/" + instance + "/scale-down"
+}
+
+// trackingKeyLine prefixes the tracking key in ticket bodies
+const trackingKeyLine = "Tracking key: "
+
+// parseTrackingKey finds the tracking key in a ticket body
+func parseTrackingKey(body string) string {
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.Trim(strings.TrimSpace(line), "`")
+		if key, ok := strings.CutPrefix(line, trackingKeyLine); ok && strings.HasPrefix(key, Label+"/") {
+			return strings.Trim(strings.TrimSpace(key), "`")
+		}
+	}
+	return ""
+}
+
+// Recommendation is a scale-down recommendation that has stood unapplied
+type Recommendation struct {
+	Project          string
+	Instance         string
+	CurrentType      string
+	TargetType       string
+	Reason           string
+	CPUP95           float64
+	MemoryP95Pct     float64
+	EstimatedSavings float64 // Monthly, in USD
+	Owner            *config.Owner
+	Since            time.Time // When the recommendation was first made
+}
+
+// NewIssue describes rec as a ticket, with savings formatted in currency
+func NewIssue(rec Recommendation, currency config.Currency, now time.Time) Issue {
+	days := int(now.Sub(rec.Since).Hours() / 24)
+	lines := []string{
+		fmt.Sprintf("Cloud SQL instance %s in project %s has been recommended for a scale-down from %s to %s for %d days, since %s, without the change being made.",
+			rec.Instance, rec.Project, rec.CurrentType, rec.TargetType, days, config.FormatTime(rec.Since)),
+		"",
+		"Reason: " + rec.Reason,
+		fmt.Sprintf("CPU P95: %.1f%%, memory P95: %.1f%%", rec.CPUP95, rec.MemoryP95Pct),
+		"Estimated savings: " + currency.Format(rec.EstimatedSavings) + "/month",
+	}
+	if rec.Owner != nil && !rec.Owner.IsZero() {
+		lines = append(lines, "Owner: "+rec.Owner.String())
+	}
+	lines = append(lines,
+		"",
+		"Apply the recommendation, or label the instance cloudsql-autoscaler-exclude=true if it is sized deliberately. This ticket is closed automatically once the instance is no longer recommended for a scale-down.",
+		"",
+		trackingKeyLine+TrackingKey(rec.Project, rec.Instance),
+	)
+	return Issue{
+		Key:   TrackingKey(rec.Project, rec.Instance),
+		Title: fmt.Sprintf("Right-size Cloud SQL instance %s (%s → %s)", rec.Instance, rec.CurrentType, rec.TargetType),
+		Body:  strings.Join(lines, "\n"),
+	}
+}
+
+// doJSON sends body, if any, as JSON to url with authorize setting the
+// credentials, and decodes the response into out, if any
+func doJSON(ctx context.Context, client *http.Client, method, url string, authorize func(*http.Request), body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	authorize(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %s: %s", method, req.URL.Path, resp.Status, strings.TrimSpace(string(detail)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s %s response: %w", method, req.URL.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package issues
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/secrets"
+)
+
+// jiraIssueType is the issue type tickets are filed as
+const jiraIssueType = "Task"
+
+// jiraPageSize is the most issues searched per request
+const jiraPageSize = 100
+
+// jiraProjectKey matches a Jira project key such as OPS
+var jiraProjectKey = regexp.MustCompile(`^[A-Z][A-Z0-9_]+$`)
+
+// Jira files tickets as tasks in a Jira Cloud or Data Center project,
+// labelled Label, and closes them through the first transition to a done
+// status
+type Jira struct {
+	baseURL string // e.g. https://example.atlassian.net
+	project string
+	cloud   bool            // Jira Cloud, hosted on atlassian.net, rather than Data Center
+	token   *secrets.Secret // EMAIL:API_TOKEN or a personal access token; re-read on refresh, so rotated tokens are picked up
+	client  *http.Client
+}
+
+// NewJira creates a tracker filing tasks in the project target names, e.g.
+// https://example.atlassian.net/OPS, with token
+func NewJira(target string, token *secrets.Secret) (*Jira, error) {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("invalid Jira project %q (must be https://HOST/PROJECT)", target)
+	}
+	project := path.Base(u.Path)
+	if !jiraProjectKey.MatchString(project) {
+		return nil, fmt.Errorf("invalid Jira project %q (must end in a project key such as OPS)", target)
+	}
+	u.Path = strings.TrimSuffix(path.Dir(u.Path), "/")
+	u.RawQuery, u.Fragment = "", ""
+	return &Jira{
+		baseURL: u.String(),
+		project: project,
+		cloud:   strings.HasSuffix(u.Hostname(), ".atlassian.net"),
+		token:   token,
+		client:  &http.Client{Timeout: trackerTimeout},
+	}, nil
+}
+
+// Name returns jira:URL/PROJECT
+func (j *Jira) Name() string {
+	return "jira:" + j.baseURL + "/" + j.project
+}
+
+// jiraIssue is an issue in the Jira REST API
+type jiraIssue struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Summary     string `json:"summary"`
+		Description string `json:"description"`
+	} `json:"fields"`
+}
+
+// OpenIssues searches the project for issues labelled Label that are not
+// done. Jira Cloud pages its search/jql endpoint by token; Data Center only
+// has the search endpoint, paged by offset.
+func (j *Jira) OpenIssues(ctx context.Context) ([]Issue, error) {
+	jql := fmt.Sprintf(`project = %q AND labels = %q AND statusCategory != Done`, j.project, Label)
+	var open []Issue
+	pageToken := ""
+	for startAt := 0; ; {
+		query := url.Values{
+			"jql":        {jql},
+			"fields":     {"summary,description"},
+			"maxResults": {fmt.Sprint(jiraPageSize)},
+		}
+		endpoint := "/rest/api/2/search/jql"
+		switch {
+		case !j.cloud:
+			endpoint = "/rest/api/2/search"
+			query.Set("startAt", fmt.Sprint(startAt))
+		case pageToken != "":
+			query.Set("nextPageToken", pageToken)
+		}
+		var page struct {
+			Issues        []jiraIssue `json:"issues"`
+			NextPageToken string      `json:"nextPageToken"` // Jira Cloud
+			Total         int         `json:"total"`         // Data Center
+		}
+		if err := doJSON(ctx, j.client, http.MethodGet, j.baseURL+endpoint+"?"+query.Encode(), j.authorize, nil, &page); err != nil {
+			return nil, fmt.Errorf("failed to search Jira issues: %w", err)
+		}
+		for _, issue := range page.Issues {
+			if key := parseTrackingKey(issue.Fields.Description); key != "" {
+				open = append(open, Issue{Key: key, ID: issue.Key, URL: j.browseURL(issue.Key), Title: issue.Fields.Summary, Body: issue.Fields.Description})
+			}
+		}
+		startAt += len(page.Issues)
+		done := page.NextPageToken == ""
+		if !j.cloud {
+			done = len(page.Issues) == 0 || startAt >= page.Total
+		}
+		if done {
+			return open, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// Create files issue as a task in the project
+func (j *Jira) Create(ctx context.Context, issue Issue) (Issue, error) {
+	request := map[string]interface{}{
+		"fields": map[string]interface{}{
+			"project":     map[string]string{"key": j.project},
+			"issuetype":   map[string]string{"name": jiraIssueType},
+			"summary":     issue.Title,
+			"description": issue.Body,
+			"labels":      []string{Label},
+		},
+	}
+	var created struct {
+		Key string `json:"key"`
+	}
+	if err := doJSON(ctx, j.client, http.MethodPost, j.baseURL+"/rest/api/2/issue", j.authorize, request, &created); err != nil {
+		return issue, fmt.Errorf("failed to create Jira issue: %w", err)
+	}
+	issue.ID = created.Key
+	issue.URL = j.browseURL(created.Key)
+	return issue, nil
+}
+
+// Close comments on issue and moves it to a done status
+func (j *Jira) Close(ctx context.Context, issue Issue, comment string) error {
+	issueURL := j.baseURL + "/rest/api/2/issue/" + url.PathEscape(issue.ID)
+	var transitions struct {
+		Transitions []struct {
+			ID string `json:"id"`
+			To struct {
+				StatusCategory struct {
+					Key string `json:"key"`
+				} `json:"statusCategory"`
+			} `json:"to"`
+		} `json:"transitions"`
+	}
+	if err := doJSON(ctx, j.client, http.MethodGet, issueURL+"/transitions", j.authorize, nil, &transitions); err != nil {
+		return fmt.Errorf("failed to list transitions of Jira issue %s: %w", issue.ID, err)
+	}
+	done := ""
+	for _, t := range transitions.Transitions {
+		if t.To.StatusCategory.Key == "done" {
+			done = t.ID
+			break
+		}
+	}
+	if done == "" {
+		return fmt.Errorf("no transition to a done status for Jira issue %s", issue.ID)
+	}
+
+	if err := doJSON(ctx, j.client, http.MethodPost, issueURL+"/comment", j.authorize, map[string]string{"body": comment}, nil); err != nil {
+		return fmt.Errorf("failed to comment on Jira issue %s: %w", issue.ID, err)
+	}
+	request := map[string]interface{}{"transition": map[string]string{"id": done}}
+	if err := doJSON(ctx, j.client, http.MethodPost, issueURL+"/transitions", j.authorize, request, nil); err != nil {
+		return fmt.Errorf("failed to close Jira issue %s: %w", issue.ID, err)
+	}
+	return nil
+}
+
+// browseURL links to the issue with key
+func (j *Jira) browseURL(key string) string {
+	return j.baseURL + "/browse/" + key
+}
+
+// authorize sets the Jira credentials on req: basic auth for an
+// EMAIL:API_TOKEN pair, a bearer token otherwise
+func (j *Jira) authorize(req *http.Request) {
+	token := j.token.Value()
+	if strings.Contains(token, ":") {
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(token)))
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+}
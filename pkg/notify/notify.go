@@ -0,0 +1,74 @@
+// Package notify sends what the daemon recommends and does to the channels
+// teams watch, such as Slack, Datadog and PagerDuty. Notifiers are given whole messages per cycle
+// step, so a channel can batch a cycle's recommendations into one post.
+package notify
+
+import (
+	"context"
+	"errors"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// Kind identifies what a message reports
+type Kind string
+
+const (
+	KindRecommendations Kind = "recommendations" // Newly recommended scaling operations
+	KindApplied         Kind = "applied"         // A scaling operation was applied
+	KindFailed          Kind = "failed"          // A scaling operation failed
+	KindCycleFailed     Kind = "cycle_failed"    // A cycle could not analyze the fleet
+	KindOverloaded      Kind = "overloaded"      // Instances stayed above the scale-up thresholds for several cycles
+	KindOverloadEnded   Kind = "overload_ended"  // Overloaded instances fell back below the scale-up thresholds
+)
+
+// Change is one instance's scaling operation, with the context the owning
+// team needs to act on it
+type Change struct {
+	Instance         string
+	InstanceID       string // Fully qualified PROJECT:REGION:NAME, see config.InstanceID
+	CurrentType      string
+	TargetType       string
+	Reason           string
+	ReasonCodes      []cloudsql.ReasonCode
+	CPUP95           float64
+	MemoryP95Pct     float64
+	EstimatedSavings float64 // Monthly, in USD; negative for a cost increase
+	DowntimeExpected bool
+	Owner            *config.Owner
+	Error            string // Why applying the change failed
+}
+
+// Message is a notification about one step of a cycle
+type Message struct {
+	Kind    Kind
+	Project string
+	DryRun  bool // Recommendations will not be applied
+	Changes []Change
+	Error   string // Why the cycle failed, for KindCycleFailed
+	Cycles  int    // Consecutive cycles above the scale-up thresholds, for KindOverloaded
+
+	// Savings were removed by redaction; channels leave them out
+	CostsRedacted bool
+}
+
+// Notifier delivers messages to a channel
+type Notifier interface {
+	Notify(ctx context.Context, m Message) error
+}
+
+// Multi delivers every message to each of its notifiers in turn. Every
+// notifier is tried; the errors of those that failed are joined.
+type Multi []Notifier
+
+// Notify delivers m to each notifier
+func (n Multi) Notify(ctx context.Context, m Message) error {
+	var errs []error
+	for _, notifier := range n {
+		if err := notifier.Notify(ctx, m); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
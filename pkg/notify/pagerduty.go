@@ -0,0 +1,173 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/secrets"
+)
+
+// pagerDutyEventsURL is the PagerDuty Events API v2 endpoint
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// pagerDutyTimeout bounds an event submission, so a slow PagerDuty never holds up a cycle for long
+const pagerDutyTimeout = 10 * time.Second
+
+// PagerDuty limits event summaries to 1024 characters
+const maxPagerDutySummary = 1024
+
+// PagerDuty pages on-call through the PagerDuty Events API v2 when a resize
+// fails or an instance stays overloaded. Each instance and problem has its
+// own deduplication key, so repeats update the open incident rather than
+// opening another, and the incident is resolved once the problem clears: a
+// failed resize by a later successful one, an overload when the instance
+// falls back below the thresholds. Other messages are not sent.
+type PagerDuty struct {
+	routingKey *secrets.Secret // Re-read on refresh, so rotated integration keys are picked up
+	client     *http.Client
+}
+
+// NewPagerDuty creates a PagerDuty notifier sending events to the service
+// integration with routingKey
+func NewPagerDuty(routingKey *secrets.Secret) *PagerDuty {
+	return &PagerDuty{
+		routingKey: routingKey,
+		client:     &http.Client{Timeout: pagerDutyTimeout},
+	}
+}
+
+// pagerDutyEvent is an Events API v2 event
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"` // trigger or resolve
+	DedupKey    string            `json:"dedup_key"`
+	Client      string            `json:"client,omitempty"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"` // Only for trigger
+}
+
+type pagerDutyPayload struct {
+	Summary       string                 `json:"summary"`
+	Source        string                 `json:"source"`
+	Severity      string                 `json:"severity"` // critical, error, warning or info
+	Component     string                 `json:"component,omitempty"`
+	Group         string                 `json:"group,omitempty"`
+	Class         string                 `json:"class,omitempty"`
+	CustomDetails map[string]interface{} `json:"custom_details,omitempty"`
+}
+
+// Notify sends one event per change in m, if m is a kind PagerDuty acts on
+func (p *PagerDuty) Notify(ctx context.Context, m Message) error {
+	var errs []error
+	for _, c := range m.Changes {
+		event := p.event(m, c)
+		if event == nil {
+			continue
+		}
+		if err := p.send(ctx, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.Instance, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// event builds the event that reports c, or nil if m is not paged on
+func (p *PagerDuty) event(m Message, c Change) *pagerDutyEvent {
+	switch m.Kind {
+	case KindFailed:
+		return p.trigger(pagerDutyDedupKey(m.Project, c.Instance, "scaling-failed"), "scaling_failed", "error",
+			fmt.Sprintf("Failed to scale Cloud SQL instance %s from %s to %s: %s", c.Instance, c.CurrentType, c.TargetType, c.Error),
+			m, c)
+	case KindApplied:
+		return p.resolve(pagerDutyDedupKey(m.Project, c.Instance, "scaling-failed"))
+	case KindOverloaded:
+		return p.trigger(pagerDutyDedupKey(m.Project, c.Instance, "overloaded"), "overloaded", "warning",
+			fmt.Sprintf("Cloud SQL instance %s has been above the scale-up thresholds for %d cycles (CPU P95: %.1f%%, memory P95: %.1f%%)",
+				c.Instance, m.Cycles, c.CPUP95, c.MemoryP95Pct),
+			m, c)
+	case KindOverloadEnded:
+		return p.resolve(pagerDutyDedupKey(m.Project, c.Instance, "overloaded"))
+	}
+	return nil
+}
+
+// trigger builds an event opening or updating the incident under dedupKey
+func (p *PagerDuty) trigger(dedupKey, class, severity, summary string, m Message, c Change) *pagerDutyEvent {
+	if len(summary) > maxPagerDutySummary {
+		summary = summary[:maxPagerDutySummary-3] + "..."
+	}
+	details := map[string]interface{}{
+		"project":      m.Project,
+		"instance":     c.Instance,
+		"instance_id":  c.InstanceID,
+		"current_type": c.CurrentType,
+		"target_type":  c.TargetType,
+		"reason":       c.Reason,
+		"reason_codes": c.ReasonCodes,
+		"cpu_p95":      c.CPUP95,
+		"memory_p95":   c.MemoryP95Pct,
+	}
+	if c.Error != "" {
+		details["error"] = c.Error
+	}
+	if c.Owner != nil && !c.Owner.IsZero() {
+		details["owner"] = c.Owner.String()
+	}
+	return &pagerDutyEvent{
+		EventAction: "trigger",
+		DedupKey:    dedupKey,
+		Client:      "cloudsql-autoscaler",
+		Payload: &pagerDutyPayload{
+			Summary:       summary,
+			Source:        m.Project + "/" + c.Instance,
+			Severity:      severity,
+			Component:     c.Instance,
+			Group:         m.Project,
+			Class:         class,
+			CustomDetails: details,
+		},
+	}
+}
+
+// resolve builds an event resolving the incident under dedupKey. PagerDuty
+// ignores resolves for incidents that are not open.
+func (p *PagerDuty) resolve(dedupKey string) *pagerDutyEvent {
+	return &pagerDutyEvent{EventAction: "resolve", DedupKey: dedupKey}
+}
+
+// pagerDutyDedupKey identifies one problem with one instance, e.g.
+// cloudsql-autoscaler/my-project/orders-db/overloaded
+func pagerDutyDedupKey(project, instance, problem string) string {
+	return "cloudsql-autoscaler/" + project + "/" + instance + "/" + problem
+}
+
+// send submits event
+func (p *PagerDuty) send(ctx context.Context, event *pagerDutyEvent) error {
+	event.RoutingKey = p.routingKey.Value()
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode PagerDuty event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, pagerDutyEventsURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create PagerDuty request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send PagerDuty event: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to send PagerDuty event: %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
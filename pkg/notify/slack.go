@@ -0,0 +1,254 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/secrets"
+)
+
+// Slack limits messages to 50 blocks; one is the header and one the overflow note
+const maxSlackChanges = 40
+
+// slackTimeout bounds a webhook post, so a slow Slack never holds up a cycle for long
+const slackTimeout = 10 * time.Second
+
+// slackPostMessageURL is the Web API method channel-routed messages are posted with
+const slackPostMessageURL = "https://slack.com/api/chat.postMessage"
+
+// Slack posts messages to a Slack incoming webhook and, with a bot token, each
+// owning team's changes to that team's channel
+type Slack struct {
+	webhook  *secrets.Secret // Re-read on refresh, so rotated webhook URLs are picked up; empty when only routed messages are posted
+	botToken *secrets.Secret // Empty when messages are not routed by owner channel
+	currency config.Currency
+	client   *http.Client
+}
+
+// NewSlack creates a Slack notifier posting to webhook, with savings
+// estimates formatted in currency. When botToken is set, changes whose owner
+// has a channel are posted there through chat.postMessage instead.
+func NewSlack(webhook, botToken *secrets.Secret, currency config.Currency) *Slack {
+	return &Slack{
+		webhook:  webhook,
+		botToken: botToken,
+		currency: currency,
+		client:   &http.Client{Timeout: slackTimeout},
+	}
+}
+
+// slackBlock is a Block Kit block; only section, header and context blocks are used
+type slackBlock struct {
+	Type     string       `json:"type"`
+	Text     *slackText   `json:"text,omitempty"`
+	Fields   []slackText  `json:"fields,omitempty"`
+	Elements []*slackText `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"` // mrkdwn or plain_text
+	Text string `json:"text"`
+}
+
+// Notify posts each owner channel's share of m's changes to that channel, and
+// the rest to the webhook
+func (s *Slack) Notify(ctx context.Context, m Message) error {
+	rest, routed := s.route(m)
+	var errs []error
+	for _, channel := range slices.Sorted(maps.Keys(routed)) {
+		payload := s.payload(routed[channel])
+		payload["channel"] = channel
+		if err := s.postMessage(ctx, payload); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", channel, err))
+		}
+	}
+	if s.webhook.Value() != "" && (len(rest.Changes) > 0 || len(m.Changes) == 0) {
+		if err := s.postWebhook(ctx, s.payload(rest)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// route splits m's changes by their owner's channel when a bot token is set;
+// rest keeps the changes without one
+func (s *Slack) route(m Message) (rest Message, routed map[string]Message) {
+	if s.botToken == nil || s.botToken.Value() == "" {
+		return m, nil
+	}
+	rest = m
+	rest.Changes = nil
+	routed = make(map[string]Message)
+	for _, c := range m.Changes {
+		if c.Owner == nil || c.Owner.Channel == "" {
+			rest.Changes = append(rest.Changes, c)
+			continue
+		}
+		channelMessage, ok := routed[c.Owner.Channel]
+		if !ok {
+			channelMessage = m
+			channelMessage.Changes = nil
+		}
+		channelMessage.Changes = append(channelMessage.Changes, c)
+		routed[c.Owner.Channel] = channelMessage
+	}
+	return rest, routed
+}
+
+// postWebhook posts payload to the webhook
+func (s *Slack) postWebhook(ctx context.Context, payload map[string]interface{}) error {
+	resp, err := s.post(ctx, s.webhook.Value(), "", payload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to post to Slack: %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// postMessage posts payload, which names its channel, through
+// chat.postMessage. The Web API reports failures in the body, not the status.
+func (s *Slack) postMessage(ctx context.Context, payload map[string]interface{}) error {
+	resp, err := s.post(ctx, slackPostMessageURL, s.botToken.Value(), payload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to post to Slack: %s", resp.Status)
+	}
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode Slack response: %w", err)
+	}
+	if !result.OK {
+		return fmt.Errorf("failed to post to Slack: %s", result.Error)
+	}
+	return nil
+}
+
+// post sends payload to url, authenticated with token when it is set
+func (s *Slack) post(ctx context.Context, url, token string, payload map[string]interface{}) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode Slack message: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Slack request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// The error carries the webhook URL, which is a credential
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("failed to post to Slack: %w", ctx.Err())
+		}
+		return nil, fmt.Errorf("failed to post to Slack: request failed")
+	}
+	return resp, nil
+}
+
+// payload renders m as a Block Kit message, with a plain-text fallback for
+// notifications
+func (s *Slack) payload(m Message) map[string]interface{} {
+	title := s.title(m)
+	blocks := []slackBlock{{Type: "header", Text: &slackText{Type: "plain_text", Text: title}}}
+	if m.Kind == KindCycleFailed {
+		blocks = append(blocks, slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: m.Error}})
+	}
+	for i, c := range m.Changes {
+		if i == maxSlackChanges {
+			blocks = append(blocks, slackBlock{Type: "context", Elements: []*slackText{
+				{Type: "mrkdwn", Text: fmt.Sprintf("…and %d more; see /api/v1/recommendations", len(m.Changes)-maxSlackChanges)},
+			}})
+			break
+		}
+		blocks = append(blocks, s.changeBlock(c, !m.CostsRedacted))
+	}
+	return map[string]interface{}{"text": title, "blocks": blocks}
+}
+
+// title summarizes m in one line
+func (s *Slack) title(m Message) string {
+	switch m.Kind {
+	case KindRecommendations:
+		title := fmt.Sprintf("%d new scaling recommendation(s) in %s", len(m.Changes), m.Project)
+		if m.DryRun {
+			title += " (dry run)"
+		}
+		return title
+	case KindApplied:
+		if len(m.Changes) == 1 {
+			c := m.Changes[0]
+			return fmt.Sprintf("Scaled %s from %s to %s", c.Instance, c.CurrentType, c.TargetType)
+		}
+		return fmt.Sprintf("Scaled %d instance(s) in %s", len(m.Changes), m.Project)
+	case KindFailed:
+		if len(m.Changes) == 1 {
+			return fmt.Sprintf("Failed to scale %s", m.Changes[0].Instance)
+		}
+		return fmt.Sprintf("Failed to scale %d instance(s) in %s", len(m.Changes), m.Project)
+	case KindCycleFailed:
+		return "Autoscaling cycle failed in " + m.Project
+	case KindOverloaded:
+		return fmt.Sprintf("%d instance(s) in %s above the scale-up thresholds for %d cycles", len(m.Changes), m.Project, m.Cycles)
+	case KindOverloadEnded:
+		return fmt.Sprintf("%d instance(s) in %s back below the scale-up thresholds", len(m.Changes), m.Project)
+	}
+	return string(m.Kind)
+}
+
+// changeBlock renders one change with its instance's context, and its
+// savings when withSavings is set
+func (s *Slack) changeBlock(c Change, withSavings bool) slackBlock {
+	text := fmt.Sprintf("*%s*: %s → %s\n%s", c.Instance, c.CurrentType, c.TargetType, c.Reason)
+	if c.Error != "" {
+		text += "\n:x: " + c.Error
+	}
+
+	fields := []slackText{
+		{Type: "mrkdwn", Text: fmt.Sprintf("CPU P95: %.1f%%, memory P95: %.1f%%", c.CPUP95, c.MemoryP95Pct)},
+	}
+	if withSavings {
+		savings := "Savings: " + s.currency.Format(c.EstimatedSavings) + "/month"
+		if c.EstimatedSavings < 0 {
+			savings = "Cost increase: " + s.currency.Format(-c.EstimatedSavings) + "/month"
+		}
+		fields = append(fields, slackText{Type: "mrkdwn", Text: savings})
+	}
+	if c.DowntimeExpected {
+		fields = append(fields, slackText{Type: "mrkdwn", Text: ":warning: Downtime expected"})
+	}
+	if c.Owner != nil && !c.Owner.IsZero() {
+		fields = append(fields, slackText{Type: "mrkdwn", Text: "Owner: " + c.Owner.String()})
+	}
+	if len(c.ReasonCodes) > 0 {
+		codes := make([]string, len(c.ReasonCodes))
+		for i, code := range c.ReasonCodes {
+			codes[i] = string(code)
+		}
+		fields = append(fields, slackText{Type: "mrkdwn", Text: "`" + strings.Join(codes, "` `") + "`"})
+	}
+	return slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: text}, Fields: fields}
+}
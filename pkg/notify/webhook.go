@@ -0,0 +1,201 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudevents"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/secrets"
+)
+
+// webhookTimeout bounds a webhook request, so a slow endpoint never holds up a cycle for long
+const webhookTimeout = 10 * time.Second
+
+// Webhook posts messages to an arbitrary HTTP endpoint, as the JSON encoding
+// of WebhookPayload, a CloudEvent carrying it or the body a template renders
+// from it, so internal tooling can be integrated without a bespoke channel
+type Webhook struct {
+	url         *secrets.Secret            // Re-read on refresh, like header values
+	headers     map[string]*secrets.Secret // By canonical header name
+	body        *template.Template         // Nil sends the payload as JSON
+	cloudEvents bool                       // Wrap the JSON payload in a CloudEvent
+	client      *http.Client
+}
+
+// webhookEventTypes are the CloudEvents types of each kind of message
+var webhookEventTypes = map[Kind]string{
+	KindRecommendations: cloudevents.TypeScalingRecommended,
+	KindApplied:         cloudevents.TypeScalingApplied,
+	KindFailed:          cloudevents.TypeScalingFailed,
+	KindCycleFailed:     cloudevents.TypeCycleFailed,
+	KindOverloaded:      cloudevents.TypeInstanceOverloaded,
+	KindOverloadEnded:   cloudevents.TypeOverloadEnded,
+}
+
+// NewWebhook creates a webhook notifier posting to url with headers. body,
+// from ParseWebhookTemplate, renders each message's request body from its
+// WebhookPayload; a nil body sends the payload as JSON.
+func NewWebhook(url *secrets.Secret, headers map[string]*secrets.Secret, body *template.Template) *Webhook {
+	canonical := make(map[string]*secrets.Secret, len(headers))
+	for name, value := range headers {
+		canonical[textproto.CanonicalMIMEHeaderKey(name)] = value
+	}
+	return &Webhook{
+		url:     url,
+		headers: canonical,
+		body:    body,
+		client:  &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// SetCloudEvents sends each payload as the data of a CloudEvent in
+// structured mode instead of bare, for endpoints routing CloudEvents. It
+// does not apply to templated bodies.
+func (w *Webhook) SetCloudEvents(on bool) {
+	w.cloudEvents = on
+}
+
+// WebhookPayload is what a webhook is sent for a message: its JSON encoding,
+// or the data its template is executed with
+type WebhookPayload struct {
+	Kind    Kind            `json:"kind"` // recommendations, applied, failed, cycle_failed, overloaded or overload_ended
+	Project string          `json:"project"`
+	DryRun  bool            `json:"dry_run"`
+	Changes []WebhookChange `json:"changes,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Cycles  int             `json:"cycles,omitempty"`
+	Time    time.Time       `json:"time"`
+
+	// Savings were removed by redaction and are reported as zero
+	CostsRedacted bool `json:"costs_redacted,omitempty"`
+}
+
+// WebhookChange is a Change in a WebhookPayload
+type WebhookChange struct {
+	Instance         string                `json:"instance"`
+	InstanceID       string                `json:"instance_id,omitempty"`
+	CurrentType      string                `json:"current_type"`
+	TargetType       string                `json:"target_type"`
+	Reason           string                `json:"reason"`
+	ReasonCodes      []cloudsql.ReasonCode `json:"reason_codes,omitempty"`
+	CPUP95           float64               `json:"cpu_p95"`
+	MemoryP95Pct     float64               `json:"memory_p95_pct"`
+	EstimatedSavings float64               `json:"estimated_savings"` // Monthly, in USD
+	DowntimeExpected bool                  `json:"downtime_expected"`
+	Owner            *config.Owner         `json:"owner,omitempty"`
+	Error            string                `json:"error,omitempty"`
+}
+
+// newWebhookPayload converts m into a WebhookPayload sent at now
+func newWebhookPayload(m Message, now time.Time) WebhookPayload {
+	p := WebhookPayload{Kind: m.Kind, Project: m.Project, DryRun: m.DryRun, Error: m.Error, Cycles: m.Cycles, Time: now.UTC(),
+		CostsRedacted: m.CostsRedacted}
+	for _, c := range m.Changes {
+		p.Changes = append(p.Changes, WebhookChange(c))
+	}
+	return p
+}
+
+// ParseWebhookTemplate parses the Go template in the file at path. Besides
+// the standard functions, templates have json (JSON-encode a value, e.g.
+// {{json .Reason}} for a quoted string), money (format a USD amount in
+// currency) and time (format a time as RFC3339 in the display time zone).
+func ParseWebhookTemplate(path string, currency config.Currency) (*template.Template, error) {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook template: %w", err)
+	}
+	funcs := template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+		"money": currency.Format,
+		"time":  config.FormatTime,
+	}
+	tmpl, err := template.New(filepath.Base(path)).Option("missingkey=error").Funcs(funcs).Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// ParseWebhookHeader splits a NAME: VALUE header
+func ParseWebhookHeader(header string) (name, value string, err error) {
+	name, value, ok := strings.Cut(header, ":")
+	name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return "", "", fmt.Errorf("invalid webhook header %q (must be NAME: VALUE)", header)
+	}
+	return name, value, nil
+}
+
+// Notify sends m to the webhook. A template that renders only whitespace
+// skips m, so templates can choose the kinds they are sent.
+func (w *Webhook) Notify(ctx context.Context, m Message) error {
+	payload := newWebhookPayload(m, time.Now())
+	var body []byte
+	contentType := "application/json"
+	if w.body == nil {
+		var v interface{} = payload
+		if w.cloudEvents {
+			subject := ""
+			if len(m.Changes) == 1 {
+				subject = m.Changes[0].Instance
+			}
+			v = cloudevents.New(webhookEventTypes[m.Kind], m.Project, subject, payload.Time, payload)
+			contentType = cloudevents.ContentType
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("failed to encode webhook payload: %w", err)
+		}
+		body = data
+	} else {
+		var buf bytes.Buffer
+		if err := w.body.Execute(&buf, payload); err != nil {
+			return fmt.Errorf("failed to render webhook template: %w", err)
+		}
+		if len(bytes.TrimSpace(buf.Bytes())) == 0 {
+			return nil
+		}
+		body = buf.Bytes()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url.Value(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: invalid URL")
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "cloudsql-autoscaler")
+	for name, value := range w.headers {
+		req.Header.Set(name, value.Value())
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		// The error carries the URL, which may hold a credential
+		if ctx.Err() != nil {
+			return fmt.Errorf("failed to call webhook: %w", ctx.Err())
+		}
+		return fmt.Errorf("failed to call webhook: request failed")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to call webhook: %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package redact
+
+import (
+	"context"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/notify"
+)
+
+// Message returns m with its project and the instances named masked in every
+// field, and its savings removed when costs are redacted. The instances of
+// m's changes are always masked; instances lists others its text may name.
+func (r *Redactor) Message(m notify.Message, instances []string) notify.Message {
+	if !r.Enabled() {
+		return m
+	}
+	names := make([]string, 0, len(instances)+len(m.Changes))
+	names = append(names, instances...)
+	for _, c := range m.Changes {
+		names = append(names, c.Instance)
+	}
+	masked := r.names(m.Project, names)
+
+	m.Project = r.Project(m.Project)
+	m.Error = r.text(m.Error, masked)
+	changes := make([]notify.Change, len(m.Changes))
+	for i, c := range m.Changes {
+		c.Instance = r.Instance(c.Instance)
+		c.InstanceID = r.text(c.InstanceID, masked)
+		c.Reason = r.text(c.Reason, masked)
+		c.Error = r.text(c.Error, masked)
+		if r.policy.Costs {
+			c.EstimatedSavings = 0
+		}
+		changes[i] = c
+	}
+	m.Changes = changes
+	m.CostsRedacted = r.policy.Costs
+	return m
+}
+
+// notifier redacts messages before delivering them
+type notifier struct {
+	next      notify.Notifier
+	redactor  *Redactor
+	instances func() []string
+}
+
+// Notifier wraps next so every message is redacted by r before it is
+// delivered. instances, if set, returns the fleet's instance names so they
+// are masked wherever a message's text names them.
+func Notifier(next notify.Notifier, r *Redactor, instances func() []string) notify.Notifier {
+	if !r.Enabled() {
+		return next
+	}
+	return &notifier{next: next, redactor: r, instances: instances}
+}
+
+// Notify delivers m to the wrapped notifier, redacted
+func (n *notifier) Notify(ctx context.Context, m notify.Message) error {
+	var instances []string
+	if n.instances != nil {
+		instances = n.instances()
+	}
+	return n.next.Notify(ctx, n.redactor.Message(m, instances))
+}
@@ -0,0 +1,50 @@
+package redact_test
+
+import (
+	"testing"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/notify"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/redact"
+)
+
+// TestMessage checks that notifications have the project, their changes'
+// instances and the other instances named masked in every field, and their
+// savings removed when costs are redacted
+func TestMessage(t *testing.T) {
+	m := notify.Message{
+		Kind:    notify.KindApplied,
+		Project: "shop-prod",
+		Changes: []notify.Change{{
+			Instance:         "orders-db",
+			InstanceID:       "shop-prod:europe-west1:orders-db",
+			Reason:           "Scaled down like billing-db, saving $120.00/month",
+			EstimatedSavings: 120,
+			Error:            "orders-db: operation conflict",
+		}},
+	}
+	r := redact.New(config.Redaction{Instances: true, Projects: true, Costs: true, Mode: config.RedactTruncate}, config.Currency{})
+
+	got := r.Message(m, []string{"billing-db"})
+	want := notify.Change{
+		Instance:   "orde***",
+		InstanceID: "shop***:europe-west1:orde***",
+		Reason:     "Scaled down like bill***, saving [redacted]/month",
+		Error:      "orde***: operation conflict",
+	}
+	if got.Project != "shop***" || !got.CostsRedacted || len(got.Changes) != 1 {
+		t.Fatalf("Message = %+v, want project shop*** with costs redacted and one change", got)
+	}
+	if c := got.Changes[0]; c.Instance != want.Instance || c.InstanceID != want.InstanceID || c.Reason != want.Reason ||
+		c.Error != want.Error || c.EstimatedSavings != 0 {
+		t.Errorf("change = %+v, want %+v", c, want)
+	}
+	if m.Changes[0].Instance != "orders-db" {
+		t.Error("Message modified the original's changes")
+	}
+
+	if got := redact.New(config.Redaction{Mode: config.RedactHash}, config.Currency{}).Message(m, nil); got.Project != m.Project ||
+		got.Changes[0].Reason != m.Changes[0].Reason || got.CostsRedacted {
+		t.Errorf("disabled redactor changed the message: %+v", got)
+	}
+}
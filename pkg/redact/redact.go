@@ -0,0 +1,229 @@
+// Package redact masks instance names, project IDs and cost figures in the
+// JSON output and notifications the autoscaler produces, so reports can be
+// forwarded outside the organization, for example to a managed-service
+// vendor. Names are replaced wherever they appear, including inside reasons
+// and errors; costs are removed.
+package redact
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+const (
+	// hashLength is how many hex digits of the hash a pseudonym keeps
+	hashLength = 12
+
+	// truncateLength is how many characters a truncated name keeps
+	truncateLength = 4
+
+	// redactedCost replaces amounts in text when costs are redacted
+	redactedCost = "[redacted]"
+)
+
+// nameToken matches the runs of characters instance names and project IDs
+// are made of, so only whole names are replaced
+var nameToken = regexp.MustCompile(`[a-z0-9][a-z0-9-]*`)
+
+// Redactor masks what a config.Redaction says to mask. It is safe for
+// concurrent use.
+type Redactor struct {
+	policy config.Redaction
+	amount *regexp.Regexp // Amounts formatted in the report currency
+}
+
+// New creates a redactor applying policy to amounts formatted in currency
+func New(policy config.Redaction, currency config.Currency) *Redactor {
+	return &Redactor{policy: policy, amount: amountPattern(currency)}
+}
+
+// amountPattern matches amounts as currency formats them, with the symbol on
+// either side and an optional sign. It is nil if currency formats no symbol.
+func amountPattern(currency config.Currency) *regexp.Regexp {
+	symbol := strings.TrimFunc(currency.Format(0), func(r rune) bool {
+		return unicode.IsDigit(r) || unicode.IsSpace(r) || r == '.' || r == ',' || r == ' '
+	})
+	if symbol == "" {
+		return nil
+	}
+	number := `\d[\d.,'\x{00a0}\x{202f}]*`
+	sym := regexp.QuoteMeta(symbol)
+	return regexp.MustCompile(`[-+]?(?:` + sym + `\s?` + number + `|` + number + `\s?` + sym + `)`)
+}
+
+// Enabled reports whether the redactor masks anything
+func (r *Redactor) Enabled() bool {
+	return r != nil && r.policy.Enabled()
+}
+
+// Costs reports whether cost figures are removed
+func (r *Redactor) Costs() bool {
+	return r.Enabled() && r.policy.Costs
+}
+
+// Instance masks an instance name, if instances are redacted
+func (r *Redactor) Instance(name string) string {
+	if !r.Enabled() || !r.policy.Instances || name == "" {
+		return name
+	}
+	return r.mask("instance", name)
+}
+
+// Project masks a project ID, if projects are redacted
+func (r *Redactor) Project(id string) string {
+	if !r.Enabled() || !r.policy.Projects || id == "" {
+		return id
+	}
+	return r.mask("project", id)
+}
+
+// mask replaces s by its pseudonym or truncation
+func (r *Redactor) mask(kind, s string) string {
+	if r.policy.Mode == config.RedactTruncate {
+		if len(s) <= truncateLength {
+			return "***"
+		}
+		return s[:truncateLength] + "***"
+	}
+	mac := hmac.New(sha256.New, []byte(r.policy.Salt))
+	mac.Write([]byte(kind + ":" + s))
+	return kind + "-" + hex.EncodeToString(mac.Sum(nil))[:hashLength]
+}
+
+// Text masks project and the instances named wherever they appear in s, and
+// amounts when costs are redacted
+func (r *Redactor) Text(s, project string, instances []string) string {
+	if !r.Enabled() || s == "" {
+		return s
+	}
+	return r.text(s, r.names(project, instances))
+}
+
+// names maps each name to mask to its replacement
+func (r *Redactor) names(project string, instances []string) map[string]string {
+	names := make(map[string]string, len(instances)+1)
+	for _, name := range instances {
+		if masked := r.Instance(name); masked != name {
+			names[name] = masked
+		}
+	}
+	if masked := r.Project(project); masked != project {
+		names[project] = masked
+	}
+	return names
+}
+
+func (r *Redactor) text(s string, names map[string]string) string {
+	if len(names) > 0 {
+		s = nameToken.ReplaceAllStringFunc(s, func(token string) string {
+			if masked, ok := names[token]; ok {
+				return masked
+			}
+			return token
+		})
+	}
+	if r.policy.Costs && r.amount != nil {
+		s = r.amount.ReplaceAllString(s, redactedCost)
+	}
+	return s
+}
+
+// JSON encodes v as indented JSON with project and the instances named masked
+// in every string, and every cost field removed when costs are redacted.
+// Fields keep the order v encodes them in.
+func (r *Redactor) JSON(v interface{}, project string, instances []string) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil || !r.Enabled() {
+		return data, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var compact bytes.Buffer
+	if err := r.rewrite(decoder, &compact, r.names(project, instances)); err != nil {
+		return nil, fmt.Errorf("failed to redact JSON output: %w", err)
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, compact.Bytes(), "", "  "); err != nil {
+		return nil, fmt.Errorf("failed to redact JSON output: %w", err)
+	}
+	return indented.Bytes(), nil
+}
+
+// rewrite copies the next JSON value from decoder to out, redacting it
+func (r *Redactor) rewrite(decoder *json.Decoder, out *bytes.Buffer, names map[string]string) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	switch token := token.(type) {
+	case json.Delim:
+		if token == '[' {
+			out.WriteByte('[')
+			for i := 0; decoder.More(); i++ {
+				if i > 0 {
+					out.WriteByte(',')
+				}
+				if err := r.rewrite(decoder, out, names); err != nil {
+					return err
+				}
+			}
+			out.WriteByte(']')
+		} else {
+			out.WriteByte('{')
+			for written := 0; decoder.More(); {
+				key, err := decoder.Token()
+				if err != nil {
+					return err
+				}
+				if r.policy.Costs && costField(key.(string)) {
+					var skipped json.RawMessage
+					if err := decoder.Decode(&skipped); err != nil {
+						return err
+					}
+					continue
+				}
+				if written > 0 {
+					out.WriteByte(',')
+				}
+				written++
+				encodeValue(out, key.(string))
+				out.WriteByte(':')
+				if err := r.rewrite(decoder, out, names); err != nil {
+					return err
+				}
+			}
+			out.WriteByte('}')
+		}
+		// The closing delimiter
+		_, err = decoder.Token()
+		return err
+	case string:
+		encodeValue(out, r.text(token, names))
+	case json.Number:
+		out.WriteString(token.String())
+	default:
+		encodeValue(out, token)
+	}
+	return nil
+}
+
+// encodeValue appends the JSON encoding of a string, bool or null
+func encodeValue(out *bytes.Buffer, v interface{}) {
+	encoded, _ := json.Marshal(v)
+	out.Write(encoded)
+}
+
+// costField reports whether a JSON key holds a cost figure
+func costField(key string) bool {
+	return strings.Contains(key, "cost") || strings.Contains(key, "saving") || strings.Contains(key, "price")
+}
@@ -0,0 +1,218 @@
+package redact_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/redact"
+)
+
+// report is shaped like the analysis output: names at several depths, costs
+// as scalars and as objects, and values that are neither
+type report struct {
+	Project   string     `json:"project"`
+	Instances []instance `json:"instances"`
+	Total     float64    `json:"total_savings"`
+}
+
+type instance struct {
+	Name             string   `json:"name"`
+	Reason           string   `json:"reason"`
+	EstimatedSavings float64  `json:"estimated_savings"`
+	CPUs             int      `json:"cpus"`
+	Cost             *costs   `json:"monthly_cost"`
+	DryRun           bool     `json:"dry_run"`
+	Owner            *string  `json:"owner"`
+	Warnings         []string `json:"warnings"`
+}
+
+type costs struct {
+	Current float64 `json:"current"`
+	Target  float64 `json:"target"`
+}
+
+var fleet = report{
+	Project: "shop-prod",
+	Instances: []instance{{
+		Name:             "orders-db",
+		Reason:           "orders-db in shop-prod saves $120.00/month; orders-db-replica is unchanged",
+		EstimatedSavings: 120,
+		CPUs:             4,
+		Cost:             &costs{Current: 300, Target: 180},
+		DryRun:           true,
+		Warnings:         []string{"orders-db: low history", "2.5 CPUs"},
+	}},
+	Total: 120,
+}
+
+// TestJSON checks what each redaction policy masks and removes in JSON
+// output, and that fields keep their order
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy config.Redaction
+		want   string
+	}{
+		{
+			name:   "disabled",
+			policy: config.Redaction{Mode: config.RedactHash},
+			want: `{
+  "project": "shop-prod",
+  "instances": [
+    {
+      "name": "orders-db",
+      "reason": "orders-db in shop-prod saves $120.00/month; orders-db-replica is unchanged",
+      "estimated_savings": 120,
+      "cpus": 4,
+      "monthly_cost": {
+        "current": 300,
+        "target": 180
+      },
+      "dry_run": true,
+      "owner": null,
+      "warnings": [
+        "orders-db: low history",
+        "2.5 CPUs"
+      ]
+    }
+  ],
+  "total_savings": 120
+}`,
+		},
+		{
+			name:   "hashed names",
+			policy: config.Redaction{Instances: true, Projects: true, Mode: config.RedactHash, Salt: "s3cret"},
+			want: `{
+  "project": "project-bde2c531f9a6",
+  "instances": [
+    {
+      "name": "instance-4b1c989a7164",
+      "reason": "instance-4b1c989a7164 in project-bde2c531f9a6 saves $120.00/month; orders-db-replica is unchanged",
+      "estimated_savings": 120,
+      "cpus": 4,
+      "monthly_cost": {
+        "current": 300,
+        "target": 180
+      },
+      "dry_run": true,
+      "owner": null,
+      "warnings": [
+        "instance-4b1c989a7164: low history",
+        "2.5 CPUs"
+      ]
+    }
+  ],
+  "total_savings": 120
+}`,
+		},
+		{
+			name:   "truncated instances",
+			policy: config.Redaction{Instances: true, Mode: config.RedactTruncate},
+			want: `{
+  "project": "shop-prod",
+  "instances": [
+    {
+      "name": "orde***",
+      "reason": "orde*** in shop-prod saves $120.00/month; orders-db-replica is unchanged",
+      "estimated_savings": 120,
+      "cpus": 4,
+      "monthly_cost": {
+        "current": 300,
+        "target": 180
+      },
+      "dry_run": true,
+      "owner": null,
+      "warnings": [
+        "orde***: low history",
+        "2.5 CPUs"
+      ]
+    }
+  ],
+  "total_savings": 120
+}`,
+		},
+		{
+			name:   "costs",
+			policy: config.Redaction{Costs: true, Mode: config.RedactHash},
+			want: `{
+  "project": "shop-prod",
+  "instances": [
+    {
+      "name": "orders-db",
+      "reason": "orders-db in shop-prod saves [redacted]/month; orders-db-replica is unchanged",
+      "cpus": 4,
+      "dry_run": true,
+      "owner": null,
+      "warnings": [
+        "orders-db: low history",
+        "2.5 CPUs"
+      ]
+    }
+  ]
+}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := redact.New(tt.policy, config.Currency{})
+			got, err := r.JSON(fleet, fleet.Project, []string{"orders-db"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("JSON =\n%s\nwant\n%s", got, tt.want)
+			}
+			if !json.Valid(got) {
+				t.Error("JSON output is not valid JSON")
+			}
+		})
+	}
+}
+
+// TestJSONNoNames checks that a redactor with nothing to mask leaves a value
+// that names no instances as it encodes
+func TestJSONNoNames(t *testing.T) {
+	r := redact.New(config.Redaction{Instances: true, Mode: config.RedactHash}, config.Currency{})
+	value := map[string]interface{}{"count": json.Number("12345678901234567890"), "empty": []string{}, "nested": map[string]string{}}
+	got, err := r.JSON(value, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := json.MarshalIndent(value, "", "  ")
+	if string(got) != string(want) {
+		t.Errorf("JSON =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestMask checks how names are masked in each mode
+func TestMask(t *testing.T) {
+	hash := redact.New(config.Redaction{Instances: true, Projects: true, Mode: config.RedactHash, Salt: "s3cret"}, config.Currency{})
+	unsalted := redact.New(config.Redaction{Instances: true, Projects: true, Mode: config.RedactHash}, config.Currency{})
+	truncate := redact.New(config.Redaction{Instances: true, Mode: config.RedactTruncate}, config.Currency{})
+	var disabled *redact.Redactor
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"hashed instance", hash.Instance("orders-db"), "instance-4b1c989a7164"},
+		{"hashed project", hash.Project("shop-prod"), "project-bde2c531f9a6"},
+		{"empty name", hash.Instance(""), ""},
+		{"truncated instance", truncate.Instance("orders-db"), "orde***"},
+		{"short name", truncate.Instance("db1"), "***"},
+		{"project not redacted", truncate.Project("shop-prod"), "shop-prod"},
+		{"nil redactor", disabled.Instance("orders-db"), "orders-db"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+	if unsalted.Instance("orders-db") == hash.Instance("orders-db") {
+		t.Error("pseudonyms do not depend on the salt")
+	}
+}
@@ -0,0 +1,78 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// Chart dimensions in SVG user units
+const (
+	chartWidth   = 560
+	chartHeight  = 140
+	chartPadding = 28  // Room for the axis labels
+	chartPoints  = 280 // Most points plotted per series
+)
+
+// Line is a horizontal reference line on a chart, such as a threshold
+type Line struct {
+	Label string
+	Value float64 // Percentage (0-100)
+	Class string  // CSS class: threshold-up, threshold-down or p95
+	Left  bool    // Label at the left end, so it does not collide with right-hand labels
+}
+
+// Chart renders percentages (0-100) sampled at timestamps as an inline SVG
+// line chart with the given reference lines. Like Sparkline it plots the
+// peak of each span of samples.
+func Chart(title string, timestamps []time.Time, percentages []float64, lines []Line) template.HTML {
+	n := len(percentages)
+	if len(timestamps) < n {
+		n = len(timestamps)
+	}
+	if n < 2 {
+		return template.HTML(fmt.Sprintf(`<p class="nodata">%s: not enough data to chart</p>`, template.HTMLEscapeString(title)))
+	}
+
+	peaks := downsample(percentages[:n], chartPoints)
+	plotW := float64(chartWidth - 2*chartPadding)
+	plotH := float64(chartHeight - 2*chartPadding)
+	x := func(i int) float64 { return chartPadding + plotW*float64(i)/float64(len(peaks)-1) }
+	y := func(v float64) float64 { return chartPadding + plotH*(1-clamp(v, 0, 100)/100) }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" viewBox="0 0 %d %d" role="img" aria-label="%s">`, chartWidth, chartHeight, template.HTMLEscapeString(title))
+	fmt.Fprintf(&b, `<text class="title" x="%d" y="16">%s</text>`, chartPadding, template.HTMLEscapeString(title))
+	fmt.Fprintf(&b, `<rect class="frame" x="%d" y="%d" width="%.0f" height="%.0f"/>`, chartPadding, chartPadding, plotW, plotH)
+	for _, pct := range []float64{0, 50, 100} {
+		fmt.Fprintf(&b, `<text class="axis" x="%d" y="%.1f" text-anchor="end">%.0f%%</text>`, chartPadding-4, y(pct)+4, pct)
+	}
+
+	for _, l := range lines {
+		fmt.Fprintf(&b, `<line class="%s" x1="%d" x2="%.0f" y1="%.1f" y2="%.1f"/>`,
+			template.HTMLEscapeString(l.Class), chartPadding, chartPadding+plotW, y(l.Value), y(l.Value))
+		labelX, anchor := chartPadding+plotW-4, "end"
+		if l.Left {
+			labelX, anchor = chartPadding+4, "start"
+		}
+		fmt.Fprintf(&b, `<text class="label %s" x="%.0f" y="%.1f" text-anchor="%s">%s %.0f%%</text>`,
+			template.HTMLEscapeString(l.Class), labelX, y(l.Value)-3, anchor, template.HTMLEscapeString(l.Label), l.Value)
+	}
+
+	points := make([]string, len(peaks))
+	for i, v := range peaks {
+		points[i] = fmt.Sprintf("%.1f,%.1f", x(i), y(v))
+	}
+	fmt.Fprintf(&b, `<polyline class="series" points="%s"/>`, strings.Join(points, " "))
+
+	const layout = "Jan 2 15:04"
+	fmt.Fprintf(&b, `<text class="axis" x="%d" y="%d">%s</text>`, chartPadding, chartHeight-8,
+		config.InDisplayLocation(timestamps[0]).Format(layout))
+	fmt.Fprintf(&b, `<text class="axis" x="%.0f" y="%d" text-anchor="end">%s %s</text>`, chartPadding+plotW, chartHeight-8,
+		config.InDisplayLocation(timestamps[n-1]).Format(layout), template.HTMLEscapeString(config.DisplayLocation().String()))
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
@@ -0,0 +1,132 @@
+package report
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/analyzer"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+//go:embed report.html
+var reportTemplate string
+
+var htmlTemplate = template.Must(template.New("report").Parse(reportTemplate))
+
+// Report is the data behind the HTML report of a project analysis
+type Report struct {
+	ProjectID   string
+	Profile     string
+	DryRun      bool
+	GeneratedAt time.Time
+	Summary     *analyzer.ProjectSummary
+	Savings     string // Formatted net estimated monthly savings; negative for a cost increase
+	Skipped     []cloudsql.SkippedInstance
+	Instances   []*Instance
+
+	DiskShrinkSavings string // Formatted monthly savings of migrating over-provisioned disks
+}
+
+// Instance is one instance's section of the report
+type Instance struct {
+	Name            string
+	Owner           string
+	CurrentType     string
+	RecommendedType string
+	Action          string // One of the analyzer.Action values
+	Status          string // Why an operation did or did not run, e.g. "deferred (cooldown): ..."
+	Reason          string
+	ReasonCodes     []cloudsql.ReasonCode
+	Savings         string // Formatted estimated monthly savings; empty when no change is recommended
+	CPUChart        template.HTML
+	MemoryChart     template.HTML
+	Warnings        []string
+
+	DiskShrink       string // Right-sized disk and its savings; empty unless the disk is over-provisioned
+	DiskShrinkReason string
+}
+
+// New builds the report of the ranked results of a project analysis. plan
+// says which operations were deferred; cfg supplies the thresholds drawn on
+// the charts.
+func New(results *analyzer.ProjectAnalysisResult, ranked []*analyzer.AnalysisResult, plan *analyzer.ScalingPlan,
+	cfg *config.Config, profile string, dryRun bool) *Report {
+	summary := results.Summarize(0, 0)
+	r := &Report{
+		ProjectID:   results.ProjectID,
+		Profile:     profile,
+		DryRun:      dryRun,
+		GeneratedAt: config.InDisplayLocation(time.Now()),
+		Summary:     summary,
+		Savings:     cfg.Currency.Format(summary.TotalSavings),
+		Skipped:     results.Skipped,
+
+		DiskShrinkSavings: cfg.Currency.Format(summary.DiskShrinkSavings),
+	}
+	for _, result := range ranked {
+		r.Instances = append(r.Instances, newInstance(result, plan, cfg))
+	}
+	return r
+}
+
+// newInstance builds the report section of one result
+func newInstance(result *analyzer.AnalysisResult, plan *analyzer.ScalingPlan, cfg *config.Config) *Instance {
+	decision := result.Decision
+	inst := &Instance{
+		Name:        result.Instance.Name,
+		Owner:       result.Owner.String(),
+		CurrentType: result.Instance.MachineType,
+		Action:      analyzer.DecisionAction(decision),
+		Reason:      decision.Reason,
+		ReasonCodes: decision.ReasonCodes,
+	}
+	if decision.ShouldScale {
+		inst.RecommendedType = decision.RecommendedType
+		inst.Savings = cfg.Currency.Format(decision.EstimatedSavings)
+		inst.Status = "recommended"
+		if d, ok := plan.IsDeferred(inst.Name); ok {
+			inst.Status = fmt.Sprintf("deferred (%s): %s", d.DeferKind, d.DeferReason)
+		}
+	}
+	for _, w := range result.Warnings {
+		inst.Warnings = append(inst.Warnings, w.Message)
+	}
+	if d := result.DiskShrink; d != nil {
+		inst.DiskShrink = fmt.Sprintf("%d → %d GB · savings %s/month after migrating", d.CurrentSizeGB, d.RecommendedSizeGB,
+			cfg.Currency.Format(d.EstimatedSavings))
+		inst.DiskShrinkReason = d.Reason
+	}
+
+	thresholds := []Line{
+		{Label: "scale up", Value: cfg.ScaleUpThreshold * 100, Class: "threshold-up"},
+		{Label: "scale down", Value: cfg.ScaleDownThreshold * 100, Class: "threshold-down"},
+	}
+	if m, s := result.Metrics, result.Summary; m != nil && s != nil {
+		inst.CPUChart = Chart("CPU utilization", m.Timestamps, m.CPUUtilization,
+			append(thresholds, Line{Label: "P95", Value: s.CPUP95, Class: "p95", Left: true}))
+		inst.MemoryChart = Chart("Memory utilization", m.Timestamps, m.MemoryPercent,
+			append(thresholds, Line{Label: "P95", Value: s.MemoryP95Pct, Class: "p95", Left: true}))
+	}
+	return inst
+}
+
+// MarkApplied records that the named instance's operation was applied
+func (r *Report) MarkApplied(name string) {
+	for _, inst := range r.Instances {
+		if inst.Name == name {
+			inst.Status = "applied"
+		}
+	}
+}
+
+// WriteHTML writes the report as a standalone HTML page
+func (r *Report) WriteHTML(w io.Writer) error {
+	if err := htmlTemplate.Execute(w, r); err != nil {
+		return fmt.Errorf("failed to render HTML report: %w", err)
+	}
+	return nil
+}
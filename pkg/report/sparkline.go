@@ -0,0 +1,59 @@
+// Package report renders analysis results for people: sparklines for the
+// CLI table and a standalone HTML report with utilization charts.
+package report
+
+import (
+	"math"
+	"strings"
+)
+
+// sparkBlocks are the sparkline levels, lowest first
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// DefaultSparklineWidth is the number of characters in a CLI sparkline
+const DefaultSparklineWidth = 24
+
+// Sparkline renders percentages (0-100) as width block characters. Each
+// character shows the peak of its share of the samples, so short spikes that
+// drive P95 stay visible. The scale is fixed at 0-100% so sparklines of
+// different instances compare directly.
+func Sparkline(percentages []float64, width int) string {
+	buckets := downsample(percentages, width)
+	if len(buckets) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, v := range buckets {
+		level := int(math.Round(clamp(v, 0, 100) / 100 * float64(len(sparkBlocks)-1)))
+		b.WriteRune(sparkBlocks[level])
+	}
+	return b.String()
+}
+
+// downsample splits values into at most n consecutive buckets and returns the
+// peak of each
+func downsample(values []float64, n int) []float64 {
+	if n <= 0 || len(values) == 0 {
+		return nil
+	}
+	if len(values) <= n {
+		return values
+	}
+
+	peaks := make([]float64, n)
+	for i := range peaks {
+		start, end := i*len(values)/n, (i+1)*len(values)/n
+		peak := values[start]
+		for _, v := range values[start:end] {
+			peak = math.Max(peak, v)
+		}
+		peaks[i] = peak
+	}
+	return peaks
+}
+
+// clamp limits v to [lo, hi]
+func clamp(v, lo, hi float64) float64 {
+	return math.Min(math.Max(v, lo), hi)
+}
@@ -0,0 +1,50 @@
+package rules
+
+import (
+	"fmt"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// CheckConnectionCapacity compares the peak connections behind a scale-down
+// with the default max_connections of its smaller machine type. Instances
+// with the max_connections flag set keep their limit and are not checked.
+// When peak connections would saturate the new limit, the scale-down is
+// held back under the block policy, and recommended with a critical warning
+// under the warn policy; otherwise decision and a nil warning are returned.
+func (e *Engine) CheckConnectionCapacity(instance *config.InstanceInfo, metrics *config.MetricsSummary, decision *cloudsql.ScalingDecision) (*cloudsql.ScalingDecision, *Warning) {
+	if !decision.ShouldScale {
+		return decision, nil
+	}
+	if up, err := config.IsUpscale(decision.CurrentType, decision.RecommendedType); err != nil || up {
+		return decision, nil
+	}
+	if _, set := instance.DatabaseFlags["max_connections"]; set {
+		return decision, nil
+	}
+	limit := config.DefaultMaxConnections(instance.DatabaseVersion, decision.RecommendedType)
+	if limit == 0 || float64(metrics.ConnectionsMax) < connectionSaturation*float64(limit) {
+		return decision, nil
+	}
+
+	why := fmt.Sprintf("peak connections (%d) would saturate the default max_connections of %d on %s",
+		metrics.ConnectionsMax, limit, decision.RecommendedType)
+	if e.config.ConnectionCapacityPolicy == config.ConnectionCapacityWarn {
+		return decision, &Warning{
+			Code:     WarningConnectionCapacity,
+			Severity: SeverityCritical,
+			Message:  fmt.Sprintf("Scaling down: %s. Set the max_connections flag or reduce connections first.", why),
+			Data: map[string]interface{}{
+				"connections_max": metrics.ConnectionsMax, "target_max_connections": limit, "target_type": decision.RecommendedType,
+			},
+		}
+	}
+	return &cloudsql.ScalingDecision{
+		CurrentType:     instance.MachineType,
+		RecommendedType: instance.MachineType,
+		Reason:          fmt.Sprintf("Scale-down to %s held: %s", decision.RecommendedType, why),
+		ReasonCodes:     append([]cloudsql.ReasonCode{cloudsql.ReasonConnectionCapacity}, decision.ReasonCodes...),
+		Metrics:         decision.Metrics,
+	}, nil
+}
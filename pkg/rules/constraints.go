@@ -15,51 +15,119 @@ type ScalingWindow struct {
 }
 
 // CheckScalingConstraints validates all constraints for a scaling operation
-func CheckScalingConstraints(instance *config.InstanceInfo, metrics *config.MetricsSummary, cfg *config.Config) []string {
-	var warnings []string
+// made at now
+func CheckScalingConstraints(instance *config.InstanceInfo, metrics *config.MetricsSummary, cfg *config.Config, now time.Time) []Warning {
+	var warnings []Warning
 
 	// Check data completeness
 	expectedDataPoints := int(cfg.MetricsPeriod / cfg.MetricsInterval)
 	dataCompleteness := float64(metrics.DataPoints) / float64(expectedDataPoints) * 100
 
 	if dataCompleteness < 80 {
-		warnings = append(warnings,
-			fmt.Sprintf("Limited metrics data available (%.0f%% complete). Recommendations may be less accurate.",
-				dataCompleteness))
+		severity := SeverityWarning
+		if dataCompleteness < 50 {
+			severity = SeverityCritical
+		}
+		warnings = append(warnings, Warning{
+			Code:     WarningLimitedData,
+			Severity: severity,
+			Message: fmt.Sprintf("Limited metrics data available (%.0f%% complete). Recommendations may be less accurate.",
+				dataCompleteness),
+			Data: map[string]interface{}{"completeness_pct": dataCompleteness, "data_points": metrics.DataPoints, "expected_data_points": expectedDataPoints},
+		})
 	}
 
 	// Check for recent scaling operations
 	if !instance.LastScaledTime.IsZero() {
-		timeSinceScale := time.Since(instance.LastScaledTime)
-		if timeSinceScale < cfg.CoolDownPeriod {
-			warnings = append(warnings,
-				fmt.Sprintf("Instance was scaled recently (%.0f minutes ago). Consider waiting for cooldown period.",
-					timeSinceScale.Minutes()))
+		timeSinceScale, cooldown := now.Sub(instance.LastScaledTime), cfg.CoolDownFor(instance.Name)
+		if timeSinceScale < cooldown {
+			warnings = append(warnings, Warning{
+				Code:     WarningRecentlyScaled,
+				Severity: SeverityWarning,
+				Message: fmt.Sprintf("Instance was scaled recently (%.0f minutes ago). Consider waiting for cooldown period.",
+					timeSinceScale.Minutes()),
+				Data: map[string]interface{}{"last_scaled": instance.LastScaledTime, "cooldown_ends": instance.LastScaledTime.Add(cooldown)},
+			})
 		}
 	}
 
 	// Check for high availability configuration
 	if instance.HighAvailability {
-		warnings = append(warnings,
-			"Instance has high availability enabled. Scaling will affect both primary and standby instances.")
+		warnings = append(warnings, Warning{
+			Code:     WarningHighAvailability,
+			Severity: SeverityInfo,
+			Message:  "Instance has high availability enabled. Scaling will affect both primary and standby instances.",
+		})
+	}
+
+	// Note data cache effect on memory interpretation
+	if DataCacheAbsorbsMemoryPressure(instance, metrics, cfg) && metrics.MemoryP95Pct > cfg.ScaleUpThreshold*100 {
+		warnings = append(warnings, Warning{
+			Code:     WarningDataCacheAbsorbs,
+			Severity: SeverityInfo,
+			Message: fmt.Sprintf("Memory P95 is %.1f%% but the data cache hit ratio is %.1f%%. Memory pressure alone will not trigger scale-up.",
+				metrics.MemoryP95Pct, metrics.DataCacheHitRatio),
+			Data: map[string]interface{}{"memory_p95_pct": metrics.MemoryP95Pct, "data_cache_hit_ratio": metrics.DataCacheHitRatio},
+		})
+	}
+
+	// Note memory utilization the engine's memory pressure mode discounts
+	if threshold := cfg.ScaleUpThreshold * 100; metrics.MemoryP95Pct > threshold {
+		mode := cfg.MemoryPressureFor(instance.DatabaseVersion)
+		data := map[string]interface{}{"memory_p95_pct": metrics.MemoryP95Pct, "mode": string(mode)}
+		if memory, _ := MemoryPressure(instance, metrics, cfg); memory <= threshold {
+			data["noncache_p95_pct"] = memory
+			warnings = append(warnings, Warning{
+				Code:     WarningCacheInflated,
+				Severity: SeverityInfo,
+				Message: fmt.Sprintf("Memory P95 is %.1f%% but only %.1f%% is used outside the page cache. Memory pressure alone will not trigger scale-up.",
+					metrics.MemoryP95Pct, memory),
+				Data: data,
+			})
+		} else if !MemoryPressureCorroborated(instance, metrics, cfg) {
+			warnings = append(warnings, Warning{
+				Code:     WarningCacheInflated,
+				Severity: SeverityInfo,
+				Message: fmt.Sprintf("Memory P95 is %.1f%% with no swapping, pending memory grants or connection saturation to corroborate it. Memory pressure alone will not trigger scale-up.",
+					metrics.MemoryP95Pct),
+				Data: data,
+			})
+		}
+	}
+
+	// Note the engine-specific scale-up threshold for licensed engines
+	if config.ParseEngine(instance.DatabaseVersion) == config.EngineSQLServer {
+		rate := config.LicenseHourlyRatePerVCPU(instance.DatabaseVersion)
+		if rate > 0 {
+			warnings = append(warnings, Warning{
+				Code:     WarningSQLServerLicensing,
+				Severity: SeverityInfo,
+				Message: fmt.Sprintf("SQL Server licensing costs %s per vCPU per month; scale-ups use a stricter %.0f%% threshold.",
+					cfg.Currency.Format(rate*24*30), cfg.SQLServerScaleUpThreshold*100),
+				Data: map[string]interface{}{"license_usd_per_vcpu_month": rate * 24 * 30, "scale_up_threshold_pct": cfg.SQLServerScaleUpThreshold * 100},
+			})
+		}
 	}
 
 	// Check backup windows
 	if instance.BackupEnabled {
-		warnings = append(warnings,
-			"Instance has backups enabled. Avoid scaling during backup windows.")
+		warnings = append(warnings, Warning{
+			Code:     WarningBackupsEnabled,
+			Severity: SeverityInfo,
+			Message:  "Instance has backups enabled. Avoid scaling during backup windows.",
+		})
 	}
 
 	return warnings
 }
 
-// GetOptimalScalingWindow suggests the best time window for scaling
-func GetOptimalScalingWindow(metrics *config.MetricsData, constraints config.ScalingConstraints) *ScalingWindow {
+// GetOptimalScalingWindow suggests the best time window after now for scaling
+func GetOptimalScalingWindow(metrics *config.MetricsData, constraints config.ScalingConstraints, now time.Time) *ScalingWindow {
 	// For Enterprise Plus with no downtime (within intervals), any time is fine
 	if !constraints.DowntimeOnScale {
 		return &ScalingWindow{
-			Start:    time.Now(),
-			End:      time.Now().Add(24 * time.Hour),
+			Start:    now,
+			End:      now.Add(24 * time.Hour),
 			Duration: 24 * time.Hour,
 		}
 	}
@@ -69,8 +137,8 @@ func GetOptimalScalingWindow(metrics *config.MetricsData, constraints config.Sca
 	lowestUsageHour := findLowestUsageHour(metrics)
 
 	// Suggest maintenance window during low usage
-	windowStart := time.Now().Truncate(24 * time.Hour).Add(time.Duration(lowestUsageHour) * time.Hour)
-	if windowStart.Before(time.Now()) {
+	windowStart := now.Truncate(24 * time.Hour).Add(time.Duration(lowestUsageHour) * time.Hour)
+	if windowStart.Before(now) {
 		windowStart = windowStart.Add(24 * time.Hour)
 	}
 
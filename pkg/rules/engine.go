@@ -1,6 +1,7 @@
 package rules
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -8,16 +9,35 @@ import (
 	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
 )
 
+// minDataPoints is the fewest data points a decision is based on
+const minDataPoints = 10
+
 // Engine is the scaling rules engine
 type Engine struct {
 	config *config.Config
+
+	// Custom conditions compiled from the config; nil uses the thresholds
+	scaleUpRule   *Rule
+	scaleDownRule *Rule
 }
 
-// NewEngine creates a new scaling rules engine
-func NewEngine(cfg *config.Config) *Engine {
-	return &Engine{
+// NewEngine creates a new scaling rules engine, compiling cfg's custom rules
+func NewEngine(cfg *config.Config) (*Engine, error) {
+	e := &Engine{
 		config: cfg,
 	}
+	var err error
+	if cfg.ScaleUpRule != "" {
+		if e.scaleUpRule, err = CompileRule(cfg.ScaleUpRule); err != nil {
+			return nil, fmt.Errorf("invalid scale-up rule: %w", err)
+		}
+	}
+	if cfg.ScaleDownRule != "" {
+		if e.scaleDownRule, err = CompileRule(cfg.ScaleDownRule); err != nil {
+			return nil, fmt.Errorf("invalid scale-down rule: %w", err)
+		}
+	}
+	return e, nil
 }
 
 // AnalyzeInstance analyzes an instance and provides scaling recommendations
@@ -27,93 +47,433 @@ func (e *Engine) AnalyzeInstance(instance *config.InstanceInfo, metrics *config.
 		Metrics:     metrics,
 	}
 
+	// Failover and DR replicas are never scaled independently of their primary
+	if instance.IsFailoverReplica {
+		decision.ShouldScale = false
+		decision.Reason = fmt.Sprintf("Failover/DR replica of %s is not scaled independently (replica policy: %s)",
+			instance.PrimaryInstance, e.config.ReplicaPolicy)
+		decision.ReasonCodes = []cloudsql.ReasonCode{cloudsql.ReasonFailoverReplica}
+		return decision, nil
+	}
+
 	// Check if we have enough data
-	if metrics.DataPoints < 10 {
+	if metrics.DataPoints < minDataPoints {
 		decision.ShouldScale = false
 		decision.Reason = "Insufficient metrics data for analysis"
+		decision.ReasonCodes = []cloudsql.ReasonCode{cloudsql.ReasonInsufficientData}
 		return decision, nil
 	}
 
-	// Determine if scaling is needed based on utilization
-	scaleUp := e.shouldScaleUp(metrics)
-	scaleDown := e.shouldScaleDown(metrics)
+	// Determine if scaling is needed based on utilization, or on a climb
+	// that will reach the scale-up threshold before percentiles catch up
+	codes, err := e.scaleUpCodes(instance, metrics)
+	if err != nil {
+		return nil, err
+	}
+	scaleUp := len(codes) > 0
+	trend, trendUp := "", false
+	if !scaleUp {
+		var code cloudsql.ReasonCode
+		trend, code, trendUp = e.risingTrend(instance, metrics)
+		if trendUp {
+			codes = []cloudsql.ReasonCode{code}
+		}
+		scaleUp = trendUp
+	}
+	scaleDown := false
+	if !scaleUp {
+		if scaleDown, err = e.shouldScaleDown(instance, metrics); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case scaleDown && e.scaleDownRule != nil:
+		codes = []cloudsql.ReasonCode{cloudsql.ReasonScaleDownRule}
+	case scaleDown:
+		codes = []cloudsql.ReasonCode{cloudsql.ReasonCPUP95Low, cloudsql.ReasonMemoryP95Low}
+	case !scaleUp:
+		codes = []cloudsql.ReasonCode{cloudsql.ReasonWithinTarget}
+	}
+
+	// Without a catalog entry there is no known next tier to move to
+	if instance.UnsupportedTier {
+		decision.ShouldScale = false
+		switch {
+		case scaleUp:
+			decision.Reason = "Advisory: would scale up"
+		case scaleDown:
+			decision.Reason = "Advisory: would scale down"
+		default:
+			decision.Reason = "Advisory: utilization is within target range"
+		}
+		decision.Reason += fmt.Sprintf(" (CPU P95: %.1f%%, Memory P95: %.1f%%), but tier %s is not in the machine type catalog",
+			metrics.CPUP95, metrics.MemoryP95Pct, instance.MachineType)
+		decision.ReasonCodes = append([]cloudsql.ReasonCode{cloudsql.ReasonUnsupportedTier}, codes...)
+		return decision, nil
+	}
 
 	if !scaleUp && !scaleDown {
 		decision.ShouldScale = false
 		decision.Reason = fmt.Sprintf("Current utilization is within target range (CPU: %.1f%%, Memory: %.1f%%)",
 			metrics.CPUP95, metrics.MemoryP95Pct)
+		decision.ReasonCodes = codes
 		return decision, nil
 	}
 
-	// Determine target machine type
-	var targetType string
-	var err error
+	// A replica that is already behind falls further behind on a smaller
+	// machine type
+	if scaleDown && e.replicaLagging(instance, metrics) {
+		decision.ShouldScale = false
+		decision.Reason = fmt.Sprintf("Scale-down held: replication lag P95 is %.0fs, above the maximum of %v (CPU P95: %.1f%%, Memory P95: %.1f%%)",
+			metrics.ReplicaLagP95Seconds, e.config.MaxReplicaLag, metrics.CPUP95, metrics.MemoryP95Pct)
+		decision.ReasonCodes = append([]cloudsql.ReasonCode{cloudsql.ReasonReplicaLagHold}, codes...)
+		return decision, nil
+	}
+
+	// Determine target machine type among those offered for the instance's
+	// edition and engine that may be a target
+	targetType, denied, err := e.nextMachineType(instance, scaleUp)
+	var maxTier *maxTierError
+	if errors.As(err, &maxTier) {
+		decision.ShouldScale = false
+		decision.Reason = fmt.Sprintf("Cannot scale up: %v", err)
+		decision.ReasonCodes = append([]cloudsql.ReasonCode{cloudsql.ReasonMaxTier}, codes...)
+		return decision, nil
+	}
+	if err != nil && denied.why != "" {
+		decision.ShouldScale = false
+		decision.Reason = fmt.Sprintf("Cannot scale %s: %s, and no other machine type is allowed", direction(scaleUp), denied.why)
+		decision.ReasonCodes = append([]cloudsql.ReasonCode{denied.code}, codes...)
+		return decision, nil
+	}
 
 	if scaleUp {
-		targetType, err = config.GetNextLargerMachineType(instance.MachineType)
 		if err != nil {
 			decision.ShouldScale = false
 			decision.Reason = fmt.Sprintf("Cannot scale up: %v", err)
+			decision.ReasonCodes = append([]cloudsql.ReasonCode{cloudsql.ReasonAtMaxSize}, codes...)
 			return decision, nil
 		}
-		decision.Reason = fmt.Sprintf("High resource utilization detected (CPU P95: %.1f%%, Memory P95: %.1f%%)",
-			metrics.CPUP95, metrics.MemoryP95Pct)
+		if trendUp {
+			decision.Reason = fmt.Sprintf("Preemptive scale-up: %s (CPU P95: %.1f%%, Memory P95: %.1f%%)",
+				trend, metrics.CPUP95, metrics.MemoryP95Pct)
+		} else if codes[0] == cloudsql.ReasonScaleUpRule {
+			decision.Reason = fmt.Sprintf("Scale-up rule matched: %s (CPU P95: %.1f%%, Memory P95: %.1f%%)",
+				e.scaleUpRule, metrics.CPUP95, metrics.MemoryP95Pct)
+		} else if codes[0] == cloudsql.ReasonReplicaLagSustained {
+			decision.Reason = fmt.Sprintf("Replication lag sustained above the maximum of %v (at least %.0fs over the last %v; CPU P95: %.1f%%, Memory P95: %.1f%%)",
+				e.config.MaxReplicaLag, metrics.ReplicaLagSustainedSeconds, e.config.MinStableDuration, metrics.CPUP95, metrics.MemoryP95Pct)
+		} else {
+			decision.Reason = fmt.Sprintf("High resource utilization detected (CPU P95: %.1f%%, Memory P95: %.1f%%)",
+				metrics.CPUP95, metrics.MemoryP95Pct)
+		}
 	} else {
-		targetType, err = config.GetNextSmallerMachineType(instance.MachineType)
 		if err != nil {
 			decision.ShouldScale = false
 			decision.Reason = fmt.Sprintf("Cannot scale down: %v", err)
+			decision.ReasonCodes = append([]cloudsql.ReasonCode{cloudsql.ReasonAtMinSize}, codes...)
 			return decision, nil
 		}
-		decision.Reason = fmt.Sprintf("Low resource utilization detected (CPU P95: %.1f%%, Memory P95: %.1f%%)",
-			metrics.CPUP95, metrics.MemoryP95Pct)
+		if judged := scaleDownMetrics(metrics); e.scaleDownRule != nil {
+			decision.Reason = fmt.Sprintf("Scale-down rule matched: %s (CPU P95: %.1f%%, Memory P95: %.1f%%)",
+				e.scaleDownRule, judged.CPUP95, judged.MemoryP95Pct)
+		} else if judged != metrics {
+			decision.Reason = fmt.Sprintf("Low resource utilization detected during business hours (CPU P95: %.1f%%, Memory P95: %.1f%%)",
+				judged.CPUP95, judged.MemoryP95Pct)
+		} else {
+			decision.Reason = fmt.Sprintf("Low resource utilization detected (CPU P95: %.1f%%, Memory P95: %.1f%%)",
+				metrics.CPUP95, metrics.MemoryP95Pct)
+		}
 	}
 
 	decision.ShouldScale = true
+	decision.ReasonCodes = codes
+	if denied.why != "" {
+		decision.Reason += fmt.Sprintf("; %s, so %s is recommended instead", denied.why, targetType)
+		decision.ReasonCodes = append(decision.ReasonCodes, denied.code)
+	}
 	decision.RecommendedType = targetType
+	decision.ID = cloudsql.NewDecisionID()
+	e.estimateImpact(decision, instance, scaleUp)
+
+	return decision, nil
+}
+
+// restriction is why the machine type that would otherwise have been chosen
+// as a target was ruled out
+type restriction struct {
+	why  string              // e.g. "db-e2-standard-4 is denylisted (db-e2-*)"; empty if none was
+	code cloudsql.ReasonCode // TARGET_DENYLISTED or TARGET_NOT_ALLOWED
+}
+
+// nextMachineType returns the next larger or smaller machine type offered for
+// instance's edition and engine that the denylist and allowlist admit. When
+// they rule out the type that would otherwise be chosen, denied says which
+// and why. Larger types above the instance's max-tier label are a
+// *maxTierError.
+func (e *Engine) nextMachineType(instance *config.InstanceInfo, scaleUp bool) (target string, denied restriction, err error) {
+	target, denied, err = e.nearestAdmitted(instance, scaleUp)
+	if err == nil && scaleUp && AboveMaxTier(instance, target) {
+		return "", denied, &maxTierError{target: target, limit: instance.MaxTier}
+	}
+	return target, denied, err
+}
+
+// nearestAdmitted returns the next larger or smaller machine type that the
+// denylist and allowlist admit, see nextMachineType
+func (e *Engine) nearestAdmitted(instance *config.InstanceInfo, scaleUp bool) (target string, denied restriction, err error) {
+	next := config.GetNextSmallerMachineTypeWhere
+	if scaleUp {
+		next = config.GetNextLargerMachineTypeWhere
+	}
+	available := config.AvailableFor(instance.Edition, instance.DatabaseVersion)
+
+	nearest, err := next(instance.MachineType, available)
+	if err != nil {
+		return "", restriction{}, err
+	}
+	why, ok := e.config.RestrictsTarget(nearest)
+	if !ok {
+		return nearest, restriction{}, nil
+	}
+	denied = restriction{why: why, code: cloudsql.ReasonTargetNotAllowed}
+	if _, listed := e.config.DeniedMachineTypes.Denies(nearest); listed {
+		denied.code = cloudsql.ReasonTargetDenylisted
+	}
+	target, err = next(instance.MachineType, e.config.AdmittingTargets(available))
+	return target, denied, err
+}
+
+// Retarget moves decision to machineType, e.g. one a decision hook chose,
+// resizing up when scaleUp is set, and estimates its downtime and cost
+// savings again for it
+func (e *Engine) Retarget(instance *config.InstanceInfo, decision *cloudsql.ScalingDecision, machineType string, scaleUp bool) {
+	decision.RecommendedType = machineType
+	e.estimateDowntime(decision, instance, scaleUp)
+	decision.EstimatedSavings = cloudsql.EstimateCostSavings(
+		instance.MachineType, machineType, instance.Region, instance.Edition, instance.DatabaseVersion, e.config.Commitment)
+}
 
-	// Check for downtime implications
+// estimateDowntime fills in whether resizing the instance in the decision's
+// direction causes downtime, and why
+func (e *Engine) estimateDowntime(decision *cloudsql.ScalingDecision, instance *config.InstanceInfo, isUpscale bool) {
 	constraints := config.GetScalingConstraints(instance.Edition)
 	if constraints.DowntimeOnScale {
 		decision.DowntimeExpected = true
 		decision.DowntimeReason = "Enterprise edition requires downtime for all scaling operations"
-	} else {
-		// Check Enterprise Plus timing constraints
-		decision.DowntimeExpected, decision.DowntimeReason = e.checkDowntimeForEnterprisePlus(
-			instance, scaleUp)
+		return
+	}
+	// Check Enterprise Plus timing constraints
+	decision.DowntimeExpected, decision.DowntimeReason, decision.DowntimeFreeAt = e.checkDowntimeForEnterprisePlus(
+		instance, isUpscale)
+}
+
+// direction names the way a decision scales
+func direction(scaleUp bool) string {
+	if scaleUp {
+		return "up"
 	}
+	return "down"
+}
+
+// estimateImpact fills in the downtime and cost implications of moving the
+// instance to decision.RecommendedType
+func (e *Engine) estimateImpact(decision *cloudsql.ScalingDecision, instance *config.InstanceInfo, isUpscale bool) {
+	targetType := decision.RecommendedType
+	e.estimateDowntime(decision, instance, isUpscale)
 
 	// Estimate cost savings
 	decision.EstimatedSavings = cloudsql.EstimateCostSavings(
-		instance.MachineType, targetType, instance.Region)
-
-	return decision, nil
+		instance.MachineType, targetType, instance.Region, instance.Edition, instance.DatabaseVersion, e.config.Commitment)
+	if commitment := e.config.Commitment; commitment.Enabled() && !isUpscale {
+		onDemand := cloudsql.EstimateCostSavings(
+			instance.MachineType, targetType, instance.Region, instance.Edition, instance.DatabaseVersion, config.Commitment{})
+		if excluded := onDemand - decision.EstimatedSavings; excluded > 0.005 {
+			decision.Reason += fmt.Sprintf("; %s/month of the reduction falls within the %s commitment covering %.0f%% of compute and is not saved",
+				e.config.Currency.Format(excluded), commitment.Term, commitment.Coverage*100)
+		}
+	}
+	if delta := cloudsql.EstimateLicenseCostDelta(instance.MachineType, targetType, instance.DatabaseVersion); delta != 0 {
+		decision.Reason += fmt.Sprintf("; license cost change %s/month", e.config.Currency.FormatSigned(delta))
+	}
 }
 
-// shouldScaleUp determines if instance should be scaled up
-func (e *Engine) shouldScaleUp(metrics *config.MetricsSummary) bool {
+// scaleUpCodes returns the reasons the instance should be scaled up, if any:
+// its scale-up rule when one is set, otherwise the scale-up threshold, and
+// sustained replication lag
+func (e *Engine) scaleUpCodes(instance *config.InstanceInfo, metrics *config.MetricsSummary) ([]cloudsql.ReasonCode, error) {
+	var codes []cloudsql.ReasonCode
+	if e.scaleUpRule != nil {
+		matched, err := e.scaleUpRule.Matches(instance, metrics, e.config)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			codes = append(codes, cloudsql.ReasonScaleUpRule)
+		}
+		if e.replicaLagSustained(instance, metrics) {
+			codes = append(codes, cloudsql.ReasonReplicaLagSustained)
+		}
+		return codes, nil
+	}
+
 	// Scale up if P95 utilization exceeds threshold
-	cpuExceeds := metrics.CPUP95 > (e.config.ScaleUpThreshold * 100)
-	memoryExceeds := metrics.MemoryP95Pct > (e.config.ScaleUpThreshold * 100)
+	threshold := e.ScaleUpThreshold(instance)
+	cpuExceeds := metrics.CPUP95 > (threshold * 100)
+	memory, _ := MemoryPressure(instance, metrics, e.config)
+	memoryExceeds := memory > (threshold * 100)
+
+	// Page cache inflates memory utilization on healthy instances, so the
+	// engine's memory pressure mode may require another sign of trouble
+	if memoryExceeds && !MemoryPressureCorroborated(instance, metrics, e.config) {
+		memoryExceeds = false
+	}
+
+	// A healthy data cache absorbs working-set growth, so memory pressure
+	// alone is not a reason to scale up
+	if memoryExceeds && DataCacheAbsorbsMemoryPressure(instance, metrics, e.config) {
+		memoryExceeds = false
+	}
+
+	if cpuExceeds {
+		codes = append(codes, cloudsql.ReasonCPUP95High)
+	}
+	if memoryExceeds {
+		codes = append(codes, cloudsql.ReasonMemoryP95High)
+	}
+	if e.replicaLagSustained(instance, metrics) {
+		codes = append(codes, cloudsql.ReasonReplicaLagSustained)
+	}
+	return codes, nil
+}
+
+// replicaLagSustained reports whether instance is a read replica whose
+// replication lag stayed above the maximum throughout the stable duration,
+// so replay cannot keep up on its machine type
+func (e *Engine) replicaLagSustained(instance *config.InstanceInfo, metrics *config.MetricsSummary) bool {
+	maxLag := e.config.MaxReplicaLag.Seconds()
+	return maxLag > 0 && cloudsql.IsReadReplica(instance) && metrics.ReplicaLagSustainedSeconds >= maxLag
+}
 
-	return cpuExceeds || memoryExceeds
+// replicaLagging reports whether instance is a read replica whose P95
+// replication lag is above the maximum, so shrinking it would leave it
+// further behind
+func (e *Engine) replicaLagging(instance *config.InstanceInfo, metrics *config.MetricsSummary) bool {
+	maxLag := e.config.MaxReplicaLag.Seconds()
+	return maxLag > 0 && cloudsql.IsReadReplica(instance) && metrics.ReplicaLagP95Seconds >= maxLag
 }
 
-// shouldScaleDown determines if instance should be scaled down
-func (e *Engine) shouldScaleDown(metrics *config.MetricsSummary) bool {
+// risingTrend reports whether CPU or memory has climbed faster than its trend
+// threshold throughout the trend window and, continuing at that rate, would
+// cross the scale-up threshold within another window. It returns a
+// description of the climb and its reason code.
+func (e *Engine) risingTrend(instance *config.InstanceInfo, metrics *config.MetricsSummary) (string, cloudsql.ReasonCode, bool) {
+	window := metrics.TrendWindow.Hours()
+	if window <= 0 {
+		return "", "", false
+	}
+	threshold := e.ScaleUpThreshold(instance) * 100
+
+	rising := func(name string, current, rate, limit float64) (string, bool) {
+		if limit <= 0 || rate <= limit || current+rate*window <= threshold {
+			return "", false
+		}
+		return fmt.Sprintf("%s climbing %.1f%%/hour over the last %.4g hours, projected to pass %.0f%% within %.4g hours",
+			name, rate, window, threshold, window), true
+	}
+
+	memory, memoryRate := MemoryPressure(instance, metrics, e.config)
+	if desc, ok := rising("memory", memory, memoryRate, e.config.MemoryTrendThreshold); ok &&
+		!DataCacheAbsorbsMemoryPressure(instance, metrics, e.config) && MemoryPressureCorroborated(instance, metrics, e.config) {
+		return desc, cloudsql.ReasonMemoryTrendRising, true
+	}
+	if desc, ok := rising("CPU", metrics.CPUP95, metrics.CPUTrendPerHour, e.config.CPUTrendThreshold); ok {
+		return desc, cloudsql.ReasonCPUTrendRising, true
+	}
+	return "", "", false
+}
+
+// ScaleUpThreshold returns the scale-up threshold for an instance. SQL Server
+// instances pay per-core licensing on every added vCPU, so they use a
+// stricter threshold when one is configured.
+func (e *Engine) ScaleUpThreshold(instance *config.InstanceInfo) float64 {
+	if config.ParseEngine(instance.DatabaseVersion) == config.EngineSQLServer &&
+		e.config.SQLServerScaleUpThreshold > e.config.ScaleUpThreshold {
+		return e.config.SQLServerScaleUpThreshold
+	}
+	return e.config.ScaleUpThreshold
+}
+
+// DataCacheAbsorbsMemoryPressure reports whether an Enterprise Plus data cache
+// is serving reads well enough that high memory utilization is not a concern
+func DataCacheAbsorbsMemoryPressure(instance *config.InstanceInfo, metrics *config.MetricsSummary, cfg *config.Config) bool {
+	if !instance.DataCacheEnabled || metrics.DataCacheHitRatio == 0 {
+		return false
+	}
+	return metrics.DataCacheHitRatio >= cfg.DataCacheHitRatioThreshold*100
+}
+
+// connectionSaturation is the share of max_connections in use at which new
+// connections start failing, corroborating memory pressure
+const connectionSaturation = 0.95
+
+// MemoryPressure returns the P95 memory percentage and its sustained hourly
+// rise that count toward scale-up under the engine's memory pressure mode.
+// Usage outside the page cache falls back to total utilization when the
+// instance does not report it.
+func MemoryPressure(instance *config.InstanceInfo, metrics *config.MetricsSummary, cfg *config.Config) (p95, trendPerHour float64) {
+	if cfg.MemoryPressureFor(instance.DatabaseVersion) == config.MemoryPressureNonCache && metrics.MemoryNonCacheP95Pct > 0 {
+		return metrics.MemoryNonCacheP95Pct, metrics.MemoryNonCacheTrendPerHour
+	}
+	return metrics.MemoryP95Pct, metrics.MemoryTrendPerHour
+}
+
+// MemoryPressureCorroborated reports whether high memory utilization is
+// backed by pages being swapped in, SQL Server queries waiting for memory
+// grants or connections nearing max_connections. It is always true unless
+// the engine uses the corroborated mode.
+func MemoryPressureCorroborated(instance *config.InstanceInfo, metrics *config.MetricsSummary, cfg *config.Config) bool {
+	if cfg.MemoryPressureFor(instance.DatabaseVersion) != config.MemoryPressureCorroborated {
+		return true
+	}
+	if metrics.SwapInP95 > 0 || metrics.MemoryGrantsPendingP95 > 0 {
+		return true
+	}
+	return instance.MaxConnections > 0 &&
+		float64(metrics.ConnectionsMax) >= connectionSaturation*float64(instance.MaxConnections)
+}
+
+// shouldScaleDown determines if instance should be scaled down: by its
+// scale-down rule when one is set, otherwise the scale-down threshold
+func (e *Engine) shouldScaleDown(instance *config.InstanceInfo, metrics *config.MetricsSummary) (bool, error) {
+	metrics = scaleDownMetrics(metrics)
+	if e.scaleDownRule != nil {
+		return e.scaleDownRule.Matches(instance, metrics, e.config)
+	}
+
 	// Scale down if P95 utilization is below threshold
 	// Both CPU and memory should be low to scale down
 	cpuLow := metrics.CPUP95 < (e.config.ScaleDownThreshold * 100)
 	memoryLow := metrics.MemoryP95Pct < (e.config.ScaleDownThreshold * 100)
 
-	return cpuLow && memoryLow
+	return cpuLow && memoryLow, nil
+}
+
+// scaleDownMetrics returns the summary scale-down is judged on: business hours
+// when configured and there is enough data from them, otherwise the whole period
+func scaleDownMetrics(metrics *config.MetricsSummary) *config.MetricsSummary {
+	if metrics.BusinessHours != nil && metrics.BusinessHours.DataPoints >= minDataPoints {
+		return metrics.BusinessHours
+	}
+	return metrics
 }
 
-// checkDowntimeForEnterprisePlus checks if Enterprise Plus scaling would cause downtime
-func (e *Engine) checkDowntimeForEnterprisePlus(instance *config.InstanceInfo, isUpscale bool) (bool, string) {
+// checkDowntimeForEnterprisePlus checks if Enterprise Plus scaling would cause
+// downtime, and returns when the minimum interval since the last operation ends
+func (e *Engine) checkDowntimeForEnterprisePlus(instance *config.InstanceInfo, isUpscale bool) (bool, string, time.Time) {
 	if instance.LastScaledTime.IsZero() {
 		// No previous scaling information
-		return false, ""
+		return false, "", time.Time{}
 	}
 
 	timeSinceLastScale := time.Since(instance.LastScaledTime)
@@ -124,18 +484,18 @@ func (e *Engine) checkDowntimeForEnterprisePlus(instance *config.InstanceInfo, i
 		if timeSinceLastScale < minInterval {
 			timeToWait := minInterval - timeSinceLastScale
 			return true, fmt.Sprintf("Scaling within %s of last operation would cause downtime. Wait %v more",
-				constraints.MinUpscaleInterval, timeToWait.Round(time.Minute))
+				constraints.MinUpscaleInterval, timeToWait.Round(time.Minute)), instance.LastScaledTime.Add(minInterval)
 		}
 	} else {
 		minInterval, _ := time.ParseDuration(constraints.MinDownscaleInterval)
 		if timeSinceLastScale < minInterval {
 			timeToWait := minInterval - timeSinceLastScale
 			return true, fmt.Sprintf("Downscaling within %s of last operation would cause downtime. Wait %v more",
-				constraints.MinDownscaleInterval, timeToWait.Round(time.Minute))
+				constraints.MinDownscaleInterval, timeToWait.Round(time.Minute)), instance.LastScaledTime.Add(minInterval)
 		}
 	}
 
-	return false, ""
+	return false, "", time.Time{}
 }
 
 // ValidateScalingDecision performs final validation of a scaling decision
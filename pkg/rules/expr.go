@@ -0,0 +1,113 @@
+package rules
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// Rule is a compiled custom scaling condition: a CEL expression over an
+// instance and its metrics summary that is true when the instance should be
+// scaled, e.g. cpu.p95 > 85 && connections.max > 400. Its variables are maps
+// of numbers, percentages as 0-100:
+//   - cpu: avg, p95, p99, max, trend_per_hour
+//   - memory: avg_pct, p95_pct, p99_pct, pressure_pct (as the engine's memory
+//     pressure mode judges it), noncache_p95_pct, avg_gb, p95_gb, max_gb,
+//     trend_per_hour
+//   - connections: avg, max, limit (max_connections, 0 if the default)
+//   - disk: used_gb, iops_p95, throughput_p95_mbps
+//   - replica: lag_p95_seconds, lag_max_seconds
+//   - instance: cpus, memory_gb, plus the strings name, machine_type,
+//     edition and database_version
+type Rule struct {
+	source  string
+	program cel.Program
+}
+
+// ruleEnv declares the variables of a Rule. Numbers compare across types, so
+// cpu.p95 > 85 needs no 85.0.
+var ruleEnv = func() *cel.Env {
+	numbers := cel.MapType(cel.StringType, cel.DoubleType)
+	env, err := cel.NewEnv(
+		cel.Variable("cpu", numbers),
+		cel.Variable("memory", numbers),
+		cel.Variable("connections", numbers),
+		cel.Variable("disk", numbers),
+		cel.Variable("replica", numbers),
+		cel.Variable("instance", cel.MapType(cel.StringType, cel.DynType)),
+		cel.CrossTypeNumericComparisons(true),
+	)
+	if err != nil {
+		panic(fmt.Sprintf("rules: declaring rule variables: %v", err))
+	}
+	return env
+}()
+
+// CompileRule compiles a custom scaling condition. It is checked to be a
+// boolean and evaluated once over an empty instance, so a misspelled field
+// such as cpu.p59 fails here rather than on the first instance.
+func CompileRule(source string) (*Rule, error) {
+	ast, issues := ruleEnv.Compile(source)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("must be a condition, not a %s", ast.OutputType())
+	}
+	program, err := ruleEnv.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+	rule := &Rule{source: source, program: program}
+	if _, err := rule.Matches(&config.InstanceInfo{}, &config.MetricsSummary{}, &config.Config{}); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// String returns the rule's expression
+func (r *Rule) String() string {
+	return r.source
+}
+
+// Matches evaluates the rule over instance and metrics, with memory pressure
+// judged as cfg's memory pressure mode judges it
+func (r *Rule) Matches(instance *config.InstanceInfo, metrics *config.MetricsSummary, cfg *config.Config) (bool, error) {
+	pressure, _ := MemoryPressure(instance, metrics, cfg)
+	out, _, err := r.program.Eval(map[string]interface{}{
+		"cpu": map[string]float64{
+			"avg": metrics.CPUAvg, "p95": metrics.CPUP95, "p99": metrics.CPUP99, "max": metrics.CPUMax,
+			"trend_per_hour": metrics.CPUTrendPerHour,
+		},
+		"memory": map[string]float64{
+			"avg_pct": metrics.MemoryAvgPct, "p95_pct": metrics.MemoryP95Pct, "p99_pct": metrics.MemoryP99Pct,
+			"pressure_pct": pressure, "noncache_p95_pct": metrics.MemoryNonCacheP95Pct,
+			"avg_gb": metrics.MemoryAvgGB, "p95_gb": metrics.MemoryP95GB, "max_gb": metrics.MemoryMaxGB,
+			"trend_per_hour": metrics.MemoryTrendPerHour,
+		},
+		"connections": map[string]float64{
+			"avg": metrics.ConnectionsAvg, "max": float64(metrics.ConnectionsMax), "limit": float64(instance.MaxConnections),
+		},
+		"disk": map[string]float64{
+			"used_gb": metrics.DiskUsedGB, "iops_p95": metrics.DiskIOPSP95, "throughput_p95_mbps": metrics.DiskThroughputP95MBps,
+		},
+		"replica": map[string]float64{
+			"lag_p95_seconds": metrics.ReplicaLagP95Seconds, "lag_max_seconds": metrics.ReplicaLagMaxSeconds,
+		},
+		"instance": map[string]interface{}{
+			"name": instance.Name, "machine_type": instance.MachineType, "edition": string(instance.Edition),
+			"database_version": instance.DatabaseVersion,
+			"cpus":             float64(instance.CurrentCPU), "memory_gb": instance.CurrentMemoryGB,
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("evaluating rule %q: %w", r.source, err)
+	}
+	matched, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("evaluating rule %q: got %v, not a condition", r.source, out)
+	}
+	return matched, nil
+}
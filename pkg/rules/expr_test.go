@@ -0,0 +1,100 @@
+package rules_test
+
+import (
+	"testing"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/rules"
+)
+
+// TestCompileRule checks that rules compile only when they are conditions
+// over known fields
+func TestCompileRule(t *testing.T) {
+	tests := []struct {
+		source  string
+		wantErr bool
+	}{
+		{"cpu.p95 > 85", false},
+		{"cpu.p95 > 85.0 && connections.max > 400", false},
+		{"memory.pressure_pct > 90 || replica.lag_max_seconds >= 60", false},
+		{`instance.edition == "ENTERPRISE_PLUS" && instance.cpus >= 8`, false},
+		{`instance.name.startsWith("orders-")`, false},
+		{"disk.iops_p95 > 1000 && !(memory.trend_per_hour < 0)", false},
+		{"", true},                        // Not an expression
+		{"cpu.p95 >", true},               // Syntax error
+		{"cpu.p95", true},                 // A number, not a condition
+		{"cpu.p59 > 85", true},            // Misspelled field, caught by the trial run
+		{"network.egress > 0", true},      // Undeclared variable
+		{`instance.name > 3`, true},       // Type error on the trial run
+		{"cpu.p95 > 85 && cpu.p95", true}, // && of a number
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			rule, err := rules.CompileRule(tt.source)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CompileRule(%q) error = %v, want error %v", tt.source, err, tt.wantErr)
+			}
+			if err == nil && rule.String() != tt.source {
+				t.Errorf("String() = %q, want %q", rule.String(), tt.source)
+			}
+		})
+	}
+}
+
+// TestRuleMatches checks rules evaluated over an instance and its metrics
+// summary
+func TestRuleMatches(t *testing.T) {
+	instance := &config.InstanceInfo{
+		Name:            "orders-db",
+		MachineType:     "db-custom-8-30720",
+		Edition:         config.EditionEnterprise,
+		DatabaseVersion: "POSTGRES_15",
+		CurrentCPU:      8,
+		CurrentMemoryGB: 30,
+		MaxConnections:  500,
+	}
+	metrics := &config.MetricsSummary{
+		CPUAvg:               60,
+		CPUP95:               88,
+		CPUMax:               97,
+		CPUTrendPerHour:      1.5,
+		MemoryP95Pct:         70,
+		MemoryP95GB:          21,
+		ConnectionsAvg:       250,
+		ConnectionsMax:       450,
+		DiskUsedGB:           120,
+		ReplicaLagMaxSeconds: 0,
+	}
+	cfg := config.DefaultConfig()
+
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{"cpu.p95 > 85", true},
+		{"cpu.p95 > 90", false},
+		{"cpu.p95 > 85 && connections.max > 400", true},
+		{"cpu.p95 > 85 && connections.max > connections.limit", false},
+		{"connections.max > 0.8 * connections.limit", true},
+		{"memory.p95_pct < 40 || cpu.avg < 30", false},
+		{"memory.p95_gb > 20 && cpu.trend_per_hour > 1", true},
+		{`instance.machine_type == "db-custom-8-30720" && instance.cpus == 8`, true},
+		{`instance.database_version.startsWith("MYSQL")`, false},
+		{"disk.used_gb >= 120 && replica.lag_max_seconds == 0.0", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			rule, err := rules.CompileRule(tt.source)
+			if err != nil {
+				t.Fatalf("CompileRule(%q): %v", tt.source, err)
+			}
+			got, err := rule.Matches(instance, metrics, cfg)
+			if err != nil {
+				t.Fatalf("Matches: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("%q matched = %v, want %v", tt.source, got, tt.want)
+			}
+		})
+	}
+}
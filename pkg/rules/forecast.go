@@ -0,0 +1,33 @@
+package rules
+
+import (
+	"fmt"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// ForecastScaleUp returns the decision growing instance to the next larger
+// machine type ahead of a forecast threshold crossing described by forecast,
+// or nil when no larger machine type is allowed
+func (e *Engine) ForecastScaleUp(instance *config.InstanceInfo, metrics *config.MetricsSummary, forecast string) *cloudsql.ScalingDecision {
+	target, denied, err := e.nextMachineType(instance, true)
+	if err != nil {
+		return nil
+	}
+	decision := &cloudsql.ScalingDecision{
+		ShouldScale:     true,
+		CurrentType:     instance.MachineType,
+		RecommendedType: target,
+		Reason:          fmt.Sprintf("Predictive scale-up: %s", forecast),
+		ReasonCodes:     []cloudsql.ReasonCode{cloudsql.ReasonForecastBreach},
+		Metrics:         metrics,
+		ID:              cloudsql.NewDecisionID(),
+	}
+	if denied.why != "" {
+		decision.Reason += fmt.Sprintf("; %s, so %s is recommended instead", denied.why, target)
+		decision.ReasonCodes = append(decision.ReasonCodes, denied.code)
+	}
+	e.estimateImpact(decision, instance, true)
+	return decision
+}
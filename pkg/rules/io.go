@@ -0,0 +1,93 @@
+package rules
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// CheckIOBound compares the P95 disk IOPS and throughput behind a resize with
+// the limits of the instance's data disk. An instance at IOBoundThreshold of
+// either limit is IO-bound, and CPU-based resizes misjudge it:
+//   - a scale-down is held with IO_BOUND_HOLD, since a smaller machine type
+//     caches less and can only lower the disk's vCPU cap
+//   - a scale-up for CPU alone is held with IO_BOUND when the larger machine
+//     type does not raise the limit the instance is at, which the disk's size
+//     or the vCPU cap sets
+//
+// Scale-ups for memory, which caches more and reads less, and those that
+// raise the limit go ahead with an io_bound warning. Otherwise decision and a
+// nil warning are returned.
+func (e *Engine) CheckIOBound(instance *config.InstanceInfo, metrics *config.MetricsSummary, decision *cloudsql.ScalingDecision) (*cloudsql.ScalingDecision, *Warning) {
+	threshold := e.config.IOBoundThreshold
+	if threshold <= 0 || !decision.ShouldScale || instance.DiskSizeGB <= 0 {
+		return decision, nil
+	}
+	limits := cloudsql.DiskLimitsFor(instance.DiskType, instance.DiskSizeGB, instance.CurrentCPU)
+	iopsUsed, throughputUsed := metrics.DiskIOPSP95/limits.IOPS, metrics.DiskThroughputP95MBps/limits.ThroughputMBps
+	if iopsUsed < threshold && throughputUsed < threshold {
+		return decision, nil
+	}
+
+	why := fmt.Sprintf("disk IOPS P95 is %.0f of %.0f", metrics.DiskIOPSP95, limits.IOPS)
+	if throughputUsed > iopsUsed {
+		why = fmt.Sprintf("disk throughput P95 is %.0f of %.0f MB/s", metrics.DiskThroughputP95MBps, limits.ThroughputMBps)
+	}
+	warning := &Warning{
+		Code:     WarningIOBound,
+		Severity: SeverityWarning,
+		Data: map[string]interface{}{
+			"disk_iops_p95": metrics.DiskIOPSP95, "disk_iops_limit": limits.IOPS,
+			"disk_throughput_p95_mbps": metrics.DiskThroughputP95MBps, "disk_throughput_limit_mbps": limits.ThroughputMBps,
+			"limited_by_size": limits.BySize, "target_type": decision.RecommendedType,
+		},
+	}
+
+	up, err := config.IsUpscale(decision.CurrentType, decision.RecommendedType)
+	if err != nil {
+		return decision, nil
+	}
+	if !up {
+		warning.Message = fmt.Sprintf("Instance is IO-bound: %s. The scale-down to %s is held.", why, decision.RecommendedType)
+		return heldForIO(instance, decision, cloudsql.ReasonIOBoundHold,
+			fmt.Sprintf("Scale-down to %s held: instance is IO-bound (%s)", decision.RecommendedType, why)), warning
+	}
+
+	target, _ := config.GetMachineType(decision.RecommendedType)
+	targetLimits := cloudsql.DiskLimitsFor(instance.DiskType, instance.DiskSizeGB, target.CPU)
+	forMemory := slices.Contains(decision.ReasonCodes, cloudsql.ReasonMemoryP95High) ||
+		slices.Contains(decision.ReasonCodes, cloudsql.ReasonMemoryTrendRising)
+	forCPU := slices.Contains(decision.ReasonCodes, cloudsql.ReasonCPUP95High) ||
+		slices.Contains(decision.ReasonCodes, cloudsql.ReasonCPUTrendRising)
+	raised := (iopsUsed >= threshold && targetLimits.IOPS > limits.IOPS) ||
+		(throughputUsed >= threshold && targetLimits.ThroughputMBps > limits.ThroughputMBps)
+	if forCPU && !forMemory && !raised {
+		setBy, advice := "the vCPU cap", "A machine type with more vCPUs or a faster disk is needed."
+		if limits.BySize {
+			setBy, advice = fmt.Sprintf("the %d GB disk's size", instance.DiskSizeGB), "Grow the disk instead of the machine type."
+		}
+		warning.Message = fmt.Sprintf("Instance is IO-bound: %s, set by %s. %s", why, setBy, advice)
+		return heldForIO(instance, decision, cloudsql.ReasonIOBound,
+			fmt.Sprintf("Scale-up to %s held: instance is IO-bound (%s), and the limit is set by %s, which %s does not raise",
+				decision.RecommendedType, why, setBy, decision.RecommendedType)), warning
+	}
+
+	warning.Severity = SeverityInfo
+	warning.Message = fmt.Sprintf("Instance is IO-bound: %s. On %s the limits are %.0f IOPS and %.0f MB/s.",
+		why, decision.RecommendedType, targetLimits.IOPS, targetLimits.ThroughputMBps)
+	return decision, warning
+}
+
+// heldForIO returns decision held at instance's machine type, leading with
+// code and followed by the codes of the held resize
+func heldForIO(instance *config.InstanceInfo, decision *cloudsql.ScalingDecision, code cloudsql.ReasonCode, reason string) *cloudsql.ScalingDecision {
+	return &cloudsql.ScalingDecision{
+		CurrentType:     instance.MachineType,
+		RecommendedType: instance.MachineType,
+		Reason:          reason,
+		ReasonCodes:     append([]cloudsql.ReasonCode{code}, decision.ReasonCodes...),
+		Metrics:         decision.Metrics,
+	}
+}
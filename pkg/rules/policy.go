@@ -0,0 +1,95 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// maxTierError reports that the next larger machine type is above the
+// largest the instance's max-tier label allows
+type maxTierError struct {
+	target, limit string
+}
+
+func (e *maxTierError) Error() string {
+	return fmt.Sprintf("%s is larger than %s, the most the %s label allows", e.target, e.limit, cloudsql.LabelMaxTier)
+}
+
+// AboveMaxTier reports whether machineType has more CPUs or memory than the
+// largest machine type instance's labels allow. A max tier that is not in
+// the catalog caps nothing; PolicyLabelWarnings reports it.
+func AboveMaxTier(instance *config.InstanceInfo, machineType string) bool {
+	if instance.MaxTier == "" {
+		return false
+	}
+	limit, err := config.GetMachineType(instance.MaxTier)
+	if err != nil {
+		return false
+	}
+	mt, err := config.GetMachineType(machineType)
+	if err != nil {
+		return false
+	}
+	return mt.CPU > limit.CPU || mt.MemoryGB > limit.MemoryGB
+}
+
+// PolicyLabelWarnings reports the autoscaler policy labels of instance whose
+// values are ignored: a profile cfg does not know, a max tier that is not a
+// machine type, or a downtime budget that is not a duration
+func PolicyLabelWarnings(instance *config.InstanceInfo, cfg *config.Config) []Warning {
+	var warnings []Warning
+	if p := instance.ProfileLabel; p != "" && cfg.ProfileConfigs != nil && cfg.ProfileConfigs[p] == nil {
+		known := make([]string, 0, len(cfg.ProfileConfigs))
+		for name := range cfg.ProfileConfigs {
+			known = append(known, name)
+		}
+		sort.Strings(known)
+		warnings = append(warnings, Warning{
+			Code:     WarningInvalidLabel,
+			Severity: SeverityWarning,
+			Message: fmt.Sprintf("Label %s=%s names no profile (must be %s); the active thresholds apply",
+				cloudsql.LabelProfile, p, strings.Join(known, ", ")),
+			Data: map[string]interface{}{"label": cloudsql.LabelProfile, "value": p},
+		})
+	}
+	if t := instance.MaxTier; t != "" {
+		if _, err := config.GetMachineType(t); err != nil {
+			warnings = append(warnings, Warning{
+				Code:     WarningInvalidLabel,
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("Label %s=%s is not a known machine type; scale-ups are not capped", cloudsql.LabelMaxTier, t),
+				Data:     map[string]interface{}{"label": cloudsql.LabelMaxTier, "value": t},
+			})
+		}
+	}
+	if d := instance.DowntimeBudget; d != "" {
+		if _, err := ParseDowntimeBudget(d); err != nil {
+			warnings = append(warnings, Warning{
+				Code:     WarningInvalidLabel,
+				Severity: SeverityWarning,
+				Message: fmt.Sprintf("Label %s=%s is not a duration such as 10m; the default downtime budget applies",
+					cloudsql.LabelDowntimeBudget, d),
+				Data: map[string]interface{}{"label": cloudsql.LabelDowntimeBudget, "value": d},
+			})
+		}
+	}
+	return warnings
+}
+
+// ParseDowntimeBudget parses the value of a downtime budget label, a
+// non-negative duration such as 10m or 1h30m
+func ParseDowntimeBudget(value string) (time.Duration, error) {
+	budget, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if budget < 0 {
+		return 0, fmt.Errorf("downtime budget %s is negative", value)
+	}
+	return budget, nil
+}
@@ -0,0 +1,125 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// ReadReplica is one of a primary's read replicas with its metrics summary
+type ReadReplica struct {
+	Instance *config.InstanceInfo
+	Summary  *config.MetricsSummary
+}
+
+// AnalyzeReadReplicas recommends adding a read replica to primary when its
+// replicas' mean P95 CPU is above the replica threshold or one of them falls
+// further behind than the maximum replication lag, and removing one when
+// they are idle enough that the rest stay below the threshold without it.
+// Only replicas the autoscaler created are removed, newest first. It returns
+// nil when replica count autoscaling is off or primary has no read replicas.
+func (e *Engine) AnalyzeReadReplicas(primary *config.InstanceInfo, replicas []ReadReplica) *cloudsql.ReplicaDecision {
+	threshold := e.config.ReplicaScaleUpThreshold
+	if threshold <= 0 || len(replicas) == 0 {
+		return nil
+	}
+
+	n := len(replicas)
+	decision := &cloudsql.ReplicaDecision{
+		Primary:          primary.Name,
+		CurrentCount:     n,
+		RecommendedCount: n,
+	}
+
+	for _, r := range replicas {
+		if r.Summary == nil || r.Summary.DataPoints < minDataPoints {
+			decision.Reason = fmt.Sprintf("Insufficient metrics data for read replica %s", r.Instance.Name)
+			decision.ReasonCodes = []cloudsql.ReasonCode{cloudsql.ReasonInsufficientData}
+			return decision
+		}
+		decision.CPUP95 += r.Summary.CPUP95 / float64(n)
+		if r.Summary.ReplicaLagP95Seconds > decision.LagP95Seconds {
+			decision.LagP95Seconds = r.Summary.ReplicaLagP95Seconds
+		}
+	}
+
+	maxLag := e.config.MaxReplicaLag.Seconds()
+	lagging := maxLag > 0 && decision.LagP95Seconds >= maxLag
+	switch {
+	case decision.CPUP95 >= threshold*100 || lagging:
+		code := cloudsql.ReasonReplicaCPUHigh
+		decision.Reason = fmt.Sprintf("Read replicas are busy (mean CPU P95: %.1f%%)", decision.CPUP95)
+		if decision.CPUP95 < threshold*100 {
+			code = cloudsql.ReasonReplicaLagHigh
+			decision.Reason = fmt.Sprintf("Read replicas are falling behind (replication lag P95: %.0fs)", decision.LagP95Seconds)
+		}
+		if limit := e.config.MaxReadReplicas; limit > 0 && n >= limit {
+			decision.Reason += fmt.Sprintf(", but %s already has the maximum of %d", primary.Name, limit)
+			decision.ReasonCodes = []cloudsql.ReasonCode{cloudsql.ReasonReplicaAtMaxCount, code}
+			return decision
+		}
+		decision.Change = 1
+		decision.Tier = replicas[0].Instance.MachineType
+		decision.Reason += fmt.Sprintf("; add a %s read replica", decision.Tier)
+		decision.ReasonCodes = []cloudsql.ReasonCode{code}
+		decision.EstimatedCost = replicaCost(replicas[0].Instance)
+
+	case n > 1 && decision.CPUP95 < e.config.ReplicaScaleDownThreshold*100 &&
+		decision.CPUP95*float64(n)/float64(n-1) < threshold*100:
+		decision.Reason = fmt.Sprintf("Read replicas are idle (mean CPU P95: %.1f%%)", decision.CPUP95)
+		if n <= e.config.MinReadReplicas {
+			decision.Reason += fmt.Sprintf(", but %s already has the minimum of %d", primary.Name, e.config.MinReadReplicas)
+			decision.ReasonCodes = []cloudsql.ReasonCode{cloudsql.ReasonReplicaAtMinCount, cloudsql.ReasonReplicaCPULow}
+			return decision
+		}
+		removable := removableReplica(replicas)
+		if removable == nil {
+			decision.Reason += ", but none was created by the autoscaler"
+			decision.ReasonCodes = []cloudsql.ReasonCode{cloudsql.ReasonReplicaNotRemovable, cloudsql.ReasonReplicaCPULow}
+			return decision
+		}
+		decision.Change = -1
+		decision.RemoveReplica = removable.Name
+		decision.Reason += fmt.Sprintf("; remove read replica %s", removable.Name)
+		decision.ReasonCodes = []cloudsql.ReasonCode{cloudsql.ReasonReplicaCPULow}
+		decision.EstimatedCost = -replicaCost(removable) +
+			cloudsql.EstimateCommittedCost(removable.MachineType, removable.Region, removable.Edition, e.config.Commitment)
+
+	default:
+		decision.Reason = fmt.Sprintf("Read replica load is within target range (mean CPU P95: %.1f%%, replication lag P95: %.0fs)",
+			decision.CPUP95, decision.LagP95Seconds)
+		decision.ReasonCodes = []cloudsql.ReasonCode{cloudsql.ReasonReplicaWithinTarget}
+		return decision
+	}
+
+	decision.RecommendedCount = n + decision.Change
+	decision.ID = cloudsql.NewDecisionID()
+	return decision
+}
+
+// removableReplica returns the newest read replica the autoscaler created,
+// or nil if it created none of them
+func removableReplica(replicas []ReadReplica) *config.InstanceInfo {
+	var created []*config.InstanceInfo
+	for _, r := range replicas {
+		if cloudsql.IsAutoscalerReplica(r.Instance.Labels) {
+			created = append(created, r.Instance)
+		}
+	}
+	if len(created) == 0 {
+		return nil
+	}
+	sort.Slice(created, func(i, j int) bool {
+		return created[i].Labels[cloudsql.LabelReplicaCreatedAt] > created[j].Labels[cloudsql.LabelReplicaCreatedAt]
+	})
+	return created[0]
+}
+
+// replicaCost estimates the monthly on-demand cost of a read replica like
+// instance. Removing one saves this less its committed spend.
+func replicaCost(instance *config.InstanceInfo) float64 {
+	return cloudsql.EstimateMonthlyCost(instance.MachineType, instance.Region, instance.Edition, instance.DatabaseVersion) +
+		cloudsql.EstimateStorageCost(instance.DiskType, instance.DiskSizeGB)
+}
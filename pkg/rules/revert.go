@@ -0,0 +1,62 @@
+package rules
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// RevertScaleUp decides whether a reactive scale-up described by tag can be
+// undone. quiet summarizes the metrics since the start of the quiet period;
+// the revert is recommended when utilization projected onto the original
+// machine type stays below the target utilization throughout. It returns
+// nil when the scale-up should stand.
+func (e *Engine) RevertScaleUp(instance *config.InstanceInfo, tag cloudsql.RevertTag, quiet *config.MetricsSummary, now time.Time) *cloudsql.ScalingDecision {
+	if now.After(tag.Deadline) || now.Sub(tag.ScaledAt) < e.config.RevertQuietPeriod {
+		return nil
+	}
+	if quiet == nil || quiet.DataPoints < minDataPoints {
+		return nil
+	}
+
+	from, err := config.GetMachineType(tag.FromTier)
+	if err != nil {
+		return nil
+	}
+	if up, err := config.IsUpscale(tag.FromTier, instance.MachineType); err != nil || !up {
+		return nil
+	}
+	// The original machine type may have been denylisted or left out of the allowlist since
+	if _, restricted := e.config.RestrictsTarget(tag.FromTier); restricted {
+		return nil
+	}
+	current, err := config.GetMachineType(instance.MachineType)
+	if err != nil || from.CPU == 0 || from.MemoryGB == 0 {
+		return nil
+	}
+
+	// What utilization would have been on the original machine type
+	cpu := quiet.CPUP95 * float64(current.CPU) / float64(from.CPU)
+	memory := quiet.MemoryP95Pct * current.MemoryGB / from.MemoryGB
+	if quiet.MemoryP95GB > 0 {
+		memory = quiet.MemoryP95GB / from.MemoryGB * 100
+	}
+	if cpu >= e.config.CPUTargetUtilization*100 || memory >= e.config.MemoryTargetUtilization*100 {
+		return nil
+	}
+
+	decision := &cloudsql.ScalingDecision{
+		ShouldScale:     true,
+		CurrentType:     instance.MachineType,
+		RecommendedType: tag.FromTier,
+		Reason: fmt.Sprintf("Emergency scale-up from %s no longer needed: utilization on %s would have been "+
+			"CPU P95 %.1f%%, Memory P95 %.1f%% over the last %s", tag.FromTier, tag.FromTier, cpu, memory, e.config.RevertQuietPeriod),
+		ReasonCodes: []cloudsql.ReasonCode{cloudsql.ReasonScaleUpRevert},
+		Metrics:     quiet,
+		ID:          cloudsql.NewDecisionID(),
+	}
+	e.estimateImpact(decision, instance, false)
+	return decision
+}
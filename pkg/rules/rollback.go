@@ -0,0 +1,79 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// RollbackScaleDown decides whether the scale-down described by last
+// regressed. after summarizes the metrics since it settled; the instance is
+// rolled back to its previous machine type when P95 CPU or memory reached the
+// rollback threshold, or peak connections saturate the default
+// max_connections of its new machine type. It returns nil when the
+// scale-down should stand.
+func (e *Engine) RollbackScaleDown(instance *config.InstanceInfo, last cloudsql.LastScaling, after *config.MetricsSummary) *cloudsql.ScalingDecision {
+	if up, err := config.IsUpscale(instance.MachineType, last.FromTier); err != nil || !up {
+		return nil
+	}
+	if after == nil || after.DataPoints < minDataPoints {
+		return nil
+	}
+
+	threshold := e.config.RollbackThreshold * 100
+	var signals []string
+	if after.CPUP95 >= threshold {
+		signals = append(signals, fmt.Sprintf("CPU P95 %.1f%%", after.CPUP95))
+	}
+	if after.MemoryP95Pct >= threshold {
+		signals = append(signals, fmt.Sprintf("Memory P95 %.1f%%", after.MemoryP95Pct))
+	}
+	if _, set := instance.DatabaseFlags["max_connections"]; !set {
+		limit := config.DefaultMaxConnections(instance.DatabaseVersion, instance.MachineType)
+		if limit > 0 && float64(after.ConnectionsMax) >= connectionSaturation*float64(limit) {
+			signals = append(signals, fmt.Sprintf("peak connections %d of max_connections %d", after.ConnectionsMax, limit))
+		}
+	}
+	if len(signals) == 0 {
+		return nil
+	}
+
+	decision := &cloudsql.ScalingDecision{
+		ShouldScale:     true,
+		CurrentType:     instance.MachineType,
+		RecommendedType: last.FromTier,
+		Reason: fmt.Sprintf("Scale-down from %s regressed: %s since %s; rolling back",
+			last.FromTier, strings.Join(signals, ", "), config.FormatTime(last.ScaledAt)),
+		ReasonCodes: []cloudsql.ReasonCode{cloudsql.ReasonRollback},
+		Metrics:     after,
+		ID:          cloudsql.NewDecisionID(),
+	}
+	e.estimateImpact(decision, instance, true)
+	return decision
+}
+
+// HoldAboveRollback holds back a scale-down of decision to floor, the machine
+// type a rollback left, or smaller. Scale-downs to a machine type larger than
+// floor, and every other decision, are returned unchanged.
+func (e *Engine) HoldAboveRollback(instance *config.InstanceInfo, floor string, decision *cloudsql.ScalingDecision) *cloudsql.ScalingDecision {
+	if !decision.ShouldScale {
+		return decision
+	}
+	if up, err := config.IsUpscale(decision.CurrentType, decision.RecommendedType); err != nil || up {
+		return decision
+	}
+	// A floor whose size is unknown holds every scale-down
+	if above, err := config.IsUpscale(floor, decision.RecommendedType); err == nil && above {
+		return decision
+	}
+	return &cloudsql.ScalingDecision{
+		CurrentType:     instance.MachineType,
+		RecommendedType: instance.MachineType,
+		Reason: fmt.Sprintf("Scale-down to %s held: a scale-down to %s was rolled back (remove the %s label to allow it)",
+			decision.RecommendedType, floor, cloudsql.LabelRollbackFloor),
+		ReasonCodes: append([]cloudsql.ReasonCode{cloudsql.ReasonRollbackHold}, decision.ReasonCodes...),
+		Metrics:     decision.Metrics,
+	}
+}
@@ -0,0 +1,22 @@
+package rules
+
+import (
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// ScheduledScaleUp returns the decision growing instance to the machine type
+// a schedule window needs, with its downtime and cost impact
+func (e *Engine) ScheduledScaleUp(instance *config.InstanceInfo, metrics *config.MetricsSummary, machineType, reason string) *cloudsql.ScalingDecision {
+	decision := &cloudsql.ScalingDecision{
+		ShouldScale:     true,
+		CurrentType:     instance.MachineType,
+		RecommendedType: machineType,
+		Reason:          reason,
+		ReasonCodes:     []cloudsql.ReasonCode{cloudsql.ReasonScheduledScaleUp},
+		Metrics:         metrics,
+		ID:              cloudsql.NewDecisionID(),
+	}
+	e.estimateImpact(decision, instance, true)
+	return decision
+}
@@ -0,0 +1,71 @@
+package rules
+
+import (
+	"fmt"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// memoryReserve is the share of instance memory left to the operating system
+// and the database's own processes, which neither the buffer pool nor
+// connections can use
+const memoryReserve = 0.1
+
+// replicaApplyHeadroom is the share of the maximum replication lag a read
+// replica's peak lag may reach and still give up vCPUs: above it, replay has
+// too little headroom to run on fewer
+const replicaApplyHeadroom = 0.5
+
+// CheckTargetSizing verifies that the smaller machine type of a scale-down
+// still meets the minimums the workload sets, which utilization alone does
+// not show:
+//   - its memory holds the buffer pool, of the size its flag sets or else
+//     Cloud SQL's share of the smaller memory, next to peak connections at
+//     the engine's per-connection estimate
+//   - a read replica whose peak replication lag came near the maximum keeps
+//     its vCPUs, since the replay that lag measures runs on them
+//
+// A scale-down that falls short is held with TARGET_UNDERSIZED, followed by
+// its codes; otherwise decision is returned.
+func (e *Engine) CheckTargetSizing(instance *config.InstanceInfo, metrics *config.MetricsSummary, decision *cloudsql.ScalingDecision) *cloudsql.ScalingDecision {
+	if !decision.ShouldScale {
+		return decision
+	}
+	if up, err := config.IsUpscale(decision.CurrentType, decision.RecommendedType); err != nil || up {
+		return decision
+	}
+	target, err := config.GetMachineType(decision.RecommendedType)
+	if err != nil {
+		return decision
+	}
+
+	var why string
+	bufferPool, fromFlag := config.BufferPoolGB(instance.DatabaseVersion, instance.DatabaseFlags, target.MemoryGB)
+	connections := float64(metrics.ConnectionsMax) * e.config.ConnectionMemoryMB(instance.DatabaseVersion) / 1024
+	usable := target.MemoryGB * (1 - memoryReserve)
+	maxLag := e.config.MaxReplicaLag.Seconds()
+	switch {
+	case bufferPool+connections > usable:
+		pool := fmt.Sprintf("a %.1f GB buffer pool", bufferPool)
+		if fromFlag {
+			pool = fmt.Sprintf("the %.1f GB buffer pool its flag sets", bufferPool)
+		}
+		why = fmt.Sprintf("%s and %d peak connections at %.0f MB each need %.1f GB, more than the %.1f GB of its %.1f GB not reserved for the system",
+			pool, metrics.ConnectionsMax, e.config.ConnectionMemoryMB(instance.DatabaseVersion), bufferPool+connections, usable, target.MemoryGB)
+	case maxLag > 0 && cloudsql.IsReadReplica(instance) && target.CPU < instance.CurrentCPU &&
+		metrics.ReplicaLagMaxSeconds >= replicaApplyHeadroom*maxLag:
+		why = fmt.Sprintf("peak replication lag of %.0fs is within %.0f%% of the maximum of %v, and replay would run on %d instead of %d vCPUs",
+			metrics.ReplicaLagMaxSeconds, (1-replicaApplyHeadroom)*100, e.config.MaxReplicaLag, target.CPU, instance.CurrentCPU)
+	default:
+		return decision
+	}
+
+	return &cloudsql.ScalingDecision{
+		CurrentType:     instance.MachineType,
+		RecommendedType: instance.MachineType,
+		Reason:          fmt.Sprintf("Scale-down to %s held: %s", decision.RecommendedType, why),
+		ReasonCodes:     append([]cloudsql.ReasonCode{cloudsql.ReasonTargetUndersized}, decision.ReasonCodes...),
+		Metrics:         decision.Metrics,
+	}
+}
@@ -0,0 +1,135 @@
+package rules
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// AnalyzeStorage recommends a data disk size increase when the disk is
+// fuller than the storage threshold. The new size brings usage back to the
+// storage target utilization, grows the disk by at least the minimum
+// increase and never exceeds the maximum disk size. It returns nil when
+// storage autoscaling is off or the disk size is unknown.
+func (e *Engine) AnalyzeStorage(instance *config.InstanceInfo, metrics *config.MetricsSummary) *cloudsql.StorageDecision {
+	threshold := e.config.StorageScaleUpThreshold
+	if threshold <= 0 || instance.DiskSizeGB <= 0 {
+		return nil
+	}
+
+	decision := &cloudsql.StorageDecision{
+		CurrentSizeGB: instance.DiskSizeGB,
+		UsedGB:        metrics.DiskUsedGB,
+		UsedPct:       cloudsql.StorageUsedPct(instance, metrics),
+	}
+
+	switch {
+	case instance.StorageAutoResize:
+		decision.Reason = "Storage auto-resize is enabled; Cloud SQL grows the disk as it fills"
+		decision.ReasonCodes = []cloudsql.ReasonCode{cloudsql.ReasonStorageAutoResize}
+		return decision
+	case metrics.DiskUsedGB <= 0:
+		decision.Reason = "No disk usage data for storage analysis"
+		decision.ReasonCodes = []cloudsql.ReasonCode{cloudsql.ReasonInsufficientData}
+		return decision
+	case decision.UsedPct < threshold*100:
+		decision.Reason = fmt.Sprintf("Disk is %.1f%% full, below the %.0f%% storage threshold", decision.UsedPct, threshold*100)
+		decision.ReasonCodes = []cloudsql.ReasonCode{cloudsql.ReasonStorageWithinTarget}
+		return decision
+	}
+
+	maxSize := e.maxDiskSizeGB()
+	if instance.DiskSizeGB >= maxSize {
+		decision.Reason = fmt.Sprintf("Disk is %.1f%% full but already at the %d GB maximum size", decision.UsedPct, maxSize)
+		decision.ReasonCodes = []cloudsql.ReasonCode{cloudsql.ReasonStorageHigh, cloudsql.ReasonStorageAtMaxSize}
+		return decision
+	}
+
+	// A target above the threshold would leave the disk over it after resizing
+	target := e.config.StorageTargetUtilization
+	if target <= 0 || target > threshold {
+		target = threshold
+	}
+	size := int64(math.Ceil(metrics.DiskUsedGB / target))
+	if minSize := instance.DiskSizeGB + e.config.StorageMinIncreaseGB; size < minSize {
+		size = minSize
+	}
+	decision.Reason = fmt.Sprintf("Disk is %.1f%% full (%.1f of %d GB), above the %.0f%% storage threshold",
+		decision.UsedPct, metrics.DiskUsedGB, instance.DiskSizeGB, threshold*100)
+	if size > maxSize {
+		size = maxSize
+		decision.Reason += fmt.Sprintf("; increase capped at the %d GB maximum size", maxSize)
+	}
+
+	decision.ShouldResize = true
+	decision.RecommendedSizeGB = size
+	decision.ReasonCodes = []cloudsql.ReasonCode{cloudsql.ReasonStorageHigh}
+	decision.EstimatedCost = cloudsql.EstimateStorageCost(instance.DiskType, size-instance.DiskSizeGB)
+	decision.ID = cloudsql.NewDecisionID()
+	return decision
+}
+
+// maxDiskSizeGB returns the largest disk size a decision may recommend
+func (e *Engine) maxDiskSizeGB() int64 {
+	if max := e.config.MaxDiskSizeGB; max > 0 && max < cloudsql.PlatformMaxDiskSizeGB {
+		return max
+	}
+	return cloudsql.PlatformMaxDiskSizeGB
+}
+
+// daysPerMonth converts daily disk growth to monthly
+const daysPerMonth = 30
+
+// minDiskSizeGB is the smallest data disk Cloud SQL provisions
+const minDiskSizeGB = 10
+
+// minReclaimableGB is the least over-provisioning worth a migration
+const minReclaimableGB = 10
+
+// minGrowthPeriod is the shortest history disk usage growth is extrapolated
+// from; shorter ones see temporary files and daily cycles as growth
+const minGrowthPeriod = 24 * time.Hour
+
+// AdviseDiskShrink reports an over-provisioned data disk: one less full than
+// the disk shrink threshold that a disk at least minReclaimableGB smaller
+// would hold, with the usage growth of the shrink horizon brought to the
+// storage target utilization. It returns nil when advisories are off, usage
+// is unknown or covers less than minGrowthPeriod, or the disk is not
+// over-provisioned.
+func (e *Engine) AdviseDiskShrink(instance *config.InstanceInfo, metrics *config.MetricsSummary) *cloudsql.DiskAdvisory {
+	threshold := e.config.DiskShrinkThreshold
+	target := e.config.StorageTargetUtilization
+	if threshold <= 0 || target <= 0 || instance.DiskSizeGB <= 0 || metrics.DiskUsedGB <= 0 || metrics.Period < minGrowthPeriod {
+		return nil
+	}
+	usedPct := cloudsql.StorageUsedPct(instance, metrics)
+	if usedPct >= threshold*100 {
+		return nil
+	}
+
+	growthPerMonth := math.Max(metrics.DiskGrowthGBPerDay, 0) * daysPerMonth
+	months := e.config.DiskShrinkHorizon.Hours() / 24 / daysPerMonth
+	projected := math.Max(metrics.DiskUsedGB+growthPerMonth*months, metrics.DiskUsedMaxGB)
+	size := max(int64(math.Ceil(projected/target)), minDiskSizeGB)
+	reclaimable := instance.DiskSizeGB - size
+	if reclaimable < minReclaimableGB {
+		return nil
+	}
+
+	return &cloudsql.DiskAdvisory{
+		CurrentSizeGB:     instance.DiskSizeGB,
+		UsedGB:            metrics.DiskUsedGB,
+		UsedPct:           usedPct,
+		GrowthGBPerMonth:  growthPerMonth,
+		ProjectedUsedGB:   projected,
+		RecommendedSizeGB: size,
+		ReclaimableGB:     reclaimable,
+		EstimatedSavings:  cloudsql.EstimateStorageCost(instance.DiskType, reclaimable),
+		Reason: fmt.Sprintf("Disk is %.1f%% full (%.1f of %d GB), growing %.1f GB/month; %d GB holds %.0f GB projected in %.0f months at %.0f%% full. "+
+			"Disks cannot shrink in place: migrating to an instance with the smaller disk (export and import, or Database Migration Service) reclaims %d GB",
+			usedPct, metrics.DiskUsedGB, instance.DiskSizeGB, growthPerMonth, size, projected, months, target*100, reclaimable),
+	}
+}
@@ -0,0 +1,45 @@
+package rules
+
+// Severity ranks how much attention a warning needs
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"     // Context for the operator; no action needed
+	SeverityWarning  Severity = "warning"  // The recommendation may be less reliable or need care
+	SeverityCritical Severity = "critical" // Acting on the recommendation is likely unsafe
+)
+
+// WarningCode identifies a kind of warning. Codes are stable so automation can
+// filter on them; new codes may be added.
+type WarningCode string
+
+const (
+	WarningLimitedData        WarningCode = "limited_data"        // Too few data points for a reliable recommendation
+	WarningRecentlyScaled     WarningCode = "recently_scaled"     // Instance is still within its cooldown
+	WarningHighAvailability   WarningCode = "high_availability"   // Scaling affects primary and standby
+	WarningDataCacheAbsorbs   WarningCode = "data_cache_absorbs"  // Data cache hit ratio discounts memory pressure
+	WarningCacheInflated      WarningCode = "cache_inflated"      // Memory pressure mode discounts page cache or uncorroborated memory
+	WarningSQLServerLicensing WarningCode = "sqlserver_licensing" // Per-core licensing raises the scale-up threshold
+	WarningBackupsEnabled     WarningCode = "backups_enabled"     // Scaling should avoid backup windows
+	WarningInvalidTarget      WarningCode = "invalid_target"      // The recommended machine type failed validation and will not be applied
+	WarningConnectionCapacity WarningCode = "connection_capacity" // A scale-down lowers max_connections below peak connections
+	WarningInvalidLabel       WarningCode = "invalid_label"       // An autoscaler policy label has a value that is ignored
+	WarningFleetSignal        WarningCode = "fleet_signal"        // A Recommender API recommendation or insight for the instance
+	WarningColdStart          WarningCode = "cold_start"          // Decisions rest on a shortened window of recent history
+	WarningDecisionHook       WarningCode = "decision_hook"       // The decision hook could not review the proposed resize
+	WarningIOBound            WarningCode = "io_bound"            // Disk IOPS or throughput nears the disk's limit
+)
+
+// Warning is a caveat attached to an analysis. Data carries the values behind
+// Message, keyed by name, for automation that should not parse the text.
+type Warning struct {
+	Code     WarningCode            `json:"code"`
+	Severity Severity               `json:"severity"`
+	Message  string                 `json:"message"`
+	Data     map[string]interface{} `json:"data,omitempty"`
+}
+
+// String returns the warning's message
+func (w Warning) String() string {
+	return w.Message
+}
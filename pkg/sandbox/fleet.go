@@ -0,0 +1,202 @@
+package sandbox
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// DefaultFleetSize is how many instances a sandbox project has by default
+const DefaultFleetSize = 8
+
+// sandboxRegion is where every sandbox instance runs
+const sandboxRegion = "us-central1"
+
+// workload generates an instance's demand. Demand is independent of the
+// instance's size, so resizing an instance changes its utilization the way
+// it would in production.
+type workload struct {
+	cpuCores     float64 // Mean CPU demand in vCPUs
+	memoryGB     float64 // Mean memory demand in GB
+	daily        float64 // Amplitude of the daily cycle, as a fraction of the mean; peaks at 15:00 UTC
+	cpuGrowth    float64 // CPU demand growth per day, as a fraction of the mean
+	memoryGrowth float64 // Memory demand growth per day, as a fraction of the mean
+	noise        float64 // Random variation, as a fraction of the mean
+	diskGB       float64 // Data disk usage in GB (default: 40% of the disk)
+	diskGrowth   float64 // Disk usage growth per day, as a fraction of the starting usage
+}
+
+// template describes one instance of the synthetic fleet
+type template struct {
+	name     string
+	version  string
+	edition  config.Edition
+	tier     string
+	ha       bool
+	primary  string // Template name of the primary, for read replicas
+	labels   map[string]string
+	workload workload
+}
+
+// templates cover the situations the autoscaler handles: instances to grow,
+// to shrink and to leave alone, across engines, editions and tier families
+var templates = []template{
+	{
+		name: "orders-db", version: "POSTGRES_15", edition: config.EditionEnterprise, tier: "db-custom-4-16384", ha: true,
+		labels:   map[string]string{"team": "checkout", "env": "prod"},
+		workload: workload{cpuCores: 3.1, memoryGB: 9, daily: 0.2, noise: 0.05, diskGB: 88, diskGrowth: 0.02},
+	},
+	{
+		name: "analytics-db", version: "POSTGRES_15", edition: config.EditionEnterprise, tier: "db-custom-16-65536",
+		labels:   map[string]string{"team": "data", "env": "prod"},
+		workload: workload{cpuCores: 1.4, memoryGB: 12, daily: 0.3, noise: 0.1, diskGB: 72, diskGrowth: 0.05},
+	},
+	{
+		name: "orders-db-replica", version: "POSTGRES_15", edition: config.EditionEnterprise, tier: "db-custom-4-16384", primary: "orders-db",
+		labels:   map[string]string{"team": "checkout", "env": "prod"},
+		workload: workload{cpuCores: 1.6, memoryGB: 7, daily: 0.2, noise: 0.05},
+	},
+	{
+		name: "payments-db", version: "MYSQL_8_0", edition: config.EditionEnterprisePlus, tier: "db-perf-optimized-N-4", ha: true,
+		labels:   map[string]string{"team": "payments", "env": "prod"},
+		workload: workload{cpuCores: 1.5, memoryGB: 20, daily: 0.1, memoryGrowth: 0.1, noise: 0.03},
+	},
+	{
+		name: "inventory-db", version: "MYSQL_8_0", edition: config.EditionEnterprise, tier: "db-n1-standard-4",
+		labels:   map[string]string{"team": "catalog", "env": "prod"},
+		workload: workload{cpuCores: 2.2, memoryGB: 8, daily: 0.1, noise: 0.05},
+	},
+	{
+		name: "sessions-db", version: "POSTGRES_16", edition: config.EditionEnterprisePlus, tier: "db-perf-optimized-N-8",
+		labels:   map[string]string{"team": "identity", "env": "prod"},
+		workload: workload{cpuCores: 4, memoryGB: 30, daily: 0.5, cpuGrowth: 0.05, noise: 0.08},
+	},
+	{
+		name: "billing-mssql", version: "SQLSERVER_2019_STANDARD", edition: config.EditionEnterprise, tier: "db-custom-8-32768",
+		labels:   map[string]string{"team": "finance", "env": "prod"},
+		workload: workload{cpuCores: 0.9, memoryGB: 9, daily: 0.2, noise: 0.05},
+	},
+	{
+		name: "staging-db", version: "POSTGRES_15", edition: config.EditionEnterprise, tier: "db-g1-small",
+		labels:   map[string]string{"team": "platform", "env": "staging"},
+		workload: workload{cpuCores: 0.3, memoryGB: 0.8, daily: 0.4, noise: 0.2},
+	},
+}
+
+// newFleet creates size instances from the templates. Fleets larger than
+// the template list repeat it with numbered names, and seed varies each
+// instance's demand so repeated templates behave differently.
+func newFleet(projectID string, size int, seed int64, now time.Time) []*instance {
+	rng := rand.New(rand.NewSource(seed))
+	fleet := make([]*instance, 0, size)
+	byName := make(map[string]*instance, size)
+	reads := make(map[string]*readGroup)
+
+	for i := 0; i < size; i++ {
+		t := templates[i%len(templates)]
+		round := i / len(templates)
+
+		w := t.workload
+		scale := 0.85 + 0.3*rng.Float64()
+		w.cpuCores *= scale
+		w.memoryGB *= scale
+		if w.diskGB == 0 {
+			w.diskGB = 40
+		}
+
+		mt, err := config.GetMachineType(t.tier)
+		if err != nil {
+			panic(fmt.Sprintf("sandbox template %s has unknown tier %s", t.name, t.tier))
+		}
+
+		info := &config.InstanceInfo{
+			Name:             fleetName(t.name, round),
+			Project:          projectID,
+			DatabaseVersion:  t.version,
+			MachineType:      t.tier,
+			Edition:          t.edition,
+			State:            "RUNNABLE",
+			CurrentCPU:       mt.CPU,
+			CurrentMemoryGB:  mt.MemoryGB,
+			BackupEnabled:    true,
+			HighAvailability: t.ha,
+			Region:           sandboxRegion,
+			Zone:             sandboxRegion + "-a",
+			Labels:           make(map[string]string, len(t.labels)),
+			DiskSizeGB:       100,
+			DiskType:         "PD_SSD",
+			PricingPlan:      "PER_USE",
+			InstanceType:     "CLOUD_SQL_INSTANCE",
+			IPAddresses:      map[string]string{"PRIVATE": fmt.Sprintf("10.0.%d.%d", round, i%len(templates)+2)},
+		}
+		if t.ha {
+			info.SecondaryZone = sandboxRegion + "-b"
+		}
+		for k, v := range t.labels {
+			info.Labels[k] = v
+		}
+		if t.primary != "" {
+			info.InstanceType = "READ_REPLICA_INSTANCE"
+			info.PrimaryInstance = fleetName(t.primary, round)
+			if primary, ok := byName[info.PrimaryInstance]; ok {
+				primary.info.Replicas = append(primary.info.Replicas, info.Name)
+			}
+		}
+
+		inst := &instance{
+			info:     info,
+			workload: w,
+			origin:   now,
+			sizes:    []resize{{machineType: mt}},
+		}
+		if t.primary != "" {
+			if reads[info.PrimaryInstance] == nil {
+				reads[info.PrimaryInstance] = &readGroup{}
+			}
+			reads[info.PrimaryInstance].join(inst, true)
+		}
+		fleet = append(fleet, inst)
+		byName[info.Name] = inst
+	}
+	return fleet
+}
+
+// fleetName names the copy of a template in the given round
+func fleetName(name string, round int) string {
+	if round == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s-%d", name, round+1)
+}
+
+// demand returns the CPU (vCPUs) and memory (GB) the workload asks for at t.
+// origin anchors the growth terms, so demand grows from the time the
+// sandbox started.
+func (w workload) demand(name string, t, origin time.Time) (cpuCores, memoryGB float64) {
+	hour := float64(t.UTC().Hour()) + float64(t.UTC().Minute())/60
+	cycle := 1 + w.daily*math.Sin(2*math.Pi*(hour-9)/24)
+	days := t.Sub(origin).Hours() / 24
+
+	cpuCores = w.cpuCores * cycle * math.Max(0.1, 1+w.cpuGrowth*days) * (1 + w.noise*noise(name+"/cpu", t))
+	memoryGB = w.memoryGB * math.Max(0.1, 1+w.memoryGrowth*days) * (1 + w.noise/2*noise(name+"/memory", t))
+	return math.Max(0, cpuCores), math.Max(0, memoryGB)
+}
+
+// diskUsage returns the data disk usage (GB) at t on a disk of sizeGB. Usage
+// grows from the time the sandbox started and stops when the disk is full.
+func (w workload) diskUsage(t, origin time.Time, sizeGB int64) float64 {
+	days := t.Sub(origin).Hours() / 24
+	return math.Min(w.diskGB*(1+w.diskGrowth*math.Max(0, days)), float64(sizeGB))
+}
+
+// noise returns a deterministic value in [-1, 1] for key at t, so repeated
+// reads of the same history return the same samples
+func noise(key string, t time.Time) float64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s/%d", key, t.Unix())
+	return float64(h.Sum64()%2001)/1000 - 1
+}
@@ -0,0 +1,427 @@
+// Package sandbox provides an in-memory Cloud SQL project with a synthetic
+// fleet and workload, so the autoscaler can run end to end without GCP.
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// DefaultOperationDelay is how long a sandbox resize takes to complete
+const DefaultOperationDelay = 20 * time.Second
+
+// Project is an in-memory stand-in for the Cloud SQL Admin and Monitoring
+// APIs of one project. It implements analyzer.SQLAdmin and
+// analyzer.MetricsSource. Metrics are generated from each instance's
+// workload and the machine type it had at every point in time, so resizes
+// show up in later readings. It is safe for concurrent use.
+type Project struct {
+	mu             sync.Mutex
+	projectID      string
+	instances      []*instance
+	byName         map[string]*instance
+	operations     map[string]*operation
+	nextOp         int
+	operationDelay time.Duration
+}
+
+// instance is a sandbox instance and its history
+type instance struct {
+	info     *config.InstanceInfo
+	workload workload
+	origin   time.Time  // When the sandbox started; workload growth is measured from here
+	sizes    []resize   // Machine types over time, oldest first
+	created  time.Time  // When a read replica added in the sandbox came up; zero for the fleet
+	removed  time.Time  // When a read replica was deleted
+	reads    *readGroup // Read replicas sharing the read workload, for read replicas
+}
+
+// resize records the machine type an instance had from a point in time
+type resize struct {
+	at          time.Time // Zero for the machine type the instance was created with
+	machineType config.MachineType
+}
+
+// operation is a pending or completed resize, of the machine type or, when
+// diskSizeGB is set, of the data disk, or the creation or deletion of a
+// read replica
+type operation struct {
+	instance    string
+	machineType config.MachineType
+	diskSizeGB  int64
+	create      bool
+	delete      bool
+	labels      map[string]string
+	done        time.Time
+	applied     bool
+}
+
+// NewProject creates a sandbox project with size instances. The same seed
+// always produces the same fleet and workload.
+func NewProject(projectID string, size int, seed int64) *Project {
+	if size <= 0 {
+		size = DefaultFleetSize
+	}
+	p := &Project{
+		projectID:      projectID,
+		instances:      newFleet(projectID, size, seed, time.Now()),
+		operations:     make(map[string]*operation),
+		operationDelay: DefaultOperationDelay,
+	}
+	p.byName = make(map[string]*instance, len(p.instances))
+	for _, inst := range p.instances {
+		p.byName[inst.info.Name] = inst
+	}
+	return p
+}
+
+// SetOperationDelay sets how long resizes take to complete
+func (p *Project) SetOperationDelay(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.operationDelay = d
+}
+
+// ProjectID returns the sandbox project's ID
+func (p *Project) ProjectID() string {
+	return p.projectID
+}
+
+// GetInstance returns a copy of the named instance
+func (p *Project) GetInstance(ctx context.Context, instanceName string) (*config.InstanceInfo, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.settleLocked(time.Now())
+
+	inst, ok := p.byName[instanceName]
+	if !ok {
+		return nil, fmt.Errorf("instance %s not in sandbox project %s: %w", instanceName, p.projectID, cloudsql.ErrInstanceNotFound)
+	}
+	return copyInfo(inst.info), nil
+}
+
+// ListInstances returns copies of every instance in the sandbox
+func (p *Project) ListInstances(ctx context.Context) ([]*config.InstanceInfo, []cloudsql.SkippedInstance, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.settleLocked(time.Now())
+
+	instances := make([]*config.InstanceInfo, 0, len(p.instances))
+	for _, inst := range p.instances {
+		instances = append(instances, copyInfo(inst.info))
+	}
+	return instances, nil, nil
+}
+
+// GetLastScalingTime returns when the instance was last resized in the sandbox
+func (p *Project) GetLastScalingTime(ctx context.Context, instanceName string) (time.Time, error) {
+	info, err := p.GetInstance(ctx, instanceName)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.LastScaledTime, nil
+}
+
+// GetPreservedSettings returns the settings a resize must leave unchanged
+func (p *Project) GetPreservedSettings(ctx context.Context, instanceName string) (*cloudsql.PreservedSettings, error) {
+	info, err := p.GetInstance(ctx, instanceName)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := &cloudsql.PreservedSettings{
+		Zone:             info.Zone,
+		SecondaryZone:    info.SecondaryZone,
+		AvailabilityType: "ZONAL",
+		Edition:          string(info.Edition),
+		ActivationPolicy: "ALWAYS",
+		DataDiskType:     info.DiskType,
+		DataDiskSizeGb:   info.DiskSizeGB,
+		DatabaseFlags:    make(map[string]string, len(info.DatabaseFlags)),
+	}
+	if info.HighAvailability {
+		settings.AvailabilityType = "REGIONAL"
+	}
+	for k, v := range info.DatabaseFlags {
+		settings.DatabaseFlags[k] = v
+	}
+	return settings, nil
+}
+
+// StartMachineTypeUpdate starts resizing the instance. The resize completes
+// after the operation delay, whether or not it is waited for.
+func (p *Project) StartMachineTypeUpdate(ctx context.Context, instanceName, machineType string, labels map[string]string) (string, error) {
+	mt, err := config.GetMachineType(machineType)
+	if err != nil {
+		return "", fmt.Errorf("failed to update machine type of %s: %w", instanceName, err)
+	}
+	if err := checkLabels(labels); err != nil {
+		return "", fmt.Errorf("failed to update machine type of %s: %w", instanceName, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.settleLocked(time.Now())
+
+	inst, ok := p.byName[instanceName]
+	if !ok {
+		return "", fmt.Errorf("instance %s not in sandbox project %s: %w", instanceName, p.projectID, cloudsql.ErrInstanceNotFound)
+	}
+	if inst.info.State != "RUNNABLE" {
+		return "", fmt.Errorf("instance %s is %s; another operation is in progress", instanceName, inst.info.State)
+	}
+	if err := config.CheckAvailability(inst.info.Edition, inst.info.DatabaseVersion, machineType); err != nil {
+		return "", fmt.Errorf("failed to update machine type of %s: %w", instanceName, err)
+	}
+
+	return p.startOperationLocked(inst, &operation{machineType: mt, labels: labels}), nil
+}
+
+// StartDiskResize starts growing the instance's data disk. Like a machine
+// type change, it completes after the operation delay.
+func (p *Project) StartDiskResize(ctx context.Context, instanceName string, sizeGB int64, labels map[string]string) (string, error) {
+	if err := checkLabels(labels); err != nil {
+		return "", fmt.Errorf("failed to resize disk of %s: %w", instanceName, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.settleLocked(time.Now())
+
+	inst, ok := p.byName[instanceName]
+	if !ok {
+		return "", fmt.Errorf("instance %s not in sandbox project %s: %w", instanceName, p.projectID, cloudsql.ErrInstanceNotFound)
+	}
+	if inst.info.State != "RUNNABLE" {
+		return "", fmt.Errorf("instance %s is %s; another operation is in progress", instanceName, inst.info.State)
+	}
+	if sizeGB <= inst.info.DiskSizeGB {
+		return "", fmt.Errorf("cannot resize disk of %s to %d GB: disk is already %d GB and cannot shrink",
+			instanceName, sizeGB, inst.info.DiskSizeGB)
+	}
+
+	return p.startOperationLocked(inst, &operation{diskSizeGB: sizeGB, labels: labels}), nil
+}
+
+// checkLabels rejects labels Cloud SQL would refuse: keys must start with a
+// lowercase letter, and keys and values hold at most 63 lowercase letters,
+// digits, '_' and '-'
+func checkLabels(labels map[string]string) error {
+	valid := func(s string) bool {
+		if len(s) > 63 {
+			return false
+		}
+		for _, r := range s {
+			if !unicode.IsLower(r) && !unicode.IsDigit(r) && r != '_' && r != '-' {
+				return false
+			}
+		}
+		return true
+	}
+	for k, v := range labels {
+		if k == "" || !unicode.IsLower(rune(k[0])) || !valid(k) {
+			return fmt.Errorf("invalid label key %q", k)
+		}
+		if !valid(v) {
+			return fmt.Errorf("invalid value %q of label %s", v, k)
+		}
+	}
+	return nil
+}
+
+// startOperationLocked registers op against inst and returns its name; p.mu
+// must be held
+func (p *Project) startOperationLocked(inst *instance, op *operation) string {
+	p.nextOp++
+	name := fmt.Sprintf("sandbox-op-%d", p.nextOp)
+	op.instance = inst.info.Name
+	op.done = time.Now().Add(p.operationDelay)
+	p.operations[name] = op
+	inst.info.State = "MAINTENANCE"
+	return name
+}
+
+// WaitForOperation waits until the operation completes or ctx is done
+func (p *Project) WaitForOperation(ctx context.Context, operationName string) error {
+	p.mu.Lock()
+	op, ok := p.operations[operationName]
+	p.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("operation %s not found in sandbox project %s", operationName, p.projectID)
+	}
+
+	timer := time.NewTimer(time.Until(op.done))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for operation %s: %w", operationName, ctx.Err())
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.settleLocked(time.Now())
+	return nil
+}
+
+// RefreshTiers does nothing; the built-in machine type catalog is used
+func (p *Project) RefreshTiers(ctx context.Context) error {
+	return nil
+}
+
+// GetInstanceMetrics generates the instance's metrics over cfg's metrics
+// period at cfg's metrics interval
+func (p *Project) GetInstanceMetrics(ctx context.Context, info *config.InstanceInfo, cfg *config.Config) (*config.MetricsData, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.settleLocked(time.Now())
+
+	inst, ok := p.byName[info.Name]
+	if !ok {
+		return nil, fmt.Errorf("instance %s not in sandbox project %s: %w", info.Name, p.projectID, cloudsql.ErrInstanceNotFound)
+	}
+
+	interval := cfg.MetricsInterval
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	end := time.Now().Truncate(interval)
+	points := int(cfg.MetricsPeriod / interval)
+
+	data := &config.MetricsData{}
+	for i := points - 1; i >= 0; i-- {
+		t := end.Add(-time.Duration(i) * interval)
+		if t.Before(inst.created) {
+			continue
+		}
+		mt := inst.machineTypeAt(t)
+		cpuCores, memoryGB := inst.workload.demand(inst.info.Name, t, inst.origin)
+		if inst.reads != nil {
+			cpuCores *= inst.reads.share(t)
+		}
+
+		cpuPercent := clamp(100*cpuCores/float64(mt.CPU), 0, 100)
+		usedGB := clamp(memoryGB, 0, 0.98*mt.MemoryGB)
+		memoryPercent := 100 * usedGB / mt.MemoryGB
+
+		data.Timestamps = append(data.Timestamps, t)
+		data.CPUUtilization = append(data.CPUUtilization, cpuPercent)
+		data.MemoryUsageGB = append(data.MemoryUsageGB, usedGB)
+		data.MemoryPercent = append(data.MemoryPercent, memoryPercent)
+		data.MemoryNonCachePercent = append(data.MemoryNonCachePercent, 0.75*memoryPercent)
+		data.SwapInPages = append(data.SwapInPages, 0)
+		if config.ParseEngine(inst.info.DatabaseVersion) == config.EngineSQLServer {
+			// Queries queue for memory grants once demand outgrows the instance
+			data.MemoryGrantsPending = append(data.MemoryGrantsPending, math.Ceil(max(memoryGB-usedGB, 0)))
+		}
+		data.Connections = append(data.Connections, int(25*cpuCores))
+		data.DiskUsageGB = append(data.DiskUsageGB, inst.workload.diskUsage(t, inst.origin, inst.info.DiskSizeGB))
+		data.DiskIOPS = append(data.DiskIOPS, 150*cpuCores)
+		data.DiskThroughputMBps = append(data.DiskThroughputMBps, 2.5*cpuCores)
+		if inst.reads != nil {
+			data.ReplicaLagSeconds = append(data.ReplicaLagSeconds, replicaLag(cpuPercent))
+		}
+	}
+	return data, nil
+}
+
+// QuotaBudget returns nil; the sandbox uses no Monitoring quota
+func (p *Project) QuotaBudget() *cloudsql.QuotaBudget {
+	return nil
+}
+
+// Close does nothing
+func (p *Project) Close() error {
+	return nil
+}
+
+// settleLocked applies the operations that have completed by now;
+// p.mu must be held
+func (p *Project) settleLocked(now time.Time) {
+	var completed []*operation
+	for _, op := range p.operations {
+		if !op.applied && !now.Before(op.done) {
+			completed = append(completed, op)
+		}
+	}
+	sort.Slice(completed, func(i, j int) bool { return completed[i].done.Before(completed[j].done) })
+
+	for _, op := range completed {
+		op.applied = true
+		inst := p.byName[op.instance]
+		info := inst.info
+		switch {
+		case op.delete:
+			p.removeLocked(inst, op.done)
+			continue
+		case op.create:
+			// The replica serves reads from inst.created; only its state changes
+		case op.diskSizeGB > 0:
+			info.DiskSizeGB = op.diskSizeGB
+		default:
+			inst.sizes = append(inst.sizes, resize{at: op.done, machineType: op.machineType})
+			info.MachineType = op.machineType.Name
+			info.CurrentCPU = op.machineType.CPU
+			info.CurrentMemoryGB = op.machineType.MemoryGB
+			info.LastScaledTime = op.done
+		}
+		info.State = "RUNNABLE"
+		for k, v := range op.labels {
+			info.Labels[k] = v
+		}
+	}
+}
+
+// machineTypeAt returns the machine type the instance had at t
+func (inst *instance) machineTypeAt(t time.Time) config.MachineType {
+	mt := inst.sizes[0].machineType
+	for _, r := range inst.sizes[1:] {
+		if r.at.After(t) {
+			break
+		}
+		mt = r.machineType
+	}
+	return mt
+}
+
+// copyInfo returns a copy of info that shares no maps or slices with it
+func copyInfo(info *config.InstanceInfo) *config.InstanceInfo {
+	c := *info
+	c.Labels = copyMap(info.Labels)
+	c.DatabaseFlags = copyMap(info.DatabaseFlags)
+	c.IPAddresses = copyMap(info.IPAddresses)
+	c.Replicas = append([]string(nil), info.Replicas...)
+	c.FailoverReplicas = append([]string(nil), info.FailoverReplicas...)
+	return &c
+}
+
+// copyMap returns a copy of m, or nil if m is nil
+func copyMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// clamp limits v to [lo, hi]
+func clamp(v, lo, hi float64) float64 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -0,0 +1,172 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// readGroup is the read replicas of one primary. Their workloads are the
+// read demand of the fleet's original replicas, spread evenly across the
+// replicas serving at any point in time, so adding a replica lowers the
+// utilization of the others.
+type readGroup struct {
+	baseline int // Read replicas the fleet was created with
+	members  []*instance
+}
+
+// join adds inst to the group; original replicas count towards the baseline
+func (g *readGroup) join(inst *instance, original bool) {
+	if original {
+		g.baseline++
+	}
+	g.members = append(g.members, inst)
+	inst.reads = g
+}
+
+// share returns the fraction of a replica's baseline read demand it serves at t
+func (g *readGroup) share(t time.Time) float64 {
+	serving := 0
+	for _, m := range g.members {
+		if !m.created.After(t) && (m.removed.IsZero() || t.Before(m.removed)) {
+			serving++
+		}
+	}
+	if serving == 0 || g.baseline == 0 {
+		return 1
+	}
+	return float64(g.baseline) / float64(serving)
+}
+
+// replicaLag returns the replication lag (seconds) of a replica at
+// cpuPercent CPU utilization; replicas fall behind once they run hot
+func replicaLag(cpuPercent float64) float64 {
+	return math.Max(0, cpuPercent-85) * 6
+}
+
+// StartReadReplicaCreate starts creating a read replica of primary. The
+// replica takes over an even share of the primary's read workload once the
+// operation completes.
+func (p *Project) StartReadReplicaCreate(ctx context.Context, primary, name, tier string, labels map[string]string) (string, error) {
+	mt, err := config.GetMachineType(tier)
+	if err != nil {
+		return "", fmt.Errorf("failed to create read replica %s: %w", name, err)
+	}
+	if err := checkLabels(labels); err != nil {
+		return "", fmt.Errorf("failed to create read replica %s: %w", name, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.settleLocked(time.Now())
+
+	source, ok := p.byName[primary]
+	if !ok {
+		return "", fmt.Errorf("instance %s not in sandbox project %s: %w", primary, p.projectID, cloudsql.ErrInstanceNotFound)
+	}
+	if _, exists := p.byName[name]; exists {
+		return "", fmt.Errorf("failed to create read replica %s: instance already exists", name)
+	}
+	if err := config.CheckAvailability(source.info.Edition, source.info.DatabaseVersion, tier); err != nil {
+		return "", fmt.Errorf("failed to create read replica %s: %w", name, err)
+	}
+
+	// New replicas serve the same reads as their siblings, or half the
+	// primary's demand for its first replica
+	group, w := p.readGroupLocked(primary)
+	if len(group.members) > 0 {
+		w = group.members[0].workload
+	} else {
+		w.cpuCores /= 2
+	}
+	w.cpuGrowth, w.memoryGrowth, w.diskGrowth = 0, 0, 0
+
+	info := copyInfo(source.info)
+	info.Name = name
+	info.MachineType = mt.Name
+	info.CurrentCPU = mt.CPU
+	info.CurrentMemoryGB = mt.MemoryGB
+	info.InstanceType = "READ_REPLICA_INSTANCE"
+	info.PrimaryInstance = primary
+	info.Replicas, info.FailoverReplicas = nil, nil
+	info.HighAvailability, info.SecondaryZone = false, ""
+	info.LastScaledTime = time.Time{}
+	for k, v := range labels {
+		info.Labels[k] = v
+	}
+
+	now := time.Now()
+	inst := &instance{
+		info:     info,
+		workload: w,
+		origin:   now,
+		sizes:    []resize{{machineType: mt}},
+		created:  now.Add(p.operationDelay),
+	}
+	group.join(inst, false)
+	p.instances = append(p.instances, inst)
+	p.byName[name] = inst
+	source.info.Replicas = append(source.info.Replicas, name)
+
+	op := p.startOperationLocked(inst, &operation{create: true})
+	inst.info.State = "PENDING_CREATE"
+	return op, nil
+}
+
+// StartReadReplicaDelete starts deleting a read replica. Its share of the
+// read workload moves to the remaining replicas once the operation completes.
+func (p *Project) StartReadReplicaDelete(ctx context.Context, name string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.settleLocked(time.Now())
+
+	inst, ok := p.byName[name]
+	if !ok {
+		return "", fmt.Errorf("instance %s not in sandbox project %s: %w", name, p.projectID, cloudsql.ErrInstanceNotFound)
+	}
+	if !cloudsql.IsReadReplica(inst.info) {
+		return "", fmt.Errorf("refusing to delete %s: not a read replica", name)
+	}
+	if inst.info.State != "RUNNABLE" {
+		return "", fmt.Errorf("instance %s is %s; another operation is in progress", name, inst.info.State)
+	}
+	return p.startOperationLocked(inst, &operation{delete: true}), nil
+}
+
+// readGroupLocked returns the read replica group of primary, creating it if
+// primary has none yet, and the primary's workload; p.mu must be held
+func (p *Project) readGroupLocked(primary string) (*readGroup, workload) {
+	source := p.byName[primary]
+	for _, name := range source.info.Replicas {
+		if r, ok := p.byName[name]; ok && r.reads != nil {
+			return r.reads, source.workload
+		}
+	}
+	return &readGroup{}, source.workload
+}
+
+// removeLocked deletes a read replica from the project at t. It stays in its
+// read group so the other replicas' history is unchanged; p.mu must be held.
+func (p *Project) removeLocked(inst *instance, t time.Time) {
+	name := inst.info.Name
+	inst.removed = t
+	delete(p.byName, name)
+	for i, other := range p.instances {
+		if other == inst {
+			p.instances = append(p.instances[:i], p.instances[i+1:]...)
+			break
+		}
+	}
+	if primary, ok := p.byName[inst.info.PrimaryInstance]; ok {
+		for i, r := range primary.info.Replicas {
+			if r == name {
+				primary.info.Replicas = append(primary.info.Replicas[:i], primary.info.Replicas[i+1:]...)
+				break
+			}
+		}
+	}
+}
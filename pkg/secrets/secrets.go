@@ -0,0 +1,216 @@
+// Package secrets resolves credentials from references rather than plain
+// values, so flags, manifests and committed configuration never hold them:
+//
+//	env:NAME                                   an environment variable
+//	file:///path                               a file, e.g. a mounted Kubernetes secret
+//	sm://projects/P/secrets/S[/versions/V]     a Secret Manager secret version (default latest)
+//
+// Anything else is taken literally. File and Secret Manager values are
+// re-read by Refresh, so rotated credentials are picked up without a restart.
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	secretmanager "google.golang.org/api/secretmanager/v1"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+)
+
+// Kind identifies where a secret's value comes from
+type Kind string
+
+const (
+	KindLiteral       Kind = "literal"
+	KindEnv           Kind = "env"
+	KindFile          Kind = "file"
+	KindSecretManager Kind = "secret_manager"
+)
+
+// Reference prefixes, see the package documentation
+const (
+	envPrefix           = "env:"
+	filePrefix          = "file://"
+	secretManagerPrefix = "sm://"
+)
+
+// ErrInvalidReference is returned for references that cannot be resolved
+var ErrInvalidReference = errors.New("invalid secret reference")
+
+// Secret is a credential resolved from a reference. Its value is safe to
+// read while it is being refreshed.
+type Secret struct {
+	kind Kind
+	ref  string // Reference without its prefix; the value itself for literals
+
+	mu       sync.RWMutex
+	value    string
+	loadedAt time.Time
+}
+
+// Literal returns a secret holding value as is
+func Literal(value string) *Secret {
+	return &Secret{kind: KindLiteral, ref: value, value: value}
+}
+
+// Resolve parses ref and loads the secret's value. An empty reference
+// resolves to an empty literal, so optional credentials stay unset.
+func Resolve(ctx context.Context, ref string) (*Secret, error) {
+	s := &Secret{kind: KindLiteral, ref: ref}
+	switch {
+	case strings.HasPrefix(ref, envPrefix):
+		s.kind, s.ref = KindEnv, strings.TrimPrefix(ref, envPrefix)
+	case strings.HasPrefix(ref, filePrefix):
+		s.kind, s.ref = KindFile, strings.TrimPrefix(ref, filePrefix)
+	case strings.HasPrefix(ref, secretManagerPrefix):
+		s.kind, s.ref = KindSecretManager, strings.TrimPrefix(ref, secretManagerPrefix)
+		if !strings.HasPrefix(s.ref, "projects/") || !strings.Contains(s.ref, "/secrets/") {
+			return nil, fmt.Errorf("%w %q: want sm://projects/PROJECT/secrets/NAME[/versions/VERSION]", ErrInvalidReference, ref)
+		}
+		if !strings.Contains(s.ref, "/versions/") {
+			s.ref += "/versions/latest"
+		}
+	default:
+		s.value = ref
+		return s, nil
+	}
+	if s.ref == "" {
+		return nil, fmt.Errorf("%w %q: empty %s reference", ErrInvalidReference, ref, s.kind)
+	}
+	if err := s.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Value returns the secret's current value
+func (s *Secret) Value() string {
+	if s == nil {
+		return ""
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.value
+}
+
+// IsSet reports whether the secret has a non-empty value
+func (s *Secret) IsSet() bool {
+	return s.Value() != ""
+}
+
+// Kind returns where the secret's value comes from
+func (s *Secret) Kind() Kind {
+	return s.kind
+}
+
+// String describes the secret without revealing its value, e.g.
+// "file:///var/run/secrets/token"
+func (s *Secret) String() string {
+	switch s.kind {
+	case KindEnv:
+		return envPrefix + s.ref
+	case KindFile:
+		return filePrefix + s.ref
+	case KindSecretManager:
+		return secretManagerPrefix + s.ref
+	default:
+		return string(KindLiteral)
+	}
+}
+
+// Refresh re-reads the secret's value. On failure the previous value is kept.
+func (s *Secret) Refresh(ctx context.Context) error {
+	var value string
+	var err error
+	switch s.kind {
+	case KindEnv:
+		var ok bool
+		if value, ok = os.LookupEnv(s.ref); !ok {
+			err = fmt.Errorf("environment variable %s is not set", s.ref)
+		}
+	case KindFile:
+		var data []byte
+		data, err = os.ReadFile(s.ref)
+		// Mounted secrets and editors commonly leave a trailing newline
+		value = strings.TrimRight(string(data), "\r\n")
+	case KindSecretManager:
+		value, err = accessSecretVersion(ctx, s.ref)
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load secret %s: %w", s, err)
+	}
+
+	s.mu.Lock()
+	s.value = value
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// Watch refreshes the secret every interval until ctx is done. Failures are
+// logged and the last good value stays in use. Literal and environment
+// secrets never change, so Watch returns at once for them.
+func (s *Secret) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 || s.kind == KindLiteral || s.kind == KindEnv {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(ctx); err != nil {
+				log.Printf("Warning: %v; keeping the value loaded at %s", err, config.FormatTime(s.loaded()))
+			}
+		}
+	}
+}
+
+// loaded returns when the secret's value was last loaded
+func (s *Secret) loaded() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.loadedAt
+}
+
+// Secret Manager client, created on first use with Application Default Credentials
+var (
+	smOnce    sync.Once
+	smService *secretmanager.Service
+	smErr     error
+)
+
+// accessSecretVersion returns the payload of the named Secret Manager version
+func accessSecretVersion(ctx context.Context, name string) (string, error) {
+	smOnce.Do(func() {
+		smService, smErr = secretmanager.NewService(context.Background())
+	})
+	if smErr != nil {
+		return "", fmt.Errorf("failed to create Secret Manager client: %w", smErr)
+	}
+
+	resp, err := smService.Projects.Secrets.Versions.Access(name).Context(ctx).Do()
+	if err != nil {
+		return "", err
+	}
+	if resp.Payload == nil {
+		return "", fmt.Errorf("secret version %s has no payload", name)
+	}
+	data, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode payload of %s: %w", name, err)
+	}
+	return string(data), nil
+}
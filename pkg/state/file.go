@@ -0,0 +1,63 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileBackend keeps the state document in a local file. Writes replace the
+// file atomically so a crash never leaves it truncated.
+type FileBackend struct {
+	path string
+}
+
+// NewFileBackend creates a backend kept at path. The file is created on the
+// first write.
+func NewFileBackend(path string) *FileBackend {
+	return &FileBackend{path: path}
+}
+
+// String returns the file's path
+func (f *FileBackend) String() string {
+	return f.path
+}
+
+// Read returns the file's contents, or nil if it does not exist
+func (f *FileBackend) Read(ctx context.Context) ([]byte, error) {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state store: %w", err)
+	}
+	return data, nil
+}
+
+// Write replaces the file's contents with data
+func (f *FileBackend) Write(ctx context.Context, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write state store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state store: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("failed to write state store: %w", err)
+	}
+	return nil
+}
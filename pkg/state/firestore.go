@@ -0,0 +1,111 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	firestore "google.golang.org/api/firestore/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// firestoreField is the document field holding the state document's JSON
+const firestoreField = "state"
+
+// firestoreBackend keeps the state document in a Firestore document. Each
+// write is conditional on the update time last read or written, so a second
+// daemon writing the same document is detected instead of silently
+// overwritten.
+type firestoreBackend struct {
+	name    string // projects/P/databases/(default)/documents/COLLECTION/DOCUMENT
+	path    string // COLLECTION/DOCUMENT
+	service *firestore.Service
+
+	mu         sync.Mutex
+	updateTime string // Empty while the document does not exist
+}
+
+// newFirestoreBackend creates a backend kept at path, COLLECTION/DOCUMENT in
+// projectID's default database. FIRESTORE_EMULATOR_HOST directs it to the
+// Firestore emulator instead, as with the Google client libraries.
+func newFirestoreBackend(ctx context.Context, projectID, path string, opts ...option.ClientOption) (*firestoreBackend, error) {
+	collection, document, ok := strings.Cut(path, "/")
+	if !ok || collection == "" || document == "" || strings.Contains(document, "/") {
+		return nil, fmt.Errorf("invalid state store firestore://%s (must be firestore://COLLECTION/DOCUMENT)", path)
+	}
+	if projectID == "" {
+		return nil, fmt.Errorf("a project is required for state store firestore://%s", path)
+	}
+
+	opts = append([]option.ClientOption{option.WithScopes(firestore.DatastoreScope)}, opts...)
+	if host := os.Getenv("FIRESTORE_EMULATOR_HOST"); host != "" {
+		opts = []option.ClientOption{option.WithEndpoint("http://" + host + "/"), option.WithoutAuthentication()}
+	}
+	service, err := firestore.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Firestore client: %w", err)
+	}
+	return &firestoreBackend{
+		name:    "projects/" + projectID + "/databases/(default)/documents/" + path,
+		path:    path,
+		service: service,
+	}, nil
+}
+
+// String returns the document's firestore:// URL
+func (f *firestoreBackend) String() string {
+	return "firestore://" + f.path
+}
+
+// Read returns the document's state field, or nil if the document does not exist
+func (f *firestoreBackend) Read(ctx context.Context) ([]byte, error) {
+	doc, err := f.service.Projects.Databases.Documents.Get(f.name).Context(ctx).Do()
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		f.setUpdateTime("")
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state store %s: %w", f, err)
+	}
+	f.setUpdateTime(doc.UpdateTime)
+	return []byte(doc.Fields[firestoreField].StringValue), nil
+}
+
+// Write stores data in the document's state field, failing if the document
+// changed since it was last read or written
+func (f *firestoreBackend) Write(ctx context.Context, data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	call := f.service.Projects.Databases.Documents.Patch(f.name, &firestore.Document{
+		Fields: map[string]firestore.Value{firestoreField: {StringValue: string(data)}},
+	})
+	if f.updateTime == "" {
+		call = call.CurrentDocumentExists(false)
+	} else {
+		call = call.CurrentDocumentUpdateTime(f.updateTime)
+	}
+	doc, err := call.Context(ctx).Do()
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && (apiErr.Code == http.StatusPreconditionFailed || apiErr.Code == http.StatusConflict ||
+		(apiErr.Code == http.StatusBadRequest && strings.Contains(apiErr.Message, "precondition"))) {
+		return fmt.Errorf("failed to write state store %s: it was changed by another writer; only one daemon may use it", f)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write state store %s: %w", f, err)
+	}
+	f.updateTime = doc.UpdateTime
+	return nil
+}
+
+func (f *firestoreBackend) setUpdateTime(updateTime string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.updateTime = updateTime
+}
@@ -0,0 +1,100 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	storage "google.golang.org/api/storage/v1"
+)
+
+// gcsBackend keeps the state document in a Cloud Storage object. Each write
+// is conditional on the generation last read or written, so a second daemon
+// writing the same object is detected instead of silently overwritten.
+type gcsBackend struct {
+	bucket  string
+	object  string
+	service *storage.Service
+
+	mu         sync.Mutex
+	generation int64 // 0 while the object does not exist
+}
+
+// newGCSBackend creates a backend kept at path, BUCKET/OBJECT
+func newGCSBackend(ctx context.Context, path string, opts ...option.ClientOption) (*gcsBackend, error) {
+	bucket, object, ok := strings.Cut(path, "/")
+	if !ok || bucket == "" || object == "" {
+		return nil, fmt.Errorf("invalid state store gs://%s (must be gs://BUCKET/OBJECT)", path)
+	}
+	opts = append([]option.ClientOption{option.WithScopes(storage.DevstorageReadWriteScope)}, opts...)
+	service, err := storage.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud Storage client: %w", err)
+	}
+	return &gcsBackend{bucket: bucket, object: object, service: service}, nil
+}
+
+// String returns the object's gs:// URL
+func (g *gcsBackend) String() string {
+	return "gs://" + g.bucket + "/" + g.object
+}
+
+// Read downloads the object, or returns nil if it does not exist
+func (g *gcsBackend) Read(ctx context.Context) ([]byte, error) {
+	resp, err := g.service.Objects.Get(g.bucket, g.object).Context(ctx).Download()
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		g.setGeneration(0)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state store %s: %w", g, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state store %s: %w", g, err)
+	}
+	generation, err := strconv.ParseInt(resp.Header.Get("X-Goog-Generation"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state store %s: no object generation in response", g)
+	}
+	g.setGeneration(generation)
+	return data, nil
+}
+
+// Write uploads data, failing if the object changed since it was last read
+// or written
+func (g *gcsBackend) Write(ctx context.Context, data []byte) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	obj, err := g.service.Objects.Insert(g.bucket, &storage.Object{Name: g.object, ContentType: "application/json"}).
+		IfGenerationMatch(g.generation).
+		Media(bytes.NewReader(data), googleapi.ContentType("application/json")).
+		Context(ctx).Do()
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+		return fmt.Errorf("failed to write state store %s: it was changed by another writer; only one daemon may use it", g)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write state store %s: %w", g, err)
+	}
+	g.generation = obj.Generation
+	return nil
+}
+
+func (g *gcsBackend) setGeneration(generation int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.generation = generation
+}
@@ -0,0 +1,165 @@
+// Package state persists what the daemon learns while it runs so that it
+// survives restarts: when the autoscaler last resized each instance, which
+// cooldowns and minimum intervals are measured from, the pre-scales it has
+// scheduled and the freezes set through its API. The state is one JSON
+// document of named sections, kept in a local file, a Cloud Storage object or
+// a Firestore document.
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/option"
+)
+
+// Backend reads and writes the state document
+type Backend interface {
+	// Read returns the stored document, or nil if none was written yet
+	Read(ctx context.Context) ([]byte, error)
+	// Write replaces the stored document
+	Write(ctx context.Context, data []byte) error
+	// String describes where the document is kept
+	String() string
+}
+
+// Store keeps named sections of the state document in a backend. The
+// document is read once and cached, so the store must be its backend's only
+// writer. It is safe for concurrent use.
+type Store struct {
+	mu       sync.Mutex
+	backend  Backend
+	sections map[string]json.RawMessage // nil until read
+}
+
+// New creates a store kept in backend
+func New(backend Backend) *Store {
+	return &Store{backend: backend}
+}
+
+// Open creates a store kept at location: gs://BUCKET/OBJECT for a Cloud
+// Storage object, firestore://COLLECTION/DOCUMENT for a document in
+// projectID's default Firestore database, or a local file path
+func Open(ctx context.Context, location, projectID string, opts ...option.ClientOption) (*Store, error) {
+	switch {
+	case strings.HasPrefix(location, "gs://"):
+		backend, err := newGCSBackend(ctx, strings.TrimPrefix(location, "gs://"), opts...)
+		if err != nil {
+			return nil, err
+		}
+		return New(backend), nil
+	case strings.HasPrefix(location, "firestore://"):
+		backend, err := newFirestoreBackend(ctx, projectID, strings.TrimPrefix(location, "firestore://"), opts...)
+		if err != nil {
+			return nil, err
+		}
+		return New(backend), nil
+	case strings.Contains(location, "://"):
+		return nil, fmt.Errorf("invalid state store %q (must be gs://BUCKET/OBJECT, firestore://COLLECTION/DOCUMENT or a file path)", location)
+	case location == "":
+		return nil, fmt.Errorf("state store location is empty")
+	default:
+		return New(NewFileBackend(location)), nil
+	}
+}
+
+// String describes where the store is kept
+func (s *Store) String() string {
+	return s.backend.String()
+}
+
+// Get decodes the named section into v. It reports false, leaving v
+// untouched, when the section was never stored.
+func (s *Store) Get(ctx context.Context, section string, v interface{}) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(ctx); err != nil {
+		return false, err
+	}
+	data, ok := s.sections[section]
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("failed to parse %s in state store %s: %w", section, s.backend, err)
+	}
+	return true, nil
+}
+
+// Put replaces the named section with v
+func (s *Store) Put(ctx context.Context, section string, v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(ctx); err != nil {
+		return err
+	}
+	return s.put(ctx, section, v)
+}
+
+// Update decodes the named section into v, calls update to change it and
+// stores the result, holding the store meanwhile so concurrent updates of a
+// section are not lost. v is left as decoded when the section was never
+// stored.
+func (s *Store) Update(ctx context.Context, section string, v interface{}, update func()) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(ctx); err != nil {
+		return err
+	}
+	if data, ok := s.sections[section]; ok {
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("failed to parse %s in state store %s: %w", section, s.backend, err)
+		}
+	}
+	update()
+	return s.put(ctx, section, v)
+}
+
+// load reads the document unless it is cached; s.mu must be held
+func (s *Store) load(ctx context.Context) error {
+	if s.sections != nil {
+		return nil
+	}
+	data, err := s.backend.Read(ctx)
+	if err != nil {
+		return err
+	}
+	sections := make(map[string]json.RawMessage)
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &sections); err != nil {
+			return fmt.Errorf("failed to parse state store %s: %w", s.backend, err)
+		}
+	}
+	s.sections = sections
+	return nil
+}
+
+// put encodes v as the named section and writes the document; s.mu must be
+// held. The cache is updated only once the write succeeds.
+func (s *Store) put(ctx context.Context, section string, v interface{}) error {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s for state store: %w", section, err)
+	}
+	sections := make(map[string]json.RawMessage, len(s.sections)+1)
+	for k, raw := range s.sections {
+		sections[k] = raw
+	}
+	sections[section] = encoded
+
+	data, err := json.MarshalIndent(sections, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state store: %w", err)
+	}
+	if err := s.backend.Write(ctx, data); err != nil {
+		return err
+	}
+	s.sections = sections
+	return nil
+}
@@ -0,0 +1,79 @@
+// Package version reports which build of the autoscaler is running.
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Build metadata, set at link time with
+//
+//	-ldflags "-X github.com/fraser-isbester/cloudsql-autoscaler/pkg/version.Version=v1.2.3 ..."
+//
+// Builds without them, such as go install, fall back to the module version
+// and commit Go records in the binary, and have no build date.
+var (
+	Version   = "" // Semantic version, e.g. v1.2.3
+	Commit    = "" // Git commit the binary was built from
+	BuildDate = "" // RFC 3339 UTC time of the build
+)
+
+// devVersion is reported when no version is known
+const devVersion = "v0.0.0-dev"
+
+// HTTPAPIVersion is the version of the daemon's /api endpoints and event stream
+const HTTPAPIVersion = "v1"
+
+// Info describes a build of the autoscaler
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Dirty     bool   `json:"dirty,omitempty"` // Built from a tree with uncommitted changes
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"` // GOOS/GOARCH
+
+	// APIs holds the version of each interface other tools depend on, by name
+	APIs map[string]string `json:"apis"`
+}
+
+// Get returns the running build's metadata. APIs lists the daemon HTTP API;
+// callers add the interfaces they serve.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		APIs:      map[string]string{"http": HTTPAPIVersion},
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.modified":
+				info.Dirty = s.Value == "true"
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = devVersion
+	}
+	return info
+}
+
+// ShortCommit returns the first 12 characters of the commit, for display
+func (i Info) ShortCommit() string {
+	if len(i.Commit) > 12 {
+		return i.Commit[:12]
+	}
+	return i.Commit
+}
@@ -0,0 +1,29 @@
+// Package integration runs the autoscaler's analyze, plan and apply loop end
+// to end and fails if any step misbehaves, so a release is validated against
+// real API behavior before it ships. Its tests are built only with the
+// integration tag:
+//
+//	go test -tags integration ./...
+//
+// TestSandbox runs against an in-memory sandbox project and needs no
+// configuration. TestGCP runs against the Cloud SQL Admin and Monitoring APIs
+// of a designated sandbox GCP project, and is skipped unless both of these
+// are set:
+//
+//	CLOUDSQL_AUTOSCALER_IT_PROJECT     GCP project holding the test instances
+//	CLOUDSQL_AUTOSCALER_IT_INSTANCES   Comma-separated instances to exercise
+//	CLOUDSQL_AUTOSCALER_IT_APPLY       Set to "true" to resize instances; otherwise apply is a dry run
+//
+// A final stage then analyzes, plans and, against the sandbox, resizes from
+// many goroutines at once, as the daemon's cycle and API do, and checks that
+// the analyzer refuses calls once closed. Run under the race detector to fail
+// on unsynchronized state:
+//
+//	go test -race -tags integration ./test/integration
+//
+// Every GCP instance must carry the label autoscaler-integration=true and have
+// at most maxTestCPU vCPUs, so a mistyped project or instance is refused
+// rather than resized. Each instance is resized by the plan's operation, or
+// one step when nothing is planned, and then returned to the machine type
+// it started with.
+package integration
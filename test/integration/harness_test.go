@@ -0,0 +1,344 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/analyzer"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/cloudsql"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/config"
+	"github.com/fraser-isbester/cloudsql-autoscaler/pkg/sandbox"
+)
+
+const (
+	// testLabel marks the instances the harness may resize
+	testLabel = "autoscaler-integration"
+
+	// maxTestCPU is the most vCPUs a GCP test instance may have
+	maxTestCPU = 2
+
+	// sandboxSeed fixes the sandbox fleet so runs are reproducible
+	sandboxSeed = 1
+
+	// concurrentRounds is how many times the concurrent stage repeats each call
+	concurrentRounds = 3
+)
+
+// target is what the harness runs against
+type target struct {
+	cfg       *config.Config
+	opts      analyzer.Options
+	instances []string
+	sandbox   bool
+}
+
+// TestSandbox runs the loop against the in-memory sandbox project
+func TestSandbox(t *testing.T) {
+	ctx := t.Context()
+	fake := sandbox.NewProject("integration-sandbox", sandbox.DefaultFleetSize, sandboxSeed)
+	fake.SetOperationDelay(0)
+	cfg := testConfig()
+	cfg.ProjectID = fake.ProjectID()
+	cfg.MetricsPeriod = 6 * time.Hour
+
+	instances, _, err := fake.ListInstances(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tg := &target{cfg: cfg, opts: analyzer.Options{Config: cfg, SQLAdmin: fake, Metrics: fake}, sandbox: true}
+	for _, inst := range instances {
+		// Replicas are resized with their primary
+		if inst.PrimaryInstance == "" {
+			tg.instances = append(tg.instances, inst.Name)
+		}
+	}
+	runStages(t, tg)
+}
+
+// TestGCP runs the loop against the test instances of a GCP project
+func TestGCP(t *testing.T) {
+	projectID := os.Getenv("CLOUDSQL_AUTOSCALER_IT_PROJECT")
+	var instances []string
+	for _, name := range strings.Split(os.Getenv("CLOUDSQL_AUTOSCALER_IT_INSTANCES"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			instances = append(instances, name)
+		}
+	}
+	if projectID == "" || len(instances) == 0 {
+		t.Skip("CLOUDSQL_AUTOSCALER_IT_PROJECT and CLOUDSQL_AUTOSCALER_IT_INSTANCES must name the test project and instances")
+	}
+
+	apply, _ := strconv.ParseBool(os.Getenv("CLOUDSQL_AUTOSCALER_IT_APPLY"))
+	cfg := testConfig()
+	cfg.ProjectID = projectID
+	cfg.DryRun = !apply
+	cfg.ProbeEnabled = false // The runner is rarely on the instances' network
+	runStages(t, &target{cfg: cfg, opts: analyzer.Options{Config: cfg, Progress: os.Stdout}, instances: instances})
+}
+
+// testConfig returns the configuration both targets start from
+func testConfig() *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.DryRun = false
+	// Tiny instances restart on every resize; the harness exists to do that
+	cfg.Force = true
+	return cfg
+}
+
+// runStages exercises each of tg's instances, then concurrent use and close
+func runStages(t *testing.T, tg *target) {
+	ctx := t.Context()
+	p, err := analyzer.NewProject(ctx, tg.opts)
+	if err != nil {
+		t.Fatalf("failed to create analyzer: %v", err)
+	}
+	defer p.Close()
+	a := p.Analyzer
+
+	if !tg.sandbox {
+		for _, name := range tg.instances {
+			if err := checkTestInstance(ctx, a, name); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	mode := "applying"
+	if tg.cfg.DryRun {
+		mode = "dry run"
+	}
+	t.Logf("Project %s: %d instance(s), %s", tg.cfg.ProjectID, len(tg.instances), mode)
+
+	for _, name := range tg.instances {
+		t.Run(name, func(t *testing.T) {
+			if err := exercise(t, a, tg.cfg, name); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+	if t.Failed() {
+		return
+	}
+
+	t.Run("concurrent", func(t *testing.T) {
+		calls, err := concurrent(ctx, p, tg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("%d calls", calls)
+	})
+
+	t.Run("close", func(t *testing.T) {
+		if err := p.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+		if _, err := p.Analyze(ctx, tg.instances[0]); !errors.Is(err, analyzer.ErrClosed) {
+			t.Fatalf("analyze after close returned %v, expected %v", err, analyzer.ErrClosed)
+		}
+	})
+}
+
+// concurrent makes the analyzer's calls from many goroutines at once and
+// returns how many were made. Against the sandbox it also analyzes the whole
+// project and resizes every instance one step and back while the analyses
+// run; against GCP it only analyzes and plans the test instances.
+func concurrent(ctx context.Context, p *analyzer.ProjectAnalyzer, tg *target) (int, error) {
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		errs  []error
+		calls int
+	)
+	call := func(name string, f func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := f()
+			mu.Lock()
+			defer mu.Unlock()
+			calls++
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}()
+	}
+
+	for round := 0; round < concurrentRounds; round++ {
+		for _, name := range tg.instances {
+			call("analyze "+name, func() error {
+				result, err := p.Analyze(ctx, name)
+				if err != nil {
+					return err
+				}
+				if plan := p.PlanInstance(result); plan == nil {
+					return fmt.Errorf("no plan built")
+				}
+				return nil
+			})
+		}
+		call("progress output", func() error {
+			p.SetProgressOutput(io.Discard)
+			return nil
+		})
+		if !tg.sandbox {
+			continue
+		}
+		call("analyze project", func() error {
+			results, err := p.AnalyzeAllInstances(ctx)
+			if err != nil {
+				return err
+			}
+			if plan := p.PlanScaling(results); plan == nil {
+				return fmt.Errorf("no plan built")
+			}
+			return nil
+		})
+	}
+	if tg.sandbox {
+		for _, name := range tg.instances {
+			call("resize "+name, func() error { return stepAndBack(ctx, p.Analyzer, name) })
+		}
+	}
+
+	wg.Wait()
+	return calls, errors.Join(errs...)
+}
+
+// stepAndBack resizes a sandbox instance one machine type up, or down when it
+// is the largest, and back again
+func stepAndBack(ctx context.Context, a *analyzer.Analyzer, name string) error {
+	instance, err := a.GetInstance(ctx, name)
+	if err != nil {
+		return err
+	}
+	original := instance.MachineType
+	target, err := config.GetNextLargerMachineType(original)
+	if err != nil {
+		if target, err = config.GetNextSmallerMachineType(original); err != nil {
+			return nil
+		}
+	}
+	for _, d := range []*cloudsql.ScalingDecision{
+		{ShouldScale: true, CurrentType: original, RecommendedType: target, Reason: "Integration test concurrent resize"},
+		{ShouldScale: true, CurrentType: target, RecommendedType: original, Reason: "Integration test concurrent revert"},
+	} {
+		if err := a.ApplyScaling(ctx, name, d); err != nil {
+			return fmt.Errorf("apply %s -> %s: %w", d.CurrentType, d.RecommendedType, err)
+		}
+	}
+	return nil
+}
+
+// checkTestInstance refuses instances that are not designated for testing
+func checkTestInstance(ctx context.Context, a *analyzer.Analyzer, name string) error {
+	instance, err := a.GetInstance(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to read test instance %s: %w", name, err)
+	}
+	if instance.Labels[testLabel] != "true" {
+		return fmt.Errorf("instance %s is not labeled %s=true; refusing to resize it", name, testLabel)
+	}
+	if instance.PrimaryInstance != "" {
+		return fmt.Errorf("instance %s is a replica of %s; list the primary instead", name, instance.PrimaryInstance)
+	}
+	mt, err := config.GetMachineType(instance.MachineType)
+	if err != nil {
+		return fmt.Errorf("instance %s: %w", name, err)
+	}
+	if mt.CPU > maxTestCPU {
+		return fmt.Errorf("instance %s has %d vCPUs; test instances may have at most %d", name, mt.CPU, maxTestCPU)
+	}
+	return nil
+}
+
+// exercise runs one instance through the loop: it is analyzed and planned,
+// resized, and returned to its original machine type, checking the instance
+// after each resize
+func exercise(t *testing.T, a *analyzer.Analyzer, cfg *config.Config, name string) error {
+	ctx := t.Context()
+	result, err := a.Analyze(ctx, name)
+	if err != nil {
+		return fmt.Errorf("analyze: %w", err)
+	}
+	if result.Instance == nil || result.Decision == nil {
+		return fmt.Errorf("analyze: result has no instance or decision")
+	}
+	original := result.Instance.MachineType
+	t.Logf("analyze %s: %s (%s)", name, original, result.Decision.ReasonCode())
+
+	plan := a.PlanInstance(result)
+	if plan == nil {
+		return fmt.Errorf("plan: no plan built")
+	}
+	decision := &cloudsql.ScalingDecision{ShouldScale: true, CurrentType: original, Reason: "Integration test resize"}
+	var planned bool
+	for _, op := range plan.Operations {
+		if op.Instance == name {
+			decision.RecommendedType, decision.Reason, decision.ReasonCodes = op.TargetType, op.Reason, op.ReasonCodes
+			planned = true
+		}
+	}
+	if planned {
+		t.Logf("plan    %s: %s -> %s", name, original, decision.RecommendedType)
+	} else {
+		decision.RecommendedType, err = config.GetNextLargerMachineType(original)
+		if err != nil {
+			decision.RecommendedType, err = config.GetNextSmallerMachineType(original)
+		}
+		if err != nil {
+			// Shared-core machine types have no neighbour to resize to
+			t.Logf("plan    %s: nothing planned and %s cannot be resized; skipping apply", name, original)
+			return nil
+		}
+		t.Logf("plan    %s: nothing planned (%d deferred), resizing to %s", name, len(plan.Deferred), decision.RecommendedType)
+	}
+
+	if err := resize(t, a, cfg, name, decision); err != nil {
+		return err
+	}
+	revert := &cloudsql.ScalingDecision{
+		ShouldScale:     true,
+		CurrentType:     decision.RecommendedType,
+		RecommendedType: original,
+		Reason:          "Integration test revert",
+	}
+	return resize(t, a, cfg, name, revert)
+}
+
+// resize applies decision and checks the instance now runs its target machine
+// type and carries the labels written with it
+func resize(t *testing.T, a *analyzer.Analyzer, cfg *config.Config, name string, decision *cloudsql.ScalingDecision) error {
+	ctx := t.Context()
+	if err := a.ApplyScaling(ctx, name, decision); err != nil {
+		return fmt.Errorf("apply %s -> %s: %w", decision.CurrentType, decision.RecommendedType, err)
+	}
+	t.Logf("apply   %s: %s -> %s", name, decision.CurrentType, decision.RecommendedType)
+	if cfg.DryRun {
+		return nil
+	}
+
+	instance, err := a.GetInstance(ctx, name)
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	if instance.MachineType != decision.RecommendedType {
+		return fmt.Errorf("verify: instance runs %s, expected %s", instance.MachineType, decision.RecommendedType)
+	}
+	if got := instance.Labels[cloudsql.LabelDecisionID]; got != decision.ID {
+		return fmt.Errorf("verify: instance labeled with decision %q, expected %q", got, decision.ID)
+	}
+	if got := cloudsql.TierFromLabel(instance.Labels[cloudsql.LabelFromTier]); got != decision.CurrentType {
+		return fmt.Errorf("verify: instance labeled as scaled from %q, expected %q", got, decision.CurrentType)
+	}
+	return nil
+}